@@ -0,0 +1,368 @@
+import { erc20Abi, type PublicClient, type WalletClient } from 'viem';
+import {
+  CheckoutCallbacks,
+  CheckoutError,
+  PaymentDetails,
+  PaymentResult,
+  checkoutError,
+  isUserRejection,
+} from './lifecycle';
+import type { InjectedWallet } from './wallet';
+
+// Settlement details returned by the Go middleware in the PAYMENT-RESPONSE header
+export type SettlementResponse = {
+  success: boolean
+  transaction: string
+  network: string
+  payer?: string
+  errorReason?: string
+};
+
+export type OrderRequest = {
+  orderHeaders?: Record<string, string>
+  /* eslint-disable @typescript-eslint/no-explicit-any */
+  orderData?: Record<string, any>
+};
+
+// Decodes a base64 PAYMENT-RESPONSE header value
+export function decodePaymentResponse(header: string | null): SettlementResponse | undefined {
+  if (!header) return undefined;
+  try {
+    return JSON.parse(atob(header)) as SettlementResponse;
+  } catch (e) {
+    console.warn('Failed to parse PAYMENT-RESPONSE header:', e);
+    return undefined;
+  }
+}
+
+// Sends the order without payment and reads requirements from the 402 PAYMENT-REQUIRED header.
+// Returns undefined if the endpoint didn't ask for payment.
+export async function discoverPayment(
+  paymentEndpoint: string,
+  { orderHeaders, orderData }: OrderRequest
+): Promise<PaymentDetails | undefined> {
+  const response = await fetch(paymentEndpoint, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+      ...(orderHeaders || {})
+    },
+    body: JSON.stringify(orderData || {})
+  });
+
+  if (response.status !== 402) return undefined;
+
+  const paymentRequiredHeader = response.headers.get('PAYMENT-REQUIRED');
+  if (!paymentRequiredHeader) {
+    throw checkoutError('discovery_failed', 'Missing PAYMENT-REQUIRED header in 402 response');
+  }
+
+  const paymentReq = JSON.parse(atob(paymentRequiredHeader));
+  const accepts = paymentReq.accepts;
+  if (!accepts || accepts.length === 0) {
+    throw checkoutError('discovery_failed', 'No accepted payment methods found in PAYMENT-REQUIRED header');
+  }
+
+  // For simplicity, pick the first accepted method
+  const network: string = accepts[0].network;
+  const chainId = parseInt(network.split(':')[1]);
+  if (!network.startsWith('eip155:') || isNaN(chainId)) {
+    throw checkoutError('unsupported_network', `Network ${network} is not supported`);
+  }
+
+  return {
+    network,
+    chainId,
+    asset: accepts[0].asset,
+    payTo: accepts[0].payTo,
+    amount: accepts[0].amount,
+    challengeNonce: accepts[0].extra?.challengeNonce,
+    maxTimeoutSeconds: accepts[0].maxTimeoutSeconds,
+  };
+}
+
+export type PricePreview = {
+  // First accepted payment option, ready for display
+  details: PaymentDetails
+  // All accepted payment options returned by the backend
+  /* eslint-disable @typescript-eslint/no-explicit-any */
+  accepts: Array<Record<string, any>>
+};
+
+// Calls a backend price preview endpoint (ginmw.PricePreviewHandler) to get the exact payable
+// amount for an order without triggering a 402
+export async function previewPrice(
+  previewEndpoint: string,
+  { orderHeaders, orderData }: OrderRequest
+): Promise<PricePreview> {
+  const response = await fetch(previewEndpoint, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+      ...(orderHeaders || {})
+    },
+    body: JSON.stringify(orderData || {})
+  });
+
+  const body = await response.json().catch(() => ({}));
+  if (!response.ok) {
+    throw checkoutError('discovery_failed', body.details || body.error || response.statusText);
+  }
+
+  const accepts = body.accepts;
+  if (!accepts || accepts.length === 0) {
+    throw checkoutError('discovery_failed', 'No accepted payment methods found in price preview');
+  }
+
+  const network: string = accepts[0].network;
+  return {
+    details: {
+      network,
+      chainId: parseInt(network.split(':')[1]),
+      asset: accepts[0].asset,
+      payTo: accepts[0].payTo,
+      amount: accepts[0].amount,
+    },
+    accepts,
+  };
+}
+
+export type TokenInfo = {
+  name: string
+  symbol: string
+  decimals: number
+  version: string
+};
+
+// Reads token metadata needed for display and the EIP-712 domain
+export async function readTokenInfo(client: PublicClient, asset: `0x${string}`): Promise<TokenInfo> {
+  const [name, symbol, decimals] = await Promise.all([
+    client.readContract({ address: asset, abi: erc20Abi, functionName: 'name' }),
+    client.readContract({ address: asset, abi: erc20Abi, functionName: 'symbol' }),
+    client.readContract({ address: asset, abi: erc20Abi, functionName: 'decimals' }),
+  ]);
+
+  // Try to read version from token contract (EIP-712 domain)
+  let version = '2';
+  try {
+    version = await client.readContract({
+      address: asset,
+      abi: [{ name: 'version', type: 'function', stateMutability: 'view', inputs: [], outputs: [{ type: 'string' }] }],
+      functionName: 'version',
+    }) as string;
+  } catch {
+    // Default to '2' if version() doesn't exist
+  }
+
+  return { name, symbol, decimals, version };
+}
+
+// Reads the payer's token balance
+export async function readBalance(client: PublicClient, asset: `0x${string}`, owner: `0x${string}`): Promise<bigint> {
+  return client.readContract({ address: asset, abi: erc20Abi, functionName: 'balanceOf', args: [owner] });
+}
+
+// Creates a base64-encoded x402 v2 payment payload with an ERC-3009 authorization
+export async function createSignature(
+  walletClient: WalletClient,
+  fromAddress: `0x${string}`,
+  details: PaymentDetails,
+  token: Pick<TokenInfo, 'name' | 'version'>
+): Promise<string> {
+  // Sign the server's challenge nonce if it issued one, else generate one, and the validity window
+  const nonce = details.challengeNonce ?? `0x${Array.from(crypto.getRandomValues(new Uint8Array(32)))
+    .map(b => b.toString(16).padStart(2, '0')).join('')}` as `0x${string}`;
+  const validAfter = BigInt(0);
+  // Valid for as long as the route asks, or 1 hour
+  const validBefore = BigInt(Math.floor(Date.now() / 1000 + (details.maxTimeoutSeconds || 3600)));
+
+  const signature = await walletClient.signTypedData({
+    account: fromAddress,
+    domain: {
+      name: token.name,
+      version: token.version,
+      chainId: details.chainId,
+      verifyingContract: details.asset
+    },
+    types: {
+      TransferWithAuthorization: [
+        { name: 'from', type: 'address' },
+        { name: 'to', type: 'address' },
+        { name: 'value', type: 'uint256' },
+        { name: 'validAfter', type: 'uint256' },
+        { name: 'validBefore', type: 'uint256' },
+        { name: 'nonce', type: 'bytes32' }
+      ]
+    },
+    primaryType: 'TransferWithAuthorization',
+    message: {
+      from: fromAddress,
+      to: details.payTo,
+      value: BigInt(details.amount),
+      validAfter,
+      validBefore,
+      nonce
+    }
+  });
+
+  // Create PaymentPayload matching x402 SDK structure
+  const paymentPayload = {
+    x402Version: 2,
+    scheme: 'exact',
+    network: details.network,
+    accepted: {
+      scheme: 'exact',
+      network: details.network,
+      asset: details.asset,
+      amount: details.amount,
+      payTo: details.payTo
+    },
+    payload: {
+      signature,
+      authorization: {
+        from: fromAddress,
+        to: details.payTo,
+        value: details.amount,
+        validAfter: validAfter.toString(),
+        validBefore: validBefore.toString(),
+        nonce
+      }
+    }
+  };
+
+  return btoa(JSON.stringify(paymentPayload));
+}
+
+// Reads the most specific error message from a failed payment response
+async function readErrorMessage(response: Response): Promise<string> {
+  // Check for error in PAYMENT-REQUIRED header (v2 format)
+  const paymentRequiredHeader = response.status === 402 ? response.headers.get('PAYMENT-REQUIRED') : null;
+  if (paymentRequiredHeader) {
+    try {
+      const paymentReq = JSON.parse(atob(paymentRequiredHeader));
+      if (paymentReq.details || paymentReq.error) {
+        return paymentReq.details || paymentReq.error;
+      }
+    } catch (e) {
+      console.warn('Failed to parse PAYMENT-REQUIRED header:', e);
+    }
+  }
+
+  // Fallback to body
+  try {
+    const errorJson = JSON.parse(await response.text());
+    if (errorJson.details || errorJson.error) {
+      return errorJson.details || errorJson.error;
+    }
+  } catch {
+    // Use statusText as fallback
+  }
+  return response.statusText;
+}
+
+// Resends the order with the PAYMENT-SIGNATURE header.
+// Throws a CheckoutError if the backend rejects the payment.
+export async function submitPayment(
+  paymentEndpoint: string,
+  signatureData: string,
+  details: PaymentDetails,
+  { orderHeaders, orderData }: OrderRequest,
+  onSettling?: (details: PaymentDetails) => void
+): Promise<PaymentResult> {
+  const response = await fetch(paymentEndpoint, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+      'PAYMENT-SIGNATURE': signatureData,
+      ...(orderHeaders || {})
+    },
+    body: orderData ? JSON.stringify(orderData) : undefined
+  });
+
+  if (!response.ok) {
+    const message = await readErrorMessage(response);
+    const error: CheckoutError = checkoutError(
+      /insufficient.*(balance|funds)/i.test(message) ? 'insufficient_balance' : 'payment_failed',
+      message
+    );
+    throw error;
+  }
+
+  onSettling?.(details);
+
+  const settlement = decodePaymentResponse(response.headers.get('PAYMENT-RESPONSE'));
+  const responseData = await response.json();
+  return {
+    response: responseData,
+    orderId: responseData.orderId,
+    transaction: responseData.transaction || settlement?.transaction,
+    payer: responseData.payer || settlement?.payer,
+    network: settlement?.network || details.network,
+  };
+}
+
+// Converts smallest token units to a display string
+export function formatAmount(amount: string, decimals: number | undefined): string {
+  if (decimals === undefined) return amount;
+  return (Number(amount) / Math.pow(10, decimals)).toFixed(Math.min(decimals, 6));
+}
+
+export type PayOptions = OrderRequest & CheckoutCallbacks & {
+  paymentEndpoint?: string
+  // Custom callback (overrides paymentEndpoint if provided)
+  onPaymentCreated?: (signatureData: string) => Promise<void>
+};
+
+// Runs the full payment step: balance check, signature, submission, and lifecycle callbacks.
+// Failures are reported through onError and rethrown as a CheckoutError.
+export async function pay(
+  wallet: InjectedWallet,
+  details: PaymentDetails,
+  token: TokenInfo,
+  options: PayOptions
+): Promise<PaymentResult | undefined> {
+  const { paymentEndpoint, onPaymentCreated, onVerifying, onSettling, onSuccess, onError } = options;
+  try {
+    const balance = await readBalance(wallet.publicClient, details.asset, wallet.address);
+    if (balance < BigInt(details.amount)) {
+      throw checkoutError(
+        'insufficient_balance',
+        `Your wallet balance is below ${formatAmount(details.amount, token.decimals)} ${token.symbol}`
+      );
+    }
+
+    let signatureData: string;
+    try {
+      signatureData = await createSignature(wallet.walletClient, wallet.address, details, token);
+    } catch (error) {
+      if (isUserRejection(error)) {
+        throw checkoutError('signature_rejected', 'You rejected the signature request in your wallet', error);
+      }
+      throw error;
+    }
+
+    onVerifying?.(details);
+    if (onPaymentCreated) {
+      await onPaymentCreated(signatureData);
+      return undefined;
+    }
+    if (!paymentEndpoint) {
+      console.warn('No payment handler provided. Pass onPaymentCreated or paymentEndpoint to handle payment.');
+      return undefined;
+    }
+
+    const result = await submitPayment(paymentEndpoint, signatureData, details, options, onSettling);
+    onSuccess?.(result);
+    return result;
+  } catch (error) {
+    const checkoutErr: CheckoutError = isCheckoutError(error)
+      ? error
+      : checkoutError('payment_failed', (error as Error).message || 'Payment failed', error);
+    onError?.(checkoutErr);
+    throw checkoutErr;
+  }
+}
+
+export function isCheckoutError(error: unknown): error is CheckoutError {
+  return !!error && typeof error === 'object' && 'kind' in error && 'message' in error;
+}
@@ -0,0 +1,47 @@
+// Block explorers keyed by EVM chain ID (the reference part of a CAIP-2 network)
+const explorers: Record<number, { name: string; url: string }> = {
+  1: { name: 'Etherscan', url: 'https://etherscan.io' },
+  11155111: { name: 'Etherscan', url: 'https://sepolia.etherscan.io' },
+  8453: { name: 'Basescan', url: 'https://basescan.org' },
+  84532: { name: 'Basescan', url: 'https://sepolia.basescan.org' },
+  10: { name: 'Optimism Explorer', url: 'https://optimistic.etherscan.io' },
+  11155420: { name: 'Optimism Explorer', url: 'https://sepolia-optimism.etherscan.io' },
+  42161: { name: 'Arbiscan', url: 'https://arbiscan.io' },
+  421614: { name: 'Arbiscan', url: 'https://sepolia.arbiscan.io' },
+  137: { name: 'Polygonscan', url: 'https://polygonscan.com' },
+  80002: { name: 'Polygonscan', url: 'https://amoy.polygonscan.com' },
+  43114: { name: 'Snowtrace', url: 'https://snowtrace.io' },
+  43113: { name: 'Snowtrace', url: 'https://testnet.snowtrace.io' },
+  59144: { name: 'Lineascan', url: 'https://lineascan.build' },
+  59141: { name: 'Lineascan', url: 'https://sepolia.lineascan.build' },
+  324: { name: 'zkSync Explorer', url: 'https://explorer.zksync.io' },
+  300: { name: 'zkSync Explorer', url: 'https://sepolia.explorer.zksync.io' },
+};
+
+// Parses a CAIP-2 network (e.g. "eip155:84532") into its EVM chain ID
+export function chainIdFromNetwork(network: string): number | undefined {
+  const [namespace, reference] = network.split(':');
+  if (namespace !== 'eip155' || !reference) return undefined;
+  const chainId = parseInt(reference);
+  return isNaN(chainId) ? undefined : chainId;
+}
+
+// Returns the explorer name for a CAIP-2 network, if known
+export function explorerName(network: string): string | undefined {
+  const chainId = chainIdFromNetwork(network);
+  return chainId !== undefined ? explorers[chainId]?.name : undefined;
+}
+
+// Builds a transaction deep link for a CAIP-2 network, if the explorer is known
+export function explorerTxUrl(network: string, transaction: string): string | undefined {
+  const chainId = chainIdFromNetwork(network);
+  const explorer = chainId !== undefined ? explorers[chainId] : undefined;
+  return explorer ? `${explorer.url}/tx/${transaction}` : undefined;
+}
+
+// Builds an address deep link for a CAIP-2 network, if the explorer is known
+export function explorerAddressUrl(network: string, address: string): string | undefined {
+  const chainId = chainIdFromNetwork(network);
+  const explorer = chainId !== undefined ? explorers[chainId] : undefined;
+  return explorer ? `${explorer.url}/address/${address}` : undefined;
+}
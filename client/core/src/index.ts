@@ -0,0 +1,25 @@
+// Main export for @xtended402/core package
+export {
+  discoverPayment,
+  previewPrice,
+  readTokenInfo,
+  readBalance,
+  createSignature,
+  submitPayment,
+  decodePaymentResponse,
+  formatAmount,
+  pay,
+  isCheckoutError,
+} from './checkout';
+export type { OrderRequest, PayOptions, PricePreview, SettlementResponse, TokenInfo } from './checkout';
+export { checkoutError, errorTitles, isUserRejection } from './lifecycle';
+export type {
+  CheckoutCallbacks,
+  CheckoutError,
+  CheckoutErrorKind,
+  PaymentDetails,
+  PaymentResult,
+} from './lifecycle';
+export { chainIdFromNetwork, explorerName, explorerTxUrl, explorerAddressUrl } from './explorers';
+export { connectInjectedWallet, getChain, supportedChains } from './wallet';
+export type { InjectedWallet } from './wallet';
@@ -0,0 +1,80 @@
+import {
+  createPublicClient,
+  createWalletClient,
+  custom,
+  http,
+  type Chain,
+  type PublicClient,
+  type WalletClient,
+} from 'viem';
+import {
+  mainnet, sepolia,
+  base, baseSepolia,
+  optimism, optimismSepolia,
+  arbitrum, arbitrumSepolia,
+  polygon, polygonAmoy,
+  avalanche, avalancheFuji,
+  linea, lineaSepolia,
+  zksync, zksyncSepoliaTestnet
+} from 'viem/chains';
+import { checkoutError, isUserRejection } from './lifecycle';
+
+export const supportedChains: readonly Chain[] = [
+  mainnet, sepolia,
+  base, baseSepolia,
+  optimism, optimismSepolia,
+  arbitrum, arbitrumSepolia,
+  polygon, polygonAmoy,
+  avalanche, avalancheFuji,
+  linea, lineaSepolia,
+  zksync, zksyncSepoliaTestnet
+];
+
+export function getChain(chainId: number): Chain | undefined {
+  return supportedChains.find(chain => chain.id === chainId);
+}
+
+export type InjectedWallet = {
+  address: `0x${string}`
+  walletClient: WalletClient
+  publicClient: PublicClient
+};
+
+// Connects the browser's injected EIP-1193 wallet and switches it to the requested chain
+export async function connectInjectedWallet(chainId: number): Promise<InjectedWallet> {
+  /* eslint-disable @typescript-eslint/no-explicit-any */
+  const ethereum = typeof window !== 'undefined' ? (window as any).ethereum : undefined;
+  if (!ethereum) {
+    throw checkoutError('payment_failed', 'No wallets available. Please install a browser wallet to proceed.');
+  }
+
+  const chain = getChain(chainId);
+  if (!chain) {
+    throw checkoutError('unsupported_network', `Chain ID ${chainId} is not supported`);
+  }
+
+  const walletClient = createWalletClient({ chain, transport: custom(ethereum) });
+  let address: `0x${string}`;
+  try {
+    [address] = await walletClient.requestAddresses();
+  } catch (error) {
+    if (isUserRejection(error)) {
+      throw checkoutError('signature_rejected', 'You rejected the connection request in your wallet', error);
+    }
+    throw error;
+  }
+
+  if (await walletClient.getChainId() !== chainId) {
+    try {
+      await walletClient.switchChain({ id: chainId });
+    } catch (error) {
+      throw checkoutError('wrong_network', `Switch your wallet to ${chain.name} to continue`, error);
+    }
+  }
+
+  return {
+    address,
+    walletClient,
+    publicClient: createPublicClient({ chain, transport: http() }) as PublicClient,
+  };
+}
@@ -0,0 +1,5 @@
+// Main export for @xtended402/nextjs package (server-only)
+export { createX402RouteHandler } from './proxy';
+export type { ProxyOptions } from './proxy';
+export { fetchPaymentRequirements, submitPayment } from './server';
+export type { BackendRequest, PaymentRequired, PaymentSubmission } from './server';
@@ -0,0 +1,83 @@
+// Request headers forwarded to the backend by default
+const defaultForwardHeaders = [
+  'accept',
+  'accept-language',
+  'authorization',
+  'content-type',
+  'cookie',
+  'user-agent',
+  'payment-signature',
+];
+
+// Response headers that must not be copied from the backend response
+const hopByHopHeaders = new Set([
+  'connection',
+  'content-encoding', // fetch already decoded the body
+  'content-length',
+  'keep-alive',
+  'transfer-encoding',
+  'set-cookie', // Copied individually below
+]);
+
+export type ProxyOptions = {
+  // Backend base URL (e.g., "http://localhost:8080")
+  backendUrl: string
+  // Backend path; defaults to the incoming request path
+  path?: string | ((req: Request) => string)
+  // Additional request headers to forward (e.g., "x-tenant-id")
+  forwardHeaders?: string[]
+  // Extra headers for the backend request, e.g. session context derived from cookies
+  getHeaders?: (req: Request) => HeadersInit | Promise<HeadersInit>
+};
+
+// Creates an App Router route handler that proxies x402 requests to the backend.
+// Forwards PAYMENT-SIGNATURE, cookies, and auth headers, and returns PAYMENT-REQUIRED,
+// PAYMENT-RESPONSE, and Set-Cookie headers to the browser unchanged.
+export function createX402RouteHandler(options: ProxyOptions) {
+  const forwardHeaders = [...defaultForwardHeaders, ...(options.forwardHeaders || []).map(h => h.toLowerCase())];
+
+  return async function handler(req: Request): Promise<Response> {
+    const incoming = new URL(req.url);
+    const path = typeof options.path === 'function'
+      ? options.path(req)
+      : options.path ?? incoming.pathname;
+    const target = new URL(path + incoming.search, options.backendUrl);
+
+    const headers = new Headers();
+    for (const name of forwardHeaders) {
+      const value = req.headers.get(name);
+      if (value) headers.set(name, value);
+    }
+    headers.set('x-forwarded-host', incoming.host);
+    headers.set('x-forwarded-proto', incoming.protocol.replace(':', ''));
+    const forwardedFor = req.headers.get('x-forwarded-for');
+    if (forwardedFor) headers.set('x-forwarded-for', forwardedFor);
+
+    if (options.getHeaders) {
+      new Headers(await options.getHeaders(req)).forEach((value, name) => headers.set(name, value));
+    }
+
+    const hasBody = req.method !== 'GET' && req.method !== 'HEAD';
+    const backendResponse = await fetch(target, {
+      method: req.method,
+      headers,
+      body: hasBody ? await req.arrayBuffer() : undefined,
+      redirect: 'manual',
+      cache: 'no-store',
+    });
+
+    const responseHeaders = new Headers();
+    backendResponse.headers.forEach((value, name) => {
+      if (!hopByHopHeaders.has(name)) responseHeaders.set(name, value);
+    });
+    for (const cookie of backendResponse.headers.getSetCookie()) {
+      responseHeaders.append('set-cookie', cookie);
+    }
+
+    return new Response(backendResponse.body, {
+      status: backendResponse.status,
+      statusText: backendResponse.statusText,
+      headers: responseHeaders,
+    });
+  };
+}
@@ -0,0 +1,142 @@
+import { cookies, headers as requestHeaders } from 'next/headers';
+
+// Payment requirements decoded from the PAYMENT-REQUIRED header
+export type PaymentRequired = {
+  x402Version: number
+  error?: string
+  resource?: { url: string; description?: string; mimeType?: string }
+  accepts: Array<{
+    scheme: string
+    network: string
+    asset: string
+    amount: string
+    payTo: string
+    maxTimeoutSeconds: number
+    extra?: Record<string, unknown>
+  }>
+};
+
+export type BackendRequest = {
+  // Full backend URL (e.g., `${process.env.BACKEND_URL}/api/purchase`)
+  url: string
+  method?: string
+  /* eslint-disable @typescript-eslint/no-explicit-any */
+  orderData?: Record<string, any>
+  headers?: Record<string, string>
+};
+
+// Builds backend request headers carrying the caller's cookies and auth context
+async function sessionHeaders(extra?: Record<string, string>): Promise<Headers> {
+  const headers = new Headers({ 'Content-Type': 'application/json', ...(extra || {}) });
+
+  const cookieHeader = (await cookies()).toString();
+  if (cookieHeader) headers.set('cookie', cookieHeader);
+
+  const incoming = await requestHeaders();
+  const authorization = incoming.get('authorization');
+  if (authorization && !headers.has('authorization')) headers.set('authorization', authorization);
+
+  return headers;
+}
+
+// Fetches payment requirements for an order from a server action or server component.
+// Returns undefined if the backend didn't respond with 402.
+export async function fetchPaymentRequirements(request: BackendRequest): Promise<PaymentRequired | undefined> {
+  const response = await fetch(request.url, {
+    method: request.method || 'POST',
+    headers: await sessionHeaders(request.headers),
+    body: JSON.stringify(request.orderData || {}),
+    cache: 'no-store',
+  });
+
+  if (response.status !== 402) return undefined;
+
+  const header = response.headers.get('PAYMENT-REQUIRED');
+  if (!header) {
+    throw new Error('Missing PAYMENT-REQUIRED header in 402 response');
+  }
+  return JSON.parse(Buffer.from(header, 'base64').toString('utf8')) as PaymentRequired;
+}
+
+export type PaymentSubmission = {
+  ok: boolean
+  status: number
+  body: any
+  // Decoded PAYMENT-RESPONSE header (settlement details) when present
+  settlement?: { success: boolean; transaction: string; network: string; payer?: string }
+};
+
+// Submits a signed payment from a server action, forwarding the caller's cookies.
+// Set-Cookie headers from the backend are applied to the Next.js response.
+export async function submitPayment(request: BackendRequest, signatureData: string): Promise<PaymentSubmission> {
+  const headers = await sessionHeaders(request.headers);
+  headers.set('PAYMENT-SIGNATURE', signatureData);
+
+  const response = await fetch(request.url, {
+    method: request.method || 'POST',
+    headers,
+    body: request.orderData ? JSON.stringify(request.orderData) : undefined,
+    cache: 'no-store',
+  });
+
+  const cookieStore = await cookies();
+  for (const cookie of response.headers.getSetCookie()) {
+    const parsed = parseSetCookie(cookie);
+    if (parsed) cookieStore.set(parsed);
+  }
+
+  const settlementHeader = response.headers.get('PAYMENT-RESPONSE');
+  let body: any = undefined;
+  try {
+    body = await response.json();
+  } catch {
+    // Empty or non-JSON body
+  }
+
+  return {
+    ok: response.ok,
+    status: response.status,
+    body,
+    settlement: settlementHeader
+      ? JSON.parse(Buffer.from(settlementHeader, 'base64').toString('utf8'))
+      : undefined,
+  };
+}
+
+type ParsedCookie = {
+  name: string
+  value: string
+  path?: string
+  domain?: string
+  maxAge?: number
+  expires?: Date
+  httpOnly?: boolean
+  secure?: boolean
+  sameSite?: 'lax' | 'strict' | 'none'
+};
+
+// Parses a Set-Cookie header so backend session cookies keep their attributes
+function parseSetCookie(header: string): ParsedCookie | undefined {
+  const [pair, ...attributes] = header.split(';').map(part => part.trim());
+  const separator = pair.indexOf('=');
+  if (separator <= 0) return undefined;
+
+  const cookie: ParsedCookie = {
+    name: pair.slice(0, separator),
+    value: pair.slice(separator + 1),
+  };
+  for (const attribute of attributes) {
+    const [key, ...rest] = attribute.split('=');
+    const value = rest.join('=');
+    switch (key.toLowerCase()) {
+      case 'path': cookie.path = value; break;
+      case 'domain': cookie.domain = value; break;
+      case 'max-age': cookie.maxAge = parseInt(value); break;
+      case 'expires': cookie.expires = new Date(value); break;
+      case 'httponly': cookie.httpOnly = true; break;
+      case 'secure': cookie.secure = true; break;
+      case 'samesite': cookie.sameSite = value.toLowerCase() as ParsedCookie['sameSite']; break;
+    }
+  }
+  return cookie;
+}
@@ -6,6 +6,7 @@ import { WagmiConfig, SupportedChainId } from './wagmi';
 import { QueryClient, QueryClientProvider } from '@tanstack/react-query';
 import { Dialog, DialogBackdrop, DialogPanel, DialogTitle } from '@headlessui/react'
 import { ConnectAndPay } from './connect-pay';
+import { CheckoutCallbacks } from './lifecycle';
 import { FiX } from "react-icons/fi";
 
 export type CheckoutProps = {
@@ -23,7 +24,7 @@ export type CheckoutProps = {
   buttonBackgroundColor?: string
   displayMode?: 'light' | 'dark' | 'system'
   accentColor?: string
-};
+} & CheckoutCallbacks;
 
 export function Checkout({
   paymentEndpoint,
@@ -37,6 +38,10 @@ export function Checkout({
   buttonBackgroundColor,
   displayMode = 'system',
   accentColor = '#338aea',
+  onVerifying,
+  onSettling,
+  onSuccess,
+  onError,
 }: CheckoutProps) {
   const [isOpen, setIsOpen] = useState(false);
   const [isDark, setIsDark] = useState(true);
@@ -126,6 +131,10 @@ export function Checkout({
                   onPaymentCreated={onPaymentCreated}
                   isDark={isDark}
                   accentColor={accentColor}
+                  onVerifying={onVerifying}
+                  onSettling={onSettling}
+                  onSuccess={onSuccess}
+                  onError={onError}
                 />
 
               </DialogPanel>
@@ -7,6 +7,16 @@ import { SupportedChainId } from './wagmi';
 import { WalletOptions } from './wallet-options';
 import { CreateSignature } from './create-signature';
 import { useIsERC3009Token } from './detect-standard';
+import {
+  CheckoutCallbacks,
+  CheckoutError,
+  CheckoutErrorKind,
+  PaymentDetails,
+  checkoutError,
+  errorTitles,
+  isUserRejection,
+} from './lifecycle';
+import { Receipt, decodePaymentResponse } from './receipt';
 
 export type ConnectAndPayProps = {
   paymentEndpoint?: string
@@ -17,7 +27,7 @@ export type ConnectAndPayProps = {
   onPaymentCreated?: (signatureData: string) => Promise<void>
   isDark: boolean
   accentColor: string
-};
+} & CheckoutCallbacks;
 
 const supportedChainIds = [
   1, 10, 11155111, 8453, 84532, 11155420, 42161, 421614, 137, 80002, 43114, 43113, 59144, 59141, 324, 300
@@ -34,12 +44,19 @@ export function ConnectAndPay({
   onPaymentCreated,
   isDark,
   accentColor,
+  onVerifying,
+  onSettling,
+  onSuccess,
+  onError,
 }: ConnectAndPayProps) {
   const [paymentResponse, setPaymentResponse] = useState<{
     success: boolean;
     message: string;
+    errorKind?: CheckoutErrorKind;
     orderId?: string;
     transaction?: string;
+    payer?: string;
+    network?: string;
   } | null>(null);
   const [processingPayment, setProcessingPayment] = useState(false);
   const [isHoveringButton, setIsHoveringButton] = useState(false);
@@ -54,6 +71,11 @@ export function ConnectAndPay({
   const switchChain = useSwitchChain()
   const { data: walletClient } = useWalletClient({ chainId })
 
+  const reportError = (error: CheckoutError) => {
+    setPaymentResponse({ success: false, message: error.message, errorKind: error.kind });
+    onError?.(error);
+  };
+
   useEffect(() => {
     if (isConnected && chain?.id !== chainId && chainId) {
       switchChain.mutate({ chainId })
@@ -94,6 +116,7 @@ export function ConnectAndPay({
             if (!isSupportedChainId(parsedChainId)) {
               console.error('Unsupported chain ID from payment discovery:', parsedChainId);
               setIsChainSupported(false);
+              onError?.(checkoutError('unsupported_network', `Chain ID ${parsedChainId} is not supported`));
               return;
             } else {
               setIsChainSupported(true);
@@ -113,12 +136,14 @@ export function ConnectAndPay({
         }
       } catch (error) {
         console.error('Price discovery failed:', error);
+        onError?.(checkoutError('discovery_failed', (error as Error).message || 'Price discovery failed', error));
       } finally {
         setIsDiscoveringAccepts(false);
       }
     };
 
     discoverAccepts();
+    // eslint-disable-next-line react-hooks/exhaustive-deps
   }, [paymentEndpoint, orderData, orderHeaders])
 
   // Validate token supports ERC-3009
@@ -127,6 +152,28 @@ export function ConnectAndPay({
     chainId
   )
 
+  // Report unsupported tokens once validation completes
+  useEffect(() => {
+    if (!isDiscoveringAccepts && !isCheckingToken && !isERC3009 && tokenAddress) {
+      onError?.(checkoutError('unsupported_token', 'This token does not support ERC-3009 gasless transfers'));
+    }
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [isDiscoveringAccepts, isCheckingToken, isERC3009, tokenAddress])
+
+  // Read payer balance to warn before signing
+  const { data: tokenBalance } = useReadContract({
+    address: tokenAddress,
+    abi: erc20Abi,
+    functionName: 'balanceOf',
+    args: address ? [address] : undefined,
+    chainId: chainId,
+    query: { enabled: !!address && !!tokenAddress && !!chainId }
+  })
+
+  const hasInsufficientBalance = tokenBalance !== undefined && amount !== null
+    && (tokenBalance as bigint) < BigInt(amount);
+  const isWrongNetwork = isConnected && !!chainId && chain?.id !== chainId && switchChain.isError;
+
   // Get token metadata for display
   const { data: tokenSymbol } = useReadContract({
     address: tokenAddress,
@@ -190,26 +237,55 @@ export function ConnectAndPay({
       return;
     }
 
+    if (chain?.id !== chainId) {
+      reportError(checkoutError('wrong_network', `Switch your wallet to chain ${chainId} to continue`));
+      return;
+    }
+
+    if (hasInsufficientBalance) {
+      reportError(checkoutError('insufficient_balance', `Your wallet balance is below ${displayAmount} ${tokenSymbol || ''}`.trim()));
+      return;
+    }
+
+    const details: PaymentDetails = {
+      network,
+      chainId,
+      asset: tokenAddress,
+      payTo: recipientAddress,
+      amount,
+    };
+
     setProcessingPayment(true);
 
     try {
       // Create signature with discovered amount
-      const signatureData = await CreateSignature(
-        walletClient,
-        address,
-        recipientAddress,
-        tokenAddress,
-        amount,
-        network,
-        chainId,
-        tokenName as string,
-        (tokenVersion as string) || '2'
-      );
+      let signatureData: string;
+      try {
+        signatureData = await CreateSignature(
+          walletClient,
+          address,
+          recipientAddress,
+          tokenAddress,
+          amount,
+          network,
+          chainId,
+          tokenName as string,
+          (tokenVersion as string) || '2'
+        );
+      } catch (error) {
+        if (isUserRejection(error)) {
+          reportError(checkoutError('signature_rejected', 'You rejected the signature request in your wallet', error));
+          return;
+        }
+        throw error;
+      }
 
       // Send payment with PAYMENT-SIGNATURE header
       if (onPaymentCreated) {
+        onVerifying?.(details);
         await onPaymentCreated(signatureData);
       } else if (paymentEndpoint) {
+        onVerifying?.(details);
         const response = await fetch(paymentEndpoint, {
           method: 'POST',
           headers: {
@@ -258,15 +334,35 @@ export function ConnectAndPay({
           }
 
           console.warn('Payment endpoint error:', errorMessage);
-          setPaymentResponse({ success: false, message: errorMessage });
+          const kind: CheckoutErrorKind = /insufficient.*(balance|funds)/i.test(errorMessage)
+            ? 'insufficient_balance'
+            : 'payment_failed';
+          reportError(checkoutError(kind, errorMessage));
         } else {
+          onSettling?.(details);
+
+          // Settlement details from PAYMENT-RESPONSE header (v2 format)
+          const settlement = decodePaymentResponse(response.headers.get('PAYMENT-RESPONSE'));
+
           const responseData = await response.json();
           console.log('Payment processed successfully:', responseData);
+          const transaction = responseData.transaction || settlement?.transaction;
+          const payer = responseData.payer || settlement?.payer;
+          const settledNetwork = settlement?.network || network;
           setPaymentResponse({
             success: true,
             message: 'Payment successful',
             orderId: responseData.orderId,
-            transaction: responseData.transaction
+            transaction,
+            payer,
+            network: settledNetwork
+          });
+          onSuccess?.({
+            response: responseData,
+            orderId: responseData.orderId,
+            transaction,
+            payer,
+            network: settledNetwork,
           });
         }
       } else {
@@ -276,7 +372,7 @@ export function ConnectAndPay({
 
     } catch (error) {
       console.error('Payment failed:', error);
-      setPaymentResponse({ success: false, message: (error as Error).message || 'Payment failed' });
+      reportError(checkoutError('payment_failed', (error as Error).message || 'Payment failed', error));
     } finally {
       setProcessingPayment(false);
     }
@@ -286,7 +382,7 @@ export function ConnectAndPay({
   if (!isDiscoveringAccepts && !isCheckingToken && !isERC3009 && tokenAddress) {
     return (
       <div style={{ padding: '1rem', backgroundColor: '#fef2f2', color: '#991b1b', border: '1px solid #fecaca', borderRadius: '0.5rem' }}>
-        <h3 style={{ fontWeight: '600', marginBottom: '0.5rem' }}>Unsupported Token</h3>
+        <h3 style={{ fontWeight: '600', marginBottom: '0.5rem' }}>{errorTitles.unsupported_token}</h3>
         <p style={{ fontSize: '0.875rem' }}>
           This token does not support ERC-3009 gasless transfers.
         </p>
@@ -301,7 +397,7 @@ export function ConnectAndPay({
   if (chainId && !isChainSupported) {
     return (
       <div style={{ padding: '1rem', backgroundColor: '#fef2f2', color: '#991b1b', border: '1px solid #fecaca', borderRadius: '0.5rem' }}>
-        <h3 style={{ fontWeight: '600', marginBottom: '0.5rem' }}>Unsupported Network</h3>
+        <h3 style={{ fontWeight: '600', marginBottom: '0.5rem' }}>{errorTitles.unsupported_network}</h3>
         <p style={{ fontSize: '0.875rem' }}>
           The selected network is not supported by this application.
         </p>
@@ -322,6 +418,34 @@ export function ConnectAndPay({
   return (
     <>
       <div style={{ height: '12rem', overflowY: 'auto' }}>
+        { !paymentResponse && !processingPayment && (isWrongNetwork || hasInsufficientBalance) &&
+          <div style={{
+            marginBottom: '1rem',
+            padding: '0.75rem 1rem',
+            backgroundColor: isDark ? '#422006' : '#fffbeb',
+            color: isDark ? '#fcd34d' : '#92400e',
+            border: isDark ? '1px solid #a16207' : '1px solid #fde68a'
+          }}>
+            <h3 style={{ fontWeight: '600', marginBottom: '0.25rem' }}>
+              {isWrongNetwork ? errorTitles.wrong_network : errorTitles.insufficient_balance}
+            </h3>
+            {isWrongNetwork ? (
+              <p style={{ fontSize: '0.875rem' }}>
+                Your wallet is on a different network.{' '}
+                <span
+                  style={{ textDecoration: 'underline', cursor: 'pointer' }}
+                  onClick={() => chainId && switchChain.mutate({ chainId })}>
+                  Switch network
+                </span>
+              </p>
+            ) : (
+              <p style={{ fontSize: '0.875rem' }}>
+                This purchase requires {displayAmount} {tokenSymbol || ''}. Add funds to continue.
+              </p>
+            )}
+          </div>
+        }
+
         { !paymentResponse && !processingPayment &&
           <WalletOptions
             chainId={chainId}
@@ -339,7 +463,9 @@ export function ConnectAndPay({
             color: isDark ? '#fca5a5' : '#991b1b',
             border: isDark ? '1px solid #52525b' : '1px solid #fecaca'
           }}>
-            <h3 style={{ fontWeight: '600', marginBottom: '0.5rem' }}>Payment Error</h3>
+            <h3 style={{ fontWeight: '600', marginBottom: '0.5rem' }}>
+              {errorTitles[paymentResponse.errorKind || 'payment_failed']}
+            </h3>
             <p>{paymentResponse.message}</p>
           </div>
         }
@@ -354,16 +480,26 @@ export function ConnectAndPay({
           }}>
             <h3 style={{ fontWeight: '600', marginBottom: '0.5rem' }}>Payment Successful</h3>
             <p>Your payment has been processed successfully.</p>
-            {paymentResponse.orderId && (
+            {paymentResponse.transaction ? (
+              <div style={{ marginTop: '0.5rem' }}>
+                <Receipt
+                  transaction={paymentResponse.transaction}
+                  network={paymentResponse.network || network}
+                  amount={amount || undefined}
+                  asset={tokenAddress}
+                  decimals={tokenDecimals !== undefined ? Number(tokenDecimals) : undefined}
+                  symbol={tokenSymbol as string | undefined}
+                  payer={paymentResponse.payer}
+                  orderId={paymentResponse.orderId}
+                  isDark={isDark}
+                  accentColor={isDark ? '#6ee7b7' : '#047857'}
+                />
+              </div>
+            ) : paymentResponse.orderId && (
               <p style={{ marginTop: '0.5rem' }}>
                 Order ID: {paymentResponse.orderId}
               </p>
             )}
-            {paymentResponse.transaction && (
-              <p style={{ marginTop: '0.25rem', wordBreak: 'break-all' }}>
-                Transaction: {paymentResponse.transaction}
-              </p>
-            )}
           </div>
         }
 
@@ -382,7 +518,7 @@ export function ConnectAndPay({
       </div>
 
       { !paymentResponse && <button
-        disabled={!isConnected || (chain?.id !== chainId) || isCheckingToken || processingPayment || isDiscoveringAccepts || !amount}
+        disabled={!isConnected || (chain?.id !== chainId) || isCheckingToken || processingPayment || isDiscoveringAccepts || !amount || hasInsufficientBalance}
         style={{
           width: '100%',
           marginTop: '1.25rem',
@@ -392,7 +528,7 @@ export function ConnectAndPay({
           paddingBottom: '0.5rem',
           backgroundColor: isHoveringButton ? darkenColor(accentColor) : accentColor,
           color: 'white',
-          opacity: (!isConnected || (chain?.id !== chainId) || isCheckingToken || isDiscoveringAccepts || !amount) ? 0.5 : 1,
+          opacity: (!isConnected || (chain?.id !== chainId) || isCheckingToken || isDiscoveringAccepts || !amount || hasInsufficientBalance) ? 0.5 : 1,
           cursor: isHoveringButton ? 'pointer' : 'default'
         }}
         onMouseEnter={() => setIsHoveringButton(true)}
@@ -1,3 +1,13 @@
 // Main export for @xtended402/react package
 export { Checkout } from './checkout';
 export type { CheckoutProps } from './checkout';
+export { Receipt, decodePaymentResponse } from './receipt';
+export type { ReceiptProps, SettlementResponse } from './receipt';
+export { explorerTxUrl, explorerAddressUrl, chainIdFromNetwork } from './explorers';
+export type {
+  CheckoutCallbacks,
+  CheckoutError,
+  CheckoutErrorKind,
+  PaymentDetails,
+  PaymentResult,
+} from './lifecycle';
@@ -0,0 +1,75 @@
+import { UserRejectedRequestError } from 'viem';
+
+// Payment requirements selected from the PAYMENT-REQUIRED header
+export type PaymentDetails = {
+  network: string
+  chainId: number
+  asset: `0x${string}`
+  payTo: `0x${string}`
+  amount: string
+};
+
+// Result passed to onSuccess after the backend accepts the payment
+export type PaymentResult = {
+  /* eslint-disable @typescript-eslint/no-explicit-any */
+  response: any
+  orderId?: string
+  transaction?: string
+  payer?: string
+  network?: string
+};
+
+// Distinct failure states the component can render and report
+export type CheckoutErrorKind =
+  | 'insufficient_balance'
+  | 'wrong_network'
+  | 'signature_rejected'
+  | 'unsupported_network'
+  | 'unsupported_token'
+  | 'discovery_failed'
+  | 'payment_failed';
+
+export type CheckoutError = {
+  kind: CheckoutErrorKind
+  message: string
+  cause?: unknown
+};
+
+// Lifecycle callbacks for merchant UX customization and analytics.
+// The backend verifies and settles within a single request, so onVerifying fires when
+// the signed payment is submitted and onSettling fires once the backend has responded
+// successfully, before the response body is read.
+export type CheckoutCallbacks = {
+  onVerifying?: (details: PaymentDetails) => void
+  onSettling?: (details: PaymentDetails) => void
+  onSuccess?: (result: PaymentResult) => void
+  onError?: (error: CheckoutError) => void
+};
+
+export const errorTitles: Record<CheckoutErrorKind, string> = {
+  insufficient_balance: 'Insufficient Balance',
+  wrong_network: 'Wrong Network',
+  signature_rejected: 'Signature Rejected',
+  unsupported_network: 'Unsupported Network',
+  unsupported_token: 'Unsupported Token',
+  discovery_failed: 'Price Unavailable',
+  payment_failed: 'Payment Error',
+};
+
+// Detects a user-rejected wallet request (EIP-1193 code 4001 or viem's wrapped error)
+export function isUserRejection(error: unknown): boolean {
+  if (!error || typeof error !== 'object') return false;
+  if (error instanceof UserRejectedRequestError) return true;
+
+  /* eslint-disable @typescript-eslint/no-explicit-any */
+  const err = error as any;
+  if (err.code === 4001 || err.name === 'UserRejectedRequestError') return true;
+  if (typeof err.walk === 'function') {
+    return !!err.walk((e: unknown) => e instanceof UserRejectedRequestError);
+  }
+  return false;
+}
+
+export function checkoutError(kind: CheckoutErrorKind, message: string, cause?: unknown): CheckoutError {
+  return { kind, message, cause };
+}
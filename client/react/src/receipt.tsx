@@ -0,0 +1,112 @@
+'use client';
+
+import { explorerAddressUrl, explorerName, explorerTxUrl } from './explorers';
+
+// Settlement details returned by the Go middleware in the PAYMENT-RESPONSE header
+export type SettlementResponse = {
+  success: boolean
+  transaction: string
+  network: string
+  payer?: string
+  errorReason?: string
+};
+
+// Decodes a base64 PAYMENT-RESPONSE header value
+export function decodePaymentResponse(header: string | null): SettlementResponse | undefined {
+  if (!header) return undefined;
+  try {
+    return JSON.parse(atob(header)) as SettlementResponse;
+  } catch (e) {
+    console.warn('Failed to parse PAYMENT-RESPONSE header:', e);
+    return undefined;
+  }
+}
+
+export type ReceiptProps = {
+  transaction: string
+  network: string // CAIP-2 format (e.g., "eip155:84532")
+  amount?: string // Smallest token units
+  asset?: `0x${string}`
+  decimals?: number
+  symbol?: string
+  payer?: string
+  orderId?: string
+  isDark?: boolean
+  accentColor?: string
+};
+
+export function Receipt({
+  transaction,
+  network,
+  amount,
+  asset,
+  decimals,
+  symbol,
+  payer,
+  orderId,
+  isDark = false,
+  accentColor = '#338aea',
+}: ReceiptProps) {
+  const txUrl = explorerTxUrl(network, transaction);
+  const assetUrl = asset ? explorerAddressUrl(network, asset) : undefined;
+  const explorer = explorerName(network) || 'block explorer';
+
+  const displayAmount = amount && decimals !== undefined
+    ? (Number(amount) / Math.pow(10, decimals)).toFixed(Math.min(decimals, 6))
+    : amount;
+
+  const labelColor = isDark ? '#a1a1aa' : '#6b7280';
+  const rowStyle = { display: 'flex', justifyContent: 'space-between', gap: '1rem', marginTop: '0.35rem', fontSize: '0.875rem' };
+  const valueStyle = { textAlign: 'right' as const, wordBreak: 'break-all' as const };
+  const shorten = (value: string) => value.length > 14 ? `${value.slice(0, 8)}…${value.slice(-6)}` : value;
+
+  return (
+    <div>
+      {orderId && (
+        <div style={rowStyle}>
+          <span style={{ color: labelColor }}>Order</span>
+          <span style={valueStyle}>{orderId}</span>
+        </div>
+      )}
+      {displayAmount && (
+        <div style={rowStyle}>
+          <span style={{ color: labelColor }}>Amount</span>
+          <span style={valueStyle}>{displayAmount} {symbol || ''}</span>
+        </div>
+      )}
+      {asset && (
+        <div style={rowStyle}>
+          <span style={{ color: labelColor }}>Asset</span>
+          <span style={valueStyle}>
+            {assetUrl
+              ? <a href={assetUrl} target="_blank" rel="noopener noreferrer" style={{ color: accentColor }}>{shorten(asset)}</a>
+              : shorten(asset)}
+          </span>
+        </div>
+      )}
+      <div style={rowStyle}>
+        <span style={{ color: labelColor }}>Network</span>
+        <span style={valueStyle}>{network}</span>
+      </div>
+      {payer && (
+        <div style={rowStyle}>
+          <span style={{ color: labelColor }}>Payer</span>
+          <span style={valueStyle}>{shorten(payer)}</span>
+        </div>
+      )}
+      <div style={rowStyle}>
+        <span style={{ color: labelColor }}>Transaction</span>
+        <span style={valueStyle}>{shorten(transaction)}</span>
+      </div>
+      {txUrl && (
+        <a
+          href={txUrl}
+          target="_blank"
+          rel="noopener noreferrer"
+          style={{ display: 'inline-block', marginTop: '0.75rem', fontSize: '0.875rem', color: accentColor, textDecoration: 'underline' }}>
+          View on {explorer}
+        </a>
+      )}
+    </div>
+  );
+}
@@ -0,0 +1,9 @@
+// Main export for @xtended402/svelte package
+export { default as Checkout } from './Checkout.svelte';
+export type {
+  CheckoutCallbacks,
+  CheckoutError,
+  CheckoutErrorKind,
+  PaymentDetails,
+  PaymentResult,
+} from '@xtended402/core';
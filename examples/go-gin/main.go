@@ -2,10 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,7 +18,9 @@ import (
 	"github.com/gin-gonic/gin"
 
 	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	"github.com/mvpoyatt/xtended402/server/go/devnet"
 	ginmw "github.com/mvpoyatt/xtended402/server/go/http/gin"
+	"github.com/mvpoyatt/xtended402/server/go/receipts"
 )
 
 // Product represents a product in the store
@@ -78,30 +83,24 @@ var (
 )
 
 const (
-	network          = x402.Network("eip155:84532")                 // Base Sepolia (CAIP-2 format)
-	usdcAddress      = "0x036CbD53842c5426634e7929541eC2318f3dCF7e" // USDC on Base Sepolia
 	recipientAddress = "0xB8E124eaA317761CF8E4C63EB445fA3d21deD759" // Your address
 	facilitatorURL   = "https://x402.org/facilitator"
 )
 
-func main() {
-	r := gin.Default()
+// Overridden in dev mode (XTENDED402_DEVNET=1) to settle on a local Anvil chain
+var (
+	network     = x402.Network("eip155:84532")                 // Base Sepolia (CAIP-2 format)
+	usdcAddress = "0x036CbD53842c5426634e7929541eC2318f3dCF7e" // USDC on Base Sepolia
+)
 
-	// CORS middleware
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, PAYMENT-SIGNATURE")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "PAYMENT-REQUIRED, PAYMENT-RESPONSE")
+// customerEmailKey carries the buyer's email from pricing to the receipt mailer
+var customerEmailKey = xtended402.NewContextKey[string]("customerEmail")
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+func main() {
+	r := gin.Default()
 
-		c.Next()
-	})
+	// CORS for the frontend, including the x402 headers
+	r.Use(ginmw.CORS([]string{"http://localhost:3000"}))
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
@@ -114,6 +113,23 @@ func main() {
 	// Get all orders
 	r.GET("/api/orders", getOrders)
 
+	// Create facilitator client
+	var facilitator x402.FacilitatorClient = x402http.NewHTTPFacilitatorClient(&x402http.FacilitatorConfig{
+		URL: facilitatorURL,
+	})
+
+	// Dev mode: self-hosted facilitator and mock USDC on a local chain (see server/go/devnet)
+	if devnet.Enabled() {
+		dev, err := devnet.Start(context.Background(), devnet.ConfigFromEnv())
+		if err != nil {
+			log.Fatalf("Failed to start dev mode: %v", err)
+		}
+		defer dev.Close()
+
+		network, usdcAddress, facilitator = devnet.Network, dev.Config.USDCAddress, dev.Facilitator
+		log.Printf("Dev mode: settling on %s at %s", network, dev.Config.RPCURL)
+	}
+
 	// Configure payment route with dynamic pricing
 	routes := x402http.RoutesConfig{
 		"POST /api/purchase": {
@@ -121,22 +137,17 @@ func main() {
 				Scheme:  "exact",
 				Network: network,
 				PayTo:   recipientAddress,
-				Price:   xtended402.ContextPrice("x402:price"), // Dynamic pricing from context
+				Price:   xtended402.ContextPrice(xtended402.PriceKey), // Dynamic pricing from context
 			}},
 		},
 	}
 
-	// Create facilitator client
-	facilitator := x402http.NewHTTPFacilitatorClient(&x402http.FacilitatorConfig{
-		URL: facilitatorURL,
-	})
-
 	// Create x402 server
 	server := x402.Newx402ResourceServer(
 		x402.WithFacilitatorClient(facilitator),
 	)
 
-	// Register EVM exact scheme for Base Sepolia
+	// Register EVM exact scheme for the payment network
 	evmScheme := evmserver.NewExactEvmScheme()
 
 	// Register money parser to convert USD to USDC
@@ -154,15 +165,35 @@ func main() {
 
 	server.Register(network, evmScheme)
 
+	middlewareOpts := []ginmw.MiddlewareOption{
+		ginmw.WithSettlementTiming("before"), // Settle before processing order
+	}
+
+	// Email order confirmations when SMTP or SendGrid is configured
+	if mailer := newReceiptMailer(); mailer != nil {
+		defer mailer.Wait()
+		middlewareOpts = append(middlewareOpts, ginmw.WithEventHandler(mailer.Handle))
+	}
+
+	payments, err := ginmw.NewMiddleware(routes, server, middlewareOpts...)
+	if err != nil {
+		log.Fatalf("Failed to create payment middleware: %v", err)
+	}
+
 	// Setup purchase endpoint with payment
 	r.POST("/api/purchase",
 		calculateOrderTotal, // Calculate price from order
-		ginmw.PaymentMiddleware(routes, server,
-			ginmw.WithSettlementTiming("before"), // Settle before processing order
-		),
+		payments.Handler(),
 		processOrder, // Process order after payment confirmed
 	)
 
+	// Preview the payable total for a cart without issuing a 402
+	preview, err := payments.PricePreviewHandler("POST /api/purchase")
+	if err != nil {
+		log.Fatalf("Failed to create price preview: %v", err)
+	}
+	r.POST("/api/purchase/preview", calculateOrderTotal, preview)
+
 	log.Println("Server starting on :8080")
 	log.Println("Products endpoint: http://localhost:8080/api/products")
 	log.Println("Orders endpoint: http://localhost:8080/api/orders")
@@ -233,10 +264,43 @@ func calculateOrderTotal(c *gin.Context) {
 	}
 
 	// Set price in context for payment middleware
-	xtended402.SetContextValueGin(c, "x402:price", fmt.Sprintf("%.2f", total))
+	xtended402.SetContextValueGin(c, xtended402.PriceKey, fmt.Sprintf("%.2f", total))
+
+	// Receipt recipient for the settlement event
+	xtended402.SetContextValueGin(c, customerEmailKey, req.CustomerEmail)
 	c.Next()
 }
 
+// newReceiptMailer sends receipts through SendGrid (SENDGRID_API_KEY) or SMTP (SMTP_HOST,
+// SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD) from RECEIPT_FROM. Returns nil if neither is set.
+func newReceiptMailer() *receipts.Mailer {
+	var sender receipts.Sender
+	switch {
+	case os.Getenv("SENDGRID_API_KEY") != "":
+		sender = receipts.NewSendGridSender(os.Getenv("SENDGRID_API_KEY"))
+	case os.Getenv("SMTP_HOST") != "":
+		port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		sender = receipts.NewSMTPSender(receipts.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		})
+	default:
+		return nil
+	}
+
+	from := os.Getenv("RECEIPT_FROM")
+	if from == "" {
+		from = "orders@duckstore.example"
+	}
+
+	return receipts.NewMailer(sender, from,
+		receipts.WithRecipient(receipts.RecipientFromContext(customerEmailKey)),
+		receipts.WithStoreName("Duck Store"),
+	)
+}
+
 // processOrder processes the order after payment is confirmed
 func processOrder(c *gin.Context) {
 	// Get verified payment data
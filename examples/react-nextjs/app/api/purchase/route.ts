@@ -0,0 +1,6 @@
+import { createX402RouteHandler } from '@xtended402/nextjs';
+
+// Proxies checkout requests to the go-gin backend so payment headers and cookies stay same-origin
+export const POST = createX402RouteHandler({
+  backendUrl: process.env.BACKEND_URL || 'http://localhost:8080',
+});
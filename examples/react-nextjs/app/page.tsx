@@ -182,7 +182,7 @@ export default function Home() {
 
                   {/* Checkout button */}
                   <Checkout
-                    paymentEndpoint={'http://localhost:8080/api/purchase'}
+                    paymentEndpoint={'/api/purchase'} // Proxied to the Go backend by app/api/purchase/route.ts
                     orderData={{
                       customerEmail: email,
                       items: cartItems
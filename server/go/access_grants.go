@@ -0,0 +1,246 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessGrantKey is the Gin context key where the AccessGrant is stored for requests let
+// through by an access window
+const AccessGrantKey = "xtended402AccessGrant"
+
+// ErrAccessGrantNotFound is returned by AccessStore.GetGrant when the payer has no grant for
+// the resource
+var ErrAccessGrantNotFound = errors.New("access grant not found")
+
+// AccessGrant records that a payer bought a resource and may access it again until ExpiresAt
+type AccessGrant struct {
+	Payer    string `json:"payer"`
+	Resource string `json:"resource"`
+
+	// Transaction is the settlement that paid for the grant
+	Transaction string `json:"transaction,omitempty"`
+
+	GrantedAt time.Time `json:"grantedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// AccessStore keeps access grants keyed by payer and resource. Payers are compared
+// case-insensitively.
+type AccessStore interface {
+	// SaveGrant stores grant, replacing any grant for the same payer and resource
+	SaveGrant(ctx context.Context, grant *AccessGrant) error
+
+	// GetGrant returns the payer's grant for resource (even if expired), or
+	// ErrAccessGrantNotFound
+	GetGrant(ctx context.Context, payer, resource string) (*AccessGrant, error)
+}
+
+// AccessWindows lets payers re-access a resource they bought without paying again, for a
+// window per route, e.g. 24 hours after buying an article. Grants are keyed by payer and the
+// exact resource ("GET /articles/42"), so buying one article doesn't unlock another. Use it
+// with ginmw.WithAccessWindows.
+type AccessWindows struct {
+	store AccessStore
+	rules []accessRule
+	clock Clock
+}
+
+type accessRule struct {
+	route  RoutePattern
+	window time.Duration
+}
+
+// AccessOption configures AccessWindows
+type AccessOption func(*AccessWindows)
+
+// WithAccessWindow grants access to resources matching pattern (same syntax as
+// x402http.RoutesConfig keys) for window after purchase. The first matching pattern wins.
+func WithAccessWindow(pattern string, window time.Duration) AccessOption {
+	return func(a *AccessWindows) {
+		a.rules = append(a.rules, accessRule{route: ParseRoutePattern(pattern), window: window})
+	}
+}
+
+// WithAccessClock sets the clock for grant and expiry times (default SystemClock)
+func WithAccessClock(clock Clock) AccessOption {
+	return func(a *AccessWindows) {
+		a.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewAccessWindows creates AccessWindows keeping grants in store
+func NewAccessWindows(store AccessStore, opts ...AccessOption) *AccessWindows {
+	a := &AccessWindows{
+		store: store,
+		clock: SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Window returns the access window for resource, or false if its route has none
+func (a *AccessWindows) Window(resource string) (time.Duration, bool) {
+	for _, rule := range a.rules {
+		if rule.route.Matches(resource) {
+			return rule.window, true
+		}
+	}
+	return 0, false
+}
+
+// Grant records access for the payer of a settled payment, if its resource has a window
+func (a *AccessWindows) Grant(ctx context.Context, event PaymentEvent) error {
+	window, ok := a.Window(event.Resource)
+	if !ok || event.Payer == "" {
+		return nil
+	}
+
+	now := a.clock.Now().UTC()
+	return a.store.SaveGrant(ctx, &AccessGrant{
+		Payer:       event.Payer,
+		Resource:    event.Resource,
+		Transaction: event.Transaction,
+		GrantedAt:   now,
+		ExpiresAt:   now.Add(window),
+	})
+}
+
+// Check returns payer's unexpired grant for resource, or nil
+func (a *AccessWindows) Check(ctx context.Context, payer, resource string) (*AccessGrant, error) {
+	if _, ok := a.Window(resource); !ok || payer == "" {
+		return nil, nil
+	}
+
+	grant, err := a.store.GetGrant(ctx, payer, resource)
+	if errors.Is(err, ErrAccessGrantNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !a.clock.Now().Before(grant.ExpiresAt) {
+		return nil, nil
+	}
+	return grant, nil
+}
+
+// GetAccessGrant returns the grant that let the request through without payment, or nil
+func GetAccessGrant(c *gin.Context) *AccessGrant {
+	grant, exists := c.Get(AccessGrantKey)
+	if !exists {
+		return nil
+	}
+	return grant.(*AccessGrant)
+}
+
+func accessKey(payer, resource string) string {
+	return strings.ToLower(payer) + " " + resource
+}
+
+// ============================================================================
+// Access Stores
+// ============================================================================
+
+// MemoryAccessStore is an in-process AccessStore. Grants are lost on restart.
+type MemoryAccessStore struct {
+	mu     sync.RWMutex
+	grants map[string]*AccessGrant
+}
+
+// NewMemoryAccessStore creates an empty MemoryAccessStore
+func NewMemoryAccessStore() *MemoryAccessStore {
+	return &MemoryAccessStore{grants: make(map[string]*AccessGrant)}
+}
+
+// SaveGrant stores grant
+func (s *MemoryAccessStore) SaveGrant(ctx context.Context, grant *AccessGrant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *grant
+	s.grants[accessKey(grant.Payer, grant.Resource)] = &stored
+	return nil
+}
+
+// GetGrant returns the payer's grant for resource, or ErrAccessGrantNotFound
+func (s *MemoryAccessStore) GetGrant(ctx context.Context, payer, resource string) (*AccessGrant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	grant, ok := s.grants[accessKey(payer, resource)]
+	if !ok {
+		return nil, ErrAccessGrantNotFound
+	}
+	found := *grant
+	return &found, nil
+}
+
+// Prune deletes grants that expired before now
+func (s *MemoryAccessStore) Prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, grant := range s.grants {
+		if !now.Before(grant.ExpiresAt) {
+			delete(s.grants, key)
+		}
+	}
+}
+
+// FileAccessStore keeps each grant as a JSON file in a directory, so grants survive restarts
+type FileAccessStore struct {
+	dir string
+}
+
+// NewFileAccessStore creates a FileAccessStore in dir, which is created if needed
+func NewFileAccessStore(dir string) *FileAccessStore {
+	return &FileAccessStore{dir: dir}
+}
+
+// SaveGrant writes grant to its file
+func (s *FileAccessStore) SaveGrant(ctx context.Context, grant *AccessGrant) error {
+	data, err := json.Marshal(grant)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(grant.Payer, grant.Resource), data)
+}
+
+// GetGrant reads the payer's grant for resource, or returns ErrAccessGrantNotFound
+func (s *FileAccessStore) GetGrant(ctx context.Context, payer, resource string) (*AccessGrant, error) {
+	data, err := os.ReadFile(s.path(payer, resource))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrAccessGrantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var grant AccessGrant
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return nil, fmt.Errorf("invalid access grant file for %s: %w", resource, err)
+	}
+	return &grant, nil
+}
+
+// path names the grant's file by a hash of its key, since resources contain slashes
+func (s *FileAccessStore) path(payer, resource string) string {
+	sum := sha256.Sum256([]byte(accessKey(payer, resource)))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
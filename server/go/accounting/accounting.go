@@ -0,0 +1,154 @@
+// Package accounting exports recorded payments as CSV files that QuickBooks and Xero import as
+// bank transactions. Each settled payment becomes a deposit valued in fiat at its settlement
+// time, and each refund a withdrawal:
+//
+//	exporter := accounting.NewExporter(store, xtended402.NewStablecoinPriceOracle())
+//	err := exporter.WriteQuickBooks(ctx, w, xtended402.PaymentQuery{Since: monthStart, Until: monthEnd})
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// Line is one exported transaction
+type Line struct {
+	Record *xtended402.PaymentRecord
+
+	// Date is the settlement (or refund) time in the exporter's location
+	Date time.Time
+
+	// Value is the fiat value at Date, negative for refunds
+	Value    *big.Rat
+	Currency string
+
+	Description string
+}
+
+// Exporter values payment records from a PaymentStore and writes them as CSV
+type Exporter struct {
+	store       xtended402.PaymentStore
+	oracle      xtended402.PriceOracle
+	currency    string
+	location    *time.Location
+	dateFormat  string
+	description func(record *xtended402.PaymentRecord) string
+}
+
+// Option configures an Exporter
+type Option func(*Exporter)
+
+// WithCurrency sets the fiat currency of exported amounts (default "USD")
+func WithCurrency(currency string) Option {
+	return func(e *Exporter) {
+		e.currency = currency
+	}
+}
+
+// WithLocation sets the time zone of exported dates (default UTC)
+func WithLocation(location *time.Location) Option {
+	return func(e *Exporter) {
+		e.location = location
+	}
+}
+
+// WithDateFormat overrides the date layout (default 01/02/2006 for QuickBooks and 02/01/2006
+// for Xero)
+func WithDateFormat(layout string) Option {
+	return func(e *Exporter) {
+		e.dateFormat = layout
+	}
+}
+
+// WithDescription replaces the default transaction description
+func WithDescription(description func(record *xtended402.PaymentRecord) string) Option {
+	return func(e *Exporter) {
+		e.description = description
+	}
+}
+
+// NewExporter creates an Exporter reading from store and valuing payments with oracle
+func NewExporter(store xtended402.PaymentStore, oracle xtended402.PriceOracle, opts ...Option) *Exporter {
+	e := &Exporter{
+		store:       store,
+		oracle:      oracle,
+		currency:    "USD",
+		location:    time.UTC,
+		description: DefaultDescription,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Lines returns the settled payments and refunds matching query, valued in the exporter's
+// currency. Failed payments are skipped.
+func (e *Exporter) Lines(ctx context.Context, query xtended402.PaymentQuery) ([]Line, error) {
+	records, err := e.store.ListPayments(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+
+	lines := make([]Line, 0, len(records))
+	for _, record := range records {
+		if record.Status != xtended402.PaymentStatusSettled && record.Status != xtended402.PaymentStatusRefunded {
+			continue
+		}
+
+		value, err := xtended402.FiatValue(ctx, e.oracle, record.Network, record.Asset, record.Amount, e.currency, record.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to value payment %s: %w", record.ID, err)
+		}
+		if record.Status == xtended402.PaymentStatusRefunded {
+			value.Neg(value)
+		}
+
+		lines = append(lines, Line{
+			Record:      record,
+			Date:        record.CreatedAt.In(e.location),
+			Value:       value,
+			Currency:    e.currency,
+			Description: e.description(record),
+		})
+	}
+
+	return lines, nil
+}
+
+func (e *Exporter) formatDate(date time.Time, defaultLayout string) string {
+	if e.dateFormat != "" {
+		return date.Format(e.dateFormat)
+	}
+	return date.Format(defaultLayout)
+}
+
+// DefaultDescription describes a record as e.g.
+// "x402 payment POST /api/purchase (4.50 USDC, tx 0xabc…)"
+func DefaultDescription(record *xtended402.PaymentRecord) string {
+	kind := "payment"
+	if record.Status == xtended402.PaymentStatusRefunded {
+		kind = "refund"
+	}
+
+	description := "x402 " + kind
+	if record.Resource != "" {
+		description += " " + record.Resource
+	}
+	description += " (" + xtended402.FormatTokenAmount(record.Network, record.Asset, record.Amount)
+	if record.Transaction != "" {
+		description += ", tx " + record.Transaction
+	}
+	return description + ")"
+}
+
+// formatValue formats a fiat value with two decimal places
+func formatValue(value *big.Rat) string {
+	return value.FloatString(2)
+}
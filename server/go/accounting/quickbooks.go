@@ -0,0 +1,30 @@
+package accounting
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// WriteQuickBooks writes the payments matching query in QuickBooks Online's three-column bank
+// transaction format: Date, Description, Amount (negative for refunds)
+func (e *Exporter) WriteQuickBooks(ctx context.Context, w io.Writer, query xtended402.PaymentQuery) error {
+	lines, err := e.Lines(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"Date", "Description", "Amount"})
+	for _, line := range lines {
+		_ = out.Write([]string{
+			e.formatDate(line.Date, "01/02/2006"),
+			line.Description,
+			formatValue(line.Value),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,32 @@
+package accounting
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// WriteXero writes the payments matching query in Xero's bank statement import format, with the
+// payer as payee and the transaction hash as reference
+func (e *Exporter) WriteXero(ctx context.Context, w io.Writer, query xtended402.PaymentQuery) error {
+	lines, err := e.Lines(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"*Date", "*Amount", "Payee", "Description", "Reference"})
+	for _, line := range lines {
+		_ = out.Write([]string{
+			e.formatDate(line.Date, "02/01/2006"),
+			formatValue(line.Value),
+			line.Record.Payer,
+			line.Description,
+			line.Record.Transaction,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
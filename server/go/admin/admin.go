@@ -0,0 +1,377 @@
+// Package admin serves an authenticated JSON API for operators: search recorded payments,
+// refund them, retry failed settlements, work through disputes, inspect route pricing, replay
+// dead letters, and switch free mode, without touching the database directly. Mount it under a
+// prefix:
+//
+//	api := admin.New(store, os.Getenv("ADMIN_TOKEN"),
+//		admin.WithRoutes(routes),
+//		admin.WithRefunder(refunder),
+//		admin.WithEventHandlers(xtended402.RevokeRefunded(revocations)),
+//	)
+//	r.Any("/admin/*path", gin.WrapH(http.StripPrefix("/admin", api.Handler())))
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	x402http "github.com/coinbase/x402/go/http"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ErrNotRefundable is returned when a payment can't be refunded (it didn't settle, or the
+// amount exceeds what is left to refund)
+var ErrNotRefundable = errors.New("payment not refundable")
+
+// ErrNotRetryable is returned when a payment's settlement can't be retried
+var ErrNotRetryable = errors.New("settlement not retryable")
+
+// ErrRefundFailed wraps errors from the Refunder
+var ErrRefundFailed = errors.New("refund failed")
+
+// ErrRetryFailed wraps errors from the SettlementRetrier
+var ErrRetryFailed = errors.New("settlement retry failed")
+
+// Refunder sends a refund of amount (in the payment's smallest units) to payment.RefundTo(),
+// the payer or the refund address they paid with, and returns the refund transaction. The
+// library doesn't move funds itself.
+type Refunder interface {
+	Refund(ctx context.Context, payment *xtended402.PaymentRecord, amount string) (transaction string, err error)
+}
+
+// RefunderFunc adapts a function to Refunder
+type RefunderFunc func(ctx context.Context, payment *xtended402.PaymentRecord, amount string) (string, error)
+
+// Refund calls f
+func (f RefunderFunc) Refund(ctx context.Context, payment *xtended402.PaymentRecord, amount string) (string, error) {
+	return f(ctx, payment, amount)
+}
+
+// SettlementRetrier settles a failed payment again and returns the settled record. Payment
+// records don't keep the signed payload, so retrying needs application state such as a queue
+// of failed settlements.
+type SettlementRetrier interface {
+	RetrySettlement(ctx context.Context, payment *xtended402.PaymentRecord) (*xtended402.PaymentRecord, error)
+}
+
+// API is the admin API
+type API struct {
+	payments  xtended402.PaymentStore
+	authorize func(r *http.Request) bool
+
+	routes      x402http.RoutesConfig
+	refunder    Refunder
+	retrier     SettlementRetrier
+	deadLetters *xtended402.DeadLetterReplayer
+	freeMode    *xtended402.FreeMode
+	disputes    *xtended402.Disputes
+	handlers    []xtended402.PaymentEventHandler
+	clock       xtended402.Clock
+}
+
+// Option configures an API
+type Option func(*API)
+
+// WithAuthorizer replaces the bearer token check, e.g. to use your own operator sessions
+func WithAuthorizer(authorize func(r *http.Request) bool) Option {
+	return func(a *API) {
+		a.authorize = authorize
+	}
+}
+
+// WithRoutes exposes the routes' pricing under GET /routes
+func WithRoutes(routes x402http.RoutesConfig) Option {
+	return func(a *API) {
+		a.routes = routes
+	}
+}
+
+// WithRefunder enables POST /payments/{id}/refund
+func WithRefunder(refunder Refunder) Option {
+	return func(a *API) {
+		a.refunder = refunder
+	}
+}
+
+// WithSettlementRetrier enables POST /payments/{id}/retry
+func WithSettlementRetrier(retrier SettlementRetrier) Option {
+	return func(a *API) {
+		a.retrier = retrier
+	}
+}
+
+// WithDeadLetters mounts replayer's API under /dead-letters
+func WithDeadLetters(replayer *xtended402.DeadLetterReplayer) Option {
+	return func(a *API) {
+		a.deadLetters = replayer
+	}
+}
+
+// WithFreeMode enables GET and PUT /free-mode to inspect and switch freeMode
+func WithFreeMode(freeMode *xtended402.FreeMode) Option {
+	return func(a *API) {
+		a.freeMode = freeMode
+	}
+}
+
+// WithDisputes enables the /disputes endpoints and POST /payments/{id}/dispute
+func WithDisputes(disputes *xtended402.Disputes) Option {
+	return func(a *API) {
+		a.disputes = disputes
+	}
+}
+
+// WithEventHandlers adds handlers for the payment.refunded events of refunds made through the
+// API, such as xtended402.RevokeRefunded and xtended402.RefundOrders
+func WithEventHandlers(handlers ...xtended402.PaymentEventHandler) Option {
+	return func(a *API) {
+		a.handlers = append(a.handlers, handlers...)
+	}
+}
+
+// WithClock sets the clock used to timestamp refunds (defaults to the system clock)
+func WithClock(clock xtended402.Clock) Option {
+	return func(a *API) {
+		a.clock = clock
+	}
+}
+
+// New creates an API over payments. Requests must send "Authorization: Bearer <token>"; an
+// empty token rejects every request unless WithAuthorizer is used.
+func New(payments xtended402.PaymentStore, token string, opts ...Option) *API {
+	a := &API{
+		payments: payments,
+		authorize: func(r *http.Request) bool {
+			presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			return ok && token != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+		},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Handler serves the API:
+//
+//	GET    /payments                 search payments (status, payer, resource, tenant, transaction, since, until, limit)
+//	GET    /payments/{id}            show one
+//	POST   /payments/{id}/refund     refund one ({"amount": "...", "reason": "..."}, amount defaults to all)
+//	POST   /payments/{id}/retry      retry a failed settlement
+//	POST   /payments/{id}/dispute    flag one as disputed ({"reason": "..."})
+//	GET    /disputes                 list disputes (status, payment, transaction)
+//	GET    /disputes/{id}            show one
+//	POST   /disputes/{id}/evidence   attach evidence ({"author": "...", "note": "...", "url": "..."})
+//	POST   /disputes/{id}/resolve    resolve one ({"outcome": "refunded" or "upheld", "note": "...", "refund": true})
+//	GET    /routes                   list routes and prices
+//	GET    /stats                    failure rate, revenue by route, and pending retries (since, until; default the last 24h)
+//	*      /dead-letters/...         the DeadLetterReplayer API
+//	GET    /free-mode                show free mode
+//	PUT    /free-mode                switch free mode ({"enabled": true, "routes": [...], "reason": "...", "until": "..."})
+//	GET    /dashboard/               the operator dashboard (served without authentication)
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /payments", a.listPayments)
+	mux.HandleFunc("GET /payments/{id}", a.getPayment)
+	mux.HandleFunc("POST /payments/{id}/refund", a.refundPayment)
+	mux.HandleFunc("POST /payments/{id}/retry", a.retrySettlement)
+	mux.HandleFunc("POST /payments/{id}/dispute", a.disputePayment)
+	mux.HandleFunc("GET /disputes", a.listDisputes)
+	mux.HandleFunc("GET /disputes/{id}", a.getDispute)
+	mux.HandleFunc("POST /disputes/{id}/evidence", a.addDisputeEvidence)
+	mux.HandleFunc("POST /disputes/{id}/resolve", a.resolveDispute)
+	mux.HandleFunc("GET /routes", a.listRoutes)
+	mux.HandleFunc("GET /stats", a.getStats)
+	mux.HandleFunc("/dead-letters/", a.serveDeadLetters)
+	mux.HandleFunc("GET /free-mode", a.getFreeMode)
+	mux.HandleFunc("PUT /free-mode", a.setFreeMode)
+	mux.Handle("GET "+dashboardPath, dashboard())
+	mux.HandleFunc("GET "+strings.TrimSuffix(dashboardPath, "/"), func(w http.ResponseWriter, r *http.Request) {
+		// Relative, so the redirect keeps whatever prefix the API is mounted under
+		w.Header().Set("Location", "dashboard/")
+		w.WriteHeader(http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isDashboard(r) && !a.authorize(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="xtended402 admin"`)
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (a *API) listPayments(w http.ResponseWriter, r *http.Request) {
+	query, err := ParsePaymentQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	records, err := a.payments.ListPayments(r.Context(), query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if records == nil {
+		records = []*xtended402.PaymentRecord{}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (a *API) getPayment(w http.ResponseWriter, r *http.Request) {
+	record, err := a.payments.GetPayment(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+func (a *API) refundPayment(w http.ResponseWriter, r *http.Request) {
+	if a.refunder == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("refunds are not configured"))
+		return
+	}
+
+	var body struct {
+		Amount string `json:"amount"`
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	refund, err := a.Refund(r.Context(), r.PathValue("id"), body.Amount, body.Reason)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, refund)
+}
+
+func (a *API) retrySettlement(w http.ResponseWriter, r *http.Request) {
+	if a.retrier == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("settlement retries are not configured"))
+		return
+	}
+
+	record, err := a.RetrySettlement(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+func (a *API) listRoutes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Routes(a.routes))
+}
+
+func (a *API) serveDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if a.deadLetters == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("dead letters are not configured"))
+		return
+	}
+	http.StripPrefix("/dead-letters", a.deadLetters.Handler()).ServeHTTP(w, r)
+}
+
+func (a *API) getFreeMode(w http.ResponseWriter, r *http.Request) {
+	if a.freeMode == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("free mode is not configured"))
+		return
+	}
+	writeJSON(w, http.StatusOK, a.freeMode.State())
+}
+
+func (a *API) setFreeMode(w http.ResponseWriter, r *http.Request) {
+	if a.freeMode == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("free mode is not configured"))
+		return
+	}
+
+	var state xtended402.FreeModeState
+	if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if state.Until != nil && !state.Until.After(xtended402.ClockOrSystem(a.clock).Now()) {
+		writeError(w, http.StatusBadRequest, errors.New("until must be in the future"))
+		return
+	}
+	if !state.Enabled {
+		state = xtended402.FreeModeState{}
+	}
+
+	a.freeMode.Set(state)
+	writeJSON(w, http.StatusOK, a.freeMode.State())
+}
+
+// ParsePaymentQuery reads a PaymentQuery from r's query string. since and until are RFC 3339
+// times.
+func ParsePaymentQuery(r *http.Request) (xtended402.PaymentQuery, error) {
+	values := r.URL.Query()
+	query := xtended402.PaymentQuery{
+		Status:      xtended402.PaymentStatus(values.Get("status")),
+		Payer:       values.Get("payer"),
+		Resource:    values.Get("resource"),
+		Tenant:      values.Get("tenant"),
+		Transaction: values.Get("transaction"),
+	}
+
+	var err error
+	if since := values.Get("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return query, errors.New("invalid since: " + err.Error())
+		}
+	}
+	if until := values.Get("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return query, errors.New("invalid until: " + err.Error())
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 0 {
+			return query, errors.New("invalid limit")
+		}
+	}
+
+	return query, nil
+}
+
+func writePaymentError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, xtended402.ErrPaymentNotFound), errors.Is(err, xtended402.ErrDisputeNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrNotRefundable), errors.Is(err, ErrNotRetryable),
+		errors.Is(err, xtended402.ErrNotDisputable), errors.Is(err, xtended402.ErrDisputeResolved):
+		status = http.StatusConflict
+	case errors.Is(err, ErrRefundFailed), errors.Is(err, ErrRetryFailed):
+		status = http.StatusBadGateway
+	}
+	writeError(w, status, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,307 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ErrReadOnly is returned by Client.SavePayment; payments are recorded by the server
+var ErrReadOnly = errors.New("admin client is read-only")
+
+// APIError is an error response from the admin API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("admin API: %s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// Client calls an admin API. It implements the read side of xtended402.PaymentStore, so an
+// accounting.Exporter can export from a remote server.
+type Client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the HTTP client (default: 30s timeout)
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// NewClient creates a Client for the API mounted at baseURL, e.g. "https://shop.example/admin"
+func NewClient(baseURL, token string, opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ListPayments searches payments
+func (c *Client) ListPayments(ctx context.Context, query xtended402.PaymentQuery) ([]*xtended402.PaymentRecord, error) {
+	values := url.Values{}
+	if query.Status != "" {
+		values.Set("status", string(query.Status))
+	}
+	if query.Payer != "" {
+		values.Set("payer", query.Payer)
+	}
+	if query.Resource != "" {
+		values.Set("resource", query.Resource)
+	}
+	if query.Tenant != "" {
+		values.Set("tenant", query.Tenant)
+	}
+	if query.Transaction != "" {
+		values.Set("transaction", query.Transaction)
+	}
+	if !query.Since.IsZero() {
+		values.Set("since", query.Since.Format(time.RFC3339Nano))
+	}
+	if !query.Until.IsZero() {
+		values.Set("until", query.Until.Format(time.RFC3339Nano))
+	}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	var records []*xtended402.PaymentRecord
+	err := c.do(ctx, http.MethodGet, "/payments?"+values.Encode(), nil, &records)
+	return records, err
+}
+
+// GetPayment returns one payment, or xtended402.ErrPaymentNotFound
+func (c *Client) GetPayment(ctx context.Context, id string) (*xtended402.PaymentRecord, error) {
+	var record xtended402.PaymentRecord
+	if err := c.do(ctx, http.MethodGet, "/payments/"+url.PathEscape(id), nil, &record); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, xtended402.ErrPaymentNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// SavePayment returns ErrReadOnly
+func (c *Client) SavePayment(ctx context.Context, record *xtended402.PaymentRecord) error {
+	return ErrReadOnly
+}
+
+// Refund refunds amount of a payment ("" refunds whatever is left) and returns the refund record
+func (c *Client) Refund(ctx context.Context, id, amount, reason string) (*xtended402.PaymentRecord, error) {
+	body := map[string]string{}
+	if amount != "" {
+		body["amount"] = amount
+	}
+	if reason != "" {
+		body["reason"] = reason
+	}
+
+	var record xtended402.PaymentRecord
+	if err := c.do(ctx, http.MethodPost, "/payments/"+url.PathEscape(id)+"/refund", body, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// RetrySettlement retries a failed settlement and returns the settled record
+func (c *Client) RetrySettlement(ctx context.Context, id string) (*xtended402.PaymentRecord, error) {
+	var record xtended402.PaymentRecord
+	if err := c.do(ctx, http.MethodPost, "/payments/"+url.PathEscape(id)+"/retry", nil, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Disputes lists the disputes matching query
+func (c *Client) Disputes(ctx context.Context, query xtended402.DisputeQuery) ([]*xtended402.Dispute, error) {
+	values := url.Values{}
+	if query.Status != "" {
+		values.Set("status", string(query.Status))
+	}
+	if query.PaymentID != "" {
+		values.Set("payment", query.PaymentID)
+	}
+	if query.Transaction != "" {
+		values.Set("transaction", query.Transaction)
+	}
+
+	var disputes []*xtended402.Dispute
+	err := c.do(ctx, http.MethodGet, "/disputes?"+values.Encode(), nil, &disputes)
+	return disputes, err
+}
+
+// Dispute returns one dispute
+func (c *Client) Dispute(ctx context.Context, id string) (*xtended402.Dispute, error) {
+	var dispute xtended402.Dispute
+	if err := c.do(ctx, http.MethodGet, "/disputes/"+url.PathEscape(id), nil, &dispute); err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// OpenDispute flags a settled payment as disputed
+func (c *Client) OpenDispute(ctx context.Context, paymentID, reason string) (*xtended402.Dispute, error) {
+	var dispute xtended402.Dispute
+	body := map[string]string{"reason": reason}
+	if err := c.do(ctx, http.MethodPost, "/payments/"+url.PathEscape(paymentID)+"/dispute", body, &dispute); err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// AddDisputeEvidence attaches evidence to an open dispute
+func (c *Client) AddDisputeEvidence(ctx context.Context, id string, evidence xtended402.DisputeEvidence) (*xtended402.Dispute, error) {
+	var dispute xtended402.Dispute
+	body := map[string]string{"author": evidence.Author, "note": evidence.Note, "url": evidence.URL}
+	if err := c.do(ctx, http.MethodPost, "/disputes/"+url.PathEscape(id)+"/evidence", body, &dispute); err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// ResolveDispute resolves an open dispute as refunded or upheld; with refund, the server
+// refunds the payment first
+func (c *Client) ResolveDispute(ctx context.Context, id string, outcome xtended402.DisputeStatus, note string, refund bool) (*xtended402.Dispute, error) {
+	var dispute xtended402.Dispute
+	body := map[string]interface{}{"outcome": outcome, "note": note, "refund": refund}
+	if err := c.do(ctx, http.MethodPost, "/disputes/"+url.PathEscape(id)+"/resolve", body, &dispute); err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// Routes lists the server's routes and prices
+func (c *Client) Routes(ctx context.Context) ([]Route, error) {
+	var routes []Route
+	err := c.do(ctx, http.MethodGet, "/routes", nil, &routes)
+	return routes, err
+}
+
+// Stats summarizes payments in [since, until). Zero times use the server's defaults.
+func (c *Client) Stats(ctx context.Context, since, until time.Time) (*Stats, error) {
+	values := url.Values{}
+	if !since.IsZero() {
+		values.Set("since", since.Format(time.RFC3339Nano))
+	}
+	if !until.IsZero() {
+		values.Set("until", until.Format(time.RFC3339Nano))
+	}
+
+	var stats Stats
+	if err := c.do(ctx, http.MethodGet, "/stats?"+values.Encode(), nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// DeadLetters lists the dead letters waiting to be replayed
+func (c *Client) DeadLetters(ctx context.Context) ([]*xtended402.DeadLetter, error) {
+	var letters []*xtended402.DeadLetter
+	err := c.do(ctx, http.MethodGet, "/dead-letters/", nil, &letters)
+	return letters, err
+}
+
+// ReplayDeadLetter redelivers one dead letter
+func (c *Client) ReplayDeadLetter(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/dead-letters/"+url.PathEscape(id)+"/replay", nil, nil)
+}
+
+// ReplayDeadLetters redelivers every dead letter and returns how many succeeded
+func (c *Client) ReplayDeadLetters(ctx context.Context) (int, error) {
+	var result struct {
+		Replayed int    `json:"replayed"`
+		Error    string `json:"error"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/dead-letters/replay", nil, &result); err != nil {
+		return 0, err
+	}
+	if result.Error != "" {
+		return result.Replayed, errors.New(result.Error)
+	}
+	return result.Replayed, nil
+}
+
+// FreeMode returns the server's free mode state
+func (c *Client) FreeMode(ctx context.Context) (*xtended402.FreeModeState, error) {
+	var state xtended402.FreeModeState
+	if err := c.do(ctx, http.MethodGet, "/free-mode", nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SetFreeMode switches the server's free mode and returns the new state
+func (c *Client) SetFreeMode(ctx context.Context, state xtended402.FreeModeState) (*xtended402.FreeModeState, error) {
+	var updated xtended402.FreeModeState
+	if err := c.do(ctx, http.MethodPut, "/free-mode", state, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&failure)
+		if failure.Error == "" {
+			failure.Error = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: failure.Error}
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
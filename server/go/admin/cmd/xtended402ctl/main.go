@@ -0,0 +1,438 @@
+// Command xtended402ctl runs on-call operations against a server's admin API:
+//
+//	export XTENDED402_ADMIN_URL=https://shop.example/admin XTENDED402_ADMIN_TOKEN=...
+//	xtended402ctl payments -status failed -since 24h
+//	xtended402ctl refund -amount 250000 -reason "duplicate order" evt_3f9a...
+//	xtended402ctl retry evt_3f9a...
+//	xtended402ctl replay -all
+//	xtended402ctl free-mode on -reason facilitator-incident -for 2h
+//	xtended402ctl export -format quickbooks -since 2026-01-01T00:00:00Z -o january.csv
+//
+// Run xtended402ctl help for every command. Add -json to print raw API responses for scripts.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	"github.com/mvpoyatt/xtended402/server/go/accounting"
+	"github.com/mvpoyatt/xtended402/server/go/admin"
+)
+
+const usage = `Usage: xtended402ctl [-url URL] [-token TOKEN] [-json] <command> [flags] [args]
+
+Commands:
+  payments    search payments (-status, -payer, -resource, -tenant, -transaction, -since, -until, -limit)
+  payment     show one payment: payment <id>
+  refund      refund a settled payment: refund [-amount N] [-reason TEXT] <id>
+  retry       retry a failed settlement: retry <id>
+  replay      replay dead letters: replay <id> | replay -all
+  dead        list dead letters waiting to be replayed
+  routes      list routes and prices
+  stats       failure rate, revenue by route, and pending retries (-since, -until)
+  export      export payments (-format quickbooks|xero|json, -since, -until, -currency, -o)
+  free-mode   show or switch free mode: free-mode [on [-routes R1,R2] [-reason TEXT] [-for 2h] | off]
+
+-url and -token default to $XTENDED402_ADMIN_URL and $XTENDED402_ADMIN_TOKEN. Times are
+RFC 3339 (2026-01-01T00:00:00Z) or durations before now (24h).
+`
+
+// cli holds the global flags
+type cli struct {
+	client *admin.Client
+	json   bool
+	out    io.Writer
+}
+
+func main() {
+	global := flag.NewFlagSet("xtended402ctl", flag.ExitOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	baseURL := global.String("url", os.Getenv("XTENDED402_ADMIN_URL"), "admin API URL")
+	token := global.String("token", os.Getenv("XTENDED402_ADMIN_TOKEN"), "admin API token")
+	asJSON := global.Bool("json", false, "print JSON")
+	_ = global.Parse(os.Args[1:])
+
+	args := global.Args()
+	if len(args) == 0 || args[0] == "help" {
+		global.Usage()
+		os.Exit(2)
+	}
+	if *baseURL == "" {
+		fatal(errors.New("-url or XTENDED402_ADMIN_URL is required"))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &cli{client: admin.NewClient(*baseURL, *token), json: *asJSON, out: os.Stdout}
+	commands := map[string]func(context.Context, []string) error{
+		"payments":  c.payments,
+		"payment":   c.payment,
+		"refund":    c.refund,
+		"retry":     c.retry,
+		"replay":    c.replay,
+		"dead":      c.dead,
+		"routes":    c.routes,
+		"stats":     c.stats,
+		"export":    c.export,
+		"free-mode": c.freeMode,
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fatal(fmt.Errorf("unknown command %q (run xtended402ctl help)", args[0]))
+	}
+	if err := command(ctx, args[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "xtended402ctl: %v\n", err)
+	os.Exit(1)
+}
+
+// ============================================================================
+// Commands
+// ============================================================================
+
+func (c *cli) payments(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("payments", flag.ExitOnError)
+	status := flags.String("status", "", "settled, failed, or refunded")
+	payer := flags.String("payer", "", "payer address")
+	resource := flags.String("resource", "", `paid request, e.g. "POST /api/purchase"`)
+	tenant := flags.String("tenant", "", "tenant ID")
+	transaction := flags.String("transaction", "", "settlement or refund transaction hash")
+	since := flags.String("since", "", "start time")
+	until := flags.String("until", "", "end time")
+	limit := flags.Int("limit", 0, "maximum number of payments")
+	_ = flags.Parse(args)
+
+	query := xtended402.PaymentQuery{
+		Status:      xtended402.PaymentStatus(*status),
+		Payer:       *payer,
+		Resource:    *resource,
+		Tenant:      *tenant,
+		Transaction: *transaction,
+		Limit:       *limit,
+	}
+	var err error
+	if query.Since, err = parseTime(*since); err != nil {
+		return err
+	}
+	if query.Until, err = parseTime(*until); err != nil {
+		return err
+	}
+
+	records, err := c.client.ListPayments(ctx, query)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(records)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tSTATUS\tRESOURCE\tAMOUNT\tPAYER\tTRANSACTION")
+	for _, record := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			record.ID,
+			record.CreatedAt.Format(time.RFC3339),
+			record.Status,
+			record.Resource,
+			xtended402.FormatTokenAmount(record.Network, record.Asset, record.Amount),
+			record.Payer,
+			record.Transaction,
+		)
+	}
+	return w.Flush()
+}
+
+func (c *cli) payment(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: payment <id>")
+	}
+	record, err := c.client.GetPayment(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.printJSON(record)
+}
+
+func (c *cli) refund(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("refund", flag.ExitOnError)
+	amount := flags.String("amount", "", "amount in the token's smallest units (default: whatever is left)")
+	reason := flags.String("reason", "", "reason recorded on the refund")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: refund [-amount N] [-reason TEXT] <id>")
+	}
+
+	record, err := c.client.Refund(ctx, flags.Arg(0), *amount, *reason)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(record)
+	}
+	fmt.Fprintf(c.out, "Refunded %s in %s (%s)\n",
+		xtended402.FormatTokenAmount(record.Network, record.Asset, record.Amount), record.Transaction, record.ID)
+	return nil
+}
+
+func (c *cli) retry(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: retry <id>")
+	}
+	record, err := c.client.RetrySettlement(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(record)
+	}
+	fmt.Fprintf(c.out, "Settled in %s (%s)\n", record.Transaction, record.ID)
+	return nil
+}
+
+func (c *cli) replay(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	all := flags.Bool("all", false, "replay every dead letter")
+	_ = flags.Parse(args)
+
+	switch {
+	case *all && flags.NArg() == 0:
+		replayed, err := c.client.ReplayDeadLetters(ctx)
+		fmt.Fprintf(c.out, "Replayed %d dead letters\n", replayed)
+		return err
+	case !*all && flags.NArg() == 1:
+		if err := c.client.ReplayDeadLetter(ctx, flags.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Replayed %s\n", flags.Arg(0))
+		return nil
+	}
+	return errors.New("usage: replay <id> | replay -all")
+}
+
+func (c *cli) dead(ctx context.Context, args []string) error {
+	letters, err := c.client.DeadLetters(ctx)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(letters)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFAILED\tSOURCE\tDESTINATION\tEVENT\tREPLAYS\tERROR")
+	for _, letter := range letters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			letter.ID,
+			letter.FailedAt.Format(time.RFC3339),
+			letter.Source,
+			letter.Destination,
+			letter.Event.Type,
+			letter.Replays,
+			letter.Error,
+		)
+	}
+	return w.Flush()
+}
+
+func (c *cli) routes(ctx context.Context, args []string) error {
+	routes, err := c.client.Routes(ctx)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(routes)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ROUTE\tSCHEME\tNETWORK\tPRICE\tPAY TO\tSCOPES")
+	for _, route := range routes {
+		for _, option := range route.Accepts {
+			price, payTo := fmt.Sprint(option.Price), option.PayTo
+			if option.DynamicPrice {
+				price = "(dynamic)"
+			}
+			if option.DynamicPayTo {
+				payTo = "(dynamic)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				route.Pattern, option.Scheme, option.Network, price, payTo, strings.Join(route.Scopes, " "))
+		}
+	}
+	return w.Flush()
+}
+
+func (c *cli) stats(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	since := flags.String("since", "", "start time (default: 24h)")
+	until := flags.String("until", "", "end time (default: now)")
+	_ = flags.Parse(args)
+
+	from, err := parseTime(*since)
+	if err != nil {
+		return err
+	}
+	to, err := parseTime(*until)
+	if err != nil {
+		return err
+	}
+
+	stats, err := c.client.Stats(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(stats)
+	}
+
+	fmt.Fprintf(c.out, "%s to %s\n", stats.Since.Format(time.RFC3339), stats.Until.Format(time.RFC3339))
+	fmt.Fprintf(c.out, "Settled %d, failed %d (%.1f%%), refunded %d, pending retries %d\n\n",
+		stats.Settled, stats.Failed, stats.FailureRate*100, stats.Refunded, stats.PendingRetries)
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ROUTE\tNETWORK\tPAYMENTS\tNET REVENUE")
+	for _, revenue := range stats.Revenue {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", revenue.Route, revenue.Network, revenue.Payments, revenue.Display)
+	}
+	return w.Flush()
+}
+
+func (c *cli) export(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "quickbooks", "quickbooks, xero, or json")
+	since := flags.String("since", "", "start time")
+	until := flags.String("until", "", "end time")
+	currency := flags.String("currency", "USD", "fiat currency of exported amounts")
+	output := flags.String("o", "", "output file (default: stdout)")
+	_ = flags.Parse(args)
+
+	var query xtended402.PaymentQuery
+	var err error
+	if query.Since, err = parseTime(*since); err != nil {
+		return err
+	}
+	if query.Until, err = parseTime(*until); err != nil {
+		return err
+	}
+
+	w := c.out
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	exporter := accounting.NewExporter(c.client, xtended402.NewStablecoinPriceOracle(), accounting.WithCurrency(*currency))
+	switch *format {
+	case "quickbooks":
+		return exporter.WriteQuickBooks(ctx, w, query)
+	case "xero":
+		return exporter.WriteXero(ctx, w, query)
+	case "json":
+		records, err := c.client.ListPayments(ctx, query)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+	return fmt.Errorf("unknown export format %q", *format)
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+func (c *cli) freeMode(ctx context.Context, args []string) error {
+	var state *xtended402.FreeModeState
+	var err error
+	switch {
+	case len(args) == 0:
+		state, err = c.client.FreeMode(ctx)
+	case args[0] == "on":
+		flags := flag.NewFlagSet("free-mode on", flag.ExitOnError)
+		routes := flags.String("routes", "", `comma-separated route patterns (default: every paid route), e.g. "GET /reports/*"`)
+		reason := flags.String("reason", "", "reason sent in the X-Payment-Waived header")
+		duration := flags.Duration("for", 0, "turn free mode off automatically after this long")
+		_ = flags.Parse(args[1:])
+
+		on := xtended402.FreeModeState{Enabled: true, Reason: *reason}
+		for _, route := range strings.Split(*routes, ",") {
+			if route = strings.TrimSpace(route); route != "" {
+				on.Routes = append(on.Routes, route)
+			}
+		}
+		if *duration > 0 {
+			until := time.Now().Add(*duration)
+			on.Until = &until
+		}
+		state, err = c.client.SetFreeMode(ctx, on)
+	case args[0] == "off" && len(args) == 1:
+		state, err = c.client.SetFreeMode(ctx, xtended402.FreeModeState{})
+	default:
+		return errors.New("usage: free-mode [on [-routes R1,R2] [-reason TEXT] [-for 2h] | off]")
+	}
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(state)
+	}
+
+	if !state.Enabled {
+		fmt.Fprintln(c.out, "Free mode is off")
+		return nil
+	}
+	routes := "every paid route"
+	if len(state.Routes) > 0 {
+		routes = strings.Join(state.Routes, ", ")
+	}
+	fmt.Fprintf(c.out, "Free mode is on for %s", routes)
+	if state.Reason != "" {
+		fmt.Fprintf(c.out, " (%s)", state.Reason)
+	}
+	if state.Until != nil {
+		fmt.Fprintf(c.out, " until %s", state.Until.Format(time.RFC3339))
+	}
+	fmt.Fprintln(c.out)
+	return nil
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// parseTime parses an RFC 3339 time or a duration before now; "" is the zero time
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-ago).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or a duration like 24h", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// dashboardPath is where Handler serves the dashboard
+const dashboardPath = "/dashboard/"
+
+// dashboard serves the embedded dashboard. The page holds no data and is served without
+// authentication; it asks the operator for the admin token and calls the API with it.
+func dashboard() http.Handler {
+	files, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix(strings.TrimSuffix(dashboardPath, "/"), http.FileServer(http.FS(files)))
+}
+
+// isDashboard reports whether r requests a dashboard file
+func isDashboard(r *http.Request) bool {
+	return r.Method == http.MethodGet && (r.URL.Path+"/" == dashboardPath || strings.HasPrefix(r.URL.Path, dashboardPath))
+}
@@ -0,0 +1,158 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ResolveDispute resolves the open dispute with id as xtended402.DisputeRefunded or
+// xtended402.DisputeUpheld. With refund, whatever is left of the disputed payment is refunded
+// first, as Refund does with note as the reason, and the dispute stays open if that fails.
+func (a *API) ResolveDispute(ctx context.Context, id string, outcome xtended402.DisputeStatus, note string, refund bool) (*xtended402.Dispute, error) {
+	if a.disputes == nil {
+		return nil, errors.New("disputes are not configured")
+	}
+
+	if refund {
+		dispute, err := a.disputes.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if dispute.Status != xtended402.DisputeOpen {
+			return nil, fmt.Errorf("%w: dispute %s is %s", xtended402.ErrDisputeResolved, id, dispute.Status)
+		}
+		if _, err := a.Refund(ctx, dispute.PaymentID, "", note); err != nil {
+			return nil, err
+		}
+	}
+	return a.disputes.Resolve(ctx, id, outcome, note)
+}
+
+func (a *API) listDisputes(w http.ResponseWriter, r *http.Request) {
+	if a.disputes == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("disputes are not configured"))
+		return
+	}
+
+	values := r.URL.Query()
+	disputes, err := a.disputes.List(r.Context(), xtended402.DisputeQuery{
+		Status:      xtended402.DisputeStatus(values.Get("status")),
+		PaymentID:   values.Get("payment"),
+		Transaction: values.Get("transaction"),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if disputes == nil {
+		disputes = []*xtended402.Dispute{}
+	}
+	writeJSON(w, http.StatusOK, disputes)
+}
+
+func (a *API) getDispute(w http.ResponseWriter, r *http.Request) {
+	if a.disputes == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("disputes are not configured"))
+		return
+	}
+
+	dispute, err := a.disputes.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dispute)
+}
+
+func (a *API) disputePayment(w http.ResponseWriter, r *http.Request) {
+	if a.disputes == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("disputes are not configured"))
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	dispute, err := a.disputes.Open(r.Context(), r.PathValue("id"), body.Reason)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, dispute)
+}
+
+func (a *API) addDisputeEvidence(w http.ResponseWriter, r *http.Request) {
+	if a.disputes == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("disputes are not configured"))
+		return
+	}
+
+	var body struct {
+		Author string `json:"author"`
+		Note   string `json:"note"`
+		URL    string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Note == "" && body.URL == "" {
+		writeError(w, http.StatusBadRequest, errors.New("evidence needs a note or a url"))
+		return
+	}
+
+	dispute, err := a.disputes.AddEvidence(r.Context(), r.PathValue("id"), xtended402.DisputeEvidence{
+		Author: body.Author,
+		Note:   body.Note,
+		URL:    body.URL,
+	})
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dispute)
+}
+
+func (a *API) resolveDispute(w http.ResponseWriter, r *http.Request) {
+	if a.disputes == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("disputes are not configured"))
+		return
+	}
+
+	var body struct {
+		Outcome xtended402.DisputeStatus `json:"outcome"`
+		Note    string                   `json:"note"`
+		Refund  bool                     `json:"refund"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Outcome != xtended402.DisputeRefunded && body.Outcome != xtended402.DisputeUpheld {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("outcome must be %q or %q", xtended402.DisputeRefunded, xtended402.DisputeUpheld))
+		return
+	}
+	if body.Refund && body.Outcome != xtended402.DisputeRefunded {
+		writeError(w, http.StatusBadRequest, errors.New("only refunded disputes can refund"))
+		return
+	}
+
+	dispute, err := a.ResolveDispute(r.Context(), r.PathValue("id"), body.Outcome, body.Note, body.Refund)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dispute)
+}
@@ -0,0 +1,163 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// RefundReasonMetadataKey is the PaymentEvent.Metadata key carrying the operator's reason for
+// a refund made through the API
+const RefundReasonMetadataKey = "refundReason"
+
+// refundMu serializes refunds so concurrent requests can't refund more than was paid. It
+// doesn't coordinate replicas; run refunds through one.
+var refundMu sync.Mutex
+
+// Refund refunds amount of the settled payment with id through the Refunder (an empty amount
+// refunds whatever is left), records the refund in the payment store, and sends the
+// payment.refunded event to the API's event handlers. The event carries the payment's metadata
+// (so RefundOrders finds the order) and the refunded transaction under
+// xtended402.RefundedTransactionMetadataKey (so RevokeRefunded revokes its access).
+func (a *API) Refund(ctx context.Context, id, amount, reason string) (*xtended402.PaymentRecord, error) {
+	if a.refunder == nil {
+		return nil, fmt.Errorf("%w: no Refunder configured", ErrNotRefundable)
+	}
+
+	refundMu.Lock()
+	defer refundMu.Unlock()
+
+	payment, err := a.payments.GetPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != xtended402.PaymentStatusSettled || payment.Transaction == "" {
+		return nil, fmt.Errorf("%w: payment %s is %s", ErrNotRefundable, id, payment.Status)
+	}
+
+	remaining, err := a.refundable(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+	if remaining.Sign() == 0 {
+		return nil, fmt.Errorf("%w: payment %s is already fully refunded", ErrNotRefundable, id)
+	}
+	if amount == "" {
+		amount = formatAmount(remaining, payment.Amount)
+	}
+	requested, ok := new(big.Rat).SetString(amount)
+	if !ok || requested.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: invalid amount %q", ErrNotRefundable, amount)
+	}
+	if requested.Cmp(remaining) > 0 {
+		return nil, fmt.Errorf("%w: %s exceeds the %s left to refund", ErrNotRefundable, amount, formatAmount(remaining, payment.Amount))
+	}
+
+	transaction, err := a.refunder.Refund(ctx, payment, amount)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRefundFailed, err)
+	}
+
+	metadata := make(map[string]string, len(payment.Metadata)+2)
+	for key, value := range payment.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.RefundedTransactionMetadataKey] = payment.Transaction
+	if reason != "" {
+		metadata[RefundReasonMetadataKey] = reason
+	}
+
+	event := xtended402.PaymentEvent{
+		ID:          xtended402.NewEventID(),
+		Type:        xtended402.EventPaymentRefunded,
+		CreatedAt:   xtended402.ClockOrSystem(a.clock).Now().UTC(),
+		Resource:    payment.Resource,
+		Scheme:      payment.Scheme,
+		Network:     payment.Network,
+		Asset:       payment.Asset,
+		Amount:      amount,
+		PayTo:       payment.PayTo,
+		Payer:       payment.Payer,
+		Transaction: transaction,
+		Metadata:    metadata,
+	}
+
+	// The funds have moved, so a bookkeeping failure is logged rather than returned
+	record, _ := xtended402.NewPaymentRecord(event)
+	if err := a.payments.SavePayment(ctx, record); err != nil {
+		fmt.Printf("Warning: refund %s of payment %s not recorded: %v\n", transaction, id, err)
+	}
+	for _, handler := range a.handlers {
+		handler(ctx, event)
+	}
+
+	return record, nil
+}
+
+// refundable returns how much of payment hasn't been refunded yet
+func (a *API) refundable(ctx context.Context, payment *xtended402.PaymentRecord) (*big.Rat, error) {
+	remaining, ok := new(big.Rat).SetString(payment.Amount)
+	if !ok {
+		return nil, fmt.Errorf("%w: payment amount %q is not a number", ErrNotRefundable, payment.Amount)
+	}
+
+	refunds, err := a.payments.ListPayments(ctx, xtended402.PaymentQuery{
+		Status: xtended402.PaymentStatusRefunded,
+		Payer:  payment.Payer,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, refund := range refunds {
+		if !strings.EqualFold(refund.Metadata[xtended402.RefundedTransactionMetadataKey], payment.Transaction) {
+			continue
+		}
+		if refunded, ok := new(big.Rat).SetString(refund.Amount); ok {
+			remaining.Sub(remaining, refunded)
+		}
+	}
+
+	if remaining.Sign() < 0 {
+		remaining.SetInt64(0)
+	}
+	return remaining, nil
+}
+
+// formatAmount formats value with as many decimal places as like, e.g. "3.50" for "5.00"
+func formatAmount(value *big.Rat, like string) string {
+	decimals := 0
+	if dot := strings.IndexByte(like, '.'); dot >= 0 {
+		decimals = len(like) - dot - 1
+	}
+	return value.FloatString(decimals)
+}
+
+// RetrySettlement retries the failed payment with id through the SettlementRetrier and records
+// the settled payment it returns
+func (a *API) RetrySettlement(ctx context.Context, id string) (*xtended402.PaymentRecord, error) {
+	if a.retrier == nil {
+		return nil, fmt.Errorf("%w: no SettlementRetrier configured", ErrNotRetryable)
+	}
+
+	payment, err := a.payments.GetPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != xtended402.PaymentStatusFailed {
+		return nil, fmt.Errorf("%w: payment %s is %s", ErrNotRetryable, id, payment.Status)
+	}
+
+	settled, err := a.retrier.RetrySettlement(ctx, payment)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRetryFailed, err)
+	}
+	if err := a.payments.SavePayment(ctx, settled); err != nil {
+		fmt.Printf("Warning: retried settlement of payment %s not recorded: %v\n", id, err)
+	}
+
+	return settled, nil
+}
@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"sort"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// Route describes a paid route for operators
+type Route struct {
+	Pattern     string       `json:"pattern"`
+	Description string       `json:"description,omitempty"`
+	MimeType    string       `json:"mimeType,omitempty"`
+	Scopes      []string     `json:"scopes,omitempty"`
+	Accepts     []RoutePrice `json:"accepts"`
+}
+
+// RoutePrice describes one payment option of a route. Prices and recipients resolved per
+// request are reported as dynamic rather than evaluated.
+type RoutePrice struct {
+	Scheme  string       `json:"scheme"`
+	Network x402.Network `json:"network"`
+
+	// Price is the configured x402.Price (e.g. "$0.01"), or nil when DynamicPrice is set
+	Price        x402.Price `json:"price,omitempty"`
+	DynamicPrice bool       `json:"dynamicPrice,omitempty"`
+
+	// PayTo is the configured recipient, or "" when DynamicPayTo is set
+	PayTo        string `json:"payTo,omitempty"`
+	DynamicPayTo bool   `json:"dynamicPayTo,omitempty"`
+}
+
+// Routes describes routes sorted by pattern
+func Routes(routes x402http.RoutesConfig) []Route {
+	result := make([]Route, 0, len(routes))
+	for pattern, route := range routes {
+		described := Route{
+			Pattern:     pattern,
+			Description: route.Description,
+			MimeType:    route.MimeType,
+			Scopes:      xtended402.RouteScopes(route),
+			Accepts:     make([]RoutePrice, 0, len(route.Accepts)),
+		}
+		for _, option := range route.Accepts {
+			price := RoutePrice{Scheme: option.Scheme, Network: option.Network}
+			if _, ok := option.Price.(x402http.DynamicPriceFunc); ok {
+				price.DynamicPrice = true
+			} else {
+				price.Price = option.Price
+			}
+			if _, ok := option.PayTo.(x402http.DynamicPayToFunc); ok {
+				price.DynamicPayTo = true
+			} else if payTo, ok := option.PayTo.(string); ok {
+				price.PayTo = payTo
+			}
+			described.Accepts = append(described.Accepts, price)
+		}
+		result = append(result, described)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Pattern < result[j].Pattern })
+	return result
+}
@@ -0,0 +1,197 @@
+package admin
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// defaultStatsWindow is the window GET /stats summarizes without since
+const defaultStatsWindow = 24 * time.Hour
+
+// Stats summarizes the payments recorded in a window
+type Stats struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+
+	Settled  int `json:"settled"`
+	Failed   int `json:"failed"`
+	Refunded int `json:"refunded"`
+
+	// FailureRate is the share of settlement attempts that failed (0 without attempts)
+	FailureRate float64 `json:"failureRate"`
+
+	// Revenue is settled minus refunded amounts, per route and token, with platform fees
+	Revenue []RouteRevenue `json:"revenue"`
+
+	// PendingRetries counts dead letters waiting to be replayed (0 without WithDeadLetters)
+	PendingRetries int `json:"pendingRetries"`
+}
+
+// RouteRevenue is the net revenue of one route in one token
+type RouteRevenue struct {
+	// Route is the matching pattern from WithRoutes, or the resource itself
+	Route   string       `json:"route"`
+	Network x402.Network `json:"network"`
+	Asset   string       `json:"asset"`
+
+	// Amount is in the token's smallest units (major units for fiat)
+	Amount  string `json:"amount"`
+	Display string `json:"display"`
+
+	// Fees are the platform fees on the settled payments (see xtended402.PlatformFee), and Net
+	// is Amount less them. Refunds don't return fees.
+	Fees        string `json:"fees"`
+	FeesDisplay string `json:"feesDisplay"`
+	Net         string `json:"net"`
+	NetDisplay  string `json:"netDisplay"`
+
+	Payments int `json:"payments"`
+}
+
+// Stats summarizes the payments recorded in [since, until)
+func (a *API) Stats(ctx context.Context, since, until time.Time) (*Stats, error) {
+	records, err := a.payments.ListPayments(ctx, xtended402.PaymentQuery{Since: since, Until: until})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{Since: since, Until: until, Revenue: []RouteRevenue{}}
+
+	type revenueKey struct {
+		route   string
+		network x402.Network
+		asset   string
+	}
+	totals := make(map[revenueKey]*big.Rat)
+	fees := make(map[revenueKey]*big.Rat)
+	counts := make(map[revenueKey]int)
+	precision := make(map[revenueKey]string)
+
+	for _, record := range records {
+		switch record.Status {
+		case xtended402.PaymentStatusSettled:
+			stats.Settled++
+		case xtended402.PaymentStatusFailed:
+			stats.Failed++
+			continue
+		case xtended402.PaymentStatusRefunded:
+			stats.Refunded++
+		default:
+			continue
+		}
+
+		amount, ok := new(big.Rat).SetString(record.Amount)
+		if !ok {
+			continue
+		}
+		key := revenueKey{a.routeOf(record.Resource), record.Network, record.Asset}
+		if totals[key] == nil {
+			totals[key] = new(big.Rat)
+			fees[key] = new(big.Rat)
+			precision[key] = record.Amount
+		}
+		if record.Status == xtended402.PaymentStatusRefunded {
+			totals[key].Sub(totals[key], amount)
+		} else {
+			totals[key].Add(totals[key], amount)
+			counts[key]++
+			if fee, ok := new(big.Rat).SetString(record.Fee); ok {
+				fees[key].Add(fees[key], fee)
+			}
+		}
+	}
+
+	if attempts := stats.Settled + stats.Failed; attempts > 0 {
+		stats.FailureRate = float64(stats.Failed) / float64(attempts)
+	}
+
+	for key, total := range totals {
+		amount := formatAmount(total, precision[key])
+		fee := formatAmount(fees[key], precision[key])
+		net := formatAmount(new(big.Rat).Sub(total, fees[key]), precision[key])
+		stats.Revenue = append(stats.Revenue, RouteRevenue{
+			Route:       key.route,
+			Network:     key.network,
+			Asset:       key.asset,
+			Amount:      amount,
+			Display:     displayAmount(key.network, key.asset, amount),
+			Fees:        fee,
+			FeesDisplay: displayAmount(key.network, key.asset, fee),
+			Net:         net,
+			NetDisplay:  displayAmount(key.network, key.asset, net),
+			Payments:    counts[key],
+		})
+	}
+	sort.Slice(stats.Revenue, func(i, j int) bool {
+		ri, rj := stats.Revenue[i], stats.Revenue[j]
+		switch {
+		case ri.Route != rj.Route:
+			return ri.Route < rj.Route
+		case ri.Network != rj.Network:
+			return ri.Network < rj.Network
+		}
+		return ri.Asset < rj.Asset
+	})
+
+	if a.deadLetters != nil {
+		letters, err := a.deadLetters.Queue().ListDeadLetters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stats.PendingRetries = len(letters)
+	}
+
+	return stats, nil
+}
+
+// routeOf returns the pattern from WithRoutes matching resource, or resource
+func (a *API) routeOf(resource string) string {
+	patterns := make([]string, 0, len(a.routes))
+	for pattern := range a.routes {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if xtended402.ParseRoutePattern(pattern).Matches(resource) {
+			return pattern
+		}
+	}
+	return resource
+}
+
+// displayAmount formats amount for people, keeping the sign of net refunds
+func displayAmount(network x402.Network, asset, amount string) string {
+	if abs, negative := strings.CutPrefix(amount, "-"); negative {
+		return "-" + xtended402.FormatTokenAmount(network, asset, abs)
+	}
+	return xtended402.FormatTokenAmount(network, asset, amount)
+}
+
+func (a *API) getStats(w http.ResponseWriter, r *http.Request) {
+	query, err := ParsePaymentQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if query.Until.IsZero() {
+		query.Until = xtended402.ClockOrSystem(a.clock).Now().UTC()
+	}
+	if query.Since.IsZero() {
+		query.Since = query.Until.Add(-defaultStatsWindow)
+	}
+
+	stats, err := a.Stats(r.Context(), query.Since, query.Until)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
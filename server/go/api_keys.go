@@ -0,0 +1,416 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API key headers
+const (
+	// APIKeyHeader carries an API key: on the response to the payment that minted it, and on
+	// later requests (which may also use "Authorization: Bearer <key>")
+	APIKeyHeader = "X-API-Key"
+
+	// APIKeyRemainingHeader reports the key's remaining quota on requests it paid for
+	APIKeyRemainingHeader = "X-API-Key-Remaining"
+)
+
+// APIKeyDataKey is the Gin context key where the APIKey is stored for requests it paid for
+const APIKeyDataKey = "xtended402APIKey"
+
+// apiKeyPrefix starts every API key, so keys are recognizable in logs and secret scanners
+const apiKeyPrefix = "x402k_"
+
+var (
+	// ErrAPIKeyNotFound is returned for unknown API key IDs
+	ErrAPIKeyNotFound = errors.New("API key not found")
+
+	// ErrAPIKeyExhausted is returned by APIKeyStore.ConsumeAPIKey when the quota is used up
+	ErrAPIKeyExhausted = errors.New("API key quota exhausted")
+
+	// ErrAPIKeyInvalid is returned (possibly wrapped) for malformed, unknown, expired, or
+	// out-of-scope keys
+	ErrAPIKeyInvalid = errors.New("API key invalid")
+)
+
+// APIKey is a key minted by a payment. Only a hash of its secret is stored.
+type APIKey struct {
+	ID         string `json:"id"`
+	SecretHash string `json:"secretHash"`
+
+	// Payer and Transaction identify the payment that minted the key
+	Payer       string `json:"payer,omitempty"`
+	Transaction string `json:"transaction,omitempty"`
+
+	// Quota is the number of requests left; Granted is the quota it was minted with
+	Quota   int64 `json:"quota"`
+	Granted int64 `json:"granted"`
+
+	// Scope lists the route patterns the key pays for; empty means every paid route
+	Scope []string `json:"scope,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+
+	// ExpiresAt is zero for keys that don't expire
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+// APIKeyStore keeps API keys and their quotas
+type APIKeyStore interface {
+	SaveAPIKey(ctx context.Context, key *APIKey) error
+
+	// GetAPIKey returns the key with id, or ErrAPIKeyNotFound
+	GetAPIKey(ctx context.Context, id string) (*APIKey, error)
+
+	// ConsumeAPIKey atomically takes n from the key's quota and returns the updated key, or
+	// returns ErrAPIKeyExhausted if less than n is left. A negative n returns quota.
+	ConsumeAPIKey(ctx context.Context, id string, n int64) (*APIKey, error)
+}
+
+// APIKeyProvisioner mints API keys with a quota when payments settle on its mint routes, and
+// authorizes later requests by key, so clients can pay once in crypto and then call the API
+// with an ordinary key. Use it with ginmw.WithAPIKeys.
+type APIKeyProvisioner struct {
+	store APIKeyStore
+	mints []mintRule
+	ttl   time.Duration
+	scope []string
+	clock Clock
+}
+
+type mintRule struct {
+	pattern string
+	route   RoutePattern
+	quota   int64
+}
+
+// APIKeyOption configures an APIKeyProvisioner
+type APIKeyOption func(*APIKeyProvisioner)
+
+// WithAPIKeyMintRoute mints a key with quota requests for each payment settled on routes
+// matching pattern (same syntax as x402http.RoutesConfig keys), e.g. "POST /api/keys". Repeat
+// it for tiers. The first matching pattern wins.
+func WithAPIKeyMintRoute(pattern string, quota int64) APIKeyOption {
+	return func(p *APIKeyProvisioner) {
+		p.mints = append(p.mints, mintRule{pattern: pattern, route: ParseRoutePattern(pattern), quota: quota})
+	}
+}
+
+// WithAPIKeyTTL makes keys expire ttl after minting (default never)
+func WithAPIKeyTTL(ttl time.Duration) APIKeyOption {
+	return func(p *APIKeyProvisioner) {
+		p.ttl = ttl
+	}
+}
+
+// WithAPIKeyScope limits keys to resources matching the route patterns. By default a key pays
+// for every paid route behind the middleware except the mint routes.
+func WithAPIKeyScope(patterns ...string) APIKeyOption {
+	return func(p *APIKeyProvisioner) {
+		p.scope = append(p.scope, patterns...)
+	}
+}
+
+// WithAPIKeyClock sets the clock for creation and expiry times (default SystemClock)
+func WithAPIKeyClock(clock Clock) APIKeyOption {
+	return func(p *APIKeyProvisioner) {
+		p.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewAPIKeyProvisioner creates an APIKeyProvisioner keeping keys in store
+func NewAPIKeyProvisioner(store APIKeyStore, opts ...APIKeyOption) *APIKeyProvisioner {
+	p := &APIKeyProvisioner{
+		store: store,
+		clock: SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// MintsFor reports whether payments for resource mint a key, and with what quota
+func (p *APIKeyProvisioner) MintsFor(resource string) (int64, bool) {
+	for _, rule := range p.mints {
+		if rule.route.Matches(resource) {
+			return rule.quota, true
+		}
+	}
+	return 0, false
+}
+
+// Mint creates a key for the settled payment described by event if its resource is a mint
+// route. It returns the key's secret, which is shown only once, or "" if nothing was minted.
+func (p *APIKeyProvisioner) Mint(ctx context.Context, event PaymentEvent) (string, *APIKey, error) {
+	quota, ok := p.MintsFor(event.Resource)
+	if !ok {
+		return "", nil, nil
+	}
+
+	id := make([]byte, 8)
+	secret := make([]byte, 24)
+	_, _ = rand.Read(id)
+	_, _ = rand.Read(secret)
+
+	now := p.clock.Now().UTC()
+	key := &APIKey{
+		ID:          hex.EncodeToString(id),
+		SecretHash:  hashAPIKeySecret(hex.EncodeToString(secret)),
+		Payer:       event.Payer,
+		Transaction: event.Transaction,
+		Quota:       quota,
+		Granted:     quota,
+		Scope:       p.scope,
+		CreatedAt:   now,
+	}
+	if p.ttl > 0 {
+		key.ExpiresAt = now.Add(p.ttl)
+	}
+
+	if err := p.store.SaveAPIKey(ctx, key); err != nil {
+		return "", nil, fmt.Errorf("failed to save API key: %w", err)
+	}
+	return apiKeyPrefix + key.ID + "_" + hex.EncodeToString(secret), key, nil
+}
+
+// Authorize checks apiKey for resource and consumes one request of its quota. Keys don't pay
+// for mint routes. Returns ErrAPIKeyInvalid or ErrAPIKeyExhausted (possibly wrapped) when the
+// key can't be used.
+func (p *APIKeyProvisioner) Authorize(ctx context.Context, apiKey, resource string) (*APIKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(apiKey, apiKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(apiKey, apiKeyPrefix) {
+		return nil, fmt.Errorf("%w: malformed", ErrAPIKeyInvalid)
+	}
+	if _, mint := p.MintsFor(resource); mint {
+		return nil, fmt.Errorf("%w: keys can't pay for %s", ErrAPIKeyInvalid, resource)
+	}
+
+	key, err := p.store.GetAPIKey(ctx, id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, fmt.Errorf("%w: unknown key", ErrAPIKeyInvalid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashAPIKeySecret(secret))) != 1 {
+		return nil, fmt.Errorf("%w: unknown key", ErrAPIKeyInvalid)
+	}
+	if !key.ExpiresAt.IsZero() && !p.clock.Now().Before(key.ExpiresAt) {
+		return nil, fmt.Errorf("%w: expired", ErrAPIKeyInvalid)
+	}
+	if len(key.Scope) > 0 && !scopeMatches(key.Scope, resource) {
+		return nil, fmt.Errorf("%w: not valid for %s", ErrAPIKeyInvalid, resource)
+	}
+
+	return p.store.ConsumeAPIKey(ctx, key.ID, 1)
+}
+
+// TopUp describes how to replace an exhausted key: the mint routes, suggesting the first
+func (p *APIKeyProvisioner) TopUp() *TopUp {
+	topUp := &TopUp{
+		Model:     TopUpAPIKey,
+		Remaining: "0",
+		Required:  "1",
+		Suggested: "0",
+		Routes:    make([]string, len(p.mints)),
+	}
+	for i, rule := range p.mints {
+		topUp.Routes[i] = rule.pattern
+	}
+	if len(p.mints) > 0 {
+		topUp.Suggested = strconv.FormatInt(p.mints[0].quota, 10)
+	}
+	return topUp
+}
+
+// Restore returns one request to the key's quota, e.g. when the handler it paid for failed
+func (p *APIKeyProvisioner) Restore(ctx context.Context, key *APIKey) error {
+	_, err := p.store.ConsumeAPIKey(ctx, key.ID, -1)
+	return err
+}
+
+// Key returns the API key presented with r, if any
+func (p *APIKeyProvisioner) Key(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		if key := strings.TrimSpace(auth[7:]); strings.HasPrefix(key, apiKeyPrefix) {
+			return key
+		}
+	}
+	return ""
+}
+
+// GetAPIKey returns the API key that paid for the request, or nil
+func GetAPIKey(c *gin.Context) *APIKey {
+	key, exists := c.Get(APIKeyDataKey)
+	if !exists {
+		return nil
+	}
+	return key.(*APIKey)
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// consume applies n to key's quota in place
+func consume(key *APIKey, n int64) error {
+	if n > 0 && key.Quota < n {
+		return ErrAPIKeyExhausted
+	}
+	key.Quota -= n
+	if key.Quota > key.Granted {
+		key.Quota = key.Granted
+	}
+	return nil
+}
+
+// ============================================================================
+// API Key Stores
+// ============================================================================
+
+// MemoryAPIKeyStore is an in-process APIKeyStore. Keys are lost on restart.
+type MemoryAPIKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]*APIKey
+}
+
+// NewMemoryAPIKeyStore creates an empty MemoryAPIKeyStore
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: make(map[string]*APIKey)}
+}
+
+// SaveAPIKey stores key, replacing any key with the same ID
+func (s *MemoryAPIKeyStore) SaveAPIKey(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *key
+	s.keys[key.ID] = &stored
+	return nil
+}
+
+// GetAPIKey returns the key with id, or ErrAPIKeyNotFound
+func (s *MemoryAPIKeyStore) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	found := *key
+	return &found, nil
+}
+
+// ConsumeAPIKey takes n from the key's quota
+func (s *MemoryAPIKeyStore) ConsumeAPIKey(ctx context.Context, id string, n int64) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err := consume(key, n); err != nil {
+		return nil, err
+	}
+	found := *key
+	return &found, nil
+}
+
+// FileAPIKeyStore keeps each key as a JSON file in a directory, so keys survive restarts.
+// Quota updates are atomic within one process only; use a database-backed APIKeyStore when
+// several replicas share keys.
+type FileAPIKeyStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileAPIKeyStore creates a FileAPIKeyStore in dir, which is created if needed
+func NewFileAPIKeyStore(dir string) *FileAPIKeyStore {
+	return &FileAPIKeyStore{dir: dir}
+}
+
+// SaveAPIKey writes key to its file
+func (s *FileAPIKeyStore) SaveAPIKey(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(key)
+}
+
+// GetAPIKey reads the key with id, or returns ErrAPIKeyNotFound
+func (s *FileAPIKeyStore) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read(id)
+}
+
+// ConsumeAPIKey takes n from the key's quota
+func (s *FileAPIKeyStore) ConsumeAPIKey(ctx context.Context, id string, n int64) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := consume(key, n); err != nil {
+		return nil, err
+	}
+	if err := s.write(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *FileAPIKeyStore) read(id string) (*APIKey, error) {
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var key APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("invalid API key file for %s: %w", id, err)
+	}
+	return &key, nil
+}
+
+func (s *FileAPIKeyStore) write(key *APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(key.ID), data)
+}
+
+func (s *FileAPIKeyStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
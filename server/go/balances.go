@@ -0,0 +1,414 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/gin-gonic/gin"
+)
+
+// BalanceHeader reports the payer's remaining balance, in the asset's smallest unit, on
+// deposits and on requests paid from the balance
+const BalanceHeader = "X-Balance"
+
+// BalanceDebitKey is the Gin context key where the BalanceDebit is stored for requests paid
+// from a balance
+const BalanceDebitKey = "xtended402BalanceDebit"
+
+// balanceReferenceTTL is how long stores remember applied references. Signed payments expire
+// well before this, so an older payment can't be replayed against a balance.
+const balanceReferenceTTL = 24 * time.Hour
+
+var (
+	// ErrInsufficientBalance is returned by BalanceStore.AdjustBalance when a debit exceeds the
+	// balance
+	ErrInsufficientBalance = errors.New("insufficient balance")
+
+	// ErrDuplicateReference is returned by BalanceStore.AdjustBalance when the reference was
+	// already applied, e.g. a replayed payment or a retried deposit
+	ErrDuplicateReference = errors.New("balance reference already applied")
+)
+
+// BalanceDebit records a request paid from a balance
+type BalanceDebit struct {
+	Account  string `json:"account"`
+	Currency string `json:"currency"`
+	Resource string `json:"resource"`
+
+	// Amount is the price debited and Balance what's left, both in the asset's smallest unit
+	Amount  *big.Int `json:"amount"`
+	Balance *big.Int `json:"balance"`
+
+	// Reference identifies the signed payment that authorized the debit
+	Reference string `json:"reference"`
+}
+
+// BalanceStore keeps prepaid balances per account (payer address) and currency (see
+// BalanceCurrency), in the asset's smallest unit
+type BalanceStore interface {
+	// GetBalance returns the balance, zero if the account has none
+	GetBalance(ctx context.Context, account, currency string) (*big.Int, error)
+
+	// AdjustBalance atomically adds delta (negative for debits) to the balance and returns the
+	// new balance. It returns ErrInsufficientBalance if the balance would go negative, and
+	// ErrDuplicateReference if reference was already applied to the account; either way the
+	// balance is unchanged.
+	AdjustBalance(ctx context.Context, account, currency string, delta *big.Int, reference string) (*big.Int, error)
+}
+
+// Balances lets payers pre-fund an account through deposit routes and then pay for other
+// routes from that balance, without a settlement per request. Use it with ginmw.WithBalances.
+type Balances struct {
+	store    BalanceStore
+	deposits []string
+	routes   []string
+	topUp    int64
+}
+
+// BalanceOption configures Balances
+type BalanceOption func(*Balances)
+
+// WithDepositRoute credits the settled amount of each payment on routes matching pattern
+// (same syntax as x402http.RoutesConfig keys) to the payer's balance, e.g.
+// "POST /account/deposit". Repeat it for several deposit routes.
+func WithDepositRoute(pattern string) BalanceOption {
+	return func(b *Balances) {
+		b.deposits = append(b.deposits, pattern)
+	}
+}
+
+// WithBalanceRoutes limits draw-down to resources matching the route patterns. By default
+// every paid route except the deposit routes is paid from the balance.
+func WithBalanceRoutes(patterns ...string) BalanceOption {
+	return func(b *Balances) {
+		b.routes = append(b.routes, patterns...)
+	}
+}
+
+// WithSuggestedTopUp sets how many requests at the current price the suggested top-up should
+// fund (default 100). The suggestion always covers at least the shortfall.
+func WithSuggestedTopUp(requests int64) BalanceOption {
+	return func(b *Balances) {
+		b.topUp = requests
+	}
+}
+
+// NewBalances creates Balances keeping balances in store
+func NewBalances(store BalanceStore, opts ...BalanceOption) *Balances {
+	b := &Balances{
+		store: store,
+		topUp: 100,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// IsDeposit reports whether resource is a deposit route
+func (b *Balances) IsDeposit(resource string) bool {
+	return scopeMatches(b.deposits, resource)
+}
+
+// Deposits returns the deposit route patterns, for pointing clients at a top-up
+func (b *Balances) Deposits() []string {
+	return append([]string(nil), b.deposits...)
+}
+
+// DrawsDown reports whether resource is paid from the balance
+func (b *Balances) DrawsDown(resource string) bool {
+	if b.IsDeposit(resource) {
+		return false
+	}
+	return len(b.routes) == 0 || scopeMatches(b.routes, resource)
+}
+
+// Deposit credits the settled payment described by event to the payer's balance if its
+// resource is a deposit route, and returns the new balance (nil if nothing was credited).
+// Deposits are applied once per transaction.
+func (b *Balances) Deposit(ctx context.Context, event PaymentEvent) (*big.Int, error) {
+	if !b.IsDeposit(event.Resource) || event.Payer == "" {
+		return nil, nil
+	}
+	amount, ok := new(big.Int).SetString(event.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid deposit amount %q", event.Amount)
+	}
+
+	currency := BalanceCurrency(string(event.Network), event.Asset)
+	return b.store.AdjustBalance(ctx, balanceAccount(event.Payer), currency, amount, "tx:"+event.Transaction)
+}
+
+// Debit pays for resource from the balance of the verified payload's payer, at the price in
+// requirements. The payload itself is never settled; it proves the payer's identity and can
+// only be used for one debit. Returns ErrInsufficientBalance or ErrDuplicateReference
+// (possibly wrapped) when the request can't be paid from the balance.
+func (b *Balances) Debit(ctx context.Context, payload *x402types.PaymentPayload, requirements *x402types.PaymentRequirements, resource string) (*BalanceDebit, error) {
+	payer := PayerOf(payload)
+	if payer == "" {
+		return nil, fmt.Errorf("payment has no payer")
+	}
+	amount, ok := new(big.Int).SetString(requirements.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid price %q", requirements.Amount)
+	}
+
+	debit := &BalanceDebit{
+		Account:   balanceAccount(payer),
+		Currency:  BalanceCurrency(requirements.Network, requirements.Asset),
+		Resource:  resource,
+		Amount:    amount,
+		Reference: "pay:" + paymentReference(payload),
+	}
+	balance, err := b.store.AdjustBalance(ctx, debit.Account, debit.Currency, new(big.Int).Neg(amount), debit.Reference)
+	if err != nil {
+		return nil, err
+	}
+	debit.Balance = balance
+	return debit, nil
+}
+
+// Refund returns a debit to the balance, e.g. when the handler it paid for failed
+func (b *Balances) Refund(ctx context.Context, debit *BalanceDebit) (*big.Int, error) {
+	return b.store.AdjustBalance(ctx, debit.Account, debit.Currency, debit.Amount, "refund:"+debit.Reference)
+}
+
+// Balance returns payer's balance in the asset on network
+func (b *Balances) Balance(ctx context.Context, payer, network, asset string) (*big.Int, error) {
+	return b.store.GetBalance(ctx, balanceAccount(payer), BalanceCurrency(network, asset))
+}
+
+// TopUp describes how the verified payload's payer can top up to afford requirements
+func (b *Balances) TopUp(ctx context.Context, payload *x402types.PaymentPayload, requirements *x402types.PaymentRequirements) (*TopUp, error) {
+	price, ok := new(big.Int).SetString(requirements.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid price %q", requirements.Amount)
+	}
+	balance, err := b.Balance(ctx, PayerOf(payload), requirements.Network, requirements.Asset)
+	if err != nil {
+		return nil, err
+	}
+
+	suggested := new(big.Int).Mul(price, big.NewInt(b.topUp))
+	suggested.Sub(suggested, balance)
+	if shortfall := new(big.Int).Sub(price, balance); suggested.Cmp(shortfall) < 0 {
+		suggested = shortfall
+	}
+
+	return &TopUp{
+		Model:     TopUpBalance,
+		Remaining: balance.String(),
+		Required:  price.String(),
+		Suggested: suggested.String(),
+		Network:   requirements.Network,
+		Asset:     requirements.Asset,
+		Routes:    b.Deposits(),
+	}, nil
+}
+
+// BalanceCurrency names the balance for asset on network, since the same token has a
+// different address (and a separate balance) on each chain
+func BalanceCurrency(network, asset string) string {
+	return network + "/" + strings.ToLower(asset)
+}
+
+// GetBalanceDebit returns the debit that paid for the request, or nil
+func GetBalanceDebit(c *gin.Context) *BalanceDebit {
+	debit, exists := c.Get(BalanceDebitKey)
+	if !exists {
+		return nil
+	}
+	return debit.(*BalanceDebit)
+}
+
+func balanceAccount(payer string) string {
+	return strings.ToLower(payer)
+}
+
+// paymentReference identifies a signed payment by a hash of its signed payload
+func paymentReference(payload *x402types.PaymentPayload) string {
+	data, _ := json.Marshal(payload.Payload)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ============================================================================
+// Balance Stores
+// ============================================================================
+
+// balanceRecord is one account's balance in one currency, with the references recently
+// applied to it
+type balanceRecord struct {
+	Balance    *big.Int             `json:"balance"`
+	References map[string]time.Time `json:"references,omitempty"`
+}
+
+// adjust applies delta to the record at now, forgetting references older than
+// balanceReferenceTTL
+func (r *balanceRecord) adjust(delta *big.Int, reference string, now time.Time) error {
+	for ref, appliedAt := range r.References {
+		if now.Sub(appliedAt) > balanceReferenceTTL {
+			delete(r.References, ref)
+		}
+	}
+	if reference != "" {
+		if _, applied := r.References[reference]; applied {
+			return ErrDuplicateReference
+		}
+	}
+
+	balance := new(big.Int).Add(r.Balance, delta)
+	if balance.Sign() < 0 {
+		return ErrInsufficientBalance
+	}
+	r.Balance = balance
+	if reference != "" {
+		if r.References == nil {
+			r.References = make(map[string]time.Time)
+		}
+		r.References[reference] = now
+	}
+	return nil
+}
+
+func newBalanceRecord() *balanceRecord {
+	return &balanceRecord{Balance: new(big.Int)}
+}
+
+// MemoryBalanceStore is an in-process BalanceStore. Balances are lost on restart, so use it
+// for development only.
+type MemoryBalanceStore struct {
+	mu      sync.Mutex
+	records map[string]*balanceRecord
+	clock   Clock
+}
+
+// NewMemoryBalanceStore creates an empty MemoryBalanceStore. clock (nil uses SystemClock)
+// times applied references.
+func NewMemoryBalanceStore(clock Clock) *MemoryBalanceStore {
+	return &MemoryBalanceStore{
+		records: make(map[string]*balanceRecord),
+		clock:   ClockOrSystem(clock),
+	}
+}
+
+// GetBalance returns the balance, zero if the account has none
+func (s *MemoryBalanceStore) GetBalance(ctx context.Context, account, currency string) (*big.Int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[balanceKey(account, currency)]
+	if !ok {
+		return new(big.Int), nil
+	}
+	return new(big.Int).Set(record.Balance), nil
+}
+
+// AdjustBalance adds delta to the balance
+func (s *MemoryBalanceStore) AdjustBalance(ctx context.Context, account, currency string, delta *big.Int, reference string) (*big.Int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := balanceKey(account, currency)
+	record, ok := s.records[key]
+	if !ok {
+		record = newBalanceRecord()
+		s.records[key] = record
+	}
+	if err := record.adjust(delta, reference, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	return new(big.Int).Set(record.Balance), nil
+}
+
+// FileBalanceStore keeps each balance as a JSON file in a directory, so balances survive
+// restarts. Adjustments are atomic within one process only; use a database-backed
+// BalanceStore when several replicas share balances.
+type FileBalanceStore struct {
+	dir   string
+	clock Clock
+	mu    sync.Mutex
+}
+
+// NewFileBalanceStore creates a FileBalanceStore in dir, which is created if needed. clock
+// (nil uses SystemClock) times applied references.
+func NewFileBalanceStore(dir string, clock Clock) *FileBalanceStore {
+	return &FileBalanceStore{dir: dir, clock: ClockOrSystem(clock)}
+}
+
+// GetBalance reads the balance, zero if the account has none
+func (s *FileBalanceStore) GetBalance(ctx context.Context, account, currency string) (*big.Int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.read(account, currency)
+	if err != nil {
+		return nil, err
+	}
+	return record.Balance, nil
+}
+
+// AdjustBalance adds delta to the balance
+func (s *FileBalanceStore) AdjustBalance(ctx context.Context, account, currency string, delta *big.Int, reference string) (*big.Int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.read(account, currency)
+	if err != nil {
+		return nil, err
+	}
+	if err := record.adjust(delta, reference, s.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(s.path(account, currency), data); err != nil {
+		return nil, err
+	}
+	return record.Balance, nil
+}
+
+func (s *FileBalanceStore) read(account, currency string) (*balanceRecord, error) {
+	data, err := os.ReadFile(s.path(account, currency))
+	if errors.Is(err, os.ErrNotExist) {
+		return newBalanceRecord(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := newBalanceRecord()
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("invalid balance file for %s: %w", account, err)
+	}
+	if record.Balance == nil {
+		record.Balance = new(big.Int)
+	}
+	return record, nil
+}
+
+// path names the balance's file by a hash of its key, since currencies contain slashes
+func (s *FileBalanceStore) path(account, currency string) string {
+	sum := sha256.Sum256([]byte(balanceKey(account, currency)))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func balanceKey(account, currency string) string {
+	return account + "|" + currency
+}
@@ -0,0 +1,90 @@
+package xtended402
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coinbase/x402/go/extensions/bazaar"
+	exttypes "github.com/coinbase/x402/go/extensions/types"
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// BazaarListing describes a paid route for Bazaar discovery, so facilitators cataloging the
+// route list what it does and how to call it rather than just its URL
+type BazaarListing struct {
+	// Title and Description become the route's resource description, "Title: Description".
+	// Bazaar has no separate title.
+	Title       string
+	Description string
+
+	// MediaType is the response's MIME type, e.g. "application/json"
+	MediaType string
+
+	// Method is the HTTP method buyers call, needed only when the route pattern has none
+	Method string
+
+	// Input is an example request: query parameters for GET, HEAD, and DELETE, or a body for
+	// POST, PUT, and PATCH. InputSchema is its JSON Schema, and BodyType how the body is
+	// encoded ("json" by default; "form-data" or "text").
+	Input       interface{}
+	InputSchema map[string]interface{}
+	BodyType    string
+
+	// Output is an example response, and OutputSchema its JSON Schema
+	Output       interface{}
+	OutputSchema map[string]interface{}
+}
+
+// Extension builds the Bazaar discovery extension for the listing, called with method
+func (l BazaarListing) Extension(method string) (exttypes.DiscoveryExtension, error) {
+	if l.Method != "" {
+		method = l.Method
+	}
+	method = strings.ToUpper(method)
+	if method == "" || method == "*" {
+		return exttypes.DiscoveryExtension{}, fmt.Errorf("bazaar listing needs a Method for routes matching any method")
+	}
+
+	var output *exttypes.OutputConfig
+	if l.Output != nil || l.OutputSchema != nil {
+		output = &exttypes.OutputConfig{Example: l.Output, Schema: l.OutputSchema}
+	}
+
+	extension, err := bazaar.DeclareDiscoveryExtension(method, l.Input, l.InputSchema, exttypes.BodyType(l.BodyType), output)
+	if err != nil {
+		return extension, fmt.Errorf("bazaar listing: %w", err)
+	}
+	if result := bazaar.ValidateDiscoveryExtension(extension); !result.Valid {
+		return extension, fmt.Errorf("bazaar listing: example input doesn't match its schema: %s", strings.Join(result.Errors, "; "))
+	}
+	return extension, nil
+}
+
+// ListOnBazaar returns route with listing's discovery extension, description, and media type,
+// for the route pattern
+func ListOnBazaar(pattern string, route x402http.RouteConfig, listing BazaarListing) (x402http.RouteConfig, error) {
+	extension, err := listing.Extension(ParseRoutePattern(pattern).method)
+	if err != nil {
+		return route, fmt.Errorf("route %q: %w", pattern, err)
+	}
+
+	extensions := make(map[string]interface{}, len(route.Extensions)+1)
+	for key, value := range route.Extensions {
+		extensions[key] = value
+	}
+	extensions[exttypes.BAZAAR] = extension
+	route.Extensions = extensions
+
+	switch {
+	case listing.Title != "" && listing.Description != "":
+		route.Description = listing.Title + ": " + listing.Description
+	case listing.Title != "":
+		route.Description = listing.Title
+	case listing.Description != "":
+		route.Description = listing.Description
+	}
+	if listing.MediaType != "" {
+		route.MimeType = listing.MediaType
+	}
+	return route, nil
+}
@@ -0,0 +1,61 @@
+// Package kafka publishes payment events to Kafka for xtended402.EventPublisher:
+//
+//	publisher := xtended402.NewEventPublisher(kafka.New("broker-1:9092", "broker-2:9092"))
+//
+// Messages are keyed by payer, so one payer's events land on one partition in order, and
+// carry the event type and ID as headers.
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Broker writes events with a kafka-go Writer, waiting for all in-sync replicas to
+// acknowledge each message
+type Broker struct {
+	writer *kafkago.Writer
+}
+
+// New creates a Broker for the given bootstrap servers
+func New(addrs ...string) *Broker {
+	return NewFromWriter(&kafkago.Writer{
+		Addr:         kafkago.TCP(addrs...),
+		Balancer:     &kafkago.Hash{},
+		RequiredAcks: kafkago.RequireAll,
+	})
+}
+
+// NewFromWriter creates a Broker using a configured Writer, e.g. with TLS or SASL transport.
+// The Writer must be synchronous and must not set Topic, since topics come from the
+// EventPublisher.
+func NewFromWriter(writer *kafkago.Writer) *Broker {
+	return &Broker{writer: writer}
+}
+
+// Publish writes msg and waits for the acknowledgement
+func (b *Broker) Publish(ctx context.Context, msg xtended402.BrokerMessage) error {
+	headers := make([]kafkago.Header, 0, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers = append(headers, kafkago.Header{Key: key, Value: []byte(value)})
+	}
+
+	err := b.writer.WriteMessages(ctx, kafkago.Message{
+		Topic:   msg.Topic,
+		Key:     []byte(msg.Key),
+		Value:   msg.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the writer
+func (b *Broker) Close() error {
+	return b.writer.Close()
+}
@@ -0,0 +1,67 @@
+// Package nats publishes payment events to NATS JetStream for xtended402.EventPublisher:
+//
+//	broker, err := nats.Connect("nats://localhost:4222")
+//	publisher := xtended402.NewEventPublisher(broker)
+//
+// A stream must capture the publisher's subjects ("x402.>" by default). Each message carries
+// the event ID as Nats-Msg-Id, so JetStream drops redeliveries within the stream's duplicate
+// window.
+package nats
+
+import (
+	"context"
+	"fmt"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Broker publishes events to JetStream, waiting for the stream's acknowledgement
+type Broker struct {
+	js   jetstream.JetStream
+	conn *natsgo.Conn // owned connection, closed by Close
+}
+
+// New creates a Broker using an existing JetStream context
+func New(js jetstream.JetStream) *Broker {
+	return &Broker{js: js}
+}
+
+// Connect connects to the NATS server at url and creates a Broker that owns the connection
+func Connect(url string, opts ...natsgo.Option) (*Broker, error) {
+	conn, err := natsgo.Connect(url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+
+	return &Broker{js: js, conn: conn}, nil
+}
+
+// Publish publishes msg and waits for the JetStream acknowledgement
+func (b *Broker) Publish(ctx context.Context, msg xtended402.BrokerMessage) error {
+	natsMsg := natsgo.NewMsg(msg.Topic)
+	natsMsg.Data = msg.Value
+	for key, value := range msg.Headers {
+		natsMsg.Header.Set(key, value)
+	}
+
+	if _, err := b.js.PublishMsg(ctx, natsMsg, jetstream.WithMsgID(msg.ID)); err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	return nil
+}
+
+// Close drains and closes the connection if the Broker opened it
+func (b *Broker) Close() error {
+	if b.conn == nil {
+		return nil
+	}
+	return b.conn.Drain()
+}
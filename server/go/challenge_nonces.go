@@ -0,0 +1,142 @@
+package xtended402
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// ChallengeNonceExtraKey is the payment requirements extra field carrying a challenge nonce.
+// Clients sign it as their ERC-3009 authorization nonce.
+const ChallengeNonceExtraKey = "challengeNonce"
+
+// ErrChallengeNonceInvalid is returned when a payment's authorization nonce wasn't issued for
+// the resource it pays for, has expired, or was already used
+var ErrChallengeNonceInvalid = errors.New("challenge nonce invalid")
+
+type challengeNonce struct {
+	resource  string
+	expiresAt time.Time
+}
+
+// ChallengeNonces issues a nonce with each 402 challenge that the payment must be signed with.
+// The nonce is bound to the resource it was issued for and used once, so a signed
+// authorization can't be replayed against another endpoint with the same price. It's safe for
+// concurrent use.
+//
+// Only EVM payments sign a nonce; payments on other networks aren't checked. Nonces are held in
+// memory, so run one replica or route a client's requests to the same one.
+type ChallengeNonces struct {
+	clock Clock
+	ttl   time.Duration
+
+	mu     sync.Mutex
+	nonces map[string]challengeNonce
+}
+
+// ChallengeNonceOption configures ChallengeNonces
+type ChallengeNonceOption func(*ChallengeNonces)
+
+// WithChallengeNonceTTL sets how long a nonce can be paid with after its challenge (default 5
+// minutes)
+func WithChallengeNonceTTL(ttl time.Duration) ChallengeNonceOption {
+	return func(n *ChallengeNonces) {
+		n.ttl = ttl
+	}
+}
+
+// WithChallengeNonceClock sets the clock used for expiry (defaults to the system clock)
+func WithChallengeNonceClock(clock Clock) ChallengeNonceOption {
+	return func(n *ChallengeNonces) {
+		n.clock = clock
+	}
+}
+
+// NewChallengeNonces creates an empty ChallengeNonces
+func NewChallengeNonces(opts ...ChallengeNonceOption) *ChallengeNonces {
+	n := &ChallengeNonces{
+		ttl:    5 * time.Minute,
+		nonces: make(map[string]challengeNonce),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	n.clock = ClockOrSystem(n.clock)
+	return n
+}
+
+// Issue returns a new nonce for a challenge for resource ("METHOD /path"), as 0x-prefixed
+// bytes32 hex
+func (n *ChallengeNonces) Issue(resource string) (string, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to create challenge nonce: %w", err)
+	}
+	nonce := "0x" + hex.EncodeToString(id)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := n.clock.Now()
+	n.sweepLocked(now)
+	n.nonces[nonce] = challengeNonce{resource: resource, expiresAt: now.Add(n.ttl)}
+	return nonce, nil
+}
+
+// Redeem checks that nonce was issued for resource and uses it up
+func (n *ChallengeNonces) Redeem(nonce, resource string) error {
+	nonce = strings.ToLower(nonce)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	issued, ok := n.nonces[nonce]
+	switch {
+	case nonce == "":
+		return fmt.Errorf("%w: the payment has no authorization nonce", ErrChallengeNonceInvalid)
+	case !ok:
+		return fmt.Errorf("%w: not issued, or already used", ErrChallengeNonceInvalid)
+	case issued.resource != resource:
+		// Left for the resource it was issued for
+		return fmt.Errorf("%w: issued for another resource", ErrChallengeNonceInvalid)
+	}
+	delete(n.nonces, nonce)
+	if n.clock.Now().After(issued.expiresAt) {
+		return fmt.Errorf("%w: expired", ErrChallengeNonceInvalid)
+	}
+	return nil
+}
+
+// sweepLocked drops expired nonces
+func (n *ChallengeNonces) sweepLocked(now time.Time) {
+	for nonce, issued := range n.nonces {
+		if now.After(issued.expiresAt) {
+			delete(n.nonces, nonce)
+		}
+	}
+}
+
+// WithChallengeNonce returns a copy of requirements asking for nonce to be signed. Only EVM
+// requirements are changed.
+func WithChallengeNonce(requirements x402types.PaymentRequirements, nonce string) x402types.PaymentRequirements {
+	if nonce == "" || !strings.HasPrefix(requirements.Network, "eip155:") {
+		return requirements
+	}
+	return WithExtras(requirements, map[string]interface{}{ChallengeNonceExtraKey: nonce})
+}
+
+// AuthorizationNonce returns the nonce of an EVM payment's ERC-3009 authorization, or ""
+func AuthorizationNonce(payload *x402types.PaymentPayload) string {
+	if payload == nil {
+		return ""
+	}
+	authorization, _ := payload.Payload["authorization"].(map[string]interface{})
+	nonce, _ := authorization["nonce"].(string)
+	return nonce
+}
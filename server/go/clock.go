@@ -0,0 +1,109 @@
+package xtended402
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock abstracts time for expiry, cooldown, and timeout logic so tests can advance time
+// deterministically instead of sleeping. See testing/clocktest for a fake implementation.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// After returns a channel that receives the current time once d has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the real wall clock
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// ClockOrSystem returns clock, or SystemClock if clock is nil
+func ClockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
+
+// ContextWithTimeout is context.WithTimeout measured on clock. The returned context reports
+// context.DeadlineExceeded once clock has advanced by timeout.
+func ContextWithTimeout(ctx context.Context, clock Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	clock = ClockOrSystem(clock)
+	if _, ok := clock.(systemClock); ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	c := &clockContext{
+		Context:  ctx,
+		deadline: clock.Now().Add(timeout),
+		done:     make(chan struct{}),
+	}
+	if parentDeadline, ok := ctx.Deadline(); ok && parentDeadline.Before(c.deadline) {
+		c.deadline = parentDeadline
+	}
+
+	stop := make(chan struct{})
+	expired := clock.After(timeout)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.finish(ctx.Err())
+		case <-expired:
+			c.finish(context.DeadlineExceeded)
+		case <-stop:
+		}
+	}()
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			close(stop)
+			c.finish(context.Canceled)
+		})
+	}
+}
+
+// clockContext is a context whose deadline is driven by a Clock
+type clockContext struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *clockContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *clockContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *clockContext) finish(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"sort"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	evmserver "github.com/coinbase/x402/go/mechanisms/evm/exact/server"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	ginmw "github.com/mvpoyatt/xtended402/server/go/http/gin"
+)
+
+// RoutesConfig builds the x402 routes
+func (c *Config) RoutesConfig() x402http.RoutesConfig {
+	routes := make(x402http.RoutesConfig, len(c.Routes))
+	for pattern, route := range c.Routes {
+		accepts := make(x402http.PaymentOptions, 0, len(route.Accepts))
+		for _, option := range route.Accepts {
+			accepts = append(accepts, x402http.PaymentOption{
+				Scheme:            option.Scheme,
+				Network:           x402.Network(option.Network),
+				Price:             option.Price,
+				PayTo:             option.PayTo,
+				MaxTimeoutSeconds: option.MaxTimeoutSeconds,
+			})
+		}
+
+		built := x402http.RouteConfig{
+			Accepts:     accepts,
+			Resource:    route.Resource,
+			Description: route.Description,
+			MimeType:    route.MimeType,
+		}
+		if len(route.Scopes) > 0 {
+			built = xtended402.ScopedRoute(built, route.Scopes...)
+		}
+		if len(route.SettleHooks) > 0 {
+			built = xtended402.RouteWithSettleHooks(built, route.SettleHooks...)
+		}
+		routes[pattern] = built
+	}
+	return routes
+}
+
+// FacilitatorClients builds the facilitator clients
+func (c *Config) FacilitatorClients() ([]x402.FacilitatorClient, error) {
+	clients := make([]x402.FacilitatorClient, 0, len(c.Facilitators))
+	for _, facilitator := range c.Facilitators {
+		if facilitator.CDP != nil {
+			client, err := xtended402.NewCDPFacilitatorClient(facilitator.CDP.APIKeyID, facilitator.CDP.APIKeySecret)
+			if err != nil {
+				return nil, err
+			}
+			clients = append(clients, client)
+			continue
+		}
+		clients = append(clients, x402http.NewHTTPFacilitatorClient(&x402http.FacilitatorConfig{URL: facilitator.URL}))
+	}
+	return clients, nil
+}
+
+// SchemeRegistrations builds the scheme servers: those listed under schemes, or the exact
+// scheme on every network the routes accept
+func (c *Config) SchemeRegistrations() []ginmw.SchemeRegistration {
+	schemes := c.Schemes
+	if len(schemes) == 0 {
+		networks := make(map[string]bool)
+		for _, route := range c.Routes {
+			for _, option := range route.Accepts {
+				networks[option.Network] = true
+			}
+		}
+		for network := range networks {
+			schemes = append(schemes, Scheme{Scheme: "exact", Network: network})
+		}
+		sort.Slice(schemes, func(i, j int) bool { return schemes[i].Network < schemes[j].Network })
+	}
+
+	registrations := make([]ginmw.SchemeRegistration, 0, len(schemes))
+	for _, scheme := range schemes {
+		registrations = append(registrations, ginmw.SchemeRegistration{
+			Network: x402.Network(scheme.Network),
+			Server:  evmserver.NewExactEvmScheme(),
+		})
+	}
+	return registrations
+}
+
+// MiddlewareOptions builds the middleware options for ginmw.NewMiddlewareFromConfig: the
+// facilitators, schemes, and middleware settings
+func (c *Config) MiddlewareOptions() ([]ginmw.MiddlewareOption, error) {
+	clients, err := c.FacilitatorClients()
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []ginmw.MiddlewareOption
+	for _, client := range clients {
+		opts = append(opts, ginmw.WithFacilitatorClient(client))
+	}
+	for _, scheme := range c.SchemeRegistrations() {
+		opts = append(opts, ginmw.WithScheme(scheme.Network, scheme.Server))
+	}
+
+	m := c.Middleware
+	if m.SettlementTiming != "" {
+		opts = append(opts, ginmw.WithSettlementTiming(m.SettlementTiming))
+	}
+	if m.SyncFacilitatorOnStart != nil {
+		opts = append(opts, ginmw.WithSyncFacilitatorOnStart(*m.SyncFacilitatorOnStart))
+	}
+	if m.InitFailurePolicy != "" {
+		opts = append(opts, ginmw.WithInitFailurePolicy(ginmw.InitFailurePolicy(m.InitFailurePolicy)))
+	}
+	if m.Bazaar {
+		opts = append(opts, ginmw.WithBazaar(true))
+	}
+	if len(m.Exempt) > 0 {
+		opts = append(opts, ginmw.WithExemptions(m.Exempt...))
+	}
+	for _, key := range m.Extensions {
+		// Validate has already checked the keys
+		if extension, ok := lookupExtension(key); ok {
+			opts = append(opts, ginmw.WithResourceServerExtension(extension))
+		}
+	}
+
+	// Validate has already checked the durations
+	if d := mustDuration(m.Timeout); d > 0 {
+		opts = append(opts, ginmw.WithTimeout(d))
+	}
+	if d := mustDuration(m.VerifyTimeout); d > 0 {
+		opts = append(opts, ginmw.WithVerifyTimeout(d))
+	}
+	if d := mustDuration(m.SettleTimeout); d > 0 {
+		opts = append(opts, ginmw.WithSettleTimeout(d))
+	}
+	if interval := mustDuration(m.RefreshInterval); interval > 0 {
+		opts = append(opts, ginmw.WithCapabilityRefresh(interval, mustDuration(m.RefreshJitter)))
+	}
+
+	return opts, nil
+}
+
+func mustDuration(value string) time.Duration {
+	d, _ := parseDuration(value)
+	return d
+}
@@ -0,0 +1,215 @@
+// Package config builds the payment middleware from a declarative YAML, TOML, or JSON file
+// instead of Go wiring. The file declares facilitators, schemes, middleware settings, and
+// routes; ${VAR} references are replaced from the environment:
+//
+//	facilitators:
+//	  - url: https://x402.org/facilitator
+//	middleware:
+//	  settlementTiming: before
+//	  settleTimeout: 60s
+//	routes:
+//	  "POST /api/purchase":
+//	    description: Purchase
+//	    accepts:
+//	      - scheme: exact
+//	        network: eip155:8453
+//	        price: "$0.01"
+//	        payTo: ${PAY_TO}
+//
+// Load the file and pass its options to the middleware, adding any Go-only options:
+//
+//	cfg, err := config.LoadFile("payments.yaml")
+//	opts, err := cfg.MiddlewareOptions()
+//	m, err := ginmw.NewMiddlewareFromConfig(cfg.RoutesConfig(), append(opts, ginmw.WithEventHandler(h))...)
+//
+// Every problem in the file is reported at once, with its location, before anything is built.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Format is a configuration file format
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+	FormatJSON Format = "json"
+)
+
+// Config is a declarative payment configuration
+type Config struct {
+	Facilitators []Facilitator    `json:"facilitators"`
+	Schemes      []Scheme         `json:"schemes"`
+	Middleware   Middleware       `json:"middleware"`
+	Routes       map[string]Route `json:"routes"`
+}
+
+// Facilitator is a facilitator client: an HTTP facilitator by URL, or the CDP facilitator
+type Facilitator struct {
+	URL string `json:"url,omitempty"`
+	CDP *CDP   `json:"cdp,omitempty"`
+}
+
+// CDP holds Coinbase Developer Platform API credentials
+type CDP struct {
+	APIKeyID     string `json:"apiKeyId"`
+	APIKeySecret string `json:"apiKeySecret"`
+}
+
+// Scheme registers a payment scheme on a network. Without any, the exact scheme is registered
+// on every network the routes accept.
+type Scheme struct {
+	Scheme  string `json:"scheme"`
+	Network string `json:"network"`
+}
+
+// Middleware holds middleware settings. Durations are Go duration strings such as "30s".
+type Middleware struct {
+	SettlementTiming       string `json:"settlementTiming,omitempty"`
+	Timeout                string `json:"timeout,omitempty"`
+	VerifyTimeout          string `json:"verifyTimeout,omitempty"`
+	SettleTimeout          string `json:"settleTimeout,omitempty"`
+	SyncFacilitatorOnStart *bool  `json:"syncFacilitatorOnStart,omitempty"`
+	InitFailurePolicy      string `json:"initFailurePolicy,omitempty"`
+	RefreshInterval        string `json:"refreshInterval,omitempty"`
+	RefreshJitter          string `json:"refreshJitter,omitempty"`
+	Bazaar                 bool   `json:"bazaar,omitempty"`
+
+	// Extensions are resource server extensions to enable, by key (see RegisterExtension)
+	Extensions []string `json:"extensions,omitempty"`
+
+	// Exempt are route patterns served without payment even where a paid route matches, e.g.
+	// "GET /api/health" under "/api/*"
+	Exempt []string `json:"exempt,omitempty"`
+}
+
+// Route is a paid route
+type Route struct {
+	Description string          `json:"description,omitempty"`
+	MimeType    string          `json:"mimeType,omitempty"`
+	Resource    string          `json:"resource,omitempty"`
+	Scopes      []string        `json:"scopes,omitempty"`
+	Accepts     []PaymentOption `json:"accepts"`
+
+	// SettleHooks name the settlement hooks the route runs, registered in Go with
+	// ginmw.WithNamedBeforeSettleHook and WithNamedAfterSettleHook
+	SettleHooks []string `json:"settleHooks,omitempty"`
+}
+
+// PaymentOption is one way to pay for a route. Price is a money string ("$0.01"), a number
+// of dollars, or a token amount ({amount: "10000", asset: "0x..."}).
+type PaymentOption struct {
+	Scheme            string      `json:"scheme"`
+	Network           string      `json:"network"`
+	Price             interface{} `json:"price"`
+	PayTo             string      `json:"payTo"`
+	MaxTimeoutSeconds int         `json:"maxTimeoutSeconds,omitempty"`
+}
+
+// LoadFile reads, parses, and validates a configuration file. The format comes from the
+// extension: .yaml, .yml, .toml, or .json.
+func LoadFile(path string) (*Config, error) {
+	format, err := formatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse expands ${VAR} references, decodes data, and validates the result. Unknown keys are
+// errors, so typos don't silently drop settings.
+func Parse(data []byte, format Format) (*Config, error) {
+	data, err := expandEnv(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	switch format {
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &generic)
+	case FormatTOML:
+		err = toml.Unmarshal(data, &generic)
+	case FormatJSON:
+		err = json.Unmarshal(data, &generic)
+	default:
+		return nil, fmt.Errorf("unsupported configuration format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", format, err)
+	}
+
+	// Decode all formats through JSON so one set of field names and strictness applies
+	normalized, err := json.Marshal(generic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", format, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+
+	cfg := &Config{}
+	if err := decoder.Decode(cfg); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			field := strings.NewReplacer("~1", "/", "~0", "~").Replace(typeErr.Field)
+			return nil, fmt.Errorf("invalid payment configuration: %s: expected %s, got %s", field, typeErr.Type, typeErr.Value)
+		}
+		return nil, fmt.Errorf("invalid payment configuration: %s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func formatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	case ".json":
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("%s: unknown configuration format (use .yaml, .yml, .toml, or .json)", path)
+}
+
+// envReference matches ${VAR}. Bare $VAR is left alone so prices like "$0.01" survive.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} references, failing on unset variables
+func expandEnv(data []byte) ([]byte, error) {
+	var missing []string
+	expanded := envReference.ReplaceAllFunc(data, func(match []byte) []byte {
+		name := string(envReference.FindSubmatch(match)[1])
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return []byte(value)
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
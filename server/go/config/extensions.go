@@ -0,0 +1,45 @@
+package config
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/coinbase/x402/go/extensions/bazaar"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// extensions are the resource server extensions files can enable by key
+var (
+	extensionsMu sync.RWMutex
+	extensions   = map[string]x402types.ResourceServerExtension{
+		bazaar.BazaarResourceServerExtension.Key(): bazaar.BazaarResourceServerExtension,
+	}
+)
+
+// RegisterExtension lets configuration files enable extension by its key under
+// middleware.extensions. Bazaar is registered already. Call it before loading a file, e.g.
+// from an init function.
+func RegisterExtension(extension x402types.ResourceServerExtension) {
+	extensionsMu.Lock()
+	defer extensionsMu.Unlock()
+	extensions[extension.Key()] = extension
+}
+
+func lookupExtension(key string) (x402types.ResourceServerExtension, bool) {
+	extensionsMu.RLock()
+	defer extensionsMu.RUnlock()
+	extension, ok := extensions[key]
+	return extension, ok
+}
+
+// extensionKeys lists the registered extension keys, for error messages
+func extensionKeys() []string {
+	extensionsMu.RLock()
+	defer extensionsMu.RUnlock()
+	keys := make([]string, 0, len(extensions))
+	for key := range extensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
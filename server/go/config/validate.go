@@ -0,0 +1,276 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid payment configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// problems collects validation failures
+type problems []string
+
+func (p *problems) add(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+// Known values
+var (
+	schemes             = map[string]bool{"exact": true}
+	namespaces          = map[string]bool{"eip155": true}
+	settlementTimings   = map[string]bool{"": true, "before": true, "after": true}
+	initFailurePolicies = map[string]bool{"": true, "warn": true, "fail-fast": true, "retry": true}
+	httpMethods         = map[string]bool{"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true, "*": true}
+
+	networkFormat = regexp.MustCompile(`^[a-z0-9-]{3,8}:[A-Za-z0-9_*-]{1,32}$`)
+	integerAmount = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// Validate checks the configuration and returns a *ValidationError listing every problem
+func (c *Config) Validate() error {
+	var p problems
+
+	if len(c.Facilitators) == 0 {
+		p.add("facilitators: at least one facilitator is required")
+	}
+	for i, facilitator := range c.Facilitators {
+		c.validateFacilitator(&p, fmt.Sprintf("facilitators[%d]", i), facilitator)
+	}
+
+	for i, scheme := range c.Schemes {
+		where := fmt.Sprintf("schemes[%d]", i)
+		validateScheme(&p, where, scheme.Scheme)
+		validateNetwork(&p, where, scheme.Network)
+	}
+
+	c.validateMiddleware(&p)
+
+	if len(c.Routes) == 0 {
+		p.add("routes: at least one route is required")
+	}
+	patterns := make([]string, 0, len(c.Routes))
+	for pattern := range c.Routes {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		c.validateRoute(&p, pattern, c.Routes[pattern])
+	}
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+	return nil
+}
+
+func (c *Config) validateFacilitator(p *problems, where string, facilitator Facilitator) {
+	switch {
+	case facilitator.URL == "" && facilitator.CDP == nil:
+		p.add("%s: set url or cdp", where)
+	case facilitator.URL != "" && facilitator.CDP != nil:
+		p.add("%s: set only one of url and cdp", where)
+	case facilitator.CDP != nil:
+		if facilitator.CDP.APIKeyID == "" || facilitator.CDP.APIKeySecret == "" {
+			p.add("%s.cdp: apiKeyId and apiKeySecret are required", where)
+		}
+	default:
+		u, err := url.Parse(facilitator.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.add("%s.url: %q is not an http(s) URL", where, facilitator.URL)
+		}
+	}
+}
+
+func (c *Config) validateMiddleware(p *problems) {
+	m := c.Middleware
+	if !settlementTimings[m.SettlementTiming] {
+		p.add("middleware.settlementTiming: %q is not \"before\" or \"after\"", m.SettlementTiming)
+	}
+	if !initFailurePolicies[m.InitFailurePolicy] {
+		p.add("middleware.initFailurePolicy: %q is not \"warn\", \"fail-fast\", or \"retry\"", m.InitFailurePolicy)
+	}
+	for _, duration := range []struct{ name, value string }{
+		{"timeout", m.Timeout},
+		{"verifyTimeout", m.VerifyTimeout},
+		{"settleTimeout", m.SettleTimeout},
+		{"refreshInterval", m.RefreshInterval},
+		{"refreshJitter", m.RefreshJitter},
+	} {
+		if _, err := parseDuration(duration.value); err != nil {
+			p.add("middleware.%s: %v", duration.name, err)
+		}
+	}
+	for i, key := range m.Extensions {
+		if _, ok := lookupExtension(key); !ok {
+			p.add("middleware.extensions[%d]: unknown extension %q (registered: %s)", i, key, strings.Join(extensionKeys(), ", "))
+		}
+	}
+	for i, pattern := range m.Exempt {
+		if err := xtended402.CheckRoutePattern(pattern); err != nil {
+			p.add("middleware.exempt[%d]: %v", i, err)
+		} else if method := xtended402.ParseRoutePattern(pattern).Method(); !httpMethods[method] {
+			p.add("middleware.exempt[%d]: unknown HTTP method %q", i, method)
+		}
+	}
+}
+
+func (c *Config) validateRoute(p *problems, pattern string, route Route) {
+	where := fmt.Sprintf("routes[%q]", pattern)
+
+	parsed := xtended402.ParseRoutePattern(pattern)
+	if !httpMethods[parsed.Method()] {
+		p.add("%s: unknown HTTP method %q", where, parsed.Method())
+	}
+	if err := xtended402.CheckRoutePattern(pattern); err != nil {
+		p.add("%s: %v", where, err)
+	}
+
+	if len(route.Accepts) == 0 {
+		p.add("%s.accepts: at least one payment option is required", where)
+	}
+	for i, option := range route.Accepts {
+		at := fmt.Sprintf("%s.accepts[%d]", where, i)
+		validateScheme(p, at, option.Scheme)
+		if validateNetwork(p, at, option.Network) {
+			validatePayTo(p, at, option.PayTo)
+			validatePrice(p, at, option.Price)
+			if option.Scheme != "" && len(c.Schemes) > 0 && !c.registers(option.Scheme, option.Network) {
+				p.add("%s: no %s scheme is registered for %s; add it under schemes", at, option.Scheme, option.Network)
+			}
+		}
+		if option.MaxTimeoutSeconds < 0 {
+			p.add("%s.maxTimeoutSeconds: must not be negative", at)
+		}
+	}
+}
+
+// registers reports whether the configured schemes cover scheme on network. A network of
+// "eip155:*" covers every EVM network.
+func (c *Config) registers(scheme, network string) bool {
+	for _, registered := range c.Schemes {
+		if registered.Scheme != scheme {
+			continue
+		}
+		if registered.Network == network {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(registered.Network, "*"); ok && strings.HasPrefix(network, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateScheme(p *problems, where, scheme string) {
+	switch {
+	case scheme == "":
+		p.add("%s.scheme: required", where)
+	case !schemes[scheme]:
+		p.add("%s.scheme: unsupported scheme %q (supported: exact)", where, scheme)
+	}
+}
+
+// validateNetwork checks a CAIP-2 network and reports whether it is usable
+func validateNetwork(p *problems, where, network string) bool {
+	switch {
+	case network == "":
+		p.add("%s.network: required", where)
+		return false
+	case !networkFormat.MatchString(network):
+		p.add("%s.network: %q is not a CAIP-2 network such as \"eip155:8453\"", where, network)
+		return false
+	case !namespaces[namespaceOf(network)]:
+		p.add("%s.network: unsupported network family %q (supported: eip155)", where, namespaceOf(network))
+		return false
+	}
+	return true
+}
+
+func validatePayTo(p *problems, where, payTo string) {
+	switch {
+	case payTo == "":
+		p.add("%s.payTo: required", where)
+	case !common.IsHexAddress(payTo):
+		p.add("%s.payTo: %q is not an EVM address (0x followed by 40 hex digits)", where, payTo)
+	}
+}
+
+func validatePrice(p *problems, where string, price interface{}) {
+	switch v := price.(type) {
+	case nil:
+		p.add("%s.price: required", where)
+	case string:
+		amount, err := strconv.ParseFloat(moneyDigits(v), 64)
+		if err != nil || amount <= 0 {
+			p.add("%s.price: %q is not a positive money amount such as \"$0.01\"", where, v)
+		}
+	case float64:
+		if v <= 0 {
+			p.add("%s.price: must be positive", where)
+		}
+	case map[string]interface{}:
+		amount, _ := v["amount"].(string)
+		asset, _ := v["asset"].(string)
+		if !integerAmount.MatchString(amount) || strings.Trim(amount, "0") == "" {
+			p.add("%s.price.amount: must be a positive integer string in the token's smallest units", where)
+		}
+		switch {
+		case asset == "":
+			p.add("%s.price.asset: required with a token amount", where)
+		case !common.IsHexAddress(asset):
+			p.add("%s.price.asset: %q is not a token address", where, asset)
+		}
+		for key := range v {
+			if key != "amount" && key != "asset" && key != "extra" {
+				p.add("%s.price: unknown field %q", where, key)
+			}
+		}
+	default:
+		p.add("%s.price: must be a money string, a number, or {amount, asset}", where)
+	}
+}
+
+// moneyDigits strips the currency decorations x402 accepts around money strings
+func moneyDigits(price string) string {
+	price = strings.TrimSpace(price)
+	price = strings.TrimPrefix(price, "$")
+	price = strings.TrimSuffix(price, " USD")
+	price = strings.TrimSuffix(price, " USDC")
+	return strings.TrimSpace(price)
+}
+
+func namespaceOf(network string) string {
+	namespace, _, _ := strings.Cut(network, ":")
+	return namespace
+}
+
+// parseDuration parses an optional, non-negative duration
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration such as \"30s\"", value)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%q must not be negative", value)
+	}
+	return d, nil
+}
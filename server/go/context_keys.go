@@ -0,0 +1,48 @@
+package xtended402
+
+import (
+	"context"
+)
+
+// ContextKey is a typed request context key. Keys are compared by identity, so values set under
+// one never collide with another key's, even one with the same name, and read back as T.
+type ContextKey[T any] struct {
+	name string
+}
+
+// NewContextKey creates a context key for values of type T. name only describes it, e.g. in
+// errors.
+//
+//	var CustomerEmailKey = xtended402.NewContextKey[string]("customerEmail")
+func NewContextKey[T any](name string) *ContextKey[T] {
+	return &ContextKey[T]{name: name}
+}
+
+// PriceKey is where pricing steps put the price ContextPrice reads, e.g. "$4.50"
+var PriceKey = NewContextKey[string]("price")
+
+// String returns the key's name
+func (k *ContextKey[T]) String() string {
+	return k.name
+}
+
+// WithValue returns a copy of ctx carrying value under the key
+func (k *ContextKey[T]) WithValue(ctx context.Context, value T) context.Context {
+	return context.WithValue(ctx, k, value)
+}
+
+// Value returns the value ctx carries under the key, and whether it carries one
+func (k *ContextKey[T]) Value(ctx context.Context) (T, bool) {
+	value, ok := ctx.Value(k).(T)
+	return value, ok
+}
+
+// Bind returns a ContextValue setting value under the key
+func (k *ContextKey[T]) Bind(value T) ContextValue {
+	return func(ctx context.Context) context.Context {
+		return k.WithValue(ctx, value)
+	}
+}
+
+// ContextValue sets a value on a context, e.g. PriceKey.Bind("$5.00")
+type ContextValue func(ctx context.Context) context.Context
@@ -0,0 +1,380 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dead letter sources
+const (
+	DeadLetterWebhook   = "webhook"
+	DeadLetterPublisher = "publisher"
+)
+
+// ErrDeadLetterNotFound is returned for unknown dead letter IDs
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is an event delivery that failed after all retries
+type DeadLetter struct {
+	ID string `json:"id"`
+
+	// Source is the component that failed to deliver (DeadLetterWebhook or DeadLetterPublisher)
+	Source string `json:"source"`
+
+	// Destination is the webhook URL or broker topic
+	Destination string `json:"destination"`
+
+	Event PaymentEvent `json:"event"`
+
+	// Error is the last delivery error
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failedAt"`
+
+	// Replays counts failed replay attempts
+	Replays int `json:"replays,omitempty"`
+}
+
+// DeadLetterQueue keeps failed deliveries until they are replayed or discarded
+type DeadLetterQueue interface {
+	AddDeadLetter(ctx context.Context, letter *DeadLetter) error
+	GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error)
+
+	// ListDeadLetters returns all dead letters, oldest first
+	ListDeadLetters(ctx context.Context) ([]*DeadLetter, error)
+
+	RemoveDeadLetter(ctx context.Context, id string) error
+}
+
+// newDeadLetter creates a dead letter for a failed delivery
+func newDeadLetter(clock Clock, source, destination string, event PaymentEvent, err error) *DeadLetter {
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+	return &DeadLetter{
+		ID:          "dlq_" + hex.EncodeToString(id),
+		Source:      source,
+		Destination: destination,
+		Event:       event,
+		Error:       err.Error(),
+		FailedAt:    ClockOrSystem(clock).Now().UTC(),
+	}
+}
+
+// ============================================================================
+// Replay
+// ============================================================================
+
+// Redeliverer delivers a dead letter again. WebhookDispatcher and EventPublisher implement it.
+type Redeliverer interface {
+	// DeadLetterSource is the Source of the dead letters this redeliverer handles
+	DeadLetterSource() string
+
+	Redeliver(ctx context.Context, letter *DeadLetter) error
+}
+
+// DeadLetterReplayer replays dead letters through the component that failed to deliver them.
+// Successful replays remove the letter from the queue; failed ones keep it with the new error.
+type DeadLetterReplayer struct {
+	queue       DeadLetterQueue
+	redeliverer map[string]Redeliverer
+}
+
+// NewDeadLetterReplayer creates a DeadLetterReplayer for queue
+func NewDeadLetterReplayer(queue DeadLetterQueue, redeliverers ...Redeliverer) *DeadLetterReplayer {
+	r := &DeadLetterReplayer{
+		queue:       queue,
+		redeliverer: make(map[string]Redeliverer, len(redeliverers)),
+	}
+	for _, redeliverer := range redeliverers {
+		r.redeliverer[redeliverer.DeadLetterSource()] = redeliverer
+	}
+	return r
+}
+
+// Queue returns the queue r replays from
+func (r *DeadLetterReplayer) Queue() DeadLetterQueue {
+	return r.queue
+}
+
+// Replay redelivers the dead letter with id
+func (r *DeadLetterReplayer) Replay(ctx context.Context, id string) error {
+	letter, err := r.queue.GetDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+	return r.replay(ctx, letter)
+}
+
+// ReplayAll redelivers every dead letter, returning how many succeeded and the first error
+func (r *DeadLetterReplayer) ReplayAll(ctx context.Context) (int, error) {
+	letters, err := r.queue.ListDeadLetters(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	var firstErr error
+	for _, letter := range letters {
+		if err := r.replay(ctx, letter); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		replayed++
+	}
+	return replayed, firstErr
+}
+
+func (r *DeadLetterReplayer) replay(ctx context.Context, letter *DeadLetter) error {
+	redeliverer, ok := r.redeliverer[letter.Source]
+	if !ok {
+		return fmt.Errorf("no redeliverer for %s dead letters", letter.Source)
+	}
+
+	if err := redeliverer.Redeliver(ctx, letter); err != nil {
+		letter.Error = err.Error()
+		letter.Replays++
+		if saveErr := r.queue.AddDeadLetter(ctx, letter); saveErr != nil {
+			return fmt.Errorf("replay failed: %w (and updating the dead letter failed: %v)", err, saveErr)
+		}
+		return fmt.Errorf("replay failed: %w", err)
+	}
+
+	return r.queue.RemoveDeadLetter(ctx, letter.ID)
+}
+
+// Handler serves a JSON API over the queue. Mount it behind authentication:
+//
+//	GET    /                list dead letters
+//	GET    /{id}            show one
+//	POST   /{id}/replay     replay one
+//	POST   /replay          replay all
+//	DELETE /{id}            discard one
+//
+// With Gin: r.Any("/admin/dead-letters/*path", gin.WrapH(http.StripPrefix("/admin/dead-letters", replayer.Handler())))
+func (r *DeadLetterReplayer) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path := strings.Trim(req.URL.Path, "/")
+		parts := strings.Split(path, "/")
+
+		switch {
+		case path == "" && req.Method == http.MethodGet:
+			letters, err := r.queue.ListDeadLetters(req.Context())
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			if letters == nil {
+				letters = []*DeadLetter{}
+			}
+			writeJSON(w, http.StatusOK, letters)
+
+		case path == "replay" && req.Method == http.MethodPost:
+			replayed, err := r.ReplayAll(req.Context())
+			body := map[string]interface{}{"replayed": replayed}
+			if err != nil {
+				body["error"] = err.Error()
+			}
+			writeJSON(w, http.StatusOK, body)
+
+		case len(parts) == 1 && req.Method == http.MethodGet:
+			letter, err := r.queue.GetDeadLetter(req.Context(), parts[0])
+			if err != nil {
+				writeDeadLetterError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, letter)
+
+		case len(parts) == 1 && req.Method == http.MethodDelete:
+			if _, err := r.queue.GetDeadLetter(req.Context(), parts[0]); err != nil {
+				writeDeadLetterError(w, err)
+				return
+			}
+			if err := r.queue.RemoveDeadLetter(req.Context(), parts[0]); err != nil {
+				writeDeadLetterError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		case len(parts) == 2 && parts[1] == "replay" && req.Method == http.MethodPost:
+			if err := r.Replay(req.Context(), parts[0]); err != nil {
+				if errors.Is(err, ErrDeadLetterNotFound) {
+					writeDeadLetterError(w, err)
+					return
+				}
+				writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]string{"replayed": parts[0]})
+
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+	})
+}
+
+func writeDeadLetterError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrDeadLetterNotFound) {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// ============================================================================
+// Queues
+// ============================================================================
+
+// MemoryDeadLetterQueue is an in-process DeadLetterQueue. Dead letters are lost on restart.
+type MemoryDeadLetterQueue struct {
+	mu      sync.Mutex
+	letters map[string]*DeadLetter
+}
+
+// NewMemoryDeadLetterQueue creates an empty MemoryDeadLetterQueue
+func NewMemoryDeadLetterQueue() *MemoryDeadLetterQueue {
+	return &MemoryDeadLetterQueue{letters: make(map[string]*DeadLetter)}
+}
+
+// AddDeadLetter stores letter, replacing any letter with the same ID
+func (q *MemoryDeadLetterQueue) AddDeadLetter(ctx context.Context, letter *DeadLetter) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stored := *letter
+	q.letters[letter.ID] = &stored
+	return nil
+}
+
+// GetDeadLetter returns the letter with id, or ErrDeadLetterNotFound
+func (q *MemoryDeadLetterQueue) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letter, ok := q.letters[id]
+	if !ok {
+		return nil, ErrDeadLetterNotFound
+	}
+	found := *letter
+	return &found, nil
+}
+
+// ListDeadLetters returns all letters, oldest first
+func (q *MemoryDeadLetterQueue) ListDeadLetters(ctx context.Context) ([]*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letters := make([]*DeadLetter, 0, len(q.letters))
+	for _, letter := range q.letters {
+		found := *letter
+		letters = append(letters, &found)
+	}
+	sortDeadLetters(letters)
+	return letters, nil
+}
+
+// RemoveDeadLetter deletes the letter with id
+func (q *MemoryDeadLetterQueue) RemoveDeadLetter(ctx context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.letters, id)
+	return nil
+}
+
+// FileDeadLetterQueue keeps each dead letter as a JSON file in a directory
+type FileDeadLetterQueue struct {
+	dir string
+}
+
+// NewFileDeadLetterQueue creates a FileDeadLetterQueue in dir, which is created if needed
+func NewFileDeadLetterQueue(dir string) *FileDeadLetterQueue {
+	return &FileDeadLetterQueue{dir: dir}
+}
+
+// AddDeadLetter writes letter to its file
+func (q *FileDeadLetterQueue) AddDeadLetter(ctx context.Context, letter *DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(q.path(letter.ID), data)
+}
+
+// GetDeadLetter reads the letter with id, or returns ErrDeadLetterNotFound
+func (q *FileDeadLetterQueue) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	data, err := os.ReadFile(q.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var letter DeadLetter
+	if err := json.Unmarshal(data, &letter); err != nil {
+		return nil, fmt.Errorf("invalid dead letter file for %s: %w", id, err)
+	}
+	return &letter, nil
+}
+
+// ListDeadLetters reads all letters, oldest first
+func (q *FileDeadLetterQueue) ListDeadLetters(ctx context.Context) ([]*DeadLetter, error) {
+	paths, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]*DeadLetter, 0, len(paths))
+	for _, path := range paths {
+		letter, err := q.GetDeadLetter(ctx, strings.TrimSuffix(filepath.Base(path), ".json"))
+		if errors.Is(err, ErrDeadLetterNotFound) {
+			continue // removed meanwhile
+		}
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	sortDeadLetters(letters)
+	return letters, nil
+}
+
+// RemoveDeadLetter deletes the letter's file
+func (q *FileDeadLetterQueue) RemoveDeadLetter(ctx context.Context, id string) error {
+	err := os.Remove(q.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (q *FileDeadLetterQueue) path(id string) string {
+	return filepath.Join(q.dir, filepath.Base(id)+".json")
+}
+
+func sortDeadLetters(letters []*DeadLetter) {
+	sort.Slice(letters, func(i, j int) bool {
+		if letters[i].FailedAt.Equal(letters[j].FailedAt) {
+			return letters[i].ID < letters[j].ID
+		}
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+}
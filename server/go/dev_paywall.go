@@ -0,0 +1,167 @@
+package xtended402
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	x402 "github.com/coinbase/x402/go"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+var (
+	// ErrNoTestPayer is returned by DevPaywall.Pay without WithTestPayer
+	ErrNoTestPayer = errors.New("no test wallet configured")
+
+	// ErrNoTestnetOption is returned by DevPaywall.Pay when a challenge has no testnet option
+	ErrNoTestnetOption = errors.New("no testnet payment option")
+)
+
+// circleFaucet hands out testnet USDC on most networks Circle supports
+var circleFaucet = PaywallLink{Label: "Circle USDC faucet", URL: "https://faucet.circle.com/"}
+
+// DefaultFaucets lists faucets for test tokens and gas by network
+var DefaultFaucets = map[x402.Network][]PaywallLink{
+	"eip155:84532": { // Base Sepolia
+		circleFaucet,
+		{Label: "Coinbase Developer Platform faucet", URL: "https://portal.cdp.coinbase.com/products/faucet"},
+	},
+	"eip155:11155111": { // Ethereum Sepolia
+		circleFaucet,
+		{Label: "Google Cloud Sepolia ETH faucet", URL: "https://cloud.google.com/application/web3/faucet/ethereum/sepolia"},
+	},
+	"eip155:43113": { // Avalanche Fuji
+		circleFaucet,
+		{Label: "Avalanche Fuji faucet", URL: "https://core.app/tools/testnet-faucet/"},
+	},
+	"eip155:80002": { // Polygon Amoy
+		circleFaucet,
+		{Label: "Polygon Amoy faucet", URL: "https://faucet.polygon.technology/"},
+	},
+	"solana:EtWTRABZaYq6iMfeYKouRu166VU2xqa1": { // Solana devnet
+		circleFaucet,
+		{Label: "Solana devnet faucet", URL: "https://faucet.solana.com/"},
+	},
+}
+
+// testnets are the networks IsTestnet knows beyond DefaultFaucets
+var testnets = map[x402.Network]bool{
+	"eip155:31337": true, // Anvil, Hardhat
+	"eip155:1337":  true, // Ganache, Geth --dev
+	"solana:4uhcVJyU9pJkvQyS88uRDiswHXSCkY3z": true, // Solana testnet
+}
+
+// IsTestnet reports whether network is a public testnet or local development chain
+func IsTestnet(network x402.Network) bool {
+	_, ok := DefaultFaucets[network]
+	return ok || testnets[network]
+}
+
+// TestPayer answers 402 challenges from a test wallet, e.g. *testwallet.Wallet
+type TestPayer interface {
+	Address() string
+	Pay(ctx context.Context, required *x402types.PaymentRequired) (map[string]string, error)
+}
+
+// DevPaywall adds testnet guidance to paywalls so integrators can complete a payment without
+// a funded wallet of their own: the network and test token, faucet links, and optionally a
+// button paying from a test wallet. Options on mainnets are never shown or paid.
+type DevPaywall struct {
+	faucets map[x402.Network][]PaywallLink
+	payer   TestPayer
+}
+
+// DevPaywallOption configures a DevPaywall
+type DevPaywallOption func(*DevPaywall)
+
+// WithFaucets sets the faucet links shown for network, replacing DefaultFaucets'
+func WithFaucets(network x402.Network, links ...PaywallLink) DevPaywallOption {
+	return func(d *DevPaywall) {
+		d.faucets[network] = links
+	}
+}
+
+// WithTestPayer pays from payer when the integrator clicks "Pay with test wallet". Its keys
+// should be public test keys holding only testnet tokens.
+func WithTestPayer(payer TestPayer) DevPaywallOption {
+	return func(d *DevPaywall) {
+		d.payer = payer
+	}
+}
+
+// NewDevPaywall creates a DevPaywall with DefaultFaucets
+func NewDevPaywall(opts ...DevPaywallOption) *DevPaywall {
+	d := &DevPaywall{faucets: make(map[x402.Network][]PaywallLink, len(DefaultFaucets))}
+	for network, links := range DefaultFaucets {
+		d.faucets[network] = links
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// TestnetGuide is what a paywall tells integrators about a testnet payment option
+type TestnetGuide struct {
+	Network x402.Network `json:"network"`
+
+	// Asset is the test token's address, and Symbol its symbol if known
+	Asset  string `json:"asset"`
+	Symbol string `json:"symbol,omitempty"`
+
+	// Amount is the formatted price, e.g. "0.01 USDC"
+	Amount string `json:"amount"`
+
+	Faucets []PaywallLink `json:"faucets,omitempty"`
+
+	// TestWallet is the test payer's address, or "" without WithTestPayer
+	TestWallet string `json:"testWallet,omitempty"`
+}
+
+// Guide returns the guide for the first testnet option among requirements, or nil if every
+// option is on a mainnet
+func (d *DevPaywall) Guide(requirements []x402types.PaymentRequirements) *TestnetGuide {
+	for _, r := range requirements {
+		network := x402.Network(r.Network)
+		if !IsTestnet(network) {
+			continue
+		}
+		guide := &TestnetGuide{
+			Network: network,
+			Asset:   r.Asset,
+			Amount:  FormatTokenAmount(network, r.Asset, r.Amount),
+			Faucets: d.faucets[network],
+		}
+		guide.Symbol, _, _ = LookupToken(network, r.Asset)
+		if d.payer != nil {
+			guide.TestWallet = d.payer.Address()
+		}
+		return guide
+	}
+	return nil
+}
+
+// Pay answers required from the test payer and returns the payment headers to retry the
+// request with. Only testnet options are considered.
+func (d *DevPaywall) Pay(ctx context.Context, required x402types.PaymentRequired) (map[string]string, error) {
+	if d.payer == nil {
+		return nil, ErrNoTestPayer
+	}
+
+	accepts := make([]x402types.PaymentRequirements, 0, len(required.Accepts))
+	for _, r := range required.Accepts {
+		if IsTestnet(x402.Network(r.Network)) {
+			accepts = append(accepts, r)
+		}
+	}
+	if len(accepts) == 0 {
+		return nil, ErrNoTestnetOption
+	}
+	required.Accepts = accepts
+
+	headers, err := d.payer.Pay(ctx, &required)
+	if err != nil {
+		return nil, fmt.Errorf("test wallet payment failed: %w", err)
+	}
+	return headers, nil
+}
@@ -0,0 +1,119 @@
+// Command devpay pays for a request with a test wallet, for trying paid routes locally:
+//
+//	go run ./devnet/cmd/devpay -url http://localhost:8080/api/purchase \
+//		-data '{"customerEmail":"dev@example.com","items":[{"productId":"cowboy-duck","quantity":1}]}'
+//
+// It sends the request, answers the 402 challenge with testwallet.ForNetwork for the offered
+// network (Anvil account 0 on the devnet), and prints the response and settlement.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/coinbase/x402/go/types"
+
+	"github.com/mvpoyatt/xtended402/server/go/devnet"
+	"github.com/mvpoyatt/xtended402/server/go/testing/testwallet"
+)
+
+func main() {
+	url := flag.String("url", "", "URL of the paid route")
+	method := flag.String("method", "POST", "HTTP method")
+	data := flag.String("data", "", "JSON request body")
+	key := flag.String("key", "", "payer private key (default: testwallet.ForNetwork for the offered network)")
+	usdc := flag.String("usdc", devnet.DefaultUSDCAddress, "MockUSDC address on the devnet")
+	flag.Parse()
+
+	if *url == "" {
+		flag.Usage()
+		log.Fatal("-url is required")
+	}
+
+	// Lets the wallet sign for eip155:31337
+	devnet.RegisterNetwork(*usdc)
+
+	resp := send(*method, *url, *data, nil)
+	if resp.StatusCode != http.StatusPaymentRequired {
+		log.Fatalf("expected 402, got %d: %s", resp.StatusCode, readBody(resp))
+	}
+
+	var required types.PaymentRequired
+	if err := decodeHeader(resp.Header.Get("PAYMENT-REQUIRED"), &required); err != nil {
+		log.Fatalf("invalid PAYMENT-REQUIRED header: %v", err)
+	}
+	if len(required.Accepts) == 0 {
+		log.Fatal("402 challenge offers no payment options")
+	}
+
+	wallet := testwallet.ForNetwork(required.Accepts[0].Network)
+	if *key != "" {
+		wallet = testwallet.MustNew(*key)
+	}
+	for _, option := range required.Accepts {
+		fmt.Printf("Challenge: %s %s of %s on %s to %s\n", option.Scheme, option.Amount, option.Asset, option.Network, option.PayTo)
+	}
+	fmt.Printf("Paying from %s\n", wallet.Address())
+
+	headers, err := wallet.Pay(context.Background(), &required)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	paid := send(*method, *url, *data, headers)
+	fmt.Printf("Status: %d\n%s\n", paid.StatusCode, readBody(paid))
+
+	if header := paid.Header.Get("PAYMENT-RESPONSE"); header != "" {
+		var settlement map[string]interface{}
+		if err := decodeHeader(header, &settlement); err != nil {
+			log.Fatalf("invalid PAYMENT-RESPONSE header: %v", err)
+		}
+		pretty, _ := json.MarshalIndent(settlement, "", "  ")
+		fmt.Printf("Settlement:\n%s\n", pretty)
+	}
+}
+
+func send(method, url, body string, headers map[string]string) *http.Response {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return resp
+}
+
+func readBody(resp *http.Response) string {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func decodeHeader(header string, v interface{}) error {
+	data, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,197 @@
+// Package devnet runs the whole payment lifecycle offline: the payment middleware, a
+// self-hosted facilitator, and a local Anvil (or Hardhat) chain with a mock USDC token.
+//
+//	docker compose -f server/go/devnet/docker-compose.yml up -d   # Anvil + MockUSDC
+//
+//	dev, err := devnet.Start(ctx, devnet.ConfigFromEnv())
+//	server := x402.Newx402ResourceServer(x402.WithFacilitatorClient(dev.Facilitator))
+//	server.Register(devnet.Network, evmserver.NewExactEvmScheme())
+//
+// Payers can use testwallet.ForNetwork(devnet.Network), which is pre-funded by the compose setup.
+package devnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	x402 "github.com/coinbase/x402/go"
+	"github.com/coinbase/x402/go/mechanisms/evm"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/mvpoyatt/xtended402/server/go/facilitator"
+	"github.com/mvpoyatt/xtended402/server/go/testing/testwallet"
+)
+
+const (
+	// Network is the CAIP-2 identifier of the local chain
+	Network = x402.Network("eip155:31337")
+
+	// ChainID is the Anvil/Hardhat default chain ID
+	ChainID = 31337
+
+	// DefaultRPCURL is Anvil's default endpoint
+	DefaultRPCURL = "http://127.0.0.1:8545"
+
+	// DefaultUSDCAddress is where MockUSDC lands when it is the first contract deployed by
+	// testwallet.Account(0) on a fresh chain, as the compose setup does
+	DefaultUSDCAddress = "0x5FbDB2315678afecb367f032d93F642f64180aa3"
+)
+
+// Environment variables read by ConfigFromEnv
+const (
+	EnvEnabled        = "XTENDED402_DEVNET"
+	EnvRPCURL         = "XTENDED402_DEVNET_RPC_URL"
+	EnvUSDCAddress    = "XTENDED402_DEVNET_USDC"
+	EnvFacilitatorKey = "XTENDED402_DEVNET_FACILITATOR_KEY"
+)
+
+// mintABI is MockUSDC's open mint function
+var mintABI = []byte(`[{
+	"inputs": [
+		{"name": "to", "type": "address"},
+		{"name": "value", "type": "uint256"}
+	],
+	"name": "mint",
+	"outputs": [],
+	"stateMutability": "nonpayable",
+	"type": "function"
+}]`)
+
+// Config locates the local chain and token
+type Config struct {
+	// RPCURL of the local chain (default DefaultRPCURL)
+	RPCURL string
+
+	// USDCAddress of the deployed MockUSDC (default DefaultUSDCAddress)
+	USDCAddress string
+
+	// FacilitatorKey pays gas for settlement (default testwallet.Account(1))
+	FacilitatorKey string
+}
+
+// Enabled reports whether dev mode is switched on via XTENDED402_DEVNET
+func Enabled() bool {
+	switch strings.ToLower(os.Getenv(EnvEnabled)) {
+	case "", "0", "false", "off":
+		return false
+	}
+	return true
+}
+
+// ConfigFromEnv reads Config from XTENDED402_DEVNET_* variables, falling back to defaults
+func ConfigFromEnv() Config {
+	return Config{
+		RPCURL:         os.Getenv(EnvRPCURL),
+		USDCAddress:    os.Getenv(EnvUSDCAddress),
+		FacilitatorKey: os.Getenv(EnvFacilitatorKey),
+	}
+}
+
+func (c Config) withDefaults() Config {
+	if c.RPCURL == "" {
+		c.RPCURL = DefaultRPCURL
+	}
+	if c.USDCAddress == "" {
+		c.USDCAddress = DefaultUSDCAddress
+	}
+	if c.FacilitatorKey == "" {
+		c.FacilitatorKey = testwallet.Account(1).PrivateKey()
+	}
+	return c
+}
+
+// RegisterNetwork makes the local chain known to the x402 EVM mechanism with usdcAddress as
+// its default asset, so clients, servers, and facilitators can price, sign, and settle on it.
+// Call it before creating servers or clients.
+func RegisterNetwork(usdcAddress string) {
+	evm.NetworkConfigs[string(Network)] = evm.NetworkConfig{
+		ChainID: big.NewInt(ChainID),
+		DefaultAsset: evm.AssetInfo{
+			Address:  usdcAddress,
+			Name:     "USD Coin",
+			Version:  "2",
+			Decimals: 6,
+		},
+		SupportedAssets: map[string]evm.AssetInfo{
+			"USDC": {Address: usdcAddress, Name: "USD Coin", Version: "2", Decimals: 6},
+		},
+	}
+}
+
+// Devnet is a running connection to the local chain with a self-hosted facilitator
+type Devnet struct {
+	Config Config
+
+	// Facilitator verifies and settles payments on the local chain
+	Facilitator *facilitator.Facilitator
+
+	signer *facilitator.Signer
+}
+
+// Start registers the local network, connects to the chain, and checks MockUSDC is deployed
+func Start(ctx context.Context, cfg Config) (*Devnet, error) {
+	cfg = cfg.withDefaults()
+	RegisterNetwork(cfg.USDCAddress)
+
+	signer, err := facilitator.NewSigner(ctx, cfg.RPCURL, cfg.FacilitatorKey)
+	if err != nil {
+		return nil, fmt.Errorf("devnet: failed to connect to %s: %w", cfg.RPCURL, err)
+	}
+
+	chainID, err := signer.GetChainID(ctx)
+	if err != nil {
+		signer.Close()
+		return nil, fmt.Errorf("devnet: failed to read chain ID: %w", err)
+	}
+	if chainID.Int64() != ChainID {
+		signer.Close()
+		return nil, fmt.Errorf("devnet: chain at %s has ID %s, expected %d", cfg.RPCURL, chainID, ChainID)
+	}
+
+	code, err := signer.GetCode(ctx, cfg.USDCAddress)
+	if err != nil {
+		signer.Close()
+		return nil, fmt.Errorf("devnet: failed to read MockUSDC code: %w", err)
+	}
+	if len(code) == 0 {
+		signer.Close()
+		return nil, fmt.Errorf("devnet: no contract at %s; deploy contracts/MockUSDC.sol first", cfg.USDCAddress)
+	}
+
+	return &Devnet{
+		Config:      cfg,
+		Facilitator: facilitator.New(signer, []x402.Network{Network}, nil),
+		signer:      signer,
+	}, nil
+}
+
+// Close releases the chain connection
+func (d *Devnet) Close() {
+	d.signer.Close()
+}
+
+// Fund mints amount (smallest units) of MockUSDC to address and waits for the transaction
+func (d *Devnet) Fund(ctx context.Context, address string, amount *big.Int) error {
+	txHash, err := d.signer.WriteContract(ctx, d.Config.USDCAddress, mintABI, "mint", common.HexToAddress(address), amount)
+	if err != nil {
+		return fmt.Errorf("devnet: failed to mint: %w", err)
+	}
+
+	receipt, err := d.signer.WaitForTransactionReceipt(ctx, txHash)
+	if err != nil {
+		return fmt.Errorf("devnet: failed to confirm mint: %w", err)
+	}
+	if receipt.Status != evm.TxStatusSuccess {
+		return errors.New("devnet: mint transaction reverted")
+	}
+	return nil
+}
+
+// Balance returns address's MockUSDC balance in smallest units
+func (d *Devnet) Balance(ctx context.Context, address string) (*big.Int, error) {
+	return d.signer.GetBalance(ctx, address, d.Config.USDCAddress)
+}
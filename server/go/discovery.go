@@ -0,0 +1,182 @@
+package xtended402
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	x402 "github.com/coinbase/x402/go"
+	exttypes "github.com/coinbase/x402/go/extensions/types"
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+const (
+	// WellKnownPath is where agents look for a server's catalog of paid routes
+	WellKnownPath = "/.well-known/x402"
+
+	// CatalogVersion is the version of the Catalog format
+	CatalogVersion = 1
+)
+
+// Catalog lists a server's paid routes and what they accept, so agents can discover paid
+// capabilities without probing each route for its 402
+type Catalog struct {
+	Version     int `json:"version"`
+	X402Version int `json:"x402Version"`
+
+	// Accepts summarizes every scheme, network, and asset accepted on any route
+	Accepts []CatalogAsset `json:"accepts"`
+
+	Resources []CatalogResource `json:"resources"`
+}
+
+// CatalogAsset is a scheme, network, and asset accepted somewhere in a Catalog
+type CatalogAsset struct {
+	Scheme  string       `json:"scheme"`
+	Network x402.Network `json:"network"`
+	Asset   string       `json:"asset,omitempty"`
+	Symbol  string       `json:"symbol,omitempty"`
+}
+
+// CatalogResource is one paid route
+type CatalogResource struct {
+	// Route is the route pattern, e.g. "GET /api/weather/[city]". Method is "*" when any
+	// method is paid.
+	Route       string   `json:"route"`
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Description string   `json:"description,omitempty"`
+	MimeType    string   `json:"mimeType,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
+
+	Accepts []CatalogPrice `json:"accepts"`
+
+	// Discovery is the route's Bazaar listing, if any
+	Discovery *exttypes.DiscoveryExtension `json:"discovery,omitempty"`
+}
+
+// CatalogPrice is one payment option of a route. Static prices are resolved to the asset and
+// amount a 402 would ask for; prices and recipients resolved per request are flagged as
+// dynamic instead.
+type CatalogPrice struct {
+	Scheme  string       `json:"scheme"`
+	Network x402.Network `json:"network"`
+
+	Price         x402.Price `json:"price,omitempty"`
+	Asset         string     `json:"asset,omitempty"`
+	Amount        string     `json:"amount,omitempty"`
+	DisplayAmount string     `json:"displayAmount,omitempty"`
+	DynamicPrice  bool       `json:"dynamicPrice,omitempty"`
+
+	PayTo        string `json:"payTo,omitempty"`
+	DynamicPayTo bool   `json:"dynamicPayTo,omitempty"`
+
+	// Quotes are dynamic prices and recipients resolved for representative requests, listed by
+	// a ResourceCatalog
+	Quotes []CatalogQuote `json:"quotes,omitempty"`
+}
+
+// BuildCatalog catalogs routes, resolving static prices through server as a 402 would. The
+// server must have synced with its facilitators; prices it can't resolve are listed without
+// an asset and amount.
+func BuildCatalog(ctx context.Context, routes x402http.RoutesConfig, server *x402.X402ResourceServer) Catalog {
+	return buildCatalog(ctx, routes, server, nil)
+}
+
+// quoteFunc resolves a dynamic payment option of the route pattern for representative requests
+type quoteFunc func(ctx context.Context, server *x402http.HTTPServer, pattern, method, path string, option x402http.PaymentOption) []CatalogQuote
+
+func buildCatalog(ctx context.Context, routes x402http.RoutesConfig, server *x402.X402ResourceServer, quote quoteFunc) Catalog {
+	httpServer := x402http.Wrappedx402HTTPResourceServer(routes, server)
+
+	catalog := Catalog{
+		Version:     CatalogVersion,
+		X402Version: 2,
+		Accepts:     []CatalogAsset{},
+		Resources:   make([]CatalogResource, 0, len(routes)),
+	}
+	seen := make(map[CatalogAsset]bool)
+
+	for pattern, route := range routes {
+		parsed := ParseRoutePattern(pattern)
+		method, path := parsed.Method(), parsed.Path()
+
+		resource := CatalogResource{
+			Route:       pattern,
+			Method:      method,
+			Path:        path,
+			Description: route.Description,
+			MimeType:    route.MimeType,
+			Scopes:      RouteScopes(route),
+			Accepts:     make([]CatalogPrice, 0, len(route.Accepts)),
+		}
+		if listing, ok := route.Extensions[exttypes.BAZAAR].(exttypes.DiscoveryExtension); ok {
+			resource.Discovery = &listing
+		}
+
+		reqCtx := x402http.HTTPRequestContext{Path: path, Method: method}
+		for _, option := range route.Accepts {
+			price := catalogPrice(ctx, httpServer, option, reqCtx)
+			if quote != nil && (price.DynamicPrice || price.DynamicPayTo) {
+				price.Quotes = quote(ctx, httpServer, pattern, method, path, option)
+			}
+			resource.Accepts = append(resource.Accepts, price)
+
+			assets := []string{price.Asset}
+			for _, q := range price.Quotes {
+				if q.Asset != "" {
+					assets = append(assets, q.Asset)
+				}
+			}
+			if price.Asset == "" && len(assets) > 1 {
+				assets = assets[1:]
+			}
+			for _, address := range assets {
+				asset := CatalogAsset{Scheme: price.Scheme, Network: price.Network, Asset: address}
+				if address != "" {
+					asset.Symbol, _, _ = LookupToken(asset.Network, address)
+				}
+				if !seen[asset] {
+					seen[asset] = true
+					catalog.Accepts = append(catalog.Accepts, asset)
+				}
+			}
+		}
+		catalog.Resources = append(catalog.Resources, resource)
+	}
+
+	sort.Slice(catalog.Resources, func(i, j int) bool { return catalog.Resources[i].Route < catalog.Resources[j].Route })
+	sort.Slice(catalog.Accepts, func(i, j int) bool {
+		a, b := catalog.Accepts[i], catalog.Accepts[j]
+		return fmt.Sprint(a.Network, a.Scheme, a.Asset) < fmt.Sprint(b.Network, b.Scheme, b.Asset)
+	})
+	return catalog
+}
+
+// catalogPrice describes option, resolving a static price without running any per-request
+// pricing or recipient function
+func catalogPrice(ctx context.Context, server *x402http.HTTPServer, option x402http.PaymentOption, reqCtx x402http.HTTPRequestContext) CatalogPrice {
+	price := CatalogPrice{Scheme: option.Scheme, Network: option.Network}
+
+	switch payTo := option.PayTo.(type) {
+	case x402http.DynamicPayToFunc:
+		price.DynamicPayTo = true
+		option.PayTo = ""
+	case string:
+		price.PayTo = payTo
+	}
+	if _, ok := option.Price.(x402http.DynamicPriceFunc); ok {
+		price.DynamicPrice = true
+		return price
+	}
+	price.Price = option.Price
+
+	requirements, err := server.BuildPaymentRequirementsFromOptions(ctx, []x402http.PaymentOption{option}, reqCtx)
+	if err != nil || len(requirements) == 0 {
+		return price
+	}
+	price.Asset = requirements[0].Asset
+	price.Amount = requirements[0].Amount
+	price.DisplayAmount = FormatTokenAmount(option.Network, price.Asset, price.Amount)
+	return price
+}
@@ -0,0 +1,514 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EventPaymentDisputed is emitted when a settled payment is flagged as disputed
+	EventPaymentDisputed PaymentEventType = "payment.disputed"
+
+	// EventDisputeUpdated is emitted when evidence or notes are added to an open dispute
+	EventDisputeUpdated PaymentEventType = "payment.dispute_updated"
+
+	// EventDisputeResolved is emitted when a dispute is resolved as refunded or upheld
+	EventDisputeResolved PaymentEventType = "payment.dispute_resolved"
+)
+
+const (
+	// DisputeIDMetadataKey is the dispute event metadata key carrying the dispute's ID
+	DisputeIDMetadataKey = "disputeId"
+
+	// DisputeStatusMetadataKey is the dispute event metadata key carrying the dispute's status
+	// after the transition
+	DisputeStatusMetadataKey = "disputeStatus"
+
+	// DisputeReasonMetadataKey is the dispute event metadata key carrying why the payment was
+	// disputed
+	DisputeReasonMetadataKey = "disputeReason"
+)
+
+var (
+	// ErrDisputeNotFound is returned for unknown dispute IDs
+	ErrDisputeNotFound = errors.New("dispute not found")
+
+	// ErrNotDisputable is returned when flagging a payment that isn't settled or is already
+	// under an open dispute
+	ErrNotDisputable = errors.New("payment not disputable")
+
+	// ErrDisputeResolved is returned when changing a dispute that was already resolved
+	ErrDisputeResolved = errors.New("dispute already resolved")
+)
+
+// DisputeStatus is where a dispute stands
+type DisputeStatus string
+
+const (
+	// DisputeOpen disputes freeze the access the payment bought until they're resolved
+	DisputeOpen DisputeStatus = "open"
+
+	// DisputeRefunded disputes were settled in the payer's favour; the access stays revoked
+	DisputeRefunded DisputeStatus = "refunded"
+
+	// DisputeUpheld disputes were settled in the merchant's favour; the access is restored
+	DisputeUpheld DisputeStatus = "upheld"
+)
+
+// Dispute is a payer's challenge to a settled payment, such as a chargeback or a complaint
+// that the content wasn't delivered
+type Dispute struct {
+	ID string `json:"id"`
+
+	// PaymentID is the ID of the disputed payment's record in the PaymentStore
+	PaymentID string `json:"paymentId"`
+
+	// Transaction is the disputed settlement transaction
+	Transaction string `json:"transaction"`
+
+	Payer    string        `json:"payer,omitempty"`
+	Resource string        `json:"resource,omitempty"`
+	Status   DisputeStatus `json:"status"`
+	Reason   string        `json:"reason,omitempty"`
+
+	Evidence []DisputeEvidence `json:"evidence,omitempty"`
+
+	// Resolution is the note the dispute was resolved with
+	Resolution string `json:"resolution,omitempty"`
+
+	OpenedAt   time.Time  `json:"openedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// DisputeEvidence is a note or document attached to a dispute
+type DisputeEvidence struct {
+	AddedAt time.Time `json:"addedAt"`
+
+	// Author is who added the evidence, e.g. an operator's email
+	Author string `json:"author,omitempty"`
+
+	Note string `json:"note,omitempty"`
+
+	// URL links a document kept elsewhere, e.g. delivery logs or a screenshot
+	URL string `json:"url,omitempty"`
+}
+
+// DisputeQuery filters disputes. Zero fields match everything.
+type DisputeQuery struct {
+	Status      DisputeStatus
+	PaymentID   string
+	Transaction string
+}
+
+// Matches reports whether dispute passes the query's filters. Transactions are compared
+// case-insensitively.
+func (q DisputeQuery) Matches(dispute *Dispute) bool {
+	if q.Status != "" && dispute.Status != q.Status {
+		return false
+	}
+	if q.PaymentID != "" && dispute.PaymentID != q.PaymentID {
+		return false
+	}
+	if q.Transaction != "" && !strings.EqualFold(dispute.Transaction, q.Transaction) {
+		return false
+	}
+	return true
+}
+
+// DisputeStore keeps disputes by ID
+type DisputeStore interface {
+	// SaveDispute stores dispute, replacing any dispute with the same ID
+	SaveDispute(ctx context.Context, dispute *Dispute) error
+
+	// GetDispute returns the dispute with id, or ErrDisputeNotFound
+	GetDispute(ctx context.Context, id string) (*Dispute, error)
+
+	// ListDisputes returns the disputes matching query, oldest first
+	ListDisputes(ctx context.Context, query DisputeQuery) ([]*Dispute, error)
+}
+
+// Disputes is a lightweight dispute process for settled payments. Flagging a payment freezes
+// the sessions, API keys, access grants, and entitlements it bought (through Revocations)
+// until the dispute is resolved: as refunded, which keeps them revoked, or as upheld, which
+// restores them. Each transition emits a payment event to the handlers.
+//
+// Disputes only tracks the process; refund the payment yourself, e.g. with the admin API.
+type Disputes struct {
+	store    DisputeStore
+	payments PaymentStore
+	handlers []PaymentEventHandler
+	clock    Clock
+
+	// mu serializes transitions so a payment can't be flagged twice. It doesn't coordinate
+	// replicas; manage disputes through one.
+	mu sync.Mutex
+}
+
+// DisputeOption configures Disputes
+type DisputeOption func(*Disputes)
+
+// WithDisputeEventHandlers adds handlers for the events of each transition, e.g. webhooks
+func WithDisputeEventHandlers(handlers ...PaymentEventHandler) DisputeOption {
+	return func(d *Disputes) {
+		d.handlers = append(d.handlers, handlers...)
+	}
+}
+
+// WithDisputeClock sets the clock for dispute and event times (default SystemClock)
+func WithDisputeClock(clock Clock) DisputeOption {
+	return func(d *Disputes) {
+		d.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewDisputes creates Disputes keeping disputes in store, for payments recorded in payments
+func NewDisputes(store DisputeStore, payments PaymentStore, opts ...DisputeOption) *Disputes {
+	d := &Disputes{
+		store:    store,
+		payments: payments,
+		clock:    SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Open flags the settled payment with paymentID as disputed and emits payment.disputed
+func (d *Disputes) Open(ctx context.Context, paymentID, reason string) (*Dispute, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	payment, err := d.payments.GetPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != PaymentStatusSettled || payment.Transaction == "" {
+		return nil, fmt.Errorf("%w: payment %s is %s", ErrNotDisputable, paymentID, payment.Status)
+	}
+	open, err := d.store.ListDisputes(ctx, DisputeQuery{Status: DisputeOpen, PaymentID: paymentID})
+	if err != nil {
+		return nil, err
+	}
+	if len(open) > 0 {
+		return nil, fmt.Errorf("%w: payment %s is already disputed in %s", ErrNotDisputable, paymentID, open[0].ID)
+	}
+
+	dispute := &Dispute{
+		ID:          newDisputeID(),
+		PaymentID:   payment.ID,
+		Transaction: payment.Transaction,
+		Payer:       payment.Payer,
+		Resource:    payment.Resource,
+		Status:      DisputeOpen,
+		Reason:      reason,
+		OpenedAt:    d.clock.Now().UTC(),
+	}
+	if err := d.store.SaveDispute(ctx, dispute); err != nil {
+		return nil, err
+	}
+
+	d.emit(ctx, EventPaymentDisputed, dispute, payment)
+	return dispute, nil
+}
+
+// AddEvidence attaches evidence to the open dispute with id and emits payment.dispute_updated.
+// AddedAt defaults to now.
+func (d *Disputes) AddEvidence(ctx context.Context, id string, evidence DisputeEvidence) (*Dispute, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dispute, err := d.openDispute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if evidence.AddedAt.IsZero() {
+		evidence.AddedAt = d.clock.Now().UTC()
+	}
+	dispute.Evidence = append(dispute.Evidence, evidence)
+	if err := d.store.SaveDispute(ctx, dispute); err != nil {
+		return nil, err
+	}
+
+	d.emit(ctx, EventDisputeUpdated, dispute, nil)
+	return dispute, nil
+}
+
+// Resolve closes the open dispute with id as DisputeRefunded or DisputeUpheld and emits
+// payment.dispute_resolved
+func (d *Disputes) Resolve(ctx context.Context, id string, outcome DisputeStatus, note string) (*Dispute, error) {
+	if outcome != DisputeRefunded && outcome != DisputeUpheld {
+		return nil, fmt.Errorf("invalid dispute outcome %q", outcome)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dispute, err := d.openDispute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	dispute.Status = outcome
+	dispute.Resolution = note
+	resolvedAt := d.clock.Now().UTC()
+	dispute.ResolvedAt = &resolvedAt
+	if err := d.store.SaveDispute(ctx, dispute); err != nil {
+		return nil, err
+	}
+
+	d.emit(ctx, EventDisputeResolved, dispute, nil)
+	return dispute, nil
+}
+
+// Get returns the dispute with id, or ErrDisputeNotFound
+func (d *Disputes) Get(ctx context.Context, id string) (*Dispute, error) {
+	return d.store.GetDispute(ctx, id)
+}
+
+// List returns the disputes matching query, oldest first
+func (d *Disputes) List(ctx context.Context, query DisputeQuery) ([]*Dispute, error) {
+	return d.store.ListDisputes(ctx, query)
+}
+
+// IsFrozen reports whether transaction's access is held by a dispute: one that's open, or was
+// resolved as refunded
+func (d *Disputes) IsFrozen(ctx context.Context, transaction string) (bool, error) {
+	disputes, err := d.store.ListDisputes(ctx, DisputeQuery{Transaction: transaction})
+	if err != nil {
+		return false, err
+	}
+	for _, dispute := range disputes {
+		if dispute.Status != DisputeUpheld {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Revocations wraps list so transactions frozen by a dispute count as revoked. Pass the result
+// wherever list was used (sessions, API keys, access grants, entitlements) to freeze the access
+// of disputed payments. Revoke goes to list.
+func (d *Disputes) Revocations(list RevocationList) RevocationList {
+	return &disputeRevocations{list: list, disputes: d}
+}
+
+func (d *Disputes) openDispute(ctx context.Context, id string) (*Dispute, error) {
+	dispute, err := d.store.GetDispute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status != DisputeOpen {
+		return nil, fmt.Errorf("%w: dispute %s is %s", ErrDisputeResolved, id, dispute.Status)
+	}
+	return dispute, nil
+}
+
+// emit sends the event for a transition of dispute. The payment is looked up when not given;
+// if it's gone, the event carries what the dispute recorded.
+func (d *Disputes) emit(ctx context.Context, eventType PaymentEventType, dispute *Dispute, payment *PaymentRecord) {
+	if len(d.handlers) == 0 {
+		return
+	}
+	if payment == nil {
+		var err error
+		if payment, err = d.payments.GetPayment(ctx, dispute.PaymentID); err != nil {
+			payment = &PaymentRecord{Resource: dispute.Resource, Payer: dispute.Payer}
+		}
+	}
+
+	metadata := make(map[string]string, len(payment.Metadata)+3)
+	for key, value := range payment.Metadata {
+		metadata[key] = value
+	}
+	metadata[DisputeIDMetadataKey] = dispute.ID
+	metadata[DisputeStatusMetadataKey] = string(dispute.Status)
+	if dispute.Reason != "" {
+		metadata[DisputeReasonMetadataKey] = dispute.Reason
+	}
+
+	event := PaymentEvent{
+		ID:          NewEventID(),
+		Type:        eventType,
+		CreatedAt:   d.clock.Now().UTC(),
+		Resource:    payment.Resource,
+		Scheme:      payment.Scheme,
+		Network:     payment.Network,
+		Asset:       payment.Asset,
+		Amount:      payment.Amount,
+		PayTo:       payment.PayTo,
+		Payer:       payment.Payer,
+		Transaction: dispute.Transaction,
+		Metadata:    metadata,
+	}
+	for _, handler := range d.handlers {
+		handler(ctx, event)
+	}
+}
+
+// disputeRevocations is a RevocationList that also revokes transactions frozen by disputes
+type disputeRevocations struct {
+	list     RevocationList
+	disputes *Disputes
+}
+
+func (r *disputeRevocations) Revoke(ctx context.Context, transaction string) error {
+	return r.list.Revoke(ctx, transaction)
+}
+
+func (r *disputeRevocations) IsRevoked(ctx context.Context, transaction string) (bool, error) {
+	revoked, err := r.list.IsRevoked(ctx, transaction)
+	if err != nil || revoked {
+		return revoked, err
+	}
+	return r.disputes.IsFrozen(ctx, transaction)
+}
+
+// newDisputeID returns a random dispute ID such as "dsp_3f9a…"
+func newDisputeID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return "dsp_" + hex.EncodeToString(id)
+}
+
+// ============================================================================
+// Dispute Stores
+// ============================================================================
+
+// MemoryDisputeStore is an in-process DisputeStore. Disputes are lost on restart.
+type MemoryDisputeStore struct {
+	mu       sync.RWMutex
+	disputes map[string]*Dispute
+}
+
+// NewMemoryDisputeStore creates an empty MemoryDisputeStore
+func NewMemoryDisputeStore() *MemoryDisputeStore {
+	return &MemoryDisputeStore{disputes: make(map[string]*Dispute)}
+}
+
+// SaveDispute stores dispute
+func (s *MemoryDisputeStore) SaveDispute(ctx context.Context, dispute *Dispute) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.disputes[dispute.ID] = copyDispute(dispute)
+	return nil
+}
+
+// GetDispute returns the dispute with id, or ErrDisputeNotFound
+func (s *MemoryDisputeStore) GetDispute(ctx context.Context, id string) (*Dispute, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dispute, ok := s.disputes[id]
+	if !ok {
+		return nil, ErrDisputeNotFound
+	}
+	return copyDispute(dispute), nil
+}
+
+// ListDisputes returns the disputes matching query, oldest first
+func (s *MemoryDisputeStore) ListDisputes(ctx context.Context, query DisputeQuery) ([]*Dispute, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var disputes []*Dispute
+	for _, dispute := range s.disputes {
+		if query.Matches(dispute) {
+			disputes = append(disputes, copyDispute(dispute))
+		}
+	}
+	sortDisputes(disputes)
+	return disputes, nil
+}
+
+// FileDisputeStore keeps each dispute as a JSON file in a directory, so disputes survive
+// restarts
+type FileDisputeStore struct {
+	dir string
+}
+
+// NewFileDisputeStore creates a FileDisputeStore in dir, which is created if needed
+func NewFileDisputeStore(dir string) *FileDisputeStore {
+	return &FileDisputeStore{dir: dir}
+}
+
+// SaveDispute writes dispute to its file
+func (s *FileDisputeStore) SaveDispute(ctx context.Context, dispute *Dispute) error {
+	data, err := json.Marshal(dispute)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(dispute.ID), data)
+}
+
+// GetDispute reads the dispute with id, or returns ErrDisputeNotFound
+func (s *FileDisputeStore) GetDispute(ctx context.Context, id string) (*Dispute, error) {
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrDisputeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dispute Dispute
+	if err := json.Unmarshal(data, &dispute); err != nil {
+		return nil, fmt.Errorf("invalid dispute file for %s: %w", id, err)
+	}
+	return &dispute, nil
+}
+
+// ListDisputes reads the disputes matching query, oldest first
+func (s *FileDisputeStore) ListDisputes(ctx context.Context, query DisputeQuery) ([]*Dispute, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var disputes []*Dispute
+	for _, path := range paths {
+		dispute, err := s.GetDispute(ctx, strings.TrimSuffix(filepath.Base(path), ".json"))
+		if errors.Is(err, ErrDisputeNotFound) {
+			continue // removed meanwhile
+		}
+		if err != nil {
+			return nil, err
+		}
+		if query.Matches(dispute) {
+			disputes = append(disputes, dispute)
+		}
+	}
+	sortDisputes(disputes)
+	return disputes, nil
+}
+
+func (s *FileDisputeStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
+
+func copyDispute(dispute *Dispute) *Dispute {
+	copied := *dispute
+	copied.Evidence = append([]DisputeEvidence(nil), dispute.Evidence...)
+	return &copied
+}
+
+func sortDisputes(disputes []*Dispute) {
+	sort.Slice(disputes, func(i, j int) bool {
+		if disputes[i].OpenedAt.Equal(disputes[j].OpenedAt) {
+			return disputes[i].ID < disputes[j].ID
+		}
+		return disputes[i].OpenedAt.Before(disputes[j].OpenedAt)
+	})
+}
@@ -0,0 +1,368 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entitlement is a capability a payer bought: a route, a feature, or a number of seats
+type Entitlement struct {
+	Payer string `json:"payer"`
+
+	// Key names the capability, e.g. "feature:export" or "seats:team"
+	Key string `json:"key"`
+
+	// Quantity counts seats or units; purchases of the same key add up
+	Quantity int `json:"quantity"`
+
+	// Transaction is the settlement that paid for the entitlement
+	Transaction string `json:"transaction,omitempty"`
+
+	GrantedAt time.Time `json:"grantedAt"`
+
+	// ExpiresAt is zero for entitlements that don't expire
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+// Active reports whether the entitlement is unexpired at now
+func (e *Entitlement) Active(now time.Time) bool {
+	return e.ExpiresAt.IsZero() || now.Before(e.ExpiresAt)
+}
+
+// EntitlementGrant describes an entitlement granted by a purchase
+type EntitlementGrant struct {
+	Key string
+
+	// Quantity defaults to 1
+	Quantity int
+
+	// Duration is how long the entitlement lasts; 0 means forever
+	Duration time.Duration
+}
+
+// EntitlementStore keeps entitlements by payer. Payers are compared case-insensitively.
+type EntitlementStore interface {
+	// SaveEntitlement stores entitlement, replacing any with the same payer, key, and
+	// transaction
+	SaveEntitlement(ctx context.Context, entitlement *Entitlement) error
+
+	// ListEntitlements returns the payer's entitlements, expired ones included
+	ListEntitlements(ctx context.Context, payer string) ([]*Entitlement, error)
+}
+
+// Entitlements maps payers to the capabilities they bought. It grants entitlements from
+// payment.settled events on configured routes, and handlers ask it what a payer may do with
+// HasEntitlement.
+type Entitlements struct {
+	store       EntitlementStore
+	rules       []entitlementRule
+	revocations RevocationList
+	clock       Clock
+}
+
+type entitlementRule struct {
+	route  RoutePattern
+	grants []EntitlementGrant
+}
+
+// EntitlementOption configures Entitlements
+type EntitlementOption func(*Entitlements)
+
+// WithEntitlementRoute grants entitlements for each payment settled on routes matching
+// pattern (same syntax as x402http.RoutesConfig keys), e.g. "POST /plans/pro". Every matching
+// pattern applies.
+func WithEntitlementRoute(pattern string, grants ...EntitlementGrant) EntitlementOption {
+	return func(e *Entitlements) {
+		e.rules = append(e.rules, entitlementRule{route: ParseRoutePattern(pattern), grants: grants})
+	}
+}
+
+// WithEntitlementRevocations ignores entitlements paid by transactions revoked in list, e.g.
+// by RevokeRefunded
+func WithEntitlementRevocations(list RevocationList) EntitlementOption {
+	return func(e *Entitlements) {
+		e.revocations = list
+	}
+}
+
+// WithEntitlementClock sets the clock for grant and expiry times (default SystemClock)
+func WithEntitlementClock(clock Clock) EntitlementOption {
+	return func(e *Entitlements) {
+		e.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewEntitlements creates Entitlements keeping entitlements in store
+func NewEntitlements(store EntitlementStore, opts ...EntitlementOption) *Entitlements {
+	e := &Entitlements{
+		store: store,
+		clock: SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Handle is a PaymentEventHandler that grants the entitlements of payment.settled events on
+// entitlement routes. Pass it to ginmw.WithEventHandler. Failures are logged.
+func (e *Entitlements) Handle(ctx context.Context, event PaymentEvent) {
+	if event.Type != EventPaymentSettled {
+		return
+	}
+	if err := e.GrantFor(ctx, event); err != nil {
+		fmt.Printf("Warning: failed to grant entitlements for %s: %v\n", event.ID, err)
+	}
+}
+
+// GrantFor grants the entitlements of every route matching the settled payment's resource
+func (e *Entitlements) GrantFor(ctx context.Context, event PaymentEvent) error {
+	if event.Payer == "" {
+		return nil
+	}
+	for _, rule := range e.rules {
+		if !rule.route.Matches(event.Resource) {
+			continue
+		}
+		for _, grant := range rule.grants {
+			if err := e.Grant(ctx, event.Payer, grant, event.Transaction); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Grant gives payer the entitlement described by grant, paid by transaction. Use it for
+// entitlements bought outside the middleware, such as seats added by an admin.
+func (e *Entitlements) Grant(ctx context.Context, payer string, grant EntitlementGrant, transaction string) error {
+	now := e.clock.Now().UTC()
+	entitlement := &Entitlement{
+		Payer:       payer,
+		Key:         grant.Key,
+		Quantity:    grant.Quantity,
+		Transaction: transaction,
+		GrantedAt:   now,
+	}
+	if entitlement.Quantity <= 0 {
+		entitlement.Quantity = 1
+	}
+	if grant.Duration > 0 {
+		entitlement.ExpiresAt = now.Add(grant.Duration)
+	}
+	return e.store.SaveEntitlement(ctx, entitlement)
+}
+
+// List returns the payer's active entitlements
+func (e *Entitlements) List(ctx context.Context, payer string) ([]*Entitlement, error) {
+	if payer == "" {
+		return nil, nil
+	}
+	entitlements, err := e.store.ListEntitlements(ctx, payer)
+	if err != nil {
+		return nil, err
+	}
+
+	now := e.clock.Now()
+	active := entitlements[:0]
+	for _, entitlement := range entitlements {
+		if !entitlement.Active(now) {
+			continue
+		}
+		if e.revocations != nil && entitlement.Transaction != "" {
+			revoked, err := e.revocations.IsRevoked(ctx, entitlement.Transaction)
+			if err != nil {
+				return nil, err
+			}
+			if revoked {
+				continue
+			}
+		}
+		active = append(active, entitlement)
+	}
+	return active, nil
+}
+
+// HasEntitlement reports whether payer holds an active entitlement to key
+func (e *Entitlements) HasEntitlement(ctx context.Context, payer, key string) (bool, error) {
+	quantity, err := e.Quantity(ctx, payer, key)
+	return quantity > 0, err
+}
+
+// Quantity returns the total quantity of payer's active entitlements to key, e.g. seats
+func (e *Entitlements) Quantity(ctx context.Context, payer, key string) (int, error) {
+	entitlements, err := e.List(ctx, payer)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, entitlement := range entitlements {
+		if entitlement.Key == key {
+			total += entitlement.Quantity
+		}
+	}
+	return total, nil
+}
+
+// entitlementID identifies an entitlement within its payer's list
+func entitlementID(entitlement *Entitlement) string {
+	return entitlement.Key + "|" + entitlement.Transaction
+}
+
+func entitlementPayer(payer string) string {
+	return strings.ToLower(payer)
+}
+
+// ============================================================================
+// Entitlement Stores
+// ============================================================================
+
+// MemoryEntitlementStore is an in-process EntitlementStore. Entitlements are lost on restart.
+type MemoryEntitlementStore struct {
+	mu           sync.Mutex
+	entitlements map[string]map[string]*Entitlement
+}
+
+// NewMemoryEntitlementStore creates an empty MemoryEntitlementStore
+func NewMemoryEntitlementStore() *MemoryEntitlementStore {
+	return &MemoryEntitlementStore{entitlements: make(map[string]map[string]*Entitlement)}
+}
+
+// SaveEntitlement stores entitlement
+func (s *MemoryEntitlementStore) SaveEntitlement(ctx context.Context, entitlement *Entitlement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payer := entitlementPayer(entitlement.Payer)
+	if s.entitlements[payer] == nil {
+		s.entitlements[payer] = make(map[string]*Entitlement)
+	}
+	stored := *entitlement
+	s.entitlements[payer][entitlementID(entitlement)] = &stored
+	return nil
+}
+
+// ListEntitlements returns the payer's entitlements, oldest first
+func (s *MemoryEntitlementStore) ListEntitlements(ctx context.Context, payer string) ([]*Entitlement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entitlements []*Entitlement
+	for _, entitlement := range s.entitlements[entitlementPayer(payer)] {
+		found := *entitlement
+		entitlements = append(entitlements, &found)
+	}
+	sortEntitlements(entitlements)
+	return entitlements, nil
+}
+
+// Prune deletes entitlements that expired before now
+func (s *MemoryEntitlementStore) Prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for payer, entitlements := range s.entitlements {
+		for id, entitlement := range entitlements {
+			if !entitlement.Active(now) {
+				delete(entitlements, id)
+			}
+		}
+		if len(entitlements) == 0 {
+			delete(s.entitlements, payer)
+		}
+	}
+}
+
+// FileEntitlementStore keeps each payer's entitlements as a JSON file in a directory, so
+// entitlements survive restarts. Writes are atomic within one process only.
+type FileEntitlementStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileEntitlementStore creates a FileEntitlementStore in dir, which is created if needed
+func NewFileEntitlementStore(dir string) *FileEntitlementStore {
+	return &FileEntitlementStore{dir: dir}
+}
+
+// SaveEntitlement adds entitlement to its payer's file
+func (s *FileEntitlementStore) SaveEntitlement(ctx context.Context, entitlement *Entitlement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entitlements, err := s.read(entitlement.Payer)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, existing := range entitlements {
+		if entitlementID(existing) == entitlementID(entitlement) {
+			entitlements[i] = entitlement
+			replaced = true
+		}
+	}
+	if !replaced {
+		entitlements = append(entitlements, entitlement)
+	}
+
+	data, err := json.Marshal(entitlements)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(entitlement.Payer), data)
+}
+
+// ListEntitlements reads the payer's entitlements, oldest first
+func (s *FileEntitlementStore) ListEntitlements(ctx context.Context, payer string) ([]*Entitlement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entitlements, err := s.read(payer)
+	if err != nil {
+		return nil, err
+	}
+	sortEntitlements(entitlements)
+	return entitlements, nil
+}
+
+func (s *FileEntitlementStore) read(payer string) ([]*Entitlement, error) {
+	data, err := os.ReadFile(s.path(payer))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entitlements []*Entitlement
+	if err := json.Unmarshal(data, &entitlements); err != nil {
+		return nil, fmt.Errorf("invalid entitlements file for %s: %w", payer, err)
+	}
+	return entitlements, nil
+}
+
+// path names the payer's file by a hash of the payer, whose format depends on the network
+func (s *FileEntitlementStore) path(payer string) string {
+	sum := sha256.Sum256([]byte(entitlementPayer(payer)))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func sortEntitlements(entitlements []*Entitlement) {
+	sort.SliceStable(entitlements, func(i, j int) bool {
+		return entitlements[i].GrantedAt.Before(entitlements[j].GrantedAt)
+	})
+}
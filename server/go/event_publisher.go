@@ -0,0 +1,385 @@
+package xtended402
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BrokerMessage is a payment event ready for a message broker
+type BrokerMessage struct {
+	// ID is the event ID, for broker-side deduplication where supported
+	ID string
+
+	// Topic is the Kafka topic or NATS subject
+	Topic string
+
+	// Key partitions messages (the payer, so one payer's events stay ordered)
+	Key string
+
+	Value   []byte
+	Headers map[string]string
+}
+
+// EventBroker publishes messages to a message broker. Publish returns only once the broker
+// has durably accepted the message. See brokers/kafka and brokers/nats.
+type EventBroker interface {
+	Publish(ctx context.Context, msg BrokerMessage) error
+}
+
+// EventOutbox holds events until a broker has accepted them, so events survive broker outages
+// and (with a persistent outbox) restarts
+type EventOutbox interface {
+	AddEvent(ctx context.Context, event PaymentEvent) error
+	RemoveEvent(ctx context.Context, id string) error
+
+	// PendingEvents returns the events not yet removed, oldest first
+	PendingEvents(ctx context.Context) ([]PaymentEvent, error)
+}
+
+// EventPublisher publishes payment events to a message broker with at-least-once delivery:
+// each event is written to an outbox before publishing and removed only after the broker
+// accepts it. Consumers should deduplicate by event ID. Use Handle as a PaymentEventHandler:
+//
+//	publisher := xtended402.NewEventPublisher(kafka.New("localhost:9092"),
+//		xtended402.WithPublisherOutbox(xtended402.NewFileEventOutbox("/var/lib/shop/outbox")),
+//	)
+//	publisher.Recover(ctx) // republish events left over from the last run
+//	middleware := ginmw.PaymentMiddleware(routes, server, ginmw.WithEventHandler(publisher.Handle))
+type EventPublisher struct {
+	broker    EventBroker
+	outbox    EventOutbox
+	topic     func(event PaymentEvent) string
+	retry     RetryPolicy
+	clock     Clock
+	onFailure func(event PaymentEvent, err error)
+	dead      DeadLetterQueue
+
+	wg sync.WaitGroup
+}
+
+// PublisherOption configures an EventPublisher
+type PublisherOption func(*EventPublisher)
+
+// WithPublisherOutbox sets the outbox (default in memory, which loses pending events on restart)
+func WithPublisherOutbox(outbox EventOutbox) PublisherOption {
+	return func(p *EventPublisher) {
+		p.outbox = outbox
+	}
+}
+
+// WithPublisherTopic sets the topic or subject for each event (default "x402.<type>", e.g.
+// "x402.payment.settled")
+func WithPublisherTopic(topic func(event PaymentEvent) string) PublisherOption {
+	return func(p *EventPublisher) {
+		p.topic = topic
+	}
+}
+
+// WithPublisherRetry sets the publish retry policy (default 8 attempts, 10s timeout, 1s backoff
+// up to 1m)
+func WithPublisherRetry(policy RetryPolicy) PublisherOption {
+	return func(p *EventPublisher) {
+		p.retry = policy
+	}
+}
+
+// WithPublisherClock sets the clock for backoff delays and timeouts (default SystemClock)
+func WithPublisherClock(clock Clock) PublisherOption {
+	return func(p *EventPublisher) {
+		p.clock = ClockOrSystem(clock)
+	}
+}
+
+// WithPublisherFailureHandler is called when an event still isn't published after all
+// retries. The event stays in the outbox for the next Recover, unless a dead letter queue is
+// set.
+func WithPublisherFailureHandler(handler func(event PaymentEvent, err error)) PublisherOption {
+	return func(p *EventPublisher) {
+		p.onFailure = handler
+	}
+}
+
+// WithPublisherDeadLetters moves events that fail after all retries from the outbox to queue,
+// to be replayed with a DeadLetterReplayer once the broker recovers
+func WithPublisherDeadLetters(queue DeadLetterQueue) PublisherOption {
+	return func(p *EventPublisher) {
+		p.dead = queue
+	}
+}
+
+// NewEventPublisher creates an EventPublisher for broker
+func NewEventPublisher(broker EventBroker, opts ...PublisherOption) *EventPublisher {
+	p := &EventPublisher{
+		broker: broker,
+		outbox: NewMemoryEventOutbox(),
+		topic: func(event PaymentEvent) string {
+			return "x402." + string(event.Type)
+		},
+		retry: RetryPolicy{Attempts: 8, Timeout: 10 * time.Second, Backoff: time.Second, MaxBackoff: time.Minute},
+		clock: SystemClock,
+		onFailure: func(event PaymentEvent, err error) {
+			fmt.Printf("Warning: publishing %s %s failed: %v\n", event.Type, event.ID, err)
+		},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Handle adds event to the outbox and publishes it in the background
+func (p *EventPublisher) Handle(ctx context.Context, event PaymentEvent) {
+	if err := p.outbox.AddEvent(ctx, event); err != nil {
+		fmt.Printf("Warning: failed to add %s to the event outbox: %v\n", event.ID, err)
+	}
+	p.publishAsync(ctx, event)
+}
+
+// Recover publishes the events left in the outbox, e.g. at startup after a crash or broker
+// outage. Publishing happens in the background; use Wait to block until it finishes.
+func (p *EventPublisher) Recover(ctx context.Context) error {
+	events, err := p.outbox.PendingEvents(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read event outbox: %w", err)
+	}
+	for _, event := range events {
+		p.publishAsync(ctx, event)
+	}
+	return nil
+}
+
+// Publish publishes event according to the retry policy and removes it from the outbox
+func (p *EventPublisher) Publish(ctx context.Context, event PaymentEvent) error {
+	msg, err := p.message(event)
+	if err != nil {
+		return err
+	}
+
+	err = retry(ctx, p.clock, p.retry, func(ctx context.Context) error {
+		return p.broker.Publish(ctx, msg)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := p.outbox.RemoveEvent(ctx, event.ID); err != nil {
+		// The event will be published again on Recover; consumers deduplicate by ID
+		fmt.Printf("Warning: failed to remove %s from the event outbox: %v\n", event.ID, err)
+	}
+	return nil
+}
+
+// Wait blocks until in-flight publishes finish, e.g. during graceful shutdown
+func (p *EventPublisher) Wait() {
+	p.wg.Wait()
+}
+
+func (p *EventPublisher) publishAsync(ctx context.Context, event PaymentEvent) {
+	ctx = context.WithoutCancel(ctx)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if err := p.Publish(ctx, event); err != nil {
+			p.deadLetter(ctx, event, err)
+			p.onFailure(event, err)
+		}
+	}()
+}
+
+// DeadLetterSource returns DeadLetterPublisher
+func (p *EventPublisher) DeadLetterSource() string {
+	return DeadLetterPublisher
+}
+
+// Redeliver publishes a dead letter's event again
+func (p *EventPublisher) Redeliver(ctx context.Context, letter *DeadLetter) error {
+	return p.Publish(ctx, letter.Event)
+}
+
+func (p *EventPublisher) deadLetter(ctx context.Context, event PaymentEvent, err error) {
+	if p.dead == nil {
+		return
+	}
+	if addErr := p.dead.AddDeadLetter(ctx, newDeadLetter(p.clock, DeadLetterPublisher, p.topic(event), event, err)); addErr != nil {
+		fmt.Printf("Warning: failed to dead-letter %s: %v\n", event.ID, addErr)
+		return
+	}
+	if removeErr := p.outbox.RemoveEvent(ctx, event.ID); removeErr != nil {
+		fmt.Printf("Warning: failed to remove %s from the event outbox: %v\n", event.ID, removeErr)
+	}
+}
+
+func (p *EventPublisher) message(event PaymentEvent) (BrokerMessage, error) {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return BrokerMessage{}, permanent(fmt.Errorf("failed to encode event: %w", err))
+	}
+
+	key := event.Payer
+	if key == "" {
+		key = event.ID
+	}
+
+	return BrokerMessage{
+		ID:    event.ID,
+		Topic: p.topic(event),
+		Key:   key,
+		Value: value,
+		Headers: map[string]string{
+			WebhookEventHeader:    string(event.Type),
+			WebhookDeliveryHeader: event.ID,
+		},
+	}, nil
+}
+
+// ============================================================================
+// Outboxes
+// ============================================================================
+
+// MemoryEventOutbox is an in-process EventOutbox. Pending events are lost on restart.
+type MemoryEventOutbox struct {
+	mu     sync.Mutex
+	events map[string]PaymentEvent
+}
+
+// NewMemoryEventOutbox creates an empty MemoryEventOutbox
+func NewMemoryEventOutbox() *MemoryEventOutbox {
+	return &MemoryEventOutbox{events: make(map[string]PaymentEvent)}
+}
+
+// AddEvent stores event
+func (o *MemoryEventOutbox) AddEvent(ctx context.Context, event PaymentEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.events[event.ID] = event
+	return nil
+}
+
+// RemoveEvent deletes the event with id
+func (o *MemoryEventOutbox) RemoveEvent(ctx context.Context, id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.events, id)
+	return nil
+}
+
+// PendingEvents returns the stored events, oldest first
+func (o *MemoryEventOutbox) PendingEvents(ctx context.Context) ([]PaymentEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	events := make([]PaymentEvent, 0, len(o.events))
+	for _, event := range o.events {
+		events = append(events, event)
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+// FileEventOutbox keeps each pending event as a JSON file in a directory, so events survive
+// restarts
+type FileEventOutbox struct {
+	dir string
+}
+
+// NewFileEventOutbox creates a FileEventOutbox in dir, which is created if needed
+func NewFileEventOutbox(dir string) *FileEventOutbox {
+	return &FileEventOutbox{dir: dir}
+}
+
+// AddEvent writes event to its file
+func (o *FileEventOutbox) AddEvent(ctx context.Context, event PaymentEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(o.path(event.ID), data)
+}
+
+// RemoveEvent deletes the event's file
+func (o *FileEventOutbox) RemoveEvent(ctx context.Context, id string) error {
+	err := os.Remove(o.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// PendingEvents reads the stored events, oldest first
+func (o *FileEventOutbox) PendingEvents(ctx context.Context) ([]PaymentEvent, error) {
+	paths, err := filepath.Glob(filepath.Join(o.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]PaymentEvent, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // published meanwhile
+		}
+		if err != nil {
+			return nil, err
+		}
+		var event PaymentEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("invalid outbox file %s: %w", path, err)
+		}
+		events = append(events, event)
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+func (o *FileEventOutbox) path(id string) string {
+	return filepath.Join(o.dir, filepath.Base(id)+".json")
+}
+
+// writeFileAtomic writes data to path via a temporary file, so a crash never leaves a partial
+// file behind
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func sortEvents(events []PaymentEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].ID < events[j].ID
+		}
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+}
@@ -0,0 +1,159 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	"github.com/coinbase/x402/go/mechanisms/evm"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// PaymentEventType names a payment lifecycle event
+type PaymentEventType string
+
+const (
+	// EventPaymentSettled is emitted after a payment settles on-chain
+	EventPaymentSettled PaymentEventType = "payment.settled"
+
+	// EventPaymentFailed is emitted when a verified payment fails to settle
+	EventPaymentFailed PaymentEventType = "payment.failed"
+
+	// EventPaymentRefunded is emitted by the application after it refunds a payment
+	EventPaymentRefunded PaymentEventType = "payment.refunded"
+)
+
+// PaymentEvent describes a change in a payment's lifecycle
+type PaymentEvent struct {
+	// ID uniquely identifies the event; receivers can use it to deduplicate deliveries
+	ID        string           `json:"id"`
+	Type      PaymentEventType `json:"type"`
+	CreatedAt time.Time        `json:"createdAt"`
+
+	// Resource is the paid request, e.g. "POST /api/purchase"
+	Resource string `json:"resource,omitempty"`
+
+	Scheme  string       `json:"scheme,omitempty"`
+	Network x402.Network `json:"network,omitempty"`
+	Asset   string       `json:"asset,omitempty"`
+	Amount  string       `json:"amount,omitempty"`
+	PayTo   string       `json:"payTo,omitempty"`
+	Payer   string       `json:"payer,omitempty"`
+
+	// Transaction is the settlement (or refund) transaction hash
+	Transaction string `json:"transaction,omitempty"`
+
+	// ErrorReason explains a payment.failed event
+	ErrorReason string `json:"errorReason,omitempty"`
+
+	// Metadata carries application data such as an order ID
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// PaymentEventHandler receives payment lifecycle events. Handlers run on the request path,
+// so slow work (like webhook delivery) should be done asynchronously.
+type PaymentEventHandler func(ctx context.Context, event PaymentEvent)
+
+// NewPaymentEvent creates an event of eventType for a payment against requirements, with a
+// fresh ID and CreatedAt from clock (nil uses SystemClock)
+func NewPaymentEvent(eventType PaymentEventType, clock Clock, payload *x402types.PaymentPayload, requirements *x402types.PaymentRequirements) PaymentEvent {
+	event := PaymentEvent{
+		ID:        NewEventID(),
+		Type:      eventType,
+		CreatedAt: ClockOrSystem(clock).Now().UTC(),
+	}
+
+	if requirements != nil {
+		event.Scheme = requirements.Scheme
+		event.Network = x402.Network(requirements.Network)
+		event.Asset = requirements.Asset
+		event.Amount = requirements.Amount
+		event.PayTo = requirements.PayTo
+	}
+	event.Payer = PayerOf(payload)
+
+	return event
+}
+
+// PayerOf returns the payer address from an EVM exact payload, or ""
+func PayerOf(payload *x402types.PaymentPayload) string {
+	if payload == nil {
+		return ""
+	}
+	authorization, _ := payload.Payload["authorization"].(map[string]interface{})
+	payer, _ := authorization["from"].(string)
+	return payer
+}
+
+// NewEventID returns a random event ID such as "evt_3f9a…"
+func NewEventID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return "evt_" + hex.EncodeToString(id)
+}
+
+// DisplayAmount formats the event's amount for people, e.g. "1.50 USDC". Tokens the x402 EVM
+// mechanism doesn't know are shown in smallest units with their address.
+func (e PaymentEvent) DisplayAmount() string {
+	return FormatTokenAmount(e.Network, e.Asset, e.Amount)
+}
+
+// FormatTokenAmount formats amount (in smallest units) of asset on network with the token's
+// symbol and decimals, keeping at least two decimal places. Fiat amounts are already in major
+// units and are shown with their currency code.
+func FormatTokenAmount(network x402.Network, asset, amount string) string {
+	if network == FiatNetwork {
+		return strings.TrimSpace(amount + " " + strings.ToUpper(asset))
+	}
+
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return strings.TrimSpace(amount + " " + asset)
+	}
+
+	symbol, decimals, known := LookupToken(network, asset)
+	if !known {
+		return strings.TrimSpace(amount + " " + asset)
+	}
+
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	whole, frac := new(big.Int).QuoRem(value, unit, new(big.Int))
+
+	fraction := ""
+	if decimals > 0 {
+		fraction = strings.TrimRight(leftPad(frac.String(), decimals), "0")
+		for len(fraction) < 2 && len(fraction) < decimals {
+			fraction += "0"
+		}
+	}
+	if fraction == "" {
+		return whole.String() + " " + symbol
+	}
+	return whole.String() + "." + fraction + " " + symbol
+}
+
+// LookupToken finds asset's symbol and decimals among the network's supported assets. asset
+// may be a token address or symbol.
+func LookupToken(network x402.Network, asset string) (symbol string, decimals int, ok bool) {
+	config, err := evm.GetNetworkConfig(string(network))
+	if err != nil {
+		return "", 0, false
+	}
+	for sym, info := range config.SupportedAssets {
+		if strings.EqualFold(info.Address, asset) || strings.EqualFold(sym, asset) {
+			return sym, info.Decimals, true
+		}
+	}
+	return "", 0, false
+}
+
+func leftPad(digits string, width int) string {
+	if len(digits) >= width {
+		return digits
+	}
+	return strings.Repeat("0", width-len(digits)) + digits
+}
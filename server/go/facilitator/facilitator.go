@@ -0,0 +1,151 @@
+// Package facilitator is a minimal self-hosted x402 facilitator. It verifies and settles
+// exact-scheme EVM payments directly on-chain via ERC-3009 transferWithAuthorization, so
+// operators can accept payments without depending on a hosted facilitator.
+//
+// A Facilitator implements x402.FacilitatorClient and can be passed straight to the
+// middleware for in-process use, or served over HTTP with Handler for other servers.
+package facilitator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	x402 "github.com/coinbase/x402/go"
+	"github.com/coinbase/x402/go/mechanisms/evm"
+	evmfacilitator "github.com/coinbase/x402/go/mechanisms/evm/exact/facilitator"
+)
+
+// Facilitator verifies and settles payments in-process
+type Facilitator struct {
+	facilitator *x402.X402Facilitator
+}
+
+// Config configures a Facilitator
+type Config struct {
+	// DeployERC4337WithEIP6492 deploys counterfactual smart wallets during settlement
+	DeployERC4337WithEIP6492 bool
+}
+
+// New creates a Facilitator settling exact EVM payments on networks with signer.
+// Use one signer per chain; register additional chains with Register.
+func New(signer evm.FacilitatorEvmSigner, networks []x402.Network, config *Config) *Facilitator {
+	f := &Facilitator{facilitator: x402.Newx402Facilitator()}
+	return f.Register(signer, networks, config)
+}
+
+// Register adds exact EVM settlement for networks using signer
+func (f *Facilitator) Register(signer evm.FacilitatorEvmSigner, networks []x402.Network, config *Config) *Facilitator {
+	schemeConfig := &evmfacilitator.ExactEvmSchemeConfig{}
+	if config != nil {
+		schemeConfig.DeployERC4337WithEIP6492 = config.DeployERC4337WithEIP6492
+	}
+
+	f.facilitator.Register(networks, evmfacilitator.NewExactEvmScheme(signer, schemeConfig))
+	return f
+}
+
+// X402Facilitator returns the underlying x402 facilitator for registering hooks or extensions
+func (f *Facilitator) X402Facilitator() *x402.X402Facilitator {
+	return f.facilitator
+}
+
+// Verify checks the payment signature, amount, recipient, validity window, and payer balance.
+// Invalid payments return an *x402.VerifyError so the resource server rejects them.
+func (f *Facilitator) Verify(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.VerifyResponse, error) {
+	return f.facilitator.Verify(ctx, payloadBytes, requirementsBytes)
+}
+
+// Settle submits transferWithAuthorization on-chain and waits for the receipt
+func (f *Facilitator) Settle(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.SettleResponse, error) {
+	return f.facilitator.Settle(ctx, payloadBytes, requirementsBytes)
+}
+
+// GetSupported returns the registered schemes and networks
+func (f *Facilitator) GetSupported(ctx context.Context) (x402.SupportedResponse, error) {
+	return f.facilitator.GetSupported(), nil
+}
+
+// ============================================================================
+// HTTP Server
+// ============================================================================
+
+type facilitatorRequest struct {
+	X402Version         int             `json:"x402Version"`
+	PaymentPayload      json.RawMessage `json:"paymentPayload"`
+	PaymentRequirements json.RawMessage `json:"paymentRequirements"`
+}
+
+// Handler serves the facilitator's /verify, /settle, and /supported endpoints using the same
+// wire format as hosted facilitators, so x402http.NewHTTPFacilitatorClient can point at it.
+// Mount it in Gin with r.Any("/facilitator/*path", gin.WrapH(http.StripPrefix("/facilitator", f.Handler()))).
+func (f *Facilitator) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /verify", func(w http.ResponseWriter, r *http.Request) {
+		var req facilitatorRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+
+		resp, err := f.Verify(r.Context(), req.PaymentPayload, req.PaymentRequirements)
+		var verifyErr *x402.VerifyError
+		if errors.As(err, &verifyErr) {
+			writeJSON(w, http.StatusOK, x402.VerifyResponse{
+				IsValid:       false,
+				InvalidReason: verifyErr.Reason,
+				Payer:         verifyErr.Payer,
+			})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	mux.HandleFunc("POST /settle", func(w http.ResponseWriter, r *http.Request) {
+		var req facilitatorRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+
+		resp, err := f.Settle(r.Context(), req.PaymentPayload, req.PaymentRequirements)
+		var settleErr *x402.SettleError
+		if errors.As(err, &settleErr) {
+			writeJSON(w, http.StatusOK, x402.SettleResponse{
+				Success:     false,
+				ErrorReason: settleErr.Reason,
+				Payer:       settleErr.Payer,
+				Transaction: settleErr.Transaction,
+				Network:     settleErr.Network,
+			})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	mux.HandleFunc("GET /supported", func(w http.ResponseWriter, r *http.Request) {
+		supported, _ := f.GetSupported(r.Context())
+		writeJSON(w, http.StatusOK, supported)
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		fmt.Printf("Warning: failed to encode facilitator response: %v\n", err)
+	}
+}
@@ -0,0 +1,263 @@
+package facilitator
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coinbase/x402/go/mechanisms/evm"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const erc20BalanceOfABI = `[{"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+// Signer implements evm.FacilitatorEvmSigner against a JSON-RPC endpoint using a single
+// private key. The key's account pays gas for settlement transactions.
+type Signer struct {
+	privateKey *ecdsa.PrivateKey
+	address    common.Address
+	client     *ethclient.Client
+	chainID    *big.Int
+
+	pollInterval time.Duration
+	sendMu       sync.Mutex // Serializes nonce assignment across concurrent settlements
+}
+
+// NewSigner connects to rpcURL and creates a Signer for privateKeyHex (with or without 0x prefix)
+func NewSigner(ctx context.Context, rpcURL string, privateKeyHex string) (*Signer, error) {
+	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	client, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RPC: %w", err)
+	}
+
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	return &Signer{
+		privateKey:   privateKey,
+		address:      crypto.PubkeyToAddress(privateKey.PublicKey),
+		client:       client,
+		chainID:      chainID,
+		pollInterval: time.Second,
+	}, nil
+}
+
+// Close closes the RPC connection
+func (s *Signer) Close() {
+	s.client.Close()
+}
+
+// Address returns the facilitator account address
+func (s *Signer) Address() common.Address {
+	return s.address
+}
+
+// GetAddresses returns the facilitator account address
+func (s *Signer) GetAddresses() []string {
+	return []string{s.address.Hex()}
+}
+
+// GetChainID returns the chain ID of the connected network
+func (s *Signer) GetChainID(ctx context.Context) (*big.Int, error) {
+	return s.chainID, nil
+}
+
+// GetCode returns the bytecode at address (empty for EOAs)
+func (s *Signer) GetCode(ctx context.Context, address string) ([]byte, error) {
+	return s.client.CodeAt(ctx, common.HexToAddress(address), nil)
+}
+
+// GetBalance returns the ERC-20 balance of address for tokenAddress
+func (s *Signer) GetBalance(ctx context.Context, address string, tokenAddress string) (*big.Int, error) {
+	result, err := s.ReadContract(ctx, tokenAddress, []byte(erc20BalanceOfABI), "balanceOf", common.HexToAddress(address))
+	if err != nil {
+		return nil, err
+	}
+
+	balance, ok := result.(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected balanceOf result type %T", result)
+	}
+	return balance, nil
+}
+
+// ReadContract calls a view function, returning the single output value or a slice of outputs
+func (s *Signer) ReadContract(ctx context.Context, address string, abiBytes []byte, functionName string, args ...interface{}) (interface{}, error) {
+	parsed, err := abi.JSON(bytes.NewReader(abiBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ABI: %w", err)
+	}
+
+	data, err := parsed.Pack(functionName, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s call: %w", functionName, err)
+	}
+
+	to := common.HexToAddress(address)
+	output, err := s.client.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s call failed: %w", functionName, err)
+	}
+
+	values, err := parsed.Unpack(functionName, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s result: %w", functionName, err)
+	}
+
+	if len(values) == 1 {
+		return values[0], nil
+	}
+	return values, nil
+}
+
+// WriteContract encodes a contract call and submits it as a transaction, returning the tx hash
+func (s *Signer) WriteContract(ctx context.Context, address string, abiBytes []byte, functionName string, args ...interface{}) (string, error) {
+	parsed, err := abi.JSON(bytes.NewReader(abiBytes))
+	if err != nil {
+		return "", fmt.Errorf("invalid ABI: %w", err)
+	}
+
+	data, err := parsed.Pack(functionName, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s call: %w", functionName, err)
+	}
+
+	return s.SendTransaction(ctx, address, data)
+}
+
+// SendTransaction signs and broadcasts a transaction with the given calldata: an EIP-1559
+// transaction, or a legacy one on chains whose blocks have no base fee
+func (s *Signer) SendTransaction(ctx context.Context, to string, data []byte) (string, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	toAddress := common.HexToAddress(to)
+
+	nonce, err := s.client.PendingNonceAt(ctx, s.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to get nonce: %w", err)
+	}
+
+	gasLimit, err := s.client.EstimateGas(ctx, ethereum.CallMsg{From: s.address, To: &toAddress, Data: data})
+	if err != nil {
+		return "", fmt.Errorf("failed to estimate gas: %w", err)
+	}
+
+	txData, err := s.feeTx(ctx, nonce, gasLimit, &toAddress, data)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := types.SignNewTx(s.privateKey, types.LatestSignerForChainID(s.chainID), txData)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	if err := s.client.SendTransaction(ctx, tx); err != nil {
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+
+	return tx.Hash().Hex(), nil
+}
+
+// feeTx prices the transaction as an EIP-1559 transaction, or a legacy one on chains whose
+// blocks have no base fee
+func (s *Signer) feeTx(ctx context.Context, nonce, gasLimit uint64, to *common.Address, data []byte) (types.TxData, error) {
+	head, err := s.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest block: %w", err)
+	}
+
+	if head.BaseFee == nil {
+		gasPrice, err := s.client.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get gas price: %w", err)
+		}
+		return &types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: gasPrice,
+			Gas:      gasLimit,
+			To:       to,
+			Data:     data,
+		}, nil
+	}
+
+	tipCap, err := s.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas tip: %w", err)
+	}
+	feeCap := new(big.Int).Add(tipCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+
+	return &types.DynamicFeeTx{
+		ChainID:   s.chainID,
+		Nonce:     nonce,
+		GasTipCap: tipCap,
+		GasFeeCap: feeCap,
+		Gas:       gasLimit,
+		To:        to,
+		Data:      data,
+	}, nil
+}
+
+// WaitForTransactionReceipt polls until the transaction is mined or ctx is done
+func (s *Signer) WaitForTransactionReceipt(ctx context.Context, txHash string) (*evm.TransactionReceipt, error) {
+	hash := common.HexToHash(txHash)
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := s.client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			return &evm.TransactionReceipt{
+				Status:      receipt.Status,
+				BlockNumber: receipt.BlockNumber.Uint64(),
+				TxHash:      receipt.TxHash.Hex(),
+			}, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, fmt.Errorf("failed to get receipt: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// VerifyTypedData verifies an EIP-712 signature from an EOA
+func (s *Signer) VerifyTypedData(
+	ctx context.Context,
+	address string,
+	domain evm.TypedDataDomain,
+	types map[string][]evm.TypedDataField,
+	primaryType string,
+	message map[string]interface{},
+	signature []byte,
+) (bool, error) {
+	hash, err := evm.HashTypedData(domain, types, primaryType, message)
+	if err != nil {
+		return false, err
+	}
+	return evm.VerifyEOASignature(hash, signature, common.HexToAddress(address))
+}
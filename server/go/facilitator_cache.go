@@ -0,0 +1,157 @@
+package xtended402
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+)
+
+// CachedCapabilities is a persisted facilitator GetSupported result
+type CachedCapabilities struct {
+	Supported x402.SupportedResponse `json:"supported"`
+	FetchedAt time.Time              `json:"fetchedAt"`
+}
+
+// CapabilityStore persists facilitator capabilities across restarts.
+// LoadCapabilities returns nil, nil when nothing has been stored yet.
+type CapabilityStore interface {
+	LoadCapabilities(ctx context.Context) (*CachedCapabilities, error)
+	SaveCapabilities(ctx context.Context, capabilities *CachedCapabilities) error
+}
+
+// CachedFacilitator wraps a FacilitatorClient and caches its supported-schemes result in a
+// CapabilityStore. Within the TTL, GetSupported is served from the cache without contacting
+// the facilitator, so restarts and serverless cold starts don't block on sync. When a refresh
+// fails, the last cached capabilities are returned regardless of age.
+type CachedFacilitator struct {
+	x402.FacilitatorClient
+	store CapabilityStore
+	ttl   time.Duration
+	clock Clock
+
+	mu     sync.Mutex
+	cached *CachedCapabilities
+}
+
+// CacheOption configures a CachedFacilitator
+type CacheOption func(*CachedFacilitator)
+
+// WithCapabilityTTL sets how long cached capabilities are used before refreshing (default 1h)
+func WithCapabilityTTL(ttl time.Duration) CacheOption {
+	return func(f *CachedFacilitator) {
+		f.ttl = ttl
+	}
+}
+
+// WithCacheClock sets the clock used to age cached capabilities (default SystemClock)
+func WithCacheClock(clock Clock) CacheOption {
+	return func(f *CachedFacilitator) {
+		f.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewCachedFacilitator creates a CachedFacilitator persisting capabilities to store
+func NewCachedFacilitator(client x402.FacilitatorClient, store CapabilityStore, opts ...CacheOption) *CachedFacilitator {
+	f := &CachedFacilitator{
+		FacilitatorClient: client,
+		store:             store,
+		ttl:               time.Hour,
+		clock:             SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// GetSupported returns cached capabilities while fresh, otherwise syncs with the facilitator
+// and falls back to stale capabilities if the sync fails
+func (f *CachedFacilitator) GetSupported(ctx context.Context) (x402.SupportedResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cached == nil {
+		cached, err := f.store.LoadCapabilities(ctx)
+		if err != nil {
+			fmt.Printf("Warning: failed to load cached facilitator capabilities: %v\n", err)
+		}
+		f.cached = cached
+	}
+
+	if f.cached != nil && f.clock.Now().Sub(f.cached.FetchedAt) < f.ttl {
+		return f.cached.Supported, nil
+	}
+
+	supported, err := f.FacilitatorClient.GetSupported(ctx)
+	if err != nil {
+		if f.cached != nil {
+			return f.cached.Supported, nil
+		}
+		return x402.SupportedResponse{}, err
+	}
+
+	f.cached = &CachedCapabilities{Supported: supported, FetchedAt: f.clock.Now()}
+	if err := f.store.SaveCapabilities(ctx, f.cached); err != nil {
+		fmt.Printf("Warning: failed to persist facilitator capabilities: %v\n", err)
+	}
+
+	return supported, nil
+}
+
+// ============================================================================
+// File Store
+// ============================================================================
+
+// FileCapabilityStore stores capabilities as JSON in a single file
+type FileCapabilityStore struct {
+	Path string
+}
+
+// NewFileCapabilityStore creates a FileCapabilityStore writing to path
+func NewFileCapabilityStore(path string) *FileCapabilityStore {
+	return &FileCapabilityStore{Path: path}
+}
+
+// LoadCapabilities reads the capabilities file, returning nil if it doesn't exist
+func (s *FileCapabilityStore) LoadCapabilities(ctx context.Context) (*CachedCapabilities, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cached CachedCapabilities
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, fmt.Errorf("invalid capabilities file %s: %w", s.Path, err)
+	}
+	return &cached, nil
+}
+
+// SaveCapabilities atomically writes the capabilities file
+func (s *FileCapabilityStore) SaveCapabilities(ctx context.Context, capabilities *CachedCapabilities) error {
+	data, err := json.Marshal(capabilities)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
@@ -0,0 +1,188 @@
+package xtended402
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// CDPFacilitatorURL is the Coinbase Developer Platform x402 facilitator
+const CDPFacilitatorURL = "https://api.cdp.coinbase.com/platform/v2/x402"
+
+// NewCDPFacilitatorClient creates a facilitator client for the Coinbase Developer Platform,
+// authenticating every request with a short-lived JWT signed by the CDP API key.
+// apiKeySecret may be an Ed25519 key (base64) or an EC private key (PEM).
+func NewCDPFacilitatorClient(apiKeyID, apiKeySecret string) (*x402http.HTTPFacilitatorClient, error) {
+	auth, err := NewCDPAuthProvider(apiKeyID, apiKeySecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return x402http.NewHTTPFacilitatorClient(&x402http.FacilitatorConfig{
+		URL:          CDPFacilitatorURL,
+		AuthProvider: auth,
+		Identifier:   "cdp",
+	}), nil
+}
+
+// CDPAuthProvider implements x402http.AuthProvider for the CDP facilitator
+type CDPAuthProvider struct {
+	keyID   string
+	signer  crypto.Signer
+	alg     string
+	baseURL *url.URL
+}
+
+// NewCDPAuthProvider parses a CDP API key for signing facilitator requests
+func NewCDPAuthProvider(apiKeyID, apiKeySecret string) (*CDPAuthProvider, error) {
+	if apiKeyID == "" || apiKeySecret == "" {
+		return nil, errors.New("CDP API key ID and secret are required")
+	}
+
+	signer, alg, err := parseCDPKey(apiKeySecret)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL, _ := url.Parse(CDPFacilitatorURL)
+	return &CDPAuthProvider{
+		keyID:   apiKeyID,
+		signer:  signer,
+		alg:     alg,
+		baseURL: baseURL,
+	}, nil
+}
+
+// GetAuthHeaders signs a fresh JWT for each facilitator endpoint
+func (p *CDPAuthProvider) GetAuthHeaders(ctx context.Context) (x402http.AuthHeaders, error) {
+	verify, err := p.bearer("POST", "/verify")
+	if err != nil {
+		return x402http.AuthHeaders{}, err
+	}
+	settle, err := p.bearer("POST", "/settle")
+	if err != nil {
+		return x402http.AuthHeaders{}, err
+	}
+	supported, err := p.bearer("GET", "/supported")
+	if err != nil {
+		return x402http.AuthHeaders{}, err
+	}
+
+	return x402http.AuthHeaders{
+		Verify:    map[string]string{"Authorization": verify},
+		Settle:    map[string]string{"Authorization": settle},
+		Supported: map[string]string{"Authorization": supported},
+	}, nil
+}
+
+// bearer creates an Authorization header value scoped to one request method and path
+func (p *CDPAuthProvider) bearer(method, path string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	now := time.Now().Unix()
+	header := map[string]interface{}{
+		"alg":   p.alg,
+		"typ":   "JWT",
+		"kid":   p.keyID,
+		"nonce": hex.EncodeToString(nonce),
+	}
+	claims := map[string]interface{}{
+		"sub": p.keyID,
+		"iss": "cdp",
+		"nbf": now,
+		"exp": now + 120,
+		"uri": fmt.Sprintf("%s %s%s%s", method, p.baseURL.Host, p.baseURL.Path, path),
+	}
+
+	token, err := signJWT(p.signer, p.alg, header, claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign CDP JWT: %w", err)
+	}
+	return "Bearer " + token, nil
+}
+
+// parseCDPKey accepts an EC private key PEM (ES256) or a base64 Ed25519 key (EdDSA)
+func parseCDPKey(secret string) (crypto.Signer, string, error) {
+	// Keys copied from JSON often carry escaped newlines
+	secret = strings.ReplaceAll(strings.TrimSpace(secret), `\n`, "\n")
+
+	if block, _ := pem.Decode([]byte(secret)); block != nil {
+		if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+			return key, "ES256", nil
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid CDP API key PEM: %w", err)
+		}
+		switch key := key.(type) {
+		case *ecdsa.PrivateKey:
+			return key, "ES256", nil
+		case ed25519.PrivateKey:
+			return key, "EdDSA", nil
+		}
+		return nil, "", fmt.Errorf("unsupported CDP API key type %T", key)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, "", errors.New("CDP API key secret must be a PEM EC key or base64 Ed25519 key")
+	}
+	switch len(raw) {
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), "EdDSA", nil
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), "EdDSA", nil
+	}
+	return nil, "", fmt.Errorf("invalid Ed25519 key length %d", len(raw))
+}
+
+func signJWT(signer crypto.Signer, alg string, header, claims map[string]interface{}) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	switch key := signer.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signingInput))
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return "", err
+		}
+		// JWS ES256 signatures are fixed-width r || s
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	default:
+		return "", fmt.Errorf("unsupported signing key for %s", alg)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
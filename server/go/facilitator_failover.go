@@ -0,0 +1,293 @@
+package xtended402
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+)
+
+// FailoverFacilitator is a FacilitatorClient that spreads calls across several facilitators.
+// Facilitators are tried in priority order (the order given), skipping any that don't support
+// the requested scheme/network or that are marked unhealthy after repeated failures.
+// Unhealthy facilitators are retried after a cooldown and recover on their first success.
+//
+// Settlement fails over only while the payment can't have been submitted, i.e. when the
+// facilitator couldn't be reached at all (a refused connection). A settlement rejection
+// (*x402.SettleError) is returned as-is, and any other error, like a timeout or a 5xx, isn't
+// handed to the next facilitator: the first may have broadcast the payment.
+type FailoverFacilitator struct {
+	entries          []*facilitatorEntry
+	failureThreshold int
+	cooldown         time.Duration
+	clock            Clock
+}
+
+// FailoverOption configures a FailoverFacilitator
+type FailoverOption func(*FailoverFacilitator)
+
+// WithFailureThreshold sets how many consecutive failures mark a facilitator unhealthy (default 3)
+func WithFailureThreshold(n int) FailoverOption {
+	return func(f *FailoverFacilitator) {
+		f.failureThreshold = n
+	}
+}
+
+// WithRecoveryCooldown sets how long an unhealthy facilitator is skipped before being retried (default 30s)
+func WithRecoveryCooldown(d time.Duration) FailoverOption {
+	return func(f *FailoverFacilitator) {
+		f.cooldown = d
+	}
+}
+
+// WithFailoverClock sets the clock used for recovery cooldowns (default SystemClock)
+func WithFailoverClock(clock Clock) FailoverOption {
+	return func(f *FailoverFacilitator) {
+		f.clock = ClockOrSystem(clock)
+	}
+}
+
+// FacilitatorHealth reports the health state of one facilitator in a FailoverFacilitator
+type FacilitatorHealth struct {
+	Priority            int
+	Healthy             bool
+	ConsecutiveFailures int
+	LastError           string
+	UnhealthyUntil      time.Time
+}
+
+type facilitatorEntry struct {
+	client x402.FacilitatorClient
+
+	mu                  sync.Mutex
+	supported           map[string]bool // "network|scheme" keys from GetSupported
+	consecutiveFailures int
+	lastError           error
+	unhealthyUntil      time.Time
+}
+
+// NewFailoverFacilitator creates a FailoverFacilitator over clients in priority order
+func NewFailoverFacilitator(clients []x402.FacilitatorClient, opts ...FailoverOption) *FailoverFacilitator {
+	f := &FailoverFacilitator{
+		failureThreshold: 3,
+		cooldown:         30 * time.Second,
+		clock:            SystemClock,
+	}
+	for _, client := range clients {
+		f.entries = append(f.entries, &facilitatorEntry{client: client})
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// Verify verifies a payment with the first available facilitator supporting its scheme/network
+func (f *FailoverFacilitator) Verify(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.VerifyResponse, error) {
+	var result *x402.VerifyResponse
+	err := f.try(ctx, requirementsBytes, false, func(client x402.FacilitatorClient) error {
+		resp, err := client.Verify(ctx, payloadBytes, requirementsBytes)
+		result = resp
+		return err
+	})
+	return result, err
+}
+
+// Settle settles a payment with the first available facilitator supporting its scheme/network
+func (f *FailoverFacilitator) Settle(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.SettleResponse, error) {
+	var result *x402.SettleResponse
+	err := f.try(ctx, requirementsBytes, true, func(client x402.FacilitatorClient) error {
+		resp, err := client.Settle(ctx, payloadBytes, requirementsBytes)
+		result = resp
+		return err
+	})
+	return result, err
+}
+
+// GetSupported queries every facilitator and merges their supported kinds.
+// Fails only if no facilitator responds.
+func (f *FailoverFacilitator) GetSupported(ctx context.Context) (x402.SupportedResponse, error) {
+	merged := x402.SupportedResponse{Signers: map[string][]string{}}
+	seenKinds := map[string]bool{}
+	seenExtensions := map[string]bool{}
+	var errs []error
+
+	for _, entry := range f.entries {
+		supported, err := entry.client.GetSupported(ctx)
+		if err != nil {
+			entry.recordFailure(err, f.failureThreshold, f.clock.Now().Add(f.cooldown))
+			errs = append(errs, err)
+			continue
+		}
+		entry.recordSuccess()
+
+		kinds := make(map[string]bool, len(supported.Kinds))
+		for _, kind := range supported.Kinds {
+			key := kindKey(kind.Network, kind.Scheme)
+			kinds[key] = true
+			versionedKey := fmt.Sprintf("%d|%s", kind.X402Version, key)
+			if !seenKinds[versionedKey] {
+				seenKinds[versionedKey] = true
+				merged.Kinds = append(merged.Kinds, kind)
+			}
+		}
+		entry.mu.Lock()
+		entry.supported = kinds
+		entry.mu.Unlock()
+
+		for _, ext := range supported.Extensions {
+			if !seenExtensions[ext] {
+				seenExtensions[ext] = true
+				merged.Extensions = append(merged.Extensions, ext)
+			}
+		}
+		for family, signers := range supported.Signers {
+			merged.Signers[family] = append(merged.Signers[family], signers...)
+		}
+	}
+
+	if len(errs) == len(f.entries) && len(errs) > 0 {
+		return x402.SupportedResponse{}, fmt.Errorf("all facilitators failed: %w", errors.Join(errs...))
+	}
+	return merged, nil
+}
+
+// Health returns the current health state of each facilitator in priority order
+func (f *FailoverFacilitator) Health() []FacilitatorHealth {
+	now := f.clock.Now()
+	health := make([]FacilitatorHealth, len(f.entries))
+	for i, entry := range f.entries {
+		entry.mu.Lock()
+		health[i] = FacilitatorHealth{
+			Priority:            i,
+			Healthy:             !now.Before(entry.unhealthyUntil),
+			ConsecutiveFailures: entry.consecutiveFailures,
+			UnhealthyUntil:      entry.unhealthyUntil,
+		}
+		if entry.lastError != nil {
+			health[i].LastError = entry.lastError.Error()
+		}
+		entry.mu.Unlock()
+	}
+	return health
+}
+
+// try runs call against candidate facilitators until one succeeds. Settlements stop at the
+// first error after which the facilitator may have submitted the payment.
+func (f *FailoverFacilitator) try(ctx context.Context, requirementsBytes []byte, settle bool, call func(x402.FacilitatorClient) error) error {
+	network, scheme := requirementsKind(requirementsBytes)
+	candidates := f.candidates(network, scheme)
+	if len(candidates) == 0 {
+		return fmt.Errorf("no facilitator available for %s on %s", scheme, network)
+	}
+
+	var errs []error
+	for _, entry := range candidates {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		err := call(entry.client)
+		if err == nil {
+			entry.recordSuccess()
+			return nil
+		}
+
+		// An invalid payment or a rejected settlement is a definitive answer, not a facilitator outage
+		var verifyErr *x402.VerifyError
+		var settleErr *x402.SettleError
+		if errors.As(err, &verifyErr) || errors.As(err, &settleErr) {
+			entry.recordSuccess()
+			return err
+		}
+
+		entry.recordFailure(err, f.failureThreshold, f.clock.Now().Add(f.cooldown))
+		errs = append(errs, err)
+		if settle && !isUnsent(err) {
+			break
+		}
+	}
+
+	return fmt.Errorf("all facilitators failed for %s on %s: %w", scheme, network, errors.Join(errs...))
+}
+
+// isTimeout reports whether err is a timeout, after which a call's outcome is unknown
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// candidates returns facilitators supporting the kind, healthy ones first in priority order.
+// Unhealthy facilitators are kept as a last resort so an all-unhealthy set still gets tried.
+func (f *FailoverFacilitator) candidates(network, scheme string) []*facilitatorEntry {
+	now := f.clock.Now()
+	var healthy, unhealthy []*facilitatorEntry
+	for _, entry := range f.entries {
+		if !entry.supports(network, scheme) {
+			continue
+		}
+		entry.mu.Lock()
+		isHealthy := !now.Before(entry.unhealthyUntil)
+		entry.mu.Unlock()
+		if isHealthy {
+			healthy = append(healthy, entry)
+		} else {
+			unhealthy = append(unhealthy, entry)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// supports reports whether the facilitator advertised the kind.
+// Facilitators that haven't been synced yet are assumed to support everything.
+func (e *facilitatorEntry) supports(network, scheme string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.supported == nil {
+		return true
+	}
+	return e.supported[kindKey(network, scheme)]
+}
+
+func (e *facilitatorEntry) recordSuccess() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.consecutiveFailures = 0
+	e.lastError = nil
+	e.unhealthyUntil = time.Time{}
+}
+
+func (e *facilitatorEntry) recordFailure(err error, threshold int, unhealthyUntil time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.consecutiveFailures++
+	e.lastError = err
+	if e.consecutiveFailures >= threshold {
+		e.unhealthyUntil = unhealthyUntil
+	}
+}
+
+// requirementsKind extracts the network and scheme from marshaled payment requirements
+func requirementsKind(requirementsBytes []byte) (network, scheme string) {
+	var kind struct {
+		Scheme  string `json:"scheme"`
+		Network string `json:"network"`
+	}
+	_ = json.Unmarshal(requirementsBytes, &kind)
+	return kind.Network, kind.Scheme
+}
+
+func kindKey(network, scheme string) string {
+	return network + "|" + scheme
+}
@@ -0,0 +1,55 @@
+package xtended402_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	"github.com/mvpoyatt/xtended402/server/go/testing/facilitatortest"
+	"github.com/mvpoyatt/xtended402/server/go/testing/fixtures"
+)
+
+func TestFailoverSettleAfterServerError(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"broadcast failed"}`, http.StatusInternalServerError)
+	}))
+	defer primary.Close()
+
+	secondary := facilitatortest.New()
+	failover := xtended402.NewFailoverFacilitator([]x402.FacilitatorClient{
+		x402http.NewHTTPFacilitatorClient(&x402http.FacilitatorConfig{URL: primary.URL}),
+		secondary,
+	})
+
+	payload, requirements := fixtures.Payment(t)
+	if _, err := failover.Settle(context.Background(), fixtures.Bytes(t, payload), fixtures.Bytes(t, requirements)); err == nil {
+		t.Fatal("Settle succeeded after the primary failed with a 500")
+	}
+	if calls := secondary.Calls(); len(calls) != 0 {
+		t.Fatalf("secondary was called %d times after the primary may have submitted the payment", len(calls))
+	}
+}
+
+func TestFailoverSettleAfterRefusedConnection(t *testing.T) {
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	secondary := facilitatortest.New()
+	failover := xtended402.NewFailoverFacilitator([]x402.FacilitatorClient{
+		x402http.NewHTTPFacilitatorClient(&x402http.FacilitatorConfig{URL: primary.URL}),
+		secondary,
+	})
+
+	payload, requirements := fixtures.Payment(t)
+	resp, err := failover.Settle(context.Background(), fixtures.Bytes(t, payload), fixtures.Bytes(t, requirements))
+	if err != nil || !resp.Success {
+		t.Fatalf("Settle didn't fail over from an unreachable primary: %v", err)
+	}
+	if settlements := len(secondary.Settlements()); settlements != 1 {
+		t.Fatalf("secondary settled %d times, want 1", settlements)
+	}
+}
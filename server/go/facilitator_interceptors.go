@@ -0,0 +1,110 @@
+package xtended402
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// FacilitatorInvoker sends a facilitator HTTP request
+type FacilitatorInvoker func(req *http.Request) (*http.Response, error)
+
+// FacilitatorInterceptor intercepts facilitator HTTP calls, analogous to a gRPC unary interceptor.
+// It may modify the request, call invoke (or not), and inspect or replace the response.
+type FacilitatorInterceptor func(req *http.Request, invoke FacilitatorInvoker) (*http.Response, error)
+
+// NewInterceptedFacilitatorClient creates an HTTP facilitator client whose requests pass through
+// interceptors in order: the first interceptor is outermost and sees the final response last.
+//
+//	facilitator := xtended402.NewInterceptedFacilitatorClient(
+//		&x402http.FacilitatorConfig{URL: "https://x402.org/facilitator"},
+//		xtended402.LoggingInterceptor(log.Printf),
+//		xtended402.HeaderInterceptor(map[string]string{"X-Api-Key": key}),
+//	)
+func NewInterceptedFacilitatorClient(config *x402http.FacilitatorConfig, interceptors ...FacilitatorInterceptor) *x402http.HTTPFacilitatorClient {
+	cfg := x402http.FacilitatorConfig{}
+	if config != nil {
+		cfg = *config
+	}
+
+	cfg.HTTPClient = NewInterceptedHTTPClient(cfg.HTTPClient, cfg.Timeout, interceptors...)
+	return x402http.NewHTTPFacilitatorClient(&cfg)
+}
+
+// NewInterceptedHTTPClient wraps base's transport, or DefaultFacilitatorTransport, with
+// interceptors. A nil base uses a new client with timeout (30s if zero).
+func NewInterceptedHTTPClient(base *http.Client, timeout time.Duration, interceptors ...FacilitatorInterceptor) *http.Client {
+	client := pooledHTTPClient(base, timeout, nil)
+	client.Transport = &interceptedTransport{base: client.Transport, interceptors: interceptors}
+	return client
+}
+
+type interceptedTransport struct {
+	base         http.RoundTripper
+	interceptors []FacilitatorInterceptor
+}
+
+func (t *interceptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	invoke := FacilitatorInvoker(t.base.RoundTrip)
+	for i := len(t.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := t.interceptors[i], invoke
+		invoke = func(req *http.Request) (*http.Response, error) {
+			return interceptor(req, next)
+		}
+	}
+	return invoke(req)
+}
+
+// FacilitatorOperation returns "verify", "settle", or "supported" for a facilitator request
+func FacilitatorOperation(req *http.Request) string {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// ============================================================================
+// Built-in Interceptors
+// ============================================================================
+
+// LoggingInterceptor logs each facilitator call with its status and duration
+func LoggingInterceptor(logf func(format string, args ...interface{})) FacilitatorInterceptor {
+	return func(req *http.Request, invoke FacilitatorInvoker) (*http.Response, error) {
+		start := time.Now()
+		resp, err := invoke(req)
+		if err != nil {
+			logf("facilitator %s %s failed after %s: %v", FacilitatorOperation(req), req.URL.Host, time.Since(start), err)
+			return resp, err
+		}
+		logf("facilitator %s %s -> %d in %s", FacilitatorOperation(req), req.URL.Host, resp.StatusCode, time.Since(start))
+		return resp, err
+	}
+}
+
+// HeaderInterceptor sets static headers on every facilitator request
+func HeaderInterceptor(headers map[string]string) FacilitatorInterceptor {
+	return func(req *http.Request, invoke FacilitatorInvoker) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		return invoke(req)
+	}
+}
+
+// MetricsInterceptor reports the operation, HTTP status (0 on transport error), and duration
+// of each facilitator call, e.g. to a Prometheus histogram
+func MetricsInterceptor(observe func(operation string, status int, duration time.Duration)) FacilitatorInterceptor {
+	return func(req *http.Request, invoke FacilitatorInvoker) (*http.Response, error) {
+		start := time.Now()
+		resp, err := invoke(req)
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		observe(FacilitatorOperation(req), status, time.Since(start))
+
+		return resp, err
+	}
+}
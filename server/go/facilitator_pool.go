@@ -0,0 +1,233 @@
+package xtended402
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	x402 "github.com/coinbase/x402/go"
+)
+
+// ErrSettlementQueueFull is returned by SettlementPool.Settle when the queue is at its limit
+var ErrSettlementQueueFull = errors.New("settlement queue is full")
+
+// SettlementPool is a FacilitatorClient that bounds how many settlements run at once, overall
+// and per network, so a burst of purchases queues instead of overwhelming the facilitator or
+// a chain's RPC. Settlements wait for a free worker; a caller whose context ends while queued
+// leaves the queue. Verify and GetSupported pass straight through.
+type SettlementPool struct {
+	client        x402.FacilitatorClient
+	size          int
+	networkLimits map[string]int
+	maxQueued     int
+	workers       chan struct{}
+
+	mu         sync.Mutex
+	networks   map[string]*poolNetwork
+	queued     int
+	inFlight   int
+	peakQueued int
+	completed  uint64
+	rejected   uint64
+}
+
+// poolNetwork is one network's share of the pool
+type poolNetwork struct {
+	workers  chan struct{} // nil when the network has no limit of its own
+	queued   int
+	inFlight int
+}
+
+// SettlementPoolOption configures a SettlementPool
+type SettlementPoolOption func(*SettlementPool)
+
+// WithSettlementWorkers sets how many settlements run at once across all networks (default 16)
+func WithSettlementWorkers(n int) SettlementPoolOption {
+	return func(p *SettlementPool) {
+		p.size = n
+	}
+}
+
+// WithNetworkSettlementWorkers limits how many settlements run at once on network, within the
+// overall limit, e.g. for a chain whose RPC rate-limits
+func WithNetworkSettlementWorkers(network x402.Network, n int) SettlementPoolOption {
+	return func(p *SettlementPool) {
+		p.networkLimits[string(network)] = n
+	}
+}
+
+// WithMaxQueuedSettlements rejects settlements with ErrSettlementQueueFull once n are waiting
+// (default 0, unbounded)
+func WithMaxQueuedSettlements(n int) SettlementPoolOption {
+	return func(p *SettlementPool) {
+		p.maxQueued = n
+	}
+}
+
+// NewSettlementPool wraps client with a bounded settlement pool
+func NewSettlementPool(client x402.FacilitatorClient, opts ...SettlementPoolOption) *SettlementPool {
+	p := &SettlementPool{
+		client:        client,
+		networkLimits: map[string]int{},
+		networks:      map[string]*poolNetwork{},
+		size:          16,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.size < 1 {
+		p.size = 1
+	}
+	p.workers = make(chan struct{}, p.size)
+	return p
+}
+
+// Verify verifies a payment without queueing
+func (p *SettlementPool) Verify(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.VerifyResponse, error) {
+	return p.client.Verify(ctx, payloadBytes, requirementsBytes)
+}
+
+// Settle waits for a free worker, overall and on the payment's network, then settles
+func (p *SettlementPool) Settle(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.SettleResponse, error) {
+	network, _ := requirementsKind(requirementsBytes)
+
+	n, err := p.enqueue(network)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.acquire(ctx, n); err != nil {
+		return nil, fmt.Errorf("settlement not started: %w", err)
+	}
+	defer p.release(n)
+
+	return p.client.Settle(ctx, payloadBytes, requirementsBytes)
+}
+
+// GetSupported returns the wrapped facilitator's capabilities
+func (p *SettlementPool) GetSupported(ctx context.Context) (x402.SupportedResponse, error) {
+	return p.client.GetSupported(ctx)
+}
+
+// SettlementPoolStats is a snapshot of a SettlementPool's load, for queue-depth metrics
+type SettlementPoolStats struct {
+	Workers  int `json:"workers"`
+	InFlight int `json:"inFlight"`
+	Queued   int `json:"queued"`
+
+	// PeakQueued is the deepest the queue has been
+	PeakQueued int `json:"peakQueued"`
+
+	// Completed counts settlements that ran, successful or not, and Rejected those turned
+	// away by WithMaxQueuedSettlements
+	Completed uint64 `json:"completed"`
+	Rejected  uint64 `json:"rejected"`
+
+	// Networks breaks InFlight and Queued down by network
+	Networks map[string]NetworkPoolStats `json:"networks,omitempty"`
+}
+
+// NetworkPoolStats is one network's load in a SettlementPool
+type NetworkPoolStats struct {
+	// Workers is the network's own limit, or 0 if it only shares the overall one
+	Workers  int `json:"workers,omitempty"`
+	InFlight int `json:"inFlight"`
+	Queued   int `json:"queued"`
+}
+
+// Stats returns the pool's current load
+func (p *SettlementPool) Stats() SettlementPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := SettlementPoolStats{
+		Workers:    cap(p.workers),
+		InFlight:   p.inFlight,
+		Queued:     p.queued,
+		PeakQueued: p.peakQueued,
+		Completed:  p.completed,
+		Rejected:   p.rejected,
+		Networks:   make(map[string]NetworkPoolStats, len(p.networks)),
+	}
+	for network, n := range p.networks {
+		stats.Networks[network] = NetworkPoolStats{Workers: cap(n.workers), InFlight: n.inFlight, Queued: n.queued}
+	}
+	return stats
+}
+
+// enqueue adds a settlement on network to the queue
+func (p *SettlementPool) enqueue(network string) (*poolNetwork, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.maxQueued > 0 && p.queued >= p.maxQueued {
+		p.rejected++
+		return nil, ErrSettlementQueueFull
+	}
+
+	n := p.networks[network]
+	if n == nil {
+		n = &poolNetwork{}
+		if limit := p.networkLimits[network]; limit > 0 {
+			n.workers = make(chan struct{}, limit)
+		}
+		p.networks[network] = n
+	}
+	n.queued++
+	p.queued++
+	if p.queued > p.peakQueued {
+		p.peakQueued = p.queued
+	}
+	return n, nil
+}
+
+// acquire takes the network's worker, then an overall one, so a saturated network doesn't
+// hold overall workers while it waits. On failure the settlement leaves the queue.
+func (p *SettlementPool) acquire(ctx context.Context, n *poolNetwork) error {
+	if n.workers != nil {
+		select {
+		case n.workers <- struct{}{}:
+		case <-ctx.Done():
+			p.dequeue(n, false)
+			return ctx.Err()
+		}
+	}
+	select {
+	case p.workers <- struct{}{}:
+	case <-ctx.Done():
+		if n.workers != nil {
+			<-n.workers
+		}
+		p.dequeue(n, false)
+		return ctx.Err()
+	}
+	p.dequeue(n, true)
+	return nil
+}
+
+// dequeue removes a settlement from the queue, starting it if started
+func (p *SettlementPool) dequeue(n *poolNetwork, started bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n.queued--
+	p.queued--
+	if started {
+		n.inFlight++
+		p.inFlight++
+	}
+}
+
+// release frees a finished settlement's workers
+func (p *SettlementPool) release(n *poolNetwork) {
+	<-p.workers
+	if n.workers != nil {
+		<-n.workers
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n.inFlight--
+	p.inFlight--
+	p.completed++
+}
@@ -0,0 +1,188 @@
+package xtended402
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+)
+
+// RetryPolicy controls retries for one facilitator operation
+type RetryPolicy struct {
+	// Attempts is the total number of tries, including the first (minimum 1)
+	Attempts int
+
+	// Timeout bounds each individual attempt (0 = no per-attempt timeout)
+	Timeout time.Duration
+
+	// Backoff is the delay before the first retry, doubling on each retry up to MaxBackoff.
+	// Delays are jittered by ±20% to avoid synchronized retries.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// RetryFacilitator wraps a FacilitatorClient with independent retry and timeout policies for
+// verify, settle, and supported calls. Settlement usually deserves more patience than verify,
+// since it waits for on-chain confirmation.
+//
+// Only transient failures are retried. Invalid payments (*x402.VerifyError) and settlement
+// rejections (*x402.SettleError) are returned immediately. Settlement isn't idempotent, so it
+// isn't retried by default, and when its policy allows retries only failures to reach the
+// facilitator are: never a timeout or an error after the request was sent, which the
+// facilitator may have acted on.
+type RetryFacilitator struct {
+	client    x402.FacilitatorClient
+	verify    RetryPolicy
+	settle    RetryPolicy
+	supported RetryPolicy
+	clock     Clock
+}
+
+// RetryOption configures a RetryFacilitator
+type RetryOption func(*RetryFacilitator)
+
+// WithVerifyPolicy sets the verify retry policy (default 2 attempts, 10s timeout, 200ms backoff)
+func WithVerifyPolicy(policy RetryPolicy) RetryOption {
+	return func(f *RetryFacilitator) {
+		f.verify = policy
+	}
+}
+
+// WithSettlePolicy sets the settle retry policy (default 1 attempt, 60s timeout). Retries only
+// cover settlements that never reached the facilitator.
+func WithSettlePolicy(policy RetryPolicy) RetryOption {
+	return func(f *RetryFacilitator) {
+		f.settle = policy
+	}
+}
+
+// WithSupportedPolicy sets the supported retry policy (default 3 attempts, 10s timeout, 500ms backoff)
+func WithSupportedPolicy(policy RetryPolicy) RetryOption {
+	return func(f *RetryFacilitator) {
+		f.supported = policy
+	}
+}
+
+// WithRetryClock sets the clock used for backoff delays and per-attempt timeouts (default SystemClock)
+func WithRetryClock(clock Clock) RetryOption {
+	return func(f *RetryFacilitator) {
+		f.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewRetryFacilitator creates a RetryFacilitator around client
+func NewRetryFacilitator(client x402.FacilitatorClient, opts ...RetryOption) *RetryFacilitator {
+	f := &RetryFacilitator{
+		client:    client,
+		verify:    RetryPolicy{Attempts: 2, Timeout: 10 * time.Second, Backoff: 200 * time.Millisecond, MaxBackoff: time.Second},
+		settle:    RetryPolicy{Attempts: 1, Timeout: 60 * time.Second, Backoff: time.Second, MaxBackoff: 5 * time.Second},
+		supported: RetryPolicy{Attempts: 3, Timeout: 10 * time.Second, Backoff: 500 * time.Millisecond, MaxBackoff: 5 * time.Second},
+		clock:     SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// Verify verifies a payment using the verify policy
+func (f *RetryFacilitator) Verify(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.VerifyResponse, error) {
+	var result *x402.VerifyResponse
+	err := retry(ctx, f.clock, f.verify, func(ctx context.Context) error {
+		resp, err := f.client.Verify(ctx, payloadBytes, requirementsBytes)
+		result = resp
+		return err
+	})
+	return result, err
+}
+
+// Settle settles a payment using the settle policy
+func (f *RetryFacilitator) Settle(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.SettleResponse, error) {
+	var result *x402.SettleResponse
+	err := retryIf(ctx, f.clock, f.settle, isUnsent, func(ctx context.Context) error {
+		resp, err := f.client.Settle(ctx, payloadBytes, requirementsBytes)
+		result = resp
+		return err
+	})
+	return result, err
+}
+
+// GetSupported fetches capabilities using the supported policy
+func (f *RetryFacilitator) GetSupported(ctx context.Context) (x402.SupportedResponse, error) {
+	var result x402.SupportedResponse
+	err := retry(ctx, f.clock, f.supported, func(ctx context.Context) error {
+		resp, err := f.client.GetSupported(ctx)
+		result = resp
+		return err
+	})
+	return result, err
+}
+
+// retry runs call according to policy until it succeeds, fails definitively, or attempts run out
+func retry(ctx context.Context, clock Clock, policy RetryPolicy, call func(context.Context) error) error {
+	return retryIf(ctx, clock, policy, isRetryable, call)
+}
+
+// retryIf is retry, retrying only the errors retryable accepts
+func retryIf(ctx context.Context, clock Clock, policy RetryPolicy, retryable func(error) bool, call func(context.Context) error) error {
+	attempts := max(policy.Attempts, 1)
+	backoff := policy.Backoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = callWithTimeout(ctx, clock, policy.Timeout, call)
+		if err == nil || !retryable(err) || ctx.Err() != nil || attempt == attempts {
+			return err
+		}
+
+		if backoff > 0 {
+			delay := time.Duration(float64(backoff) * (0.8 + 0.4*rand.Float64()))
+			select {
+			case <-ctx.Done():
+				return err
+			case <-clock.After(delay):
+			}
+
+			backoff *= 2
+			if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	}
+	return err
+}
+
+func callWithTimeout(ctx context.Context, clock Clock, timeout time.Duration, call func(context.Context) error) error {
+	if timeout <= 0 {
+		return call(ctx)
+	}
+
+	ctx, cancel := ContextWithTimeout(ctx, clock, timeout)
+	defer cancel()
+	return call(ctx)
+}
+
+// isRetryable reports whether err is a transient failure
+func isRetryable(err error) bool {
+	var verifyErr *x402.VerifyError
+	var settleErr *x402.SettleError
+	var permanentErr *permanentError
+	return !errors.As(err, &verifyErr) && !errors.As(err, &settleErr) && !errors.As(err, &permanentErr)
+}
+
+// isUnsent reports whether err is a failure to reach the facilitator, e.g. a refused
+// connection, so the request certainly wasn't acted on. Timeouts aren't: even a dial timeout
+// may have raced a request through.
+func isUnsent(err error) bool {
+	if !isRetryable(err) || isTimeout(err) {
+		return false
+	}
+	var opErr *net.OpError
+	return (errors.As(err, &opErr) && opErr.Op == "dial") || errors.Is(err, syscall.ECONNREFUSED)
+}
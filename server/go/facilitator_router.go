@@ -0,0 +1,136 @@
+package xtended402
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	x402 "github.com/coinbase/x402/go"
+)
+
+// NetworkRouter is a FacilitatorClient that routes verify/settle calls to a facilitator chosen
+// by the payment's network, since no single facilitator supports every chain.
+//
+// Networks are matched exactly ("eip155:8453") or by CAIP-2 namespace wildcard ("solana:*").
+// Exact matches win over wildcards; unmatched networks go to the fallback, if any.
+type NetworkRouter struct {
+	exact    map[string]x402.FacilitatorClient
+	patterns map[string]x402.FacilitatorClient // namespace -> client for "namespace:*"
+	fallback x402.FacilitatorClient
+}
+
+// NewNetworkRouter creates a NetworkRouter from a network-to-facilitator mapping.
+// fallback may be nil to reject unmapped networks.
+func NewNetworkRouter(routes map[string]x402.FacilitatorClient, fallback x402.FacilitatorClient) *NetworkRouter {
+	r := &NetworkRouter{
+		exact:    make(map[string]x402.FacilitatorClient),
+		patterns: make(map[string]x402.FacilitatorClient),
+		fallback: fallback,
+	}
+
+	for network, client := range routes {
+		if namespace, ok := strings.CutSuffix(network, ":*"); ok {
+			r.patterns[namespace] = client
+		} else {
+			r.exact[network] = client
+		}
+	}
+
+	return r
+}
+
+// ClientFor returns the facilitator responsible for network, or nil if none is
+func (r *NetworkRouter) ClientFor(network string) x402.FacilitatorClient {
+	if client, ok := r.exact[network]; ok {
+		return client
+	}
+
+	namespace, _, _ := strings.Cut(network, ":")
+	if client, ok := r.patterns[namespace]; ok {
+		return client
+	}
+
+	return r.fallback
+}
+
+// Verify verifies with the facilitator mapped to the payment's network
+func (r *NetworkRouter) Verify(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.VerifyResponse, error) {
+	network, _ := requirementsKind(requirementsBytes)
+	client := r.ClientFor(network)
+	if client == nil {
+		return nil, fmt.Errorf("no facilitator configured for network %s", network)
+	}
+	return client.Verify(ctx, payloadBytes, requirementsBytes)
+}
+
+// Settle settles with the facilitator mapped to the payment's network
+func (r *NetworkRouter) Settle(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.SettleResponse, error) {
+	network, _ := requirementsKind(requirementsBytes)
+	client := r.ClientFor(network)
+	if client == nil {
+		return nil, fmt.Errorf("no facilitator configured for network %s", network)
+	}
+	return client.Settle(ctx, payloadBytes, requirementsBytes)
+}
+
+// GetSupported merges capabilities from every facilitator, keeping only the kinds each
+// facilitator is routed for so advertised networks always match where calls will go.
+// Fails only if no facilitator responds.
+func (r *NetworkRouter) GetSupported(ctx context.Context) (x402.SupportedResponse, error) {
+	merged := x402.SupportedResponse{Signers: map[string][]string{}}
+	seenExtensions := map[string]bool{}
+	var errs []error
+
+	for _, client := range r.clients() {
+		supported, err := client.GetSupported(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, kind := range supported.Kinds {
+			if r.ClientFor(kind.Network) == client {
+				merged.Kinds = append(merged.Kinds, kind)
+			}
+		}
+		for _, ext := range supported.Extensions {
+			if !seenExtensions[ext] {
+				seenExtensions[ext] = true
+				merged.Extensions = append(merged.Extensions, ext)
+			}
+		}
+		for family, signers := range supported.Signers {
+			merged.Signers[family] = append(merged.Signers[family], signers...)
+		}
+	}
+
+	if len(merged.Kinds) == 0 && len(errs) > 0 {
+		return x402.SupportedResponse{}, fmt.Errorf("all facilitators failed: %w", errors.Join(errs...))
+	}
+	return merged, nil
+}
+
+// clients returns each distinct facilitator once in a stable order
+func (r *NetworkRouter) clients() []x402.FacilitatorClient {
+	var clients []x402.FacilitatorClient
+	seen := map[x402.FacilitatorClient]bool{}
+	add := func(client x402.FacilitatorClient) {
+		if client != nil && !seen[client] {
+			seen[client] = true
+			clients = append(clients, client)
+		}
+	}
+
+	for _, network := range slices.Sorted(maps.Keys(r.exact)) {
+		add(r.exact[network])
+	}
+	for _, namespace := range slices.Sorted(maps.Keys(r.patterns)) {
+		add(r.patterns[namespace])
+	}
+	add(r.fallback)
+
+	return clients
+}
@@ -0,0 +1,161 @@
+package xtended402
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// DefaultFacilitatorTransport is the shared transport facilitator clients use when they aren't
+// given one. Sharing it lets every client reuse the same warm connections.
+var DefaultFacilitatorTransport = NewFacilitatorTransport()
+
+// FacilitatorTransportOption configures NewFacilitatorTransport
+type FacilitatorTransportOption func(*facilitatorTransportConfig)
+
+type facilitatorTransportConfig struct {
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+	idleConnTimeout     time.Duration
+	dialTimeout         time.Duration
+	keepAlive           time.Duration
+	tlsHandshakeTimeout time.Duration
+	http2               bool
+}
+
+// WithMaxIdleConnsPerHost sets how many idle connections are kept open to each facilitator
+// (default 32). Keep it at or above the number of concurrent verifies and settlements so
+// bursts reuse connections instead of dialing new ones.
+func WithMaxIdleConnsPerHost(n int) FacilitatorTransportOption {
+	return func(c *facilitatorTransportConfig) {
+		c.maxIdleConnsPerHost = n
+		if n > c.maxIdleConns {
+			c.maxIdleConns = n
+		}
+	}
+}
+
+// WithMaxConnsPerHost caps connections to each facilitator, idle or not (default 0, unlimited)
+func WithMaxConnsPerHost(n int) FacilitatorTransportOption {
+	return func(c *facilitatorTransportConfig) {
+		c.maxConnsPerHost = n
+	}
+}
+
+// WithIdleConnTimeout sets how long an idle connection is kept open (default 90s)
+func WithIdleConnTimeout(d time.Duration) FacilitatorTransportOption {
+	return func(c *facilitatorTransportConfig) {
+		c.idleConnTimeout = d
+	}
+}
+
+// WithDialTimeout sets how long connecting to a facilitator may take (default 5s)
+func WithDialTimeout(d time.Duration) FacilitatorTransportOption {
+	return func(c *facilitatorTransportConfig) {
+		c.dialTimeout = d
+	}
+}
+
+// WithKeepAlive sets the TCP keep-alive probe interval (default 30s)
+func WithKeepAlive(d time.Duration) FacilitatorTransportOption {
+	return func(c *facilitatorTransportConfig) {
+		c.keepAlive = d
+	}
+}
+
+// WithTLSHandshakeTimeout sets how long the TLS handshake may take (default 5s)
+func WithTLSHandshakeTimeout(d time.Duration) FacilitatorTransportOption {
+	return func(c *facilitatorTransportConfig) {
+		c.tlsHandshakeTimeout = d
+	}
+}
+
+// WithHTTP2 sets whether HTTP/2 is negotiated with facilitators that support it (default
+// true). Disable it behind proxies that mishandle HTTP/2.
+func WithHTTP2(enabled bool) FacilitatorTransportOption {
+	return func(c *facilitatorTransportConfig) {
+		c.http2 = enabled
+	}
+}
+
+// NewFacilitatorTransport creates a transport tuned for facilitator calls: keep-alives, a pool
+// of idle connections per facilitator, and dial and TLS timeouts short enough that an
+// unreachable facilitator fails fast instead of using up the request's whole timeout.
+func NewFacilitatorTransport(opts ...FacilitatorTransportOption) *http.Transport {
+	config := &facilitatorTransportConfig{
+		maxIdleConns:        100,
+		maxIdleConnsPerHost: 32,
+		idleConnTimeout:     90 * time.Second,
+		dialTimeout:         5 * time.Second,
+		keepAlive:           30 * time.Second,
+		tlsHandshakeTimeout: 5 * time.Second,
+		http2:               true,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.dialTimeout,
+		KeepAlive: config.keepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          config.maxIdleConns,
+		MaxIdleConnsPerHost:   config.maxIdleConnsPerHost,
+		MaxConnsPerHost:       config.maxConnsPerHost,
+		IdleConnTimeout:       config.idleConnTimeout,
+		TLSHandshakeTimeout:   config.tlsHandshakeTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     config.http2,
+	}
+	if !config.http2 {
+		// A non-nil, empty map is how net/http is told not to upgrade to HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// NewPooledFacilitatorClient creates an HTTP facilitator client on transport, or on
+// DefaultFacilitatorTransport if nil. A config.HTTPClient with its own transport keeps it.
+//
+//	facilitator := xtended402.NewPooledFacilitatorClient(
+//		&x402http.FacilitatorConfig{URL: "https://x402.org/facilitator"},
+//		xtended402.NewFacilitatorTransport(xtended402.WithMaxIdleConnsPerHost(64)),
+//	)
+func NewPooledFacilitatorClient(config *x402http.FacilitatorConfig, transport http.RoundTripper) *x402http.HTTPFacilitatorClient {
+	cfg := x402http.FacilitatorConfig{}
+	if config != nil {
+		cfg = *config
+	}
+
+	cfg.HTTPClient = pooledHTTPClient(cfg.HTTPClient, cfg.Timeout, transport)
+	return x402http.NewHTTPFacilitatorClient(&cfg)
+}
+
+// pooledHTTPClient copies base, or creates a client with timeout (30s if zero), and gives it
+// transport unless base has its own
+func pooledHTTPClient(base *http.Client, timeout time.Duration, transport http.RoundTripper) *http.Client {
+	client := &http.Client{}
+	if base != nil {
+		*client = *base
+	} else {
+		if timeout == 0 {
+			timeout = 30 * time.Second
+		}
+		client.Timeout = timeout
+	}
+
+	if client.Transport == nil {
+		client.Transport = transport
+	}
+	if client.Transport == nil {
+		client.Transport = DefaultFacilitatorTransport
+	}
+	return client
+}
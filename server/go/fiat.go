@@ -0,0 +1,71 @@
+package xtended402
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// Fiat fallback headers
+const (
+	// FiatCheckoutHeader carries the card checkout URL on 402 responses
+	FiatCheckoutHeader = "X-Fiat-Checkout"
+
+	// FiatPaymentHeader carries the completed checkout's reference (e.g. a Stripe Checkout
+	// Session ID) when a client retries the request after paying by card
+	FiatPaymentHeader = "X-Fiat-Payment"
+)
+
+// FiatNetwork is the network recorded on events and PaymentData for card payments. Their
+// asset is the currency code (e.g. "USD") and their amount is in major units (e.g. "4.50").
+const FiatNetwork x402.Network = "fiat"
+
+// ErrFiatPaymentInvalid is returned by FiatProvider.Redeem when the presented reference is
+// unknown, unpaid, already used, or doesn't cover the requested resource
+var ErrFiatPaymentInvalid = errors.New("fiat payment invalid")
+
+// FiatCheckout is a card checkout offered alongside an x402 payment challenge
+type FiatCheckout struct {
+	Provider  string    `json:"provider"`
+	URL       string    `json:"url"`
+	Reference string    `json:"reference"`
+	Amount    string    `json:"amount"`
+	Currency  string    `json:"currency"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+// FiatPayment is a completed card payment redeemed for a request
+type FiatPayment struct {
+	Provider string `json:"provider"`
+
+	// Reference is what the client presented (e.g. the Checkout Session ID)
+	Reference string `json:"reference"`
+
+	// Transaction is the provider's payment ID (e.g. a Stripe PaymentIntent)
+	Transaction string `json:"transaction,omitempty"`
+
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+
+	// Customer identifies the payer, e.g. their email
+	Customer string `json:"customer,omitempty"`
+}
+
+// FiatProvider offers card payment as an alternative to x402 on the same routes. Checkout
+// starts a checkout for resource ("METHOD /path") priced from its x402 requirements; once the
+// provider confirms payment (e.g. by a verified webhook), Redeem accepts the checkout's
+// reference as payment for one request.
+type FiatProvider interface {
+	Checkout(ctx context.Context, resource string, requirements x402types.PaymentRequirements) (*FiatCheckout, error)
+
+	// Redeem consumes reference as payment for resource, returning ErrFiatPaymentInvalid
+	// (possibly wrapped) if it can't be used
+	Redeem(ctx context.Context, resource, reference string, requirements x402types.PaymentRequirements) (*FiatPayment, error)
+
+	// Release returns a redeemed reference so it can be used again, e.g. when the handler it
+	// paid for failed
+	Release(ctx context.Context, reference string) error
+}
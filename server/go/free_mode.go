@@ -0,0 +1,168 @@
+package xtended402
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FreeModeHeader marks responses served without payment because of free mode. Its value is the
+// reason free mode was turned on.
+const FreeModeHeader = "X-Payment-Waived"
+
+// FreeModeKey is the Gin context key holding the free mode reason on waived requests
+const FreeModeKey = "xtended402FreeMode"
+
+// Environment variables read by FreeModeFromEnv
+const (
+	// FreeModeEnv turns free mode on: "all" (or "true"/"1") for every paid route, or
+	// comma-separated route patterns such as "GET /reports/*,POST /api/search"
+	FreeModeEnv = "XTENDED402_FREE_MODE"
+
+	// FreeModeReasonEnv is the reason sent in FreeModeHeader
+	FreeModeReasonEnv = "XTENDED402_FREE_MODE_REASON"
+)
+
+// defaultFreeModeReason is sent in FreeModeHeader when free mode has no reason
+const defaultFreeModeReason = "free-mode"
+
+// FreeModeState is whether free mode is on and for which routes
+type FreeModeState struct {
+	Enabled bool `json:"enabled"`
+
+	// Routes are the route patterns free mode covers (empty covers every paid route)
+	Routes []string `json:"routes,omitempty"`
+
+	// Reason is sent in FreeModeHeader, e.g. "launch-week" or "facilitator-incident"
+	Reason string `json:"reason,omitempty"`
+
+	// Until turns free mode off automatically (nil keeps it on until disabled)
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// FreeMode is a runtime switch that waives payment on every paid route or selected ones, for
+// promotions or to keep serving during facilitator incidents. It's safe for concurrent use;
+// flip it from the admin API or at startup from the environment.
+type FreeMode struct {
+	mu       sync.RWMutex
+	state    FreeModeState
+	patterns []RoutePattern
+	clock    Clock
+}
+
+// FreeModeOption configures a FreeMode
+type FreeModeOption func(*FreeMode)
+
+// WithFreeModeClock sets the clock used to expire free mode (defaults to the system clock)
+func WithFreeModeClock(clock Clock) FreeModeOption {
+	return func(f *FreeMode) {
+		f.clock = clock
+	}
+}
+
+// NewFreeMode creates a FreeMode that is off
+func NewFreeMode(opts ...FreeModeOption) *FreeMode {
+	f := &FreeMode{}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// FreeModeFromEnv creates a FreeMode turned on by XTENDED402_FREE_MODE, if set
+func FreeModeFromEnv(opts ...FreeModeOption) *FreeMode {
+	f := NewFreeMode(opts...)
+
+	value := strings.TrimSpace(os.Getenv(FreeModeEnv))
+	switch strings.ToLower(value) {
+	case "", "false", "0", "off":
+		return f
+	case "all", "true", "1", "on":
+		f.Enable(os.Getenv(FreeModeReasonEnv))
+	default:
+		var routes []string
+		for _, route := range strings.Split(value, ",") {
+			if route = strings.TrimSpace(route); route != "" {
+				routes = append(routes, route)
+			}
+		}
+		f.Enable(os.Getenv(FreeModeReasonEnv), routes...)
+	}
+	return f
+}
+
+// Set replaces the free mode state
+func (f *FreeMode) Set(state FreeModeState) {
+	patterns := make([]RoutePattern, 0, len(state.Routes))
+	for _, route := range state.Routes {
+		patterns = append(patterns, ParseRoutePattern(route))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = state
+	f.patterns = patterns
+}
+
+// Enable turns free mode on for routes (every paid route if none) until disabled
+func (f *FreeMode) Enable(reason string, routes ...string) {
+	f.Set(FreeModeState{Enabled: true, Routes: routes, Reason: reason})
+}
+
+// Disable turns free mode off
+func (f *FreeMode) Disable() {
+	f.Set(FreeModeState{})
+}
+
+// State returns the current state. Expired free mode reports as off.
+func (f *FreeMode) State() FreeModeState {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if !f.activeLocked() {
+		return FreeModeState{}
+	}
+	return f.state
+}
+
+// Waives reports whether free mode covers resource ("METHOD /path") and returns the reason to
+// send in FreeModeHeader
+func (f *FreeMode) Waives(resource string) (reason string, ok bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if !f.activeLocked() {
+		return "", false
+	}
+
+	covered := len(f.patterns) == 0
+	for _, pattern := range f.patterns {
+		if pattern.Matches(resource) {
+			covered = true
+			break
+		}
+	}
+	if !covered {
+		return "", false
+	}
+
+	if f.state.Reason == "" {
+		return defaultFreeModeReason, true
+	}
+	return f.state.Reason, true
+}
+
+func (f *FreeMode) activeLocked() bool {
+	if !f.state.Enabled {
+		return false
+	}
+	return f.state.Until == nil || ClockOrSystem(f.clock).Now().Before(*f.state.Until)
+}
+
+// GetFreeModeReason returns why payment was waived for the request, or "" if it wasn't
+func GetFreeModeReason(c *gin.Context) string {
+	return c.GetString(FreeModeKey)
+}
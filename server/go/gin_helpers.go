@@ -1,19 +1,45 @@
 package xtended402
 
 import (
-	"context"
-
 	"github.com/gin-gonic/gin"
 )
 
-// SetContextValueGin sets a value in the request context.
+// SetContextValueGin sets a value in the request context under key.
 // Updates the Gin request context properly for use with context-based pricing or validation hooks.
-func SetContextValueGin(c *gin.Context, key string, value interface{}) {
-	ctx := context.WithValue(c.Request.Context(), key, value)
-	c.Request = c.Request.WithContext(ctx)
+func SetContextValueGin[T any](c *gin.Context, key *ContextKey[T], value T) {
+	c.Request = c.Request.WithContext(key.WithValue(c.Request.Context(), value))
 }
 
 // StoreForValidationGin stores data in request context for later validation in before-settle hooks.
-func StoreForValidationGin(c *gin.Context, key string, value interface{}) {
+func StoreForValidationGin[T any](c *gin.Context, key *ContextKey[T], value T) {
 	SetContextValueGin(c, key, value)
 }
+
+// GetPayer returns who paid for the request: the payer of its payment, or the payer behind the
+// session token, API key, access grant, or balance that let it through. Returns "" for
+// unpaid requests.
+func GetPayer(c *gin.Context) string {
+	if data := GetPaymentData(c); data != nil {
+		switch {
+		case data.SettleResponse != nil && data.SettleResponse.Payer != "":
+			return data.SettleResponse.Payer
+		case data.FiatPayment != nil:
+			return data.FiatPayment.Customer
+		default:
+			return PayerOf(data.PaymentPayload)
+		}
+	}
+	if claims := GetSession(c); claims != nil {
+		return claims.Subject
+	}
+	if key := GetAPIKey(c); key != nil {
+		return key.Payer
+	}
+	if grant := GetAccessGrant(c); grant != nil {
+		return grant.Payer
+	}
+	if debit := GetBalanceDebit(c); debit != nil {
+		return debit.Account
+	}
+	return ""
+}
@@ -8,11 +8,12 @@ import (
 	x402http "github.com/coinbase/x402/go/http"
 )
 
-// ContextPrice creates a DynamicPriceFunc that reads price from request context.
-// Use with SetContextValueGin to calculate prices from request body data in preceding middleware.
-func ContextPrice(key string) x402http.DynamicPriceFunc {
+// ContextPrice creates a DynamicPriceFunc that reads price from request context, usually under
+// PriceKey. Use with SetContextValueGin to calculate prices from request body data in preceding
+// middleware.
+func ContextPrice(key *ContextKey[string]) x402http.DynamicPriceFunc {
 	return func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (x402.Price, error) {
-		if price, ok := ctx.Value(key).(string); ok {
+		if price, ok := key.Value(ctx); ok {
 			return x402.Price(price), nil
 		}
 		return nil, fmt.Errorf("price not found in context with key: %s", key)
@@ -33,8 +34,8 @@ func CreateBeforeSettleHook(validateFn func(ctx context.Context) error) x402.Bef
 	}
 }
 
-// StoreForValidation stores data in request context for later validation in before-settle hooks.
-// For Gin, use StoreForValidationGin instead.
-func StoreForValidation(ctx context.Context, key string, value interface{}) context.Context {
-	return context.WithValue(ctx, key, value)
+// StoreForValidation stores data in request context for later validation in before-settle hooks,
+// which read it back with key.Value. For Gin, use StoreForValidationGin instead.
+func StoreForValidation[T any](ctx context.Context, key *ContextKey[T], value T) context.Context {
+	return key.WithValue(ctx, value)
 }
@@ -0,0 +1,53 @@
+package gin
+
+import (
+	"fmt"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Access Windows
+// ============================================================================
+
+// WithAccessWindows lets payers re-access resources they bought within the route's access
+// window without paying again. The client still sends a signed payment, which is verified to
+// prove the payer's identity but not settled. Handlers read the grant with
+// xtended402.GetAccessGrant instead of GetPaymentData.
+func WithAccessWindows(windows *xtended402.AccessWindows) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.AccessWindows = windows
+	}
+}
+
+// accessGranted reports whether the verified payer already bought the requested resource, and
+// stores the grant for the handler if so. Store errors fall back to charging.
+func accessGranted(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult) bool {
+	if config.AccessWindows == nil {
+		return false
+	}
+
+	payer := xtended402.PayerOf(result.PaymentPayload)
+	grant, err := config.AccessWindows.Check(c.Request.Context(), payer, resourceOf(c))
+	if err != nil {
+		fmt.Printf("Warning: failed to check access grant for %s: %v\n", payer, err)
+		return false
+	}
+	if grant == nil || revoked(c, config, grant.Transaction) {
+		return false
+	}
+	c.Set(xtended402.AccessGrantKey, grant)
+	return true
+}
+
+// grantAccess records an access window for a settled payment
+func grantAccess(c *gin.Context, config *MiddlewareConfig, event xtended402.PaymentEvent) {
+	if config.AccessWindows == nil {
+		return
+	}
+	if err := config.AccessWindows.Grant(c.Request.Context(), event); err != nil {
+		fmt.Printf("Warning: failed to grant access to %s for %s: %v\n", event.Resource, event.Payer, err)
+	}
+}
@@ -0,0 +1,168 @@
+package gin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Access Log
+// ============================================================================
+
+// settlementLogKey holds the current request's settlement for the access log in the Gin context
+const settlementLogKey = "xtended402SettlementLog"
+
+// AccessLogEntry is a request's access log line with its payment
+type AccessLogEntry struct {
+	Time     time.Time
+	Method   string
+	Path     string
+	Status   int
+	Size     int
+	Latency  time.Duration
+	ClientIP string
+
+	// Paid is set when the request was paid for, on-chain, by card, or with an invoice
+	Paid        bool
+	Network     x402.Network
+	Amount      string
+	Asset       string
+	Payer       string
+	Transaction string
+
+	// SettlementLatency is how long the facilitator took to settle the payment
+	SettlementLatency time.Duration
+
+	// PaymentError explains a settlement that failed
+	PaymentError string
+}
+
+// String formats the entry as a log line, e.g.
+//
+//	POST /api/purchase 200 512B 45ms 203.0.113.7 paid amount=4500000 asset=0x8335... network=eip155:8453 payer=0x623B... tx=0xdb0d... settle=30ms
+func (e AccessLogEntry) String() string {
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s %s %d %dB %s %s", e.Method, e.Path, e.Status, max(e.Size, 0), e.Latency.Round(time.Millisecond), e.ClientIP)
+	switch {
+	case e.Paid:
+		fmt.Fprintf(&line, " paid amount=%s asset=%s network=%s payer=%s", e.Amount, e.Asset, e.Network, e.Payer)
+		if e.Transaction != "" {
+			fmt.Fprintf(&line, " tx=%s", e.Transaction)
+		}
+		if e.SettlementLatency > 0 {
+			fmt.Fprintf(&line, " settle=%s", e.SettlementLatency.Round(time.Millisecond))
+		}
+	case e.PaymentError != "":
+		fmt.Fprintf(&line, " unpaid error=%q", e.PaymentError)
+	default:
+		line.WriteString(" unpaid")
+	}
+	return line.String()
+}
+
+// AccessLog logs each request with its payment through logf, e.g. log.Printf. Mount it before
+// PaymentMiddleware, so it sees payments settled after the handler:
+//
+//	r.Use(ginmw.AccessLog(log.Printf))
+//	r.Use(ginmw.PaymentMiddleware(routes, server))
+func AccessLog(logf func(format string, args ...interface{})) gin.HandlerFunc {
+	return AccessLogHandler(func(c *gin.Context, entry AccessLogEntry) {
+		logf("%s", entry)
+	})
+}
+
+// AccessLogHandler passes each request's AccessLogEntry to handler, e.g. for structured logging.
+// It reads the status and size from the response writer once the middleware has replayed the
+// response, rather than buffering the body a second time.
+func AccessLogHandler(handler func(c *gin.Context, entry AccessLogEntry)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		entry := AccessLogEntry{
+			Time:     start,
+			Method:   c.Request.Method,
+			Path:     path,
+			Status:   c.Writer.Status(),
+			Size:     c.Writer.Size(),
+			Latency:  time.Since(start),
+			ClientIP: c.ClientIP(),
+		}
+		withPayment(c, &entry)
+		handler(c, entry)
+	}
+}
+
+// settlementLog is a settlement made during the current request
+type settlementLog struct {
+	requirements *x402types.PaymentRequirements
+	result       *x402http.ProcessSettleResult
+	latency      time.Duration
+}
+
+// logSettlement records a settlement attempt for the access log
+func logSettlement(c *gin.Context, result x402http.HTTPProcessResult, settleResult *x402http.ProcessSettleResult, latency time.Duration) {
+	c.Set(settlementLogKey, settlementLog{requirements: result.PaymentRequirements, result: settleResult, latency: latency})
+}
+
+// withPayment fills in the entry's payment, from the settlement made during the request or
+// the PaymentData of card, wallet, and invoice payments
+func withPayment(c *gin.Context, entry *AccessLogEntry) {
+	if value, ok := c.Get(settlementLogKey); ok {
+		settlement := value.(settlementLog)
+		entry.SettlementLatency = settlement.latency
+		if !settlement.result.Success {
+			entry.PaymentError = settlement.result.ErrorReason
+			if entry.PaymentError == "" {
+				entry.PaymentError = "Settlement failed"
+			}
+			return
+		}
+		entry.Paid = true
+		entry.Network = settlement.result.Network
+		entry.Payer = settlement.result.Payer
+		entry.Transaction = settlement.result.Transaction
+		if settlement.requirements != nil {
+			entry.Amount = settlement.requirements.Amount
+			entry.Asset = settlement.requirements.Asset
+			if entry.Network == "" {
+				entry.Network = x402.Network(settlement.requirements.Network)
+			}
+		}
+		return
+	}
+
+	data := xtended402.GetPaymentData(c)
+	switch {
+	case data == nil:
+	case data.FiatPayment != nil:
+		entry.Paid = true
+		entry.Network = xtended402.FiatNetwork
+		entry.Amount = data.FiatPayment.Amount
+		entry.Asset = data.FiatPayment.Currency
+		entry.Payer = data.FiatPayment.Customer
+		entry.Transaction = data.FiatPayment.Transaction
+	case data.WalletPayment != nil:
+		entry.Paid = true
+		entry.Network = data.WalletPayment.Network
+		entry.Amount = data.WalletPayment.Amount
+		entry.Asset = data.WalletPayment.Asset
+		entry.Payer = data.WalletPayment.Payer
+		entry.Transaction = data.WalletPayment.Transaction
+	case data.Invoice != nil:
+		entry.Paid = true
+		entry.Network = data.Invoice.Network
+		entry.Amount = data.Invoice.Amount
+		entry.Asset = data.Invoice.Asset
+		entry.Payer = data.Invoice.Payer
+		entry.Transaction = data.Invoice.Transaction
+	}
+}
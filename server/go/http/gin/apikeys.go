@@ -0,0 +1,102 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// API Keys
+// ============================================================================
+
+// WithAPIKeys mints an API key from provisioner for each payment settled on its mint routes,
+// returned in the X-API-Key header and, when settling before the handler, as
+// PaymentData.APIKey. Requests presenting a key with quota left skip the 402 flow and use up
+// one request; if the handler responds with an error status, the request is returned to the
+// quota. When a key is exhausted, the 402 carries an xtended402.TopUp in the X-Top-Up header
+// pointing at the mint routes. Handlers read the key with xtended402.GetAPIKey instead of
+// GetPaymentData.
+func WithAPIKeys(provisioner *xtended402.APIKeyProvisioner) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.APIKeys = provisioner
+	}
+}
+
+// apiKeyPaid reports whether the request carries an API key that paid for it, and runs the
+// handler if so. Unusable keys fall through to the 402 flow, so clients can pay instead.
+func apiKeyPaid(c *gin.Context, config *MiddlewareConfig) bool {
+	if config.APIKeys == nil {
+		return false
+	}
+	apiKey := config.APIKeys.Key(c.Request)
+	if apiKey == "" {
+		return false
+	}
+
+	key, err := config.APIKeys.Authorize(c.Request.Context(), apiKey, resourceOf(c))
+	if errors.Is(err, xtended402.ErrAPIKeyExhausted) {
+		c.Set(topUpKey, config.APIKeys.TopUp())
+		return false
+	}
+	if err != nil {
+		if !errors.Is(err, xtended402.ErrAPIKeyInvalid) {
+			fmt.Printf("Warning: failed to authorize API key: %v\n", err)
+		}
+		return false
+	}
+	if revoked(c, config, key.Transaction) {
+		return false
+	}
+
+	c.Set(xtended402.APIKeyDataKey, key)
+	c.Header(xtended402.APIKeyRemainingHeader, strconv.FormatInt(key.Quota, 10))
+	c.Next()
+
+	if c.Writer.Status() >= http.StatusBadRequest {
+		if err := config.APIKeys.Restore(c.Request.Context(), key); err != nil {
+			fmt.Printf("Warning: failed to restore quota of API key %s: %v\n", key.ID, err)
+		}
+	}
+	return true
+}
+
+// topUpKey is the Gin context key where a TopUp is kept until the request's 402 is written
+const topUpKey = "xtended402TopUp"
+
+// addTopUp adds the TopUp or SessionRenewal found earlier in the request, if any, to its 402
+// response
+func addTopUp(c *gin.Context) {
+	if topUp, exists := c.Get(topUpKey); exists {
+		c.Header(xtended402.TopUpHeader, topUp.(*xtended402.TopUp).Header())
+	}
+	if renewal, exists := c.Get(sessionRenewalKey); exists {
+		c.Header(xtended402.SessionRenewalHeader, renewal.(*xtended402.SessionRenewal).Header())
+	}
+}
+
+// mintAPIKey mints a key for a settled payment on a mint route and adds it to the response.
+// The payment has already settled, so a failure is only logged.
+func mintAPIKey(c *gin.Context, config *MiddlewareConfig, event xtended402.PaymentEvent) {
+	if config.APIKeys == nil {
+		return
+	}
+	apiKey, key, err := config.APIKeys.Mint(c.Request.Context(), event)
+	if err != nil {
+		fmt.Printf("Warning: failed to mint API key for %s: %v\n", event.ID, err)
+		return
+	}
+	if key == nil {
+		return
+	}
+
+	c.Header(xtended402.APIKeyHeader, apiKey)
+	c.Header(xtended402.APIKeyRemainingHeader, strconv.FormatInt(key.Quota, 10))
+	if paymentData := xtended402.GetPaymentData(c); paymentData != nil {
+		paymentData.APIKey = apiKey
+	}
+}
@@ -0,0 +1,91 @@
+package gin_test
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	ginmw "github.com/mvpoyatt/xtended402/server/go/http/gin"
+	"github.com/mvpoyatt/xtended402/server/go/testing/e2e"
+)
+
+func TestAPIKeys(t *testing.T) {
+	routes := x402http.RoutesConfig{
+		"POST /api/keys":  pricedRoute("$1.00"),
+		"GET /api/search": pricedRoute("$0.01"),
+		"GET /api/fail":   pricedRoute("$0.01"),
+	}
+	keys := xtended402.NewAPIKeyProvisioner(xtended402.NewMemoryAPIKeyStore(),
+		xtended402.WithAPIKeyMintRoute("POST /api/keys", 2),
+	)
+	var searches atomic.Int32
+	h := e2e.New(t, routes, func(r *gin.Engine, paid gin.HandlerFunc) {
+		r.POST("/api/keys", paid, func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"apiKey": xtended402.GetPaymentData(c).APIKey})
+		})
+		r.GET("/api/search", paid, func(c *gin.Context) {
+			if xtended402.GetAPIKey(c) == nil {
+				t.Error("search paid by key has no APIKey")
+			}
+			searches.Add(1)
+			c.JSON(http.StatusOK, gin.H{"results": []string{}})
+		})
+		r.GET("/api/fail", paid, func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "upstream down"})
+		})
+	}, e2e.WithMiddlewareOptions(ginmw.WithSettlementTiming("before"), ginmw.WithAPIKeys(keys)))
+
+	minted := h.Pay(t, "POST", "/api/keys", nil)
+	apiKey := minted.Header().Get(xtended402.APIKeyHeader)
+	if minted.Code != http.StatusOK || apiKey == "" {
+		t.Fatalf("minting: got %d without a key: %s", minted.Code, minted.Body)
+	}
+	if remaining := minted.Header().Get(xtended402.APIKeyRemainingHeader); remaining != "2" {
+		t.Fatalf("minted key has %s requests, want 2", remaining)
+	}
+	settled := len(h.Facilitator.Settlements())
+	withKey := map[string]string{xtended402.APIKeyHeader: apiKey}
+
+	call := func(path string, wantStatus int, wantRemaining string) *e2e.Response {
+		t.Helper()
+		resp := h.Do(t, "GET", path, nil, withKey)
+		if resp.Code != wantStatus {
+			t.Fatalf("GET %s with key: got %d, want %d: %s", path, resp.Code, wantStatus, resp.Body)
+		}
+		if remaining := resp.Header().Get(xtended402.APIKeyRemainingHeader); remaining != wantRemaining {
+			t.Fatalf("GET %s with key: %q requests left, want %q", path, remaining, wantRemaining)
+		}
+		return resp
+	}
+
+	// Each request uses one unit; a failed one gives it back
+	call("/api/search", http.StatusOK, "1")
+	call("/api/fail", http.StatusInternalServerError, "0")
+	call("/api/search", http.StatusOK, "0")
+
+	// The exhausted key falls back to the 402, pointing at the mint route
+	exhausted := call("/api/search", http.StatusPaymentRequired, "")
+	topUp, err := xtended402.DecodeTopUp(exhausted.Header().Get(xtended402.TopUpHeader))
+	if err != nil {
+		t.Fatalf("exhausted key's 402 has no top-up: %v", err)
+	}
+	if topUp.Model != xtended402.TopUpAPIKey || len(topUp.Routes) != 1 || topUp.Routes[0] != "POST /api/keys" {
+		t.Fatalf("got top-up %+v, want the API key mint route", topUp)
+	}
+
+	if n := searches.Load(); n != 2 {
+		t.Fatalf("search ran %d times, want 2", n)
+	}
+	if n := len(h.Facilitator.Settlements()); n != settled {
+		t.Fatalf("requests paid by key settled %d payments", n-settled)
+	}
+
+	// Keys never pay for mint routes
+	if resp := h.Do(t, "POST", "/api/keys", nil, withKey); resp.Code != http.StatusPaymentRequired {
+		t.Fatalf("key paid for a mint route: %d", resp.Code)
+	}
+}
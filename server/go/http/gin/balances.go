@@ -0,0 +1,113 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Prepaid Balances
+// ============================================================================
+
+// WithBalances credits payments settled on the deposit routes of balances to the payer's
+// account, and pays other routes from that account instead of settling each request. The
+// client still sends a signed payment, which is verified to prove the payer's identity but
+// not settled. When the balance is too low, the request fails with 402 and an
+// xtended402.TopUp, in the body and the X-Top-Up header, suggesting an amount to deposit.
+// Handlers read the debit with xtended402.GetBalanceDebit instead of GetPaymentData.
+func WithBalances(balances *xtended402.Balances) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Balances = balances
+	}
+}
+
+// balancePaid pays for the request from the verified payer's balance and runs the handler, or
+// writes the 402 top-up response. It reports false for routes not paid from balances. If the
+// handler responds with an error status, the debit is refunded.
+func balancePaid(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult) bool {
+	if config.Balances == nil || !config.Balances.DrawsDown(resourceOf(c)) {
+		return false
+	}
+
+	debit, err := config.Balances.Debit(c.Request.Context(), result.PaymentPayload, result.PaymentRequirements, resourceOf(c))
+	if err != nil {
+		rejectBalancePayment(c, config, result, err)
+		return true
+	}
+
+	c.Set(xtended402.BalanceDebitKey, debit)
+	c.Header(xtended402.BalanceHeader, debit.Balance.String())
+	c.Next()
+
+	if c.Writer.Status() >= http.StatusBadRequest {
+		if _, err := config.Balances.Refund(c.Request.Context(), debit); err != nil {
+			fmt.Printf("Warning: failed to refund balance debit %s: %v\n", debit.Reference, err)
+		}
+	}
+	return true
+}
+
+func rejectBalancePayment(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult, err error) {
+	var topUp *xtended402.TopUp
+	if errors.Is(err, xtended402.ErrInsufficientBalance) {
+		var topUpErr error
+		topUp, topUpErr = config.Balances.TopUp(c.Request.Context(), result.PaymentPayload, result.PaymentRequirements)
+		if topUpErr != nil {
+			fmt.Printf("Warning: failed to build top-up for %s: %v\n", resourceOf(c), topUpErr)
+		} else {
+			c.Header(xtended402.TopUpHeader, topUp.Header())
+			c.Header(xtended402.BalanceHeader, topUp.Remaining)
+		}
+	}
+
+	if handler := errorHandler(c, config); handler != nil {
+		status := http.StatusPaymentRequired
+		if !errors.Is(err, xtended402.ErrInsufficientBalance) && !errors.Is(err, xtended402.ErrDuplicateReference) {
+			status = http.StatusServiceUnavailable
+		}
+		handler(c, rejectionError(StageVerify, status, result.PaymentRequirements, fmt.Errorf("balance payment failed: %w", err)))
+		c.Abort()
+		return
+	}
+
+	switch {
+	case errors.Is(err, xtended402.ErrInsufficientBalance):
+		c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
+			"error": "Insufficient balance",
+			"topUp": topUp,
+		}))
+	case errors.Is(err, xtended402.ErrDuplicateReference):
+		c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
+			"error":   "Payment already used",
+			"details": "Sign a new payment for each request",
+		}))
+	default:
+		c.JSON(http.StatusServiceUnavailable, errorBody(c, gin.H{
+			"error":   "Balance unavailable",
+			"details": err.Error(),
+		}))
+	}
+	c.Abort()
+}
+
+// creditDeposit credits a settled payment on a deposit route to the payer's balance. The
+// payment has already settled, so a failure is only logged; the payment.settled event still
+// carries the transaction for reconciliation.
+func creditDeposit(c *gin.Context, config *MiddlewareConfig, event xtended402.PaymentEvent) {
+	if config.Balances == nil {
+		return
+	}
+	balance, err := config.Balances.Deposit(c.Request.Context(), event)
+	if err != nil {
+		fmt.Printf("Warning: failed to credit deposit %s for %s: %v\n", event.Transaction, event.Payer, err)
+		return
+	}
+	if balance != nil {
+		c.Header(xtended402.BalanceHeader, balance.String())
+	}
+}
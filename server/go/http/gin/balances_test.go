@@ -0,0 +1,84 @@
+package gin_test
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	ginmw "github.com/mvpoyatt/xtended402/server/go/http/gin"
+	"github.com/mvpoyatt/xtended402/server/go/testing/e2e"
+)
+
+func TestBalances(t *testing.T) {
+	routes := x402http.RoutesConfig{
+		"POST /account/deposit": pricedRoute("$1.00"),
+		"GET /api/search":       pricedRoute("$0.40"),
+		"GET /api/fail":         pricedRoute("$0.40"),
+	}
+	balances := xtended402.NewBalances(xtended402.NewMemoryBalanceStore(nil),
+		xtended402.WithDepositRoute("POST /account/deposit"),
+	)
+	var searches atomic.Int32
+	h := e2e.New(t, routes, func(r *gin.Engine, paid gin.HandlerFunc) {
+		r.POST("/account/deposit", paid, func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"deposited": true})
+		})
+		r.GET("/api/search", paid, func(c *gin.Context) {
+			if xtended402.GetBalanceDebit(c) == nil {
+				t.Error("search paid from balance has no BalanceDebit")
+			}
+			searches.Add(1)
+			c.JSON(http.StatusOK, gin.H{"results": []string{}})
+		})
+		r.GET("/api/fail", paid, func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "upstream down"})
+		})
+	}, e2e.WithMiddlewareOptions(ginmw.WithSettlementTiming("before"), ginmw.WithBalances(balances)))
+
+	pay := func(method, path string, wantStatus int, wantBalance string) *e2e.Response {
+		t.Helper()
+		resp := h.Pay(t, method, path, nil)
+		if resp.Code != wantStatus {
+			t.Fatalf("%s %s: got %d, want %d: %s", method, path, resp.Code, wantStatus, resp.Body)
+		}
+		if balance := resp.Header().Get(xtended402.BalanceHeader); balance != wantBalance {
+			t.Fatalf("%s %s: balance %q, want %q", method, path, balance, wantBalance)
+		}
+		return resp
+	}
+	wantTopUp := func(resp *e2e.Response) {
+		t.Helper()
+		topUp, err := xtended402.DecodeTopUp(resp.Header().Get(xtended402.TopUpHeader))
+		if err != nil {
+			t.Fatalf("insufficient balance's 402 has no top-up: %v", err)
+		}
+		if topUp.Model != xtended402.TopUpBalance || len(topUp.Routes) != 1 || topUp.Routes[0] != "POST /account/deposit" {
+			t.Fatalf("got top-up %+v, want the deposit route", topUp)
+		}
+	}
+
+	wantTopUp(pay("GET", "/api/search", http.StatusPaymentRequired, "0"))
+
+	pay("POST", "/account/deposit", http.StatusOK, "1000000")
+	if n := len(h.Facilitator.Settlements()); n != 1 {
+		t.Fatalf("deposit settled %d payments, want 1", n)
+	}
+
+	// Each request draws down the balance; a failed one is refunded
+	pay("GET", "/api/search", http.StatusOK, "600000")
+	pay("GET", "/api/fail", http.StatusInternalServerError, "200000")
+	pay("GET", "/api/search", http.StatusOK, "200000")
+
+	wantTopUp(pay("GET", "/api/search", http.StatusPaymentRequired, "200000"))
+
+	if n := searches.Load(); n != 2 {
+		t.Fatalf("search ran %d times, want 2", n)
+	}
+	if n := len(h.Facilitator.Settlements()); n != 1 {
+		t.Fatalf("requests paid from balance settled %d payments", n-1)
+	}
+}
@@ -0,0 +1,49 @@
+package gin
+
+import (
+	"fmt"
+
+	x402http "github.com/coinbase/x402/go/http"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Bazaar Listings
+// ============================================================================
+
+// WithBazaarListing describes the route configured under pattern for Bazaar discovery: its
+// title, description, media type, and example request and response. The route must be one of
+// the middleware's routes.
+func WithBazaarListing(pattern string, listing xtended402.BazaarListing) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		if c.BazaarListings == nil {
+			c.BazaarListings = make(map[string]xtended402.BazaarListing)
+		}
+		c.BazaarListings[pattern] = listing
+	}
+}
+
+// listRoutes applies the Bazaar listings to a copy of the routes, leaving the caller's intact
+func listRoutes(config *MiddlewareConfig) error {
+	if len(config.BazaarListings) == 0 {
+		return nil
+	}
+
+	routes := make(x402http.RoutesConfig, len(config.Routes))
+	for pattern, route := range config.Routes {
+		routes[pattern] = route
+	}
+	for pattern, listing := range config.BazaarListings {
+		route, ok := routes[pattern]
+		if !ok {
+			return fmt.Errorf("bazaar listing for unknown route %q", pattern)
+		}
+		listed, err := xtended402.ListOnBazaar(pattern, route, listing)
+		if err != nil {
+			return err
+		}
+		routes[pattern] = listed
+	}
+	config.Routes = routes
+	return nil
+}
@@ -0,0 +1,39 @@
+package gin
+
+import (
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Payment Bypass
+// ============================================================================
+
+// PaymentRequiredFunc reports whether a request to a paid route must pay. Returning false lets
+// the request through without a 402, e.g. for a valid subscription token, an internal service
+// credential, or a staff role set by preceding authentication middleware.
+type PaymentRequiredFunc func(c *gin.Context, reqCtx x402http.HTTPRequestContext) bool
+
+// WithPaymentRequiredFunc exempts the requests required returns false for from payment. They
+// skip the 402 flow entirely, send no payment events, and are marked for handlers, which
+// check xtended402.IsPaymentBypassed. Anonymous requests still pay per request.
+//
+//	ginmw.WithPaymentRequiredFunc(func(c *gin.Context, reqCtx x402http.HTTPRequestContext) bool {
+//		return !subscriptions.Active(c.GetHeader("Authorization"))
+//	})
+func WithPaymentRequiredFunc(required PaymentRequiredFunc) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.PaymentRequired = required
+	}
+}
+
+// paymentBypassed reports whether the payment-required hook exempts the request, marking it
+// if so
+func paymentBypassed(c *gin.Context, config *MiddlewareConfig, reqCtx x402http.HTTPRequestContext) bool {
+	if config.PaymentRequired == nil || config.PaymentRequired(c, reqCtx) {
+		return false
+	}
+	c.Set(xtended402.PaymentBypassedKey, true)
+	return true
+}
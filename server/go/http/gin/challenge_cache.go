@@ -0,0 +1,90 @@
+package gin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// 402 Caching
+// ============================================================================
+
+// WithChallengeMaxAge lets shared caches reuse the 402 challenges of routes matching the
+// patterns, or of every paid route without patterns, for maxAge before revalidating. The
+// longest matching pattern wins. Only use it where requirements don't vary per request: static
+// prices and pay-to addresses, and no invoices or per-request requirement extras.
+func WithChallengeMaxAge(maxAge time.Duration, routes ...string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		if len(routes) == 0 {
+			c.ChallengeMaxAge = maxAge
+			return
+		}
+		if c.ChallengeMaxAges == nil {
+			c.ChallengeMaxAges = make(map[string]time.Duration)
+		}
+		for _, route := range routes {
+			c.ChallengeMaxAges[route] = maxAge
+		}
+	}
+}
+
+// challengeMaxAge returns how long the current request's 402 may be reused without
+// revalidating
+func challengeMaxAge(c *gin.Context, config *MiddlewareConfig) time.Duration {
+	resource := resourceOf(c)
+	var matched string
+	for pattern := range config.ChallengeMaxAges {
+		if xtended402.ParseRoutePattern(pattern).Matches(resource) && len(pattern) > len(matched) {
+			matched = pattern
+		}
+	}
+	if matched != "" {
+		return config.ChallengeMaxAges[matched]
+	}
+	return config.ChallengeMaxAge
+}
+
+// markPaidContent keeps shared caches from storing what a paid route serves once it's paid
+// for. Handlers can set their own Cache-Control.
+func markPaidContent(c *gin.Context) {
+	c.Header("Cache-Control", "private, no-store")
+}
+
+// cacheChallenge labels a 402 challenge with an ETag of body and when caches may reuse it. It
+// reports whether the request's If-None-Match already names the challenge, so a 304 can be
+// sent instead.
+func cacheChallenge(c *gin.Context, config *MiddlewareConfig, body []byte) (notModified bool) {
+	hash := sha256.New()
+	hash.Write([]byte(c.Writer.Header().Get("PAYMENT-REQUIRED")))
+	hash.Write(body)
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+
+	c.Header("ETag", etag)
+	if maxAge := challengeMaxAge(c, config); maxAge > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+	// A cached challenge must not answer a request carrying a payment
+	c.Writer.Header().Add("Vary", xtended402.PaymentHeader)
+
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+	if c.GetHeader(xtended402.PaymentHeader) != "" {
+		return false
+	}
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Challenge Nonces
+// ============================================================================
+
+// challengeNonceKey holds the nonce issued with the current request's 402 in the Gin context
+const challengeNonceKey = "xtended402ChallengeNonce"
+
+// WithChallengeNonces issues a nonce with each 402 from paid routes matching routes (every paid
+// route when none), in the requirements' challengeNonce extra field. EVM payments must sign it
+// as their authorization nonce, and each nonce pays for one request to the resource it was
+// issued for. Other payments get a 402.
+func WithChallengeNonces(nonces *xtended402.ChallengeNonces, routes ...string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.ChallengeNonces = nonces
+		c.ChallengeNonceRoutes = nil
+		if len(routes) > 0 {
+			c.ChallengeNonceRoutes = xtended402.NewRouteMatcher(routes...)
+		}
+	}
+}
+
+// challengesNonce reports whether the current request's route is challenged with nonces
+func challengesNonce(c *gin.Context, config *MiddlewareConfig) bool {
+	return config.ChallengeNonces != nil &&
+		(config.ChallengeNonceRoutes == nil || config.ChallengeNonceRoutes.Match(c.Request.Method, c.Request.URL.Path))
+}
+
+// issueChallengeNonce issues a nonce for the current request's 402, which extendChallenge adds
+// to its requirements. Failures leave the challenge without one.
+func issueChallengeNonce(c *gin.Context, config *MiddlewareConfig) {
+	if !challengesNonce(c, config) {
+		return
+	}
+	nonce, err := config.ChallengeNonces.Issue(resourceOf(c))
+	if err != nil {
+		fmt.Printf("Warning: challenge nonce unavailable: %v\n", err)
+		return
+	}
+	c.Set(challengeNonceKey, nonce)
+}
+
+// challengeNonceOf returns the nonce issued with the current request's 402, or ""
+func challengeNonceOf(c *gin.Context) string {
+	return c.GetString(challengeNonceKey)
+}
+
+// checkChallengeNonce rejects an EVM payment not signed with a nonce issued for the current
+// resource. It writes the error response and returns false.
+func checkChallengeNonce(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult) bool {
+	if !challengesNonce(c, config) || !strings.HasPrefix(result.PaymentRequirements.Network, "eip155:") {
+		return true
+	}
+	err := config.ChallengeNonces.Redeem(xtended402.AuthorizationNonce(result.PaymentPayload), resourceOf(c))
+	if err == nil {
+		return true
+	}
+
+	if handler := errorHandler(c, config); handler != nil {
+		handler(c, rejectionError(StageVerify, http.StatusPaymentRequired, result.PaymentRequirements, err))
+	} else {
+		c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
+			"error":   "Invalid challenge nonce",
+			"details": err.Error(),
+		}))
+	}
+	c.Abort()
+	return false
+}
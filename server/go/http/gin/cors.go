@@ -0,0 +1,148 @@
+package gin
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// CORS
+// ============================================================================
+
+// corsAllowHeaders are the request headers browsers may send payments, sessions, and
+// references in
+var corsAllowHeaders = []string{
+	"Content-Type",
+	"Authorization",
+	"PAYMENT-SIGNATURE",
+	xtended402.SessionHeader,
+	xtended402.SessionProofHeader,
+	xtended402.APIKeyHeader,
+	xtended402.QuoteHeader,
+	xtended402.PaymentTokenHeader,
+	xtended402.WalletPaymentHeader,
+	xtended402.FiatPaymentHeader,
+}
+
+// corsExposeHeaders are the response headers browser clients need to read challenges,
+// settlements, and the extensions' state
+var corsExposeHeaders = []string{
+	"PAYMENT-REQUIRED",
+	"PAYMENT-RESPONSE",
+	"Retry-After",
+	xtended402.SessionHeader,
+	xtended402.SessionRenewalHeader,
+	xtended402.APIKeyHeader,
+	xtended402.APIKeyRemainingHeader,
+	xtended402.BalanceHeader,
+	xtended402.TopUpHeader,
+	xtended402.FiatCheckoutHeader,
+	xtended402.FreeModeHeader,
+	xtended402.InvoiceHeader,
+	xtended402.PaymentIncrementsHeader,
+}
+
+// corsConfig configures CORS
+type corsConfig struct {
+	allowHeaders  []string
+	exposeHeaders []string
+	methods       []string
+	maxAge        time.Duration
+}
+
+// CORSOption configures CORS
+type CORSOption func(*corsConfig)
+
+// WithCORSAllowHeaders allows more request headers. Repeatable.
+func WithCORSAllowHeaders(headers ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.allowHeaders = append(c.allowHeaders, headers...)
+	}
+}
+
+// WithCORSExposeHeaders exposes more response headers. Repeatable.
+func WithCORSExposeHeaders(headers ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.exposeHeaders = append(c.exposeHeaders, headers...)
+	}
+}
+
+// WithCORSMethods sets the allowed methods (default GET, HEAD, POST, PUT, PATCH, DELETE)
+func WithCORSMethods(methods ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.methods = methods
+	}
+}
+
+// WithCORSMaxAge sets how long browsers cache preflight results (default 10 minutes)
+func WithCORSMaxAge(maxAge time.Duration) CORSOption {
+	return func(c *corsConfig) {
+		c.maxAge = maxAge
+	}
+}
+
+// CORS lets browsers on origins pay for routes cross-origin. It allows the PAYMENT-SIGNATURE
+// request header, exposes PAYMENT-REQUIRED and PAYMENT-RESPONSE along with the extensions'
+// headers, and answers preflights with a 204. Mount it before PaymentMiddleware:
+//
+//	r.Use(ginmw.CORS([]string{"https://app.example.com"}))
+//	r.Use(ginmw.PaymentMiddleware(routes, server))
+//
+// Listed origins may send credentials, such as session cookies. "*" allows any origin without
+// credentials. Requests from other origins get no CORS headers, so browsers block them.
+func CORS(origins []string, opts ...CORSOption) gin.HandlerFunc {
+	config := &corsConfig{
+		allowHeaders:  slices.Clone(corsAllowHeaders),
+		exposeHeaders: slices.Clone(corsExposeHeaders),
+		methods:       []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+		maxAge:        10 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	allowHeaders := strings.Join(config.allowHeaders, ", ")
+	exposeHeaders := strings.Join(config.exposeHeaders, ", ")
+	methods := strings.Join(config.methods, ", ")
+	maxAge := strconv.Itoa(int(config.maxAge.Seconds()))
+	anyOrigin := slices.Contains(origins, "*")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+
+		switch {
+		case slices.Contains(origins, origin):
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+		case anyOrigin:
+			c.Header("Access-Control-Allow-Origin", "*")
+		default:
+			if isPreflight(c) {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if isPreflight(c) {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", allowHeaders)
+			c.Header("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Header("Access-Control-Expose-Headers", exposeHeaders)
+		c.Next()
+	}
+}
@@ -0,0 +1,129 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Developer-Mode Paywall
+// ============================================================================
+
+// WithDevPaywall adds testnet guidance to the browser paywall on routes with a testnet option:
+// the network, the test token's address, faucet links, and, with a test payer, a button paying
+// from the test wallet through DevPaymentHandler mounted at payPath. Don't use it in production.
+func WithDevPaywall(dev *xtended402.DevPaywall, payPath string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.DevPaywall = dev
+		c.DevPayPath = payPath
+	}
+}
+
+// devPaymentRequest is what the paywall's test wallet button posts
+type devPaymentRequest struct {
+	// PaymentRequired is the encoded PAYMENT-REQUIRED header to pay
+	PaymentRequired string `json:"paymentRequired"`
+}
+
+// DevPaymentHandler signs the posted challenge's testnet option with the test wallet and
+// returns the payment headers for the paywall to retry with:
+//
+//	r.POST("/dev/pay", ginmw.DevPaymentHandler(dev))
+func DevPaymentHandler(dev *xtended402.DevPaywall) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		var request devPaymentRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid test payment request", "details": err.Error()})
+			return
+		}
+		required, err := xtended402.DecodePaymentRequiredHeader(request.PaymentRequired)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid test payment request", "details": "invalid paymentRequired"})
+			return
+		}
+
+		headers, err := dev.Pay(c.Request.Context(), *required)
+		switch {
+		case errors.Is(err, xtended402.ErrNoTestPayer), errors.Is(err, xtended402.ErrNoTestnetOption):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Test payment unavailable", "details": err.Error()})
+		case err != nil:
+			fmt.Printf("Warning: test wallet payment failed: %v\n", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Test payment failed", "details": err.Error()})
+		default:
+			c.JSON(http.StatusOK, gin.H{"headers": headers})
+		}
+	}
+}
+
+// guideTestnet adds testnet guidance to x402's built-in paywall page
+func guideTestnet(c *gin.Context, server *x402http.HTTPServer, config *MiddlewareConfig, response *x402http.HTTPResponseInstructions) {
+	page, ok := response.Body.(string)
+	if !ok {
+		return
+	}
+	required, header, err := challenge(c, server, config, response)
+	if err != nil {
+		fmt.Printf("Warning: testnet guidance unavailable: %v\n", err)
+		return
+	}
+	if guide := config.DevPaywall.Guide(required.Accepts); guide != nil {
+		response.Body = insertBefore(page, "</body>", testnetWidget(c, config, guide, header))
+	}
+}
+
+// testnetWidget renders a testnet guide, with a script behind the test wallet button that
+// pays through DevPaymentHandler and replaces the page with the paid response
+func testnetWidget(c *gin.Context, config *MiddlewareConfig, guide *xtended402.TestnetGuide, header string) string {
+	token := html.EscapeString(guide.Asset)
+	if guide.Symbol != "" {
+		token += " (" + html.EscapeString(guide.Symbol) + ")"
+	}
+
+	var widget strings.Builder
+	fmt.Fprintf(&widget,
+		`<div id="xtended402-dev" style="margin:1.5rem auto;max-width:600px;padding:1rem;border:1px dashed #d97706;border-radius:6px;font-family:system-ui,sans-serif;font-size:14px">`+
+			`<p><strong>%s</strong></p><p>%s <code>%s</code><br>%s <code>%s</code></p>`,
+		html.EscapeString(translate(c, "Testnet payment (developer mode)")),
+		html.EscapeString(translate(c, "Network:")), html.EscapeString(string(guide.Network)),
+		html.EscapeString(translate(c, "Test token:")), token,
+	)
+
+	if len(guide.Faucets) > 0 {
+		links := make([]string, 0, len(guide.Faucets))
+		for _, faucet := range guide.Faucets {
+			links = append(links, fmt.Sprintf(`<a href="%s" target="_blank" rel="noopener">%s</a>`, html.EscapeString(faucet.URL), html.EscapeString(faucet.Label)))
+		}
+		fmt.Fprintf(&widget, `<p>%s %s</p>`, html.EscapeString(translate(c, "Get test tokens:")), strings.Join(links, " &middot; "))
+	}
+
+	if guide.TestWallet != "" && config.DevPayPath != "" {
+		script := fmt.Sprintf(`(function(){var url=%s,required=%s,failed=%s,button=document.getElementById("xtended402-dev-pay"),status=document.getElementById("xtended402-dev-status");`+
+			`button.onclick=function(){button.disabled=true;status.textContent="";`+
+			`fetch(url,{method:"POST",headers:{"Content-Type":"application/json"},body:JSON.stringify({paymentRequired:required})})`+
+			`.then(function(r){return r.json().then(function(b){if(!r.ok)throw new Error(b.details||b.error);return b.headers})})`+
+			`.then(function(h){return fetch(location.href,{headers:h,credentials:"same-origin"})})`+
+			`.then(function(r){return r.text().then(function(t){if(r.status===402)throw new Error(failed);document.open();document.write(t);document.close()})})`+
+			`.catch(function(e){button.disabled=false;status.textContent=e.message})}})();`,
+			jsString(config.DevPayPath), jsString(header), jsString(translate(c, "The test payment was rejected. Is the test wallet funded?")),
+		)
+		fmt.Fprintf(&widget,
+			`<p>%s <code>%s</code></p><button id="xtended402-dev-pay" type="button">%s</button>`+
+				`<p id="xtended402-dev-status" style="color:#b91c1c"></p><script>%s</script>`,
+			html.EscapeString(translate(c, "Test wallet:")), html.EscapeString(guide.TestWallet),
+			html.EscapeString(translate(c, "Pay %s with test wallet", guide.Amount)),
+			script,
+		)
+	}
+
+	widget.WriteString(`</div>`)
+	return widget.String()
+}
@@ -0,0 +1,60 @@
+package gin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Discovery
+// ============================================================================
+
+// DiscoveryHandler serves a catalog of the paid routes, their accepted schemes, networks, and
+// assets, and their static prices, for agents discovering paid capabilities. Mount it at
+// xtended402.WellKnownPath:
+//
+//	r.GET(xtended402.WellKnownPath, ginmw.DiscoveryHandler(routes, server))
+//
+// The server must be the one used by PaymentMiddleware so facilitator capabilities are synced.
+// Routes listed with WithBazaarListing are cataloged with their listing only if routes has it
+// applied, e.g. with xtended402.ListOnBazaar.
+func DiscoveryHandler(routes x402http.RoutesConfig, server *x402.X402ResourceServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		c.Header("Cache-Control", "public, max-age=300")
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.JSON(http.StatusOK, xtended402.BuildCatalog(ctx, routes, server))
+	}
+}
+
+// ResourceCatalogHandler serves catalog, with dynamic prices quoted for its samples, for
+// marketplaces indexing paid routes:
+//
+//	catalog := xtended402.NewResourceCatalog(routes, server,
+//		xtended402.WithCatalogSamples("POST /api/purchase", xtended402.CatalogSample{
+//			Label:   "1 item",
+//			Context: []xtended402.ContextValue{xtended402.PriceKey.Bind("$5.00")},
+//		}),
+//	)
+//	r.GET("/api/catalog", ginmw.ResourceCatalogHandler(catalog))
+//
+// Responses are cacheable for the catalog's TTL.
+func ResourceCatalogHandler(catalog *xtended402.ResourceCatalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		defer cancel()
+
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(catalog.TTL().Seconds())))
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.JSON(http.StatusOK, catalog.Catalog(ctx))
+	}
+}
@@ -0,0 +1,54 @@
+package gin_test
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	ginmw "github.com/mvpoyatt/xtended402/server/go/http/gin"
+	"github.com/mvpoyatt/xtended402/server/go/testing/e2e"
+	"github.com/mvpoyatt/xtended402/server/go/testing/facilitatortest"
+)
+
+// TestDuplicatePayments submits one payment many times at once. Besides a single settlement,
+// the protected handler must run exactly once: a duplicate that reached it would deliver the
+// purchase twice even when its settlement failed. With "after" timing that takes payment
+// locks, since duplicates reach the handler before either settles. Run with -race.
+func TestDuplicatePayments(t *testing.T) {
+	tests := []struct {
+		timing string
+		opts   []ginmw.MiddlewareOption
+	}{
+		{timing: "before"},
+		{timing: "after", opts: []ginmw.MiddlewareOption{ginmw.WithPaymentLocks(xtended402.NewMemoryPaymentLocker())}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.timing, func(t *testing.T) {
+			var handled atomic.Int32
+			h := e2e.New(t, testRoutes, func(r *gin.Engine, paid gin.HandlerFunc) {
+				r.POST("/api/purchase", paid, func(c *gin.Context) {
+					handled.Add(1)
+					c.JSON(http.StatusOK, gin.H{"order": "ord_1"})
+				})
+			},
+				e2e.WithFacilitator(facilitatortest.New(
+					facilitatortest.WithNetworks(string(e2e.DefaultNetwork)),
+					facilitatortest.WithReplayProtection(),
+					facilitatortest.WithLatency(time.Millisecond, 5*time.Millisecond),
+				)),
+				e2e.WithMiddlewareOptions(append(tt.opts, ginmw.WithSettlementTiming(tt.timing))...),
+			)
+
+			dups := h.PayDuplicates(t, 20, "POST", "/api/purchase", `{}`)
+			dups.AssertSettledOnce(t)
+
+			if n := handled.Load(); n != 1 {
+				t.Fatalf("protected handler ran %d times for one payment, want 1", n)
+			}
+		})
+	}
+}
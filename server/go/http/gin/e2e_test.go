@@ -0,0 +1,199 @@
+package gin_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+
+	ginmw "github.com/mvpoyatt/xtended402/server/go/http/gin"
+	"github.com/mvpoyatt/xtended402/server/go/testing/e2e"
+	"github.com/mvpoyatt/xtended402/server/go/testing/facilitatortest"
+)
+
+var testRoutes = x402http.RoutesConfig{
+	"POST /api/purchase": {
+		Accepts: x402http.PaymentOptions{{
+			Scheme:  "exact",
+			Network: e2e.DefaultNetwork,
+			PayTo:   "0x1111111111111111111111111111111111111111",
+			Price:   "$0.01",
+		}},
+	},
+	"GET /api/download": {
+		Accepts: x402http.PaymentOptions{{
+			Scheme:  "exact",
+			Network: e2e.DefaultNetwork,
+			PayTo:   "0x1111111111111111111111111111111111111111",
+			Price:   "$0.01",
+		}},
+	},
+}
+
+// download is larger than the streaming tests' response buffer
+var download = bytes.Repeat([]byte("0123456789abcdef"), 1024)
+
+func newHarness(t *testing.T, opts ...ginmw.MiddlewareOption) *e2e.Harness {
+	t.Helper()
+
+	return e2e.New(t, testRoutes, func(r *gin.Engine, paid gin.HandlerFunc) {
+		r.POST("/api/purchase", paid, func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"order": "ord_1"})
+		})
+		r.GET("/api/download", paid, func(c *gin.Context) {
+			c.Header("Content-Type", "application/octet-stream")
+			for i := 0; i < len(download); i += 1024 {
+				_, _ = c.Writer.Write(download[i : i+1024])
+			}
+		})
+	}, e2e.WithMiddlewareOptions(opts...))
+}
+
+func TestSettleBefore(t *testing.T) {
+	h := newHarness(t, ginmw.WithSettlementTiming("before"))
+
+	if resp := h.Do(t, "POST", "/api/purchase", `{}`); resp.Code != http.StatusPaymentRequired || resp.PaymentRequired == nil {
+		t.Fatalf("unpaid request: got %d, want a 402 challenge", resp.Code)
+	}
+
+	resp := h.Pay(t, "POST", "/api/purchase", `{}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("paid request: got %d: %s", resp.Code, resp.Body)
+	}
+	if resp.Settlement == nil || !resp.Settlement.Success {
+		t.Fatalf("paid request: missing successful settlement, got %+v", resp.Settlement)
+	}
+	if settlements := len(h.Facilitator.Settlements()); settlements != 1 {
+		t.Fatalf("got %d settlements, want 1", settlements)
+	}
+}
+
+func TestSettleAfter(t *testing.T) {
+	h := newHarness(t, ginmw.WithSettlementTiming("after"))
+
+	resp := h.Pay(t, "POST", "/api/purchase", `{}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("paid request: got %d: %s", resp.Code, resp.Body)
+	}
+	if resp.Settlement == nil || !resp.Settlement.Success {
+		t.Fatalf("paid request: missing successful settlement, got %+v", resp.Settlement)
+	}
+
+	var body struct {
+		Order string `json:"order"`
+	}
+	resp.JSON(t, &body)
+	if body.Order != "ord_1" {
+		t.Fatalf("got order %q, want the handler's response", body.Order)
+	}
+}
+
+func TestSettleAfterFailedSettlement(t *testing.T) {
+	var paymentErr *ginmw.PaymentError
+	h := newHarness(t,
+		ginmw.WithSettlementTiming("after"),
+		ginmw.WithErrorHandler(func(c *gin.Context, err *ginmw.PaymentError) {
+			paymentErr = err
+			c.JSON(err.Status, gin.H{"code": err.Code})
+		}),
+	)
+	h.Facilitator.OnSettle(facilitatortest.SettleFails("insufficient_funds"))
+
+	resp := h.Pay(t, "POST", "/api/purchase", `{}`)
+	if resp.Code != http.StatusPaymentRequired {
+		t.Fatalf("got %d, want 402: %s", resp.Code, resp.Body)
+	}
+	if bytes.Contains(resp.Body.Bytes(), []byte("ord_1")) {
+		t.Fatalf("the handler's response leaked past a failed settlement: %s", resp.Body)
+	}
+	if resp.Settlement != nil && resp.Settlement.Success {
+		t.Fatalf("got a successful settlement header: %+v", resp.Settlement)
+	}
+	if paymentErr == nil || paymentErr.Stage != ginmw.StageSettle || paymentErr.Code != "insufficient_funds" {
+		t.Fatalf("got payment error %v at %q with code %q, want a settle-stage insufficient_funds", paymentErr, paymentErr.Stage, paymentErr.Code)
+	}
+}
+
+func TestStreamingRoute(t *testing.T) {
+	h := newHarness(t,
+		ginmw.WithSettlementTiming("after"),
+		ginmw.WithStreamingResponses("GET /api/download"),
+		ginmw.WithMaxResponseBuffer(4096),
+	)
+
+	resp := h.Pay(t, "GET", "/api/download", nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("paid download: got %d", resp.Code)
+	}
+	if !bytes.Equal(resp.Body.Bytes(), download) {
+		t.Fatalf("got %d bytes, want the full %d-byte download", resp.Body.Len(), len(download))
+	}
+
+	trailer := resp.Result().Trailer
+	var settlement x402.SettleResponse
+	data, err := base64.StdEncoding.DecodeString(trailer.Get("PAYMENT-RESPONSE"))
+	if err == nil {
+		err = json.Unmarshal(data, &settlement)
+	}
+	if err != nil || !settlement.Success {
+		t.Fatalf("missing successful settlement trailer: %v %+v", err, settlement)
+	}
+	if status := trailer.Get(ginmw.StreamStatusTrailer); status != "" {
+		t.Fatalf("got stream status %s on a settled download", status)
+	}
+}
+
+func TestStreamingRouteFailedSettlement(t *testing.T) {
+	h := newHarness(t,
+		ginmw.WithSettlementTiming("after"),
+		ginmw.WithStreamingResponses("GET /api/download"),
+		ginmw.WithMaxResponseBuffer(4096),
+	)
+	h.Facilitator.OnSettle(facilitatortest.SettleFails("insufficient_funds"))
+
+	resp := h.Pay(t, "GET", "/api/download", nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("streamed download: got %d, want the already-sent 200", resp.Code)
+	}
+	status := resp.Result().Trailer.Get(ginmw.StreamStatusTrailer)
+	if status != strconv.Itoa(http.StatusPaymentRequired) {
+		t.Fatalf("got stream status %q, want 402 after the settlement failed", status)
+	}
+}
+
+func TestPreflightOnAnyMethodRoute(t *testing.T) {
+	routes := x402http.RoutesConfig{
+		"/api/any": {
+			Accepts: x402http.PaymentOptions{{
+				Scheme:  "exact",
+				Network: e2e.DefaultNetwork,
+				PayTo:   "0x1111111111111111111111111111111111111111",
+				Price:   "$0.01",
+			}},
+		},
+	}
+	var handled bool
+	h := e2e.New(t, routes, func(r *gin.Engine, paid gin.HandlerFunc) {
+		r.Any("/api/any", paid, func(c *gin.Context) {
+			handled = true
+			c.JSON(http.StatusOK, gin.H{"secret": true})
+		})
+	})
+
+	resp := h.Do(t, "OPTIONS", "/api/any", nil, map[string]string{
+		"Origin":                        "https://evil.example.com",
+		"Access-Control-Request-Method": "GET",
+	})
+	if handled {
+		t.Fatal("a forged preflight reached the paid handler")
+	}
+	if resp.Code != http.StatusNoContent || resp.Body.Len() != 0 {
+		t.Fatalf("got %d %q, want an empty 204", resp.Code, resp.Body)
+	}
+}
@@ -0,0 +1,31 @@
+package gin
+
+import (
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Exemptions
+// ============================================================================
+
+// WithExemptions serves requests matching the patterns without payment, whatever paid route
+// covers them, so a whole prefix can be paid while health checks, webhooks, and CORS
+// preflights aren't:
+//
+//	routes := x402http.RoutesConfig{"/api/*": apiRoute}
+//	ginmw.WithExemptions("GET /api/health", "POST /api/webhooks/*", "OPTIONS /*")
+//
+// Patterns take the route pattern syntax, including regular expressions. Repeatable.
+func WithExemptions(patterns ...string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Exemptions = append(c.Exemptions, patterns...)
+	}
+}
+
+// exemptions compiles the exemption patterns, or returns nil without any
+func exemptions(config *MiddlewareConfig) *xtended402.RouteMatcher {
+	if len(config.Exemptions) == 0 {
+		return nil
+	}
+	return xtended402.NewRouteMatcher(config.Exemptions...)
+}
@@ -0,0 +1,51 @@
+package gin
+
+import (
+	"github.com/coinbase/x402/go/extensions/bazaar"
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// ============================================================================
+// Resource Server Extensions
+// ============================================================================
+
+// WithResourceServerExtension registers an x402 resource server extension with the
+// middleware's server. Repeat it for each extension; a later extension with the same key
+// replaces an earlier one.
+func WithResourceServerExtension(extension x402types.ResourceServerExtension) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		if extension != nil {
+			c.Extensions = append(c.Extensions, extension)
+		}
+	}
+}
+
+// WithExtensions registers several resource server extensions, as WithResourceServerExtension
+// does for each
+func WithExtensions(extensions ...x402types.ResourceServerExtension) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		for _, extension := range extensions {
+			WithResourceServerExtension(extension)(c)
+		}
+	}
+}
+
+// WithBazaar registers the Bazaar discovery extension, so facilitators can catalog the paid
+// routes (default false). WithBazaarListing implies it.
+func WithBazaar(enabled bool) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Bazaar = enabled
+	}
+}
+
+// registerExtensions registers the configured extensions, Bazaar first when enabled or implied
+// by a listing
+func registerExtensions(server *x402http.HTTPServer, config *MiddlewareConfig) {
+	if config.Bazaar || len(config.BazaarListings) > 0 {
+		server.RegisterExtension(bazaar.BazaarResourceServerExtension)
+	}
+	for _, extension := range config.Extensions {
+		server.RegisterExtension(extension)
+	}
+}
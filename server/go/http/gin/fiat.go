@@ -0,0 +1,249 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Fiat Fallback
+// ============================================================================
+
+// WithFiatFallback offers card payment alongside x402 on every paid route. 402 responses get a
+// checkout link from provider (the X-Fiat-Checkout header, a "fiatCheckout" field in JSON
+// bodies, and a link on the paywall page), and requests carrying a paid checkout's reference
+// in the X-Fiat-Payment header are let through without an x402 payment.
+func WithFiatFallback(provider xtended402.FiatProvider) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.FiatProvider = provider
+	}
+}
+
+// challenge returns the 402 challenge for the current request and its encoded
+// PAYMENT-REQUIRED header
+func challenge(c *gin.Context, server *x402http.HTTPServer, config *MiddlewareConfig, response *x402http.HTTPResponseInstructions) (*x402types.PaymentRequired, string, error) {
+	header := response.Headers["PAYMENT-REQUIRED"]
+	if header == "" {
+		// Browser paywalls don't carry the header; ask again as an API client
+		ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.verifyTimeout())
+		defer cancel()
+
+		result := server.ProcessHTTPRequest(ctx, x402http.HTTPRequestContext{
+			Adapter: apiAdapter{NewGinAdapter(c)},
+			Path:    routePath(c),
+			Method:  c.Request.Method,
+		}, config.PaywallConfig)
+		if result.Response != nil {
+			header = extendChallenge(c, result.Response.Headers["PAYMENT-REQUIRED"])
+		}
+	}
+	if header == "" {
+		return nil, "", errors.New("no payment requirements for request")
+	}
+
+	required, err := xtended402.DecodePaymentRequiredHeader(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(required.Accepts) == 0 {
+		return nil, "", errors.New("no payment requirements for request")
+	}
+	return required, header, nil
+}
+
+// challengeRequirements returns the requirements of a 402 challenge for the current request
+func challengeRequirements(c *gin.Context, server *x402http.HTTPServer, config *MiddlewareConfig, response *x402http.HTTPResponseInstructions) ([]x402types.PaymentRequirements, error) {
+	required, _, err := challenge(c, server, config, response)
+	if err != nil {
+		return nil, err
+	}
+	return required.Accepts, nil
+}
+
+// offerFiatCheckout adds a checkout link to a 402 response and returns the checkout. Failures
+// leave the x402 challenge as it is and return nil.
+func offerFiatCheckout(c *gin.Context, server *x402http.HTTPServer, config *MiddlewareConfig, response *x402http.HTTPResponseInstructions) *xtended402.FiatCheckout {
+	requirements, err := challengeRequirements(c, server, config, response)
+	if err != nil {
+		fmt.Printf("Warning: fiat checkout unavailable: %v\n", err)
+		return nil
+	}
+
+	ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.verifyTimeout())
+	defer cancel()
+
+	checkout, err := config.FiatProvider.Checkout(ctx, resourceOf(c), requirements[0])
+	if err != nil {
+		fmt.Printf("Warning: fiat checkout unavailable: %v\n", err)
+		return nil
+	}
+
+	c.Header(xtended402.FiatCheckoutHeader, checkout.URL)
+
+	switch body := response.Body.(type) {
+	case nil:
+		response.Body = gin.H{"fiatCheckout": checkout}
+	case map[string]interface{}:
+		body["fiatCheckout"] = checkout
+	case gin.H:
+		body["fiatCheckout"] = checkout
+	case *xtended402.PaymentRequiredBody:
+		body.FiatCheckout = checkout
+	case string:
+		if response.IsHTML {
+			response.Body = injectCheckoutLink(c, body, checkout)
+		}
+	}
+	return checkout
+}
+
+// injectCheckoutLink adds a "pay by card" link to the end of a paywall page
+func injectCheckoutLink(c *gin.Context, page string, checkout *xtended402.FiatCheckout) string {
+	link := fmt.Sprintf(
+		`<div style="text-align:center;margin:1.5rem auto;font-family:system-ui,sans-serif">`+
+			`<a href="%s" style="color:#2563eb">%s</a></div>`,
+		html.EscapeString(checkout.URL),
+		html.EscapeString(translate(c, "Pay %s %s by card instead", checkout.Amount, checkout.Currency)),
+	)
+	return insertBefore(page, "</body>", link)
+}
+
+// handleFiatPayment lets a request paid by card through to the handler
+func handleFiatPayment(
+	c *gin.Context,
+	server *x402http.HTTPServer,
+	response *x402http.HTTPResponseInstructions,
+	config *MiddlewareConfig,
+	requestBody []byte,
+	reference string,
+) {
+	requirements, err := challengeRequirements(c, server, config, response)
+	if err != nil {
+		rejectFiatPayment(c, config, err)
+		return
+	}
+
+	ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.verifyTimeout())
+	defer cancel()
+
+	resource := resourceOf(c)
+	payment, err := config.FiatProvider.Redeem(ctx, resource, reference, requirements[0])
+	if err != nil {
+		rejectFiatPayment(c, config, err)
+		return
+	}
+
+	paymentData := &xtended402.PaymentData{
+		SettleResponse: &x402.SettleResponse{
+			Success:     true,
+			Transaction: payment.Transaction,
+			Network:     xtended402.FiatNetwork,
+			Payer:       payment.Customer,
+		},
+		PaymentRequirements: &requirements[0],
+		RequestBody:         requestBody,
+		FiatPayment:         payment,
+		RouteParams:         xtended402.GetRouteParams(c),
+		OrderReference:      xtended402.OrderReferenceFromContext(c.Request.Context()),
+		Tax:                 xtended402.TaxFromContext(c.Request.Context()),
+	}
+	c.Set(xtended402.PaymentDataKey, paymentData)
+
+	if settlementTiming(c, config) == "before" {
+		fiatSettled(c, config, paymentData)
+		c.Next()
+		return
+	}
+
+	// Settle-after: only keep the payment if the handler succeeds
+	writer := acquireCapture(c.Writer)
+	c.Writer = writer
+
+	c.Next()
+
+	writer.restore(c)
+	defer releaseCapture(writer)
+	if c.IsAborted() || writer.statusCode >= 400 {
+		if err := config.FiatProvider.Release(c.Request.Context(), reference); err != nil {
+			fmt.Printf("Warning: failed to release fiat payment %s: %v\n", reference, err)
+		}
+	} else {
+		fiatSettled(c, config, paymentData)
+	}
+
+	writer.replay()
+}
+
+// fiatSettled creates the order, runs the settlement handler, issues the session, and emits
+// payment.settled for a card payment
+func fiatSettled(c *gin.Context, config *MiddlewareConfig, paymentData *xtended402.PaymentData) {
+	payment := paymentData.FiatPayment
+	if config.OrderConnector != nil {
+		// The card has already been charged, so a failed order is only logged
+		orderID, err := config.OrderConnector.CreateOrder(c.Request.Context(), xtended402.OrderRequest{
+			Resource:    resourceOf(c),
+			Scheme:      payment.Provider,
+			Network:     xtended402.FiatNetwork,
+			Asset:       payment.Currency,
+			Amount:      payment.Amount,
+			PayTo:       paymentData.PaymentRequirements.PayTo,
+			Payer:       payment.Customer,
+			RequestBody: paymentData.RequestBody,
+		})
+		if err != nil {
+			fmt.Printf("Warning: failed to create order for fiat payment %s: %v\n", payment.Reference, err)
+		}
+		paymentData.OrderID = orderID
+	}
+
+	if handler := settlementHandler(c, config); handler != nil {
+		handler(c, paymentData.SettleResponse)
+	}
+	if len(config.EventHandlers) == 0 && paymentData.OrderID == "" && config.Sessions == nil && config.APIKeys == nil {
+		return
+	}
+
+	event := xtended402.NewPaymentEvent(xtended402.EventPaymentSettled, config.clock(), nil, nil)
+	event.Resource = resourceOf(c)
+	event.Scheme = payment.Provider
+	event.Network = xtended402.FiatNetwork
+	event.Asset = payment.Currency
+	event.Amount = payment.Amount
+	event.Payer = payment.Customer
+	event.Transaction = payment.Transaction
+	event = withScopes(event, xtended402.ResourceScopes(config.routes(), event.Resource))
+	event = withOrderID(event, paymentData.OrderID)
+	event = withOrderReference(event, paymentData.OrderReference)
+	event = withTax(event, paymentData.Tax)
+	event = withQuoteID(c, event)
+	issueSession(c, config, event)
+	mintAPIKey(c, config, event)
+	markOrderPaid(c, config, paymentData.OrderID, event)
+	settleQuote(c, config, event)
+	emit(c, config, event)
+}
+
+func rejectFiatPayment(c *gin.Context, config *MiddlewareConfig, err error) {
+	if handler := errorHandler(c, config); handler != nil {
+		handler(c, rejectionError(StageVerify, http.StatusPaymentRequired, nil, fmt.Errorf("fiat payment rejected: %w", err)))
+	} else {
+		c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
+			"error":   "Fiat payment rejected",
+			"details": err.Error(),
+		}))
+	}
+	c.Abort()
+}
+
+// resourceOf names the request as "METHOD /path"
+func resourceOf(c *gin.Context) string {
+	return c.Request.Method + " " + c.Request.URL.Path
+}
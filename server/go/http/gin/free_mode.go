@@ -0,0 +1,33 @@
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Free Mode
+// ============================================================================
+
+// WithFreeMode serves the routes free mode covers without payment while it's on. Waived
+// responses carry the reason in the X-Payment-Waived header; handlers read it with
+// xtended402.GetFreeModeReason. No payment events are sent for them.
+func WithFreeMode(freeMode *xtended402.FreeMode) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.FreeMode = freeMode
+	}
+}
+
+// paymentWaived reports whether free mode covers the request, marking it if so
+func paymentWaived(c *gin.Context, config *MiddlewareConfig) bool {
+	if config.FreeMode == nil {
+		return false
+	}
+	reason, ok := config.FreeMode.Waives(resourceOf(c))
+	if !ok {
+		return false
+	}
+	c.Set(xtended402.FreeModeKey, reason)
+	c.Header(xtended402.FreeModeHeader, reason)
+	return true
+}
@@ -0,0 +1,77 @@
+package gin
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================================
+// Connection Hijacking
+// ============================================================================
+
+var (
+	// errHijackWritten is returned when a handler hijacks after writing a response, which
+	// the capture would otherwise send over the hijacked connection
+	errHijackWritten = errors.New("cannot hijack: response already written")
+
+	// errHijackUnsupported is returned when the server's response writer can't be hijacked,
+	// e.g. under HTTP/2 or in tests
+	errHijackUnsupported = errors.New("cannot hijack: response writer doesn't support it")
+)
+
+// Hijack hands the connection to the handler, e.g. for a websocket upgrade. Nothing is sent
+// for the handler afterwards: the payment settles when it returns, as for a 101 Switching
+// Protocols response, unless it set a failing status with SetStreamStatus first.
+func (w *responseCapture) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.written || w.hijacked {
+		return nil, nil, errHijackWritten
+	}
+	if _, ok := unwrapGin(w.ResponseWriter).(http.Hijacker); !ok {
+		return nil, nil, errHijackUnsupported
+	}
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.hijacked = true
+	w.written = true
+	w.statusCode = http.StatusSwitchingProtocols
+	return conn, rw, nil
+}
+
+// CloseNotify returns the wrapped writer's close notifications, or a channel that's never
+// signaled where the server's writer has none, rather than panicking as gin's writer does
+func (w *responseCapture) CloseNotify() <-chan bool {
+	if _, ok := unwrapGin(w.ResponseWriter).(http.CloseNotifier); !ok {
+		return make(chan bool)
+	}
+	return w.ResponseWriter.CloseNotify()
+}
+
+// Unwrap returns the wrapped writer, so http.ResponseController can set the connection's
+// deadlines. Its Flush and Hijack still go through the capture.
+func (w *responseCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// unwrapGin returns the writer beneath gin's writers, whose optional interfaces gin asserts
+// without checking
+func unwrapGin(w http.ResponseWriter) http.ResponseWriter {
+	for {
+		if _, ok := w.(gin.ResponseWriter); !ok {
+			return w
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return w
+		}
+		w = unwrapper.Unwrap()
+	}
+}
@@ -0,0 +1,95 @@
+package gin
+
+import (
+	"html"
+	"strings"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Localization
+// ============================================================================
+
+// localizerKey holds the Localizer on paid requests so error responses can be translated
+// without the middleware config
+const localizerKey = "xtended402Localizer"
+
+// WithLocalizer negotiates each paid request's locale from Accept-Language and translates the
+// built-in paywall page and error payloads into it. Error payloads keep their English "error"
+// for clients to match on and add the translation as "message".
+func WithLocalizer(localizer *xtended402.Localizer) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Localizer = localizer
+	}
+}
+
+// negotiateLocale adds the request's locale to its context
+func negotiateLocale(c *gin.Context, config *MiddlewareConfig) {
+	if config.Localizer == nil {
+		return
+	}
+	c.Set(xtended402.LocaleKey, config.Localizer.Negotiate(c.GetHeader("Accept-Language")))
+	c.Set(localizerKey, config.Localizer)
+}
+
+// localizerOf returns the request's Localizer, or nil without WithLocalizer
+func localizerOf(c *gin.Context) *xtended402.Localizer {
+	value, _ := c.Get(localizerKey)
+	localizer, _ := value.(*xtended402.Localizer)
+	return localizer
+}
+
+// translate returns message in the request's locale, formatted with args
+func translate(c *gin.Context, message string, args ...interface{}) string {
+	return localizerOf(c).Translate(xtended402.GetLocale(c), message, args...)
+}
+
+// markLocalized labels the response with the request's locale
+func markLocalized(c *gin.Context) {
+	c.Header("Content-Language", xtended402.GetLocale(c))
+	c.Writer.Header().Add("Vary", "Accept-Language")
+}
+
+// errorBody adds the translation of body's "error" as "message" when the request is localized
+func errorBody(c *gin.Context, body gin.H) gin.H {
+	if localizerOf(c) == nil {
+		return body
+	}
+	if message, ok := body["error"].(string); ok {
+		body["message"] = translate(c, message)
+		markLocalized(c)
+	}
+	return body
+}
+
+// paywallLabels are the English labels on x402's built-in paywall page
+var paywallLabels = []struct {
+	html    string
+	message string
+}{
+	{"<title>Payment Required</title>", "Payment Required"},
+	{"<h1>Payment Required</h1>", "Payment Required"},
+	{"<strong>Resource:</strong>", "Resource:"},
+	{`<p class="amount">Amount:`, "Amount:"},
+	{"<p>Loading payment widget...</p>", "Loading payment widget..."},
+}
+
+// localizePaywall translates the labels on x402's built-in paywall page
+func localizePaywall(c *gin.Context, response *x402http.HTTPResponseInstructions) {
+	page, ok := response.Body.(string)
+	if !ok {
+		return
+	}
+
+	locale := xtended402.GetLocale(c)
+	replacements := []string{"<html>", `<html lang="` + locale + `">`}
+	for _, label := range paywallLabels {
+		translated := strings.Replace(label.html, label.message, html.EscapeString(translate(c, label.message)), 1)
+		replacements = append(replacements, label.html, translated)
+	}
+	response.Body = strings.NewReplacer(replacements...).Replace(page)
+	markLocalized(c)
+}
@@ -0,0 +1,170 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Deferred Invoices
+// ============================================================================
+
+// WithInvoices offers an invoice on JSON 402 responses from paid routes matching routes (every
+// paid route when none), in the body and the X-Invoice-ID header. The invoice can be paid out
+// of band, recorded with Invoices.MarkPaid, and redeemed by retrying the request with its ID in
+// X-Invoice-ID.
+func WithInvoices(invoices *xtended402.Invoices, routes ...string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Invoices = invoices
+		c.InvoiceRoutes = nil
+		if len(routes) > 0 {
+			c.InvoiceRoutes = xtended402.NewRouteMatcher(routes...)
+		}
+	}
+}
+
+// InvoiceStatusHandler serves the invoice named by the :id path parameter, for clients to poll
+// until it's paid:
+//
+//	r.GET("/invoices/:id", ginmw.InvoiceStatusHandler(invoices))
+func InvoiceStatusHandler(invoices *xtended402.Invoices) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		invoice, err := invoices.Get(c.Request.Context(), c.Param("id"))
+		switch {
+		case errors.Is(err, xtended402.ErrInvoiceInvalid):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown invoice"})
+		case err != nil:
+			fmt.Printf("Warning: failed to load invoice: %v\n", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Invoice status unavailable"})
+		default:
+			c.JSON(http.StatusOK, invoice)
+		}
+	}
+}
+
+// offerInvoice issues an invoice for a JSON 402 and adds it to the response. Failures leave the
+// x402 challenge as it is.
+func offerInvoice(c *gin.Context, server *x402http.HTTPServer, config *MiddlewareConfig, response *x402http.HTTPResponseInstructions) {
+	if response.IsHTML || (config.InvoiceRoutes != nil && !config.InvoiceRoutes.Match(c.Request.Method, c.Request.URL.Path)) {
+		return
+	}
+
+	requirements, err := challengeRequirements(c, server, config, response)
+	if err != nil {
+		fmt.Printf("Warning: invoice unavailable: %v\n", err)
+		return
+	}
+	invoice, err := config.Invoices.Issue(c.Request.Context(), resourceOf(c), requirements[0])
+	if err != nil {
+		fmt.Printf("Warning: invoice unavailable: %v\n", err)
+		return
+	}
+
+	response.Headers[xtended402.InvoiceHeader] = invoice.ID
+	switch body := response.Body.(type) {
+	case nil:
+		response.Body = gin.H{"invoice": invoice}
+	case map[string]interface{}:
+		body["invoice"] = invoice
+	case gin.H:
+		body["invoice"] = invoice
+	case *xtended402.PaymentRequiredBody:
+		body.Invoice = invoice
+	}
+}
+
+// handleInvoicePayment serves a request redeeming a paid invoice. With settle-after timing the
+// invoice is released if the handler fails, so it can be redeemed again.
+func handleInvoicePayment(c *gin.Context, config *MiddlewareConfig, requestBody []byte, id string) {
+	invoice, err := config.Invoices.Redeem(c.Request.Context(), id, resourceOf(c))
+	if err != nil {
+		if handler := errorHandler(c, config); handler != nil {
+			handler(c, rejectionError(StageVerify, http.StatusPaymentRequired, nil, fmt.Errorf("invoice rejected: %w", err)))
+		} else {
+			c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
+				"error":   "Invoice rejected",
+				"details": err.Error(),
+			}))
+		}
+		c.Abort()
+		return
+	}
+
+	paymentData := &xtended402.PaymentData{
+		SettleResponse: &x402.SettleResponse{
+			Success:     true,
+			Transaction: invoice.Transaction,
+			Network:     invoice.Network,
+			Payer:       invoice.Payer,
+		},
+		RequestBody:    requestBody,
+		Invoice:        invoice,
+		RouteParams:    xtended402.GetRouteParams(c),
+		PayerName:      payerName(c, config, invoice.Network, invoice.Payer),
+		OrderReference: xtended402.OrderReferenceFromContext(c.Request.Context()),
+		Tax:            xtended402.TaxFromContext(c.Request.Context()),
+	}
+	c.Set(xtended402.PaymentDataKey, paymentData)
+
+	if settlementTiming(c, config) == "before" {
+		invoiceRedeemed(c, config, paymentData)
+		c.Next()
+		return
+	}
+
+	// Settle-after: only keep the redemption if the handler succeeds
+	writer := acquireCapture(c.Writer)
+	c.Writer = writer
+
+	c.Next()
+
+	writer.restore(c)
+	defer releaseCapture(writer)
+	if c.IsAborted() || writer.statusCode >= 400 {
+		if err := config.Invoices.Release(c.Request.Context(), id); err != nil {
+			fmt.Printf("Warning: failed to release invoice %s: %v\n", id, err)
+		}
+	} else {
+		invoiceRedeemed(c, config, paymentData)
+	}
+
+	writer.replay()
+}
+
+// invoiceRedeemed runs the settlement handler, issues the session, and emits payment.settled
+// for a redeemed invoice
+func invoiceRedeemed(c *gin.Context, config *MiddlewareConfig, paymentData *xtended402.PaymentData) {
+	if handler := settlementHandler(c, config); handler != nil {
+		handler(c, paymentData.SettleResponse)
+	}
+	if len(config.EventHandlers) == 0 && config.Sessions == nil && config.APIKeys == nil {
+		return
+	}
+
+	invoice := paymentData.Invoice
+	event := xtended402.NewPaymentEvent(xtended402.EventPaymentSettled, config.clock(), nil, nil)
+	event.Resource = resourceOf(c)
+	event.Scheme = xtended402.InvoiceScheme
+	event.Network = invoice.Network
+	event.Asset = invoice.Asset
+	event.Amount = invoice.Amount
+	event.PayTo = invoice.PayTo
+	event.Payer = invoice.Payer
+	event.Transaction = invoice.Transaction
+	event = withScopes(event, xtended402.ResourceScopes(config.routes(), event.Resource))
+	event = withTenant(c, event)
+	event = withPayerName(c, config, event)
+	event = withOrderReference(event, paymentData.OrderReference)
+	event = withTax(event, paymentData.Tax)
+	issueSession(c, config, event)
+	mintAPIKey(c, config, event)
+	emit(c, config, event)
+}
@@ -0,0 +1,235 @@
+package gin
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Initialization Policy
+// ============================================================================
+
+// InitFailurePolicy controls what happens when the startup facilitator sync fails
+type InitFailurePolicy string
+
+const (
+	// InitWarn logs the failure and starts anyway (default)
+	InitWarn InitFailurePolicy = "warn"
+
+	// InitFailFast returns the error from NewMiddleware (PaymentMiddleware panics)
+	InitFailFast InitFailurePolicy = "fail-fast"
+
+	// InitRetryInBackground starts immediately and keeps retrying with backoff until sync succeeds
+	InitRetryInBackground InitFailurePolicy = "retry"
+)
+
+// InitState describes the facilitator sync state
+type InitState string
+
+const (
+	InitStateSkipped  InitState = "skipped" // SyncFacilitatorOnStart disabled
+	InitStateReady    InitState = "ready"
+	InitStateRetrying InitState = "retrying"
+	InitStateFailed   InitState = "failed"
+)
+
+// InitStatus reports the result of facilitator initialization
+type InitStatus struct {
+	State       InitState
+	Err         error
+	Attempts    int
+	LastAttempt time.Time
+}
+
+// Ready reports whether facilitator capabilities have been synced
+func (s InitStatus) Ready() bool {
+	return s.State == InitStateReady
+}
+
+// ============================================================================
+// Middleware
+// ============================================================================
+
+// Middleware is a configured payment middleware with observable initialization state.
+// Use Handler to mount it and InitStatus to expose readiness, e.g. from a health check.
+type Middleware struct {
+	server  *x402http.HTTPServer
+	config  *MiddlewareConfig
+	handler gin.HandlerFunc
+
+	mu     sync.RWMutex
+	status InitStatus
+
+	// routesMu serializes AddPaidRoute, RemovePaidRoute, and ReloadRoutes, and provided holds
+	// the routes last loaded from the route provider
+	routesMu sync.Mutex
+	provided x402http.RoutesConfig
+
+	// capabilities are the facilitators' supported kinds, when known (see Validate)
+	capabilities *capabilities
+
+	// drain and stop support Shutdown
+	drain    *drain
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// newMiddleware initializes server according to config, validates the routes, and starts
+// background work
+func newMiddleware(server *x402http.HTTPServer, config *MiddlewareConfig, capabilities *capabilities) (*Middleware, error) {
+	m := &Middleware{
+		server:       server,
+		config:       config,
+		status:       InitStatus{State: InitStateSkipped},
+		capabilities: capabilities,
+		drain:        &drain{},
+		stop:         make(chan struct{}),
+	}
+	config.table.Store(newRouteTable(config.Routes, server))
+	m.handler = createMiddlewareHandler(config, m.drain)
+
+	retry := false
+	if config.SyncFacilitatorOnStart {
+		if err := m.initialize(); err != nil {
+			switch config.InitFailurePolicy {
+			case InitFailFast:
+				return nil, fmt.Errorf("failed to initialize x402 server: %w", err)
+			case InitRetryInBackground:
+				fmt.Printf("Warning: failed to initialize x402 server, retrying in background: %v\n", err)
+				m.setState(InitStateRetrying)
+				retry = true
+			default:
+				fmt.Printf("Warning: failed to initialize x402 server: %v\n", err)
+			}
+		}
+	}
+
+	if config.RouteProvider != nil {
+		m.loadProvidedRoutes()
+	}
+
+	if config.ValidateOnStart {
+		if err := m.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if retry {
+		go m.retryInitialize()
+	}
+	if config.RefreshInterval > 0 {
+		go m.refreshCapabilities()
+	}
+	if config.RouteProvider != nil && config.RouteRefresh > 0 {
+		go m.refreshRoutes()
+	}
+
+	return m, nil
+}
+
+// Handler returns the Gin handler for the middleware
+func (m *Middleware) Handler() gin.HandlerFunc {
+	return m.handler
+}
+
+// InitStatus returns the current facilitator initialization status
+func (m *Middleware) InitStatus() InitStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Server returns the underlying x402 HTTP server, for the routes currently in effect
+func (m *Middleware) Server() *x402http.HTTPServer {
+	return m.config.table.Load().server
+}
+
+// initialize syncs facilitator capabilities once and records the outcome
+func (m *Middleware) initialize() error {
+	ctx, cancel := xtended402.ContextWithTimeout(context.Background(), m.config.clock(), m.config.Timeout)
+	defer cancel()
+
+	err := m.server.Initialize(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status.Attempts++
+	m.status.LastAttempt = m.config.clock().Now()
+	m.status.Err = err
+	if err != nil {
+		m.status.State = InitStateFailed
+	} else {
+		m.status.State = InitStateReady
+	}
+
+	return err
+}
+
+func (m *Middleware) setState(state InitState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status.State = state
+}
+
+// retryInitialize retries initialization with exponential backoff until it succeeds or the
+// middleware shuts down
+func (m *Middleware) retryInitialize() {
+	backoff := time.Second
+	for {
+		select {
+		case <-m.config.clock().After(backoff):
+		case <-m.stop:
+			return
+		}
+
+		if err := m.initialize(); err == nil {
+			return
+		}
+		m.setState(InitStateRetrying)
+
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// refreshCapabilities periodically re-runs Initialize so newly supported kinds are registered,
+// until the middleware shuts down. Kinds already mapped to a facilitator keep their existing
+// client.
+func (m *Middleware) refreshCapabilities() {
+	for {
+		delay := m.config.RefreshInterval
+		if m.config.RefreshJitter > 0 {
+			delay += time.Duration(rand.Int64N(int64(2*m.config.RefreshJitter))) - m.config.RefreshJitter
+		}
+		select {
+		case <-m.config.clock().After(delay):
+		case <-m.stop:
+			return
+		}
+
+		// Don't race the background initializer
+		if m.InitStatus().State == InitStateRetrying {
+			continue
+		}
+		// A failed startup sync (InitWarn) becomes ready on the first successful refresh
+		if !m.InitStatus().Ready() {
+			if err := m.initialize(); err != nil {
+				fmt.Printf("Warning: failed to refresh facilitator capabilities: %v\n", err)
+			}
+			continue
+		}
+
+		ctx, cancel := xtended402.ContextWithTimeout(context.Background(), m.config.clock(), m.config.Timeout)
+		if err := m.server.Initialize(ctx); err != nil {
+			fmt.Printf("Warning: failed to refresh facilitator capabilities: %v\n", err)
+		}
+		cancel()
+	}
+}
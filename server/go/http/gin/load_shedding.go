@@ -0,0 +1,50 @@
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Load Shedding
+// ============================================================================
+
+// ShedHandler responds to a payment turned away because the facilitator is overloaded.
+// reason is one of the xtended402.Shed* constants. It must abort the request.
+type ShedHandler func(c *gin.Context, reason string)
+
+// WithLoadShedding turns payments away early while shedder reports the facilitator overloaded,
+// so they don't each hold a goroutine until the payment timeout. Only requests carrying a
+// payment are shed; 402 challenges, sessions, and API keys don't call the facilitator. handler
+// responds to shed requests, or nil for a 503 with Retry-After.
+func WithLoadShedding(shedder *xtended402.LoadShedder, handler ShedHandler) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.LoadShedder = shedder
+		c.ShedHandler = handler
+	}
+}
+
+// shedLoad turns the request away if it carries a payment while the facilitator is overloaded
+func shedLoad(c *gin.Context, config *MiddlewareConfig) bool {
+	if config.LoadShedder == nil || c.GetHeader(xtended402.PaymentHeader) == "" {
+		return false
+	}
+	reason, overloaded := config.LoadShedder.Overloaded()
+	if !overloaded {
+		return false
+	}
+
+	if config.ShedHandler != nil {
+		config.ShedHandler(c, reason)
+		c.Abort()
+		return true
+	}
+	c.Header("Retry-After", "5")
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, errorBody(c, gin.H{
+		"error":   "Payment service is busy",
+		"details": reason,
+	}))
+	return true
+}
@@ -8,15 +8,17 @@ package gin
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	x402 "github.com/coinbase/x402/go"
-	"github.com/coinbase/x402/go/extensions/bazaar"
 	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
 	"github.com/gin-gonic/gin"
 	xtended402 "github.com/mvpoyatt/xtended402/server/go"
 )
@@ -91,25 +93,214 @@ type MiddlewareConfig struct {
 	// Paywall configuration
 	PaywallConfig *x402http.PaywallConfig
 
+	// PaywallRenderer replaces x402's built-in browser paywall (nil keeps it)
+	PaywallRenderer xtended402.PaywallRenderer
+
+	// DevPaywall adds testnet guidance to paywalls (nil disables), and DevPayPath is where
+	// DevPaymentHandler is mounted
+	DevPaywall *xtended402.DevPaywall
+	DevPayPath string
+
+	// PaywallTheme brands the built-in paywall (nil keeps its look)
+	PaywallTheme *xtended402.PaywallTheme
+
+	// Localizer translates paywalls and error payloads per request (nil leaves them in English)
+	Localizer *xtended402.Localizer
+
+	// ChallengeFormat is how 402s are sent on every paid route (ChallengeAuto when empty), and
+	// ChallengeFormats overrides it per route pattern
+	ChallengeFormat  ChallengeFormat
+	ChallengeFormats map[string]ChallengeFormat
+
+	// PaymentRequiredSchemaURL is linked from JSON 402s (xtended402.PaymentRequiredSchemaURL when empty)
+	PaymentRequiredSchemaURL string
+
+	// BazaarListings describe routes for Bazaar discovery, by route pattern
+	BazaarListings map[string]xtended402.BazaarListing
+
+	// Bazaar registers the Bazaar discovery extension (implied by BazaarListings)
+	Bazaar bool
+
+	// Extensions are resource server extensions to register, besides Bazaar
+	Extensions []x402types.ResourceServerExtension
+
 	// Sync with facilitator on start
 	SyncFacilitatorOnStart bool
 
-	// Custom error handler
-	ErrorHandler func(*gin.Context, error)
+	// ValidateOnStart fails construction on invalid routes (see Middleware.Validate)
+	ValidateOnStart bool
+
+	// InitFailurePolicy controls startup behavior when the facilitator sync fails
+	InitFailurePolicy InitFailurePolicy
+
+	// RefreshInterval re-syncs facilitator capabilities periodically (0 = never)
+	RefreshInterval time.Duration
+
+	// RefreshJitter randomizes each refresh by up to ±RefreshJitter so replicas don't sync in lockstep
+	RefreshJitter time.Duration
 
-	// Custom settlement handler
-	SettlementHandler func(*gin.Context, *x402.SettleResponse)
+	// Custom error handler, and ErrorHandlers overriding it per route pattern
+	ErrorHandler  func(*gin.Context, *PaymentError)
+	ErrorHandlers map[string]func(*gin.Context, *PaymentError)
+
+	// Custom settlement handler, and SettlementHandlers overriding it per route pattern
+	SettlementHandler  func(*gin.Context, *x402.SettleResponse)
+	SettlementHandlers map[string]func(*gin.Context, *x402.SettleResponse)
 
 	// Context timeout for payment operations
 	Timeout time.Duration
 
+	// VerifyTimeout bounds payment verification (defaults to Timeout)
+	VerifyTimeout time.Duration
+
+	// SettleTimeout bounds settlement, measured from when settlement starts rather than from
+	// the start of the request (defaults to Timeout)
+	SettleTimeout time.Duration
+
+	// Clock drives timeouts, init retry backoff, and capability refresh (defaults to the system clock)
+	Clock xtended402.Clock
+
 	// SettlementTiming controls when settlement occurs relative to handler execution
 	// "after" (default): verify, run handler, then settle
 	// "before": settle before handler (safer for e-commerce - money confirmed before order processing)
 	SettlementTiming string
 
+	// Exemptions are route patterns served without payment even where a paid route matches
+	Exemptions []string
+
+	// RequestBodyRoutes limits which paid routes buffer request bodies (nil buffers on all), and
+	// MaxRequestBody bounds the size buffered (DefaultMaxRequestBody when 0)
+	RequestBodyRoutes *xtended402.RouteMatcher
+	MaxRequestBody    int64
+
+	// TaskQuotes prices requests for accepted agent quotes at the quoted price (nil disables
+	// quotes)
+	TaskQuotes *xtended402.TaskQuotes
+
+	// PaymentBodyField is the JSON body field payment payloads are accepted in ("" accepts
+	// them in the header only)
+	PaymentBodyField string
+
+	// PaymentSubmissions accepts the tokens of payment payloads POSTed to PaymentSubmitURL (nil
+	// disables them)
+	PaymentSubmissions *xtended402.PaymentSubmissions
+	PaymentSubmitURL   string
+
+	// StreamingRoutes stream after-settlement responses on paid routes matching them once
+	// the handler flushes or writes more than MaxResponseBuffer (nil holds every response
+	// until settlement; DefaultMaxResponseBuffer when 0)
+	StreamingRoutes   *xtended402.RouteMatcher
+	MaxResponseBuffer int
+
+	// LoadShedder turns payments away while the facilitator is overloaded (nil disables it),
+	// and ShedHandler responds to them (a 503 when nil)
+	LoadShedder *xtended402.LoadShedder
+	ShedHandler ShedHandler
+
 	// BeforeSettleHook is called after verification but before settlement
 	BeforeSettleHook func(*gin.Context, *x402.VerifyResponse) error
+
+	// BeforeSettleHooks and AfterSettleHooks are named hooks run on the routes naming them (see
+	// xtended402.RouteWithSettleHooks)
+	BeforeSettleHooks map[string]func(*gin.Context, *x402.VerifyResponse) error
+	AfterSettleHooks  map[string]func(*gin.Context, *x402.SettleResponse)
+
+	// Reserve reserves what a payment buys before it settles, released if the purchase fails
+	// (see ReserveThenSettle)
+	Reserve ReserveFunc
+
+	// PaymentLocks locks payments across replicas while they're processed (nil disables it)
+	PaymentLocks xtended402.PaymentLocker
+
+	// EventHandlers receive payment.settled and payment.failed events
+	EventHandlers []xtended402.PaymentEventHandler
+
+	// FiatProvider offers card payment as an alternative to x402 (nil disables it)
+	FiatProvider xtended402.FiatProvider
+
+	// WalletPayments offers a QR code for phone wallets on the paywall (nil disables it), and
+	// WalletStatusPath is where the page polls for the payment
+	WalletPayments   *xtended402.WalletPayments
+	WalletStatusPath string
+
+	// RequirementExtras adds extra fields to the payment requirements of routes matching the
+	// patterns (the longest matching pattern wins)
+	RequirementExtras map[string]xtended402.RequirementExtras
+
+	// ChallengeMaxAge is how long shared caches may reuse 402 challenges before revalidating
+	// them (0 makes them revalidate every time), and ChallengeMaxAges overrides it per route
+	// pattern
+	ChallengeMaxAge  time.Duration
+	ChallengeMaxAges map[string]time.Duration
+
+	// Tips accepts payments of more than the price
+	Tips bool
+
+	// PlatformFee records a platform's cut of each settled payment in its event (nil for none)
+	PlatformFee *xtended402.PlatformFee
+
+	// Invoices offers invoices payable out of band on 402s from InvoiceRoutes (nil offers them on
+	// every paid route) and redeems them once paid (nil disables them)
+	Invoices      *xtended402.Invoices
+	InvoiceRoutes *xtended402.RouteMatcher
+
+	// ChallengeNonces issues nonces with 402s from ChallengeNonceRoutes (nil issues them on
+	// every paid route) that EVM payments must sign (nil disables them)
+	ChallengeNonces      *xtended402.ChallengeNonces
+	ChallengeNonceRoutes *xtended402.RouteMatcher
+
+	// PayerNames resolves payers' names for PaymentData and events (nil disables it)
+	PayerNames xtended402.NameResolver
+
+	// ValidityWindows rejects authorizations valid longer than their route's maxTimeoutSeconds
+	ValidityWindows bool
+
+	// OrderConnector creates an order before settlement and marks it paid after (nil disables it)
+	OrderConnector xtended402.OrderConnector
+
+	// Sessions issues session tokens after settlement and accepts them instead of payment
+	// (nil disables them)
+	Sessions *xtended402.SessionIssuer
+
+	// AccessWindows lets payers re-access purchased resources without paying again (nil
+	// disables them)
+	AccessWindows *xtended402.AccessWindows
+
+	// APIKeys mints quota-limited API keys after settlement on its mint routes and accepts them
+	// instead of payment (nil disables them)
+	APIKeys *xtended402.APIKeyProvisioner
+
+	// Balances credits deposits and pays other routes from prepaid balances (nil disables them)
+	Balances *xtended402.Balances
+
+	// Revocations rejects sessions, API keys, and access grants paid by revoked transactions
+	// (nil disables checks)
+	Revocations xtended402.RevocationList
+
+	// Tenants resolves the tenant behind each paid request (nil disables multi-tenancy)
+	Tenants *xtended402.Tenants
+
+	// FreeMode waives payment on the routes it covers while it's on (nil disables it)
+	FreeMode *xtended402.FreeMode
+
+	// PaymentRequired exempts the requests it returns false for from payment (nil requires
+	// payment on every paid route)
+	PaymentRequired PaymentRequiredFunc
+
+	// RouteProvider supplies paid routes besides Routes, reloaded every RouteRefresh (0 loads
+	// them once)
+	RouteProvider xtended402.RouteProvider
+	RouteRefresh  time.Duration
+
+	// ShutdownWaits are async workers Middleware.Shutdown waits for after in-flight payments
+	ShutdownWaits []AsyncWorker
+
+	// ShutdownHooks run last in Middleware.Shutdown
+	ShutdownHooks []func(ctx context.Context) error
+
+	// table holds the paid routes in effect, which Middleware.AddPaidRoute and RemovePaidRoute
+	// replace while serving
+	table atomic.Pointer[routeTable]
 }
 
 // SchemeRegistration registers a scheme with the server
@@ -118,6 +309,32 @@ type SchemeRegistration struct {
 	Server  x402.SchemeNetworkServer
 }
 
+func (c *MiddlewareConfig) verifyTimeout() time.Duration {
+	if c.VerifyTimeout > 0 {
+		return c.VerifyTimeout
+	}
+	return c.Timeout
+}
+
+func (c *MiddlewareConfig) settleTimeout() time.Duration {
+	if c.SettleTimeout > 0 {
+		return c.SettleTimeout
+	}
+	return c.Timeout
+}
+
+func (c *MiddlewareConfig) clock() xtended402.Clock {
+	return xtended402.ClockOrSystem(c.Clock)
+}
+
+// routes returns the paid routes in effect
+func (c *MiddlewareConfig) routes() x402http.RoutesConfig {
+	if table := c.table.Load(); table != nil {
+		return table.routes
+	}
+	return c.Routes
+}
+
 // ============================================================================
 // Middleware Options
 // ============================================================================
@@ -156,17 +373,55 @@ func WithSyncFacilitatorOnStart(sync bool) MiddlewareOption {
 	}
 }
 
-// WithErrorHandler sets a custom error handler
-func WithErrorHandler(handler func(*gin.Context, error)) MiddlewareOption {
+// WithInitFailurePolicy sets what happens when the startup facilitator sync fails:
+// InitWarn (default), InitFailFast, or InitRetryInBackground
+func WithInitFailurePolicy(policy InitFailurePolicy) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.InitFailurePolicy = policy
+	}
+}
+
+// WithCapabilityRefresh re-syncs facilitator capabilities in the background every interval
+// (±jitter), so long-running servers pick up newly supported schemes and networks.
+func WithCapabilityRefresh(interval, jitter time.Duration) MiddlewareOption {
 	return func(c *MiddlewareConfig) {
-		c.ErrorHandler = handler
+		c.RefreshInterval = interval
+		c.RefreshJitter = jitter
 	}
 }
 
-// WithSettlementHandler sets a custom settlement handler
-func WithSettlementHandler(handler func(*gin.Context, *x402.SettleResponse)) MiddlewareOption {
+// WithErrorHandler sets a custom error handler on routes matching the patterns, e.g.
+// "POST /api/purchase", or on every paid route without patterns. The longest matching pattern
+// wins.
+func WithErrorHandler(handler func(*gin.Context, *PaymentError), routes ...string) MiddlewareOption {
 	return func(c *MiddlewareConfig) {
-		c.SettlementHandler = handler
+		if len(routes) == 0 {
+			c.ErrorHandler = handler
+			return
+		}
+		if c.ErrorHandlers == nil {
+			c.ErrorHandlers = make(map[string]func(*gin.Context, *PaymentError))
+		}
+		for _, route := range routes {
+			c.ErrorHandlers[route] = handler
+		}
+	}
+}
+
+// WithSettlementHandler sets a custom settlement handler on routes matching the patterns, or
+// on every paid route without patterns. The longest matching pattern wins.
+func WithSettlementHandler(handler func(*gin.Context, *x402.SettleResponse), routes ...string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		if len(routes) == 0 {
+			c.SettlementHandler = handler
+			return
+		}
+		if c.SettlementHandlers == nil {
+			c.SettlementHandlers = make(map[string]func(*gin.Context, *x402.SettleResponse))
+		}
+		for _, route := range routes {
+			c.SettlementHandlers[route] = handler
+		}
 	}
 }
 
@@ -177,8 +432,32 @@ func WithTimeout(timeout time.Duration) MiddlewareOption {
 	}
 }
 
+// WithVerifyTimeout sets the context timeout for payment verification
+func WithVerifyTimeout(timeout time.Duration) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.VerifyTimeout = timeout
+	}
+}
+
+// WithSettleTimeout sets the context timeout for settlement.
+// Settlement waits for on-chain confirmation and usually deserves more time than verification.
+func WithSettleTimeout(timeout time.Duration) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.SettleTimeout = timeout
+	}
+}
+
+// WithClock sets the clock for timeouts and background timers, letting tests advance time
+// deterministically
+func WithClock(clock xtended402.Clock) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Clock = clock
+	}
+}
+
 // WithSettlementTiming sets when settlement occurs relative to handler execution.
 // Options: "after" (default, handler then settle) or "before" (settle then handler).
+// Routes can override it with xtended402.RouteWithSettlementTiming.
 func WithSettlementTiming(timing string) MiddlewareOption {
 	return func(c *MiddlewareConfig) {
 		c.SettlementTiming = timing
@@ -193,120 +472,273 @@ func WithBeforeSettleHook(hook func(*gin.Context, *x402.VerifyResponse) error) M
 	}
 }
 
+// WithEventHandler adds a handler for payment lifecycle events: payment.settled after a
+// successful settlement and payment.failed when settlement fails
+func WithEventHandler(handler xtended402.PaymentEventHandler) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.EventHandlers = append(c.EventHandlers, handler)
+	}
+}
+
 // ============================================================================
 // Payment Middleware
 // ============================================================================
 
 // PaymentMiddleware creates Gin middleware for x402 payment handling using a pre-configured server.
 // Supports configurable settlement timing, before-settle hooks, and context-based dynamic pricing.
+// Panics if the routes are invalid or initialization fails under InitFailFast; use NewMiddleware
+// to handle the error.
 func PaymentMiddleware(routes x402http.RoutesConfig, server *x402.X402ResourceServer, opts ...MiddlewareOption) gin.HandlerFunc {
+	return mustHandler(NewMiddleware(routes, server, opts...))
+}
+
+// PaymentMiddlewareFromConfig creates Gin middleware for x402 payment handling.
+// This creates the server internally from the provided options.
+// Panics if the routes are invalid or initialization fails under InitFailFast; use
+// NewMiddlewareFromConfig to handle the error.
+func PaymentMiddlewareFromConfig(routes x402http.RoutesConfig, opts ...MiddlewareOption) gin.HandlerFunc {
+	return mustHandler(NewMiddlewareFromConfig(routes, opts...))
+}
+
+// NewMiddleware creates a Middleware using a pre-configured server.
+// Returns an error when the routes are invalid or initialization fails under InitFailFast.
+func NewMiddleware(routes x402http.RoutesConfig, server *x402.X402ResourceServer, opts ...MiddlewareOption) (*Middleware, error) {
 	config := &MiddlewareConfig{
 		Routes:                 routes,
 		SyncFacilitatorOnStart: true,
+		ValidateOnStart:        true,
 		Timeout:                30 * time.Second,
 		SettlementTiming:       "after",
+		InitFailurePolicy:      InitWarn,
 	}
 
 	// Apply options
 	for _, opt := range opts {
 		opt(config)
 	}
+	if err := listRoutes(config); err != nil {
+		return nil, err
+	}
 
 	// Wrap the resource server with HTTP functionality
-	httpServer := x402http.Wrappedx402HTTPResourceServer(routes, server)
-
-	httpServer.RegisterExtension(bazaar.BazaarResourceServerExtension)
+	// x402 matches extended route patterns by their aliases
+	x402Routes, _ := aliasRoutes(config.Routes)
+	httpServer := x402http.Wrappedx402HTTPResourceServer(x402Routes, server)
 
-	// Initialize if requested
-	if config.SyncFacilitatorOnStart {
-		ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
-		defer cancel()
-		if err := httpServer.Initialize(ctx); err != nil {
-			fmt.Printf("Warning: failed to initialize x402 server: %v\n", err)
-		}
-	}
+	registerExtensions(httpServer, config)
 
-	return createMiddlewareHandler(httpServer, config)
+	return newMiddleware(httpServer, config, nil)
 }
 
-// PaymentMiddlewareFromConfig creates Gin middleware for x402 payment handling.
-// This creates the server internally from the provided options.
-func PaymentMiddlewareFromConfig(routes x402http.RoutesConfig, opts ...MiddlewareOption) gin.HandlerFunc {
+// NewMiddlewareFromConfig creates a Middleware, building the server from the provided options.
+// Returns an error when the routes are invalid, no facilitator supports them, or initialization
+// fails under InitFailFast.
+func NewMiddlewareFromConfig(routes x402http.RoutesConfig, opts ...MiddlewareOption) (*Middleware, error) {
 	config := &MiddlewareConfig{
 		Routes:                 routes,
 		FacilitatorClients:     []x402.FacilitatorClient{},
 		Schemes:                []SchemeRegistration{},
 		SyncFacilitatorOnStart: true,
+		ValidateOnStart:        true,
 		Timeout:                30 * time.Second,
 		SettlementTiming:       "after",
+		InitFailurePolicy:      InitWarn,
 	}
 
 	// Apply options
 	for _, opt := range opts {
 		opt(config)
 	}
+	if err := listRoutes(config); err != nil {
+		return nil, err
+	}
 
+	// Record facilitator capabilities for Validate
+	capabilities := &capabilities{}
 	serverOpts := []x402.ResourceServerOption{}
 	for _, client := range config.FacilitatorClients {
-		serverOpts = append(serverOpts, x402.WithFacilitatorClient(client))
+		serverOpts = append(serverOpts, x402.WithFacilitatorClient(capabilities.record(client)))
 	}
 
-	httpServer := x402http.Newx402HTTPResourceServer(config.Routes, serverOpts...)
+	x402Routes, _ := aliasRoutes(config.Routes)
+	httpServer := x402http.Newx402HTTPResourceServer(x402Routes, serverOpts...)
 
-	httpServer.RegisterExtension(bazaar.BazaarResourceServerExtension)
+	registerExtensions(httpServer, config)
 
 	// Register schemes
 	for _, scheme := range config.Schemes {
 		httpServer.Register(scheme.Network, scheme.Server)
 	}
 
-	// Initialize if requested
-	if config.SyncFacilitatorOnStart {
-		ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
-		defer cancel()
-		if err := httpServer.Initialize(ctx); err != nil {
-			fmt.Printf("Warning: failed to initialize x402 server: %v\n", err)
-		}
-	}
+	return newMiddleware(httpServer, config, capabilities)
+}
 
-	return createMiddlewareHandler(httpServer, config)
+func mustHandler(m *Middleware, err error) gin.HandlerFunc {
+	if err != nil {
+		panic(fmt.Sprintf("xtended402: %v", err))
+	}
+	return m.Handler()
 }
 
 // createMiddlewareHandler creates the actual Gin handler function with enhancements
-func createMiddlewareHandler(server *x402http.HTTPServer, config *MiddlewareConfig) gin.HandlerFunc {
+func createMiddlewareHandler(config *MiddlewareConfig, drain *drain) gin.HandlerFunc {
+	exempt := exemptions(config)
+
 	return func(c *gin.Context) {
+		// Routes can change while serving, so the request keeps the ones in effect as it starts
+		table := config.table.Load()
+		server := table.server
+
+		// Check if route requires payment, as server.RequiresPayment would
+		method := paidMethod(c, table.matcher)
+		if !table.matcher.Match(method, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
 		// ========================================
-		// ENHANCEMENT: Preserve request body
+		// ENHANCEMENT: Exempt paths under paid prefixes
 		// ========================================
-		var requestBody []byte
-		if c.Request.Body != nil {
-			bodyBytes, err := io.ReadAll(c.Request.Body)
-			if err == nil {
-				requestBody = bodyBytes
-				// Restore body for further reading
-				c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-			}
+		if exempt != nil && exempt.Match(method, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		// ========================================
+		// ENHANCEMENT: CORS preflights never pay
+		// ========================================
+		if isPreflight(c) {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		// Query parameters reach dynamic pricing through the request context
+		if c.Request.URL.RawQuery != "" {
+			c.Request = c.Request.WithContext(xtended402.ContextWithQuery(c.Request.Context(), c.Request.URL.Query()))
 		}
+		// Taxes worked out by TaxedPrice prices are recorded with the payment
+		c.Request = c.Request.WithContext(xtended402.ContextWithTaxes(c.Request.Context()))
 
 		// Create adapter and request context
-		adapter := NewGinAdapter(c)
+		adapter := acquireAdapter(c)
+		defer releaseAdapter(adapter)
 		reqCtx := x402http.HTTPRequestContext{
 			Adapter: adapter,
-			Path:    c.Request.URL.Path,
-			Method:  c.Request.Method,
+			Path:    table.resolver.resolve(c, method),
+			Method:  method,
 		}
 
-		// Check if route requires payment
-		if !server.RequiresPayment(reqCtx) {
+		// ========================================
+		// ENHANCEMENT: Negotiate the buyer's locale
+		// ========================================
+		negotiateLocale(c, config)
+
+		// ========================================
+		// ENHANCEMENT: Resolve the tenant being paid
+		// ========================================
+		if !resolveTenant(c, config) {
+			return
+		}
+
+		// ========================================
+		// ENHANCEMENT: Exempt requests skip payment
+		// ========================================
+		if paymentBypassed(c, config, reqCtx) {
 			c.Next()
 			return
 		}
 
+		// ========================================
+		// ENHANCEMENT: Free mode waives payment
+		// ========================================
+		if paymentWaived(c, config) {
+			c.Next()
+			return
+		}
+
+		// ========================================
+		// ENHANCEMENT: HEAD and preview requests see the requirements free
+		// ========================================
+		if isPreview(c) {
+			previewRequirements(c, config, server, reqCtx, adapter)
+			return
+		}
+
+		// ========================================
+		// ENHANCEMENT: Paid content stays out of shared caches
+		// ========================================
+		markPaidContent(c)
+
+		// ========================================
+		// ENHANCEMENT: Session tokens skip payment
+		// ========================================
+		if sessionValid(c, config) {
+			c.Next()
+			return
+		}
+
+		// ========================================
+		// ENHANCEMENT: API keys skip payment
+		// ========================================
+		if apiKeyPaid(c, config) {
+			return
+		}
+
+		// ========================================
+		// ENHANCEMENT: Payments sent in the body or by token
+		// ========================================
+		if !paymentFromTransport(c, config) {
+			return
+		}
+
+		// ========================================
+		// ENHANCEMENT: Agents pay for accepted quotes
+		// ========================================
+		if !taskQuote(c, config) {
+			return
+		}
+
+		// ========================================
+		// ENHANCEMENT: Shed payments while the facilitator is overloaded
+		// ========================================
+		if shedLoad(c, config) {
+			return
+		}
+
+		// ========================================
+		// ENHANCEMENT: Graceful shutdown stops new payments
+		// ========================================
+		if !drain.enter() {
+			rejectDraining(c)
+			return
+		}
+		defer drain.leave()
+
 		// Create context with timeout
-		ctx, cancel := context.WithTimeout(c.Request.Context(), config.Timeout)
+		ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.verifyTimeout())
 		defer cancel()
 
-		result := server.ProcessHTTPRequest(ctx, reqCtx, config.PaywallConfig)
+		// ========================================
+		// ENHANCEMENT: Negotiate the 402 format
+		// ========================================
+		challengeCtx := reqCtx
+		challengeCtx.Adapter = negotiatedAdapter(c, config, adapter)
+
+		// ========================================
+		// ENHANCEMENT: App-specific requirement extras
+		// ========================================
+		if !resolveRequirementExtras(c, ctx, config, reqCtx) {
+			return
+		}
+
+		result := server.ProcessHTTPRequest(ctx, challengeCtx, config.PaywallConfig)
+
+		// ========================================
+		// ENHANCEMENT: Payments above the price are tips
+		// ========================================
+		if config.Tips && result.Type == x402http.ResultPaymentError && matchTip(c, server, config, result.Response) {
+			result = server.ProcessHTTPRequest(ctx, challengeCtx, config.PaywallConfig)
+		}
 
 		// Handle result based on type
 		switch result.Type {
@@ -314,56 +746,218 @@ func createMiddlewareHandler(server *x402http.HTTPServer, config *MiddlewareConf
 			c.Next()
 
 		case x402http.ResultPaymentError:
+			// ========================================
+			// ENHANCEMENT: Requirement extras and nonces in the challenge
+			// ========================================
+			issueChallengeNonce(c, config)
+			if header, ok := result.Response.Headers["PAYMENT-REQUIRED"]; ok {
+				result.Response.Headers["PAYMENT-REQUIRED"] = extendChallenge(c, header)
+			}
+
+			// ========================================
+			// ENHANCEMENT: Versioned JSON 402 body
+			// ========================================
+			describeChallenge(c, server, config, result.Response)
+
+			// ========================================
+			// ENHANCEMENT: Card payments via the fiat fallback
+			// ========================================
+			var checkout *xtended402.FiatCheckout
+			if config.FiatProvider != nil && result.Response.Status == http.StatusPaymentRequired {
+				if reference := c.GetHeader(xtended402.FiatPaymentHeader); reference != "" {
+					handleFiatPayment(c, server, result.Response, config, bufferedBody(c, config), reference)
+					return
+				}
+				checkout = offerFiatCheckout(c, server, config, result.Response)
+			}
+
+			// ========================================
+			// ENHANCEMENT: Phone wallet payments via QR code
+			// ========================================
+			var walletCheckout *xtended402.WalletCheckout
+			if config.WalletPayments != nil && result.Response.Status == http.StatusPaymentRequired {
+				if reference, fromCookie := walletPaymentReference(c); reference != "" {
+					if handleWalletPayment(c, server, result.Response, config, bufferedBody(c, config), reference, fromCookie) {
+						return
+					}
+				}
+				if result.Response.IsHTML {
+					walletCheckout = offerWalletCheckout(c, server, config, result.Response)
+				}
+			}
+
+			// ========================================
+			// ENHANCEMENT: Deferred invoices paid out of band
+			// ========================================
+			if config.Invoices != nil && result.Response.Status == http.StatusPaymentRequired {
+				if id := c.GetHeader(xtended402.InvoiceHeader); id != "" {
+					handleInvoicePayment(c, config, bufferedBody(c, config), id)
+					return
+				}
+				offerInvoice(c, server, config, result.Response)
+			}
+
+			// ========================================
+			// ENHANCEMENT: Testnet guidance in developer mode
+			// ========================================
+			if config.DevPaywall != nil && config.PaywallRenderer == nil && result.Response.IsHTML {
+				guideTestnet(c, server, config, result.Response)
+			}
+
+			// ========================================
+			// ENHANCEMENT: Custom browser paywall
+			// ========================================
+			if config.PaywallRenderer != nil && result.Response.IsHTML {
+				renderPaywall(c, server, config, result.Response, checkout, walletCheckout)
+			}
+
+			// ========================================
+			// ENHANCEMENT: Translate the built-in paywall
+			// ========================================
+			if config.Localizer != nil && config.PaywallRenderer == nil && result.Response.IsHTML {
+				localizePaywall(c, result.Response)
+			}
+
+			// ========================================
+			// ENHANCEMENT: Brand the built-in paywall
+			// ========================================
+			if config.PaywallTheme != nil && config.PaywallRenderer == nil && result.Response.IsHTML {
+				themePaywall(c, config, result.Response)
+			}
+			addTopUp(c)
 			handlePaymentError(c, result.Response, config)
 
 		case x402http.ResultPaymentVerified:
+			// ========================================
+			// ENHANCEMENT: Payments sign the challenge's nonce
+			// ========================================
+			if !checkChallengeNonce(c, config, result) {
+				return
+			}
+
+			// ========================================
+			// ENHANCEMENT: Authorizations fit the route's validity window
+			// ========================================
+			if !checkValidityWindow(c, config, result) {
+				return
+			}
+
+			// ========================================
+			// ENHANCEMENT: One replica processes each payment
+			// ========================================
+			unlock, locked := lockPayment(c, config, result)
+			if !locked {
+				return
+			}
+			defer unlock()
+
+			// ========================================
+			// ENHANCEMENT: Access windows skip settlement
+			// ========================================
+			if accessGranted(c, config, result) {
+				c.Next()
+				return
+			}
+
+			// ========================================
+			// ENHANCEMENT: Prepaid balances skip settlement
+			// ========================================
+			if balancePaid(c, config, result) {
+				return
+			}
+
+			// ========================================
+			// ENHANCEMENT: Refund addresses distinct from the payer
+			// ========================================
+			if !checkRefundAddress(c, config, result) {
+				return
+			}
+
+			// ========================================
+			// ENHANCEMENT: Echoed requirement extras must match
+			// ========================================
+			var extrasOK bool
+			if result, extrasOK = checkRequirementExtras(c, config, result); !extrasOK {
+				return
+			}
+
+			// ========================================
+			// ENHANCEMENT: Streams settle in increments
+			// ========================================
+			if terms := xtended402.ResourceStreamTerms(config.routes(), resourceOf(c)); terms != nil {
+				handleStreamingPayment(c, server, result, config, terms)
+				return
+			}
+
 			// ========================================
 			// ENHANCEMENT: Settlement timing logic
 			// ========================================
-			if config.SettlementTiming == "before" {
+			if settlementTiming(c, config) == "before" {
 				// Settle BEFORE handler (e-commerce pattern)
-				handlePaymentVerifiedSettleBefore(c, server, ctx, result, config, requestBody)
+				handlePaymentVerifiedSettleBefore(c, server, result, config, bufferedBody(c, config))
 			} else {
 				// Settle AFTER handler
-				handlePaymentVerifiedSettleAfter(c, server, ctx, result, config, requestBody)
+				handlePaymentVerifiedSettleAfter(c, server, result, config, bufferedBody(c, config))
 			}
 		}
 	}
 }
 
 // handlePaymentError handles payment error responses
-func handlePaymentError(c *gin.Context, response *x402http.HTTPResponseInstructions, _ *MiddlewareConfig) {
+func handlePaymentError(c *gin.Context, response *x402http.HTTPResponseInstructions, config *MiddlewareConfig) {
 	c.Status(response.Status)
 
 	for key, value := range response.Headers {
 		c.Header(key, value)
 	}
 
+	contentType := "application/json; charset=utf-8"
+	var body []byte
 	if response.IsHTML {
-		c.Data(response.Status, "text/html; charset=utf-8", []byte(response.Body.(string)))
+		contentType = "text/html; charset=utf-8"
+		body = []byte(response.Body.(string))
 	} else {
-		c.JSON(response.Status, response.Body)
+		data, err := json.Marshal(response.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode payment requirements"})
+			return
+		}
+		body = data
+	}
+
+	// ========================================
+	// ENHANCEMENT: Cacheable 402 challenges
+	// ========================================
+	if response.Status == http.StatusPaymentRequired && cacheChallenge(c, config, body) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
 	}
 
+	c.Data(response.Status, contentType, body)
 	c.Abort()
 }
 
+// settlementTiming returns when the current request's payment settles: its route's settlement
+// timing, if declared, otherwise the middleware's
+func settlementTiming(c *gin.Context, config *MiddlewareConfig) string {
+	if timing := xtended402.ResourceSettlementTiming(config.routes(), resourceOf(c)); timing != "" {
+		return timing
+	}
+	return config.SettlementTiming
+}
+
 // handlePaymentVerifiedSettleAfter handles verified payments with after-settlement timing:
 // verify → run handler → settle
 func handlePaymentVerifiedSettleAfter(
 	c *gin.Context,
 	server *x402http.HTTPServer,
-	ctx context.Context,
 	result x402http.HTTPProcessResult,
 	config *MiddlewareConfig,
 	requestBody []byte,
 ) {
 	// Capture response for settlement
-	writer := &responseCapture{
-		ResponseWriter: c.Writer,
-		body:           &bytes.Buffer{},
-		statusCode:     http.StatusOK,
-	}
+	writer := acquireCapture(c.Writer)
+	writer.limit = responseBufferLimit(c, config)
 	c.Writer = writer
 
 	// Continue to protected handler
@@ -374,34 +968,47 @@ func handlePaymentVerifiedSettleAfter(
 		return
 	}
 
-	// Restore original writer
-	c.Writer = writer.ResponseWriter
+	// Restore original writer. A streamed response has been sent, so the capture stays until
+	// settlement is done, turning the headers it sets into trailers.
+	defer releaseCapture(writer)
+	if writer.endStream() {
+		defer writer.restoreStream(c)
+	} else {
+		writer.restore(c)
+	}
+	defer releaseReservation(c)
 
 	// Don't settle if response failed
-	if writer.statusCode >= 400 {
-		c.Writer.WriteHeader(writer.statusCode)
-		_, _ = c.Writer.Write(writer.body.Bytes())
+	if writer.failed() {
+		writer.replay()
 		return
 	}
 
-	// Call before-settle hook if configured
-	if config.BeforeSettleHook != nil {
-		verifyResp := &x402.VerifyResponse{IsValid: true} // Simplified
-		if err := config.BeforeSettleHook(c, verifyResp); err != nil {
-			if config.ErrorHandler != nil {
-				config.ErrorHandler(c, fmt.Errorf("before-settle hook failed: %w", err))
-			} else {
-				c.JSON(http.StatusPaymentRequired, gin.H{
-					"error":   "Pre-settlement validation failed",
-					"details": err.Error(),
-				})
-			}
-			return
+	// Call before-settle hooks if configured
+	verifyResp := &x402.VerifyResponse{IsValid: true} // Simplified
+	if err := beforeSettle(c, config, verifyResp); err != nil {
+		if handler := errorHandler(c, config); handler != nil {
+			handler(c, rejectionError(StageHook, http.StatusPaymentRequired, result.PaymentRequirements, fmt.Errorf("before-settle hook failed: %w", err)))
+		} else {
+			c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
+				"error":   "Pre-settlement validation failed",
+				"details": err.Error(),
+			}))
 		}
+		return
+	}
+
+	orderID, ok := createOrder(c, config, result, requestBody)
+	if !ok {
+		return
 	}
 
 	// Process settlement
+	ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.settleTimeout())
+	defer cancel()
+	settleStart := config.clock().Now()
 	settleResult := server.ProcessSettlement(ctx, *result.PaymentPayload, *result.PaymentRequirements)
+	logSettlement(c, result, settleResult, config.clock().Now().Sub(settleStart))
 
 	// Check settlement success
 	if !settleResult.Success {
@@ -409,13 +1016,14 @@ func handlePaymentVerifiedSettleAfter(
 		if errorReason == "" {
 			errorReason = "Settlement failed"
 		}
-		if config.ErrorHandler != nil {
-			config.ErrorHandler(c, fmt.Errorf("settlement failed: %s", errorReason))
+		emitSettlementFailed(c, config, result, errorReason, orderID)
+		if handler := errorHandler(c, config); handler != nil {
+			handler(c, settlementError(ctx, result, settleResult.ErrorReason, errorReason))
 		} else {
-			c.JSON(http.StatusPaymentRequired, gin.H{
+			c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
 				"error":   "Settlement failed",
 				"details": errorReason,
-			})
+			}))
 		}
 		return
 	}
@@ -425,20 +1033,22 @@ func handlePaymentVerifiedSettleAfter(
 		c.Header(key, value)
 	}
 
-	// Call settlement handler if configured
-	if config.SettlementHandler != nil {
-		settleResponse := &x402.SettleResponse{
-			Success:     true,
-			Transaction: settleResult.Transaction,
-			Network:     settleResult.Network,
-			Payer:       settleResult.Payer,
-		}
-		config.SettlementHandler(c, settleResponse)
+	// Call after-settle hooks and settlement handler if configured
+	settleResponse := &x402.SettleResponse{
+		Success:     true,
+		Transaction: settleResult.Transaction,
+		Network:     settleResult.Network,
+		Payer:       settleResult.Payer,
+	}
+	keepReservation(c)
+	afterSettle(c, config, settleResponse)
+	if handler := settlementHandler(c, config); handler != nil {
+		handler(c, settleResponse)
 	}
+	emitSettled(c, config, result, settleResult, orderID)
 
 	// Write captured response
-	c.Writer.WriteHeader(writer.statusCode)
-	_, _ = c.Writer.Write(writer.body.Bytes())
+	writer.replay()
 }
 
 // handlePaymentVerifiedSettleBefore handles verified payments with e-commerce timing:
@@ -446,30 +1056,55 @@ func handlePaymentVerifiedSettleAfter(
 func handlePaymentVerifiedSettleBefore(
 	c *gin.Context,
 	server *x402http.HTTPServer,
-	ctx context.Context,
 	result x402http.HTTPProcessResult,
 	config *MiddlewareConfig,
 	requestBody []byte,
 ) {
-	// Call before-settle hook if configured
-	if config.BeforeSettleHook != nil {
-		verifyResp := &x402.VerifyResponse{IsValid: true} // Simplified
-		if err := config.BeforeSettleHook(c, verifyResp); err != nil {
-			if config.ErrorHandler != nil {
-				config.ErrorHandler(c, fmt.Errorf("before-settle hook failed: %w", err))
-			} else {
-				c.JSON(http.StatusPaymentRequired, gin.H{
-					"error":   "Pre-settlement validation failed",
-					"details": err.Error(),
-				})
-			}
-			c.Abort()
-			return
+	defer releaseReservation(c)
+	if settleBeforeHandler(c, server, result, config, requestBody) {
+		// Continue to handler (payment already settled)
+		c.Next()
+		if !handlerFailed(c) {
+			keepReservation(c)
 		}
 	}
+}
+
+// settleBeforeHandler settles a verified payment and stores its PaymentData for the handler.
+// When it fails, the error response is written and it returns false.
+func settleBeforeHandler(
+	c *gin.Context,
+	server *x402http.HTTPServer,
+	result x402http.HTTPProcessResult,
+	config *MiddlewareConfig,
+	requestBody []byte,
+) bool {
+	// Call before-settle hooks if configured
+	verifyResp := &x402.VerifyResponse{IsValid: true} // Simplified
+	if err := beforeSettle(c, config, verifyResp); err != nil {
+		if handler := errorHandler(c, config); handler != nil {
+			handler(c, rejectionError(StageHook, http.StatusPaymentRequired, result.PaymentRequirements, fmt.Errorf("before-settle hook failed: %w", err)))
+		} else {
+			c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
+				"error":   "Pre-settlement validation failed",
+				"details": err.Error(),
+			}))
+		}
+		c.Abort()
+		return false
+	}
+
+	orderID, ok := createOrder(c, config, result, requestBody)
+	if !ok {
+		return false
+	}
 
 	// Process settlement BEFORE handler
+	ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.settleTimeout())
+	defer cancel()
+	settleStart := config.clock().Now()
 	settleResult := server.ProcessSettlement(ctx, *result.PaymentPayload, *result.PaymentRequirements)
+	logSettlement(c, result, settleResult, config.clock().Now().Sub(settleStart))
 
 	// Check settlement success
 	if !settleResult.Success {
@@ -477,16 +1112,17 @@ func handlePaymentVerifiedSettleBefore(
 		if errorReason == "" {
 			errorReason = "Settlement failed"
 		}
-		if config.ErrorHandler != nil {
-			config.ErrorHandler(c, fmt.Errorf("settlement failed: %s", errorReason))
+		emitSettlementFailed(c, config, result, errorReason, orderID)
+		if handler := errorHandler(c, config); handler != nil {
+			handler(c, settlementError(ctx, result, settleResult.ErrorReason, errorReason))
 		} else {
-			c.JSON(http.StatusPaymentRequired, gin.H{
+			c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
 				"error":   "Settlement failed",
 				"details": errorReason,
-			})
+			}))
 		}
 		c.Abort()
-		return
+		return false
 	}
 
 	// Add settlement headers
@@ -508,16 +1144,85 @@ func handlePaymentVerifiedSettleBefore(
 		PaymentRequirements: result.PaymentRequirements,
 		VerifyResponse:      &x402.VerifyResponse{IsValid: true},
 		RequestBody:         requestBody,
+		OrderID:             orderID,
+		RouteParams:         xtended402.GetRouteParams(c),
+		PayerName:           payerName(c, config, settleResult.Network, settleResult.Payer),
+		OrderReference:      xtended402.OrderReferenceFromContext(c.Request.Context()),
+		Tax:                 xtended402.TaxFromContext(c.Request.Context()),
 	}
 	c.Set(xtended402.PaymentDataKey, paymentData)
 
-	// Call settlement handler if configured
-	if config.SettlementHandler != nil {
-		config.SettlementHandler(c, paymentData.SettleResponse)
+	// Call after-settle hooks and settlement handler if configured
+	afterSettle(c, config, paymentData.SettleResponse)
+	if handler := settlementHandler(c, config); handler != nil {
+		handler(c, paymentData.SettleResponse)
 	}
+	emitSettled(c, config, result, settleResult, orderID)
+	return true
+}
 
-	// Continue to handler (payment already settled)
-	c.Next()
+// ============================================================================
+// Payment Events
+// ============================================================================
+
+// emitSettled issues the session, grants access, mints the API key, credits the deposit, marks
+// the order and quote paid, and sends a payment.settled event to the configured handlers
+func emitSettled(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult, settleResult *x402http.ProcessSettleResult, orderID string) {
+	if len(config.EventHandlers) == 0 && config.OrderConnector == nil && config.Sessions == nil && config.AccessWindows == nil && config.APIKeys == nil && config.Balances == nil && config.TaskQuotes == nil {
+		return
+	}
+
+	event := newEvent(c, config, xtended402.EventPaymentSettled, result)
+	event.Transaction = settleResult.Transaction
+	if settleResult.Payer != "" {
+		event.Payer = settleResult.Payer
+	}
+	if settleResult.Network != "" {
+		event.Network = settleResult.Network
+	}
+	event = withOrderID(event, orderID)
+	issueSession(c, config, event)
+	grantAccess(c, config, event)
+	mintAPIKey(c, config, event)
+	creditDeposit(c, config, event)
+	markOrderPaid(c, config, orderID, event)
+	settleQuote(c, config, event)
+	emit(c, config, event)
+}
+
+// emitSettlementFailed sends a payment.failed event to the configured handlers. The order, if
+// any, stays pending; its ID is in the event's metadata.
+func emitSettlementFailed(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult, reason, orderID string) {
+	if len(config.EventHandlers) == 0 {
+		return
+	}
+
+	event := newEvent(c, config, xtended402.EventPaymentFailed, result)
+	event.ErrorReason = reason
+	emit(c, config, withOrderID(event, orderID))
+}
+
+func newEvent(c *gin.Context, config *MiddlewareConfig, eventType xtended402.PaymentEventType, result x402http.HTTPProcessResult) xtended402.PaymentEvent {
+	event := xtended402.NewPaymentEvent(eventType, config.clock(), result.PaymentPayload, result.PaymentRequirements)
+	event.Resource = resourceOf(c)
+	event = withTenant(c, event)
+	event = withQuoteID(c, event)
+	event = withStreamIncrement(c, event)
+	event = withTip(event, result)
+	event = withRefundAddress(event, result)
+	event = withPayerName(c, config, event)
+	event = withOrderReference(event, xtended402.OrderReferenceFromContext(c.Request.Context()))
+	event = withTax(event, xtended402.TaxFromContext(c.Request.Context()))
+	return withScopes(event, xtended402.ResourceScopes(config.routes(), event.Resource))
+}
+
+func emit(c *gin.Context, config *MiddlewareConfig, event xtended402.PaymentEvent) {
+	if config.PlatformFee != nil && event.Type == xtended402.EventPaymentSettled {
+		event = xtended402.WithPlatformFee(event, *config.PlatformFee)
+	}
+	for _, handler := range config.EventHandlers {
+		handler(c.Request.Context(), event)
+	}
 }
 
 // ============================================================================
@@ -531,6 +1236,52 @@ type responseCapture struct {
 	statusCode int
 	written    bool
 	mu         sync.Mutex
+
+	// held are the headers describing the captured body, kept off the response until it's
+	// replayed
+	held http.Header
+
+	// limit is how much is captured before the response streams (0 never streams it), and
+	// trailers are the headers set once a streamed response has ended
+	limit     int
+	streaming bool
+	ended     bool
+	trailers  http.Header
+
+	// hijacked is set once the handler took over the connection
+	hijacked bool
+}
+
+// contentHeaders describe a response body. An error sent in place of a captured response
+// mustn't carry them, e.g. a gzip Content-Encoding on a plain JSON error.
+var contentHeaders = []string{
+	"Content-Encoding", "Content-Length", "Content-Type", "Content-Range", "Content-Disposition",
+	"Etag", "Last-Modified",
+}
+
+// restore puts the wrapped writer back in c and holds the captured body's content headers
+func (w *responseCapture) restore(c *gin.Context) {
+	c.Writer = w.ResponseWriter
+	header := w.ResponseWriter.Header()
+	for _, key := range contentHeaders {
+		if values, ok := header[key]; ok {
+			w.held[key] = values
+			delete(header, key)
+		}
+	}
+}
+
+// replay writes the captured response, with its content headers, to the restored writer
+func (w *responseCapture) replay() {
+	if w.streaming || w.hijacked {
+		return
+	}
+	header := w.ResponseWriter.Header()
+	for key, values := range w.held {
+		header[key] = values
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
 }
 
 func (w *responseCapture) WriteHeader(code int) {
@@ -541,6 +1292,9 @@ func (w *responseCapture) WriteHeader(code int) {
 }
 
 func (w *responseCapture) writeHeaderLocked(code int) {
+	if w.ended && code >= 400 {
+		w.trailers.Set(StreamStatusTrailer, strconv.Itoa(code))
+	}
 	if !w.written {
 		w.statusCode = code
 		w.written = true
@@ -554,9 +1308,70 @@ func (w *responseCapture) Write(data []byte) (int, error) {
 	if !w.written {
 		w.writeHeaderLocked(http.StatusOK)
 	}
-	return w.body.Write(data)
+	switch {
+	case w.ended:
+		return len(data), nil
+	case w.hijacked:
+		return 0, http.ErrHijacked
+	case w.streaming:
+		return w.ResponseWriter.Write(data)
+	}
+	n, err := w.body.Write(data)
+	if w.limit > 0 && w.body.Len() > w.limit {
+		w.streamLocked()
+	}
+	return n, err
 }
 
 func (w *responseCapture) WriteString(s string) (int, error) {
 	return w.Write([]byte(s))
 }
+
+// WriteHeaderNow, Status, Size, and Written report the captured response rather than the
+// wrapped writer's, which nothing reaches until settlement. Compression middleware checks Size
+// to tell whether anything was written.
+func (w *responseCapture) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeaderLocked(w.statusCode)
+}
+
+func (w *responseCapture) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.statusCode
+}
+
+func (w *responseCapture) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.written {
+		return -1
+	}
+	if w.streaming {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+func (w *responseCapture) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.written
+}
+
+// Header returns the wrapped writer's headers, or once a streamed response has ended, the
+// trailers to send after it
+func (w *responseCapture) Header() http.Header {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.ended {
+		return w.trailers
+	}
+	return w.ResponseWriter.Header()
+}
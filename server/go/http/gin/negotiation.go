@@ -0,0 +1,189 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Content Negotiation
+// ============================================================================
+
+// ChallengeFormat is how a 402 challenge is sent
+type ChallengeFormat string
+
+const (
+	// ChallengeAuto sends the paywall page to browsers that prefer HTML over JSON and the JSON
+	// body to everyone else (default)
+	ChallengeAuto ChallengeFormat = "auto"
+
+	// ChallengeJSON always sends the JSON body, e.g. on API routes browsers open directly
+	ChallengeJSON ChallengeFormat = "json"
+
+	// ChallengeHTML always sends the paywall page, e.g. on pages fetched without an Accept header
+	ChallengeHTML ChallengeFormat = "html"
+)
+
+// WithChallengeFormat forces how 402s are sent on routes matching the patterns, e.g.
+// "GET /api/*", or on every paid route without patterns. The longest matching pattern wins.
+func WithChallengeFormat(format ChallengeFormat, routes ...string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		if len(routes) == 0 {
+			c.ChallengeFormat = format
+			return
+		}
+		if c.ChallengeFormats == nil {
+			c.ChallengeFormats = make(map[string]ChallengeFormat)
+		}
+		for _, route := range routes {
+			c.ChallengeFormats[route] = format
+		}
+	}
+}
+
+// WithPaymentRequiredSchemaURL links JSON 402s to url instead of the published schema, e.g.
+// where PaymentRequiredSchemaHandler is mounted
+func WithPaymentRequiredSchemaURL(url string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.PaymentRequiredSchemaURL = url
+	}
+}
+
+// PaymentRequiredSchemaHandler serves the JSON Schema for JSON 402 bodies
+func PaymentRequiredSchemaHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/schema+json", xtended402.PaymentRequiredSchema)
+	}
+}
+
+// apiAdapter presents a request as coming from an API client so the 402 carries the
+// PAYMENT-REQUIRED header even when the real client is a browser
+type apiAdapter struct {
+	*GinAdapter
+}
+
+func (a apiAdapter) GetAcceptHeader() string {
+	return "application/json"
+}
+
+// browserAdapter presents a request as coming from a browser so the 402 is the paywall page
+type browserAdapter struct {
+	*GinAdapter
+}
+
+func (a browserAdapter) GetAcceptHeader() string {
+	return "text/html"
+}
+
+func (a browserAdapter) GetUserAgent() string {
+	return "Mozilla/5.0"
+}
+
+// challengeFormat returns the 402 format for the current request's route
+func challengeFormat(c *gin.Context, config *MiddlewareConfig) ChallengeFormat {
+	resource := resourceOf(c)
+	var matched string
+	for pattern := range config.ChallengeFormats {
+		if xtended402.ParseRoutePattern(pattern).Matches(resource) && len(pattern) > len(matched) {
+			matched = pattern
+		}
+	}
+	if matched != "" {
+		return config.ChallengeFormats[matched]
+	}
+	if config.ChallengeFormat != "" {
+		return config.ChallengeFormat
+	}
+	return ChallengeAuto
+}
+
+// negotiatedAdapter returns the adapter x402 builds the 402 from. x402 sends HTML whenever
+// Accept mentions text/html; this only does when a browser prefers it to JSON.
+func negotiatedAdapter(c *gin.Context, config *MiddlewareConfig, adapter *GinAdapter) x402http.HTTPAdapter {
+	switch challengeFormat(c, config) {
+	case ChallengeJSON:
+		return apiAdapter{adapter}
+	case ChallengeHTML:
+		return browserAdapter{adapter}
+	}
+
+	c.Writer.Header().Add("Vary", "Accept")
+	accept := c.GetHeader("Accept")
+	if strings.Contains(c.GetHeader("User-Agent"), "Mozilla") &&
+		acceptQuality(accept, "text/html") > acceptQuality(accept, "application/json") {
+		return browserAdapter{adapter}
+	}
+	return apiAdapter{adapter}
+}
+
+// acceptQuality returns the q-value Accept gives mediaType, from its most specific matching
+// range, or 0 if none matches
+func acceptQuality(accept, mediaType string) float64 {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	specificity, quality := -1, 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+
+		var rank int
+		switch strings.ToLower(strings.TrimSpace(params[0])) {
+		case mediaType:
+			rank = 2
+		case mainType + "/*":
+			rank = 1
+		case "*/*":
+			rank = 0
+		default:
+			continue
+		}
+		if rank <= specificity {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			if key, value, ok := strings.Cut(param, "="); ok && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		specificity, quality = rank, q
+	}
+	return quality
+}
+
+// describeChallenge gives a JSON 402 the versioned body and links it to its schema. Routes
+// with their own UnpaidResponseBody keep it.
+func describeChallenge(c *gin.Context, server *x402http.HTTPServer, config *MiddlewareConfig, response *x402http.HTTPResponseInstructions) {
+	if response.IsHTML || response.Status != http.StatusPaymentRequired {
+		return
+	}
+
+	schemaURL := config.PaymentRequiredSchemaURL
+	if schemaURL == "" {
+		schemaURL = xtended402.PaymentRequiredSchemaURL
+	}
+	response.Headers["Link"] = fmt.Sprintf(`<%s>; rel="describedby"`, schemaURL)
+
+	if response.Body != nil {
+		return
+	}
+	required, header, err := challenge(c, server, config, response)
+	if err != nil {
+		fmt.Printf("Warning: 402 body unavailable: %v\n", err)
+		return
+	}
+	body := xtended402.NewPaymentRequiredBody(*required, header)
+	body.Transports = paymentTransports(config)
+	if localizerOf(c) != nil {
+		body.Message = translate(c, body.Error)
+		markLocalized(c)
+	}
+	response.Body = &body
+}
@@ -0,0 +1,90 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Order Connector
+// ============================================================================
+
+// WithOrderConnector creates an order in connector for each verified payment before it
+// settles, and marks it paid once settlement succeeds. If the order can't be created, the
+// payment isn't settled and the request fails with 503. The order ID is available to handlers
+// as PaymentData.OrderID and to event handlers as event.Metadata["orderId"].
+func WithOrderConnector(connector xtended402.OrderConnector) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.OrderConnector = connector
+	}
+}
+
+// createOrder creates the order for a verified payment. When it fails, the error response is
+// written and ok is false.
+func createOrder(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult, requestBody []byte) (orderID string, ok bool) {
+	if config.OrderConnector == nil {
+		return "", true
+	}
+
+	ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.verifyTimeout())
+	defer cancel()
+
+	request := xtended402.NewOrderRequest(resourceOf(c), result.PaymentPayload, result.PaymentRequirements, requestBody)
+	orderID, err := config.OrderConnector.CreateOrder(ctx, request)
+	if err != nil {
+		if handler := errorHandler(c, config); handler != nil {
+			handler(c, rejectionError(StageOrder, http.StatusServiceUnavailable, result.PaymentRequirements, fmt.Errorf("order creation failed: %w", err)))
+		} else {
+			c.JSON(http.StatusServiceUnavailable, errorBody(c, gin.H{
+				"error":   "Order creation failed",
+				"details": err.Error(),
+			}))
+		}
+		c.Abort()
+		return "", false
+	}
+	return orderID, true
+}
+
+// markOrderPaid records a settlement on its order. The payment has already settled, so a
+// failure is only logged; the payment.settled event still carries the order ID for retries.
+func markOrderPaid(c *gin.Context, config *MiddlewareConfig, orderID string, event xtended402.PaymentEvent) {
+	if config.OrderConnector == nil || orderID == "" {
+		return
+	}
+	if err := config.OrderConnector.MarkPaid(c.Request.Context(), orderID, event); err != nil {
+		fmt.Printf("Warning: failed to mark order %s paid: %v\n", orderID, err)
+	}
+}
+
+// withOrderID adds orderID to the event's metadata
+func withOrderID(event xtended402.PaymentEvent, orderID string) xtended402.PaymentEvent {
+	if orderID == "" {
+		return event
+	}
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.OrderIDMetadataKey] = orderID
+	event.Metadata = metadata
+	return event
+}
+
+// withOrderReference adds the order reference set by the pricing step to the event's metadata
+func withOrderReference(event xtended402.PaymentEvent, reference string) xtended402.PaymentEvent {
+	if reference == "" {
+		return event
+	}
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.OrderReferenceMetadataKey] = reference
+	event.Metadata = metadata
+	return event
+}
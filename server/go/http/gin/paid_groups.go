@@ -0,0 +1,134 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Paid Router Groups
+// ============================================================================
+
+// catchAllParam is a Gin catch-all parameter ending a path, e.g. "/*filepath"
+var catchAllParam = regexp.MustCompile(`/\*[^/]*$`)
+
+// PaidGroup registers Gin routes together with their prices, so paid routes aren't declared
+// twice, once in Gin and once as RoutesConfig keys. Each route's pattern is derived from its
+// Gin method and full path:
+//
+//	paid, err := ginmw.NewPaidGroup(r.Group("/api"), server, xtended402.RouteDefaults{
+//		Network: "eip155:8453",
+//		PayTo:   "0x...",
+//	})
+//	paid.GET("/weather/:city", "$0.01", weatherHandler)           // "GET /api/weather/:city"
+//	paid.POST("/purchase", xtended402.ContextPrice(xtended402.PriceKey), priceOrder, purchaseHandler)
+//	r.Group("/api").GET("/health", healthHandler)                 // free
+//
+// The group's middleware runs on every route registered on the Gin group after NewPaidGroup;
+// routes registered through the Gin group directly stay free.
+type PaidGroup struct {
+	group      *gin.RouterGroup
+	routes     *xtended402.RouteGroup
+	middleware *Middleware
+}
+
+// NewPaidGroup attaches payment middleware to group, with no paid routes until they're
+// registered through the returned PaidGroup. defaults fill the payment options routes leave
+// unset, as for xtended402.RouteGroup.
+// Returns an error when initialization fails under InitFailFast.
+func NewPaidGroup(group *gin.RouterGroup, server *x402.X402ResourceServer, defaults xtended402.RouteDefaults, opts ...MiddlewareOption) (*PaidGroup, error) {
+	m, err := NewMiddleware(x402http.RoutesConfig{}, server, opts...)
+	if err != nil {
+		return nil, err
+	}
+	group.Use(m.Handler())
+	return &PaidGroup{
+		group:      group,
+		routes:     xtended402.NewRouteGroup("", defaults),
+		middleware: m,
+	}, nil
+}
+
+// Group creates a Gin subgroup under relativePath whose routes are paid through the same
+// middleware. Its defaults override the group's where set.
+func (g *PaidGroup) Group(relativePath string, defaults xtended402.RouteDefaults, handlers ...gin.HandlerFunc) *PaidGroup {
+	return &PaidGroup{
+		group:      g.group.Group(relativePath, handlers...),
+		routes:     g.routes.Group("", defaults),
+		middleware: g.middleware,
+	}
+}
+
+// Handle registers a paid route with Gin and charges price for it: an x402.Price or
+// x402http.DynamicPriceFunc for a single payment option with the group's defaults, or an
+// x402http.RouteConfig for full control.
+// Panics if the route is invalid, as Gin does for conflicting paths.
+func (g *PaidGroup) Handle(method, relativePath string, price interface{}, handlers ...gin.HandlerFunc) gin.IRoutes {
+	pattern := method + " " + ginRoutePath(g.group.BasePath(), relativePath)
+	if route, ok := price.(x402http.RouteConfig); ok {
+		g.routes.Route(pattern, route)
+	} else {
+		g.routes.Price(pattern, price)
+	}
+	if err := g.middleware.AddPaidRoute(pattern, g.routes.Routes()[pattern]); err != nil {
+		panic(fmt.Sprintf("xtended402: %v", err))
+	}
+	return g.group.Handle(method, relativePath, handlers...)
+}
+
+// GET registers a paid GET route (see Handle)
+func (g *PaidGroup) GET(relativePath string, price interface{}, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodGet, relativePath, price, handlers...)
+}
+
+// POST registers a paid POST route (see Handle)
+func (g *PaidGroup) POST(relativePath string, price interface{}, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodPost, relativePath, price, handlers...)
+}
+
+// PUT registers a paid PUT route (see Handle)
+func (g *PaidGroup) PUT(relativePath string, price interface{}, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodPut, relativePath, price, handlers...)
+}
+
+// PATCH registers a paid PATCH route (see Handle)
+func (g *PaidGroup) PATCH(relativePath string, price interface{}, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodPatch, relativePath, price, handlers...)
+}
+
+// DELETE registers a paid DELETE route (see Handle)
+func (g *PaidGroup) DELETE(relativePath string, price interface{}, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodDelete, relativePath, price, handlers...)
+}
+
+// Routes returns the paid routes registered through the group, its subgroups, and its parent,
+// e.g. for a resource catalog or OpenAPI document
+func (g *PaidGroup) Routes() x402http.RoutesConfig {
+	return g.routes.Routes()
+}
+
+// Middleware returns the group's payment middleware, e.g. for Shutdown
+func (g *PaidGroup) Middleware() *Middleware {
+	return g.middleware
+}
+
+// ginRoutePath joins a Gin group's base path and a route's relative path as Gin does, and turns a
+// trailing catch-all parameter into a wildcard
+func ginRoutePath(basePath, relativePath string) string {
+	full := basePath
+	if relativePath != "" {
+		full = path.Join(basePath, relativePath)
+		if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(full, "/") {
+			full += "/"
+		}
+	}
+	return catchAllParam.ReplaceAllString(full, "/*")
+}
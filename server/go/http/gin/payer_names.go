@@ -0,0 +1,70 @@
+package gin
+
+import (
+	"fmt"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Payer Names
+// ============================================================================
+
+// payerNameKey holds the current request's resolved payer name in the Gin context
+const payerNameKey = "xtended402PayerName"
+
+// payerNameTimeout bounds how long a payment waits for its payer's name
+const payerNameTimeout = 2 * time.Second
+
+// WithPayerNames resolves payers' names, e.g. ENS or Base names, into PaymentData.PayerName
+// and the payerName metadata of payment events, for receipts, notifications, and analytics.
+// Payments go ahead without a name when it can't be resolved in time.
+func WithPayerNames(resolver xtended402.NameResolver) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.PayerNames = resolver
+	}
+}
+
+// resolvedPayerName is a payer's name resolved during the current request
+type resolvedPayerName struct {
+	payer string
+	name  string
+}
+
+// payerName returns the resolved name of payer, or "" when it has none or resolving fails.
+// It's resolved once per request.
+func payerName(c *gin.Context, config *MiddlewareConfig, network x402.Network, payer string) string {
+	if config.PayerNames == nil || payer == "" {
+		return ""
+	}
+	if resolved, ok := c.Get(payerNameKey); ok && resolved.(resolvedPayerName).payer == payer {
+		return resolved.(resolvedPayerName).name
+	}
+
+	ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), payerNameTimeout)
+	defer cancel()
+	name, err := config.PayerNames.ResolveName(ctx, network, payer)
+	if err != nil {
+		fmt.Printf("Warning: failed to resolve payer name: %v\n", err)
+	}
+	c.Set(payerNameKey, resolvedPayerName{payer: payer, name: name})
+	return name
+}
+
+// withPayerName adds the payer's resolved name to the event's metadata
+func withPayerName(c *gin.Context, config *MiddlewareConfig, event xtended402.PaymentEvent) xtended402.PaymentEvent {
+	name := payerName(c, config, event.Network, event.Payer)
+	if name == "" {
+		return event
+	}
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.PayerNameMetadataKey] = name
+	event.Metadata = metadata
+	return event
+}
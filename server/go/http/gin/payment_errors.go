@@ -0,0 +1,126 @@
+package gin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// ============================================================================
+// Payment Errors
+// ============================================================================
+
+// PaymentStage is where a paid request failed
+type PaymentStage string
+
+const (
+	// StageVerify: the payment was rejected before settling, e.g. for a reused challenge nonce,
+	// an invalid refund address, or a card, wallet, invoice, or balance payment that didn't check
+	// out
+	StageVerify PaymentStage = "verify"
+
+	// StageLock: another request is processing the same payment, or the lock store failed
+	StageLock PaymentStage = "lock"
+
+	// StageHook: a before-settle hook rejected the payment
+	StageHook PaymentStage = "hook"
+
+	// StageOrder: the OrderConnector couldn't create the order
+	StageOrder PaymentStage = "order"
+
+	// StageSettle: the facilitator failed to settle the payment
+	StageSettle PaymentStage = "settle"
+
+	// StageTimeout: settlement ran past SettleTimeout, so whether it went through is unknown
+	StageTimeout PaymentStage = "timeout"
+)
+
+// PaymentError is what error handlers receive when a paid request fails, so they can respond
+// to each failure without parsing error strings:
+//
+//	ginmw.WithErrorHandler(func(c *gin.Context, err *ginmw.PaymentError) {
+//		if err.Retryable {
+//			c.Header("Retry-After", "5")
+//		}
+//		c.JSON(err.Status, gin.H{"stage": err.Stage, "code": err.Code, "message": err.Error()})
+//	})
+type PaymentError struct {
+	Stage PaymentStage
+
+	// Status is the status the middleware responds with without an error handler
+	Status int
+
+	// Code is the facilitator's error reason, e.g. "insufficient_funds", when it gave one
+	Code string
+
+	// Requirements are what the payment was checked against (nil for card, wallet, and invoice
+	// payments)
+	Requirements *x402types.PaymentRequirements
+
+	// Retryable reports whether the payment certainly didn't settle, so the client can retry
+	// without risking paying twice
+	Retryable bool
+
+	// Err is the underlying error
+	Err error
+}
+
+func (e *PaymentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PaymentError) Unwrap() error {
+	return e.Err
+}
+
+// rejectionError describes a payment rejected before settling
+func rejectionError(stage PaymentStage, status int, requirements *x402types.PaymentRequirements, err error) *PaymentError {
+	return &PaymentError{
+		Stage:        stage,
+		Status:       status,
+		Requirements: requirements,
+		Retryable:    true,
+		Err:          err,
+	}
+}
+
+// settlementError describes a failed settlement, as a timeout when ctx ran out first
+func settlementError(ctx context.Context, result x402http.HTTPProcessResult, code, reason string) *PaymentError {
+	code = settleErrorCode(code)
+	if code != "" {
+		reason = code
+	}
+	err := &PaymentError{
+		Stage:        StageSettle,
+		Status:       http.StatusPaymentRequired,
+		Code:         code,
+		Requirements: result.PaymentRequirements,
+		Retryable:    true,
+		Err:          fmt.Errorf("settlement failed: %s", reason),
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err.Stage = StageTimeout
+		err.Code = ""
+		err.Retryable = false
+	}
+	return err
+}
+
+// settleErrorCode returns the facilitator's reason from a settlement's error reason. x402
+// passes in-band reasons through as-is, but reports a *x402.SettleError by its message:
+// "settlement failed: <reason>", or "settlement failed: <error> (reason: <reason>)".
+func settleErrorCode(reason string) string {
+	rest, ok := strings.CutPrefix(reason, "settlement failed: ")
+	if !ok {
+		return reason
+	}
+	if i := strings.LastIndex(rest, " (reason: "); i >= 0 && strings.HasSuffix(rest, ")") {
+		return rest[i+len(" (reason: ") : len(rest)-1]
+	}
+	return rest
+}
@@ -0,0 +1,55 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Payment Forwarding
+// ============================================================================
+
+// ForwardPayment creates a Gin handler that signs the request's verified PaymentData into the
+// xtended402.ForwardedPaymentHeader of the request, for a reverse proxy or HTTP client to pass
+// to internal services. Chain it after the payment middleware and before the proxy:
+//
+//	r.Any("/orders/*path", paid, ginmw.ForwardPayment(forwarder), gin.WrapH(ordersProxy))
+//
+// Payment data is only there once the payment settled, so use it on routes settling before the
+// handler (WithSettlementTiming("before")) or paid by card, wallet transfer, or invoice.
+// A header sent by the client is always removed, so services only see payments the gateway
+// verified. Requests without payment data pass through without one.
+func ForwardPayment(forwarder *xtended402.PaymentForwarder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Header.Del(xtended402.ForwardedPaymentHeader)
+
+		data := xtended402.GetPaymentData(c)
+		if data == nil {
+			return
+		}
+		payment := xtended402.NewForwardedPayment(data, resourceOf(c))
+		if err := forwarder.SetHeader(c.Request.Header, payment); err != nil {
+			fmt.Printf("Warning: failed to forward payment for %s: %v\n", payment.Resource, err)
+		}
+	}
+}
+
+// RequireForwardedPayment creates a Gin handler for internal services that rejects requests
+// without a valid forwarded payment with a 401. Handlers read the payment with
+// xtended402.ForwardedPaymentFromContext(c.Request.Context()).
+func RequireForwardedPayment(forwarder *xtended402.PaymentForwarder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payment, err := forwarder.FromHeader(c.Request.Header)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(c, gin.H{
+				"error":   "Invalid forwarded payment",
+				"details": err.Error(),
+			}))
+			return
+		}
+		c.Request = c.Request.WithContext(xtended402.ContextWithForwardedPayment(c.Request.Context(), payment))
+	}
+}
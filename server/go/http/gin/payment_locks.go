@@ -0,0 +1,89 @@
+package gin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Payment Locks
+// ============================================================================
+
+const (
+	// paymentLockTimeout bounds taking and releasing a payment's lock
+	paymentLockTimeout = 2 * time.Second
+
+	// paymentLockTTL is how long payments without an authorization window stay locked
+	paymentLockTTL = 10 * time.Minute
+)
+
+// WithPaymentLocks locks each verified payment, keyed on its authorization, while it's
+// fulfilled and settled, so that replicas receiving the same payment at once can't both
+// fulfill it. The other replicas answer with a 409. Payments that settle stay locked until
+// their authorization expires; others are unlocked for the client to retry. While the
+// locker fails, payments get a 503.
+func WithPaymentLocks(locker xtended402.PaymentLocker) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.PaymentLocks = locker
+	}
+}
+
+// lockPayment takes the lock of the current request's payment and returns how to release it
+// once the request is done. When the payment is locked, it writes the error response and
+// returns false.
+func lockPayment(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult) (func(), bool) {
+	if config.PaymentLocks == nil {
+		return func() {}, true
+	}
+
+	ttl := paymentLockTTL
+	if _, validBefore, ok := xtended402.AuthorizationWindow(result.PaymentPayload); ok {
+		ttl = max(validBefore.Sub(config.clock().Now())+xtended402.ValidityGrace, xtended402.ValidityGrace)
+	}
+	ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), paymentLockTimeout)
+	unlock, err := config.PaymentLocks.Lock(ctx, xtended402.PaymentLockKey(result.PaymentPayload), ttl)
+	cancel()
+	if err == nil {
+		return func() { releasePaymentLock(c, config, unlock) }, true
+	}
+
+	status, message := http.StatusServiceUnavailable, "Payment lock unavailable"
+	if errors.Is(err, xtended402.ErrPaymentLocked) {
+		status, message = http.StatusConflict, "Payment already being processed"
+	} else {
+		fmt.Printf("Warning: failed to lock payment: %v\n", err)
+	}
+	if handler := errorHandler(c, config); handler != nil {
+		paymentErr := rejectionError(StageLock, status, result.PaymentRequirements, err)
+		// A locked payment may be settling in another request
+		paymentErr.Retryable = !errors.Is(err, xtended402.ErrPaymentLocked)
+		handler(c, paymentErr)
+	} else {
+		c.JSON(status, errorBody(c, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		}))
+	}
+	c.Abort()
+	return nil, false
+}
+
+// releasePaymentLock releases a payment's lock unless it settled: its authorization is spent,
+// and the lock keeps replays out until it expires
+func releasePaymentLock(c *gin.Context, config *MiddlewareConfig, unlock func(context.Context) error) {
+	if value, ok := c.Get(settlementLogKey); ok && value.(settlementLog).result.Success {
+		return
+	}
+	ctx, cancel := xtended402.ContextWithTimeout(context.WithoutCancel(c.Request.Context()), config.clock(), paymentLockTimeout)
+	defer cancel()
+	if err := unlock(ctx); err != nil {
+		fmt.Printf("Warning: failed to unlock payment: %v\n", err)
+	}
+}
@@ -0,0 +1,147 @@
+package gin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Payment Transports
+// ============================================================================
+
+// WithBodyPayments accepts payment payloads in the JSON body field named field
+// (xtended402.PaymentBodyField when ""), for clients whose headers can't carry them. The
+// handler sees the body under xtended402.PaymentBodyRequestField, or none. The 402 body offers
+// the field in its transports.
+func WithBodyPayments(field string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		if field == "" {
+			field = xtended402.PaymentBodyField
+		}
+		c.PaymentBodyField = field
+	}
+}
+
+// WithPaymentSubmissions accepts the tokens of payment payloads POSTed to submitURL, where
+// PaymentSubmissionHandler is mounted, in the xtended402.PaymentTokenHeader header. The 402
+// body offers the URL in its transports. Unknown and expired tokens get the 402 challenge.
+func WithPaymentSubmissions(submissions *xtended402.PaymentSubmissions, submitURL string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.PaymentSubmissions = submissions
+		c.PaymentSubmitURL = submitURL
+	}
+}
+
+// PaymentSubmissionHandler stores the payment payload POSTed as the request body, the JSON
+// object or its encoded header, and responds with its token:
+//
+//	r.POST("/pay", ginmw.PaymentSubmissionHandler(submissions))
+func PaymentSubmissionHandler(submissions *xtended402.PaymentSubmissions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, DefaultMaxRequestBody))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unreadable payment payload"})
+			return
+		}
+		if body = bytes.TrimSpace(body); len(body) > 0 && body[0] != '{' && body[0] != '"' {
+			// An encoded header sent as plain text
+			body, _ = json.Marshal(string(body))
+		}
+		submission, err := submissions.Submit(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment payload", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, submission)
+	}
+}
+
+// paymentFromTransport moves a payment payload sent by another transport into the payment
+// header, where x402 reads it. When the payload is invalid it responds and returns false.
+func paymentFromTransport(c *gin.Context, config *MiddlewareConfig) bool {
+	if c.GetHeader(xtended402.PaymentHeader) != "" {
+		return true
+	}
+
+	if token := c.GetHeader(xtended402.PaymentTokenHeader); token != "" && config.PaymentSubmissions != nil {
+		header, err := config.PaymentSubmissions.Redeem(token)
+		if err == nil {
+			c.Request.Header.Set(xtended402.PaymentHeader, header)
+			return true
+		}
+		if !errors.Is(err, xtended402.ErrPaymentSubmissionInvalid) {
+			fmt.Printf("Warning: failed to redeem payment token: %v\n", err)
+		}
+	}
+
+	if config.PaymentBodyField != "" && c.ContentType() == "application/json" {
+		return paymentFromBody(c, config)
+	}
+	return true
+}
+
+// paymentFromBody unwraps a payment envelope, leaving the handler the request's own body.
+// Bodies without the payment field are left as they are.
+func paymentFromBody(c *gin.Context, config *MiddlewareConfig) bool {
+	body := readRequestBody(c, config.maxRequestBody())
+	if len(body) == 0 {
+		return true
+	}
+	body, err := decodeBody(c.GetHeader("Content-Encoding"), body, config.maxRequestBody())
+	if err != nil {
+		return true
+	}
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return true
+	}
+	payload, ok := envelope[config.PaymentBodyField]
+	if !ok {
+		return true
+	}
+
+	header, err := xtended402.EncodePaymentPayload(payload)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorBody(c, gin.H{
+			"error":   "Invalid payment payload",
+			"details": err.Error(),
+		}))
+		return false
+	}
+	c.Request.Header.Set(xtended402.PaymentHeader, header)
+
+	// The inner request is handed on decoded
+	request := envelope[xtended402.PaymentBodyRequestField]
+	c.Request.Header.Del("Content-Encoding")
+	c.Request.Body = io.NopCloser(bytes.NewReader(request))
+	c.Request.ContentLength = int64(len(request))
+	if len(request) == 0 {
+		c.Request.Body = http.NoBody
+	}
+	return true
+}
+
+// paymentTransports returns the transports offered besides the header, or nil
+func paymentTransports(config *MiddlewareConfig) *xtended402.PaymentTransports {
+	if config.PaymentBodyField == "" && config.PaymentSubmissions == nil {
+		return nil
+	}
+	transports := &xtended402.PaymentTransports{
+		Header:    xtended402.PaymentHeader,
+		BodyField: config.PaymentBodyField,
+	}
+	if config.PaymentSubmissions != nil {
+		transports.SubmitURL = config.PaymentSubmitURL
+		transports.TokenHeader = xtended402.PaymentTokenHeader
+	}
+	return transports
+}
@@ -0,0 +1,166 @@
+package gin
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"strings"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Custom Paywall
+// ============================================================================
+
+// WithPaywallRenderer replaces x402's built-in browser paywall with renderer, e.g. a
+// xtended402.PaywallTemplate. API clients still get the JSON challenge.
+func WithPaywallRenderer(renderer xtended402.PaywallRenderer) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.PaywallRenderer = renderer
+	}
+}
+
+// renderPaywall replaces a paywall page with the configured renderer's. Failures keep the
+// built-in page.
+func renderPaywall(
+	c *gin.Context,
+	server *x402http.HTTPServer,
+	config *MiddlewareConfig,
+	response *x402http.HTTPResponseInstructions,
+	checkout *xtended402.FiatCheckout,
+	walletCheckout *xtended402.WalletCheckout,
+) {
+	required, header, err := challenge(c, server, config, response)
+	if err != nil {
+		fmt.Printf("Warning: custom paywall unavailable: %v\n", err)
+		return
+	}
+
+	data := xtended402.NewPaywallData(resourceOf(c), *required, header)
+	if paywall := config.PaywallConfig; paywall != nil {
+		data.AppName = paywall.AppName
+		data.AppLogo = paywall.AppLogo
+		data.Testnet = paywall.Testnet
+	}
+	if theme := config.PaywallTheme; theme != nil {
+		data.Theme = theme
+		data.ProductImage = theme.ProductImageFor(data.Resource)
+	}
+	data.FiatCheckout = checkout
+	if walletCheckout != nil {
+		data.WalletCheckout = walletCheckout
+		data.WalletWidget = htmltemplate.HTML(walletWidget(c, config, walletCheckout))
+	}
+	if config.DevPaywall != nil {
+		if guide := config.DevPaywall.Guide(required.Accepts); guide != nil {
+			data.TestnetGuide = guide
+			data.TestnetWidget = htmltemplate.HTML(testnetWidget(c, config, guide, header))
+		}
+	}
+	data.Tenant = xtended402.GetTenant(c)
+	data.Locale = xtended402.GetLocale(c)
+	data.Localizer = localizerOf(c)
+
+	var page bytes.Buffer
+	if err := config.PaywallRenderer.RenderPaywall(c.Request.Context(), &page, data); err != nil {
+		fmt.Printf("Warning: custom paywall failed to render: %v\n", err)
+		return
+	}
+	response.Body = page.String()
+	if data.Localizer != nil {
+		markLocalized(c)
+	}
+}
+
+// ============================================================================
+// Paywall Theme
+// ============================================================================
+
+// WithPaywallTheme brands x402's built-in paywall page with a logo, colors, product imagery,
+// and footer links. Custom paywalls from WithPaywallRenderer get the theme as .Theme instead.
+func WithPaywallTheme(theme *xtended402.PaywallTheme) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.PaywallTheme = theme
+	}
+}
+
+// themePaywall applies the theme to x402's built-in paywall page
+func themePaywall(c *gin.Context, config *MiddlewareConfig, response *x402http.HTTPResponseInstructions) {
+	page, ok := response.Body.(string)
+	if !ok {
+		return
+	}
+	theme := config.PaywallTheme
+
+	if css := themeCSS(theme); css != "" {
+		page = insertBefore(page, "</head>", "<style>"+css+"</style>")
+	}
+
+	if theme.Logo != "" {
+		logo := html.EscapeString(theme.Logo)
+		if config.PaywallConfig != nil && config.PaywallConfig.AppLogo != "" {
+			page = strings.Replace(page, `src="`+html.EscapeString(config.PaywallConfig.AppLogo)+`"`, `src="`+logo+`"`, 1)
+		} else {
+			alt := ""
+			if config.PaywallConfig != nil {
+				alt = html.EscapeString(config.PaywallConfig.AppName)
+			}
+			page = strings.Replace(page, `<div class="container">`,
+				`<div class="container"><img class="logo" src="`+logo+`" alt="`+alt+`" style="max-width: 200px;">`, 1)
+		}
+	}
+
+	if image := theme.ProductImageFor(resourceOf(c)); image != "" {
+		page = strings.Replace(page, "</h1>",
+			`</h1><img class="product" src="`+html.EscapeString(image)+`" alt="" style="max-width: 100%; border-radius: 6px;">`, 1)
+	}
+
+	if len(theme.FooterLinks) > 0 {
+		links := make([]string, 0, len(theme.FooterLinks))
+		for _, link := range theme.FooterLinks {
+			links = append(links, `<a href="`+html.EscapeString(link.URL)+`">`+html.EscapeString(link.Label)+`</a>`)
+		}
+		page = insertBefore(page, "</body>",
+			`<footer style="text-align:center;margin:1.5rem auto;font-size:14px">`+strings.Join(links, " &middot; ")+`</footer>`)
+	}
+
+	response.Body = page
+}
+
+// themeCSS overrides the built-in page's styles with the theme's. Values that could break out
+// of their declaration are dropped; Validate reports them.
+func themeCSS(theme *xtended402.PaywallTheme) string {
+	var css strings.Builder
+	rule := func(selector, property, value string) {
+		if value != "" && !unsafeCSS(value) {
+			fmt.Fprintf(&css, "%s{%s:%s}", selector, property, value)
+		}
+	}
+
+	rule("body", "background", theme.BackgroundColor)
+	rule("body", "color", theme.TextColor)
+	rule("body", "font-family", theme.FontFamily)
+	rule(".container", "background", theme.SurfaceColor)
+	rule("h1", "color", theme.TextColor)
+	rule(".amount", "color", theme.PrimaryColor)
+	rule("a", "color", theme.PrimaryColor)
+	rule("#payment-widget", "border-color", theme.PrimaryColor)
+	return css.String()
+}
+
+// unsafeCSS reports whether a theme value could end its declaration or the style element
+func unsafeCSS(value string) bool {
+	return strings.ContainsAny(value, ";{}<>\\")
+}
+
+// insertBefore inserts text before the last occurrence of tag, or at the end without one
+func insertBefore(page, tag, text string) string {
+	if i := strings.LastIndex(page, tag); i >= 0 {
+		return page[:i] + text + page[i:]
+	}
+	return page + text
+}
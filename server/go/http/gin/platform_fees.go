@@ -0,0 +1,19 @@
+package gin
+
+import (
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Platform Fees
+// ============================================================================
+
+// WithPlatformFee records fee, a platform's cut, on every settled token payment: events carry
+// it as event.Metadata["platformFee"] and the rest as event.Metadata["netAmount"], and
+// RecordPayments stores both on the PaymentRecord. The payer still pays the route's price to
+// its recipient; pay the fee out with xtended402.PayPlatformFees.
+func WithPlatformFee(fee xtended402.PlatformFee) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.PlatformFee = &fee
+	}
+}
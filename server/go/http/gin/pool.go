@@ -0,0 +1,76 @@
+package gin
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================================
+// Pooling
+// ============================================================================
+
+// maxPooledBuffer is the largest buffer returned to a pool. Bigger ones, from large uploads or
+// responses, are left to the garbage collector so one outlier doesn't pin its memory.
+const maxPooledBuffer = 1 << 20
+
+var adapterPool = sync.Pool{
+	New: func() interface{} { return new(GinAdapter) },
+}
+
+// acquireAdapter returns a pooled adapter for c. Release it once the request is handled.
+func acquireAdapter(c *gin.Context) *GinAdapter {
+	adapter := adapterPool.Get().(*GinAdapter)
+	adapter.ctx = c
+	return adapter
+}
+
+func releaseAdapter(adapter *GinAdapter) {
+	adapter.ctx = nil
+	adapterPool.Put(adapter)
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+var capturePool = sync.Pool{
+	New: func() interface{} {
+		return &responseCapture{body: new(bytes.Buffer), held: make(http.Header), trailers: make(http.Header)}
+	},
+}
+
+// acquireCapture returns a pooled responseCapture wrapping w. Release it only after restoring
+// c.Writer and replaying the captured response.
+func acquireCapture(w gin.ResponseWriter) *responseCapture {
+	capture := capturePool.Get().(*responseCapture)
+	capture.ResponseWriter = w
+	capture.statusCode = http.StatusOK
+	return capture
+}
+
+func releaseCapture(capture *responseCapture) {
+	if capture.body.Cap() > maxPooledBuffer {
+		return
+	}
+	capture.ResponseWriter = nil
+	capture.body.Reset()
+	clear(capture.held)
+	clear(capture.trailers)
+	capture.written = false
+	capture.limit = 0
+	capture.streaming = false
+	capture.ended = false
+	capture.hijacked = false
+	capturePool.Put(capture)
+}
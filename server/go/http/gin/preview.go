@@ -0,0 +1,83 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Price Preview
+// ============================================================================
+
+// PricePreviewHandler creates a Gin handler that returns the payment requirements the paid
+// route would charge for the current request, without issuing a 402 or verifying any payment.
+//
+// Chain it after the same pricing middleware used by the paid route so ContextPrice resolves
+// identically, letting carts display the exact payable total before checkout:
+//
+//	preview, err := mw.PricePreviewHandler("POST /api/purchase")
+//	r.POST("/api/purchase/preview", calculateOrderTotal, preview)
+//
+// It prices with the routes in effect, so routes changed at runtime are previewed as changed;
+// once the route is removed, previews get a 404. The error is a *ValidationError when route
+// isn't a paid route.
+func (m *Middleware) PricePreviewHandler(route string) (gin.HandlerFunc, error) {
+	if _, ok := m.config.routes()[route]; !ok {
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("price preview route %q is not a paid route", route)}}
+	}
+
+	pattern := xtended402.ParseRoutePattern(route)
+	method, path := pattern.Method(), pattern.Path()
+
+	return func(c *gin.Context) {
+		if c.IsAborted() {
+			return
+		}
+
+		table := m.config.table.Load()
+		routeConfig, ok := table.routes[route]
+		if !ok {
+			c.JSON(http.StatusNotFound, errorBody(c, gin.H{
+				"error":   "Route not found",
+				"details": fmt.Sprintf("%s is no longer a paid route", route),
+			}))
+			return
+		}
+
+		ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), m.config.clock(), m.config.Timeout)
+		defer cancel()
+		if c.Request.URL.RawQuery != "" {
+			ctx = xtended402.ContextWithQuery(ctx, c.Request.URL.Query())
+		}
+
+		reqCtx := x402http.HTTPRequestContext{
+			Adapter: NewGinAdapter(c),
+			Path:    path,
+			Method:  method,
+		}
+
+		requirements, err := table.server.BuildPaymentRequirementsFromOptions(ctx, routeConfig.Accepts, reqCtx)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, errorBody(c, gin.H{
+				"error":   "Failed to resolve price",
+				"details": err.Error(),
+			}))
+			return
+		}
+
+		c.JSON(http.StatusOK, x402types.PaymentRequired{
+			X402Version: 2,
+			Resource: &x402types.ResourceInfo{
+				URL:         routeConfig.Resource,
+				Description: routeConfig.Description,
+				MimeType:    routeConfig.MimeType,
+			},
+			Accepts: requirements,
+		})
+	}, nil
+}
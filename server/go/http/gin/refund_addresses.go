@@ -0,0 +1,60 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Refund Addresses
+// ============================================================================
+
+// checkRefundAddress rejects a payment whose refund address isn't an address on its network,
+// or that has none when its route requires one. It writes the error response and returns false.
+func checkRefundAddress(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult) bool {
+	address := xtended402.RefundAddressOf(result.PaymentPayload)
+	var err error
+	switch {
+	case address != "":
+		err = xtended402.CheckRefundAddress(x402.Network(result.PaymentRequirements.Network), address)
+	default:
+		if request := xtended402.ResourceRefundAddress(config.routes(), resourceOf(c)); request != nil && request.Required {
+			err = fmt.Errorf("%w: the route requires a refund address in the payment's %q extension", xtended402.ErrRefundAddressInvalid, xtended402.RefundAddressExtensionKey)
+		}
+	}
+	if err == nil {
+		return true
+	}
+
+	if handler := errorHandler(c, config); handler != nil {
+		handler(c, rejectionError(StageVerify, http.StatusBadRequest, result.PaymentRequirements, err))
+	} else {
+		c.JSON(http.StatusBadRequest, errorBody(c, gin.H{
+			"error":   "Invalid refund address",
+			"details": err.Error(),
+		}))
+	}
+	c.Abort()
+	return false
+}
+
+// withRefundAddress adds the address a payment asked to be refunded to to the event's metadata
+func withRefundAddress(event xtended402.PaymentEvent, result x402http.HTTPProcessResult) xtended402.PaymentEvent {
+	address := xtended402.RefundAddressOf(result.PaymentPayload)
+	if address == "" || result.PaymentRequirements == nil ||
+		xtended402.CheckRefundAddress(x402.Network(result.PaymentRequirements.Network), address) != nil {
+		return event
+	}
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.RefundAddressMetadataKey] = address
+	event.Metadata = metadata
+	return event
+}
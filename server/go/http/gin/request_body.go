@@ -0,0 +1,156 @@
+package gin
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Request Body Buffering
+// ============================================================================
+
+// DefaultMaxRequestBody is the largest request body buffered into PaymentData by default
+const DefaultMaxRequestBody = 1 << 20
+
+// requestBodyKey holds the buffered request body once it has been read
+const requestBodyKey = "xtended402RequestBody"
+
+// WithRequestBodyRoutes buffers request bodies into PaymentData.RequestBody and orders only on
+// paid routes matching the patterns, e.g. "POST /api/purchase". Call it with none to never
+// buffer. Without it, every paid route buffers. Other routes' bodies stream to the handler.
+func WithRequestBodyRoutes(routes ...string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.RequestBodyRoutes = xtended402.NewRouteMatcher(routes...)
+	}
+}
+
+// WithMaxRequestBody sets the largest request body buffered (default DefaultMaxRequestBody).
+// Larger bodies stream to the handler unbuffered and PaymentData.RequestBody is nil.
+func WithMaxRequestBody(n int64) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.MaxRequestBody = n
+	}
+}
+
+// errBodyTooLarge is returned when a decoded request body exceeds the buffering limit
+var errBodyTooLarge = errors.New("request body too large")
+
+// bufferedBody returns the request body for PaymentData and orders, reading it the first time
+// a payment needs it so requests answered with a 402 never do. A gzip or deflate body is
+// decoded, while the handler still reads it as sent. It's nil when the route doesn't buffer
+// bodies, or the body is too large or can't be decoded.
+func bufferedBody(c *gin.Context, config *MiddlewareConfig) []byte {
+	if body, exists := c.Get(requestBodyKey); exists {
+		return body.([]byte)
+	}
+
+	var body []byte
+	if config.RequestBodyRoutes == nil || config.RequestBodyRoutes.Match(c.Request.Method, c.Request.URL.Path) {
+		body = readRequestBody(c, config.maxRequestBody())
+		if decoded, err := decodeBody(c.GetHeader("Content-Encoding"), body, config.maxRequestBody()); err == nil {
+			body = decoded
+		} else {
+			body = nil
+		}
+	}
+	c.Set(requestBodyKey, body)
+	return body
+}
+
+// readRequestBody reads a body of up to max bytes through a pooled buffer and restores it for
+// the handler. The returned slice is the request's own, sized to the body, since payment data
+// and events keep it past the request. Larger bodies are put back together unread and nil is
+// returned.
+func readRequestBody(c *gin.Context, max int64) []byte {
+	if c.Request.Body == nil {
+		return nil
+	}
+	if c.Request.Body == http.NoBody {
+		return []byte{}
+	}
+	if c.Request.ContentLength > max {
+		return nil
+	}
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer releaseBuffer(buf)
+
+	if _, err := buf.ReadFrom(io.LimitReader(c.Request.Body, max+1)); err != nil {
+		return nil
+	}
+	read := bytes.Clone(buf.Bytes())
+	if read == nil {
+		read = []byte{}
+	}
+
+	if int64(len(read)) > max {
+		// Too large to buffer: replay what was read ahead of the rest
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(read), c.Request.Body), c.Request.Body}
+		return nil
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(read))
+	return read
+}
+
+// decodeBody undoes the Content-Encoding of a request body, the last listed coding first. The
+// decoded body is limited to max bytes so a small compressed body can't expand without bound.
+func decodeBody(encoding string, body []byte, max int64) ([]byte, error) {
+	if encoding == "" || body == nil {
+		return body, nil
+	}
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var reader io.Reader
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, fmt.Errorf("invalid gzip body: %w", err)
+			}
+			defer gz.Close()
+			reader = gz
+		case "deflate":
+			// Meant to be zlib-wrapped, but some clients send raw deflate
+			zr, err := zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				reader = flate.NewReader(bytes.NewReader(body))
+			} else {
+				reader = zr
+			}
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %q", coding)
+		}
+
+		decoded, err := io.ReadAll(io.LimitReader(reader, max+1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s body: %w", strings.TrimSpace(codings[i]), err)
+		}
+		if int64(len(decoded)) > max {
+			return nil, errBodyTooLarge
+		}
+		body = decoded
+	}
+	return body, nil
+}
+
+func (c *MiddlewareConfig) maxRequestBody() int64 {
+	if c.MaxRequestBody > 0 {
+		return c.MaxRequestBody
+	}
+	return DefaultMaxRequestBody
+}
@@ -0,0 +1,141 @@
+package gin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Requirement Extras
+// ============================================================================
+
+// requirementExtrasKey holds the extras resolved for the current request in the Gin context
+const requirementExtrasKey = "xtended402RequirementExtras"
+
+// WithRequirementExtras adds extras to the payment requirements of routes matching the
+// patterns, e.g. "POST /api/purchase". The longest matching pattern wins. Payments whose
+// accepted requirements don't echo the fields unchanged, or that extras.Validate rejects, get a
+// 402. Handlers find the fields in PaymentData.PaymentRequirements.Extra.
+func WithRequirementExtras(extras xtended402.RequirementExtras, routes ...string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		if c.RequirementExtras == nil {
+			c.RequirementExtras = make(map[string]xtended402.RequirementExtras)
+		}
+		for _, route := range routes {
+			c.RequirementExtras[route] = extras
+		}
+	}
+}
+
+// routeExtras returns the requirement extras for the current request's route, or nil
+func routeExtras(c *gin.Context, config *MiddlewareConfig) *xtended402.RequirementExtras {
+	resource := resourceOf(c)
+	var matched string
+	for pattern := range config.RequirementExtras {
+		if xtended402.ParseRoutePattern(pattern).Matches(resource) && len(pattern) > len(matched) {
+			matched = pattern
+		}
+	}
+	if matched == "" {
+		return nil
+	}
+	extras := config.RequirementExtras[matched]
+	return &extras
+}
+
+// resolveRequirementExtras resolves the current request's requirement extras, with the order
+// reference set by the pricing step, into the Gin context. When they can't be resolved, the
+// error response is written and ok is false.
+func resolveRequirementExtras(c *gin.Context, ctx context.Context, config *MiddlewareConfig, reqCtx x402http.HTTPRequestContext) (ok bool) {
+	extras := routeExtras(c, config)
+	reference := xtended402.OrderReferenceFromContext(ctx)
+	if extras == nil && reference == "" {
+		return true
+	}
+	resolved := make(map[string]interface{}, 1)
+	if extras != nil {
+		var err error
+		if resolved, err = extras.Resolve(ctx, reqCtx); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorBody(c, gin.H{"error": err.Error()}))
+			return false
+		}
+	}
+	if reference != "" {
+		resolved[xtended402.OrderReferenceExtraKey] = reference
+	}
+	c.Set(requirementExtrasKey, resolved)
+	return true
+}
+
+// requirementExtrasOf returns the extras resolved for the current request, or nil
+func requirementExtrasOf(c *gin.Context) map[string]interface{} {
+	extras, ok := c.Get(requirementExtrasKey)
+	if !ok {
+		return nil
+	}
+	return extras.(map[string]interface{})
+}
+
+// extendChallenge adds the current request's requirement extras and challenge nonce to a
+// PAYMENT-REQUIRED header
+func extendChallenge(c *gin.Context, header string) string {
+	extras, nonce := requirementExtrasOf(c), challengeNonceOf(c)
+	if (extras == nil && nonce == "") || header == "" {
+		return header
+	}
+	required, err := xtended402.DecodePaymentRequiredHeader(header)
+	if err != nil {
+		return header
+	}
+	for i, requirements := range required.Accepts {
+		required.Accepts[i] = xtended402.WithChallengeNonce(xtended402.WithExtras(requirements, extras), nonce)
+	}
+	data, err := json.Marshal(required)
+	if err != nil {
+		return header
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// checkRequirementExtras checks the extras echoed by a verified payment and adds the generated
+// ones to the requirements it settles against. When the extras don't match, the error response
+// is written and ok is false.
+func checkRequirementExtras(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult) (x402http.HTTPProcessResult, bool) {
+	extras := requirementExtrasOf(c)
+	if extras == nil {
+		return result, true
+	}
+
+	echoed := result.PaymentPayload.Accepted.Extra
+	err := xtended402.CheckEchoedExtras(extras, echoed)
+	if err == nil {
+		if route := routeExtras(c, config); route != nil && route.Validate != nil {
+			if err = route.Validate(c.Request.Context(), echoed); err != nil {
+				err = fmt.Errorf("%w: %v", xtended402.ErrExtrasMismatch, err)
+			}
+		}
+	}
+	if err != nil {
+		if handler := errorHandler(c, config); handler != nil {
+			handler(c, rejectionError(StageVerify, http.StatusPaymentRequired, result.PaymentRequirements, err))
+		} else {
+			c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
+				"error":   "Payment requirements mismatch",
+				"details": err.Error(),
+			}))
+		}
+		c.Abort()
+		return result, false
+	}
+
+	requirements := xtended402.WithExtras(*result.PaymentRequirements, extras)
+	result.PaymentRequirements = &requirements
+	return result, true
+}
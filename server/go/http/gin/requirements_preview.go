@@ -0,0 +1,77 @@
+package gin
+
+import (
+	"net/http"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Requirements Preview
+// ============================================================================
+
+// RequirementsPreviewQuery is the query parameter asking a paid route for its payment
+// requirements without paying, e.g. "POST /api/purchase?x402-preview". HEAD requests always
+// get them.
+const RequirementsPreviewQuery = "x402-preview"
+
+// isPreflight reports whether the request is a CORS preflight. Browsers send them without the
+// payment, so on routes priced for OPTIONS they're answered with a 204 rather than a challenge,
+// never reaching the handler.
+func isPreflight(c *gin.Context) bool {
+	return c.Request.Method == http.MethodOptions &&
+		c.GetHeader("Origin") != "" &&
+		c.GetHeader("Access-Control-Request-Method") != ""
+}
+
+// isPreview reports whether the request asks for the payment requirements only
+func isPreview(c *gin.Context) bool {
+	if c.Request.Method == http.MethodHead {
+		return true
+	}
+	_, ok := c.Request.URL.Query()[RequirementsPreviewQuery]
+	return ok
+}
+
+// paidMethod returns the method the request is priced as. HEAD previews the route's GET unless
+// HEAD is a paid route of its own.
+func paidMethod(c *gin.Context, matcher *xtended402.RouteMatcher) string {
+	if c.Request.Method == http.MethodHead && !matcher.Match(http.MethodHead, c.Request.URL.Path) {
+		return http.MethodGet
+	}
+	return c.Request.Method
+}
+
+// previewRequirements answers a preview request with the PAYMENT-REQUIRED header the route
+// would challenge with, and no body. Any payment sent along is ignored, never verified or
+// settled, and the handler doesn't run.
+func previewRequirements(c *gin.Context, config *MiddlewareConfig, server *x402http.HTTPServer, reqCtx x402http.HTTPRequestContext, adapter *GinAdapter) {
+	defer c.Abort()
+	c.Request.Header.Del(xtended402.PaymentHeader)
+	c.Header("Cache-Control", "no-cache")
+
+	ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.verifyTimeout())
+	defer cancel()
+
+	if !resolveRequirementExtras(c, ctx, config, reqCtx) {
+		return
+	}
+
+	// The header is only sent with JSON challenges
+	reqCtx.Adapter = apiAdapter{adapter}
+	result := server.ProcessHTTPRequest(ctx, reqCtx, nil)
+	switch {
+	case result.Type == x402http.ResultNoPaymentRequired:
+		c.Status(http.StatusNoContent)
+	case result.Response != nil && result.Response.Headers["PAYMENT-REQUIRED"] != "":
+		c.Header("PAYMENT-REQUIRED", extendChallenge(c, result.Response.Headers["PAYMENT-REQUIRED"]))
+		c.Status(http.StatusNoContent)
+	case result.Response != nil:
+		c.Status(result.Response.Status)
+	default:
+		c.Status(http.StatusInternalServerError)
+	}
+	c.Writer.WriteHeaderNow()
+}
@@ -0,0 +1,83 @@
+package gin
+
+import (
+	x402 "github.com/coinbase/x402/go"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================================
+// Reservations
+// ============================================================================
+
+// reservationKey holds the current request's reservation in the Gin context
+const reservationKey = "xtended402Reservation"
+
+// ReserveFunc reserves what a verified payment buys, e.g. stock, and returns how to release the
+// reservation (nil when there's nothing to release). An error stops settlement as a
+// before-settle hook's does, e.g. when the item sold out.
+type ReserveFunc func(c *gin.Context, verify *x402.VerifyResponse) (release func(), err error)
+
+// ReserveThenSettle reserves with reserve once the before-settle hooks pass, then settles. The
+// reservation is released when settlement fails or, with "before" settlement timing, when the
+// handler fails, so concurrent buyers can't both pay for the last item:
+//
+//	ginmw.ReserveThenSettle(func(c *gin.Context, verify *x402.VerifyResponse) (func(), error) {
+//		sku := c.Param("sku")
+//		if err := inventory.Reserve(sku, 1); err != nil {
+//			return nil, err
+//		}
+//		return func() { inventory.Release(sku, 1) }, nil
+//	})
+//
+// Handlers fail by responding with a 4xx or 5xx status, or adding an error with c.Error. Their
+// payment has already settled, so refund it yourself.
+func ReserveThenSettle(reserve ReserveFunc) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Reserve = reserve
+	}
+}
+
+// reservation is a reservation held by the current request
+type reservation struct {
+	release func()
+	kept    bool
+}
+
+// reserve makes the current request's reservation
+func reserve(c *gin.Context, config *MiddlewareConfig, verifyResp *x402.VerifyResponse) error {
+	if config.Reserve == nil {
+		return nil
+	}
+	release, err := config.Reserve(c, verifyResp)
+	if err != nil {
+		return err
+	}
+	if release != nil {
+		c.Set(reservationKey, &reservation{release: release})
+	}
+	return nil
+}
+
+// keepReservation keeps the current request's reservation once its purchase went through
+func keepReservation(c *gin.Context) {
+	if value, ok := c.Get(reservationKey); ok {
+		value.(*reservation).kept = true
+	}
+}
+
+// releaseReservation releases the current request's reservation unless it's kept. It's
+// released once.
+func releaseReservation(c *gin.Context) {
+	value, ok := c.Get(reservationKey)
+	if !ok || value.(*reservation).kept {
+		return
+	}
+	held := value.(*reservation)
+	held.kept = true
+	held.release()
+}
+
+// handlerFailed reports whether the handler responded with an error
+func handlerFailed(c *gin.Context) bool {
+	return c.Writer.Status() >= 400 || len(c.Errors) > 0
+}
@@ -0,0 +1,153 @@
+package gin
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Response Streaming
+// ============================================================================
+
+// DefaultMaxResponseBuffer is how much of a streaming route's response is held before it
+// starts streaming, by default
+const DefaultMaxResponseBuffer = 1 << 20
+
+// StreamStatusTrailer is the trailer carrying a streamed response's final status. Handlers set
+// it with SetStreamStatus when a stream fails after its headers were sent, so the payment
+// doesn't settle; the middleware sets it to 402 when settlement fails.
+const StreamStatusTrailer = "X-Stream-Status"
+
+// streamTrailers are announced in the Trailer header of streamed responses
+var streamTrailers = []string{"PAYMENT-RESPONSE", StreamStatusTrailer}
+
+// WithStreamingResponses lets paid routes matching the patterns, e.g. "GET /downloads/*",
+// stream their responses with after-settlement timing. Responses are held until the payment
+// settles as usual, until the handler flushes or writes more than MaxResponseBuffer: then
+// what's held is sent and the rest passes through. The payment settles once the handler
+// returns, if its status, or the StreamStatusTrailer it set, is below 400, and the settlement
+// headers are sent as trailers.
+//
+// A streamed response can't be withheld when settlement fails, so only stream what's fine to
+// give away then: failures are reported in the StreamStatusTrailer and payment.failed events.
+func WithStreamingResponses(routes ...string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.StreamingRoutes = xtended402.NewRouteMatcher(routes...)
+	}
+}
+
+// WithMaxResponseBuffer sets how much of a streaming route's response is held before it starts
+// streaming (default DefaultMaxResponseBuffer)
+func WithMaxResponseBuffer(n int) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.MaxResponseBuffer = n
+	}
+}
+
+// SetStreamStatus records the final status of the response being streamed, e.g. a 500 when
+// the source of a download fails halfway. A status of 400 or above keeps the payment from
+// settling. It's sent as the StreamStatusTrailer.
+func SetStreamStatus(c *gin.Context, status int) {
+	c.Writer.Header().Set(http.TrailerPrefix+StreamStatusTrailer, strconv.Itoa(status))
+}
+
+// responseBufferLimit returns how much of the current request's response is held before it
+// streams, or 0 when its route doesn't stream
+func responseBufferLimit(c *gin.Context, config *MiddlewareConfig) int {
+	if config.StreamingRoutes == nil || !config.StreamingRoutes.Match(c.Request.Method, c.Request.URL.Path) {
+		return 0
+	}
+	if config.MaxResponseBuffer > 0 {
+		return config.MaxResponseBuffer
+	}
+	return DefaultMaxResponseBuffer
+}
+
+// Flush starts streaming on streaming routes: the captured response is sent and later writes
+// pass through. Elsewhere it's a no-op, the response being held until the payment settles.
+func (w *responseCapture) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.limit == 0 || w.ended || w.hijacked {
+		return
+	}
+	if !w.written {
+		w.writeHeaderLocked(http.StatusOK)
+	}
+	w.streamLocked()
+	w.ResponseWriter.Flush()
+}
+
+// streamLocked sends the captured response and switches the capture to pass-through. The
+// Content-Length is dropped so the response is chunked and can carry trailers.
+func (w *responseCapture) streamLocked() {
+	if w.streaming {
+		return
+	}
+	w.streaming = true
+
+	header := w.ResponseWriter.Header()
+	header.Del("Content-Length")
+	for _, key := range streamTrailers {
+		header.Add("Trailer", key)
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	w.body.Reset()
+}
+
+// endStream reports whether the response was streamed or the connection hijacked, in which
+// case the handler is done writing: later writes, like error bodies, are dropped, and headers
+// set are sent as trailers, if anything
+func (w *responseCapture) endStream() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.ended = w.streaming || w.hijacked
+	return w.ended
+}
+
+// restoreStream puts the wrapped writer back in c, sending the headers set since endStream
+// as trailers
+func (w *responseCapture) restoreStream(c *gin.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	header := w.ResponseWriter.Header()
+	for key, values := range w.trailers {
+		key = strings.TrimPrefix(key, http.TrailerPrefix)
+		if isContentHeader(key) {
+			continue
+		}
+		header[http.TrailerPrefix+key] = values
+	}
+	c.Writer = w.ResponseWriter
+}
+
+// failed reports whether the handler failed, by its status or the StreamStatusTrailer it set
+func (w *responseCapture) failed() bool {
+	if w.statusCode >= 400 {
+		return true
+	}
+	header := w.ResponseWriter.Header()
+	for _, key := range []string{StreamStatusTrailer, http.TrailerPrefix + StreamStatusTrailer} {
+		if status, err := strconv.Atoi(header.Get(key)); err == nil && status >= 400 {
+			return true
+		}
+	}
+	return false
+}
+
+func isContentHeader(key string) bool {
+	for _, content := range contentHeaders {
+		if http.CanonicalHeaderKey(key) == content {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package gin
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Revocations
+// ============================================================================
+
+// WithRevocations rejects session tokens, API keys, and access grants whose paying transaction
+// is in list, so access bought by a refunded payment ends. Fill list from refund events with
+// xtended402.RevokeRefunded. Rejected requests fall through to the 402 flow.
+func WithRevocations(list xtended402.RevocationList) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Revocations = list
+	}
+}
+
+// revoked reports whether access paid by transaction was revoked. If the list can't be read,
+// the access is treated as revoked, so the request falls back to payment.
+func revoked(c *gin.Context, config *MiddlewareConfig, transaction string) bool {
+	if config.Revocations == nil || transaction == "" {
+		return false
+	}
+	isRevoked, err := config.Revocations.IsRevoked(c.Request.Context(), transaction)
+	if err != nil {
+		fmt.Printf("Warning: failed to check revocation of %s: %v\n", transaction, err)
+		return true
+	}
+	return isRevoked
+}
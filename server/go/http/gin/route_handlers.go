@@ -0,0 +1,43 @@
+package gin
+
+import (
+	x402 "github.com/coinbase/x402/go"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Per-Route Handlers
+// ============================================================================
+
+// errorHandler returns the error handler for the current request's route: the one set for the
+// longest matching pattern, otherwise the middleware's
+func errorHandler(c *gin.Context, config *MiddlewareConfig) func(*gin.Context, *PaymentError) {
+	resource := resourceOf(c)
+	var matched string
+	for pattern := range config.ErrorHandlers {
+		if xtended402.ParseRoutePattern(pattern).Matches(resource) && len(pattern) > len(matched) {
+			matched = pattern
+		}
+	}
+	if matched != "" {
+		return config.ErrorHandlers[matched]
+	}
+	return config.ErrorHandler
+}
+
+// settlementHandler returns the settlement handler for the current request's route: the one
+// set for the longest matching pattern, otherwise the middleware's
+func settlementHandler(c *gin.Context, config *MiddlewareConfig) func(*gin.Context, *x402.SettleResponse) {
+	resource := resourceOf(c)
+	var matched string
+	for pattern := range config.SettlementHandlers {
+		if xtended402.ParseRoutePattern(pattern).Matches(resource) && len(pattern) > len(matched) {
+			matched = pattern
+		}
+	}
+	if matched != "" {
+		return config.SettlementHandlers[matched]
+	}
+	return config.SettlementHandler
+}
@@ -0,0 +1,104 @@
+package gin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	x402http "github.com/coinbase/x402/go/http"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Route Providers
+// ============================================================================
+
+// WithRouteProvider loads paid routes from provider at startup, besides the compiled-in
+// RoutesConfig, and reloads them every refresh (0 loads them once; call
+// Middleware.ReloadRoutes to reload on demand, e.g. when an admin panel saves a change).
+// Requests are served from the loaded copy, never waiting on the provider.
+//
+// A provided route replaces a compiled-in one with the same pattern. Routes dropped by the
+// provider stop being paid, or revert to their compiled-in configuration.
+func WithRouteProvider(provider xtended402.RouteProvider, refresh time.Duration) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.RouteProvider = provider
+		c.RouteRefresh = refresh
+	}
+}
+
+// ReloadRoutes loads the route provider's routes now. Routes that fail validation are skipped,
+// and reported in a *ValidationError once the valid ones are in effect. If the provider fails,
+// the routes loaded before stay in effect.
+func (m *Middleware) ReloadRoutes(ctx context.Context) error {
+	if m.config.RouteProvider == nil {
+		return fmt.Errorf("no route provider configured")
+	}
+	provided, err := m.config.RouteProvider.PaidRoutes(ctx)
+	if err != nil {
+		return fmt.Errorf("route provider: %w", err)
+	}
+
+	patterns := make([]string, 0, len(provided))
+	for pattern := range provided {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	var problems []string
+	valid := make(x402http.RoutesConfig, len(provided))
+	for _, pattern := range patterns {
+		if routeProblems := m.routeProblems(pattern, provided[pattern]); len(routeProblems) > 0 {
+			problems = append(problems, routeProblems...)
+			continue
+		}
+		valid[pattern] = provided[pattern]
+	}
+
+	m.routesMu.Lock()
+	m.replaceRoutes(func(routes x402http.RoutesConfig) {
+		for pattern := range m.provided {
+			if _, ok := valid[pattern]; ok {
+				continue
+			}
+			if route, ok := m.config.Routes[pattern]; ok {
+				routes[pattern] = route
+			} else {
+				delete(routes, pattern)
+			}
+		}
+		for pattern, route := range valid {
+			routes[pattern] = route
+		}
+	})
+	m.provided = valid
+	m.routesMu.Unlock()
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// loadProvidedRoutes loads the route provider's routes, logging failures
+func (m *Middleware) loadProvidedRoutes() {
+	ctx, cancel := xtended402.ContextWithTimeout(context.Background(), m.config.clock(), m.config.Timeout)
+	defer cancel()
+	if err := m.ReloadRoutes(ctx); err != nil {
+		fmt.Printf("Warning: loading paid routes: %v\n", err)
+	}
+}
+
+// refreshRoutes reloads the route provider's routes every RouteRefresh until the middleware
+// shuts down
+func (m *Middleware) refreshRoutes() {
+	for {
+		select {
+		case <-m.config.clock().After(m.config.RouteRefresh):
+		case <-m.stop:
+			return
+		}
+		m.loadProvidedRoutes()
+	}
+}
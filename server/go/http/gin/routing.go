@@ -0,0 +1,169 @@
+package gin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Extended Route Patterns
+// ============================================================================
+
+// routeAliasPrefix starts the literal paths x402 is given for extended route patterns
+const routeAliasPrefix = "/.xtended402/routes/"
+
+// routePathKey holds the path x402 processes the current request as
+const routePathKey = "xtended402RoutePath"
+
+// routeAlias stands in for a route pattern x402 can't match itself, one with a regular
+// expression or exclusions. x402 is given the route under a literal alias path, and requests
+// the pattern matches are processed as that path.
+type routeAlias struct {
+	pattern xtended402.RoutePattern
+	path    string
+}
+
+// aliasRoutes returns the routes as x402 should see them, with extended patterns replaced by
+// their aliases, and the aliases, sorted by pattern
+func aliasRoutes(routes x402http.RoutesConfig) (x402http.RoutesConfig, []routeAlias) {
+	var extended []string
+	for pattern := range routes {
+		if xtended402.ParseRoutePattern(pattern).Extended() {
+			extended = append(extended, pattern)
+		}
+	}
+	if len(extended) == 0 {
+		return routes, nil
+	}
+	sort.Strings(extended)
+
+	aliased := make(x402http.RoutesConfig, len(routes))
+	for pattern, route := range routes {
+		aliased[pattern] = route
+	}
+	aliases := make([]routeAlias, 0, len(extended))
+	for i, pattern := range extended {
+		alias := routeAlias{pattern: xtended402.ParseRoutePattern(pattern), path: fmt.Sprintf("%s%d", routeAliasPrefix, i)}
+		key := alias.path
+		if method := alias.pattern.Method(); method != "*" {
+			key = method + " " + alias.path
+		}
+
+		delete(aliased, pattern)
+		aliased[key] = withRequestPath(routes[pattern])
+		aliases = append(aliases, alias)
+	}
+	return aliased, aliases
+}
+
+// withRequestPath copies route so its pricing and recipient functions see the request's path
+// rather than its alias
+func withRequestPath(route x402http.RouteConfig) x402http.RouteConfig {
+	accepts := make(x402http.PaymentOptions, len(route.Accepts))
+	for i, option := range route.Accepts {
+		if price, ok := option.Price.(x402http.DynamicPriceFunc); ok {
+			option.Price = x402http.DynamicPriceFunc(func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (x402.Price, error) {
+				return price(ctx, requestPath(reqCtx))
+			})
+		}
+		if payTo, ok := option.PayTo.(x402http.DynamicPayToFunc); ok {
+			option.PayTo = x402http.DynamicPayToFunc(func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (string, error) {
+				return payTo(ctx, requestPath(reqCtx))
+			})
+		}
+		accepts[i] = option
+	}
+	route.Accepts = accepts
+	return route
+}
+
+func requestPath(reqCtx x402http.HTTPRequestContext) x402http.HTTPRequestContext {
+	if reqCtx.Adapter != nil {
+		reqCtx.Path = reqCtx.Adapter.GetPath()
+	}
+	return reqCtx
+}
+
+// routeResolver finds the route pattern a request matched, when that matters: for extended
+// patterns, processed by x402 as their aliases, and patterns with parameters, which are added
+// to the request context. When patterns overlap, the one with the longest path wins, as
+// elsewhere.
+type routeResolver struct {
+	routes []resolvedRoute
+}
+
+type resolvedRoute struct {
+	pattern xtended402.RoutePattern
+	alias   string
+}
+
+func newRouteResolver(routes x402http.RoutesConfig) *routeResolver {
+	_, aliases := aliasRoutes(routes)
+	needed := len(aliases) > 0
+
+	r := &routeResolver{}
+	for _, alias := range aliases {
+		r.routes = append(r.routes, resolvedRoute{pattern: alias.pattern, alias: alias.path})
+	}
+	for pattern := range routes {
+		parsed := xtended402.ParseRoutePattern(pattern)
+		if parsed.Extended() {
+			continue
+		}
+		r.routes = append(r.routes, resolvedRoute{pattern: parsed})
+		needed = needed || len(parsed.ParamNames()) > 0
+	}
+	if !needed {
+		// Requests are processed as they are
+		r.routes = nil
+		return r
+	}
+
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		a, b := r.routes[i].pattern, r.routes[j].pattern
+		switch {
+		case len(a.Path()) != len(b.Path()):
+			return len(a.Path()) > len(b.Path())
+		case a.Path() != b.Path():
+			return a.Path() < b.Path()
+		}
+		// A pattern for the method wins over one for any method
+		return a.Method() != "*" && b.Method() == "*"
+	})
+	return r
+}
+
+// resolve returns the path x402 should process the request, priced as method, as. It adds the
+// matched pattern's parameters to the request context and records an alias for routePath.
+func (r *routeResolver) resolve(c *gin.Context, method string) string {
+	path := c.Request.URL.Path
+	for _, route := range r.routes {
+		if !route.pattern.MatchRequest(method, path) {
+			continue
+		}
+
+		if params := route.pattern.Params(path); len(params) > 0 {
+			c.Request = c.Request.WithContext(xtended402.ContextWithRouteParams(c.Request.Context(), params))
+		}
+		if route.alias == "" {
+			return path
+		}
+		c.Set(routePathKey, route.alias)
+		return route.alias
+	}
+	return path
+}
+
+// routePath returns the path x402 processes the current request as
+func routePath(c *gin.Context) string {
+	if path := c.GetString(routePathKey); path != "" {
+		return path
+	}
+	return c.Request.URL.Path
+}
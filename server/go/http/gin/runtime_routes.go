@@ -0,0 +1,93 @@
+package gin
+
+import (
+	x402http "github.com/coinbase/x402/go/http"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Runtime Routes
+// ============================================================================
+
+// routeTable is the paid routes in effect and the x402 server matching them. Changing routes
+// replaces the whole table, so a request sees one consistent set of routes.
+type routeTable struct {
+	routes   x402http.RoutesConfig
+	server   *x402http.HTTPServer
+	matcher  *xtended402.RouteMatcher
+	resolver *routeResolver
+}
+
+func newRouteTable(routes x402http.RoutesConfig, server *x402http.HTTPServer) *routeTable {
+	patterns := make([]string, 0, len(routes))
+	for pattern := range routes {
+		patterns = append(patterns, pattern)
+	}
+	return &routeTable{
+		routes:   routes,
+		server:   server,
+		matcher:  xtended402.NewRouteMatcher(patterns...),
+		resolver: newRouteResolver(routes),
+	}
+}
+
+// Routes returns a copy of the paid routes in effect
+func (m *Middleware) Routes() x402http.RoutesConfig {
+	current := m.config.routes()
+	routes := make(x402http.RoutesConfig, len(current))
+	for pattern, route := range current {
+		routes[pattern] = route
+	}
+	return routes
+}
+
+// AddPaidRoute starts charging for requests matching pattern while the middleware is serving,
+// e.g. for a product created at runtime. A route already under pattern is replaced. The route
+// is checked as Validate checks routes at startup, and isn't added if it has problems; the
+// error is then a *ValidationError. Safe for concurrent use.
+//
+// Requests already being processed finish under the routes they started with.
+func (m *Middleware) AddPaidRoute(pattern string, route x402http.RouteConfig) error {
+	if problems := m.routeProblems(pattern, route); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	m.routesMu.Lock()
+	defer m.routesMu.Unlock()
+	m.replaceRoutes(func(routes x402http.RoutesConfig) {
+		routes[pattern] = route
+	})
+	return nil
+}
+
+// RemovePaidRoute stops charging for the route under pattern, reporting whether there was
+// one. Requests it matched pass through unpaid, unless another route matches them. Safe for
+// concurrent use.
+func (m *Middleware) RemovePaidRoute(pattern string) bool {
+	m.routesMu.Lock()
+	defer m.routesMu.Unlock()
+	if _, ok := m.config.routes()[pattern]; !ok {
+		return false
+	}
+	m.replaceRoutes(func(routes x402http.RoutesConfig) {
+		delete(routes, pattern)
+	})
+	return true
+}
+
+// replaceRoutes swaps in a table of the current routes changed by change. Callers hold
+// routesMu.
+func (m *Middleware) replaceRoutes(change func(x402http.RoutesConfig)) {
+	current := m.config.table.Load()
+	routes := make(x402http.RoutesConfig, len(current.routes)+1)
+	for pattern, route := range current.routes {
+		routes[pattern] = route
+	}
+	change(routes)
+
+	// The new x402 server shares the resource server, so registered schemes, extensions, and
+	// synced facilitator capabilities carry over
+	x402Routes, _ := aliasRoutes(routes)
+	server := x402http.Wrappedx402HTTPResourceServer(x402Routes, current.server.X402ResourceServer)
+	m.config.table.Store(newRouteTable(routes, server))
+}
@@ -0,0 +1,100 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Session Tokens
+// ============================================================================
+
+// WithSessions issues a session token from issuer after each successful settlement, in the
+// X-Payment-Session header and a cookie. Requests presenting a valid token skip the 402 flow;
+// their handlers read the claims with xtended402.GetSession instead of GetPaymentData. Tokens
+// carry the scopes declared by the paid route (see xtended402.ScopedRoute), and routes
+// declaring scopes only accept tokens with all of them.
+func WithSessions(issuer *xtended402.SessionIssuer) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Sessions = issuer
+	}
+}
+
+// sessionValid reports whether the request carries a valid session token for its route, and
+// stores the claims for the handler if so. Invalid and revoked tokens, tokens missing a scope
+// the route declares, and tokens scoped by access scopes on routes declaring none fall through
+// to the 402 flow.
+func sessionValid(c *gin.Context, config *MiddlewareConfig) bool {
+	if config.Sessions == nil {
+		return false
+	}
+	if config.Sessions.Token(c.Request) == "" {
+		return false
+	}
+	claims, err := config.Sessions.VerifyRequest(c.Request, resourceOf(c))
+	if errors.Is(err, xtended402.ErrSessionExpired) {
+		offerRenewal(c, config)
+		return false
+	}
+	if err != nil || revoked(c, config, claims.Transaction) {
+		return false
+	}
+	required := xtended402.ResourceScopes(config.routes(), resourceOf(c))
+	if !xtended402.HasScopes(claims.Scopes, required...) {
+		return false
+	}
+	// A token scoped by what it bought only unlocks routes selling those scopes
+	if len(claims.Scope) == 0 && len(required) == 0 && !config.Sessions.Unscoped() {
+		return false
+	}
+	c.Set(xtended402.SessionKey, claims)
+	return true
+}
+
+// offerRenewal marks a request presenting a just-expired token as a renewal, so the route's
+// SessionRenewalPrice applies and its 402 carries the SessionRenewal
+func offerRenewal(c *gin.Context, config *MiddlewareConfig) {
+	claims, ok := config.Sessions.Renewable(c.Request, resourceOf(c))
+	if !ok || revoked(c, config, claims.Transaction) {
+		return
+	}
+	c.Request = c.Request.WithContext(xtended402.WithRenewingSession(c.Request.Context(), claims))
+	c.Set(sessionRenewalKey, config.Sessions.Renewal(claims))
+}
+
+// sessionRenewalKey is the Gin context key where a SessionRenewal is kept until the request's
+// 402 is written
+const sessionRenewalKey = "xtended402SessionRenewal"
+
+// issueSession adds a session token for a settled payment to the response
+func issueSession(c *gin.Context, config *MiddlewareConfig, event xtended402.PaymentEvent) {
+	if config.Sessions == nil {
+		return
+	}
+	// Tokens are scoped to the route paid for, unless the issuer says otherwise
+	ctx := xtended402.WithPaidRoute(c.Request.Context(), xtended402.ResourceRoute(config.routes(), resourceOf(c)))
+	token, claims, err := config.Sessions.IssueRequest(c.Request.WithContext(ctx), event)
+	if err != nil {
+		fmt.Printf("Warning: failed to issue session for %s: %v\n", event.ID, err)
+		return
+	}
+	config.Sessions.SetToken(c.Writer, token, claims)
+}
+
+// withScopes lists the scopes bought by the event's payment in its metadata
+func withScopes(event xtended402.PaymentEvent, scopes []string) xtended402.PaymentEvent {
+	if len(scopes) == 0 {
+		return event
+	}
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.ScopesMetadataKey] = strings.Join(scopes, " ")
+	event.Metadata = metadata
+	return event
+}
@@ -0,0 +1,66 @@
+package gin_test
+
+import (
+	"net/http"
+	"testing"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	ginmw "github.com/mvpoyatt/xtended402/server/go/http/gin"
+	"github.com/mvpoyatt/xtended402/server/go/testing/e2e"
+)
+
+func pricedRoute(price string) x402http.RouteConfig {
+	return x402http.RouteConfig{
+		Accepts: x402http.PaymentOptions{{
+			Scheme:  "exact",
+			Network: e2e.DefaultNetwork,
+			PayTo:   "0x1111111111111111111111111111111111111111",
+			Price:   price,
+		}},
+	}
+}
+
+// TestSessionScopedToPaidRoute pays for the cheap route and presents its token on the
+// expensive one, which must still ask for payment
+func TestSessionScopedToPaidRoute(t *testing.T) {
+	routes := x402http.RoutesConfig{
+		"GET /cheap/*":    pricedRoute("$0.01"),
+		"GET /expensive":  pricedRoute("$100.00"),
+		"GET /reports/*":  xtended402.ScopedRoute(pricedRoute("$0.10"), "read:reports"),
+		"POST /plans/pro": xtended402.ScopedRoute(pricedRoute("$20.00"), "read:reports"),
+	}
+	issuer := xtended402.NewSessionIssuer("0123456789abcdef0123456789abcdef", xtended402.WithSessionCookie(""))
+	h := e2e.New(t, routes, func(r *gin.Engine, paid gin.HandlerFunc) {
+		ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) }
+		r.GET("/cheap/:id", paid, ok)
+		r.GET("/expensive", paid, ok)
+		r.GET("/reports/:id", paid, ok)
+		r.POST("/plans/pro", paid, ok)
+	}, e2e.WithMiddlewareOptions(ginmw.WithSettlementTiming("before"), ginmw.WithSessions(issuer)))
+
+	token := h.Pay(t, "GET", "/cheap/1", nil).Header().Get(xtended402.SessionHeader)
+	if token == "" {
+		t.Fatal("no session token issued")
+	}
+	session := map[string]string{xtended402.SessionHeader: token}
+
+	if resp := h.Do(t, "GET", "/cheap/2", nil, session); resp.Code != http.StatusOK {
+		t.Fatalf("token rejected on the route it paid for: %d", resp.Code)
+	}
+	if resp := h.Do(t, "GET", "/expensive", nil, session); resp.Code != http.StatusPaymentRequired {
+		t.Fatalf("cheap route's token unlocked the expensive route: %d", resp.Code)
+	}
+
+	// Tokens bought for scopes unlock routes declaring them, and nothing else
+	token = h.Pay(t, "POST", "/plans/pro", nil).Header().Get(xtended402.SessionHeader)
+	session = map[string]string{xtended402.SessionHeader: token}
+	if resp := h.Do(t, "GET", "/reports/1", nil, session); resp.Code != http.StatusOK {
+		t.Fatalf("scoped token rejected on a route declaring its scope: %d", resp.Code)
+	}
+	if resp := h.Do(t, "GET", "/expensive", nil, session); resp.Code != http.StatusPaymentRequired {
+		t.Fatalf("scoped token unlocked a route declaring no scopes: %d", resp.Code)
+	}
+}
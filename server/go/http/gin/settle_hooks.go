@@ -0,0 +1,70 @@
+package gin
+
+import (
+	"fmt"
+
+	x402 "github.com/coinbase/x402/go"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Named Settlement Hooks
+// ============================================================================
+
+// WithNamedBeforeSettleHook registers a before-settle hook that runs only on routes naming it
+// with xtended402.RouteWithSettleHooks, after the middleware's WithBeforeSettleHook. An error
+// stops settlement as WithBeforeSettleHook's does.
+func WithNamedBeforeSettleHook(name string, hook func(*gin.Context, *x402.VerifyResponse) error) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		if c.BeforeSettleHooks == nil {
+			c.BeforeSettleHooks = make(map[string]func(*gin.Context, *x402.VerifyResponse) error)
+		}
+		c.BeforeSettleHooks[name] = hook
+	}
+}
+
+// WithNamedAfterSettleHook registers a hook that runs after a successful settlement, before the
+// settlement handler, only on routes naming it with xtended402.RouteWithSettleHooks, e.g. to
+// meter API usage
+func WithNamedAfterSettleHook(name string, hook func(*gin.Context, *x402.SettleResponse)) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		if c.AfterSettleHooks == nil {
+			c.AfterSettleHooks = make(map[string]func(*gin.Context, *x402.SettleResponse))
+		}
+		c.AfterSettleHooks[name] = hook
+	}
+}
+
+// beforeSettle runs the middleware's before-settle hook, then the named hooks of the current
+// request's route in order, stopping at the first error. Once they pass, it makes the
+// request's reservation.
+func beforeSettle(c *gin.Context, config *MiddlewareConfig, verifyResp *x402.VerifyResponse) error {
+	if config.BeforeSettleHook != nil {
+		if err := config.BeforeSettleHook(c, verifyResp); err != nil {
+			return err
+		}
+	}
+	if len(config.BeforeSettleHooks) > 0 {
+		for _, name := range xtended402.ResourceSettleHooks(config.routes(), resourceOf(c)) {
+			if hook, ok := config.BeforeSettleHooks[name]; ok {
+				if err := hook(c, verifyResp); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+		}
+	}
+	return reserve(c, config, verifyResp)
+}
+
+// afterSettle runs the named after-settle hooks of the current request's route in order
+func afterSettle(c *gin.Context, config *MiddlewareConfig, settleResp *x402.SettleResponse) {
+	if len(config.AfterSettleHooks) == 0 {
+		return
+	}
+	for _, name := range xtended402.ResourceSettleHooks(config.routes(), resourceOf(c)) {
+		if hook, ok := config.AfterSettleHooks[name]; ok {
+			hook(c, settleResp)
+		}
+	}
+}
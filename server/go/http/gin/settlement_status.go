@@ -0,0 +1,35 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Settlement Status
+// ============================================================================
+
+// SettlementStatusHandler serves the status of the settlement named by the :tx path
+// parameter, for clients to poll until it's confirmed:
+//
+//	r.GET("/x402/settlements/:tx", ginmw.SettlementStatusHandler(statuses))
+func SettlementStatusHandler(statuses *xtended402.SettlementStatuses) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		status, err := statuses.Status(c.Request.Context(), c.Param("tx"))
+		switch {
+		case errors.Is(err, xtended402.ErrPaymentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown settlement"})
+		case err != nil:
+			fmt.Printf("Warning: failed to load settlement status: %v\n", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Settlement status unavailable"})
+		default:
+			c.JSON(http.StatusOK, status)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package gin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================================
+// Graceful Shutdown
+// ============================================================================
+
+// AsyncWorker is a background worker Shutdown waits for, such as a WebhookDispatcher,
+// EventPublisher, notify.Notifier, or receipts.Mailer
+type AsyncWorker interface {
+	Wait()
+}
+
+// WithShutdownWait registers async workers for Shutdown to wait for once in-flight payments
+// have finished, so the events those payments produced are delivered before exit
+func WithShutdownWait(workers ...AsyncWorker) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.ShutdownWaits = append(c.ShutdownWaits, workers...)
+	}
+}
+
+// WithShutdownHook registers a function for Shutdown to call last, e.g. a warehouse.Sink's Close
+func WithShutdownHook(hook func(ctx context.Context) error) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.ShutdownHooks = append(c.ShutdownHooks, hook)
+	}
+}
+
+// drain tracks in-flight payments and stops new ones once shutdown starts
+type drain struct {
+	mu       sync.Mutex
+	draining bool
+	inflight int
+	idle     chan struct{}
+}
+
+// enter admits a payment, or reports false once draining
+func (d *drain) enter() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining {
+		return false
+	}
+	d.inflight++
+	return true
+}
+
+func (d *drain) leave() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.inflight--
+	if d.draining && d.inflight == 0 {
+		close(d.idle)
+	}
+}
+
+// start stops admitting payments and returns a channel closed once none are in flight
+func (d *drain) start() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.draining {
+		d.draining = true
+		d.idle = make(chan struct{})
+		if d.inflight == 0 {
+			close(d.idle)
+		}
+	}
+	return d.idle
+}
+
+func (d *drain) pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.inflight
+}
+
+// rejectDraining refuses a new payment while the middleware shuts down. Clients should retry,
+// reaching another replica.
+func rejectDraining(c *gin.Context) {
+	c.Header("Retry-After", "5")
+	c.Header("Connection", "close")
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, errorBody(c, gin.H{
+		"error": "Server is shutting down",
+	}))
+}
+
+// Shutdown stops the middleware taking new payments and waits for in-flight ones to verify,
+// settle, and finish their handlers. It then stops background capability syncing and route
+// reloading, waits for the WithShutdownWait workers, and runs the WithShutdownHook hooks.
+//
+// Once Shutdown is called, requests that would pay get a 503 with Retry-After. Session tokens,
+// API keys, and unpaid routes keep working. Call Shutdown when SIGTERM arrives, before
+// shutting down the HTTP server:
+//
+//	<-sigterm
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	if err := m.Shutdown(ctx); err != nil {
+//		log.Printf("payment shutdown: %v", err)
+//	}
+//	srv.Shutdown(ctx)
+//
+// If ctx ends first, Shutdown returns an error counting the payments still in flight. Their
+// settlements aren't interrupted; they complete if the process lives long enough.
+func (m *Middleware) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	select {
+	case <-m.drain.start():
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("%d payments still in flight: %w", m.drain.pending(), ctx.Err()))
+	}
+
+	m.stopOnce.Do(func() { close(m.stop) })
+
+	for _, worker := range m.config.ShutdownWaits {
+		if err := waitFor(ctx, worker); err != nil {
+			errs = append(errs, fmt.Errorf("async worker did not finish: %w", err))
+			break
+		}
+	}
+	for _, hook := range m.config.ShutdownHooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// waitFor waits for worker until ctx ends
+func waitFor(ctx context.Context, worker AsyncWorker) error {
+	done := make(chan struct{})
+	go func() {
+		worker.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
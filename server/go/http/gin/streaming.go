@@ -0,0 +1,218 @@
+package gin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Pay-As-You-Stream
+// ============================================================================
+
+// handleStreamingPayment serves a route with stream terms: it verifies the pre-authorized
+// increments, settles the first before the handler runs, and settles the rest as the handler
+// writes. Once an increment can't be paid, writes fail with xtended402.ErrStreamPaymentFailed
+// and the request context is canceled, so the handler stops streaming.
+func handleStreamingPayment(c *gin.Context, server *x402http.HTTPServer, result x402http.HTTPProcessResult, config *MiddlewareConfig, terms *xtended402.StreamTerms) {
+	var increments []x402types.PaymentPayload
+	if header := c.GetHeader(xtended402.PaymentIncrementsHeader); header != "" {
+		decoded, err := xtended402.DecodePaymentIncrements(header)
+		if err != nil {
+			rejectStream(c, config, "Invalid payment increments", rejectionError(StageVerify, http.StatusBadRequest, result.PaymentRequirements, err))
+			return
+		}
+		increments = decoded
+	}
+	if len(increments)+1 > terms.MaxIncrements {
+		rejectStream(c, config, "Too many payment increments", rejectionError(StageVerify, http.StatusBadRequest, result.PaymentRequirements,
+			fmt.Errorf("%d increments authorized, the route allows %d", len(increments)+1, terms.MaxIncrements)))
+		return
+	}
+
+	ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.verifyTimeout())
+	for i, payload := range increments {
+		verifyResp, err := server.VerifyPayment(ctx, payload, *result.PaymentRequirements)
+		if err == nil && !verifyResp.IsValid {
+			err = fmt.Errorf("%s", verifyResp.InvalidReason)
+		}
+		if err != nil {
+			cancel()
+			paymentErr := rejectionError(StageVerify, http.StatusPaymentRequired, result.PaymentRequirements, fmt.Errorf("increment %d: %w", i+2, err))
+			if verifyResp != nil {
+				paymentErr.Code = verifyResp.InvalidReason
+			}
+			rejectStream(c, config, "Invalid payment increment", paymentErr)
+			return
+		}
+	}
+	cancel()
+
+	payment := &xtended402.StreamPayment{Authorized: len(increments) + 1}
+	c.Set(xtended402.StreamPaymentKey, payment)
+	if !settleBeforeHandler(c, server, result, config, bufferedBody(c, config)) {
+		releaseReservation(c)
+		return
+	}
+	keepReservation(c)
+	payment.Settled = 1
+	payment.Transactions = append(payment.Transactions, xtended402.GetPaymentData(c).SettleResponse.Transaction)
+
+	streamCtx, cancelStream := context.WithCancelCause(c.Request.Context())
+	defer cancelStream(nil)
+	c.Request = c.Request.WithContext(streamCtx)
+
+	writer := &streamWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		server:         server,
+		config:         config,
+		result:         result,
+		terms:          terms,
+		increments:     increments,
+		payment:        payment,
+		cancel:         cancelStream,
+		paidBytes:      terms.EveryBytes,
+		paidUntil:      config.clock().Now().Add(terms.Every()),
+	}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+}
+
+func rejectStream(c *gin.Context, config *MiddlewareConfig, message string, err *PaymentError) {
+	if handler := errorHandler(c, config); handler != nil {
+		err.Err = fmt.Errorf("%s: %w", message, err.Err)
+		handler(c, err)
+	} else {
+		c.JSON(err.Status, errorBody(c, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		}))
+	}
+	c.Abort()
+}
+
+// streamWriter settles a stream's increments as the handler writes past what's paid for
+type streamWriter struct {
+	gin.ResponseWriter
+	c          *gin.Context
+	server     *x402http.HTTPServer
+	config     *MiddlewareConfig
+	result     x402http.HTTPProcessResult
+	terms      *xtended402.StreamTerms
+	increments []x402types.PaymentPayload
+	payment    *xtended402.StreamPayment
+	cancel     context.CancelCauseFunc
+
+	written   int64
+	paidBytes int64
+	paidUntil time.Time
+	err       error
+}
+
+func (w *streamWriter) Write(data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		if err := w.pay(); err != nil {
+			return written, err
+		}
+		chunk := data
+		if w.terms.EveryBytes > 0 && int64(len(chunk)) > w.paidBytes-w.written {
+			chunk = chunk[:w.paidBytes-w.written]
+		}
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		w.written += int64(n)
+		data = data[n:]
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (w *streamWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// pay settles increments until the stream is paid for up to its current length and time.
+// After a failure the stream stays unpaid.
+func (w *streamWriter) pay() error {
+	if w.err != nil {
+		return w.err
+	}
+	for (w.terms.EveryBytes > 0 && w.written >= w.paidBytes) ||
+		(w.terms.EverySeconds > 0 && !w.config.clock().Now().Before(w.paidUntil)) {
+		if err := w.settleNext(); err != nil {
+			w.err = err
+			w.cancel(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// settleNext settles the next pre-authorized increment
+func (w *streamWriter) settleNext() error {
+	payment := w.payment
+	if payment.Settled >= payment.Authorized {
+		return fmt.Errorf("%w: all %d pre-authorized increments used", xtended402.ErrStreamPaymentFailed, payment.Authorized)
+	}
+	result := w.result
+	result.PaymentPayload = &w.increments[payment.Settled-1]
+
+	ctx, cancel := xtended402.ContextWithTimeout(w.c.Request.Context(), w.config.clock(), w.config.settleTimeout())
+	defer cancel()
+	settleStart := w.config.clock().Now()
+	settleResult := w.server.ProcessSettlement(ctx, *result.PaymentPayload, *result.PaymentRequirements)
+	logSettlement(w.c, result, settleResult, w.config.clock().Now().Sub(settleStart))
+	if !settleResult.Success {
+		errorReason := settleResult.ErrorReason
+		if errorReason == "" {
+			errorReason = "Settlement failed"
+		}
+		emitSettlementFailed(w.c, w.config, result, errorReason, "")
+		return fmt.Errorf("%w: increment %d: %s", xtended402.ErrStreamPaymentFailed, payment.Settled+1, errorReason)
+	}
+
+	if len(w.config.EventHandlers) > 0 {
+		event := newEvent(w.c, w.config, xtended402.EventPaymentSettled, result)
+		event.Transaction = settleResult.Transaction
+		if settleResult.Payer != "" {
+			event.Payer = settleResult.Payer
+		}
+		if settleResult.Network != "" {
+			event.Network = settleResult.Network
+		}
+		emit(w.c, w.config, event)
+	}
+
+	payment.Settled++
+	payment.Transactions = append(payment.Transactions, settleResult.Transaction)
+	w.paidBytes += w.terms.EveryBytes
+	w.paidUntil = w.paidUntil.Add(w.terms.Every())
+	return nil
+}
+
+// withStreamIncrement adds the stream increment being paid for to the event's metadata
+func withStreamIncrement(c *gin.Context, event xtended402.PaymentEvent) xtended402.PaymentEvent {
+	payment := xtended402.GetStreamPayment(c)
+	if payment == nil {
+		return event
+	}
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.StreamIncrementMetadataKey] = strconv.Itoa(payment.Settled + 1)
+	event.Metadata = metadata
+	return event
+}
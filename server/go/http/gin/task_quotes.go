@@ -0,0 +1,138 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Agent-to-Agent Quotes
+// ============================================================================
+
+// WithTaskQuotes lets agents pay for accepted quotes. A request with the xtended402.QuoteHeader
+// header is priced at its quote (see xtended402.QuotePrice), and handlers find the quote with
+// xtended402.QuoteFromContext. Quotes that aren't accepted, are expired or already paid, or
+// are for another route get a 409. Settling marks the quote paid.
+func WithTaskQuotes(quotes *xtended402.TaskQuotes) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.TaskQuotes = quotes
+	}
+}
+
+// TaskQuoteHandler quotes the xtended402.TaskRequest in the request body, responding with the
+// offered xtended402.TaskQuote:
+//
+//	r.POST("/quotes", ginmw.TaskQuoteHandler(quotes))
+//	r.GET("/quotes/:id", ginmw.TaskQuoteStatusHandler(quotes))
+//	r.POST("/quotes/:id/accept", ginmw.TaskQuoteAcceptHandler(quotes))
+//	r.POST("/quotes/:id/decline", ginmw.TaskQuoteDeclineHandler(quotes))
+func TaskQuoteHandler(quotes *xtended402.TaskQuotes) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		var request xtended402.TaskRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task request", "details": err.Error()})
+			return
+		}
+		quote, err := quotes.Quote(c.Request.Context(), request)
+		switch {
+		case errors.Is(err, xtended402.ErrQuoteInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task request", "details": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Task can't be quoted", "details": err.Error()})
+		default:
+			c.JSON(http.StatusCreated, quote)
+		}
+	}
+}
+
+// TaskQuoteStatusHandler serves the quote named by the :id path parameter, for agents to poll
+func TaskQuoteStatusHandler(quotes *xtended402.TaskQuotes) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		quote, err := quotes.Get(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown quote"})
+			return
+		}
+		c.JSON(http.StatusOK, quote)
+	}
+}
+
+// TaskQuoteAcceptHandler accepts the quote named by the :id path parameter
+func TaskQuoteAcceptHandler(quotes *xtended402.TaskQuotes) gin.HandlerFunc {
+	return quoteDecisionHandler(quotes.Accept)
+}
+
+// TaskQuoteDeclineHandler declines the quote named by the :id path parameter
+func TaskQuoteDeclineHandler(quotes *xtended402.TaskQuotes) gin.HandlerFunc {
+	return quoteDecisionHandler(quotes.Decline)
+}
+
+func quoteDecisionHandler(decide func(id string) (*xtended402.TaskQuote, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		quote, err := decide(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Quote can't be changed", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, quote)
+	}
+}
+
+// taskQuote adds the quote the request pays for to its context. When the quote can't be paid,
+// the error response is written and ok is false.
+func taskQuote(c *gin.Context, config *MiddlewareConfig) (ok bool) {
+	id := c.GetHeader(xtended402.QuoteHeader)
+	if config.TaskQuotes == nil || id == "" {
+		return true
+	}
+
+	quote, err := config.TaskQuotes.Payable(id, resourceOf(c))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, errorBody(c, gin.H{
+			"error":   "Quote can't be paid",
+			"details": err.Error(),
+		}))
+		return false
+	}
+	c.Request = c.Request.WithContext(xtended402.ContextWithQuote(c.Request.Context(), quote))
+	return true
+}
+
+// settleQuote marks the quote a settled payment paid for as paid
+func settleQuote(c *gin.Context, config *MiddlewareConfig, event xtended402.PaymentEvent) {
+	if config.TaskQuotes == nil {
+		return
+	}
+	quote := xtended402.QuoteFromContext(c.Request.Context())
+	if quote == nil {
+		return
+	}
+	if err := config.TaskQuotes.MarkPaid(quote.ID, event.Transaction); err != nil {
+		fmt.Printf("Warning: failed to mark quote %s paid: %v\n", quote.ID, err)
+	}
+}
+
+// withQuoteID adds the ID of the quote the request pays for to the event's metadata
+func withQuoteID(c *gin.Context, event xtended402.PaymentEvent) xtended402.PaymentEvent {
+	quote := xtended402.QuoteFromContext(c.Request.Context())
+	if quote == nil {
+		return event
+	}
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.QuoteIDMetadataKey] = quote.ID
+	event.Metadata = metadata
+	return event
+}
@@ -0,0 +1,29 @@
+package gin
+
+import (
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Taxes
+// ============================================================================
+
+// withTax adds the tax worked out while pricing the request to the event's metadata
+func withTax(event xtended402.PaymentEvent, tax *xtended402.TaxBreakdown) xtended402.PaymentEvent {
+	if tax == nil {
+		return event
+	}
+	metadata := make(map[string]string, len(event.Metadata)+5)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.TaxMetadataKey] = tax.Tax
+	metadata[xtended402.TaxNetMetadataKey] = tax.Net
+	metadata[xtended402.TaxRateMetadataKey] = tax.Rate
+	metadata[xtended402.TaxJurisdictionMetadataKey] = tax.Jurisdiction
+	if tax.Name != "" {
+		metadata[xtended402.TaxNameMetadataKey] = tax.Name
+	}
+	event.Metadata = metadata
+	return event
+}
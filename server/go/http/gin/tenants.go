@@ -0,0 +1,61 @@
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Tenants
+// ============================================================================
+
+// WithTenants resolves the tenant behind each paid request and adds it to the request context,
+// where xtended402.TenantPayTo, TenantPrice, and TenantFacilitator find it. Requests naming
+// no tenant or an unknown one get a 404. Events carry the tenant ID in
+// event.Metadata["tenant"].
+func WithTenants(tenants *xtended402.Tenants) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Tenants = tenants
+	}
+}
+
+// resolveTenant adds the request's tenant to its context. When it can't, the error response
+// is written and ok is false.
+func resolveTenant(c *gin.Context, config *MiddlewareConfig) (ok bool) {
+	if config.Tenants == nil {
+		return true
+	}
+
+	tenant, err := config.Tenants.Resolve(c.Request)
+	if err != nil {
+		if errors.Is(err, xtended402.ErrTenantNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, errorBody(c, gin.H{"error": "Unknown tenant"}))
+		} else {
+			fmt.Printf("Warning: failed to resolve tenant: %v\n", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, errorBody(c, gin.H{"error": "Tenant lookup failed"}))
+		}
+		return false
+	}
+
+	c.Request = c.Request.WithContext(xtended402.ContextWithTenant(c.Request.Context(), tenant))
+	return true
+}
+
+// withTenant names the request's tenant in the event's metadata
+func withTenant(c *gin.Context, event xtended402.PaymentEvent) xtended402.PaymentEvent {
+	tenant := xtended402.TenantFromContext(c.Request.Context())
+	if tenant == nil {
+		return event
+	}
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.TenantMetadataKey] = tenant.ID
+	event.Metadata = metadata
+	return event
+}
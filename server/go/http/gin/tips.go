@@ -0,0 +1,68 @@
+package gin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Tips
+// ============================================================================
+
+// WithTips accepts payments of more than the price. A client tips by signing a larger amount,
+// and may name that amount in the requirements it accepted; the payment must still be for the
+// route's scheme, network, asset, and recipient. Handlers read the tip with PaymentData.Tip,
+// and payment events carry it as event.Metadata["tip"].
+func WithTips() MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.Tips = true
+	}
+}
+
+// matchTip points a payment that accepted a tipped amount at the requirements it tips on, so
+// x402 can match it. It reports whether the payment was changed and should be processed again.
+func matchTip(c *gin.Context, server *x402http.HTTPServer, config *MiddlewareConfig, response *x402http.HTTPResponseInstructions) bool {
+	header := c.GetHeader(xtended402.PaymentHeader)
+	if header == "" {
+		return false
+	}
+	payload, err := xtended402.DecodePaymentHeader(header)
+	if err != nil {
+		return false
+	}
+	requirements, err := challengeRequirements(c, server, config, response)
+	if err != nil {
+		return false
+	}
+	tipped := xtended402.TippedRequirements(payload, requirements)
+	if tipped == nil {
+		return false
+	}
+
+	payload.Accepted = *tipped
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false
+	}
+	c.Request.Header.Set(xtended402.PaymentHeader, base64.StdEncoding.EncodeToString(data))
+	return true
+}
+
+// withTip adds how much a payment paid above the price to the event's metadata
+func withTip(event xtended402.PaymentEvent, result x402http.HTTPProcessResult) xtended402.PaymentEvent {
+	tip := xtended402.PaymentTip(result.PaymentPayload, result.PaymentRequirements)
+	if tip == "" {
+		return event
+	}
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[xtended402.TipMetadataKey] = tip
+	event.Metadata = metadata
+	return event
+}
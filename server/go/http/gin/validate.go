@@ -0,0 +1,308 @@
+package gin
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/ethereum/go-ethereum/common"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Startup Validation
+// ============================================================================
+
+// ValidationError lists every problem Validate found in the payment configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid payment configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// WithValidateOnStart sets whether NewMiddleware validates the routes before starting
+// (default true)
+func WithValidateOnStart(validate bool) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.ValidateOnStart = validate
+	}
+}
+
+var (
+	routeMethods = map[string]bool{"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true, "*": true}
+
+	// base58Address matches a Solana public key
+	base58Address = regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{32,44}$`)
+)
+
+// Validate checks every route for a mistake that would otherwise surface on the first paid
+// request: malformed patterns, missing schemes or networks, pay-to addresses in the wrong
+// format for their network, schemes not registered on their network, unparseable prices, and,
+// once facilitator capabilities have synced, payment options no facilitator supports. It also
+// checks the paywall theme's CSS values and that payment submissions have a submit URL.
+// Dynamic prices and pay-to addresses are resolved per request and skipped.
+//
+// NewMiddleware runs Validate and fails on any problem, unless WithValidateOnStart(false). Call
+// it again after a background sync to check facilitator support. Facilitator support is only
+// known for facilitators added with WithFacilitatorClient.
+//
+// The error is a *ValidationError listing every problem.
+func (m *Middleware) Validate() error {
+	var problems []string
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	routes := m.config.routes()
+	patterns := make([]string, 0, len(routes))
+	for pattern := range routes {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		problems = append(problems, m.routeProblems(pattern, routes[pattern])...)
+	}
+	for _, pattern := range m.config.Exemptions {
+		if problem := checkRoutePattern(pattern); problem != "" {
+			problemf("exemption %q: %s", pattern, problem)
+		}
+	}
+
+	if m.config.PaymentSubmissions != nil && m.config.PaymentSubmitURL == "" {
+		problemf("payment submissions: no submit URL to offer in 402 responses")
+	}
+
+	if theme := m.config.PaywallTheme; theme != nil {
+		for _, field := range []struct{ name, value string }{
+			{"PrimaryColor", theme.PrimaryColor},
+			{"BackgroundColor", theme.BackgroundColor},
+			{"SurfaceColor", theme.SurfaceColor},
+			{"TextColor", theme.TextColor},
+			{"FontFamily", theme.FontFamily},
+		} {
+			if unsafeCSS(field.value) {
+				problemf("paywall theme: %s %q contains ; { } < > or \\", field.name, field.value)
+			}
+		}
+	}
+
+	checkFormat := func(where string, format ChallengeFormat) {
+		switch format {
+		case "", ChallengeAuto, ChallengeJSON, ChallengeHTML:
+		default:
+			problemf("%s: unknown challenge format %q (want auto, json, or html)", where, format)
+		}
+	}
+	checkFormat("challenge format", m.config.ChallengeFormat)
+	formatPatterns := make([]string, 0, len(m.config.ChallengeFormats))
+	for pattern := range m.config.ChallengeFormats {
+		formatPatterns = append(formatPatterns, pattern)
+	}
+	sort.Strings(formatPatterns)
+	for _, pattern := range formatPatterns {
+		checkFormat(fmt.Sprintf("challenge format for %q", pattern), m.config.ChallengeFormats[pattern])
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// routeProblems checks a route's pattern and payment options
+func (m *Middleware) routeProblems(pattern string, route x402http.RouteConfig) []string {
+	var problems []string
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	where := fmt.Sprintf("route %q", pattern)
+	if problem := checkRoutePattern(pattern); problem != "" {
+		problemf("%s: %s", where, problem)
+	}
+
+	if len(route.Accepts) == 0 {
+		problemf("%s: no payment options in Accepts", where)
+	}
+	if timing, ok := route.Extensions[xtended402.SettlementTimingExtensionKey]; ok && timing != "before" && timing != "after" {
+		problemf("%s: settlement timing %v is not \"before\" or \"after\"", where, timing)
+	}
+	if _, ok := route.Extensions[xtended402.StreamingExtensionKey]; ok {
+		terms := xtended402.RouteStreamTerms(route)
+		switch {
+		case terms == nil:
+			problemf("%s: stream terms are not xtended402.StreamTerms", where)
+		case terms.EverySeconds <= 0 && terms.EveryBytes <= 0:
+			problemf("%s: stream terms set neither EverySeconds nor EveryBytes", where)
+		case terms.MaxIncrements < 1:
+			problemf("%s: stream terms allow no increments", where)
+		}
+	}
+	for _, name := range xtended402.RouteSettleHooks(route) {
+		_, before := m.config.BeforeSettleHooks[name]
+		_, after := m.config.AfterSettleHooks[name]
+		if !before && !after {
+			problemf("%s: settlement hook %q isn't registered", where, name)
+		}
+	}
+	for i, option := range route.Accepts {
+		at := fmt.Sprintf("%s option %d", where, i)
+		if option.Scheme == "" {
+			problemf("%s: Scheme is required", at)
+		}
+		if option.Network == "" {
+			problemf("%s: Network is required", at)
+			continue
+		}
+
+		payTo, staticPayTo := option.PayTo.(string)
+		if staticPayTo {
+			if problem := checkPayTo(option.Network, payTo); problem != "" {
+				problemf("%s: %s", at, problem)
+			}
+		} else if _, ok := option.PayTo.(x402http.DynamicPayToFunc); !ok {
+			problemf("%s: PayTo must be a string or x402http.DynamicPayToFunc, got %T", at, option.PayTo)
+		}
+
+		if option.Scheme == "" {
+			continue
+		}
+		if problem := m.checkPrice(option, payTo); problem != "" {
+			problemf("%s: %s", at, problem)
+		}
+		if m.capabilities != nil && m.InitStatus().Ready() && !m.capabilities.supports(option.Scheme, option.Network) {
+			problemf("%s: no facilitator supports the %s scheme on %s (x402 v2)", at, option.Scheme, option.Network)
+		}
+	}
+	return problems
+}
+
+// checkRoutePattern checks a "METHOD /path" or "/path" route pattern, with any exclusions
+func checkRoutePattern(pattern string) string {
+	if err := xtended402.CheckRoutePattern(pattern); err != nil {
+		return err.Error()
+	}
+
+	parsed := xtended402.ParseRoutePattern(pattern)
+	path := parsed.Path()
+	switch {
+	case !routeMethods[parsed.Method()]:
+		return fmt.Sprintf("unknown HTTP method %q", parsed.Method())
+	case strings.HasPrefix(path, "/") && strings.Count(path, "[") != strings.Count(path, "]"):
+		return fmt.Sprintf("path %q has an unclosed [param]", path)
+	}
+	return ""
+}
+
+// checkPayTo checks a static pay-to address against its network's address format
+func checkPayTo(network x402.Network, payTo string) string {
+	switch {
+	case payTo == "":
+		return "PayTo is required"
+	case strings.HasPrefix(string(network), "eip155:"):
+		if !common.IsHexAddress(payTo) {
+			return fmt.Sprintf("PayTo %q is not an EVM address (0x followed by 40 hex digits)", payTo)
+		}
+		if common.HexToAddress(payTo) == (common.Address{}) {
+			return "PayTo is the zero address; payments to it are unrecoverable"
+		}
+	case strings.HasPrefix(string(network), "solana:"):
+		if !base58Address.MatchString(payTo) {
+			return fmt.Sprintf("PayTo %q is not a Solana address (base58 public key)", payTo)
+		}
+	}
+	return ""
+}
+
+// checkPrice builds the option's payment requirements, which fails when its scheme isn't
+// registered for the network or its price doesn't parse. Dynamic prices are skipped.
+func (m *Middleware) checkPrice(option x402http.PaymentOption, payTo string) string {
+	if _, ok := option.Price.(x402http.DynamicPriceFunc); ok {
+		return ""
+	}
+	if option.Price == nil {
+		return "Price is required"
+	}
+	if reflect.TypeOf(option.Price).Kind() == reflect.Func {
+		return fmt.Sprintf("Price is a %T; convert it to x402http.DynamicPriceFunc to price per request", option.Price)
+	}
+
+	_, err := m.server.BuildPaymentRequirementsFromConfig(context.Background(), x402.ResourceConfig{
+		Scheme:            option.Scheme,
+		Network:           option.Network,
+		Price:             option.Price,
+		PayTo:             payTo,
+		MaxTimeoutSeconds: option.MaxTimeoutSeconds,
+	})
+	switch {
+	case err == nil:
+		return ""
+	case strings.HasPrefix(err.Error(), "no scheme server"):
+		return fmt.Sprintf("no %s scheme server is registered for %s; register one with WithScheme", option.Scheme, option.Network)
+	default:
+		return fmt.Sprintf("Price %v: %v", option.Price, err)
+	}
+}
+
+// ============================================================================
+// Facilitator Capabilities
+// ============================================================================
+
+// capabilities records the kinds facilitators reported in their last GetSupported, so
+// Validate can check routes against them
+type capabilities struct {
+	mu    sync.RWMutex
+	kinds map[*recordingFacilitator][]x402.SupportedKind
+}
+
+// record wraps client so its GetSupported responses are recorded
+func (c *capabilities) record(client x402.FacilitatorClient) x402.FacilitatorClient {
+	return &recordingFacilitator{FacilitatorClient: client, capabilities: c}
+}
+
+func (c *capabilities) set(client *recordingFacilitator, kinds []x402.SupportedKind) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.kinds == nil {
+		c.kinds = make(map[*recordingFacilitator][]x402.SupportedKind)
+	}
+	c.kinds[client] = kinds
+}
+
+// supports reports whether any facilitator supports x402 v2 payments in scheme on network
+func (c *capabilities) supports(scheme string, network x402.Network) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, kinds := range c.kinds {
+		for _, kind := range kinds {
+			if kind.X402Version == 2 && kind.Scheme == scheme && x402.Network(kind.Network) == network {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recordingFacilitator records a facilitator's supported kinds
+type recordingFacilitator struct {
+	x402.FacilitatorClient
+	capabilities *capabilities
+}
+
+func (f *recordingFacilitator) GetSupported(ctx context.Context) (x402.SupportedResponse, error) {
+	supported, err := f.FacilitatorClient.GetSupported(ctx)
+	if err == nil {
+		f.capabilities.set(f, supported.Kinds)
+	}
+	return supported, err
+}
@@ -0,0 +1,45 @@
+package gin
+
+import (
+	"net/http"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Validity Windows
+// ============================================================================
+
+// WithValidityWindows rejects EVM payments whose authorization has expired, isn't valid yet,
+// or stays valid longer than the route's maxTimeoutSeconds (see xtended402.RouteWithValidity).
+// Clients must sign windows no longer than the requirements ask for.
+func WithValidityWindows() MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.ValidityWindows = true
+	}
+}
+
+// checkValidityWindow rejects a payment whose authorization window doesn't fit its route. It
+// writes the error response and returns false.
+func checkValidityWindow(c *gin.Context, config *MiddlewareConfig, result x402http.HTTPProcessResult) bool {
+	if !config.ValidityWindows {
+		return true
+	}
+	err := xtended402.CheckValidityWindow(result.PaymentPayload, result.PaymentRequirements, config.clock().Now())
+	if err == nil {
+		return true
+	}
+
+	if handler := errorHandler(c, config); handler != nil {
+		handler(c, rejectionError(StageVerify, http.StatusPaymentRequired, result.PaymentRequirements, err))
+	} else {
+		c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
+			"error":   "Invalid payment validity window",
+			"details": err.Error(),
+		}))
+	}
+	c.Abort()
+	return false
+}
@@ -0,0 +1,83 @@
+package gin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Wallet Balance Check
+// ============================================================================
+
+// WalletBalanceQuery is the query parameter carrying the payer's address on balance checks
+const WalletBalanceQuery = "payer"
+
+// WalletBalanceHandler creates a Gin handler reporting whether the wallet in the "payer" query
+// parameter holds enough of each asset a paid route accepts, as an
+// xtended402.WalletBalanceReport, so checkout UIs can warn users before they sign.
+//
+// Like PricePreviewHandler, chain it after the paid route's pricing middleware so dynamic
+// prices resolve identically:
+//
+//	balances, err := xtended402.NewRPCWalletBalanceChecker(ctx, map[x402.Network]string{
+//		"eip155:8453": os.Getenv("BASE_RPC_URL"),
+//	})
+//	r.POST("/api/purchase/balance", calculateOrderTotal,
+//		ginmw.WalletBalanceHandler("POST /api/purchase", routes, server, balances))
+//
+// Balances are read fresh on every request; they're a hint, and payment may still fail.
+func WalletBalanceHandler(route string, routes x402http.RoutesConfig, server *x402.X402ResourceServer, checker xtended402.WalletBalanceChecker) gin.HandlerFunc {
+	routeConfig, ok := routes[route]
+	if !ok {
+		panic(fmt.Sprintf("xtended402: wallet balance route %q not found in RoutesConfig", route))
+	}
+
+	pattern := xtended402.ParseRoutePattern(route)
+	method, path := pattern.Method(), pattern.Path()
+
+	httpServer := x402http.Wrappedx402HTTPResourceServer(routes, server)
+
+	return func(c *gin.Context) {
+		if c.IsAborted() {
+			return
+		}
+
+		payer := c.Query(WalletBalanceQuery)
+		if payer == "" {
+			c.JSON(http.StatusBadRequest, errorBody(c, gin.H{
+				"error":   "Missing payer",
+				"details": fmt.Sprintf("pass the wallet address in the %q query parameter", WalletBalanceQuery),
+			}))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		defer cancel()
+		ctx = xtended402.ContextWithQuery(ctx, c.Request.URL.Query())
+
+		reqCtx := x402http.HTTPRequestContext{
+			Adapter: NewGinAdapter(c),
+			Path:    path,
+			Method:  method,
+		}
+
+		requirements, err := httpServer.BuildPaymentRequirementsFromOptions(ctx, routeConfig.Accepts, reqCtx)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, errorBody(c, gin.H{
+				"error":   "Failed to resolve price",
+				"details": err.Error(),
+			}))
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, xtended402.CheckWalletBalance(ctx, checker, payer, requirements))
+	}
+}
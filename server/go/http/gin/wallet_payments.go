@@ -0,0 +1,287 @@
+package gin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/gin-gonic/gin"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// ============================================================================
+// Wallet Payments
+// ============================================================================
+
+// WithWalletPayments adds a QR code and wallet links to the browser paywall so buyers without
+// a browser wallet can pay from a phone. The page polls statusPath, where
+// WalletPaymentStatusHandler is mounted, and reloads once the transfer is found. Requests
+// presenting a paid checkout's reference in the X-Wallet-Payment header (or the cookie the
+// page sets) are let through without an x402 payment.
+func WithWalletPayments(payments *xtended402.WalletPayments, statusPath string) MiddlewareOption {
+	return func(c *MiddlewareConfig) {
+		c.WalletPayments = payments
+		c.WalletStatusPath = statusPath
+	}
+}
+
+// WalletPaymentStatusHandler serves the status of the wallet checkout named by the :reference
+// path parameter, for the paywall to poll:
+//
+//	r.GET("/wallet-payments/:reference", ginmw.WalletPaymentStatusHandler(payments))
+func WalletPaymentStatusHandler(payments *xtended402.WalletPayments) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		status, err := payments.Status(c.Request.Context(), c.Param("reference"))
+		switch {
+		case errors.Is(err, xtended402.ErrWalletPaymentInvalid):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown wallet payment"})
+		case err != nil:
+			fmt.Printf("Warning: failed to check wallet payment: %v\n", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Wallet payment status unavailable"})
+		default:
+			c.JSON(http.StatusOK, status)
+		}
+	}
+}
+
+// walletRequirements picks the payment option wallet checkouts pay: the first on an EVM network
+func walletRequirements(requirements []x402types.PaymentRequirements) (x402types.PaymentRequirements, bool) {
+	for _, r := range requirements {
+		if strings.HasPrefix(r.Network, "eip155:") {
+			return r, true
+		}
+	}
+	return x402types.PaymentRequirements{}, false
+}
+
+// walletPaymentReference returns the wallet checkout reference the request presents, and
+// whether it came from the paywall's cookie
+func walletPaymentReference(c *gin.Context) (reference string, fromCookie bool) {
+	if reference := c.GetHeader(xtended402.WalletPaymentHeader); reference != "" {
+		return reference, false
+	}
+	if reference, err := c.Cookie(xtended402.WalletPaymentCookie); err == nil && reference != "" {
+		return reference, true
+	}
+	return "", false
+}
+
+// offerWalletCheckout starts a wallet checkout for a paywall page and adds it to the built-in
+// page. Failures leave the page as it is and return nil.
+func offerWalletCheckout(c *gin.Context, server *x402http.HTTPServer, config *MiddlewareConfig, response *x402http.HTTPResponseInstructions) *xtended402.WalletCheckout {
+	requirements, err := challengeRequirements(c, server, config, response)
+	if err != nil {
+		fmt.Printf("Warning: wallet checkout unavailable: %v\n", err)
+		return nil
+	}
+	option, ok := walletRequirements(requirements)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.verifyTimeout())
+	defer cancel()
+
+	checkout, err := config.WalletPayments.Checkout(ctx, resourceOf(c), option)
+	if err != nil {
+		fmt.Printf("Warning: wallet checkout unavailable: %v\n", err)
+		return nil
+	}
+
+	if page, ok := response.Body.(string); ok && config.PaywallRenderer == nil {
+		response.Body = insertBefore(page, "</body>", walletWidget(c, config, checkout))
+	}
+	return checkout
+}
+
+// walletWidget renders a wallet checkout's QR code and links, with a script that polls its
+// status and reloads the page with the reference once it's paid
+func walletWidget(c *gin.Context, config *MiddlewareConfig, checkout *xtended402.WalletCheckout) string {
+	links := make([]string, 0, len(checkout.Links))
+	for _, link := range checkout.Links {
+		links = append(links, fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(link.URL), html.EscapeString(translate(c, link.Wallet))))
+	}
+
+	statusURL := strings.TrimSuffix(config.WalletStatusPath, "/") + "/" + checkout.Reference
+	script := fmt.Sprintf(`(function(){var ref=%s,url=%s,cookie=%s,expired=%s,status=document.getElementById("xtended402-wallet-status");`+
+		`function poll(){fetch(url,{cache:"no-store"}).then(function(r){return r.json()}).then(function(s){`+
+		`if(s.paid){document.cookie=cookie+"="+ref+"; path="+location.pathname+"; max-age=300; SameSite=Lax";location.reload();return}`+
+		`if(s.expired){status.textContent=expired;return}setTimeout(poll,3000)}).catch(function(){setTimeout(poll,5000)})}`+
+		`setTimeout(poll,3000)})();`,
+		jsString(checkout.Reference), jsString(statusURL), jsString(xtended402.WalletPaymentCookie),
+		jsString(translate(c, "This QR code has expired. Reload the page for a new one.")),
+	)
+
+	return fmt.Sprintf(
+		`<div id="xtended402-wallet" style="text-align:center;margin:1.5rem auto;max-width:600px;font-family:system-ui,sans-serif">`+
+			`<p><strong>%s</strong></p><div style="width:220px;margin:0 auto">%s</div>`+
+			`<p>%s</p><p>%s</p><p id="xtended402-wallet-status" style="color:#666">%s</p></div><script>%s</script>`,
+		html.EscapeString(translate(c, "Pay from your phone")),
+		checkout.QRCode,
+		html.EscapeString(translate(c, "Scan with a wallet app to send exactly %s", checkout.DisplayAmount)),
+		strings.Join(links, " &middot; "),
+		html.EscapeString(translate(c, "Waiting for payment...")),
+		script,
+	)
+}
+
+// jsString encodes s as a JavaScript string literal safe inside a script element
+func jsString(s string) string {
+	encoded, _ := json.Marshal(s)
+	return string(encoded)
+}
+
+// handleWalletPayment lets a request paid by a wallet transfer through to the handler. A
+// reference from the paywall's cookie that can't be redeemed is dropped and handled reports
+// false, so the request gets the usual 402.
+func handleWalletPayment(
+	c *gin.Context,
+	server *x402http.HTTPServer,
+	response *x402http.HTTPResponseInstructions,
+	config *MiddlewareConfig,
+	requestBody []byte,
+	reference string,
+	fromCookie bool,
+) (handled bool) {
+	if fromCookie {
+		c.SetCookie(xtended402.WalletPaymentCookie, "", -1, c.Request.URL.Path, "", false, false)
+	}
+
+	requirements, err := challengeRequirements(c, server, config, response)
+	if err == nil {
+		if option, ok := walletRequirements(requirements); ok {
+			requirements = []x402types.PaymentRequirements{option}
+		} else {
+			err = errors.New("no EVM payment option for request")
+		}
+	}
+
+	var payment *xtended402.WalletPayment
+	if err == nil {
+		ctx, cancel := xtended402.ContextWithTimeout(c.Request.Context(), config.clock(), config.verifyTimeout())
+		payment, err = config.WalletPayments.Redeem(ctx, resourceOf(c), reference, requirements[0])
+		cancel()
+	}
+	if err != nil {
+		if fromCookie {
+			fmt.Printf("Warning: ignoring wallet payment %s: %v\n", reference, err)
+			return false
+		}
+		rejectWalletPayment(c, config, err)
+		return true
+	}
+
+	paymentData := &xtended402.PaymentData{
+		SettleResponse: &x402.SettleResponse{
+			Success:     true,
+			Transaction: payment.Transaction,
+			Network:     payment.Network,
+			Payer:       payment.Payer,
+		},
+		PaymentRequirements: &requirements[0],
+		RequestBody:         requestBody,
+		WalletPayment:       payment,
+		RouteParams:         xtended402.GetRouteParams(c),
+		PayerName:           payerName(c, config, payment.Network, payment.Payer),
+		OrderReference:      xtended402.OrderReferenceFromContext(c.Request.Context()),
+		Tax:                 xtended402.TaxFromContext(c.Request.Context()),
+	}
+	c.Set(xtended402.PaymentDataKey, paymentData)
+
+	if settlementTiming(c, config) == "before" {
+		walletSettled(c, config, paymentData)
+		c.Next()
+		return true
+	}
+
+	// Settle-after: only keep the payment if the handler succeeds
+	writer := acquireCapture(c.Writer)
+	c.Writer = writer
+
+	c.Next()
+
+	writer.restore(c)
+	defer releaseCapture(writer)
+	if c.IsAborted() || writer.statusCode >= 400 {
+		if err := config.WalletPayments.Release(c.Request.Context(), reference); err != nil {
+			fmt.Printf("Warning: failed to release wallet payment %s: %v\n", reference, err)
+		}
+	} else {
+		walletSettled(c, config, paymentData)
+	}
+
+	writer.replay()
+	return true
+}
+
+// walletSettled creates the order, runs the settlement handler, issues the session, and emits
+// payment.settled for a wallet transfer
+func walletSettled(c *gin.Context, config *MiddlewareConfig, paymentData *xtended402.PaymentData) {
+	payment := paymentData.WalletPayment
+	if config.OrderConnector != nil {
+		// The transfer is already on chain, so a failed order is only logged
+		orderID, err := config.OrderConnector.CreateOrder(c.Request.Context(), xtended402.OrderRequest{
+			Resource:    resourceOf(c),
+			Scheme:      xtended402.WalletTransferScheme,
+			Network:     payment.Network,
+			Asset:       payment.Asset,
+			Amount:      payment.Amount,
+			PayTo:       paymentData.PaymentRequirements.PayTo,
+			Payer:       payment.Payer,
+			RequestBody: paymentData.RequestBody,
+		})
+		if err != nil {
+			fmt.Printf("Warning: failed to create order for wallet payment %s: %v\n", payment.Reference, err)
+		}
+		paymentData.OrderID = orderID
+	}
+
+	if handler := settlementHandler(c, config); handler != nil {
+		handler(c, paymentData.SettleResponse)
+	}
+	if len(config.EventHandlers) == 0 && paymentData.OrderID == "" && config.Sessions == nil && config.APIKeys == nil {
+		return
+	}
+
+	event := xtended402.NewPaymentEvent(xtended402.EventPaymentSettled, config.clock(), nil, nil)
+	event.Resource = resourceOf(c)
+	event.Scheme = xtended402.WalletTransferScheme
+	event.Network = payment.Network
+	event.Asset = payment.Asset
+	event.Amount = payment.Amount
+	event.PayTo = paymentData.PaymentRequirements.PayTo
+	event.Payer = payment.Payer
+	event.Transaction = payment.Transaction
+	event = withScopes(event, xtended402.ResourceScopes(config.routes(), event.Resource))
+	event = withOrderID(event, paymentData.OrderID)
+	event = withQuoteID(c, event)
+	event = withTenant(c, event)
+	event = withPayerName(c, config, event)
+	event = withOrderReference(event, paymentData.OrderReference)
+	event = withTax(event, paymentData.Tax)
+	issueSession(c, config, event)
+	mintAPIKey(c, config, event)
+	markOrderPaid(c, config, paymentData.OrderID, event)
+	settleQuote(c, config, event)
+	emit(c, config, event)
+}
+
+func rejectWalletPayment(c *gin.Context, config *MiddlewareConfig, err error) {
+	if handler := errorHandler(c, config); handler != nil {
+		handler(c, rejectionError(StageVerify, http.StatusPaymentRequired, nil, fmt.Errorf("wallet payment rejected: %w", err)))
+	} else {
+		c.JSON(http.StatusPaymentRequired, errorBody(c, gin.H{
+			"error":   "Wallet payment rejected",
+			"details": err.Error(),
+		}))
+	}
+	c.Abort()
+}
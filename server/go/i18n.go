@@ -0,0 +1,375 @@
+package xtended402
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocaleKey is the Gin context key holding the locale negotiated for a paid request
+const LocaleKey = "xtended402Locale"
+
+// DefaultLocale is the language messages are written in, used when no supported locale matches
+const DefaultLocale = "en"
+
+// MessageCatalog translates the middleware's English messages. Messages are keyed by their
+// English text, which is also what's shown when a catalog has no translation.
+type MessageCatalog interface {
+	// Message returns the translation of message into locale, if the catalog has one
+	Message(locale, message string) (string, bool)
+
+	// Locales lists the locales the catalog translates into
+	Locales() []string
+}
+
+// Messages is a MessageCatalog of translations by locale, then by English message:
+//
+//	xtended402.Messages{
+//		"it": {"Payment Required": "Pagamento richiesto", "Amount:": "Importo:"},
+//	}
+//
+// Regional locales fall back to their language, so "pt-BR" uses "pt" translations it doesn't
+// override. Messages containing verbs like %s keep them in the translation.
+type Messages map[string]map[string]string
+
+// Message returns the translation of message into locale or its language
+func (m Messages) Message(locale, message string) (string, bool) {
+	locale = normalizeLocale(locale)
+	if translated, ok := m[locale][message]; ok {
+		return translated, true
+	}
+	if base := baseLocale(locale); base != locale {
+		if translated, ok := m[base][message]; ok {
+			return translated, true
+		}
+	}
+	return "", false
+}
+
+// Locales lists the locales with translations
+func (m Messages) Locales() []string {
+	locales := make([]string, 0, len(m))
+	for locale := range m {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Localizer negotiates each request's locale and translates paywall pages and error payloads
+// into it
+type Localizer struct {
+	catalogs      []MessageCatalog
+	defaultLocale string
+}
+
+// LocalizerOption configures a Localizer
+type LocalizerOption func(*Localizer)
+
+// WithMessageCatalog adds a catalog, consulted before catalogs added earlier and before
+// DefaultMessages
+func WithMessageCatalog(catalog MessageCatalog) LocalizerOption {
+	return func(l *Localizer) {
+		l.catalogs = append([]MessageCatalog{catalog}, l.catalogs...)
+	}
+}
+
+// WithDefaultLocale sets the locale used when none of the request's languages are supported
+// (defaults to DefaultLocale)
+func WithDefaultLocale(locale string) LocalizerOption {
+	return func(l *Localizer) {
+		l.defaultLocale = normalizeLocale(locale)
+	}
+}
+
+// NewLocalizer creates a Localizer translating with DefaultMessages and any catalogs added
+// with WithMessageCatalog
+func NewLocalizer(opts ...LocalizerOption) *Localizer {
+	l := &Localizer{defaultLocale: DefaultLocale}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.catalogs = append(l.catalogs, DefaultMessages)
+	return l
+}
+
+// Locales lists every locale a catalog translates into, plus the default locale
+func (l *Localizer) Locales() []string {
+	seen := map[string]bool{l.defaultLocale: true}
+	locales := []string{l.defaultLocale}
+	for _, catalog := range l.catalogs {
+		for _, locale := range catalog.Locales() {
+			if locale = normalizeLocale(locale); !seen[locale] {
+				seen[locale] = true
+				locales = append(locales, locale)
+			}
+		}
+	}
+	return locales
+}
+
+// Negotiate picks the supported locale best matching an Accept-Language header
+func (l *Localizer) Negotiate(acceptLanguage string) string {
+	if locale := NegotiateLocale(acceptLanguage, l.Locales()); locale != "" {
+		return locale
+	}
+	return l.defaultLocale
+}
+
+// Translate returns message in locale, formatted with args if there are any. Untranslated
+// messages are returned in English. A nil Localizer only formats.
+func (l *Localizer) Translate(locale, message string, args ...interface{}) string {
+	if l != nil {
+		for _, catalog := range l.catalogs {
+			if translated, ok := catalog.Message(locale, message); ok {
+				message = translated
+				break
+			}
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// NegotiateLocale picks the locale in supported best matching an Accept-Language header such as
+// "fr-CA,fr;q=0.9,en;q=0.5", or "" if none match. Languages are tried by quality, each matching
+// a supported locale exactly or by language ("fr-CA" matches "fr").
+func NegotiateLocale(acceptLanguage string, supported []string) string {
+	type weighted struct {
+		locale  string
+		quality float64
+	}
+
+	var ranges []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		locale, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if locale = normalizeLocale(locale); locale != "" && locale != "*" && quality > 0 {
+			ranges = append(ranges, weighted{locale, quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		for _, locale := range supported {
+			if normalizeLocale(locale) == r.locale {
+				return locale
+			}
+		}
+		for _, locale := range supported {
+			if baseLocale(normalizeLocale(locale)) == baseLocale(r.locale) {
+				return locale
+			}
+		}
+	}
+	return ""
+}
+
+// GetLocale returns the locale negotiated for the request, or "" without WithLocalizer
+func GetLocale(c *gin.Context) string {
+	return c.GetString(LocaleKey)
+}
+
+// normalizeLocale lowercases a language tag and separates its subtags with hyphens
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// baseLocale returns a language tag's language, e.g. "pt" for "pt-br"
+func baseLocale(locale string) string {
+	base, _, _ := strings.Cut(locale, "-")
+	return base
+}
+
+// DefaultMessages translates the built-in paywall page and error payloads into Spanish,
+// French, German, Portuguese, Japanese, and Chinese
+var DefaultMessages = Messages{
+	"es": {
+		"Payment Required":          "Pago requerido",
+		"Resource:":                 "Recurso:",
+		"Amount:":                   "Importe:",
+		"Loading payment widget...": "Cargando el widget de pago...",
+		"Pay %s %s by card instead": "Pagar %s %s con tarjeta",
+
+		"Pay from your phone":                       "Pagar desde el teléfono",
+		"Scan with a wallet app to send exactly %s": "Escanea con una app de billetera para enviar exactamente %s",
+		"Wallet app":                                "App de billetera",
+		"Waiting for payment...":                    "Esperando el pago...",
+		"This QR code has expired. Reload the page for a new one.": "Este código QR ha caducado. Recarga la página para obtener uno nuevo.",
+
+		"Payment required":                 "Pago requerido",
+		"No matching payment requirements": "Ningún requisito de pago coincide",
+		"Insufficient balance":             "Saldo insuficiente",
+		"Payment already used":             "Pago ya utilizado",
+		"Balance unavailable":              "Saldo no disponible",
+		"Fiat payment rejected":            "Pago con tarjeta rechazado",
+		"Wallet payment rejected":          "Pago con billetera rechazado",
+		"Pre-settlement validation failed": "La validación previa a la liquidación falló",
+		"Settlement failed":                "La liquidación falló",
+		"Order creation failed":            "No se pudo crear el pedido",
+		"Failed to resolve price":          "No se pudo calcular el precio",
+		"Server is shutting down":          "El servidor se está apagando",
+		"Payment service is busy":          "El servicio de pago está ocupado",
+		"Unknown tenant":                   "Comercio desconocido",
+		"Tenant lookup failed":             "No se pudo consultar el comercio",
+	},
+	"fr": {
+		"Payment Required":          "Paiement requis",
+		"Resource:":                 "Ressource :",
+		"Amount:":                   "Montant :",
+		"Loading payment widget...": "Chargement du module de paiement...",
+		"Pay %s %s by card instead": "Payer %s %s par carte",
+
+		"Pay from your phone":                       "Payer depuis votre téléphone",
+		"Scan with a wallet app to send exactly %s": "Scannez avec une application de portefeuille pour envoyer exactement %s",
+		"Wallet app":                                "Application de portefeuille",
+		"Waiting for payment...":                    "En attente du paiement...",
+		"This QR code has expired. Reload the page for a new one.": "Ce code QR a expiré. Rechargez la page pour en obtenir un nouveau.",
+
+		"Payment required":                 "Paiement requis",
+		"No matching payment requirements": "Aucune condition de paiement ne correspond",
+		"Insufficient balance":             "Solde insuffisant",
+		"Payment already used":             "Paiement déjà utilisé",
+		"Balance unavailable":              "Solde indisponible",
+		"Fiat payment rejected":            "Paiement par carte refusé",
+		"Wallet payment rejected":          "Paiement par portefeuille refusé",
+		"Pre-settlement validation failed": "Échec de la validation avant règlement",
+		"Settlement failed":                "Échec du règlement",
+		"Order creation failed":            "Échec de la création de la commande",
+		"Failed to resolve price":          "Impossible de calculer le prix",
+		"Server is shutting down":          "Le serveur est en cours d'arrêt",
+		"Payment service is busy":          "Le service de paiement est occupé",
+		"Unknown tenant":                   "Marchand inconnu",
+		"Tenant lookup failed":             "Échec de la recherche du marchand",
+	},
+	"de": {
+		"Payment Required":          "Zahlung erforderlich",
+		"Resource:":                 "Ressource:",
+		"Amount:":                   "Betrag:",
+		"Loading payment widget...": "Zahlungs-Widget wird geladen...",
+		"Pay %s %s by card instead": "Stattdessen %s %s mit Karte bezahlen",
+
+		"Pay from your phone":                       "Mit dem Handy bezahlen",
+		"Scan with a wallet app to send exactly %s": "Mit einer Wallet-App scannen, um genau %s zu senden",
+		"Wallet app":                                "Wallet-App",
+		"Waiting for payment...":                    "Warte auf Zahlung...",
+		"This QR code has expired. Reload the page for a new one.": "Dieser QR-Code ist abgelaufen. Laden Sie die Seite neu, um einen neuen zu erhalten.",
+
+		"Payment required":                 "Zahlung erforderlich",
+		"No matching payment requirements": "Keine passenden Zahlungsanforderungen",
+		"Insufficient balance":             "Unzureichendes Guthaben",
+		"Payment already used":             "Zahlung bereits verwendet",
+		"Balance unavailable":              "Guthaben nicht verfügbar",
+		"Fiat payment rejected":            "Kartenzahlung abgelehnt",
+		"Wallet payment rejected":          "Wallet-Zahlung abgelehnt",
+		"Pre-settlement validation failed": "Prüfung vor der Abrechnung fehlgeschlagen",
+		"Settlement failed":                "Abrechnung fehlgeschlagen",
+		"Order creation failed":            "Bestellung konnte nicht erstellt werden",
+		"Failed to resolve price":          "Preis konnte nicht ermittelt werden",
+		"Server is shutting down":          "Server wird heruntergefahren",
+		"Payment service is busy":          "Der Zahlungsdienst ist ausgelastet",
+		"Unknown tenant":                   "Unbekannter Händler",
+		"Tenant lookup failed":             "Händlersuche fehlgeschlagen",
+	},
+	"pt": {
+		"Payment Required":          "Pagamento necessário",
+		"Resource:":                 "Recurso:",
+		"Amount:":                   "Valor:",
+		"Loading payment widget...": "Carregando o widget de pagamento...",
+		"Pay %s %s by card instead": "Pagar %s %s com cartão",
+
+		"Pay from your phone":                       "Pagar pelo celular",
+		"Scan with a wallet app to send exactly %s": "Escaneie com um app de carteira para enviar exatamente %s",
+		"Wallet app":                                "App de carteira",
+		"Waiting for payment...":                    "Aguardando o pagamento...",
+		"This QR code has expired. Reload the page for a new one.": "Este código QR expirou. Recarregue a página para obter um novo.",
+
+		"Payment required":                 "Pagamento necessário",
+		"No matching payment requirements": "Nenhum requisito de pagamento correspondente",
+		"Insufficient balance":             "Saldo insuficiente",
+		"Payment already used":             "Pagamento já utilizado",
+		"Balance unavailable":              "Saldo indisponível",
+		"Fiat payment rejected":            "Pagamento com cartão recusado",
+		"Wallet payment rejected":          "Pagamento com carteira recusado",
+		"Pre-settlement validation failed": "Falha na validação antes da liquidação",
+		"Settlement failed":                "Falha na liquidação",
+		"Order creation failed":            "Falha ao criar o pedido",
+		"Failed to resolve price":          "Não foi possível calcular o preço",
+		"Server is shutting down":          "O servidor está sendo desligado",
+		"Payment service is busy":          "O serviço de pagamento está ocupado",
+		"Unknown tenant":                   "Comerciante desconhecido",
+		"Tenant lookup failed":             "Falha ao consultar o comerciante",
+	},
+	"ja": {
+		"Payment Required":          "お支払いが必要です",
+		"Resource:":                 "リソース:",
+		"Amount:":                   "金額:",
+		"Loading payment widget...": "お支払いウィジェットを読み込んでいます...",
+		"Pay %s %s by card instead": "代わりにカードで %s %s を支払う",
+
+		"Pay from your phone":                       "スマートフォンで支払う",
+		"Scan with a wallet app to send exactly %s": "ウォレットアプリでスキャンして、ちょうど %s を送金してください",
+		"Wallet app":                                "ウォレットアプリ",
+		"Waiting for payment...":                    "お支払いを待っています...",
+		"This QR code has expired. Reload the page for a new one.": "この QR コードは有効期限が切れました。ページを再読み込みして新しいコードを取得してください。",
+
+		"Payment required":                 "お支払いが必要です",
+		"No matching payment requirements": "一致する支払い条件がありません",
+		"Insufficient balance":             "残高が不足しています",
+		"Payment already used":             "この支払いは使用済みです",
+		"Balance unavailable":              "残高を利用できません",
+		"Fiat payment rejected":            "カード決済が拒否されました",
+		"Wallet payment rejected":          "ウォレット決済が拒否されました",
+		"Pre-settlement validation failed": "決済前の検証に失敗しました",
+		"Settlement failed":                "決済に失敗しました",
+		"Order creation failed":            "注文を作成できませんでした",
+		"Failed to resolve price":          "価格を算出できませんでした",
+		"Server is shutting down":          "サーバーはシャットダウン中です",
+		"Payment service is busy":          "決済サービスが混み合っています",
+		"Unknown tenant":                   "不明な販売者です",
+		"Tenant lookup failed":             "販売者の照会に失敗しました",
+	},
+	"zh": {
+		"Payment Required":          "需要付款",
+		"Resource:":                 "资源：",
+		"Amount:":                   "金额：",
+		"Loading payment widget...": "正在加载支付组件...",
+		"Pay %s %s by card instead": "改用银行卡支付 %s %s",
+
+		"Pay from your phone":                       "用手机支付",
+		"Scan with a wallet app to send exactly %s": "使用钱包应用扫码，精确转账 %s",
+		"Wallet app":                                "钱包应用",
+		"Waiting for payment...":                    "正在等待付款...",
+		"This QR code has expired. Reload the page for a new one.": "此二维码已过期。请刷新页面获取新的二维码。",
+
+		"Payment required":                 "需要付款",
+		"No matching payment requirements": "没有匹配的付款要求",
+		"Insufficient balance":             "余额不足",
+		"Payment already used":             "该付款已被使用",
+		"Balance unavailable":              "余额不可用",
+		"Fiat payment rejected":            "银行卡付款被拒绝",
+		"Wallet payment rejected":          "钱包付款被拒绝",
+		"Pre-settlement validation failed": "结算前验证失败",
+		"Settlement failed":                "结算失败",
+		"Order creation failed":            "创建订单失败",
+		"Failed to resolve price":          "无法计算价格",
+		"Server is shutting down":          "服务器正在关闭",
+		"Payment service is busy":          "支付服务繁忙",
+		"Unknown tenant":                   "未知商户",
+		"Tenant lookup failed":             "商户查询失败",
+	},
+}
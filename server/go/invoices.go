@@ -0,0 +1,336 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// InvoiceHeader carries an invoice's ID: on a 402 offering the invoice, and on the later
+// request redeeming it once paid
+const InvoiceHeader = "X-Invoice-ID"
+
+// InvoiceScheme is the scheme recorded on events for redeemed invoices
+const InvoiceScheme = "invoice"
+
+// Invoice statuses
+const (
+	InvoiceOpen     = "open"
+	InvoicePaid     = "paid"
+	InvoiceRedeemed = "redeemed"
+	InvoiceExpired  = "expired"
+)
+
+// ErrInvoiceInvalid is returned when an invoice is unknown, or can't be paid or redeemed in its
+// status or for the requested resource
+var ErrInvoiceInvalid = errors.New("invoice invalid")
+
+// Invoice is a payment a 402 asks for that can be paid out of band, e.g. after an approval
+// process, and then redeemed for the request it was issued for
+type Invoice struct {
+	ID string `json:"id"`
+
+	// Resource is the request the invoice pays for, e.g. "GET /reports/42"
+	Resource string `json:"resource"`
+
+	Network x402.Network `json:"network"`
+	Asset   string       `json:"asset"`
+	PayTo   string       `json:"payTo"`
+
+	// Amount is the price in the asset's smallest unit, and DisplayAmount the same formatted,
+	// e.g. "250 USDC"
+	Amount        string `json:"amount"`
+	DisplayAmount string `json:"displayAmount"`
+
+	// Status is InvoiceOpen, InvoicePaid, InvoiceRedeemed, or InvoiceExpired
+	Status string `json:"status"`
+
+	IssuedAt time.Time `json:"issuedAt"`
+
+	// ExpiresAt is when an open invoice can no longer be paid. Paid invoices can be redeemed
+	// after it.
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	// Payer and Transaction identify the payment recorded with MarkPaid
+	Payer       string     `json:"payer,omitempty"`
+	Transaction string     `json:"transaction,omitempty"`
+	PaidAt      *time.Time `json:"paidAt,omitempty"`
+
+	RedeemedAt *time.Time `json:"redeemedAt,omitempty"`
+}
+
+// InvoicePayment is an out-of-band payment of an invoice
+type InvoicePayment struct {
+	// Payer is who paid, e.g. an address or a customer account
+	Payer string
+
+	// Transaction references the payment, e.g. a transfer hash or a purchase order number
+	Transaction string
+}
+
+// InvoiceStore keeps invoices by ID
+type InvoiceStore interface {
+	// SaveInvoice stores invoice, replacing any with the same ID
+	SaveInvoice(ctx context.Context, invoice *Invoice) error
+
+	// LoadInvoice returns the invoice with id, or nil if there is none
+	LoadInvoice(ctx context.Context, id string) (*Invoice, error)
+}
+
+// Invoices issues invoices on 402 responses and redeems them once paid, for buyers who can't
+// pay at request time, like enterprises approving each purchase. Invoices are paid out of band
+// and recorded with MarkPaid, e.g. from an accounts-payable webhook or an admin action.
+//
+// Status changes are atomic within one process only.
+type Invoices struct {
+	store InvoiceStore
+	clock Clock
+	ttl   time.Duration
+
+	mu sync.Mutex
+}
+
+// InvoiceOption configures Invoices
+type InvoiceOption func(*Invoices)
+
+// WithInvoiceTTL sets how long an invoice can be paid after it's issued (default 7 days)
+func WithInvoiceTTL(ttl time.Duration) InvoiceOption {
+	return func(i *Invoices) {
+		i.ttl = ttl
+	}
+}
+
+// WithInvoiceClock sets the clock used for issue and expiry times (defaults to the system
+// clock)
+func WithInvoiceClock(clock Clock) InvoiceOption {
+	return func(i *Invoices) {
+		i.clock = clock
+	}
+}
+
+// NewInvoices creates Invoices keeping invoices in store
+func NewInvoices(store InvoiceStore, opts ...InvoiceOption) *Invoices {
+	i := &Invoices{
+		store: store,
+		ttl:   7 * 24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.clock = ClockOrSystem(i.clock)
+	return i
+}
+
+// Issue issues an invoice for resource ("METHOD /path") asking for requirements
+func (i *Invoices) Issue(ctx context.Context, resource string, requirements x402types.PaymentRequirements) (*Invoice, error) {
+	id, err := newInvoiceID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invoice ID: %w", err)
+	}
+	now := i.clock.Now()
+	invoice := &Invoice{
+		ID:            id,
+		Resource:      resource,
+		Network:       x402.Network(requirements.Network),
+		Asset:         requirements.Asset,
+		PayTo:         requirements.PayTo,
+		Amount:        requirements.Amount,
+		DisplayAmount: FormatTokenAmount(x402.Network(requirements.Network), requirements.Asset, requirements.Amount),
+		Status:        InvoiceOpen,
+		IssuedAt:      now,
+		ExpiresAt:     now.Add(i.ttl),
+	}
+	if err := i.store.SaveInvoice(ctx, invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// Get returns the invoice with id
+func (i *Invoices) Get(ctx context.Context, id string) (*Invoice, error) {
+	return i.load(ctx, id)
+}
+
+// MarkPaid records the out-of-band payment of an open invoice
+func (i *Invoices) MarkPaid(ctx context.Context, id string, payment InvoicePayment) (*Invoice, error) {
+	return i.update(ctx, id, func(invoice *Invoice, now time.Time) error {
+		if invoice.Status != InvoiceOpen {
+			return fmt.Errorf("%w: invoice is %s", ErrInvoiceInvalid, invoice.Status)
+		}
+		invoice.Status = InvoicePaid
+		invoice.Payer = payment.Payer
+		invoice.Transaction = payment.Transaction
+		invoice.PaidAt = &now
+		return nil
+	})
+}
+
+// Redeem redeems a paid invoice for resource ("METHOD /path"). Each invoice is redeemed once.
+func (i *Invoices) Redeem(ctx context.Context, id, resource string) (*Invoice, error) {
+	return i.update(ctx, id, func(invoice *Invoice, now time.Time) error {
+		if invoice.Status != InvoicePaid {
+			return fmt.Errorf("%w: invoice is %s", ErrInvoiceInvalid, invoice.Status)
+		}
+		if invoice.Resource != resource {
+			return fmt.Errorf("%w: invoice is for %s", ErrInvoiceInvalid, invoice.Resource)
+		}
+		invoice.Status = InvoiceRedeemed
+		invoice.RedeemedAt = &now
+		return nil
+	})
+}
+
+// Release returns a redeemed invoice to paid so it can be redeemed again, e.g. when the
+// handler it paid for failed
+func (i *Invoices) Release(ctx context.Context, id string) error {
+	_, err := i.update(ctx, id, func(invoice *Invoice, now time.Time) error {
+		if invoice.Status == InvoiceRedeemed {
+			invoice.Status = InvoicePaid
+			invoice.RedeemedAt = nil
+		}
+		return nil
+	})
+	return err
+}
+
+// update applies change to the invoice with id and saves it
+func (i *Invoices) update(ctx context.Context, id string, change func(invoice *Invoice, now time.Time) error) (*Invoice, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	invoice, err := i.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(invoice, i.clock.Now()); err != nil {
+		return nil, err
+	}
+	if err := i.store.SaveInvoice(ctx, invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// load returns the invoice with id, reporting open invoices past their expiry as expired
+func (i *Invoices) load(ctx context.Context, id string) (*Invoice, error) {
+	invoice, err := i.store.LoadInvoice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if invoice == nil {
+		return nil, fmt.Errorf("%w: unknown invoice", ErrInvoiceInvalid)
+	}
+	if invoice.Status == InvoiceOpen && !i.clock.Now().Before(invoice.ExpiresAt) {
+		invoice.Status = InvoiceExpired
+	}
+	return invoice, nil
+}
+
+func newInvoiceID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return "inv_" + hex.EncodeToString(id), nil
+}
+
+// ============================================================================
+// Invoice Stores
+// ============================================================================
+
+// MemoryInvoiceStore is an in-process InvoiceStore. Invoices are lost on restart.
+type MemoryInvoiceStore struct {
+	mu       sync.Mutex
+	invoices map[string]*Invoice
+}
+
+// NewMemoryInvoiceStore creates an empty MemoryInvoiceStore
+func NewMemoryInvoiceStore() *MemoryInvoiceStore {
+	return &MemoryInvoiceStore{invoices: make(map[string]*Invoice)}
+}
+
+// SaveInvoice stores invoice
+func (s *MemoryInvoiceStore) SaveInvoice(ctx context.Context, invoice *Invoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *invoice
+	s.invoices[invoice.ID] = &stored
+	return nil
+}
+
+// LoadInvoice returns the invoice with id, or nil
+func (s *MemoryInvoiceStore) LoadInvoice(ctx context.Context, id string) (*Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invoice, ok := s.invoices[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *invoice
+	return &found, nil
+}
+
+// invoiceID matches the IDs Invoices issues, so an ID from a request can't name another file
+var invoiceID = regexp.MustCompile(`^inv_[0-9a-f]{32}$`)
+
+// FileInvoiceStore keeps each invoice as a JSON file in a directory, so invoices survive
+// restarts. Writes are atomic within one process only.
+type FileInvoiceStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileInvoiceStore creates a FileInvoiceStore in dir, which is created if needed
+func NewFileInvoiceStore(dir string) *FileInvoiceStore {
+	return &FileInvoiceStore{dir: dir}
+}
+
+// SaveInvoice writes invoice to its file
+func (s *FileInvoiceStore) SaveInvoice(ctx context.Context, invoice *Invoice) error {
+	if !invoiceID.MatchString(invoice.ID) {
+		return fmt.Errorf("%w: malformed ID %q", ErrInvoiceInvalid, invoice.ID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(invoice)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, invoice.ID+".json"), data)
+}
+
+// LoadInvoice reads the invoice with id, or returns nil
+func (s *FileInvoiceStore) LoadInvoice(ctx context.Context, id string) (*Invoice, error) {
+	if !invoiceID.MatchString(id) {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var invoice Invoice
+	if err := json.Unmarshal(data, &invoice); err != nil {
+		return nil, fmt.Errorf("invalid invoice file %s: %w", id, err)
+	}
+	return &invoice, nil
+}
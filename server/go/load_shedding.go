@@ -0,0 +1,200 @@
+package xtended402
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+)
+
+// Reasons LoadShedder.Overloaded reports
+const (
+	ShedFacilitatorLatency  = "facilitator latency"
+	ShedFacilitatorInFlight = "facilitator in-flight calls"
+	ShedSettlementQueue     = "settlement queue depth"
+)
+
+// LoadShedder is a FacilitatorClient that measures how the facilitator is coping, so payments
+// can be turned away early while it's slow instead of each holding a goroutine until its
+// timeout. It's overloaded while the facilitator's recent latency, its oldest in-flight call,
+// the number of calls in flight, or a settlement queue's depth is over its threshold.
+//
+// Latency counts only for a window after the last completed call, so shedding stops by itself
+// once traffic is let through again.
+type LoadShedder struct {
+	x402.FacilitatorClient
+	maxLatency  time.Duration
+	maxInFlight int
+	queueDepth  func() int
+	maxQueue    int
+	window      time.Duration
+	clock       Clock
+
+	mu         sync.Mutex
+	latency    time.Duration
+	lastSample time.Time
+	calls      map[uint64]time.Time
+	nextCall   uint64
+	shed       uint64
+}
+
+// LoadShedderOption configures a LoadShedder
+type LoadShedderOption func(*LoadShedder)
+
+// WithMaxFacilitatorLatency sheds load while facilitator calls take longer than d on average,
+// or one has been in flight longer than d (default 5s)
+func WithMaxFacilitatorLatency(d time.Duration) LoadShedderOption {
+	return func(s *LoadShedder) {
+		s.maxLatency = d
+	}
+}
+
+// WithMaxFacilitatorInFlight sheds load while more than n facilitator calls are in flight
+// (default 0, no limit)
+func WithMaxFacilitatorInFlight(n int) LoadShedderOption {
+	return func(s *LoadShedder) {
+		s.maxInFlight = n
+	}
+}
+
+// WithMaxQueueDepth sheds load while depth reports more than max waiting settlements, e.g.
+// a SettlementPool's:
+//
+//	xtended402.WithMaxQueueDepth(func() int { return pool.Stats().Queued }, 100)
+func WithMaxQueueDepth(depth func() int, max int) LoadShedderOption {
+	return func(s *LoadShedder) {
+		s.queueDepth = depth
+		s.maxQueue = max
+	}
+}
+
+// WithLatencyWindow sets how long after the last completed call its latency counts (default
+// 10s)
+func WithLatencyWindow(d time.Duration) LoadShedderOption {
+	return func(s *LoadShedder) {
+		s.window = d
+	}
+}
+
+// WithLoadShedderClock sets the clock used to time facilitator calls (default SystemClock)
+func WithLoadShedderClock(clock Clock) LoadShedderOption {
+	return func(s *LoadShedder) {
+		s.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewLoadShedder wraps client to measure its load
+func NewLoadShedder(client x402.FacilitatorClient, opts ...LoadShedderOption) *LoadShedder {
+	s := &LoadShedder{
+		FacilitatorClient: client,
+		maxLatency:        5 * time.Second,
+		window:            10 * time.Second,
+		clock:             SystemClock,
+		calls:             make(map[uint64]time.Time),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Verify verifies a payment, timing the call
+func (s *LoadShedder) Verify(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.VerifyResponse, error) {
+	defer s.track()()
+	return s.FacilitatorClient.Verify(ctx, payloadBytes, requirementsBytes)
+}
+
+// Settle settles a payment, timing the call
+func (s *LoadShedder) Settle(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.SettleResponse, error) {
+	defer s.track()()
+	return s.FacilitatorClient.Settle(ctx, payloadBytes, requirementsBytes)
+}
+
+// track records a call starting and returns a func recording it finishing. Latency is a
+// moving average weighting the latest call by a fifth.
+func (s *LoadShedder) track() func() {
+	s.mu.Lock()
+	id := s.nextCall
+	s.nextCall++
+	start := s.clock.Now()
+	s.calls[id] = start
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		now := s.clock.Now()
+		delete(s.calls, id)
+		elapsed := now.Sub(start)
+		if s.lastSample.IsZero() || now.Sub(s.lastSample) > s.window {
+			s.latency = elapsed
+		} else {
+			s.latency += (elapsed - s.latency) / 5
+		}
+		s.lastSample = now
+	}
+}
+
+// Overloaded reports whether payments should be shed, and why. Each true result is counted
+// in Stats as a shed request.
+func (s *LoadShedder) Overloaded() (string, bool) {
+	reason := s.overloaded()
+	if reason == "" {
+		return "", false
+	}
+
+	s.mu.Lock()
+	s.shed++
+	s.mu.Unlock()
+	return reason, true
+}
+
+func (s *LoadShedder) overloaded() string {
+	if s.queueDepth != nil && s.maxQueue > 0 && s.queueDepth() > s.maxQueue {
+		return ShedSettlementQueue
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxInFlight > 0 && len(s.calls) > s.maxInFlight {
+		return ShedFacilitatorInFlight
+	}
+	if s.maxLatency <= 0 {
+		return ""
+	}
+	now := s.clock.Now()
+	if !s.lastSample.IsZero() && now.Sub(s.lastSample) <= s.window && s.latency > s.maxLatency {
+		return ShedFacilitatorLatency
+	}
+	for _, start := range s.calls {
+		if now.Sub(start) > s.maxLatency {
+			return ShedFacilitatorLatency
+		}
+	}
+	return ""
+}
+
+// LoadStats is a snapshot of a LoadShedder's measurements
+type LoadStats struct {
+	// Latency is the recent average facilitator call latency, or 0 outside the window
+	Latency  time.Duration `json:"latency"`
+	InFlight int           `json:"inFlight"`
+
+	// Shed counts the requests turned away
+	Shed uint64 `json:"shed"`
+}
+
+// Stats returns the shedder's current measurements
+func (s *LoadShedder) Stats() LoadStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := LoadStats{InFlight: len(s.calls), Shed: s.shed}
+	if !s.lastSample.IsZero() && s.clock.Now().Sub(s.lastSample) <= s.window {
+		stats.Latency = s.latency
+	}
+	return stats
+}
@@ -0,0 +1,233 @@
+// Package redis takes xtended402 payment locks in Redis, so replicas behind a load balancer
+// don't both fulfill a payment sent to each of them:
+//
+//	locker := redis.New("localhost:6379", redis.WithPassword(os.Getenv("REDIS_PASSWORD")))
+//	defer locker.Close()
+//	ginmw.WithPaymentLocks(locker)
+//
+// Locks are keys set with SET NX PX, released only by their holder. It speaks the Redis
+// protocol over a single connection, redialed after errors, and needs no client library.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// unlockScript deletes a lock only while it holds the caller's token
+const unlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// Locker takes payment locks in a Redis server. It's safe for concurrent use.
+type Locker struct {
+	addr        string
+	password    string
+	db          int
+	tlsConfig   *tls.Config
+	dialTimeout time.Duration
+	prefix      string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Option configures a Locker
+type Option func(*Locker)
+
+// WithPassword authenticates with AUTH
+func WithPassword(password string) Option {
+	return func(l *Locker) {
+		l.password = password
+	}
+}
+
+// WithDB selects a database other than 0
+func WithDB(db int) Option {
+	return func(l *Locker) {
+		l.db = db
+	}
+}
+
+// WithTLS connects over TLS, e.g. to a managed Redis
+func WithTLS(config *tls.Config) Option {
+	return func(l *Locker) {
+		l.tlsConfig = config
+	}
+}
+
+// WithDialTimeout bounds connecting to the server (default 5s)
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(l *Locker) {
+		l.dialTimeout = timeout
+	}
+}
+
+// WithKeyPrefix prefixes lock keys, e.g. to share a database between services
+func WithKeyPrefix(prefix string) Option {
+	return func(l *Locker) {
+		l.prefix = prefix
+	}
+}
+
+// New creates a Locker for the Redis server at addr ("host:port"). It connects on first use.
+func New(addr string, opts ...Option) *Locker {
+	l := &Locker{addr: addr, dialTimeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Lock sets key for ttl unless it's already set
+func (l *Locker) Lock(ctx context.Context, key string, ttl time.Duration) (func(context.Context) error, error) {
+	key = l.prefix + key
+	token := xtended402.NewLockToken()
+	reply, err := l.do(ctx, "SET", key, token, "NX", "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if reply == nil {
+		return nil, xtended402.ErrPaymentLocked
+	}
+
+	return func(ctx context.Context) error {
+		if _, err := l.do(ctx, "EVAL", unlockScript, "1", key, token); err != nil {
+			return fmt.Errorf("redis: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// Close closes the connection
+func (l *Locker) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	err := l.conn.Close()
+	l.conn, l.reader = nil, nil
+	return err
+}
+
+// do sends a command and reads its reply: a string, an int64, or nil. The connection is
+// dropped after errors other than the server's own.
+func (l *Locker) do(ctx context.Context, args ...string) (interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		if err := l.connectLocked(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := l.roundTripLocked(ctx, args...)
+	var serverErr serverError
+	if err != nil && !errors.As(err, &serverErr) {
+		l.conn.Close()
+		l.conn, l.reader = nil, nil
+	}
+	return reply, err
+}
+
+func (l *Locker) connectLocked(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: l.dialTimeout}
+	var conn net.Conn
+	var err error
+	if l.tlsConfig != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: l.tlsConfig}).DialContext(ctx, "tcp", l.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", l.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", l.addr, err)
+	}
+	l.conn, l.reader = conn, bufio.NewReader(conn)
+
+	if l.password != "" {
+		if _, err := l.roundTripLocked(ctx, "AUTH", l.password); err != nil {
+			l.conn.Close()
+			l.conn, l.reader = nil, nil
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if l.db != 0 {
+		if _, err := l.roundTripLocked(ctx, "SELECT", strconv.Itoa(l.db)); err != nil {
+			l.conn.Close()
+			l.conn, l.reader = nil, nil
+			return fmt.Errorf("failed to select database %d: %w", l.db, err)
+		}
+	}
+	return nil
+}
+
+func (l *Locker) roundTripLocked(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, _ := ctx.Deadline()
+	if err := l.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := l.conn.Write([]byte(command.String())); err != nil {
+		return nil, err
+	}
+	return readReply(l.reader)
+}
+
+// serverError is an error reply from the server
+type serverError string
+
+func (e serverError) Error() string {
+	return string(e)
+}
+
+// readReply reads a simple string, error, integer, or bulk string reply
+func readReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, serverError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	default:
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,9 @@
+package notify
+
+// NewDiscord creates a Notifier posting to a Discord webhook URL
+// (https://discord.com/api/webhooks/...)
+func NewDiscord(webhookURL string, opts ...Option) *Notifier {
+	return newNotifier("discord", webhookURL, func(text string) interface{} {
+		return map[string]string{"content": text}
+	}, opts)
+}
@@ -0,0 +1,234 @@
+// Package notify posts payment events to Slack or Discord channels through incoming webhooks,
+// so a team sees sales as they happen. Each Notifier posts to one channel; filter it by route
+// and event type to send different routes to different channels:
+//
+//	sales := notify.NewSlack(os.Getenv("SLACK_SALES_WEBHOOK"), notify.WithRoutes("POST /api/purchase"))
+//	alerts := notify.NewDiscord(os.Getenv("DISCORD_ALERTS_WEBHOOK"), notify.WithEvents(xtended402.EventPaymentFailed))
+//	ginmw.PaymentMiddleware(routes, server,
+//		ginmw.WithEventHandler(sales.Handle),
+//		ginmw.WithEventHandler(alerts.Handle),
+//	)
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// Formatter turns an event into the message text posted to the channel
+type Formatter func(event xtended402.PaymentEvent) string
+
+// Notifier posts payment events to one Slack or Discord channel
+type Notifier struct {
+	service    string
+	webhookURL string
+	payload    func(text string) interface{}
+
+	routes    []xtended402.RoutePattern
+	events    map[xtended402.PaymentEventType]bool
+	format    Formatter
+	templates *xtended402.EventTemplates
+	client    *http.Client
+	onError   func(event xtended402.PaymentEvent, err error)
+
+	wg sync.WaitGroup
+}
+
+// Option configures a Notifier
+type Option func(*Notifier)
+
+// WithRoutes limits the notifier to events from matching routes. Patterns use the same syntax
+// as x402http.RoutesConfig keys, e.g. "POST /api/purchase", "GET /reports/*", or "/items/[id]".
+// By default events from every route are posted.
+func WithRoutes(patterns ...string) Option {
+	return func(n *Notifier) {
+		for _, pattern := range patterns {
+			n.routes = append(n.routes, xtended402.ParseRoutePattern(pattern))
+		}
+	}
+}
+
+// WithEvents limits the notifier to the given event types (default settled, failed, and
+// refunded)
+func WithEvents(types ...xtended402.PaymentEventType) Option {
+	return func(n *Notifier) {
+		n.events = make(map[xtended402.PaymentEventType]bool, len(types))
+		for _, eventType := range types {
+			n.events[eventType] = true
+		}
+	}
+}
+
+// WithFormatter replaces the default message text
+func WithFormatter(format Formatter) Option {
+	return func(n *Notifier) {
+		n.format = format
+	}
+}
+
+// WithTemplates renders events with the shared templates where one exists for the event type,
+// falling back to the formatter for the rest
+func WithTemplates(templates *xtended402.EventTemplates) Option {
+	return func(n *Notifier) {
+		n.templates = templates
+	}
+}
+
+// WithHTTPClient sets the HTTP client (default 10s timeout)
+func WithHTTPClient(client *http.Client) Option {
+	return func(n *Notifier) {
+		n.client = client
+	}
+}
+
+// WithErrorHandler is called when posting a notification fails
+func WithErrorHandler(handler func(event xtended402.PaymentEvent, err error)) Option {
+	return func(n *Notifier) {
+		n.onError = handler
+	}
+}
+
+func newNotifier(service, webhookURL string, payload func(text string) interface{}, opts []Option) *Notifier {
+	n := &Notifier{
+		service:    service,
+		webhookURL: webhookURL,
+		payload:    payload,
+		events: map[xtended402.PaymentEventType]bool{
+			xtended402.EventPaymentSettled:  true,
+			xtended402.EventPaymentFailed:   true,
+			xtended402.EventPaymentRefunded: true,
+		},
+		format: DefaultFormatter,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	n.onError = func(event xtended402.PaymentEvent, err error) {
+		fmt.Printf("Warning: %s notification for %s failed: %v\n", n.service, event.ID, err)
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n
+}
+
+// Handle posts event in the background if it matches the notifier's routes and event types.
+// Use it as a xtended402.PaymentEventHandler.
+func (n *Notifier) Handle(ctx context.Context, event xtended402.PaymentEvent) {
+	if !n.Matches(event) {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if err := n.Post(ctx, event); err != nil {
+			n.onError(event, err)
+		}
+	}()
+}
+
+// Matches reports whether event passes the notifier's route and event type filters
+func (n *Notifier) Matches(event xtended402.PaymentEvent) bool {
+	if !n.events[event.Type] {
+		return false
+	}
+	if len(n.routes) == 0 {
+		return true
+	}
+	for _, route := range n.routes {
+		if route.Matches(event.Resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// Post formats and posts event synchronously, without filtering
+func (n *Notifier) Post(ctx context.Context, event xtended402.PaymentEvent) error {
+	text := n.format(event)
+	if n.templates != nil {
+		rendered, ok, err := n.templates.Render(ctx, event)
+		if err != nil {
+			return fmt.Errorf("%s: %w", n.service, err)
+		}
+		if ok {
+			text = rendered
+		}
+	}
+
+	body, err := json.Marshal(n.payload(text))
+	if err != nil {
+		return fmt.Errorf("%s: failed to encode message: %w", n.service, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %w", n.service, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: request failed: %w", n.service, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("%s: webhook returned %d: %s", n.service, resp.StatusCode, detail)
+	}
+	return nil
+}
+
+// Wait blocks until notifications being posted finish, e.g. during graceful shutdown
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// DefaultFormatter describes the event in one line, e.g.
+// "💰 Sale: 4.50 USDC for POST /api/purchase from 0x1234…abcd (tx 0x9f…)"
+func DefaultFormatter(event xtended402.PaymentEvent) string {
+	var b bytes.Buffer
+
+	switch event.Type {
+	case xtended402.EventPaymentSettled:
+		b.WriteString("💰 Sale: ")
+	case xtended402.EventPaymentFailed:
+		b.WriteString("⚠️ Payment failed: ")
+	case xtended402.EventPaymentRefunded:
+		b.WriteString("↩️ Refunded: ")
+	default:
+		fmt.Fprintf(&b, "%s: ", event.Type)
+	}
+
+	b.WriteString(event.DisplayAmount())
+	if event.Resource != "" {
+		fmt.Fprintf(&b, " for %s", event.Resource)
+	}
+	if name := event.Metadata[xtended402.PayerNameMetadataKey]; name != "" {
+		fmt.Fprintf(&b, " from %s", name)
+	} else if event.Payer != "" {
+		fmt.Fprintf(&b, " from %s", xtended402.ShortAddress(event.Payer))
+	}
+	if event.Network != "" {
+		fmt.Fprintf(&b, " on %s", event.Network)
+	}
+	if event.Transaction != "" {
+		fmt.Fprintf(&b, " (tx %s)", event.Transaction)
+	}
+	if event.ErrorReason != "" {
+		fmt.Fprintf(&b, ": %s", event.ErrorReason)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,9 @@
+package notify
+
+// NewSlack creates a Notifier posting to a Slack incoming webhook URL
+// (https://hooks.slack.com/services/...)
+func NewSlack(webhookURL string, opts ...Option) *Notifier {
+	return newNotifier("slack", webhookURL, func(text string) interface{} {
+		return map[string]string{"text": text}
+	}, opts)
+}
@@ -0,0 +1,389 @@
+// Package openapi generates an OpenAPI 3.1 document describing paid routes' payment contract,
+// so API consumers and gateways know which operations cost what before they get a 402:
+//
+//	doc := openapi.Generate(routes,
+//		openapi.WithInfo("Weather API", "1.0.0"),
+//		openapi.WithServers("https://api.example.com"),
+//	)
+//	r.GET("/openapi.json", gin.WrapH(doc.Handler()))
+//
+// Every operation documents the PAYMENT-SIGNATURE request header, the 402 challenge (its
+// PAYMENT-REQUIRED header and versioned JSON body), and the PAYMENT-RESPONSE header of the paid
+// response. Prices, networks, and recipients are under the x-x402 vendor extension. Routes
+// listed with ginmw.WithBazaarListing also get their example request and response.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	exttypes "github.com/coinbase/x402/go/extensions/types"
+	x402http "github.com/coinbase/x402/go/http"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	"github.com/mvpoyatt/xtended402/server/go/admin"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.1.0"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info is a document's title and version
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL the API is served from
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem maps lowercase HTTP methods to operations
+type PathItem map[string]*Operation
+
+// Operation is one paid route and method
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+
+	// Payment describes what the route costs
+	Payment Payment `json:"x-x402"`
+}
+
+// Parameter is a path, query, or header parameter
+type Parameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      Schema      `json:"schema"`
+	Example     interface{} `json:"example,omitempty"`
+}
+
+// RequestBody is an operation's request body
+type RequestBody struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+// Response is a response, or a reference to a shared one
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header is a response header
+type Header struct {
+	Description string `json:"description,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+// MediaType is a body's schema and example
+type MediaType struct {
+	Schema  Schema      `json:"schema,omitempty"`
+	Example interface{} `json:"example,omitempty"`
+}
+
+// Schema is a JSON Schema
+type Schema map[string]interface{}
+
+// Components holds the schemas and responses operations share
+type Components struct {
+	Schemas   map[string]Schema   `json:"schemas"`
+	Responses map[string]Response `json:"responses"`
+}
+
+// Payment is the x-x402 vendor extension of an operation
+type Payment struct {
+	// Accepts are the route's payment options. Prices and recipients resolved per request are
+	// flagged as dynamic.
+	Accepts []admin.RoutePrice `json:"accepts"`
+
+	// Scopes are the session scopes a payment for the route grants
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// Option configures Generate
+type Option func(*Document)
+
+// WithInfo sets the document's title and version (default "Paid API", "1.0.0")
+func WithInfo(title, version string) Option {
+	return func(d *Document) {
+		d.Info.Title = title
+		d.Info.Version = version
+	}
+}
+
+// WithDescription sets the document's description
+func WithDescription(description string) Option {
+	return func(d *Document) {
+		d.Info.Description = description
+	}
+}
+
+// WithServers sets the base URLs the API is served from
+func WithServers(urls ...string) Option {
+	return func(d *Document) {
+		for _, url := range urls {
+			d.Servers = append(d.Servers, Server{URL: url})
+		}
+	}
+}
+
+// anyMethod lists the operations documented for route patterns without a method
+var anyMethod = []string{"get", "post", "put", "patch", "delete"}
+
+// Generate describes routes' payment contract
+func Generate(routes x402http.RoutesConfig, opts ...Option) *Document {
+	d := &Document{
+		OpenAPI:    Version,
+		Info:       Info{Title: "Paid API", Version: "1.0.0"},
+		Paths:      make(map[string]PathItem),
+		Components: components(),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	for _, described := range admin.Routes(routes) {
+		route := routes[described.Pattern]
+
+		// Regular expressions have no path template
+		pattern := xtended402.ParseRoutePattern(described.Pattern)
+		if strings.HasPrefix(pattern.Path(), "~") {
+			continue
+		}
+
+		methods := anyMethod
+		if method := pattern.Method(); method != "*" {
+			methods = []string{strings.ToLower(method)}
+		}
+		path, params := openAPIPath(pattern.Path())
+
+		item := d.Paths[path]
+		if item == nil {
+			item = make(PathItem)
+			d.Paths[path] = item
+		}
+		for _, m := range methods {
+			item[m] = operation(m, path, params, route, described)
+		}
+	}
+	return d
+}
+
+// JSON encodes the document
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Handler serves the document as JSON
+func (d *Document) Handler() http.Handler {
+	body, err := d.JSON()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// operation documents one method of a paid route
+func operation(method, path string, params []Parameter, route x402http.RouteConfig, described admin.Route) *Operation {
+	op := &Operation{
+		OperationID: operationID(method, path),
+		Summary:     route.Description,
+		Parameters: append(append([]Parameter{}, params...), Parameter{
+			Name:        "PAYMENT-SIGNATURE",
+			In:          "header",
+			Description: "Base64-encoded x402 payment payload answering the 402 challenge",
+			Schema:      Schema{"type": "string", "contentEncoding": "base64"},
+		}),
+		Responses: map[string]Response{
+			"402": {Ref: "#/components/responses/PaymentRequired"},
+		},
+		Payment: Payment{Accepts: described.Accepts, Scopes: described.Scopes},
+	}
+
+	mimeType := route.MimeType
+	if mimeType == "" {
+		mimeType = "application/json"
+	}
+	paid := Response{
+		Description: "Paid response",
+		Headers: map[string]Header{
+			"PAYMENT-RESPONSE": {
+				Description: "Base64-encoded settlement result, once the payment settles",
+				Schema:      Schema{"type": "string", "contentEncoding": "base64"},
+			},
+		},
+		Content: map[string]MediaType{mimeType: {}},
+	}
+
+	if extension, ok := route.Extensions[exttypes.BAZAAR].(exttypes.DiscoveryExtension); ok {
+		describeListing(op, &paid, mimeType, extension)
+	}
+	op.Responses["200"] = paid
+	return op
+}
+
+// describeListing adds a Bazaar listing's example request and response
+func describeListing(op *Operation, paid *Response, mimeType string, extension exttypes.DiscoveryExtension) {
+	input := nestedSchema(extension.Schema, "properties", "input", "properties")
+	switch in := extension.Info.Input.(type) {
+	case exttypes.QueryInput:
+		querySchema := nestedSchema(input, "queryParams")
+		required := map[string]bool{}
+		if names, ok := querySchema["required"].([]string); ok {
+			for _, name := range names {
+				required[name] = true
+			}
+		}
+		properties := nestedSchema(querySchema, "properties")
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		for name := range in.QueryParams {
+			if _, ok := properties[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			schema := nestedSchema(properties, name)
+			if schema == nil {
+				schema = Schema{}
+			}
+			op.Parameters = append(op.Parameters, Parameter{
+				Name: name, In: "query", Required: required[name], Schema: schema, Example: in.QueryParams[name],
+			})
+		}
+
+	case exttypes.BodyInput:
+		contentType := "application/json"
+		switch in.BodyType {
+		case exttypes.BodyTypeFormData:
+			contentType = "multipart/form-data"
+		case exttypes.BodyTypeText:
+			contentType = "text/plain"
+		}
+		op.RequestBody = &RequestBody{Content: map[string]MediaType{
+			contentType: {Schema: nestedSchema(input, "body"), Example: in.Body},
+		}}
+	}
+
+	if output := extension.Info.Output; output != nil {
+		paid.Content[mimeType] = MediaType{
+			Schema:  nestedSchema(extension.Schema, "properties", "output", "properties", "example"),
+			Example: output.Example,
+		}
+	}
+}
+
+// nestedSchema follows keys down a schema, returning nil where one is missing
+func nestedSchema(schema map[string]interface{}, keys ...string) Schema {
+	for _, key := range keys {
+		next, ok := schema[key].(map[string]interface{})
+		if !ok {
+			if typed, isSchema := schema[key].(exttypes.JSONSchema); isSchema {
+				next, ok = typed, true
+			}
+		}
+		if !ok {
+			return nil
+		}
+		schema = next
+	}
+	return schema
+}
+
+// components returns the 402 response and body schema every operation references
+func components() Components {
+	var body Schema
+	if err := json.Unmarshal(xtended402.PaymentRequiredSchema, &body); err == nil {
+		delete(body, "$schema")
+		delete(body, "$id")
+	}
+
+	return Components{
+		Schemas: map[string]Schema{"PaymentRequired": body},
+		Responses: map[string]Response{
+			"PaymentRequired": {
+				Description: "Payment required. Pay one of the accepted options and retry with PAYMENT-SIGNATURE.",
+				Headers: map[string]Header{
+					"PAYMENT-REQUIRED": {
+						Description: "Base64-encoded x402 challenge: the accepted payment requirements",
+						Schema:      Schema{"type": "string", "contentEncoding": "base64"},
+					},
+				},
+				Content: map[string]MediaType{
+					"application/json": {Schema: Schema{"$ref": "#/components/schemas/PaymentRequired"}},
+					"text/html":        {Schema: Schema{"type": "string", "description": "Paywall page, for browsers"}},
+				},
+			},
+		},
+	}
+}
+
+var (
+	routeParam    = regexp.MustCompile(`\[([^\]]+)\]`)
+	colonParam    = regexp.MustCompile(`/:([^/]+)`)
+	nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// openAPIPath turns a route path into an OpenAPI path template: "[id]" and ":id" become
+// "{id}", and each "*" a "{wildcard}" parameter
+func openAPIPath(path string) (string, []Parameter) {
+	path = colonParam.ReplaceAllString(path, "/[$1]")
+
+	var params []Parameter
+	path = routeParam.ReplaceAllStringFunc(path, func(match string) string {
+		name := match[1 : len(match)-1]
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: Schema{"type": "string"}})
+		return "{" + name + "}"
+	})
+
+	wildcards := strings.Count(path, "*")
+	for i := 1; i <= wildcards; i++ {
+		name := "wildcard"
+		if wildcards > 1 {
+			name += string(rune('0' + i))
+		}
+		path = strings.Replace(path, "*", "{"+name+"}", 1)
+		params = append(params, Parameter{
+			Name: name, In: "path", Required: true, Schema: Schema{"type": "string"},
+			Description: "Matches any characters, including slashes",
+		})
+	}
+	return path, params
+}
+
+// operationID derives an operation ID from the method and path, e.g. "getApiWeatherCity"
+func operationID(method, path string) string {
+	id := method
+	for _, word := range nonIdentifier.Split(path, -1) {
+		if word != "" {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
@@ -0,0 +1,42 @@
+package xtended402
+
+import (
+	"context"
+
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/gin-gonic/gin"
+)
+
+// OrderReferenceKey is the request context key a pricing step sets the order reference under,
+// next to the price (see SetOrderReferenceGin)
+var OrderReferenceKey = NewContextKey[string]("orderReference")
+
+const (
+	// OrderReferenceExtraKey is the requirements' extra field carrying the order reference
+	OrderReferenceExtraKey = "orderReference"
+
+	// OrderReferenceMetadataKey is the event metadata key carrying the order reference
+	OrderReferenceMetadataKey = "orderReference"
+)
+
+// SetOrderReferenceGin sets the application's reference for what the request pays for, e.g.
+// a cart ID, from the pricing step. The middleware adds it to the payment requirements, and
+// the paid request's pricing step must set the same reference again.
+func SetOrderReferenceGin(c *gin.Context, reference string) {
+	SetContextValueGin(c, OrderReferenceKey, reference)
+}
+
+// OrderReferenceFromContext returns the order reference set by the pricing step, or ""
+func OrderReferenceFromContext(ctx context.Context) string {
+	reference, _ := OrderReferenceKey.Value(ctx)
+	return reference
+}
+
+// OrderReferenceOf returns the order reference carried by requirements, or ""
+func OrderReferenceOf(requirements *x402types.PaymentRequirements) string {
+	if requirements == nil {
+		return ""
+	}
+	reference, _ := requirements.Extra[OrderReferenceExtraKey].(string)
+	return reference
+}
@@ -0,0 +1,222 @@
+package xtended402
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// OrderIDMetadataKey is the event metadata key carrying the OrderConnector's order ID
+const OrderIDMetadataKey = "orderId"
+
+// OrderRequest describes the order to create for a verified payment
+type OrderRequest struct {
+	// Resource is the paid request, e.g. "POST /api/purchase"
+	Resource string
+
+	Scheme  string
+	Network x402.Network
+	Asset   string
+	Amount  string
+	PayTo   string
+	Payer   string
+
+	// RequestBody is the paid request's body, e.g. the cart
+	RequestBody []byte
+}
+
+// NewOrderRequest describes the order for a payment against requirements on resource
+func NewOrderRequest(resource string, payload *x402types.PaymentPayload, requirements *x402types.PaymentRequirements, body []byte) OrderRequest {
+	order := OrderRequest{
+		Resource:    resource,
+		Payer:       PayerOf(payload),
+		RequestBody: body,
+	}
+	if requirements != nil {
+		order.Scheme = requirements.Scheme
+		order.Network = x402.Network(requirements.Network)
+		order.Asset = requirements.Asset
+		order.Amount = requirements.Amount
+		order.PayTo = requirements.PayTo
+	}
+	return order
+}
+
+// OrderConnector connects the payment lifecycle to an order-management or ERP system. The
+// middleware (see ginmw.WithOrderConnector) calls CreateOrder once a payment is verified,
+// before it settles, and MarkPaid after it settles; RefundOrders calls MarkRefunded for the
+// application's refund events. The order ID is carried in event metadata under
+// OrderIDMetadataKey and in PaymentData.OrderID.
+type OrderConnector interface {
+	// CreateOrder creates a pending order and returns its ID. An error stops the payment
+	// from settling.
+	CreateOrder(ctx context.Context, order OrderRequest) (string, error)
+
+	// MarkPaid records the settlement described by event on the order
+	MarkPaid(ctx context.Context, orderID string, event PaymentEvent) error
+
+	// MarkRefunded records the refund described by event on the order
+	MarkRefunded(ctx context.Context, orderID string, event PaymentEvent) error
+}
+
+// RefundOrders returns a PaymentEventHandler that calls connector.MarkRefunded for
+// payment.refunded events carrying an order ID. Refund events come from the application, so
+// pass them to the handler yourself. Failures are logged.
+func RefundOrders(connector OrderConnector) PaymentEventHandler {
+	return func(ctx context.Context, event PaymentEvent) {
+		orderID := event.Metadata[OrderIDMetadataKey]
+		if event.Type != EventPaymentRefunded || orderID == "" {
+			return
+		}
+		if err := connector.MarkRefunded(ctx, orderID, event); err != nil {
+			fmt.Printf("Warning: failed to mark order %s refunded: %v\n", orderID, err)
+		}
+	}
+}
+
+// ============================================================================
+// Memory Order Connector
+// ============================================================================
+
+// OrderStatus is the state of an order in a MemoryOrderConnector
+type OrderStatus string
+
+const (
+	OrderStatusPending  OrderStatus = "pending"
+	OrderStatusPaid     OrderStatus = "paid"
+	OrderStatusRefunded OrderStatus = "refunded"
+)
+
+// ErrOrderNotFound is returned for unknown order IDs
+var ErrOrderNotFound = errors.New("order not found")
+
+// Order is an order kept by MemoryOrderConnector
+type Order struct {
+	ID        string
+	Status    OrderStatus
+	CreatedAt time.Time
+	Request   OrderRequest
+
+	// Transaction is the settlement transaction, set by MarkPaid
+	Transaction string
+	PaidAt      time.Time
+
+	// RefundTransaction is the refund transaction, set by MarkRefunded
+	RefundTransaction string
+	RefundedAt        time.Time
+
+	seq int
+}
+
+// MemoryOrderConnector is an in-process OrderConnector. It is a reference for connectors to
+// real systems, and useful in development and tests.
+type MemoryOrderConnector struct {
+	clock Clock
+
+	mu     sync.RWMutex
+	orders map[string]*Order
+	nextID int
+}
+
+// NewMemoryOrderConnector creates an empty MemoryOrderConnector. clock stamps orders (nil uses
+// SystemClock).
+func NewMemoryOrderConnector(clock Clock) *MemoryOrderConnector {
+	return &MemoryOrderConnector{
+		clock:  ClockOrSystem(clock),
+		orders: make(map[string]*Order),
+	}
+}
+
+// CreateOrder stores a pending order with a sequential ID ("ord_1", "ord_2", …)
+func (m *MemoryOrderConnector) CreateOrder(ctx context.Context, request OrderRequest) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	order := &Order{
+		ID:        fmt.Sprintf("ord_%d", m.nextID),
+		Status:    OrderStatusPending,
+		CreatedAt: m.clock.Now().UTC(),
+		Request:   request,
+		seq:       m.nextID,
+	}
+	m.orders[order.ID] = order
+	return order.ID, nil
+}
+
+// MarkPaid moves a pending order to paid. Marking a paid order again does nothing.
+func (m *MemoryOrderConnector) MarkPaid(ctx context.Context, orderID string, event PaymentEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.orders[orderID]
+	switch {
+	case !ok:
+		return ErrOrderNotFound
+	case order.Status == OrderStatusPaid:
+		return nil
+	case order.Status != OrderStatusPending:
+		return fmt.Errorf("order %s is %s, not pending", orderID, order.Status)
+	}
+
+	order.Status = OrderStatusPaid
+	order.Transaction = event.Transaction
+	order.PaidAt = event.CreatedAt
+	return nil
+}
+
+// MarkRefunded moves a paid order to refunded. Marking a refunded order again does nothing.
+func (m *MemoryOrderConnector) MarkRefunded(ctx context.Context, orderID string, event PaymentEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.orders[orderID]
+	switch {
+	case !ok:
+		return ErrOrderNotFound
+	case order.Status == OrderStatusRefunded:
+		return nil
+	case order.Status != OrderStatusPaid:
+		return fmt.Errorf("order %s is %s, not paid", orderID, order.Status)
+	}
+
+	order.Status = OrderStatusRefunded
+	order.RefundTransaction = event.Transaction
+	order.RefundedAt = event.CreatedAt
+	return nil
+}
+
+// GetOrder returns the order with id, or ErrOrderNotFound
+func (m *MemoryOrderConnector) GetOrder(ctx context.Context, id string) (*Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order, ok := m.orders[id]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	found := *order
+	return &found, nil
+}
+
+// ListOrders returns every order, oldest first
+func (m *MemoryOrderConnector) ListOrders(ctx context.Context) ([]*Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := make([]*Order, 0, len(m.orders))
+	for _, order := range m.orders {
+		found := *order
+		orders = append(orders, &found)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].seq < orders[j].seq
+	})
+	return orders, nil
+}
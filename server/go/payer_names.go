@@ -0,0 +1,233 @@
+package xtended402
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// PayerNameMetadataKey is the event metadata key carrying the payer's resolved name, e.g.
+// "alice.base.eth"
+const PayerNameMetadataKey = "payerName"
+
+// NameResolver resolves the primary name of a payer's address, e.g. an ENS or Base name. It
+// returns "" when the address has none.
+type NameResolver interface {
+	ResolveName(ctx context.Context, network x402.Network, address string) (string, error)
+}
+
+// NameResolverFunc adapts a function to NameResolver
+type NameResolverFunc func(ctx context.Context, network x402.Network, address string) (string, error)
+
+// ResolveName calls f
+func (f NameResolverFunc) ResolveName(ctx context.Context, network x402.Network, address string) (string, error) {
+	return f(ctx, network, address)
+}
+
+// nameRegistry is an ENS-style registry whose reverse records name addresses
+type nameRegistry struct {
+	network  x402.Network
+	registry common.Address
+
+	// reverse is the parent of reverse records, e.g. "addr.reverse"
+	reverse string
+}
+
+// nameRegistries are the registries RPCNameResolver looks names up in, in order: Base names,
+// then ENS
+var nameRegistries = []nameRegistry{
+	{network: "eip155:8453", registry: common.HexToAddress("0xB94704422c2a1E396835A571837Aa5AE53285a95"), reverse: "80002105.reverse"},
+	{network: "eip155:1", registry: common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"), reverse: "addr.reverse"},
+}
+
+// nameABI holds the registry and resolver methods used to resolve names
+var nameABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(`[
+		{"name": "resolver", "type": "function", "stateMutability": "view", "inputs": [{"name": "node", "type": "bytes32"}], "outputs": [{"name": "", "type": "address"}]},
+		{"name": "name", "type": "function", "stateMutability": "view", "inputs": [{"name": "node", "type": "bytes32"}], "outputs": [{"name": "", "type": "string"}]},
+		{"name": "addr", "type": "function", "stateMutability": "view", "inputs": [{"name": "node", "type": "bytes32"}], "outputs": [{"name": "", "type": "address"}]}
+	]`))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+type cachedName struct {
+	name      string
+	expiresAt time.Time
+}
+
+// RPCNameResolver resolves the primary Base and ENS names of EVM addresses through JSON-RPC
+// nodes on Base (eip155:8453) and Ethereum (eip155:1). Names are the same whichever EVM network
+// the payment was on. A reverse record only counts when the name resolves back to the address.
+// Results, including addresses without a name, are cached. It's safe for concurrent use.
+type RPCNameResolver struct {
+	clients map[x402.Network]*ethclient.Client
+	clock   Clock
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	names map[string]cachedName
+}
+
+// NameResolverOption configures RPCNameResolver
+type NameResolverOption func(*RPCNameResolver)
+
+// WithNameCacheTTL sets how long resolved names are cached (default 1 hour)
+func WithNameCacheTTL(ttl time.Duration) NameResolverOption {
+	return func(r *RPCNameResolver) {
+		r.ttl = ttl
+	}
+}
+
+// WithNameResolverClock sets the clock used for cache expiry (defaults to the system clock)
+func WithNameResolverClock(clock Clock) NameResolverOption {
+	return func(r *RPCNameResolver) {
+		r.clock = clock
+	}
+}
+
+// NewRPCNameResolver connects to RPC nodes for eip155:8453, eip155:1, or both. Base names are
+// looked up before ENS names.
+func NewRPCNameResolver(ctx context.Context, rpcURLs map[x402.Network]string, opts ...NameResolverOption) (*RPCNameResolver, error) {
+	r := &RPCNameResolver{
+		clients: make(map[x402.Network]*ethclient.Client, len(rpcURLs)),
+		ttl:     time.Hour,
+		names:   make(map[string]cachedName),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.clock = ClockOrSystem(r.clock)
+
+	for network, rpcURL := range rpcURLs {
+		if network != "eip155:8453" && network != "eip155:1" {
+			return nil, fmt.Errorf("no name registry on %s", network)
+		}
+		client, err := ethclient.DialContext(ctx, rpcURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s RPC: %w", network, err)
+		}
+		r.clients[network] = client
+	}
+	return r, nil
+}
+
+// ResolveName returns the address's primary name, or "" when it has none or isn't an EVM
+// address
+func (r *RPCNameResolver) ResolveName(ctx context.Context, network x402.Network, address string) (string, error) {
+	if !strings.HasPrefix(string(network), "eip155:") || !common.IsHexAddress(address) {
+		return "", nil
+	}
+	key := strings.ToLower(address)
+
+	r.mu.Lock()
+	cached, ok := r.names[key]
+	r.mu.Unlock()
+	if ok && r.clock.Now().Before(cached.expiresAt) {
+		return cached.name, nil
+	}
+
+	var name string
+	for _, registry := range nameRegistries {
+		client := r.clients[registry.network]
+		if client == nil {
+			continue
+		}
+		resolved, err := lookupName(ctx, client, registry, common.HexToAddress(address))
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve name of %s on %s: %w", address, registry.network, err)
+		}
+		if resolved != "" {
+			name = resolved
+			break
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	for cachedAddress, cached := range r.names {
+		if now.After(cached.expiresAt) {
+			delete(r.names, cachedAddress)
+		}
+	}
+	r.names[key] = cachedName{name: name, expiresAt: now.Add(r.ttl)}
+	return name, nil
+}
+
+// lookupName reads address's reverse record in registry and checks the name resolves back to
+// it
+func lookupName(ctx context.Context, client *ethclient.Client, registry nameRegistry, address common.Address) (string, error) {
+	reverseNode := NameHash(strings.ToLower(strings.TrimPrefix(address.Hex(), "0x")) + "." + registry.reverse)
+	resolver, err := callNameAddress(ctx, client, registry.registry, "resolver", reverseNode)
+	if err != nil || resolver == (common.Address{}) {
+		return "", err
+	}
+	values, err := callName(ctx, client, resolver, "name", reverseNode)
+	if err != nil {
+		return "", err
+	}
+	name, _ := values[0].(string)
+	if name == "" {
+		return "", nil
+	}
+
+	// Anyone can claim any name in their reverse record
+	node := NameHash(name)
+	forwardResolver, err := callNameAddress(ctx, client, registry.registry, "resolver", node)
+	if err != nil || forwardResolver == (common.Address{}) {
+		return "", err
+	}
+	resolved, err := callNameAddress(ctx, client, forwardResolver, "addr", node)
+	if err != nil || resolved != address {
+		return "", err
+	}
+	return name, nil
+}
+
+func callName(ctx context.Context, client *ethclient.Client, contract common.Address, method string, node [32]byte) ([]interface{}, error) {
+	data, err := nameABI.Pack(method, node)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return nameABI.Unpack(method, out)
+}
+
+func callNameAddress(ctx context.Context, client *ethclient.Client, contract common.Address, method string, node [32]byte) (common.Address, error) {
+	values, err := callName(ctx, client, contract, method, node)
+	if err != nil {
+		return common.Address{}, err
+	}
+	address, _ := values[0].(common.Address)
+	return address, nil
+}
+
+// NameHash computes the ENS namehash of a name, e.g. "alice.base.eth". Names are lowercased
+// rather than fully normalized.
+func NameHash(name string) [32]byte {
+	var node [32]byte
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		label := crypto.Keccak256([]byte(labels[i]))
+		copy(node[:], crypto.Keccak256(node[:], label))
+	}
+	return node
+}
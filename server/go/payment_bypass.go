@@ -0,0 +1,13 @@
+package xtended402
+
+import "github.com/gin-gonic/gin"
+
+// PaymentBypassedKey is the Gin context key marking requests to paid routes let through
+// without payment because the middleware's payment-required hook exempted them
+const PaymentBypassedKey = "xtended402PaymentBypassed"
+
+// IsPaymentBypassed reports whether the request was exempted from payment, e.g. as a
+// subscriber's or an internal service's
+func IsPaymentBypassed(c *gin.Context) bool {
+	return c.GetBool(PaymentBypassedKey)
+}
@@ -0,0 +1,310 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// ForwardedPaymentHeader is the HTTP header carrying a signed ForwardedPayment to services
+	// behind the paid gateway
+	ForwardedPaymentHeader = "X-Forwarded-Payment"
+
+	// ForwardedPaymentMetadataKey is the gRPC metadata key carrying a signed ForwardedPayment.
+	// gRPC metadata keys are lowercase.
+	ForwardedPaymentMetadataKey = "x-forwarded-payment"
+)
+
+// ErrForwardedPaymentInvalid is returned for forwarded payments that are missing, malformed,
+// wrongly signed, or expired
+var ErrForwardedPaymentInvalid = errors.New("invalid forwarded payment")
+
+// ForwardedPayment is the payment context a gateway passes to internal services: what was paid,
+// by whom, for which request. It's built from verified PaymentData, so services trust it as far
+// as they trust the signature.
+type ForwardedPayment struct {
+	// Resource is the paid request, e.g. "POST /api/purchase"
+	Resource string `json:"resource,omitempty"`
+
+	// Scheme is the x402 scheme, or for the middleware's alternatives the card provider,
+	// WalletTransferScheme, or InvoiceScheme, as on payment events
+	Scheme  string `json:"scheme,omitempty"`
+	Network string `json:"network,omitempty"`
+	Asset   string `json:"asset,omitempty"`
+	Amount  string `json:"amount,omitempty"`
+	PayTo   string `json:"payTo,omitempty"`
+
+	Payer       string `json:"payer,omitempty"`
+	PayerName   string `json:"payerName,omitempty"`
+	Transaction string `json:"transaction,omitempty"`
+
+	OrderID        string            `json:"orderId,omitempty"`
+	OrderReference string            `json:"orderReference,omitempty"`
+	Tax            *TaxBreakdown     `json:"tax,omitempty"`
+	RouteParams    map[string]string `json:"routeParams,omitempty"`
+
+	IssuedAt  time.Time `json:"issuedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// NewForwardedPayment describes data, the verified payment of resource ("METHOD /path"), for
+// forwarding. IssuedAt and ExpiresAt are set when it's encoded.
+func NewForwardedPayment(data *PaymentData, resource string) ForwardedPayment {
+	payment := ForwardedPayment{
+		Resource:       resource,
+		PayerName:      data.PayerName,
+		OrderID:        data.OrderID,
+		OrderReference: data.OrderReference,
+		Tax:            data.Tax,
+		RouteParams:    data.RouteParams,
+	}
+
+	if req := data.PaymentRequirements; req != nil {
+		payment.Scheme = req.Scheme
+		payment.Network = req.Network
+		payment.Asset = req.Asset
+		payment.Amount = req.Amount
+		payment.PayTo = req.PayTo
+	}
+	payment.Payer = PayerOf(data.PaymentPayload)
+	if settle := data.SettleResponse; settle != nil {
+		payment.Transaction = settle.Transaction
+		if settle.Payer != "" {
+			payment.Payer = settle.Payer
+		}
+	}
+
+	switch {
+	case data.FiatPayment != nil:
+		payment.Scheme = data.FiatPayment.Provider
+		payment.Network = string(FiatNetwork)
+		payment.Asset = data.FiatPayment.Currency
+		payment.Amount = data.FiatPayment.Amount
+		payment.Payer = data.FiatPayment.Customer
+		payment.Transaction = data.FiatPayment.Transaction
+	case data.WalletPayment != nil:
+		payment.Scheme = WalletTransferScheme
+		payment.Network = string(data.WalletPayment.Network)
+		payment.Asset = data.WalletPayment.Asset
+		payment.Amount = data.WalletPayment.Amount
+		payment.Payer = data.WalletPayment.Payer
+		payment.Transaction = data.WalletPayment.Transaction
+	case data.Invoice != nil:
+		payment.Scheme = InvoiceScheme
+		payment.Network = string(data.Invoice.Network)
+		payment.Asset = data.Invoice.Asset
+		payment.Amount = data.Invoice.Amount
+		payment.PayTo = data.Invoice.PayTo
+		payment.Payer = data.Invoice.Payer
+		payment.Transaction = data.Invoice.Transaction
+	}
+
+	return payment
+}
+
+// PaymentForwarder signs forwarded payments at the gateway and verifies them in internal
+// services, which share its secret:
+//
+//	forwarder := xtended402.NewPaymentForwarder(os.Getenv("PAYMENT_FORWARDING_SECRET"))
+//
+//	// Gateway, before proxying
+//	r.Any("/orders/*path", paid, ginmw.ForwardPayment(forwarder), proxyToOrders)
+//
+//	// Internal service
+//	http.ListenAndServe(":8081", forwarder.Handler(ordersMux))
+//	payment := xtended402.ForwardedPaymentFromContext(r.Context())
+//
+// Tokens are short-lived HMAC-signed JSON; keep the secret off the public edge.
+type PaymentForwarder struct {
+	secret   []byte
+	previous [][]byte
+	ttl      time.Duration
+	clock    Clock
+}
+
+// ForwarderOption configures a PaymentForwarder
+type ForwarderOption func(*PaymentForwarder)
+
+// WithForwardedPaymentTTL sets how long a forwarded payment stays valid (default 1 minute).
+// Keep it short: it only needs to outlive the gateway's call to the service.
+func WithForwardedPaymentTTL(ttl time.Duration) ForwarderOption {
+	return func(f *PaymentForwarder) {
+		f.ttl = ttl
+	}
+}
+
+// WithPreviousForwardingSecrets keeps verifying payments signed with retired secrets while
+// a new secret rolls out
+func WithPreviousForwardingSecrets(secrets ...string) ForwarderOption {
+	return func(f *PaymentForwarder) {
+		for _, secret := range secrets {
+			f.previous = append(f.previous, []byte(secret))
+		}
+	}
+}
+
+// WithForwardingClock sets the clock for issue and expiry times (default SystemClock)
+func WithForwardingClock(clock Clock) ForwarderOption {
+	return func(f *PaymentForwarder) {
+		f.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewPaymentForwarder creates a PaymentForwarder signing with secret, which should be at least
+// 32 random bytes
+func NewPaymentForwarder(secret string, opts ...ForwarderOption) *PaymentForwarder {
+	f := &PaymentForwarder{
+		secret: []byte(secret),
+		ttl:    time.Minute,
+		clock:  SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// Encode signs payment, stamping its issue and expiry times
+func (f *PaymentForwarder) Encode(payment ForwardedPayment) (string, error) {
+	now := f.clock.Now().UTC().Truncate(time.Second)
+	payment.IssuedAt = now
+	payment.ExpiresAt = now.Add(f.ttl)
+
+	data, err := json.Marshal(payment)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + forwardingMAC(f.secret, encoded), nil
+}
+
+// Decode verifies token's signature and expiry and returns the payment it carries, or an error
+// wrapping ErrForwardedPaymentInvalid
+func (f *PaymentForwarder) Decode(token string) (*ForwardedPayment, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || encoded == "" || signature == "" {
+		return nil, fmt.Errorf("%w: malformed", ErrForwardedPaymentInvalid)
+	}
+	if !f.verifySignature(encoded, signature) {
+		return nil, fmt.Errorf("%w: bad signature", ErrForwardedPaymentInvalid)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrForwardedPaymentInvalid)
+	}
+	var payment ForwardedPayment
+	if err := json.Unmarshal(data, &payment); err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrForwardedPaymentInvalid)
+	}
+	if !f.clock.Now().Before(payment.ExpiresAt) {
+		return nil, fmt.Errorf("%w: expired", ErrForwardedPaymentInvalid)
+	}
+	return &payment, nil
+}
+
+// SetHeader signs payment into h under ForwardedPaymentHeader, replacing any value a client sent
+func (f *PaymentForwarder) SetHeader(h http.Header, payment ForwardedPayment) error {
+	token, err := f.Encode(payment)
+	if err != nil {
+		return err
+	}
+	h.Set(ForwardedPaymentHeader, token)
+	return nil
+}
+
+// FromHeader verifies the payment in h's ForwardedPaymentHeader
+func (f *PaymentForwarder) FromHeader(h http.Header) (*ForwardedPayment, error) {
+	token := h.Get(ForwardedPaymentHeader)
+	if token == "" {
+		return nil, fmt.Errorf("%w: missing", ErrForwardedPaymentInvalid)
+	}
+	return f.Decode(token)
+}
+
+// SetMetadata signs payment into outgoing gRPC metadata (a metadata.MD) under
+// ForwardedPaymentMetadataKey:
+//
+//	md := metadata.MD{}
+//	err := forwarder.SetMetadata(md, xtended402.NewForwardedPayment(data, resource))
+//	ctx = metadata.NewOutgoingContext(ctx, md)
+func (f *PaymentForwarder) SetMetadata(md map[string][]string, payment ForwardedPayment) error {
+	token, err := f.Encode(payment)
+	if err != nil {
+		return err
+	}
+	md[ForwardedPaymentMetadataKey] = []string{token}
+	return nil
+}
+
+// FromMetadata verifies the payment in incoming gRPC metadata, e.g. in a server interceptor:
+//
+//	md, _ := metadata.FromIncomingContext(ctx)
+//	payment, err := forwarder.FromMetadata(md)
+//	if err != nil {
+//		return nil, status.Error(codes.Unauthenticated, err.Error())
+//	}
+//	ctx = xtended402.ContextWithForwardedPayment(ctx, payment)
+func (f *PaymentForwarder) FromMetadata(md map[string][]string) (*ForwardedPayment, error) {
+	values := md[ForwardedPaymentMetadataKey]
+	if len(values) == 0 || values[0] == "" {
+		return nil, fmt.Errorf("%w: missing", ErrForwardedPaymentInvalid)
+	}
+	return f.Decode(values[0])
+}
+
+// Handler wraps an internal service's handler so only requests carrying a valid forwarded
+// payment reach it; others get a 401. Handlers read the payment with
+// ForwardedPaymentFromContext.
+func (f *PaymentForwarder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payment, err := f.FromHeader(r.Header)
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ContextWithForwardedPayment(r.Context(), payment)))
+	})
+}
+
+func (f *PaymentForwarder) verifySignature(encoded, signature string) bool {
+	if hmac.Equal([]byte(signature), []byte(forwardingMAC(f.secret, encoded))) {
+		return true
+	}
+	for _, secret := range f.previous {
+		if hmac.Equal([]byte(signature), []byte(forwardingMAC(secret, encoded))) {
+			return true
+		}
+	}
+	return false
+}
+
+func forwardingMAC(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type forwardedPaymentContextKey struct{}
+
+// ContextWithForwardedPayment returns a context carrying payment, for
+// ForwardedPaymentFromContext
+func ContextWithForwardedPayment(ctx context.Context, payment *ForwardedPayment) context.Context {
+	return context.WithValue(ctx, forwardedPaymentContextKey{}, payment)
+}
+
+// ForwardedPaymentFromContext returns the verified forwarded payment of the request, or nil
+func ForwardedPaymentFromContext(ctx context.Context) *ForwardedPayment {
+	payment, _ := ctx.Value(forwardedPaymentContextKey{}).(*ForwardedPayment)
+	return payment
+}
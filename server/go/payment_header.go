@@ -0,0 +1,70 @@
+package xtended402
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// maxPooledHeaderBuffer is the largest decode buffer returned to the pool. Payment headers are
+// a few KB; an oversized one is left to the garbage collector.
+const maxPooledHeaderBuffer = 64 << 10
+
+// headerBufferPool holds buffers for base64-decoded header JSON. encoding/json copies strings
+// and raw messages out of its input, so a buffer can be reused as soon as it's unmarshaled.
+var headerBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 4<<10)
+		return &buf
+	},
+}
+
+// decodeHeader base64-decodes header into a pooled buffer sized for it and unmarshals the
+// JSON into v
+func decodeHeader(header string, v interface{}) error {
+	bufp := headerBufferPool.Get().(*[]byte)
+	defer func() {
+		if cap(*bufp) <= maxPooledHeaderBuffer {
+			headerBufferPool.Put(bufp)
+		}
+	}()
+
+	size := base64.StdEncoding.DecodedLen(len(header))
+	if cap(*bufp) < size {
+		*bufp = make([]byte, size)
+	}
+	buf := (*bufp)[:size]
+
+	n, err := base64.StdEncoding.Decode(buf, []byte(header))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf[:n], v)
+}
+
+// DecodePaymentHeader decodes a PAYMENT-SIGNATURE header value in one pass, with a pooled
+// buffer, checking the version from the decoded payload rather than parsing the JSON twice.
+// It's for code that inspects payments before x402 does: the middleware's verification goes
+// through x402's ProcessHTTPRequest, which decodes the header itself.
+func DecodePaymentHeader(header string) (*x402types.PaymentPayload, error) {
+	var payload x402types.PaymentPayload
+	if err := decodeHeader(header, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payment header: %w", err)
+	}
+	if payload.X402Version != 2 {
+		return nil, fmt.Errorf("invalid payment header: only V2 payments supported, got V%d", payload.X402Version)
+	}
+	return &payload, nil
+}
+
+// DecodePaymentRequiredHeader decodes a PAYMENT-REQUIRED header value
+func DecodePaymentRequiredHeader(header string) (*x402types.PaymentRequired, error) {
+	var required x402types.PaymentRequired
+	if err := decodeHeader(header, &required); err != nil {
+		return nil, fmt.Errorf("invalid PAYMENT-REQUIRED header: %w", err)
+	}
+	return &required, nil
+}
@@ -0,0 +1,61 @@
+package xtended402_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/coinbase/x402/go/types"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	"github.com/mvpoyatt/xtended402/server/go/testing/fixtures"
+)
+
+func TestDecodePaymentHeader(t *testing.T) {
+	payload, _ := fixtures.Payment(t)
+
+	decoded, err := xtended402.DecodePaymentHeader(fixtures.Header(t, payload))
+	if err != nil {
+		t.Fatalf("DecodePaymentHeader: %v", err)
+	}
+	if decoded.X402Version != payload.X402Version || decoded.Accepted.Network != payload.Accepted.Network {
+		t.Fatalf("decoded %+v, want %+v", decoded, payload)
+	}
+
+	if _, err := xtended402.DecodePaymentHeader("not base64!"); err == nil {
+		t.Fatal("DecodePaymentHeader accepted an invalid header")
+	}
+}
+
+// BenchmarkDecodePaymentHeaderX402 decodes the header as x402's ProcessHTTPRequest does, for
+// comparison with BenchmarkDecodePaymentHeader
+func BenchmarkDecodePaymentHeaderX402(b *testing.B) {
+	payload, _ := fixtures.Payment(b)
+	header := fixtures.Header(b, payload)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, err := base64.StdEncoding.DecodeString(header)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := types.DetectVersion(data); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := types.ToPaymentPayload(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodePaymentHeader(b *testing.B) {
+	payload, _ := fixtures.Payment(b)
+	header := fixtures.Header(b, payload)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := xtended402.DecodePaymentHeader(header); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// ErrPaymentLocked is returned by PaymentLocker.Lock when another request holds the lock
+var ErrPaymentLocked = errors.New("payment is already being processed")
+
+// PaymentLocker takes locks shared by every replica of a service, e.g. in Redis or etcd, so
+// that a payment sent to two replicas at once is only fulfilled by one
+type PaymentLocker interface {
+	// Lock takes key for ttl, or returns ErrPaymentLocked when it's held. unlock releases it
+	// early, only if it's still held by this lock.
+	Lock(ctx context.Context, key string, ttl time.Duration) (unlock func(ctx context.Context) error, err error)
+}
+
+// PaymentLockKey returns the lock key of a payment: its network, payer, and authorization
+// nonce for EVM payments, or a hash of the signed payload otherwise
+func PaymentLockKey(payload *x402types.PaymentPayload) string {
+	if payload == nil {
+		return ""
+	}
+	if nonce := AuthorizationNonce(payload); nonce != "" {
+		return "x402:payment:" + payload.Accepted.Network + ":" + strings.ToLower(PayerOf(payload)) + ":" + strings.ToLower(nonce)
+	}
+	data, _ := json.Marshal(payload.Payload)
+	sum := sha256.Sum256(data)
+	return "x402:payment:" + payload.Accepted.Network + ":" + hex.EncodeToString(sum[:])
+}
+
+// NewLockToken returns a random token identifying a lock's holder, for PaymentLocker
+// implementations
+func NewLockToken() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// ============================================================================
+// Memory Payment Locker
+// ============================================================================
+
+type paymentLock struct {
+	token     string
+	expiresAt time.Time
+}
+
+// MemoryPaymentLocker is an in-process PaymentLocker for a single replica, development, and
+// tests. It's safe for concurrent use.
+type MemoryPaymentLocker struct {
+	clock Clock
+
+	mu    sync.Mutex
+	locks map[string]paymentLock
+}
+
+// MemoryPaymentLockerOption configures MemoryPaymentLocker
+type MemoryPaymentLockerOption func(*MemoryPaymentLocker)
+
+// WithPaymentLockClock sets the clock used for lock expiry (defaults to the system clock)
+func WithPaymentLockClock(clock Clock) MemoryPaymentLockerOption {
+	return func(l *MemoryPaymentLocker) {
+		l.clock = clock
+	}
+}
+
+// NewMemoryPaymentLocker creates an empty MemoryPaymentLocker
+func NewMemoryPaymentLocker(opts ...MemoryPaymentLockerOption) *MemoryPaymentLocker {
+	l := &MemoryPaymentLocker{locks: make(map[string]paymentLock)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.clock = ClockOrSystem(l.clock)
+	return l
+}
+
+// Lock takes key for ttl
+func (l *MemoryPaymentLocker) Lock(ctx context.Context, key string, ttl time.Duration) (func(context.Context) error, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.sweepLocked(now)
+	if _, held := l.locks[key]; held {
+		return nil, ErrPaymentLocked
+	}
+	token := NewLockToken()
+	l.locks[key] = paymentLock{token: token, expiresAt: now.Add(ttl)}
+
+	return func(context.Context) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if held, ok := l.locks[key]; ok && held.token == token {
+			delete(l.locks, key)
+		}
+		return nil
+	}, nil
+}
+
+// sweepLocked drops expired locks
+func (l *MemoryPaymentLocker) sweepLocked(now time.Time) {
+	for key, held := range l.locks {
+		if !now.Before(held.expiresAt) {
+			delete(l.locks, key)
+		}
+	}
+}
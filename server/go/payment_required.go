@@ -0,0 +1,81 @@
+package xtended402
+
+import (
+	_ "embed"
+
+	x402 "github.com/coinbase/x402/go"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// PaymentRequiredVersion is the version of the JSON 402 body. Fields may be added within a
+// version; removing or changing one bumps it.
+const PaymentRequiredVersion = 1
+
+// PaymentRequiredSchemaURL is where the JSON Schema for the 402 body is published. 402 responses
+// link to it with `Link: <url>; rel="describedby"`.
+const PaymentRequiredSchemaURL = "https://raw.githubusercontent.com/mvpoyatt/xtended402/main/server/go/schema/payment-required.v1.json"
+
+// PaymentRequiredSchema is the JSON Schema for PaymentRequiredBody, for serving alongside the API
+//
+//go:embed schema/payment-required.v1.json
+var PaymentRequiredSchema []byte
+
+// PaymentRequiredBody is the JSON body of a 402 response to an API client. It carries the same
+// challenge as the PAYMENT-REQUIRED header, decoded, so clients can read either.
+type PaymentRequiredBody struct {
+	// Version is PaymentRequiredVersion
+	Version int `json:"version"`
+
+	X402Version int `json:"x402Version"`
+
+	// Error is why payment is required, in English for clients to match on
+	Error string `json:"error"`
+
+	// Message is Error translated into the buyer's locale with WithLocalizer
+	Message string `json:"message,omitempty"`
+
+	Resource   *x402types.ResourceInfo `json:"resource,omitempty"`
+	Accepts    []PaymentRequiredOption `json:"accepts"`
+	Extensions map[string]interface{}  `json:"extensions,omitempty"`
+
+	// PaymentRequired is the encoded PAYMENT-REQUIRED header, for clients that only see bodies
+	PaymentRequired string `json:"paymentRequired,omitempty"`
+
+	// FiatCheckout is the card checkout offered with WithFiatFallback, or nil
+	FiatCheckout *FiatCheckout `json:"fiatCheckout,omitempty"`
+
+	// Invoice is the invoice offered with ginmw.WithInvoices, or nil
+	Invoice *Invoice `json:"invoice,omitempty"`
+
+	// Transports are the ways the server accepts the payment payload besides its header, or
+	// nil for the header only
+	Transports *PaymentTransports `json:"transports,omitempty"`
+}
+
+// PaymentRequiredOption is an accepted payment in a PaymentRequiredBody
+type PaymentRequiredOption struct {
+	x402types.PaymentRequirements
+
+	// DisplayAmount is the formatted price, e.g. "0.01 USDC"
+	DisplayAmount string `json:"displayAmount"`
+}
+
+// NewPaymentRequiredBody builds the JSON 402 body for a challenge and its encoded header
+func NewPaymentRequiredBody(required x402types.PaymentRequired, header string) PaymentRequiredBody {
+	body := PaymentRequiredBody{
+		Version:         PaymentRequiredVersion,
+		X402Version:     required.X402Version,
+		Error:           required.Error,
+		Resource:        required.Resource,
+		Accepts:         make([]PaymentRequiredOption, 0, len(required.Accepts)),
+		Extensions:      required.Extensions,
+		PaymentRequired: header,
+	}
+	for _, requirements := range required.Accepts {
+		body.Accepts = append(body.Accepts, PaymentRequiredOption{
+			PaymentRequirements: requirements,
+			DisplayAmount:       FormatTokenAmount(x402.Network(requirements.Network), requirements.Asset, requirements.Amount),
+		})
+	}
+	return body
+}
@@ -0,0 +1,224 @@
+package xtended402
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+)
+
+// PaymentStatus is the state of a recorded payment
+type PaymentStatus string
+
+const (
+	// PaymentStatusSettled is a payment that settled on-chain
+	PaymentStatusSettled PaymentStatus = "settled"
+
+	// PaymentStatusFailed is a verified payment that failed to settle
+	PaymentStatusFailed PaymentStatus = "failed"
+
+	// PaymentStatusRefunded is a refund paid back to a payer
+	PaymentStatusRefunded PaymentStatus = "refunded"
+)
+
+// ErrPaymentNotFound is returned by PaymentStore.GetPayment for unknown IDs
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// PaymentRecord is a stored payment (or refund). Refunds are recorded as their own records so
+// the store reads as a ledger.
+type PaymentRecord struct {
+	// ID is the ID of the event that created the record
+	ID        string        `json:"id"`
+	Status    PaymentStatus `json:"status"`
+	CreatedAt time.Time     `json:"createdAt"`
+
+	Resource    string       `json:"resource,omitempty"`
+	Scheme      string       `json:"scheme,omitempty"`
+	Network     x402.Network `json:"network,omitempty"`
+	Asset       string       `json:"asset,omitempty"`
+	Amount      string       `json:"amount,omitempty"`
+	PayTo       string       `json:"payTo,omitempty"`
+	Payer       string       `json:"payer,omitempty"`
+	Transaction string       `json:"transaction,omitempty"`
+	ErrorReason string       `json:"errorReason,omitempty"`
+
+	// Fee is the platform's fee on Amount, and NetAmount what's left of it, in the asset's
+	// smallest unit ("" without a PlatformFee)
+	Fee       string `json:"fee,omitempty"`
+	NetAmount string `json:"netAmount,omitempty"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// NewPaymentRecord creates the record for a payment event
+func NewPaymentRecord(event PaymentEvent) (*PaymentRecord, error) {
+	var status PaymentStatus
+	switch event.Type {
+	case EventPaymentSettled:
+		status = PaymentStatusSettled
+	case EventPaymentFailed:
+		status = PaymentStatusFailed
+	case EventPaymentRefunded:
+		status = PaymentStatusRefunded
+	default:
+		return nil, fmt.Errorf("no payment status for event type %q", event.Type)
+	}
+
+	record := &PaymentRecord{
+		ID:          event.ID,
+		Status:      status,
+		CreatedAt:   event.CreatedAt,
+		Resource:    event.Resource,
+		Scheme:      event.Scheme,
+		Network:     event.Network,
+		Asset:       event.Asset,
+		Amount:      event.Amount,
+		PayTo:       event.PayTo,
+		Payer:       event.Payer,
+		Transaction: event.Transaction,
+		ErrorReason: event.ErrorReason,
+		Metadata:    event.Metadata,
+	}
+	// Refunds carry their payment's metadata, fee included
+	if status == PaymentStatusSettled {
+		record.Fee = event.Metadata[PlatformFeeMetadataKey]
+		record.NetAmount = event.Metadata[NetAmountMetadataKey]
+	}
+	return record, nil
+}
+
+// Net returns the amount left after the platform's fee, or Amount without a fee
+func (r *PaymentRecord) Net() string {
+	if r.NetAmount != "" {
+		return r.NetAmount
+	}
+	return r.Amount
+}
+
+// PaymentQuery filters ListPayments. Zero fields match everything.
+type PaymentQuery struct {
+	Status   PaymentStatus
+	Payer    string
+	Resource string
+
+	// Tenant matches records whose metadata names the tenant (see TenantMetadataKey)
+	Tenant string
+
+	// Transaction matches records of a settlement (or refund) transaction hash
+	Transaction string
+
+	// Since and Until bound CreatedAt (Since inclusive, Until exclusive)
+	Since time.Time
+	Until time.Time
+
+	// Limit caps the number of records returned (0 for no limit)
+	Limit int
+}
+
+// Matches reports whether record passes the query's filters (ignoring Limit)
+func (q PaymentQuery) Matches(record *PaymentRecord) bool {
+	switch {
+	case q.Status != "" && record.Status != q.Status:
+		return false
+	case q.Payer != "" && !strings.EqualFold(record.Payer, q.Payer):
+		return false
+	case q.Resource != "" && record.Resource != q.Resource:
+		return false
+	case q.Tenant != "" && record.Metadata[TenantMetadataKey] != q.Tenant:
+		return false
+	case q.Transaction != "" && !strings.EqualFold(record.Transaction, q.Transaction):
+		return false
+	case !q.Since.IsZero() && record.CreatedAt.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !record.CreatedAt.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// PaymentStore persists payment records. ListPayments returns records oldest first.
+type PaymentStore interface {
+	SavePayment(ctx context.Context, record *PaymentRecord) error
+	GetPayment(ctx context.Context, id string) (*PaymentRecord, error)
+	ListPayments(ctx context.Context, query PaymentQuery) ([]*PaymentRecord, error)
+}
+
+// MemoryPaymentStore is an in-process PaymentStore, for development and tests
+type MemoryPaymentStore struct {
+	mu      sync.RWMutex
+	records map[string]*PaymentRecord
+}
+
+// NewMemoryPaymentStore creates an empty MemoryPaymentStore
+func NewMemoryPaymentStore() *MemoryPaymentStore {
+	return &MemoryPaymentStore{records: make(map[string]*PaymentRecord)}
+}
+
+// SavePayment stores record, replacing any record with the same ID
+func (s *MemoryPaymentStore) SavePayment(ctx context.Context, record *PaymentRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *record
+	s.records[record.ID] = &stored
+	return nil
+}
+
+// GetPayment returns the record with id, or ErrPaymentNotFound
+func (s *MemoryPaymentStore) GetPayment(ctx context.Context, id string) (*PaymentRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	found := *record
+	return &found, nil
+}
+
+// ListPayments returns the records matching query, oldest first
+func (s *MemoryPaymentStore) ListPayments(ctx context.Context, query PaymentQuery) ([]*PaymentRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []*PaymentRecord
+	for _, record := range s.records {
+		if query.Matches(record) {
+			found := *record
+			records = append(records, &found)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].ID < records[j].ID
+		}
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	if query.Limit > 0 && len(records) > query.Limit {
+		records = records[:query.Limit]
+	}
+
+	return records, nil
+}
+
+// RecordPayments returns a PaymentEventHandler that saves every payment event to store.
+// Saving runs on the request path, so store should be fast; failures are logged.
+func RecordPayments(store PaymentStore) PaymentEventHandler {
+	return func(ctx context.Context, event PaymentEvent) {
+		record, err := NewPaymentRecord(event)
+		if err != nil {
+			fmt.Printf("Warning: payment %s not recorded: %v\n", event.ID, err)
+			return
+		}
+		if err := store.SavePayment(ctx, record); err != nil {
+			fmt.Printf("Warning: payment %s not recorded: %v\n", event.ID, err)
+		}
+	}
+}
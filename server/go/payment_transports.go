@@ -0,0 +1,179 @@
+package xtended402
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Payment transports, for clients and proxies that mangle large headers
+const (
+	// PaymentHeader is the header x402 clients send payment payloads in
+	PaymentHeader = "PAYMENT-SIGNATURE"
+
+	// PaymentBodyField is the default JSON body field a payment payload can be sent in instead.
+	// The request's own body then goes under PaymentBodyRequestField:
+	//
+	//	{"x402Payment": {"x402Version": 2, ...}, "request": {"city": "Paris"}}
+	PaymentBodyField        = "x402Payment"
+	PaymentBodyRequestField = "request"
+
+	// PaymentTokenHeader carries the token a payment payload was submitted under (see
+	// PaymentSubmissions)
+	PaymentTokenHeader = "X-Payment-Token"
+)
+
+// PaymentTransports tells a client how it can send its payment payload, in the 402 body. The
+// header always works; the others are offered when the server accepts them.
+type PaymentTransports struct {
+	// Header is PaymentHeader
+	Header string `json:"header"`
+
+	// BodyField is the JSON body field a payload can be sent in, with the request's own body
+	// under PaymentBodyRequestField
+	BodyField string `json:"bodyField,omitempty"`
+
+	// SubmitURL is where a payload can be POSTed for a token, sent back in TokenHeader
+	SubmitURL   string `json:"submitUrl,omitempty"`
+	TokenHeader string `json:"tokenHeader,omitempty"`
+}
+
+// ErrPaymentSubmissionInvalid is returned when a submitted payment token is unknown, expired,
+// or already used
+var ErrPaymentSubmissionInvalid = errors.New("payment submission invalid")
+
+// EncodePaymentPayload turns a payment payload sent as JSON into a PAYMENT-SIGNATURE header
+// value. The payload may be the JSON object or a string holding the encoded header.
+func EncodePaymentPayload(payload json.RawMessage) (string, error) {
+	payload = bytes.TrimSpace(payload)
+	var header string
+	if len(payload) > 0 && payload[0] == '"' {
+		if err := json.Unmarshal(payload, &header); err != nil {
+			return "", fmt.Errorf("invalid payment payload: %w", err)
+		}
+	} else {
+		header = base64.StdEncoding.EncodeToString(payload)
+	}
+
+	if _, err := DecodePaymentHeader(header); err != nil {
+		return "", err
+	}
+	return header, nil
+}
+
+// PaymentSubmission is a stored payment payload's token
+type PaymentSubmission struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type paymentSubmission struct {
+	header    string
+	expiresAt time.Time
+}
+
+// PaymentSubmissions holds payment payloads POSTed ahead of the request they pay for, so a
+// client whose headers can't carry a payload sends a short token in PaymentTokenHeader
+// instead. Each token is redeemed once. It's safe for concurrent use.
+//
+// Submissions are held in memory, so run one replica or route a client's requests to the same
+// one.
+type PaymentSubmissions struct {
+	clock Clock
+	ttl   time.Duration
+
+	mu          sync.Mutex
+	submissions map[string]paymentSubmission
+}
+
+// PaymentSubmissionOption configures PaymentSubmissions
+type PaymentSubmissionOption func(*PaymentSubmissions)
+
+// WithPaymentSubmissionTTL sets how long a submitted payload can be redeemed (default 5
+// minutes). The payload's own validity still applies.
+func WithPaymentSubmissionTTL(ttl time.Duration) PaymentSubmissionOption {
+	return func(s *PaymentSubmissions) {
+		s.ttl = ttl
+	}
+}
+
+// WithPaymentSubmissionClock sets the clock used for expiry (defaults to the system clock)
+func WithPaymentSubmissionClock(clock Clock) PaymentSubmissionOption {
+	return func(s *PaymentSubmissions) {
+		s.clock = clock
+	}
+}
+
+// NewPaymentSubmissions creates an empty PaymentSubmissions
+func NewPaymentSubmissions(opts ...PaymentSubmissionOption) *PaymentSubmissions {
+	s := &PaymentSubmissions{
+		ttl:         5 * time.Minute,
+		submissions: make(map[string]paymentSubmission),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.clock = ClockOrSystem(s.clock)
+	return s
+}
+
+// Submit stores a payment payload (see EncodePaymentPayload) and returns its token
+func (s *PaymentSubmissions) Submit(payload json.RawMessage) (*PaymentSubmission, error) {
+	header, err := EncodePaymentPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	token, err := newPaymentToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payment token: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.sweepLocked(now)
+	expiresAt := now.Add(s.ttl)
+	s.submissions[token] = paymentSubmission{header: header, expiresAt: expiresAt}
+	return &PaymentSubmission{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// Redeem returns the PAYMENT-SIGNATURE header value submitted under token, which can't be
+// redeemed again
+func (s *PaymentSubmissions) Redeem(token string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	submission, ok := s.submissions[token]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown token", ErrPaymentSubmissionInvalid)
+	}
+	delete(s.submissions, token)
+	if s.clock.Now().After(submission.expiresAt) {
+		return "", fmt.Errorf("%w: token expired", ErrPaymentSubmissionInvalid)
+	}
+	return submission.header, nil
+}
+
+// sweepLocked drops expired submissions
+func (s *PaymentSubmissions) sweepLocked(now time.Time) {
+	for token, submission := range s.submissions {
+		if now.After(submission.expiresAt) {
+			delete(s.submissions, token)
+		}
+	}
+}
+
+func newPaymentToken() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return "pt_" + hex.EncodeToString(id), nil
+}
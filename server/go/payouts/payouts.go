@@ -0,0 +1,258 @@
+// Package payouts aggregates recorded payments by recipient, asset, and day or week, so
+// operators can reconcile what each collection address received, and sweeps collected funds to
+// a treasury on a schedule:
+//
+//	reporter := payouts.NewReporter(store, payouts.WithLocation(berlin))
+//	totals, err := reporter.Totals(ctx, payouts.Weekly, xtended402.PaymentQuery{Since: monthStart, Until: monthEnd})
+package payouts
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// Period is the length of the windows payments are totalled over
+type Period string
+
+const (
+	// Daily totals run from midnight to midnight in the reporter's location
+	Daily Period = "daily"
+
+	// Weekly totals run from midnight on the reporter's first day of the week
+	Weekly Period = "weekly"
+)
+
+// ParsePeriod parses "daily" or "weekly"
+func ParsePeriod(value string) (Period, error) {
+	switch period := Period(strings.ToLower(value)); period {
+	case Daily, Weekly:
+		return period, nil
+	default:
+		return "", fmt.Errorf("invalid payout period %q", value)
+	}
+}
+
+// Total is what one recipient received in one token over one period. Amounts are in the
+// token's smallest units (major units for fiat).
+type Total struct {
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+
+	PayTo   string       `json:"payTo"`
+	Network x402.Network `json:"network"`
+	Asset   string       `json:"asset"`
+
+	// Gross is the sum of settled payments, and Refunded the sum of refunds made in the period
+	Gross    string `json:"gross"`
+	Refunded string `json:"refunded"`
+
+	// Fees are the platform fees on the settled payments (see xtended402.PlatformFee)
+	Fees string `json:"fees"`
+
+	// Net is Gross less Refunded and Fees: what the recipient keeps
+	Net        string `json:"net"`
+	NetDisplay string `json:"netDisplay"`
+
+	Payments int `json:"payments"`
+	Refunds  int `json:"refunds"`
+}
+
+// Reporter totals the payments in a PaymentStore
+type Reporter struct {
+	store     xtended402.PaymentStore
+	location  *time.Location
+	weekStart time.Weekday
+}
+
+// Option configures a Reporter
+type Option func(*Reporter)
+
+// WithLocation sets the time zone periods start in (default UTC)
+func WithLocation(location *time.Location) Option {
+	return func(r *Reporter) {
+		r.location = location
+	}
+}
+
+// WithWeekStart sets the first day of Weekly periods (default Monday)
+func WithWeekStart(day time.Weekday) Option {
+	return func(r *Reporter) {
+		r.weekStart = day
+	}
+}
+
+// NewReporter creates a Reporter reading from store
+func NewReporter(store xtended402.PaymentStore, opts ...Option) *Reporter {
+	r := &Reporter{
+		store:     store,
+		location:  time.UTC,
+		weekStart: time.Monday,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// PeriodOf returns the bounds of the period containing t
+func (r *Reporter) PeriodOf(period Period, t time.Time) (start, end time.Time) {
+	t = t.In(r.location)
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, r.location)
+	if period == Weekly {
+		start = start.AddDate(0, 0, -((int(start.Weekday()) - int(r.weekStart) + 7) % 7))
+		return start, start.AddDate(0, 0, 7)
+	}
+	return start, start.AddDate(0, 0, 1)
+}
+
+// Totals returns the settled payments and refunds matching query, totalled per recipient,
+// token, and period, oldest period first. Failed payments are skipped.
+func (r *Reporter) Totals(ctx context.Context, period Period, query xtended402.PaymentQuery) ([]Total, error) {
+	records, err := r.store.ListPayments(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+
+	type totalKey struct {
+		start   int64
+		payTo   string
+		network x402.Network
+		asset   string
+	}
+	type sums struct {
+		total     Total
+		gross     *big.Rat
+		refunded  *big.Rat
+		fees      *big.Rat
+		precision string
+	}
+	totals := make(map[totalKey]*sums)
+
+	for _, record := range records {
+		if record.Status != xtended402.PaymentStatusSettled && record.Status != xtended402.PaymentStatusRefunded {
+			continue
+		}
+		amount, ok := new(big.Rat).SetString(record.Amount)
+		if !ok {
+			continue
+		}
+
+		start, end := r.PeriodOf(period, record.CreatedAt)
+		key := totalKey{start.Unix(), strings.ToLower(record.PayTo), record.Network, strings.ToLower(record.Asset)}
+		sum := totals[key]
+		if sum == nil {
+			sum = &sums{
+				total: Total{
+					PeriodStart: start,
+					PeriodEnd:   end,
+					PayTo:       record.PayTo,
+					Network:     record.Network,
+					Asset:       record.Asset,
+				},
+				gross:     new(big.Rat),
+				refunded:  new(big.Rat),
+				fees:      new(big.Rat),
+				precision: record.Amount,
+			}
+			totals[key] = sum
+		}
+
+		if record.Status == xtended402.PaymentStatusRefunded {
+			sum.refunded.Add(sum.refunded, amount)
+			sum.total.Refunds++
+			continue
+		}
+		sum.gross.Add(sum.gross, amount)
+		sum.total.Payments++
+		if fee, ok := new(big.Rat).SetString(record.Fee); ok {
+			sum.fees.Add(sum.fees, fee)
+		}
+	}
+
+	result := make([]Total, 0, len(totals))
+	for _, sum := range totals {
+		net := new(big.Rat).Sub(sum.gross, sum.refunded)
+		net.Sub(net, sum.fees)
+
+		total := sum.total
+		total.Gross = formatAmount(sum.gross, sum.precision)
+		total.Refunded = formatAmount(sum.refunded, sum.precision)
+		total.Fees = formatAmount(sum.fees, sum.precision)
+		total.Net = formatAmount(net, sum.precision)
+		total.NetDisplay = displayAmount(total.Network, total.Asset, total.Net)
+		result = append(result, total)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ti, tj := result[i], result[j]
+		switch {
+		case !ti.PeriodStart.Equal(tj.PeriodStart):
+			return ti.PeriodStart.Before(tj.PeriodStart)
+		case ti.PayTo != tj.PayTo:
+			return ti.PayTo < tj.PayTo
+		case ti.Network != tj.Network:
+			return ti.Network < tj.Network
+		}
+		return ti.Asset < tj.Asset
+	})
+
+	return result, nil
+}
+
+// WriteCSV writes the totals of the payments matching query as CSV, one row per recipient,
+// token, and period
+func (r *Reporter) WriteCSV(ctx context.Context, w io.Writer, period Period, query xtended402.PaymentQuery) error {
+	totals, err := r.Totals(ctx, period, query)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"Period Start", "Period End", "Pay To", "Network", "Asset", "Payments", "Refunds", "Gross", "Refunded", "Fees", "Net", "Net Display"})
+	for _, total := range totals {
+		_ = out.Write([]string{
+			total.PeriodStart.Format(time.RFC3339),
+			total.PeriodEnd.Format(time.RFC3339),
+			total.PayTo,
+			string(total.Network),
+			total.Asset,
+			strconv.Itoa(total.Payments),
+			strconv.Itoa(total.Refunds),
+			total.Gross,
+			total.Refunded,
+			total.Fees,
+			total.Net,
+			total.NetDisplay,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// formatAmount formats value with as many decimal places as like, e.g. "3.50" for "5.00"
+func formatAmount(value *big.Rat, like string) string {
+	decimals := 0
+	if dot := strings.IndexByte(like, '.'); dot >= 0 {
+		decimals = len(like) - dot - 1
+	}
+	return value.FloatString(decimals)
+}
+
+// displayAmount formats amount for people, keeping the sign of net refunds
+func displayAmount(network x402.Network, asset, amount string) string {
+	if abs, negative := strings.CutPrefix(amount, "-"); negative {
+		return "-" + xtended402.FormatTokenAmount(network, asset, abs)
+	}
+	return xtended402.FormatTokenAmount(network, asset, amount)
+}
@@ -0,0 +1,226 @@
+package payouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// Transferer sends amount (in the asset's smallest unit) of asset from a collection address
+// it controls to another address, and returns the transfer transaction. The library doesn't
+// move funds itself.
+type Transferer interface {
+	Transfer(ctx context.Context, network x402.Network, asset, from, to, amount string) (transaction string, err error)
+}
+
+// TransfererFunc adapts a function to Transferer
+type TransfererFunc func(ctx context.Context, network x402.Network, asset, from, to, amount string) (string, error)
+
+// Transfer calls f
+func (f TransfererFunc) Transfer(ctx context.Context, network x402.Network, asset, from, to, amount string) (string, error) {
+	return f(ctx, network, asset, from, to, amount)
+}
+
+// SweepRule moves a collection address's whole balance of one token to a treasury address
+type SweepRule struct {
+	Network x402.Network `json:"network"`
+	Asset   string       `json:"asset"`
+
+	// From is the collection address payments are paid to, To the treasury
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Minimum skips sweeps of smaller balances, in the asset's smallest unit, so dust isn't
+	// swept at a loss to gas ("" sweeps any balance)
+	Minimum string `json:"minimum,omitempty"`
+}
+
+// Sweep is one transfer made by a Scheduler
+type Sweep struct {
+	Rule        SweepRule `json:"rule"`
+	Amount      string    `json:"amount"`
+	Transaction string    `json:"transaction"`
+	SweptAt     time.Time `json:"sweptAt"`
+}
+
+// Scheduler reports each period's totals once it ends, and optionally sweeps collected funds to
+// a treasury at the same time:
+//
+//	scheduler := payouts.NewScheduler(reporter, payouts.Daily,
+//		payouts.WithReportHandler(func(ctx context.Context, start, end time.Time, totals []payouts.Total) {
+//			emailFinance(start, totals)
+//		}),
+//		payouts.WithSweeps(balances, transferer, payouts.SweepRule{
+//			Network: "eip155:8453", Asset: usdc, From: collection, To: treasury, Minimum: "10000000",
+//		}),
+//	)
+//	defer scheduler.Close()
+//
+// Payments recorded after their period was reported show up in Reporter.Totals, not in a later
+// report.
+type Scheduler struct {
+	reporter *Reporter
+	period   Period
+
+	onReport func(ctx context.Context, start, end time.Time, totals []Total)
+	onSweep  func(ctx context.Context, sweep Sweep)
+	onError  func(err error)
+
+	balances   xtended402.WalletBalanceChecker
+	transferer Transferer
+	sweeps     []SweepRule
+
+	clock xtended402.Clock
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// SchedulerOption configures a Scheduler
+type SchedulerOption func(*Scheduler)
+
+// WithReportHandler is called with each period's totals after it ends
+func WithReportHandler(handler func(ctx context.Context, start, end time.Time, totals []Total)) SchedulerOption {
+	return func(s *Scheduler) {
+		s.onReport = handler
+	}
+}
+
+// WithSweeps sweeps each rule's collection address into its treasury after every period.
+// Balances are read through balances, e.g. xtended402.NewRPCWalletBalanceChecker, and moved
+// by transferer.
+func WithSweeps(balances xtended402.WalletBalanceChecker, transferer Transferer, rules ...SweepRule) SchedulerOption {
+	return func(s *Scheduler) {
+		s.balances = balances
+		s.transferer = transferer
+		s.sweeps = append(s.sweeps, rules...)
+	}
+}
+
+// WithSweepHandler is called after each sweep, e.g. to record it
+func WithSweepHandler(handler func(ctx context.Context, sweep Sweep)) SchedulerOption {
+	return func(s *Scheduler) {
+		s.onSweep = handler
+	}
+}
+
+// WithErrorHandler is called when a report or sweep fails (default: logged)
+func WithErrorHandler(handler func(err error)) SchedulerOption {
+	return func(s *Scheduler) {
+		s.onError = handler
+	}
+}
+
+// WithClock sets the clock that decides when periods end (default SystemClock)
+func WithClock(clock xtended402.Clock) SchedulerOption {
+	return func(s *Scheduler) {
+		s.clock = xtended402.ClockOrSystem(clock)
+	}
+}
+
+// NewScheduler creates a Scheduler running at the end of every period and starts it. Call
+// Close to stop it.
+func NewScheduler(reporter *Reporter, period Period, opts ...SchedulerOption) *Scheduler {
+	s := &Scheduler{
+		reporter: reporter,
+		period:   period,
+		clock:    xtended402.SystemClock,
+		onError: func(err error) {
+			fmt.Printf("Warning: payout scheduler: %v\n", err)
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	go s.loop()
+	return s
+}
+
+// Run reports the period that ends at end, then sweeps. The scheduler calls it at the end of
+// every period; call it yourself to catch up after downtime.
+func (s *Scheduler) Run(ctx context.Context, end time.Time) {
+	if s.onReport != nil {
+		start, _ := s.reporter.PeriodOf(s.period, end.Add(-time.Nanosecond))
+		totals, err := s.reporter.Totals(ctx, s.period, xtended402.PaymentQuery{Since: start, Until: end})
+		if err != nil {
+			s.onError(fmt.Errorf("report for %s: %w", start.Format(time.DateOnly), err))
+		} else {
+			s.onReport(ctx, start, end, totals)
+		}
+	}
+
+	for _, rule := range s.sweeps {
+		sweep, err := s.Sweep(ctx, rule)
+		if err != nil {
+			s.onError(fmt.Errorf("sweep of %s on %s: %w", rule.From, rule.Network, err))
+			continue
+		}
+		if sweep != nil && s.onSweep != nil {
+			s.onSweep(ctx, *sweep)
+		}
+	}
+}
+
+// Sweep moves rule's collection balance to its treasury now. It returns nil without a
+// transfer when the balance is below the rule's minimum.
+func (s *Scheduler) Sweep(ctx context.Context, rule SweepRule) (*Sweep, error) {
+	if s.balances == nil || s.transferer == nil {
+		return nil, errors.New("sweeps are not configured")
+	}
+
+	balance, err := s.balances.WalletBalance(ctx, rule.Network, rule.Asset, rule.From)
+	if err != nil {
+		return nil, err
+	}
+	minimum := big.NewInt(1)
+	if rule.Minimum != "" {
+		if _, ok := minimum.SetString(rule.Minimum, 10); !ok {
+			return nil, fmt.Errorf("invalid minimum %q", rule.Minimum)
+		}
+	}
+	if balance.Sign() <= 0 || balance.Cmp(minimum) < 0 {
+		return nil, nil
+	}
+
+	transaction, err := s.transferer.Transfer(ctx, rule.Network, rule.Asset, rule.From, rule.To, balance.String())
+	if err != nil {
+		return nil, err
+	}
+	return &Sweep{
+		Rule:        rule,
+		Amount:      balance.String(),
+		Transaction: transaction,
+		SweptAt:     s.clock.Now().UTC(),
+	}, nil
+}
+
+// Close stops the scheduler, waiting for a run in progress
+func (s *Scheduler) Close() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.done)
+	for {
+		_, end := s.reporter.PeriodOf(s.period, s.clock.Now())
+		select {
+		case <-s.stop:
+			return
+		case <-s.clock.After(end.Sub(s.clock.Now())):
+			s.Run(context.Background(), end)
+		}
+	}
+}
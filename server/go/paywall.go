@@ -0,0 +1,216 @@
+package xtended402
+
+import (
+	"context"
+	htmltemplate "html/template"
+	"io"
+
+	x402 "github.com/coinbase/x402/go"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// PaywallOption is one way to pay shown on a paywall
+type PaywallOption struct {
+	Requirements x402types.PaymentRequirements
+
+	// Amount is the formatted price, e.g. "0.01 USDC"
+	Amount string
+
+	Network x402.Network
+	PayTo   string
+}
+
+// PaywallData is what a custom paywall renders
+type PaywallData struct {
+	// Resource is the request as "METHOD /path"
+	Resource string
+
+	// URL and Description come from the route's resource info
+	URL         string
+	Description string
+
+	// Options lists every accepted payment, in the route's order
+	Options []PaywallOption
+
+	// Price is the formatted price of the first option
+	Price string
+
+	// PaymentRequired is the full x402 challenge, and PaymentRequiredHeader its encoded
+	// PAYMENT-REQUIRED header for wallet scripts on the page
+	PaymentRequired       x402types.PaymentRequired
+	PaymentRequiredHeader string
+
+	// AppName, AppLogo, and Testnet come from the middleware's PaywallConfig
+	AppName string
+	AppLogo string
+	Testnet bool
+
+	// Theme is the branding set with WithPaywallTheme, or nil, and ProductImage its image for
+	// this route
+	Theme        *PaywallTheme
+	ProductImage string
+
+	// FiatCheckout is the card checkout offered with WithFiatFallback, or nil
+	FiatCheckout *FiatCheckout
+
+	// WalletCheckout is the phone wallet checkout offered with WithWalletPayments, or nil, and
+	// WalletWidget its QR code, links, and status polling script, ready to place on the page
+	WalletCheckout *WalletCheckout
+	WalletWidget   htmltemplate.HTML
+
+	// TestnetGuide is the testnet guidance from WithDevPaywall, or nil, and TestnetWidget its
+	// faucet links and test wallet button, ready to place on the page
+	TestnetGuide  *TestnetGuide
+	TestnetWidget htmltemplate.HTML
+
+	// Tenant is the tenant being paid with WithTenants, or nil
+	Tenant *Tenant
+
+	// Vars holds the variables set with WithPaywallVars
+	Vars map[string]interface{}
+
+	// Locale is the buyer's locale with WithLocalizer, or ""
+	Locale string
+
+	// Localizer translates with T, or is nil without WithLocalizer
+	Localizer *Localizer
+}
+
+// T translates message into the buyer's locale, formatted with args: {{.T "Pay %s" .Price}}.
+// Without a Localizer, message is only formatted.
+func (d PaywallData) T(message string, args ...interface{}) string {
+	return d.Localizer.Translate(d.Locale, message, args...)
+}
+
+// PaywallTheme brands x402's built-in paywall page without replacing it. Empty fields keep
+// the page's defaults. Colors and fonts are CSS values, e.g. "#0f766e" or "Inter, sans-serif".
+type PaywallTheme struct {
+	// Logo is the logo's URL, replacing PaywallConfig.AppLogo
+	Logo string `json:"logo,omitempty"`
+
+	// PrimaryColor colors the amount, links, and payment widget border
+	PrimaryColor string `json:"primaryColor,omitempty"`
+
+	// BackgroundColor is the page background, and SurfaceColor the card holding the content
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	SurfaceColor    string `json:"surfaceColor,omitempty"`
+
+	TextColor  string `json:"textColor,omitempty"`
+	FontFamily string `json:"fontFamily,omitempty"`
+
+	// ProductImage is shown under the heading on every route
+	ProductImage string `json:"productImage,omitempty"`
+
+	// ProductImages overrides ProductImage per route pattern, e.g. "GET /reports/*"
+	ProductImages map[string]string `json:"productImages,omitempty"`
+
+	// FooterLinks are shown at the bottom of the page, e.g. terms, privacy, and support
+	FooterLinks []PaywallLink `json:"footerLinks,omitempty"`
+}
+
+// PaywallLink is a link in the paywall footer
+type PaywallLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// ProductImageFor returns the product image for resource ("METHOD /path"), or "" if none
+func (t *PaywallTheme) ProductImageFor(resource string) string {
+	var matched string
+	for pattern := range t.ProductImages {
+		// The longest matching pattern is the most specific
+		if ParseRoutePattern(pattern).Matches(resource) && len(pattern) > len(matched) {
+			matched = pattern
+		}
+	}
+	if matched != "" {
+		return t.ProductImages[matched]
+	}
+	return t.ProductImage
+}
+
+// PaywallRenderer renders the page browsers see instead of x402's built-in paywall
+type PaywallRenderer interface {
+	RenderPaywall(ctx context.Context, w io.Writer, data PaywallData) error
+}
+
+// PaywallRendererFunc adapts a function to PaywallRenderer
+type PaywallRendererFunc func(ctx context.Context, w io.Writer, data PaywallData) error
+
+// RenderPaywall calls f
+func (f PaywallRendererFunc) RenderPaywall(ctx context.Context, w io.Writer, data PaywallData) error {
+	return f(ctx, w, data)
+}
+
+// PaywallTemplate renders a paywall from an html/template, with TemplateFuncs available:
+//
+//	paywall, err := xtended402.ParsePaywallTemplate(`<h1>{{.Vars.brand}}</h1>
+//	<p>{{.Description}} costs {{.Price}}</p>
+//	{{with .FiatCheckout}}<a href="{{.URL}}">Pay by card</a>{{end}}`,
+//		xtended402.WithPaywallVars(map[string]interface{}{"brand": "Acme"}))
+type PaywallTemplate struct {
+	template *htmltemplate.Template
+	vars     map[string]interface{}
+}
+
+// PaywallTemplateOption configures a PaywallTemplate
+type PaywallTemplateOption func(*PaywallTemplate)
+
+// WithPaywallVars sets fixed variables available as .Vars, e.g. brand colors or support links
+func WithPaywallVars(vars map[string]interface{}) PaywallTemplateOption {
+	return func(t *PaywallTemplate) {
+		t.vars = vars
+	}
+}
+
+// ParsePaywallTemplate parses an html/template paywall page
+func ParsePaywallTemplate(text string, opts ...PaywallTemplateOption) (*PaywallTemplate, error) {
+	tmpl, err := htmltemplate.New("paywall").Funcs(htmltemplate.FuncMap(TemplateFuncs)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return NewPaywallTemplate(tmpl, opts...), nil
+}
+
+// NewPaywallTemplate creates a PaywallTemplate from a parsed template, e.g. one loaded with
+// html/template's ParseFS alongside its partials
+func NewPaywallTemplate(tmpl *htmltemplate.Template, opts ...PaywallTemplateOption) *PaywallTemplate {
+	t := &PaywallTemplate{template: tmpl}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// RenderPaywall executes the template with data
+func (t *PaywallTemplate) RenderPaywall(ctx context.Context, w io.Writer, data PaywallData) error {
+	if data.Vars == nil {
+		data.Vars = t.vars
+	}
+	return t.template.Execute(w, data)
+}
+
+// NewPaywallData builds the data for a paywall from a 402 challenge
+func NewPaywallData(resource string, required x402types.PaymentRequired, header string) PaywallData {
+	data := PaywallData{
+		Resource:              resource,
+		PaymentRequired:       required,
+		PaymentRequiredHeader: header,
+	}
+	if required.Resource != nil {
+		data.URL = required.Resource.URL
+		data.Description = required.Resource.Description
+	}
+	for _, requirements := range required.Accepts {
+		data.Options = append(data.Options, PaywallOption{
+			Requirements: requirements,
+			Amount:       FormatTokenAmount(x402.Network(requirements.Network), requirements.Asset, requirements.Amount),
+			Network:      x402.Network(requirements.Network),
+			PayTo:        requirements.PayTo,
+		})
+	}
+	if len(data.Options) > 0 {
+		data.Price = data.Options[0].Amount
+	}
+	return data
+}
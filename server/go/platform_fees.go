@@ -0,0 +1,113 @@
+package xtended402
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// PlatformFeeMetadataKey is the event metadata key carrying the platform's fee on a payment,
+	// in the asset's smallest unit
+	PlatformFeeMetadataKey = "platformFee"
+
+	// NetAmountMetadataKey is the event metadata key carrying what's left of a payment after the
+	// platform's fee, in the asset's smallest unit
+	NetAmountMetadataKey = "netAmount"
+
+	// PlatformFeePayToMetadataKey is the event metadata key carrying the address the fee is paid
+	// out to, when it's paid out
+	PlatformFeePayToMetadataKey = "platformFeePayTo"
+)
+
+// PlatformFee is a marketplace's or platform's cut of each payment: a share of the amount, a
+// flat amount, or both. Fees apply to token payments; card payments are left as they are.
+type PlatformFee struct {
+	// BasisPoints is the share of each payment, in hundredths of a percent (250 is 2.5%).
+	// Fractions of the smallest unit are rounded down.
+	BasisPoints int64
+
+	// Flat is a fixed fee per payment in the asset's smallest unit, e.g. "10000" for 0.01 USDC
+	Flat string
+
+	// PayTo receives the fee when it's paid out with PayPlatformFees ("" only records it)
+	PayTo string
+}
+
+// Split divides amount (in the asset's smallest unit) into the fee and the net amount. The fee
+// never exceeds the amount. Returns ok false for amounts that aren't whole numbers.
+func (f PlatformFee) Split(amount string) (fee, net string, ok bool) {
+	gross, ok := new(big.Int).SetString(amount, 10)
+	if !ok || gross.Sign() < 0 {
+		return "", "", false
+	}
+
+	cut := new(big.Int).Mul(gross, big.NewInt(f.BasisPoints))
+	cut.Quo(cut, big.NewInt(10000))
+	if flat, ok := new(big.Int).SetString(f.Flat, 10); ok {
+		cut.Add(cut, flat)
+	}
+	if cut.Sign() < 0 {
+		cut.SetInt64(0)
+	}
+	if cut.Cmp(gross) > 0 {
+		cut.Set(gross)
+	}
+	return cut.String(), new(big.Int).Sub(gross, cut).String(), true
+}
+
+// WithPlatformFee returns event with the fee on its amount and the net amount in its metadata.
+// Events for card payments, or with amounts that aren't whole numbers, are returned as they are.
+func WithPlatformFee(event PaymentEvent, fee PlatformFee) PaymentEvent {
+	if event.Network == FiatNetwork {
+		return event
+	}
+	cut, net, ok := fee.Split(event.Amount)
+	if !ok {
+		return event
+	}
+
+	metadata := make(map[string]string, len(event.Metadata)+3)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[PlatformFeeMetadataKey] = cut
+	metadata[NetAmountMetadataKey] = net
+	if fee.PayTo != "" {
+		metadata[PlatformFeePayToMetadataKey] = fee.PayTo
+	}
+	event.Metadata = metadata
+	return event
+}
+
+// FeePayer pays a payment's platform fee (in the asset's smallest unit) out to payTo. The
+// library doesn't move funds itself.
+type FeePayer interface {
+	PayFee(ctx context.Context, event PaymentEvent, fee, payTo string) error
+}
+
+// FeePayerFunc adapts a function to FeePayer
+type FeePayerFunc func(ctx context.Context, event PaymentEvent, fee, payTo string) error
+
+// PayFee calls f
+func (f FeePayerFunc) PayFee(ctx context.Context, event PaymentEvent, fee, payTo string) error {
+	return f(ctx, event, fee, payTo)
+}
+
+// PayPlatformFees returns a PaymentEventHandler paying out the fee of every settled payment
+// whose metadata names a fee address (see PlatformFee.PayTo). Payouts run on the request path,
+// so payer should be fast, e.g. queue the transfer; failures are logged.
+func PayPlatformFees(payer FeePayer) PaymentEventHandler {
+	return func(ctx context.Context, event PaymentEvent) {
+		if event.Type != EventPaymentSettled {
+			return
+		}
+		fee, payTo := event.Metadata[PlatformFeeMetadataKey], event.Metadata[PlatformFeePayToMetadataKey]
+		if payTo == "" || fee == "" || fee == "0" {
+			return
+		}
+		if err := payer.PayFee(ctx, event, fee, payTo); err != nil {
+			fmt.Printf("Warning: platform fee of payment %s not paid out: %v\n", event.ID, err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package xtended402
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// PriceCache remembers price conversions so identical prices and carts aren't converted again
+// on every request: a scheme's money parser results, per price and network, and dynamic price
+// resolutions, per caller-chosen key. Entries expire after a TTL and can be invalidated early,
+// e.g. when exchange rates or the catalog change.
+type PriceCache struct {
+	ttl        time.Duration
+	maxEntries int
+	clock      Clock
+
+	mu      sync.Mutex
+	entries map[string]priceCacheEntry
+	hits    uint64
+	misses  uint64
+}
+
+type priceCacheEntry struct {
+	network x402.Network
+	value   interface{}
+	expires time.Time
+}
+
+// PriceCacheOption configures a PriceCache
+type PriceCacheOption func(*PriceCache)
+
+// WithPriceCacheTTL sets how long a conversion is reused (default 1m)
+func WithPriceCacheTTL(ttl time.Duration) PriceCacheOption {
+	return func(p *PriceCache) {
+		p.ttl = ttl
+	}
+}
+
+// WithMaxCachedPrices bounds the number of cached conversions (default 10000). When full,
+// expired entries are dropped, and new conversions aren't cached until there's room.
+func WithMaxCachedPrices(n int) PriceCacheOption {
+	return func(p *PriceCache) {
+		p.maxEntries = n
+	}
+}
+
+// WithPriceCacheClock sets the clock used to expire conversions (default SystemClock)
+func WithPriceCacheClock(clock Clock) PriceCacheOption {
+	return func(p *PriceCache) {
+		p.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewPriceCache creates an empty PriceCache
+func NewPriceCache(opts ...PriceCacheOption) *PriceCache {
+	p := &PriceCache{
+		ttl:        time.Minute,
+		maxEntries: 10000,
+		clock:      SystemClock,
+		entries:    make(map[string]priceCacheEntry),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Scheme wraps a scheme server so its ParsePrice results, including custom money parsers, are
+// cached per scheme, network, and price. Register the wrapper in place of the scheme:
+//
+//	server := x402.Newx402ResourceServer(
+//		x402.WithSchemeServer("eip155:8453", prices.Scheme(evm.NewExactEvmScheme())),
+//	)
+func (p *PriceCache) Scheme(scheme x402.SchemeNetworkServer) x402.SchemeNetworkServer {
+	return &cachedScheme{SchemeNetworkServer: scheme, cache: p}
+}
+
+type cachedScheme struct {
+	x402.SchemeNetworkServer
+	cache *PriceCache
+}
+
+func (s *cachedScheme) ParsePrice(price x402.Price, network x402.Network) (x402.AssetAmount, error) {
+	money, ok := moneyKey(price)
+	if !ok {
+		// Asset amounts need no conversion
+		return s.SchemeNetworkServer.ParsePrice(price, network)
+	}
+
+	key := fmt.Sprintf("parse|%s|%s|%s", s.Scheme(), network, money)
+	if cached, ok := s.cache.get(key); ok {
+		return copyAssetAmount(cached.(x402.AssetAmount)), nil
+	}
+
+	amount, err := s.SchemeNetworkServer.ParsePrice(price, network)
+	if err != nil {
+		return amount, err
+	}
+	s.cache.put(key, network, copyAssetAmount(amount))
+	return amount, nil
+}
+
+// DynamicPrice caches price's resolutions under the key key returns for a request, e.g. a hash
+// of the cart. When key returns false, or price resolves to anything but a money amount (a
+// string or number), the request isn't cached. Errors are never cached.
+func (p *PriceCache) DynamicPrice(key func(context.Context, x402http.HTTPRequestContext) (string, bool), price x402http.DynamicPriceFunc) x402http.DynamicPriceFunc {
+	return func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (x402.Price, error) {
+		requestKey, ok := key(ctx, reqCtx)
+		if !ok {
+			return price(ctx, reqCtx)
+		}
+
+		cacheKey := "dynamic|" + requestKey
+		if cached, ok := p.get(cacheKey); ok {
+			return cached, nil
+		}
+
+		resolved, err := price(ctx, reqCtx)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := moneyKey(resolved); ok {
+			p.put(cacheKey, "", resolved)
+		}
+		return resolved, nil
+	}
+}
+
+// Invalidate drops the dynamic price cached under key
+func (p *PriceCache) Invalidate(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.entries, "dynamic|"+key)
+}
+
+// InvalidateNetwork drops the conversions cached for network, e.g. after its token's rate moves
+func (p *PriceCache) InvalidateNetwork(network x402.Network) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, entry := range p.entries {
+		if entry.network == network {
+			delete(p.entries, key)
+		}
+	}
+}
+
+// Purge drops every cached conversion
+func (p *PriceCache) Purge() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = make(map[string]priceCacheEntry)
+}
+
+// PriceCacheStats counts a PriceCache's lookups, for hit-rate metrics
+type PriceCacheStats struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// Stats returns the cache's size and lookup counts
+func (p *PriceCache) Stats() PriceCacheStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PriceCacheStats{Entries: len(p.entries), Hits: p.hits, Misses: p.misses}
+}
+
+func (p *PriceCache) get(key string) (interface{}, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.entries[key]
+	if ok && p.clock.Now().Before(entry.expires) {
+		p.hits++
+		return entry.value, true
+	}
+	if ok {
+		delete(p.entries, key)
+	}
+	p.misses++
+	return nil, false
+}
+
+func (p *PriceCache) put(key string, network x402.Network, value interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	if _, exists := p.entries[key]; !exists && len(p.entries) >= p.maxEntries {
+		for k, entry := range p.entries {
+			if !now.Before(entry.expires) {
+				delete(p.entries, k)
+			}
+		}
+		if len(p.entries) >= p.maxEntries {
+			return
+		}
+	}
+	p.entries[key] = priceCacheEntry{network: network, value: value, expires: now.Add(p.ttl)}
+}
+
+// moneyKey formats a money price (a string or number) for a cache key. Other prices, such as
+// asset amounts, aren't cached.
+func moneyKey(price x402.Price) (string, bool) {
+	switch v := price.(type) {
+	case string:
+		return "s:" + v, true
+	case float64, int, int64:
+		return fmt.Sprintf("n:%v", v), true
+	}
+	return "", false
+}
+
+// copyAssetAmount copies amount's Extra, which requirement building writes to, so cached
+// amounts aren't shared between requests
+func copyAssetAmount(amount x402.AssetAmount) x402.AssetAmount {
+	if amount.Extra != nil {
+		extra := make(map[string]interface{}, len(amount.Extra))
+		for k, v := range amount.Extra {
+			extra[k] = v
+		}
+		amount.Extra = extra
+	}
+	return amount
+}
@@ -0,0 +1,208 @@
+package xtended402
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+)
+
+// PriceOracle values tokens in fiat currencies
+type PriceOracle interface {
+	// Rate returns the value of one whole token (e.g. 1 USDC) in currency (e.g. "USD") at
+	// time at
+	Rate(ctx context.Context, symbol, currency string, at time.Time) (*big.Rat, error)
+}
+
+// FiatValue values amount (in smallest units) of asset on network in currency, using oracle's
+// rate at time at. Card payments (FiatNetwork) are converted only when their currency differs,
+// using the oracle's rate for the currency pair.
+func FiatValue(ctx context.Context, oracle PriceOracle, network x402.Network, asset, amount, currency string, at time.Time) (*big.Rat, error) {
+	if network == FiatNetwork {
+		value, ok := new(big.Rat).SetString(amount)
+		if !ok {
+			return nil, fmt.Errorf("invalid amount %q", amount)
+		}
+		if strings.EqualFold(asset, currency) {
+			return value, nil
+		}
+		rate, err := oracle.Rate(ctx, asset, currency, at)
+		if err != nil {
+			return nil, err
+		}
+		return value.Mul(value, rate), nil
+	}
+
+	symbol, decimals, ok := LookupToken(network, asset)
+	if !ok {
+		return nil, fmt.Errorf("unknown token %s on %s", asset, network)
+	}
+
+	units, ok := new(big.Rat).SetString(amount)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	rate, err := oracle.Rate(ctx, symbol, currency, at)
+	if err != nil {
+		return nil, err
+	}
+
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	value := new(big.Rat).Quo(units, new(big.Rat).SetInt(unit))
+	return value.Mul(value, rate), nil
+}
+
+// StaticPriceOracle values tokens at fixed rates, such as stablecoins at par
+type StaticPriceOracle struct {
+	rates map[string]*big.Rat
+}
+
+// NewStaticPriceOracle creates a StaticPriceOracle from rates keyed "SYMBOL/CURRENCY", e.g.
+// {"USDC/USD": "1", "USDC/EUR": "0.92"}
+func NewStaticPriceOracle(rates map[string]string) (*StaticPriceOracle, error) {
+	o := &StaticPriceOracle{rates: make(map[string]*big.Rat, len(rates))}
+	for pair, rate := range rates {
+		symbol, currency, ok := strings.Cut(pair, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate pair %q (want SYMBOL/CURRENCY)", pair)
+		}
+		value, ok := new(big.Rat).SetString(rate)
+		if !ok {
+			return nil, fmt.Errorf("invalid rate %q for %s", rate, pair)
+		}
+		o.rates[ratePair(symbol, currency)] = value
+	}
+	return o, nil
+}
+
+// NewStablecoinPriceOracle values USDC at 1 USD and EURC at 1 EUR
+func NewStablecoinPriceOracle() *StaticPriceOracle {
+	o, _ := NewStaticPriceOracle(map[string]string{"USDC/USD": "1", "EURC/EUR": "1"})
+	return o
+}
+
+// Rate returns the configured rate, ignoring at
+func (o *StaticPriceOracle) Rate(ctx context.Context, symbol, currency string, at time.Time) (*big.Rat, error) {
+	rate, ok := o.rates[ratePair(symbol, currency)]
+	if !ok {
+		return nil, fmt.Errorf("no rate for %s/%s", symbol, currency)
+	}
+	return new(big.Rat).Set(rate), nil
+}
+
+// CoinbasePriceEndpoint is the Coinbase public price API
+const CoinbasePriceEndpoint = "https://api.coinbase.com/v2"
+
+// CoinbasePriceOracle looks up daily spot prices from the Coinbase price API. Rates are cached
+// per pair and UTC day, so valuing many payments makes one request per day.
+type CoinbasePriceOracle struct {
+	endpoint string
+	client   *http.Client
+
+	mu    sync.Mutex
+	rates map[string]*big.Rat
+}
+
+// CoinbaseOracleOption configures a CoinbasePriceOracle
+type CoinbaseOracleOption func(*CoinbasePriceOracle)
+
+// WithCoinbaseOracleEndpoint overrides the API base URL
+func WithCoinbaseOracleEndpoint(endpoint string) CoinbaseOracleOption {
+	return func(o *CoinbasePriceOracle) {
+		o.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithCoinbaseOracleHTTPClient sets the HTTP client (default 10s timeout)
+func WithCoinbaseOracleHTTPClient(client *http.Client) CoinbaseOracleOption {
+	return func(o *CoinbasePriceOracle) {
+		o.client = client
+	}
+}
+
+// NewCoinbasePriceOracle creates a CoinbasePriceOracle
+func NewCoinbasePriceOracle(opts ...CoinbaseOracleOption) *CoinbasePriceOracle {
+	o := &CoinbasePriceOracle{
+		endpoint: CoinbasePriceEndpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		rates:    make(map[string]*big.Rat),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// Rate returns the spot price of symbol in currency on at's UTC day
+func (o *CoinbasePriceOracle) Rate(ctx context.Context, symbol, currency string, at time.Time) (*big.Rat, error) {
+	pair := strings.ToUpper(symbol) + "-" + strings.ToUpper(currency)
+	date := at.UTC().Format("2006-01-02")
+	key := pair + "@" + date
+
+	o.mu.Lock()
+	rate, ok := o.rates[key]
+	o.mu.Unlock()
+	if ok {
+		return new(big.Rat).Set(rate), nil
+	}
+
+	rate, err := o.fetch(ctx, pair, date)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	o.rates[key] = rate
+	o.mu.Unlock()
+
+	return new(big.Rat).Set(rate), nil
+}
+
+func (o *CoinbasePriceOracle) fetch(ctx context.Context, pair, date string) (*big.Rat, error) {
+	u := o.endpoint + "/prices/" + url.PathEscape(pair) + "/spot?date=" + url.QueryEscape(date)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("coinbase price: %w", err)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("coinbase price request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("coinbase price for %s on %s failed (%d): %s", pair, date, resp.StatusCode, detail)
+	}
+
+	var body struct {
+		Data struct {
+			Amount string `json:"amount"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid coinbase price response: %w", err)
+	}
+
+	rate, ok := new(big.Rat).SetString(body.Data.Amount)
+	if !ok {
+		return nil, fmt.Errorf("invalid coinbase price %q for %s", body.Data.Amount, pair)
+	}
+	return rate, nil
+}
+
+func ratePair(symbol, currency string) string {
+	return strings.ToUpper(symbol) + "/" + strings.ToUpper(currency)
+}
@@ -0,0 +1,64 @@
+package xtended402
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+type queryContextKey struct{}
+
+// ContextWithQuery returns a context carrying a request's query parameters. The Gin
+// middleware and PricePreviewHandler add them for paid requests with a query.
+func ContextWithQuery(ctx context.Context, query url.Values) context.Context {
+	return context.WithValue(ctx, queryContextKey{}, query)
+}
+
+// QueryFromContext returns the query parameters in ctx, or nil
+func QueryFromContext(ctx context.Context) url.Values {
+	query, _ := ctx.Value(queryContextKey{}).(url.Values)
+	return query
+}
+
+// QueryPrice creates a DynamicPriceFunc pricing each request by its query parameters. The
+// price may be an x402.AssetAmount, so the asset can vary too:
+//
+//	Price: xtended402.QueryPrice(func(ctx context.Context, query url.Values) (x402.Price, error) {
+//		if query.Get("quality") == "hd" {
+//			return "$0.05", nil
+//		}
+//		return "$0.01", nil
+//	}),
+func QueryPrice(price func(ctx context.Context, query url.Values) (x402.Price, error)) x402http.DynamicPriceFunc {
+	return func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (x402.Price, error) {
+		query := QueryFromContext(ctx)
+		if query == nil {
+			query = url.Values{}
+		}
+		return price(ctx, query)
+	}
+}
+
+// QueryParamPrice creates a DynamicPriceFunc pricing each request by the value of the named
+// query parameter, e.g. {"sd": "$0.01", "hd": "$0.05"} for ?quality=. Requests without the
+// parameter are charged fallback. Unknown values are rejected, as are requests without the
+// parameter when fallback is nil.
+func QueryParamPrice(name string, prices map[string]x402.Price, fallback x402.Price) x402http.DynamicPriceFunc {
+	return QueryPrice(func(ctx context.Context, query url.Values) (x402.Price, error) {
+		value, ok := query[name]
+		if !ok || len(value) == 0 {
+			if fallback == nil {
+				return nil, fmt.Errorf("query parameter %q is required", name)
+			}
+			return fallback, nil
+		}
+		price, ok := prices[value[0]]
+		if !ok {
+			return nil, fmt.Errorf("query parameter %q has no price for %q", name, value[0])
+		}
+		return price, nil
+	})
+}
@@ -0,0 +1,171 @@
+// Package receipts emails payment receipts when payments settle. A Mailer renders a Template
+// for each payment.settled event and hands the message to a Sender (SMTP or SendGrid):
+//
+//	mailer := receipts.NewMailer(receipts.NewSendGridSender(os.Getenv("SENDGRID_API_KEY")),
+//		"orders@example.com",
+//		receipts.WithRecipient(receipts.RecipientFromContext(customerEmailKey)),
+//	)
+//	ginmw.PaymentMiddleware(routes, server, ginmw.WithEventHandler(mailer.Handle))
+package receipts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// Message is a rendered email
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers an email
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// RecipientFunc returns the email address to send event's receipt to, or "" to skip it.
+// ctx is the paid request's context.
+type RecipientFunc func(ctx context.Context, event xtended402.PaymentEvent) string
+
+// RecipientFromContext reads the recipient from a request context value stored with
+// xtended402.SetContextValueGin before the payment middleware runs
+func RecipientFromContext(key *xtended402.ContextKey[string]) RecipientFunc {
+	return func(ctx context.Context, event xtended402.PaymentEvent) string {
+		email, _ := key.Value(ctx)
+		return email
+	}
+}
+
+// Mailer sends a receipt for every settled payment with a known recipient
+type Mailer struct {
+	sender    Sender
+	from      string
+	template  *Template
+	templates *xtended402.EventTemplates
+	recipient RecipientFunc
+	details   func(ctx context.Context, event xtended402.PaymentEvent) interface{}
+	storeName string
+	onError   func(event xtended402.PaymentEvent, err error)
+
+	wg sync.WaitGroup
+}
+
+// Option configures a Mailer
+type Option func(*Mailer)
+
+// WithTemplate replaces the default receipt template
+func WithTemplate(template *Template) Option {
+	return func(m *Mailer) {
+		m.template = template
+	}
+}
+
+// WithEventTemplates takes the order, variables, and explorer links in receipt data from the
+// templates shared with other channels. Their per-event templates aren't used for email.
+func WithEventTemplates(templates *xtended402.EventTemplates) Option {
+	return func(m *Mailer) {
+		m.templates = templates
+	}
+}
+
+// WithRecipient sets how the recipient is found (required; events without one are skipped)
+func WithRecipient(recipient RecipientFunc) Option {
+	return func(m *Mailer) {
+		m.recipient = recipient
+	}
+}
+
+// WithDetails adds application data, such as the order, to the template data as .Details
+func WithDetails(details func(ctx context.Context, event xtended402.PaymentEvent) interface{}) Option {
+	return func(m *Mailer) {
+		m.details = details
+	}
+}
+
+// WithStoreName sets the name shown in receipts (default "Your order")
+func WithStoreName(name string) Option {
+	return func(m *Mailer) {
+		m.storeName = name
+	}
+}
+
+// WithErrorHandler is called when rendering or sending a receipt fails
+func WithErrorHandler(handler func(event xtended402.PaymentEvent, err error)) Option {
+	return func(m *Mailer) {
+		m.onError = handler
+	}
+}
+
+// NewMailer creates a Mailer sending from the given address
+func NewMailer(sender Sender, from string, opts ...Option) *Mailer {
+	m := &Mailer{
+		sender:    sender,
+		from:      from,
+		template:  DefaultTemplate(),
+		templates: xtended402.MustParseEventTemplates(nil),
+		storeName: "Your order",
+		onError: func(event xtended402.PaymentEvent, err error) {
+			fmt.Printf("Warning: receipt for %s failed: %v\n", event.ID, err)
+		},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Handle sends a receipt for payment.settled events in the background; other events are
+// ignored. Use it as a xtended402.PaymentEventHandler.
+func (m *Mailer) Handle(ctx context.Context, event xtended402.PaymentEvent) {
+	if event.Type != xtended402.EventPaymentSettled || m.recipient == nil {
+		return
+	}
+
+	to := m.recipient(ctx, event)
+	if to == "" {
+		return
+	}
+
+	data := Data{TemplateData: m.templates.Data(ctx, event), To: to, StoreName: m.storeName}
+	if m.details != nil {
+		data.Details = m.details(ctx, event)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		if err := m.Send(ctx, data); err != nil {
+			m.onError(event, err)
+		}
+	}()
+}
+
+// Send renders and sends one receipt synchronously
+func (m *Mailer) Send(ctx context.Context, data Data) error {
+	msg, err := m.template.Render(data)
+	if err != nil {
+		return err
+	}
+	msg.From = m.from
+	msg.To = data.To
+
+	if err := m.sender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send receipt to %s: %w", data.To, err)
+	}
+	return nil
+}
+
+// Wait blocks until receipts being sent finish, e.g. during graceful shutdown
+func (m *Mailer) Wait() {
+	m.wg.Wait()
+}
@@ -0,0 +1,110 @@
+package receipts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SendGridEndpoint is the SendGrid v3 mail send API
+const SendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGridSender sends mail through the SendGrid v3 API
+type SendGridSender struct {
+	apiKey   string
+	endpoint string
+	client   *http.Client
+}
+
+// SendGridOption configures a SendGridSender
+type SendGridOption func(*SendGridSender)
+
+// WithSendGridEndpoint overrides the API endpoint (e.g. for the EU region or a test server)
+func WithSendGridEndpoint(endpoint string) SendGridOption {
+	return func(s *SendGridSender) {
+		s.endpoint = endpoint
+	}
+}
+
+// WithSendGridHTTPClient sets the HTTP client (default 10s timeout)
+func WithSendGridHTTPClient(client *http.Client) SendGridOption {
+	return func(s *SendGridSender) {
+		s.client = client
+	}
+}
+
+// NewSendGridSender creates a SendGridSender authenticated with apiKey
+func NewSendGridSender(apiKey string, opts ...SendGridOption) *SendGridSender {
+	s := &SendGridSender{
+		apiKey:   apiKey,
+		endpoint: SendGridEndpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+// Send delivers msg
+func (s *SendGridSender) Send(ctx context.Context, msg Message) error {
+	request := sendGridRequest{
+		Personalizations: []sendGridPersonalization{{To: []sendGridAddress{{Email: msg.To}}}},
+		From:             sendGridAddress{Email: msg.From},
+		Subject:          msg.Subject,
+		Content:          []sendGridContent{{Type: "text/plain", Value: msg.Text}},
+	}
+	if msg.HTML != "" {
+		request.Content = append(request.Content, sendGridContent{Type: "text/html", Value: msg.HTML})
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("sendgrid: failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("sendgrid: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sendgrid: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("sendgrid: send failed (%d): %s", resp.StatusCode, detail)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package receipts
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig locates an SMTP server
+type SMTPConfig struct {
+	Host string
+	Port int // default 587
+
+	// Username and Password enable PLAIN auth (requires TLS unless Host is localhost)
+	Username string
+	Password string
+}
+
+// SMTPSender sends mail through an SMTP server, upgrading to TLS with STARTTLS when offered
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+// NewSMTPSender creates an SMTPSender
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	return &SMTPSender{config: config}
+}
+
+// Send delivers msg. The SMTP exchange can't be interrupted, so ctx is only checked up front.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	if err := smtp.SendMail(addr, auth, msg.From, []string{msg.To}, buildMIME(msg)); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return nil
+}
+
+// buildMIME encodes msg as a MIME message, multipart/alternative when it has an HTML body
+func buildMIME(msg Message) []byte {
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+
+	header("From", msg.From)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		writeQuotedPrintable(&buf, msg.Text)
+		return buf.Bytes()
+	}
+
+	boundary := newBoundary()
+	header("Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, boundary))
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		header("Content-Type", part.contentType+`; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		writeQuotedPrintable(&buf, part.body)
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes()
+}
+
+func writeQuotedPrintable(buf *bytes.Buffer, body string) {
+	w := quotedprintable.NewWriter(buf)
+	_, _ = w.Write([]byte(body))
+	_ = w.Close()
+}
+
+func newBoundary() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "receipt-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,128 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// Data is what receipt templates render. The shared template variables are embedded, so
+// {{.Amount}}, {{.TxURL}}, {{.Order}}, and {{.Vars}} work as in other channels, alongside
+// {{.Event}}.
+type Data struct {
+	xtended402.TemplateData
+
+	// To is the recipient's address
+	To string
+
+	// StoreName is set with WithStoreName
+	StoreName string
+
+	// Details is application data set with WithDetails, such as the order
+	Details interface{}
+}
+
+// Template renders a receipt's subject, plain-text body, and HTML body
+type Template struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// ParseTemplate parses a receipt template. Subject and text use text/template; html uses
+// html/template and may be empty for plain-text receipts.
+func ParseTemplate(subject, text, html string) (*Template, error) {
+	t := &Template{}
+	var err error
+
+	if t.subject, err = texttemplate.New("subject").Funcs(xtended402.TemplateFuncs).Parse(subject); err != nil {
+		return nil, fmt.Errorf("invalid subject template: %w", err)
+	}
+	if t.text, err = texttemplate.New("text").Funcs(xtended402.TemplateFuncs).Parse(text); err != nil {
+		return nil, fmt.Errorf("invalid text template: %w", err)
+	}
+	if html != "" {
+		if t.html, err = htmltemplate.New("html").Funcs(htmltemplate.FuncMap(xtended402.TemplateFuncs)).Parse(html); err != nil {
+			return nil, fmt.Errorf("invalid HTML template: %w", err)
+		}
+	}
+
+	return t, nil
+}
+
+// MustParseTemplate is like ParseTemplate but panics on an invalid template
+func MustParseTemplate(subject, text, html string) *Template {
+	t, err := ParseTemplate(subject, text, html)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// DefaultTemplate is a plain order confirmation with the amount, payer, and transaction
+func DefaultTemplate() *Template {
+	return MustParseTemplate(defaultSubject, defaultText, defaultHTML)
+}
+
+// Render produces the message for data (From and To are left to the Mailer)
+func (t *Template) Render(data Data) (Message, error) {
+	var msg Message
+
+	var buf bytes.Buffer
+	if err := t.subject.Execute(&buf, data); err != nil {
+		return msg, fmt.Errorf("failed to render receipt subject: %w", err)
+	}
+	msg.Subject = buf.String()
+
+	buf.Reset()
+	if err := t.text.Execute(&buf, data); err != nil {
+		return msg, fmt.Errorf("failed to render receipt text: %w", err)
+	}
+	msg.Text = buf.String()
+
+	if t.html != nil {
+		buf.Reset()
+		if err := t.html.Execute(&buf, data); err != nil {
+			return msg, fmt.Errorf("failed to render receipt HTML: %w", err)
+		}
+		msg.HTML = buf.String()
+	}
+
+	return msg, nil
+}
+
+const defaultSubject = `{{.StoreName}}: payment received ({{.Amount}})`
+
+const defaultText = `Thanks for your purchase!
+
+We received your payment of {{.Amount}}.
+
+Paid from:   {{if .PayerName}}{{.PayerName}} ({{.Event.Payer}}){{else}}{{.Event.Payer}}{{end}}
+Network:     {{.Event.Network}}
+Transaction: {{.Event.Transaction}}
+Date:        {{.Event.CreatedAt.Format "2006-01-02 15:04 MST"}}
+Reference:   {{.Event.ID}}
+{{- if .TxURL}}
+
+View the transaction: {{.TxURL}}
+{{- end}}
+`
+
+const defaultHTML = `<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; color: #222;">
+  <h2>Thanks for your purchase!</h2>
+  <p>We received your payment of <strong>{{.Amount}}</strong>.</p>
+  <table cellpadding="4">
+    <tr><td>Paid from</td><td>{{if .PayerName}}{{.PayerName}} {{end}}<code>{{.Event.Payer}}</code></td></tr>
+    <tr><td>Network</td><td>{{.Event.Network}}</td></tr>
+    <tr><td>Transaction</td><td>{{if .TxURL}}<a href="{{.TxURL}}"><code>{{.Event.Transaction}}</code></a>{{else}}<code>{{.Event.Transaction}}</code>{{end}}</td></tr>
+    <tr><td>Date</td><td>{{.Event.CreatedAt.Format "2006-01-02 15:04 MST"}}</td></tr>
+    <tr><td>Reference</td><td>{{.Event.ID}}</td></tr>
+  </table>
+</body>
+</html>
+`
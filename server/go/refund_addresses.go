@@ -0,0 +1,123 @@
+package xtended402
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RefundAddressExtensionKey is the route extension asking payers for a refund address, and the
+// payment payload extension carrying it. Set the route's with RouteWithRefundAddress; it's sent
+// in the 402 as {"required": bool}.
+const RefundAddressExtensionKey = "refundAddress"
+
+// RefundAddressMetadataKey is the event metadata key carrying the address a payment asked to
+// be refunded to
+const RefundAddressMetadataKey = "refundAddress"
+
+// ErrRefundAddressInvalid is returned for a refund address that isn't an address on the
+// payment's network, or a missing one a route requires
+var ErrRefundAddressInvalid = errors.New("refund address invalid")
+
+// solanaAddress matches a base58 public key
+var solanaAddress = regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{32,44}$`)
+
+// RefundAddressRequest asks payers where refunds should go, for payments whose payer can't
+// receive them: smart accounts without the token's receive logic, or exchange hot wallets that
+// don't credit the customer
+type RefundAddressRequest struct {
+	// Required rejects payments without a refund address. Otherwise they're refunded to the
+	// payer.
+	Required bool `json:"required"`
+}
+
+// RouteWithRefundAddress returns a copy of route asking payers for a refund address
+func RouteWithRefundAddress(route x402http.RouteConfig, required bool) x402http.RouteConfig {
+	extensions := make(map[string]interface{}, len(route.Extensions)+1)
+	for key, value := range route.Extensions {
+		extensions[key] = value
+	}
+	extensions[RefundAddressExtensionKey] = RefundAddressRequest{Required: required}
+	route.Extensions = extensions
+	return route
+}
+
+// RouteRefundAddress returns the refund address request declared by route, or nil
+func RouteRefundAddress(route x402http.RouteConfig) *RefundAddressRequest {
+	switch request := route.Extensions[RefundAddressExtensionKey].(type) {
+	case RefundAddressRequest:
+		return &request
+	case *RefundAddressRequest:
+		return request
+	case map[string]interface{}:
+		// Declared in a config file
+		data, err := json.Marshal(request)
+		if err != nil {
+			return nil
+		}
+		var parsed RefundAddressRequest
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return nil
+		}
+		return &parsed
+	}
+	return nil
+}
+
+// ResourceRefundAddress returns the refund address request declared by the route in routes
+// matching resource ("METHOD /path"), or nil
+func ResourceRefundAddress(routes x402http.RoutesConfig, resource string) *RefundAddressRequest {
+	for pattern, route := range routes {
+		if ParseRoutePattern(pattern).Matches(resource) {
+			return RouteRefundAddress(route)
+		}
+	}
+	return nil
+}
+
+// RefundAddressOf returns the refund address payload carries in its extensions, or ""
+func RefundAddressOf(payload *x402types.PaymentPayload) string {
+	if payload == nil {
+		return ""
+	}
+	address, _ := payload.Extensions[RefundAddressExtensionKey].(string)
+	return strings.TrimSpace(address)
+}
+
+// CheckRefundAddress checks address against network's address format
+func CheckRefundAddress(network x402.Network, address string) error {
+	switch {
+	case strings.HasPrefix(string(network), "eip155:"):
+		if !common.IsHexAddress(address) || common.HexToAddress(address) == (common.Address{}) {
+			return fmt.Errorf("%w: %q is not an EVM address", ErrRefundAddressInvalid, address)
+		}
+	case strings.HasPrefix(string(network), "solana:"):
+		if !solanaAddress.MatchString(address) {
+			return fmt.Errorf("%w: %q is not a Solana address", ErrRefundAddressInvalid, address)
+		}
+	case address == "":
+		return fmt.Errorf("%w: empty address", ErrRefundAddressInvalid)
+	}
+	return nil
+}
+
+// RefundAddress returns the address the payment asked to be refunded to, or ""
+func (p *PaymentData) RefundAddress() string {
+	return RefundAddressOf(p.PaymentPayload)
+}
+
+// RefundTo returns where refunds of the payment go: the refund address it was paid with, or
+// else the payer
+func (r *PaymentRecord) RefundTo() string {
+	if address := r.Metadata[RefundAddressMetadataKey]; address != "" {
+		return address
+	}
+	return r.Payer
+}
@@ -0,0 +1,94 @@
+package xtended402
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// ErrExtrasMismatch is returned when the requirements a payment accepted don't carry the extra
+// fields generated for the request, or the extras validator rejects them
+var ErrExtrasMismatch = errors.New("requirement extras mismatch")
+
+// ExtrasFunc returns extra fields for a request's payment requirements, e.g. the SKU or order
+// reference being paid for
+type ExtrasFunc func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (map[string]interface{}, error)
+
+// ExtrasValidator checks the extra fields of the requirements a payment accepted, as the client
+// echoed them
+type ExtrasValidator func(ctx context.Context, extra map[string]interface{}) error
+
+// RequirementExtras attaches app-specific fields to a route's payment requirements, in their
+// "extra" object. Clients echo the requirements they pay for, so the fields come back with the
+// payment, where they're checked against the ones generated for the request.
+type RequirementExtras struct {
+	// Static fields are added to every request's requirements
+	Static map[string]interface{}
+
+	// Func adds per-request fields, overriding Static ones with the same name
+	Func ExtrasFunc
+
+	// Validate checks the echoed extras once the generated fields match, e.g. that an order
+	// reference is still open
+	Validate ExtrasValidator
+}
+
+// Resolve returns the extra fields for a request, as they read once encoded to JSON
+func (e RequirementExtras) Resolve(ctx context.Context, reqCtx x402http.HTTPRequestContext) (map[string]interface{}, error) {
+	extras := make(map[string]interface{}, len(e.Static))
+	for key, value := range e.Static {
+		extras[key] = value
+	}
+	if e.Func != nil {
+		dynamic, err := e.Func(ctx, reqCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve requirement extras: %w", err)
+		}
+		for key, value := range dynamic {
+			extras[key] = value
+		}
+	}
+
+	// Echoed extras are decoded from JSON, so compare against the same form
+	data, err := json.Marshal(extras)
+	if err != nil {
+		return nil, fmt.Errorf("invalid requirement extras: %w", err)
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, fmt.Errorf("invalid requirement extras: %w", err)
+	}
+	return normalized, nil
+}
+
+// WithExtras returns a copy of requirements with extras added to its extra fields
+func WithExtras(requirements x402types.PaymentRequirements, extras map[string]interface{}) x402types.PaymentRequirements {
+	extra := make(map[string]interface{}, len(requirements.Extra)+len(extras))
+	for key, value := range requirements.Extra {
+		extra[key] = value
+	}
+	for key, value := range extras {
+		extra[key] = value
+	}
+	requirements.Extra = extra
+	return requirements
+}
+
+// CheckEchoedExtras checks that echoed carries every field of extras unchanged
+func CheckEchoedExtras(extras, echoed map[string]interface{}) error {
+	for key, value := range extras {
+		got, ok := echoed[key]
+		if !ok {
+			return fmt.Errorf("%w: %q is missing", ErrExtrasMismatch, key)
+		}
+		if !reflect.DeepEqual(got, value) {
+			return fmt.Errorf("%w: %q is %v, not %v", ErrExtrasMismatch, key, got, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package xtended402
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// CatalogSample is a representative request for a route with a dynamic price, such as a
+// typical cart, used to quote what the route costs
+type CatalogSample struct {
+	// Label describes the sample to buyers, e.g. "1 item"
+	Label string
+
+	// Path is a concrete request path, e.g. "/api/weather/paris" (default the route's path). A
+	// query, e.g. "/api/video?quality=hd", reaches pricing as the request's query parameters.
+	Path string
+
+	Headers map[string]string
+
+	// Context holds request context values pricing reads, as set by SetContextValueGin, e.g.
+	// PriceKey.Bind("$5.00") for ContextPrice(PriceKey)
+	Context []ContextValue
+}
+
+// CatalogQuote is a dynamic payment option resolved for a CatalogSample
+type CatalogQuote struct {
+	Label         string `json:"label,omitempty"`
+	Path          string `json:"path"`
+	Asset         string `json:"asset"`
+	Amount        string `json:"amount"`
+	DisplayAmount string `json:"displayAmount,omitempty"`
+	PayTo         string `json:"payTo,omitempty"`
+}
+
+// ResourceCatalog is a Catalog of paid routes whose dynamic prices are quoted by running them
+// for representative requests, for marketplaces indexing the server. Catalogs are rebuilt at
+// most once per TTL, so indexers polling it don't each run the pricing functions.
+type ResourceCatalog struct {
+	routes       x402http.RoutesConfig
+	server       *x402.X402ResourceServer
+	samples      map[string][]CatalogSample
+	ttl          time.Duration
+	quoteTimeout time.Duration
+	clock        Clock
+
+	build   sync.Mutex
+	mu      sync.Mutex
+	catalog *Catalog
+	builtAt time.Time
+}
+
+// ResourceCatalogOption configures a ResourceCatalog
+type ResourceCatalogOption func(*ResourceCatalog)
+
+// WithCatalogSamples quotes the route pattern's dynamic prices for samples. Routes without
+// samples are listed with their dynamic prices flagged but unquoted.
+func WithCatalogSamples(pattern string, samples ...CatalogSample) ResourceCatalogOption {
+	return func(r *ResourceCatalog) {
+		r.samples[pattern] = append(r.samples[pattern], samples...)
+	}
+}
+
+// WithCatalogTTL sets how long a built catalog is served before it's rebuilt (default 5m)
+func WithCatalogTTL(ttl time.Duration) ResourceCatalogOption {
+	return func(r *ResourceCatalog) {
+		r.ttl = ttl
+	}
+}
+
+// WithQuoteTimeout bounds each pricing function run for a sample (default 5s)
+func WithQuoteTimeout(d time.Duration) ResourceCatalogOption {
+	return func(r *ResourceCatalog) {
+		r.quoteTimeout = d
+	}
+}
+
+// WithCatalogClock sets the clock used to age catalogs (default SystemClock)
+func WithCatalogClock(clock Clock) ResourceCatalogOption {
+	return func(r *ResourceCatalog) {
+		r.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewResourceCatalog creates a ResourceCatalog of routes, resolving prices through server. The
+// server must be the one the payment middleware uses, so facilitator capabilities are synced.
+func NewResourceCatalog(routes x402http.RoutesConfig, server *x402.X402ResourceServer, opts ...ResourceCatalogOption) *ResourceCatalog {
+	r := &ResourceCatalog{
+		routes:       routes,
+		server:       server,
+		samples:      make(map[string][]CatalogSample),
+		ttl:          5 * time.Minute,
+		quoteTimeout: 5 * time.Second,
+		clock:        SystemClock,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	for pattern := range r.samples {
+		if _, ok := routes[pattern]; !ok {
+			fmt.Printf("Warning: catalog samples for route %q, which isn't in RoutesConfig\n", pattern)
+		}
+	}
+	return r
+}
+
+// TTL is how long a built catalog is served
+func (r *ResourceCatalog) TTL() time.Duration {
+	return r.ttl
+}
+
+// Catalog returns the current catalog, rebuilding it if it's older than the TTL. Concurrent
+// callers wait for a single rebuild.
+func (r *ResourceCatalog) Catalog(ctx context.Context) Catalog {
+	if catalog, ok := r.fresh(); ok {
+		return catalog
+	}
+
+	r.build.Lock()
+	defer r.build.Unlock()
+	if catalog, ok := r.fresh(); ok {
+		return catalog
+	}
+
+	catalog := buildCatalog(ctx, r.routes, r.server, r.quote)
+	r.mu.Lock()
+	r.catalog = &catalog
+	r.builtAt = r.clock.Now()
+	r.mu.Unlock()
+	return catalog
+}
+
+// Invalidate makes the next Catalog call rebuild, e.g. after prices or routes change
+func (r *ResourceCatalog) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.catalog = nil
+}
+
+func (r *ResourceCatalog) fresh() (Catalog, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.catalog == nil || r.clock.Now().Sub(r.builtAt) >= r.ttl {
+		return Catalog{}, false
+	}
+	return *r.catalog, true
+}
+
+// quote resolves option for each of the route's samples. Samples whose pricing fails are
+// left out and logged, so pricing errors aren't published.
+func (r *ResourceCatalog) quote(ctx context.Context, server *x402http.HTTPServer, pattern, method, path string, option x402http.PaymentOption) []CatalogQuote {
+	samples := r.samples[pattern]
+	if len(samples) == 0 {
+		return nil
+	}
+
+	if method == "*" {
+		method = http.MethodGet
+	}
+
+	quotes := make([]CatalogQuote, 0, len(samples))
+	for _, sample := range samples {
+		if sample.Path == "" {
+			sample.Path = path
+		}
+
+		sampleCtx := ctx
+		for _, value := range sample.Context {
+			sampleCtx = value(sampleCtx)
+		}
+		samplePath, rawQuery, _ := strings.Cut(sample.Path, "?")
+		if query, err := url.ParseQuery(rawQuery); err == nil && rawQuery != "" {
+			sampleCtx = ContextWithQuery(sampleCtx, query)
+		}
+		sampleCtx, cancel := context.WithTimeout(sampleCtx, r.quoteTimeout)
+		reqCtx := x402http.HTTPRequestContext{
+			Adapter: sampleAdapter{sample: sample, method: method, path: samplePath},
+			Path:    samplePath,
+			Method:  method,
+		}
+		requirements, err := server.BuildPaymentRequirementsFromOptions(sampleCtx, []x402http.PaymentOption{option}, reqCtx)
+		cancel()
+		if err != nil || len(requirements) == 0 {
+			fmt.Printf("Warning: failed to quote route %q for sample %q: %v\n", pattern, sample.Label, err)
+			continue
+		}
+
+		quotes = append(quotes, CatalogQuote{
+			Label:         sample.Label,
+			Path:          sample.Path,
+			Asset:         requirements[0].Asset,
+			Amount:        requirements[0].Amount,
+			DisplayAmount: FormatTokenAmount(option.Network, requirements[0].Asset, requirements[0].Amount),
+			PayTo:         requirements[0].PayTo,
+		})
+	}
+	return quotes
+}
+
+// sampleAdapter presents a CatalogSample to pricing functions as a request
+type sampleAdapter struct {
+	sample CatalogSample
+	method string
+	path   string
+}
+
+func (a sampleAdapter) GetHeader(name string) string {
+	for key, value := range a.sample.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+func (a sampleAdapter) GetMethod() string       { return a.method }
+func (a sampleAdapter) GetPath() string         { return a.path }
+func (a sampleAdapter) GetURL() string          { return a.path }
+func (a sampleAdapter) GetAcceptHeader() string { return a.GetHeader("Accept") }
+func (a sampleAdapter) GetUserAgent() string    { return a.GetHeader("User-Agent") }
@@ -0,0 +1,110 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// RefundedTransactionMetadataKey is the PaymentEvent.Metadata key naming the settlement
+// transaction a payment.refunded event refunds. Set it on your refund events so
+// RevokeRefunded can find the access the payment bought.
+const RefundedTransactionMetadataKey = "refundedTransaction"
+
+// RevocationList records settlement transactions whose access has been revoked. Session
+// tokens, API keys, and access grants carry the transaction that paid for them, so revoking it
+// revokes them all.
+type RevocationList interface {
+	Revoke(ctx context.Context, transaction string) error
+	IsRevoked(ctx context.Context, transaction string) (bool, error)
+}
+
+// RevokeRefunded returns a PaymentEventHandler that revokes the refunded transaction of
+// payment.refunded events, so refunded payers lose the sessions, API keys, and access grants
+// the payment bought. Refund events come from the application, so pass them to the handler
+// yourself, or consume them from your event broker on each replica. Failures are logged.
+func RevokeRefunded(list RevocationList) PaymentEventHandler {
+	return func(ctx context.Context, event PaymentEvent) {
+		transaction := event.Metadata[RefundedTransactionMetadataKey]
+		if event.Type != EventPaymentRefunded || transaction == "" {
+			return
+		}
+		if err := list.Revoke(ctx, transaction); err != nil {
+			fmt.Printf("Warning: failed to revoke access for refunded transaction %s: %v\n", transaction, err)
+		}
+	}
+}
+
+// revocationKey normalizes transaction hashes, which clients may send in either case
+func revocationKey(transaction string) string {
+	return strings.ToLower(transaction)
+}
+
+// MemoryRevocationList is an in-process RevocationList. Revocations are lost on restart.
+type MemoryRevocationList struct {
+	mu      sync.RWMutex
+	revoked map[string]bool
+}
+
+// NewMemoryRevocationList creates an empty MemoryRevocationList
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{revoked: make(map[string]bool)}
+}
+
+// Revoke revokes transaction
+func (l *MemoryRevocationList) Revoke(ctx context.Context, transaction string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.revoked[revocationKey(transaction)] = true
+	return nil
+}
+
+// IsRevoked reports whether transaction was revoked
+func (l *MemoryRevocationList) IsRevoked(ctx context.Context, transaction string) (bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.revoked[revocationKey(transaction)], nil
+}
+
+// FileRevocationList keeps each revocation as a file in a directory, so revocations survive
+// restarts and can be shared by replicas on the same volume
+type FileRevocationList struct {
+	dir string
+}
+
+// NewFileRevocationList creates a FileRevocationList in dir, which is created if needed
+func NewFileRevocationList(dir string) *FileRevocationList {
+	return &FileRevocationList{dir: dir}
+}
+
+// Revoke writes transaction's revocation file
+func (l *FileRevocationList) Revoke(ctx context.Context, transaction string) error {
+	return writeFileAtomic(l.path(transaction), []byte(revocationKey(transaction)))
+}
+
+// IsRevoked reports whether transaction's revocation file exists
+func (l *FileRevocationList) IsRevoked(ctx context.Context, transaction string) (bool, error) {
+	_, err := os.Stat(l.path(transaction))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// path names the revocation's file by a hash of the transaction, whose format depends on the
+// network
+func (l *FileRevocationList) path(transaction string) string {
+	sum := sha256.Sum256([]byte(revocationKey(transaction)))
+	return filepath.Join(l.dir, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,227 @@
+package xtended402
+
+import (
+	"strings"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// SettlementTimingExtensionKey is the route extension overriding the middleware's settlement
+// timing for a route, "before" or "after". Set it with RouteWithSettlementTiming.
+const SettlementTimingExtensionKey = "settlementTiming"
+
+// RouteWithSettlementTiming returns a copy of route settling payments before or after its
+// handler runs, whatever the middleware's settlement timing
+func RouteWithSettlementTiming(route x402http.RouteConfig, timing string) x402http.RouteConfig {
+	extensions := make(map[string]interface{}, len(route.Extensions)+1)
+	for key, value := range route.Extensions {
+		extensions[key] = value
+	}
+	extensions[SettlementTimingExtensionKey] = timing
+	route.Extensions = extensions
+	return route
+}
+
+// RouteSettlementTiming returns the settlement timing declared by route, or ""
+func RouteSettlementTiming(route x402http.RouteConfig) string {
+	timing, _ := route.Extensions[SettlementTimingExtensionKey].(string)
+	return timing
+}
+
+// ResourceSettlementTiming returns the settlement timing declared by the route in routes
+// matching resource ("METHOD /path"), or ""
+func ResourceSettlementTiming(routes x402http.RoutesConfig, resource string) string {
+	for pattern, route := range routes {
+		if ParseRoutePattern(pattern).Matches(resource) {
+			return RouteSettlementTiming(route)
+		}
+	}
+	return ""
+}
+
+// RouteDefaults are the payment settings a RouteGroup's routes share. Zero fields are unset.
+type RouteDefaults struct {
+	// Scheme, Network, PayTo, MaxTimeoutSeconds, and Extra fill the matching fields of each
+	// payment option that leaves them unset. Scheme defaults to "exact".
+	Scheme            string
+	Network           x402.Network
+	PayTo             interface{} // string or x402http.DynamicPayToFunc
+	MaxTimeoutSeconds int
+	Extra             map[string]interface{}
+
+	// MimeType is the routes' response MIME type, unless a route sets its own
+	MimeType string
+
+	// SettlementTiming settles the routes' payments "before" or "after" their handlers run,
+	// unless a route declares its own (see RouteWithSettlementTiming)
+	SettlementTiming string
+
+	// Scopes are declared on routes that don't declare their own (see ScopedRoute)
+	Scopes []string
+
+	// SettleHooks are run by the routes before any they name (see RouteWithSettleHooks). A
+	// subgroup's are added to its group's.
+	SettleHooks []string
+}
+
+// RouteGroup builds routes sharing a path prefix and payment defaults, so an API with dozens
+// of paid endpoints doesn't repeat its network and recipient on each:
+//
+//	api := xtended402.NewRouteGroup("/api/v1", xtended402.RouteDefaults{
+//		Network: "eip155:8453",
+//		PayTo:   "0x...",
+//	})
+//	api.Price("GET /weather", "$0.01")
+//	api.Price("GET /forecast", "$0.05")
+//	api.Route("POST /orders", x402http.RouteConfig{Accepts: x402http.PaymentOptions{
+//		{Price: orderPrice, Network: "eip155:84532"}, // overrides the group's network
+//	}})
+//	routes := api.Routes()
+type RouteGroup struct {
+	prefix   string
+	defaults RouteDefaults
+	routes   x402http.RoutesConfig
+}
+
+// NewRouteGroup creates a group of routes under prefix, e.g. "/api/v1" ("" for none)
+func NewRouteGroup(prefix string, defaults RouteDefaults) *RouteGroup {
+	if defaults.Scheme == "" {
+		defaults.Scheme = "exact"
+	}
+	return &RouteGroup{
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		defaults: defaults,
+		routes:   make(x402http.RoutesConfig),
+	}
+}
+
+// Group creates a subgroup under the group's prefix. Its routes are added to the group's
+// Routes, and its defaults override the group's where set.
+func (g *RouteGroup) Group(prefix string, defaults RouteDefaults) *RouteGroup {
+	return &RouteGroup{
+		prefix:   g.prefix + strings.TrimSuffix(prefix, "/"),
+		defaults: mergeRouteDefaults(g.defaults, defaults),
+		routes:   g.routes,
+	}
+}
+
+// Price adds a route with a single payment option: the group's defaults at price, an
+// x402.Price or x402http.DynamicPriceFunc
+func (g *RouteGroup) Price(pattern string, price interface{}) *RouteGroup {
+	return g.Route(pattern, x402http.RouteConfig{Accepts: x402http.PaymentOptions{{Price: price}}})
+}
+
+// Route adds route under the group's prefix, filling what it leaves unset from the group's
+// defaults. Fields the route sets override the defaults.
+func (g *RouteGroup) Route(pattern string, route x402http.RouteConfig) *RouteGroup {
+	d := g.defaults
+
+	accepts := make(x402http.PaymentOptions, len(route.Accepts))
+	for i, option := range route.Accepts {
+		if option.Scheme == "" {
+			option.Scheme = d.Scheme
+		}
+		if option.Network == "" {
+			option.Network = d.Network
+		}
+		if option.PayTo == nil || option.PayTo == "" {
+			option.PayTo = d.PayTo
+		}
+		if option.MaxTimeoutSeconds == 0 {
+			option.MaxTimeoutSeconds = d.MaxTimeoutSeconds
+		}
+		if option.Extra == nil && d.Extra != nil {
+			option.Extra = make(map[string]interface{}, len(d.Extra))
+			for key, value := range d.Extra {
+				option.Extra[key] = value
+			}
+		}
+		accepts[i] = option
+	}
+	route.Accepts = accepts
+
+	if route.MimeType == "" {
+		route.MimeType = d.MimeType
+	}
+	if len(d.Scopes) > 0 && RouteScopes(route) == nil {
+		route = ScopedRoute(route, d.Scopes...)
+	}
+	if d.SettlementTiming != "" && RouteSettlementTiming(route) == "" {
+		route = RouteWithSettlementTiming(route, d.SettlementTiming)
+	}
+	if len(d.SettleHooks) > 0 {
+		// The group's hooks run before the route's own
+		route = withSettleHooks(route, append(append([]string{}, d.SettleHooks...), RouteSettleHooks(route)...))
+	}
+
+	g.routes[g.pattern(pattern)] = route
+	return g
+}
+
+// Routes returns the routes of the group and its subgroups
+func (g *RouteGroup) Routes() x402http.RoutesConfig {
+	routes := make(x402http.RoutesConfig, len(g.routes))
+	for pattern, route := range g.routes {
+		routes[pattern] = route
+	}
+	return routes
+}
+
+// pattern prefixes the paths and exclusions of pattern with the group's prefix. Regular
+// expressions are matched against the whole path, so they're left as written.
+func (g *RouteGroup) pattern(pattern string) string {
+	if g.prefix == "" {
+		return pattern
+	}
+
+	method, path, exclusions := splitRoutePattern(pattern)
+	fields := make([]string, 0, len(exclusions)+2)
+	if method != "*" || len(strings.Fields(pattern)) > len(exclusions)+1 {
+		fields = append(fields, method)
+	}
+	fields = append(fields, g.prefixed(path))
+	for _, exclusion := range exclusions {
+		fields = append(fields, "!"+g.prefixed(strings.TrimPrefix(exclusion, "!")))
+	}
+	return strings.Join(fields, " ")
+}
+
+func (g *RouteGroup) prefixed(path string) string {
+	if strings.HasPrefix(path, "~") {
+		return path
+	}
+	return g.prefix + path
+}
+
+// mergeRouteDefaults returns parent overridden by the fields child sets
+func mergeRouteDefaults(parent, child RouteDefaults) RouteDefaults {
+	if child.Scheme != "" {
+		parent.Scheme = child.Scheme
+	}
+	if child.Network != "" {
+		parent.Network = child.Network
+	}
+	if child.PayTo != nil && child.PayTo != "" {
+		parent.PayTo = child.PayTo
+	}
+	if child.MaxTimeoutSeconds != 0 {
+		parent.MaxTimeoutSeconds = child.MaxTimeoutSeconds
+	}
+	if child.Extra != nil {
+		parent.Extra = child.Extra
+	}
+	if child.MimeType != "" {
+		parent.MimeType = child.MimeType
+	}
+	if child.SettlementTiming != "" {
+		parent.SettlementTiming = child.SettlementTiming
+	}
+	if child.Scopes != nil {
+		parent.Scopes = child.Scopes
+	}
+	if len(child.SettleHooks) > 0 {
+		parent.SettleHooks = append(append([]string{}, parent.SettleHooks...), child.SettleHooks...)
+	}
+	return parent
+}
@@ -0,0 +1,54 @@
+package xtended402
+
+import (
+	"context"
+	"fmt"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+)
+
+type routeParamsContextKey struct{}
+
+// ContextWithRouteParams returns a context carrying the parameters of the route pattern a
+// request matched. The Gin middleware adds them for patterns with parameters.
+func ContextWithRouteParams(ctx context.Context, params map[string]string) context.Context {
+	return context.WithValue(ctx, routeParamsContextKey{}, params)
+}
+
+// RouteParamsFromContext returns the route parameters in ctx, or nil
+func RouteParamsFromContext(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(routeParamsContextKey{}).(map[string]string)
+	return params
+}
+
+// RouteParam returns the named route parameter in ctx, or ""
+func RouteParam(ctx context.Context, name string) string {
+	return RouteParamsFromContext(ctx)[name]
+}
+
+// GetRouteParams returns the parameters of the paid route pattern the request matched, e.g.
+// {"id": "42"} for "GET /api/items/:id", or nil
+func GetRouteParams(c *gin.Context) map[string]string {
+	return RouteParamsFromContext(c.Request.Context())
+}
+
+// RouteParamPrice creates a DynamicPriceFunc pricing each request by the named route
+// parameter, e.g. per item:
+//
+//	"GET /api/items/:id": {Accepts: x402http.PaymentOptions{{
+//		Price: xtended402.RouteParamPrice("id", func(ctx context.Context, id string) (x402.Price, error) {
+//			return catalog.Price(ctx, id)
+//		}),
+//		...
+//	}}}
+func RouteParamPrice(name string, price func(ctx context.Context, value string) (x402.Price, error)) x402http.DynamicPriceFunc {
+	return func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (x402.Price, error) {
+		value := RouteParam(ctx, name)
+		if value == "" {
+			return nil, fmt.Errorf("route parameter %q not found in context", name)
+		}
+		return price(ctx, value)
+	}
+}
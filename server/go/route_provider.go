@@ -0,0 +1,78 @@
+package xtended402
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// RouteProvider supplies paid routes kept outside the code, e.g. products and prices in a
+// database managed by a CMS or admin panel. The Gin middleware loads them with
+// ginmw.WithRouteProvider and serves its cached copy between refreshes, so requests never wait
+// on the provider.
+type RouteProvider interface {
+	PaidRoutes(ctx context.Context) (x402http.RoutesConfig, error)
+}
+
+// RouteProviderFunc adapts a function to a RouteProvider
+type RouteProviderFunc func(ctx context.Context) (x402http.RoutesConfig, error)
+
+// PaidRoutes calls f
+func (f RouteProviderFunc) PaidRoutes(ctx context.Context) (x402http.RoutesConfig, error) {
+	return f(ctx)
+}
+
+// SQLRouteProvider is a RouteProvider reading routes from a SQL database with query. Each row
+// is one payment option, selected as (pattern, description, scheme, network, price, pay_to):
+//
+//	SELECT pattern, description, scheme, network, price, pay_to FROM paid_routes WHERE active
+//
+// Rows sharing a pattern are options of the same route, in row order. Prices are money
+// strings such as "$0.01".
+type SQLRouteProvider struct {
+	db    *sql.DB
+	query string
+}
+
+// NewSQLRouteProvider creates a SQLRouteProvider running query on db, opened with any
+// database/sql driver
+func NewSQLRouteProvider(db *sql.DB, query string) *SQLRouteProvider {
+	return &SQLRouteProvider{db: db, query: query}
+}
+
+// PaidRoutes runs the provider's query
+func (p *SQLRouteProvider) PaidRoutes(ctx context.Context) (x402http.RoutesConfig, error) {
+	rows, err := p.db.QueryContext(ctx, p.query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query paid routes: %w", err)
+	}
+	defer rows.Close()
+
+	routes := make(x402http.RoutesConfig)
+	for rows.Next() {
+		var pattern, scheme, network, price, payTo string
+		var description sql.NullString
+		if err := rows.Scan(&pattern, &description, &scheme, &network, &price, &payTo); err != nil {
+			return nil, fmt.Errorf("failed to read paid route: %w", err)
+		}
+
+		route := routes[pattern]
+		if description.Valid {
+			route.Description = description.String
+		}
+		route.Accepts = append(route.Accepts, x402http.PaymentOption{
+			Scheme:  scheme,
+			Network: x402.Network(network),
+			Price:   x402.Price(price),
+			PayTo:   payTo,
+		})
+		routes[pattern] = route
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read paid routes: %w", err)
+	}
+	return routes, nil
+}
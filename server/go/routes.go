@@ -0,0 +1,319 @@
+package xtended402
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RoutePattern matches resources ("METHOD /path") against an x402http.RoutesConfig-style
+// pattern, e.g. "POST /api/purchase", "GET /reports/*", "/items/[id]" (any method),
+// "GET ~^/v[0-9]+/items$" (a regular expression), or "GET /api/* !/api/health" (a prefix with
+// an exclusion)
+type RoutePattern struct {
+	method  string // "*" matches any method
+	raw     string
+	path    *regexp.Regexp // nil for invalid patterns, which match nothing
+	params  []string       // parameter names by capture group, "" for unnamed groups
+	exclude []*regexp.Regexp
+}
+
+var (
+	routeParamPattern = regexp.MustCompile(`\\\[([^\]]+)\\\]`)
+	routeParamName    = regexp.MustCompile(`\[([^\]]+)\]`)
+	colonParamPattern = regexp.MustCompile(`/:([^/]+)`)
+)
+
+// ParseRoutePattern parses a route pattern. In a path, "*" matches any characters, and "[name]"
+// or ":name" matches one path segment as a parameter. A path starting with "~" is instead a
+// regular expression matched against the whole path, whose named groups are parameters. Paths
+// after the first, each starting with "!", are exclusions: resources matching any of them
+// don't match the pattern.
+//
+// Invalid patterns, such as a malformed regular expression, match nothing; CheckRoutePattern
+// reports why.
+func ParseRoutePattern(pattern string) RoutePattern {
+	p, _ := compileRoutePattern(pattern)
+	return p
+}
+
+// CheckRoutePattern returns why pattern is invalid, or nil
+func CheckRoutePattern(pattern string) error {
+	_, err := compileRoutePattern(pattern)
+	return err
+}
+
+func compileRoutePattern(pattern string) (RoutePattern, error) {
+	method, path, exclusions := splitRoutePattern(pattern)
+	p := RoutePattern{method: method, raw: path}
+
+	compiled, params, err := compilePatternPath(path)
+	if err != nil {
+		return p, err
+	}
+	for _, exclusion := range exclusions {
+		if !strings.HasPrefix(exclusion, "!") {
+			return p, fmt.Errorf("exclusion %q must start with !", exclusion)
+		}
+		excluded, _, err := compilePatternPath(exclusion[1:])
+		if err != nil {
+			return p, err
+		}
+		p.exclude = append(p.exclude, excluded)
+	}
+	p.path, p.params = compiled, params
+	return p, nil
+}
+
+// splitRoutePattern splits a pattern into its method ("*" when it has none), path, and
+// exclusions. Like x402, a pattern of two fields is always a method and a path.
+func splitRoutePattern(pattern string) (method, path string, exclusions []string) {
+	fields := strings.Fields(pattern)
+	switch {
+	case len(fields) < 2:
+		return "*", strings.TrimSpace(pattern), nil
+	case len(fields) == 2 && !strings.HasPrefix(fields[1], "!"):
+		return strings.ToUpper(fields[0]), fields[1], nil
+	case strings.HasPrefix(fields[0], "/") || strings.HasPrefix(fields[0], "~"):
+		return "*", fields[0], fields[1:]
+	}
+	return strings.ToUpper(fields[0]), fields[1], fields[2:]
+}
+
+// compilePatternPath compiles a pattern path or exclusion, returning its parameter names by
+// capture group: a regular expression after "~", otherwise a path with "*" wildcards and
+// "[name]" or ":name" parameters
+func compilePatternPath(path string) (*regexp.Regexp, []string, error) {
+	if expr, ok := strings.CutPrefix(path, "~"); ok {
+		compiled, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+		}
+		return compiled, compiled.SubexpNames()[1:], nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, nil, fmt.Errorf("path %q must start with / (or ~ for a regular expression)", path)
+	}
+
+	path = colonParamPattern.ReplaceAllString(path, "/[$1]")
+	var params []string
+	for _, match := range routeParamName.FindAllStringSubmatch(path, -1) {
+		params = append(params, match[1])
+	}
+	return compileRoutePath(path), params, nil
+}
+
+// compileRoutePath compiles a pattern's path the way x402 does, capturing each parameter
+func compileRoutePath(path string) *regexp.Regexp {
+	expr := "^" + regexp.QuoteMeta(path)
+	expr = strings.ReplaceAll(expr, `\*`, `.*?`)
+	expr = routeParamPattern.ReplaceAllString(expr, `([^/]+)`)
+	expr += "$"
+	return regexp.MustCompile(expr)
+}
+
+// Method is the pattern's method, or "*" for any method
+func (p RoutePattern) Method() string {
+	return p.method
+}
+
+// Path is the pattern's path as written, e.g. "/api/*" or "~^/v[0-9]+/items$"
+func (p RoutePattern) Path() string {
+	return p.raw
+}
+
+// Extended reports whether the pattern uses syntax x402 doesn't match itself: a regular
+// expression, ":name" parameters, or exclusions
+func (p RoutePattern) Extended() bool {
+	return strings.HasPrefix(p.raw, "~") || colonParamPattern.MatchString(p.raw) || len(p.exclude) > 0
+}
+
+// ParamNames returns the names of the pattern's parameters, in order
+func (p RoutePattern) ParamNames() []string {
+	var names []string
+	for _, name := range p.params {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Params returns the parameters a request path matching the pattern binds, e.g. {"id": "42"}
+// for "/items/42" and "/items/[id]", or nil when the path doesn't match or the pattern has no
+// parameters. The path is normalized as MatchRequest does.
+func (p RoutePattern) Params(path string) map[string]string {
+	if p.path == nil || len(p.params) == 0 {
+		return nil
+	}
+	path = normalizeRoutePath(path)
+	match := p.path.FindStringSubmatch(path)
+	if match == nil {
+		return nil
+	}
+	for _, excluded := range p.exclude {
+		if excluded.MatchString(path) {
+			return nil
+		}
+	}
+
+	params := make(map[string]string, len(p.params))
+	for i, name := range p.params {
+		if name != "" && i+1 < len(match) {
+			params[name] = match[i+1]
+		}
+	}
+	return params
+}
+
+// Matches reports whether resource ("METHOD /path") matches the pattern
+func (p RoutePattern) Matches(resource string) bool {
+	method, path, ok := strings.Cut(resource, " ")
+	if !ok {
+		return false
+	}
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return p.match(strings.ToUpper(method), path)
+}
+
+// MatchRequest reports whether a request's method and URL path match the pattern. The path is
+// normalized like x402's: decoded, with duplicate and trailing slashes removed.
+func (p RoutePattern) MatchRequest(method, path string) bool {
+	return p.match(strings.ToUpper(method), normalizeRoutePath(path))
+}
+
+// match matches an uppercase method and a normalized path
+func (p RoutePattern) match(method, path string) bool {
+	if p.path == nil || (p.method != "*" && p.method != method) || !p.path.MatchString(path) {
+		return false
+	}
+	for _, excluded := range p.exclude {
+		if excluded.MatchString(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// RouteMatcher reports whether requests match any of a set of route patterns, as x402's
+// RequiresPayment does, without its per-request regular expressions. Patterns are compiled
+// into a tree of path segments; only segments with a wildcard or partial parameter, such as
+// "/files/*.pdf", fall back to a regular expression, as do regular expression patterns and
+// patterns with exclusions.
+type RouteMatcher struct {
+	root routeNode
+}
+
+type routeNode struct {
+	literals map[string]*routeNode
+	param    *routeNode // a "[name]" segment
+
+	// methods are those of patterns ending at this node ("*" for any)
+	methods map[string]bool
+
+	// tails are patterns whose remaining segments need a regular expression
+	tails []RoutePattern
+}
+
+// NewRouteMatcher compiles route patterns, e.g. the keys of an x402http.RoutesConfig
+func NewRouteMatcher(patterns ...string) *RouteMatcher {
+	m := &RouteMatcher{}
+	for _, pattern := range patterns {
+		m.add(pattern)
+	}
+	return m
+}
+
+func (m *RouteMatcher) add(pattern string) {
+	parsed := ParseRoutePattern(pattern)
+	if parsed.Extended() {
+		m.root.tails = append(m.root.tails, parsed)
+		return
+	}
+
+	method, path := parsed.method, parsed.raw
+	node := &m.root
+	for _, segment := range strings.Split(path, "/") {
+		switch {
+		case isRouteParam(segment):
+			if node.param == nil {
+				node.param = &routeNode{}
+			}
+			node = node.param
+		case strings.ContainsAny(segment, "*[]"):
+			node.tails = append(node.tails, parsed)
+			return
+		default:
+			if node.literals == nil {
+				node.literals = make(map[string]*routeNode)
+			}
+			child := node.literals[segment]
+			if child == nil {
+				child = &routeNode{}
+				node.literals[segment] = child
+			}
+			node = child
+		}
+	}
+	if node.methods == nil {
+		node.methods = make(map[string]bool)
+	}
+	node.methods[method] = true
+}
+
+// isRouteParam reports whether segment is exactly one "[name]" parameter
+func isRouteParam(segment string) bool {
+	return len(segment) > 2 && segment[0] == '[' && segment[len(segment)-1] == ']' &&
+		!strings.ContainsAny(segment[1:len(segment)-1], "[]*")
+}
+
+// Match reports whether a request's method and URL path match any pattern. The path is
+// normalized like x402's: decoded, with duplicate and trailing slashes removed.
+func (m *RouteMatcher) Match(method, path string) bool {
+	path = normalizeRoutePath(path)
+	return m.root.matchAfter(strings.ToUpper(method), path, path, true)
+}
+
+// match matches the next segment of rest, the unmatched part of path, against n's children
+func (n *routeNode) match(method, path, rest string) bool {
+	segment, rest, more := strings.Cut(rest, "/")
+	if child := n.literals[segment]; child != nil && child.matchAfter(method, path, rest, more) {
+		return true
+	}
+	return n.param != nil && segment != "" && n.param.matchAfter(method, path, rest, more)
+}
+
+// matchAfter continues once n's segment has matched; more reports whether segments remain
+func (n *routeNode) matchAfter(method, path, rest string, more bool) bool {
+	for _, tail := range n.tails {
+		if tail.match(method, path) {
+			return true
+		}
+	}
+	if more {
+		return n.match(method, path, rest)
+	}
+	return n.methods["*"] || n.methods[method]
+}
+
+// normalizeRoutePath normalizes a URL path the way x402 does before matching routes
+func normalizeRoutePath(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	if decoded, err := url.PathUnescape(path); err == nil {
+		path = decoded
+	}
+	path = strings.ReplaceAll(path, `\`, `/`)
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+	path = strings.TrimSuffix(path, "/")
+	if path == "" {
+		path = "/"
+	}
+	return path
+}
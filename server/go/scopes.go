@@ -0,0 +1,92 @@
+package xtended402
+
+import (
+	"strings"
+
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// ScopesExtensionKey is the route extension declaring the scopes a route sells, e.g.
+// ["read:reports"]. It is advertised in the route's 402 responses. Set it with ScopedRoute.
+const ScopesExtensionKey = "scopes"
+
+// ScopesMetadataKey is the PaymentEvent.Metadata key listing the scopes a payment bought,
+// space-separated as in OAuth
+const ScopesMetadataKey = "scopes"
+
+// ScopedRoute returns a copy of route declaring scopes. Paying for the route buys the scopes,
+// and a session token only unlocks the route if it carries all of them.
+//
+//	"GET /reports/*": xtended402.ScopedRoute(x402http.RouteConfig{Accepts: ...}, "read:reports"),
+func ScopedRoute(route x402http.RouteConfig, scopes ...string) x402http.RouteConfig {
+	extensions := make(map[string]interface{}, len(route.Extensions)+1)
+	for key, value := range route.Extensions {
+		extensions[key] = value
+	}
+	extensions[ScopesExtensionKey] = scopes
+	route.Extensions = extensions
+	return route
+}
+
+// RouteScopes returns the scopes declared by route, or nil
+func RouteScopes(route x402http.RouteConfig) []string {
+	switch scopes := route.Extensions[ScopesExtensionKey].(type) {
+	case []string:
+		return scopes
+	case []interface{}:
+		result := make([]string, 0, len(scopes))
+		for _, scope := range scopes {
+			if s, ok := scope.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	case string:
+		return strings.Fields(scopes)
+	}
+	return nil
+}
+
+// ResourceScopes returns the scopes declared by the route in routes matching resource
+// ("METHOD /path"), or nil
+func ResourceScopes(routes x402http.RoutesConfig, resource string) []string {
+	for pattern, route := range routes {
+		if ParseRoutePattern(pattern).Matches(resource) {
+			return RouteScopes(route)
+		}
+	}
+	return nil
+}
+
+// ResourceRoute returns the pattern of the route in routes matching resource ("METHOD /path"),
+// or ""
+func ResourceRoute(routes x402http.RoutesConfig, resource string) string {
+	for pattern := range routes {
+		if ParseRoutePattern(pattern).Matches(resource) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// EventScopes returns the scopes bought by the payment of event, or nil
+func EventScopes(event PaymentEvent) []string {
+	return strings.Fields(event.Metadata[ScopesMetadataKey])
+}
+
+// HasScopes reports whether granted includes every scope in required
+func HasScopes(granted []string, required ...string) bool {
+	for _, scope := range required {
+		found := false
+		for _, have := range granted {
+			if have == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
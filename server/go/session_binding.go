@@ -0,0 +1,125 @@
+package xtended402
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SessionProofHeader carries the proof of possession for a wallet-bound session token:
+// "t=<unix seconds>,sig=<0x EIP-191 signature of SessionProofMessage>"
+const SessionProofHeader = "X-Payment-Session-Proof"
+
+// WithSessionWalletBinding binds tokens to the paying wallet. Requests presenting a bound token
+// must also sign a proof for the request with that wallet (see SignSessionProof), so a leaked
+// token is useless without the wallet's key. Proofs older than tolerance (default 5m when 0)
+// are rejected. Payments without a wallet address, such as card payments, get unbound tokens.
+func WithSessionWalletBinding(tolerance time.Duration) SessionOption {
+	return func(s *SessionIssuer) {
+		s.bindWallet = true
+		s.proofTolerance = tolerance
+		if s.proofTolerance <= 0 {
+			s.proofTolerance = 5 * time.Minute
+		}
+	}
+}
+
+// WithSessionFingerprint binds tokens to a fingerprint of the paying client, e.g. its User-Agent
+// or TLS client certificate. Requests presenting the token must produce the same fingerprint.
+// Only a hash of the fingerprint is stored in the token.
+func WithSessionFingerprint(fingerprint func(r *http.Request) string) SessionOption {
+	return func(s *SessionIssuer) {
+		s.fingerprint = fingerprint
+	}
+}
+
+// SessionProofMessage is the message a client signs (EIP-191 personal_sign) to prove it holds
+// the wallet a session token is bound to, for a request to resource ("METHOD /path") at
+// timestamp
+func SessionProofMessage(token, resource string, timestamp time.Time) string {
+	signature := token
+	if i := strings.LastIndex(token, "."); i >= 0 {
+		signature = token[i+1:]
+	}
+	return "x402 session proof\nsession: " + signature + "\nrequest: " + resource + "\ntimestamp: " + strconv.FormatInt(timestamp.Unix(), 10)
+}
+
+// SignSessionProof signs a proof of possession with key for a request to resource, returning
+// the SessionProofHeader value
+func SignSessionProof(key *ecdsa.PrivateKey, token, resource string, timestamp time.Time) (string, error) {
+	signature, err := crypto.Sign(accounts.TextHash([]byte(SessionProofMessage(token, resource, timestamp))), key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign session proof: %w", err)
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return "t=" + strconv.FormatInt(timestamp.Unix(), 10) + ",sig=" + hexutil.Encode(signature), nil
+}
+
+// verifyProof checks r's proof of possession of wallet for token
+func (s *SessionIssuer) verifyProof(r *http.Request, token, resource, wallet string) error {
+	var t, sig string
+	for _, part := range strings.Split(r.Header.Get(SessionProofHeader), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "sig":
+			sig = value
+		}
+	}
+	if t == "" || sig == "" {
+		return fmt.Errorf("%w: missing proof of possession", ErrSessionInvalid)
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed proof", ErrSessionInvalid)
+	}
+	timestamp := time.Unix(unix, 0)
+	if age := s.clock.Now().Sub(timestamp); age > s.proofTolerance || age < -s.proofTolerance {
+		return fmt.Errorf("%w: proof outside tolerance", ErrSessionInvalid)
+	}
+
+	signature, err := hexutil.Decode(sig)
+	if err != nil || len(signature) != crypto.SignatureLength {
+		return fmt.Errorf("%w: malformed proof", ErrSessionInvalid)
+	}
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	publicKey, err := crypto.SigToPub(accounts.TextHash([]byte(SessionProofMessage(token, resource, timestamp))), signature)
+	if err != nil {
+		return fmt.Errorf("%w: malformed proof", ErrSessionInvalid)
+	}
+	if !strings.EqualFold(crypto.PubkeyToAddress(*publicKey).Hex(), wallet) {
+		return fmt.Errorf("%w: proof not signed by bound wallet", ErrSessionInvalid)
+	}
+	return nil
+}
+
+// fingerprintOf hashes r's fingerprint, or returns "" when fingerprints aren't configured
+func (s *SessionIssuer) fingerprintOf(r *http.Request) string {
+	if s.fingerprint == nil || r == nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s.fingerprint(r)))
+	return hex.EncodeToString(sum[:])
+}
+
+// bindableWallet returns payer if it is a wallet address a proof can be checked against
+func bindableWallet(payer string) string {
+	if common.IsHexAddress(payer) {
+		return payer
+	}
+	return ""
+}
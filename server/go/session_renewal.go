@@ -0,0 +1,100 @@
+package xtended402
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// SessionRenewalHeader carries a base64-encoded JSON SessionRenewal on 402 responses to
+// requests presenting a session token that has just expired
+const SessionRenewalHeader = "X-Payment-Session-Renewal"
+
+// SessionRenewal tells a client its session can be renewed rather than bought from scratch.
+// The 402's payment requirements carry the renewal price.
+type SessionRenewal struct {
+	// PreviousSession is the expired token's ID (jti); the renewed token references it
+	PreviousSession string `json:"previousSession"`
+
+	ExpiredAt time.Time `json:"expiredAt"`
+
+	// RenewBy is when the grace period ends and renewal pricing no longer applies
+	RenewBy time.Time `json:"renewBy"`
+}
+
+// Header encodes the renewal for SessionRenewalHeader
+func (r *SessionRenewal) Header() string {
+	data, _ := json.Marshal(r)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// DecodeSessionRenewal decodes a SessionRenewalHeader value
+func DecodeSessionRenewal(header string) (*SessionRenewal, error) {
+	var renewal SessionRenewal
+	if err := decodeHeader(header, &renewal); err != nil {
+		return nil, fmt.Errorf("invalid session renewal header: %w", err)
+	}
+	return &renewal, nil
+}
+
+// WithSessionRenewal lets tokens that expired less than grace ago be renewed: requests
+// presenting one get a 402 with a SessionRenewal, priced by SessionRenewalPrice, and paying it
+// issues a token referencing the previous one
+func WithSessionRenewal(grace time.Duration) SessionOption {
+	return func(s *SessionIssuer) {
+		s.renewalGrace = grace
+	}
+}
+
+// Renewable returns the claims of the token presented with r if it has expired within the
+// renewal grace period and is otherwise valid for resource
+func (s *SessionIssuer) Renewable(r *http.Request, resource string) (*SessionClaims, bool) {
+	if s.renewalGrace <= 0 || s.Token(r) == "" {
+		return nil, false
+	}
+	claims, err := s.verifyRequest(r, resource, s.renewalGrace)
+	if err != nil || s.clock.Now().Before(claims.ExpiresAt) {
+		return nil, false
+	}
+	return claims, true
+}
+
+// Renewal describes the renewal offered for claims
+func (s *SessionIssuer) Renewal(claims *SessionClaims) *SessionRenewal {
+	return &SessionRenewal{
+		PreviousSession: claims.ID,
+		ExpiredAt:       claims.ExpiresAt,
+		RenewBy:         claims.ExpiresAt.Add(s.renewalGrace),
+	}
+}
+
+type renewingSessionKey struct{}
+
+// WithRenewingSession returns a copy of ctx carrying the claims of the expired session a
+// request is renewing
+func WithRenewingSession(ctx context.Context, claims *SessionClaims) context.Context {
+	return context.WithValue(ctx, renewingSessionKey{}, claims)
+}
+
+// RenewingSession returns the claims of the expired session the request is renewing, or nil
+func RenewingSession(ctx context.Context) *SessionClaims {
+	claims, _ := ctx.Value(renewingSessionKey{}).(*SessionClaims)
+	return claims
+}
+
+// SessionRenewalPrice creates a DynamicPriceFunc charging renewal for requests renewing an
+// expired session and price otherwise, e.g. SessionRenewalPrice("$5.00", "$4.00")
+func SessionRenewalPrice(price, renewal x402.Price) x402http.DynamicPriceFunc {
+	return func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (x402.Price, error) {
+		if RenewingSession(ctx) != nil {
+			return renewal, nil
+		}
+		return price, nil
+	}
+}
@@ -0,0 +1,470 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHeader carries a session token on the response to a paid request. Later requests
+// present the token in this header, as "Authorization: Bearer <token>", or in the session
+// cookie.
+const SessionHeader = "X-Payment-Session"
+
+// SessionKey is the Gin context key where SessionClaims are stored for requests let through by
+// a session token
+const SessionKey = "xtended402Session"
+
+// ErrSessionInvalid is returned (possibly wrapped) for tokens that are malformed, forged,
+// expired, or don't cover the requested resource
+var ErrSessionInvalid = errors.New("session token invalid")
+
+// ErrSessionExpired is returned for expired tokens; it wraps ErrSessionInvalid
+var ErrSessionExpired = fmt.Errorf("%w: expired", ErrSessionInvalid)
+
+// SessionClaims are the claims of a session token
+type SessionClaims struct {
+	// ID is the token's unique ID (jti)
+	ID string
+
+	// Issuer is the iss claim set with WithSessionIssuer
+	Issuer string
+
+	// Subject is the payer (sub)
+	Subject string
+
+	// Transaction is the settlement that paid for the session
+	Transaction string
+
+	// Scope lists the route patterns the token unlocks: the paid route by default, or the
+	// WithSessionScope patterns. It's empty on tokens carrying Scopes, which unlock the routes
+	// declaring them, and on tokens from issuers with WithUnscopedSessions, which unlock every
+	// paid route.
+	Scope []string
+
+	// Scopes lists the access scopes the payment bought (scp), declared by the paid route with
+	// ScopedRoute. Routes declaring scopes require all of them.
+	Scopes []string
+
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+
+	// Wallet is the wallet the token is bound to (cnf), set with WithSessionWalletBinding
+	Wallet string
+
+	// Fingerprint is the hash of the client fingerprint the token is bound to (fp), set with
+	// WithSessionFingerprint
+	Fingerprint string
+
+	// Renews is the ID of the expired token this one renewed (prev), set with
+	// WithSessionRenewal
+	Renews string
+
+	// Extra holds the claims added with WithSessionClaims
+	Extra map[string]interface{}
+}
+
+// SessionIssuer issues HS256 JWT session tokens after a payment settles and verifies them on
+// later requests, so one payment unlocks paid routes for a while ("pay once, browse for an
+// hour"). Use it with ginmw.WithSessions.
+type SessionIssuer struct {
+	secret []byte
+	ttl    time.Duration
+	issuer string
+	scope  []string
+	claims func(ctx context.Context, event PaymentEvent) map[string]interface{}
+	cookie string
+	clock  Clock
+
+	bindWallet     bool
+	proofTolerance time.Duration
+	fingerprint    func(r *http.Request) string
+	renewalGrace   time.Duration
+
+	// unscoped lets tokens without a scope unlock every route
+	unscoped bool
+}
+
+// SessionOption configures a SessionIssuer
+type SessionOption func(*SessionIssuer)
+
+// WithSessionTTL sets how long tokens stay valid (default 1h)
+func WithSessionTTL(ttl time.Duration) SessionOption {
+	return func(s *SessionIssuer) {
+		s.ttl = ttl
+	}
+}
+
+// WithSessionScope limits tokens to resources matching the route patterns (same syntax as
+// x402http.RoutesConfig keys). By default a token only unlocks the route it was paid on.
+func WithSessionScope(patterns ...string) SessionOption {
+	return func(s *SessionIssuer) {
+		s.scope = append(s.scope, patterns...)
+	}
+}
+
+// WithUnscopedSessions issues tokens unlocking every route behind the middleware, and accepts
+// tokens without a scope. Paying for the cheapest route then unlocks the most expensive, so
+// only use it when every paid route is priced the same.
+func WithUnscopedSessions() SessionOption {
+	return func(s *SessionIssuer) {
+		s.unscoped = true
+	}
+}
+
+// Unscoped reports whether tokens without a route scope unlock every route, as set with
+// WithUnscopedSessions
+func (s *SessionIssuer) Unscoped() bool {
+	return s.unscoped
+}
+
+type paidRouteKey struct{}
+
+// WithPaidRoute returns a copy of ctx naming the route pattern a payment was made on, which
+// tokens issued for it are scoped to by default. The middleware sets it.
+func WithPaidRoute(ctx context.Context, pattern string) context.Context {
+	return context.WithValue(ctx, paidRouteKey{}, pattern)
+}
+
+// PaidRoute returns the route pattern the payment in ctx was made on, or ""
+func PaidRoute(ctx context.Context) string {
+	pattern, _ := ctx.Value(paidRouteKey{}).(string)
+	return pattern
+}
+
+// WithSessionIssuer sets the iss claim, which verification requires to match, so tokens from
+// one site or tier aren't accepted by another sharing the secret
+func WithSessionIssuer(issuer string) SessionOption {
+	return func(s *SessionIssuer) {
+		s.issuer = issuer
+	}
+}
+
+// WithSessionClaims adds claims to each token, e.g. a subscription tier. They can't override
+// the standard claims.
+func WithSessionClaims(claims func(ctx context.Context, event PaymentEvent) map[string]interface{}) SessionOption {
+	return func(s *SessionIssuer) {
+		s.claims = claims
+	}
+}
+
+// WithSessionCookie sets the session cookie's name (default "x402_session"); "" sends the
+// token in the header only
+func WithSessionCookie(name string) SessionOption {
+	return func(s *SessionIssuer) {
+		s.cookie = name
+	}
+}
+
+// WithSessionClock sets the clock for issue and expiry times (default SystemClock)
+func WithSessionClock(clock Clock) SessionOption {
+	return func(s *SessionIssuer) {
+		s.clock = ClockOrSystem(clock)
+	}
+}
+
+// NewSessionIssuer creates a SessionIssuer signing with secret, which should be at least 32
+// random bytes
+func NewSessionIssuer(secret string, opts ...SessionOption) *SessionIssuer {
+	s := &SessionIssuer{
+		secret: []byte(secret),
+		ttl:    time.Hour,
+		cookie: "x402_session",
+		clock:  SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Issue signs a token for the settled payment described by event. Use IssueRequest when
+// tokens are bound to a client fingerprint.
+func (s *SessionIssuer) Issue(ctx context.Context, event PaymentEvent) (string, *SessionClaims, error) {
+	return s.issue(ctx, event, "")
+}
+
+// IssueRequest signs a token for the settled payment described by event, bound to the
+// fingerprint of r if WithSessionFingerprint is set
+func (s *SessionIssuer) IssueRequest(r *http.Request, event PaymentEvent) (string, *SessionClaims, error) {
+	return s.issue(r.Context(), event, s.fingerprintOf(r))
+}
+
+// issue signs a token. If ctx carries a RenewingSession, the token references it.
+func (s *SessionIssuer) issue(ctx context.Context, event PaymentEvent, fingerprint string) (string, *SessionClaims, error) {
+	scope, err := s.scopeFor(ctx, event)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := s.clock.Now().UTC().Truncate(time.Second)
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	claims := &SessionClaims{
+		ID:          "ses_" + hex.EncodeToString(id),
+		Issuer:      s.issuer,
+		Subject:     event.Payer,
+		Transaction: event.Transaction,
+		Scope:       scope,
+		Scopes:      EventScopes(event),
+		IssuedAt:    now,
+		ExpiresAt:   now.Add(s.ttl),
+		Fingerprint: fingerprint,
+	}
+	if s.bindWallet {
+		claims.Wallet = bindableWallet(event.Payer)
+	}
+	if renewing := RenewingSession(ctx); renewing != nil {
+		claims.Renews = renewing.ID
+	}
+	if s.claims != nil {
+		claims.Extra = s.claims(ctx, event)
+	}
+
+	body := make(map[string]interface{}, len(claims.Extra)+11)
+	for key, value := range claims.Extra {
+		body[key] = value
+	}
+	body["jti"] = claims.ID
+	body["iat"] = claims.IssuedAt.Unix()
+	body["exp"] = claims.ExpiresAt.Unix()
+	if claims.Issuer != "" {
+		body["iss"] = claims.Issuer
+	}
+	if claims.Subject != "" {
+		body["sub"] = claims.Subject
+	}
+	if claims.Transaction != "" {
+		body["tx"] = claims.Transaction
+	}
+	if len(claims.Scope) > 0 {
+		body["scope"] = claims.Scope
+	}
+	if len(claims.Scopes) > 0 {
+		body["scp"] = claims.Scopes
+	}
+	if claims.Wallet != "" {
+		body["cnf"] = map[string]string{"addr": claims.Wallet}
+	}
+	if claims.Fingerprint != "" {
+		body["fp"] = claims.Fingerprint
+	}
+	if claims.Renews != "" {
+		body["prev"] = claims.Renews
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode session claims: %w", err)
+	}
+
+	signingInput := sessionHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + s.sign(signingInput), claims, nil
+}
+
+// Verify checks token's signature, expiry, issuer, and scope for resource ("METHOD /path").
+// Tokens bound to a wallet or fingerprint can only be checked with VerifyRequest.
+func (s *SessionIssuer) Verify(token, resource string) (*SessionClaims, error) {
+	claims, err := s.verify(token, resource, 0)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Wallet != "" || claims.Fingerprint != "" {
+		return nil, fmt.Errorf("%w: bound token needs the request to verify", ErrSessionInvalid)
+	}
+	return claims, nil
+}
+
+// VerifyRequest checks the session token presented with r for resource ("METHOD /path"),
+// including its proof of possession and client fingerprint if the token is bound
+func (s *SessionIssuer) VerifyRequest(r *http.Request, resource string) (*SessionClaims, error) {
+	return s.verifyRequest(r, resource, 0)
+}
+
+// verifyRequest is VerifyRequest accepting tokens up to grace past expiry
+func (s *SessionIssuer) verifyRequest(r *http.Request, resource string, grace time.Duration) (*SessionClaims, error) {
+	token := s.Token(r)
+	if token == "" {
+		return nil, fmt.Errorf("%w: missing", ErrSessionInvalid)
+	}
+	claims, err := s.verify(token, resource, grace)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Fingerprint != "" && !hmac.Equal([]byte(claims.Fingerprint), []byte(s.fingerprintOf(r))) {
+		return nil, fmt.Errorf("%w: client fingerprint mismatch", ErrSessionInvalid)
+	}
+	if claims.Wallet != "" {
+		if err := s.verifyProof(r, token, resource, claims.Wallet); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+func (s *SessionIssuer) verify(token, resource string, grace time.Duration) (*SessionClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != sessionHeader {
+		return nil, fmt.Errorf("%w: malformed", ErrSessionInvalid)
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(parts[0]+"."+parts[1]))) {
+		return nil, fmt.Errorf("%w: bad signature", ErrSessionInvalid)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrSessionInvalid)
+	}
+	var standard struct {
+		ID          string   `json:"jti"`
+		Issuer      string   `json:"iss"`
+		Subject     string   `json:"sub"`
+		Transaction string   `json:"tx"`
+		Scope       []string `json:"scope"`
+		Scopes      []string `json:"scp"`
+		Confirm     struct {
+			Addr string `json:"addr"`
+		} `json:"cnf"`
+		Fingerprint string `json:"fp"`
+		Renews      string `json:"prev"`
+		IssuedAt    int64  `json:"iat"`
+		ExpiresAt   int64  `json:"exp"`
+	}
+	var extra map[string]interface{}
+	if err := json.Unmarshal(payload, &standard); err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrSessionInvalid)
+	}
+	if err := json.Unmarshal(payload, &extra); err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrSessionInvalid)
+	}
+	for _, key := range []string{"jti", "iss", "sub", "tx", "scope", "scp", "cnf", "fp", "prev", "iat", "exp"} {
+		delete(extra, key)
+	}
+	if len(extra) == 0 {
+		extra = nil
+	}
+
+	claims := &SessionClaims{
+		ID:          standard.ID,
+		Issuer:      standard.Issuer,
+		Subject:     standard.Subject,
+		Transaction: standard.Transaction,
+		Scope:       standard.Scope,
+		Scopes:      standard.Scopes,
+		Wallet:      standard.Confirm.Addr,
+		Fingerprint: standard.Fingerprint,
+		Renews:      standard.Renews,
+		IssuedAt:    time.Unix(standard.IssuedAt, 0).UTC(),
+		ExpiresAt:   time.Unix(standard.ExpiresAt, 0).UTC(),
+		Extra:       extra,
+	}
+
+	if !s.clock.Now().Before(claims.ExpiresAt.Add(grace)) {
+		return nil, ErrSessionExpired
+	}
+	if claims.Issuer != s.issuer {
+		return nil, fmt.Errorf("%w: wrong issuer", ErrSessionInvalid)
+	}
+	if len(claims.Scope) == 0 && len(claims.Scopes) == 0 && !s.unscoped {
+		return nil, fmt.Errorf("%w: unscoped", ErrSessionInvalid)
+	}
+	if len(claims.Scope) > 0 && !scopeMatches(claims.Scope, resource) {
+		return nil, fmt.Errorf("%w: not valid for %s", ErrSessionInvalid, resource)
+	}
+
+	return claims, nil
+}
+
+// scopeFor returns the scope of a token for the payment: the WithSessionScope patterns, or
+// else the route it was paid on, or the paid resource itself. Payments buying access scopes
+// are scoped by those instead.
+func (s *SessionIssuer) scopeFor(ctx context.Context, event PaymentEvent) ([]string, error) {
+	switch {
+	case len(s.scope) > 0:
+		return s.scope, nil
+	case s.unscoped || len(EventScopes(event)) > 0:
+		return nil, nil
+	case PaidRoute(ctx) != "":
+		return []string{PaidRoute(ctx)}, nil
+	case event.Resource != "":
+		return []string{event.Resource}, nil
+	}
+	return nil, fmt.Errorf("no paid route to scope the session for %s to", event.ID)
+}
+
+// Token returns the session token presented with r, if any
+func (s *SessionIssuer) Token(r *http.Request) string {
+	if token := r.Header.Get(SessionHeader); token != "" {
+		return token
+	}
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	if s.cookie != "" {
+		if cookie, err := r.Cookie(s.cookie); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// SetToken adds token to the response in the session header and, unless disabled, an
+// HttpOnly cookie that expires with it
+func (s *SessionIssuer) SetToken(w http.ResponseWriter, token string, claims *SessionClaims) {
+	w.Header().Set(SessionHeader, token)
+	if s.cookie == "" {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  claims.ExpiresAt,
+		MaxAge:   int(claims.ExpiresAt.Sub(claims.IssuedAt).Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// GetSession returns the claims of the session token that let the request through, or nil if
+// the request was paid for (or wasn't paid at all)
+func GetSession(c *gin.Context) *SessionClaims {
+	claims, exists := c.Get(SessionKey)
+	if !exists {
+		return nil
+	}
+	return claims.(*SessionClaims)
+}
+
+func (s *SessionIssuer) sign(signingInput string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sessionHeader is the encoded JWT header of every session token
+var sessionHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func scopeMatches(scope []string, resource string) bool {
+	for _, pattern := range scope {
+		if ParseRoutePattern(pattern).Matches(resource) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package xtended402_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	"github.com/mvpoyatt/xtended402/server/go/testing/clocktest"
+)
+
+const sessionSecret = "0123456789abcdef0123456789abcdef"
+
+var sessionEvent = xtended402.PaymentEvent{
+	ID:          "evt_1",
+	Resource:    "GET /articles/1",
+	Payer:       "0x2222222222222222222222222222222222222222",
+	Transaction: "0xabc",
+}
+
+func issueSession(t *testing.T, issuer *xtended402.SessionIssuer, ctx context.Context) string {
+	t.Helper()
+	token, _, err := issuer.Issue(ctx, sessionEvent)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	return token
+}
+
+func TestSessionTampering(t *testing.T) {
+	issuer := xtended402.NewSessionIssuer(sessionSecret, xtended402.WithUnscopedSessions())
+	token := issueSession(t, issuer, context.Background())
+
+	if _, err := issuer.Verify(token, "GET /articles/1"); err != nil {
+		t.Fatalf("Verify rejected a fresh token: %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	forged := xtended402.NewSessionIssuer("another secret, at least 32 bytes", xtended402.WithUnscopedSessions())
+	forgedToken := issueSession(t, forged, context.Background())
+	tampered := map[string]string{
+		"signature": parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])),
+		"payload":   parts[0] + "." + strings.Split(forgedToken, ".")[1] + "." + parts[2],
+		"secret":    forgedToken,
+		"truncated": parts[0] + "." + parts[1],
+	}
+	for name, token := range tampered {
+		if _, err := issuer.Verify(token, "GET /articles/1"); !errors.Is(err, xtended402.ErrSessionInvalid) {
+			t.Errorf("%s: Verify returned %v, want ErrSessionInvalid", name, err)
+		}
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	clock := clocktest.New(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	issuer := xtended402.NewSessionIssuer(sessionSecret,
+		xtended402.WithUnscopedSessions(),
+		xtended402.WithSessionTTL(time.Hour),
+		xtended402.WithSessionClock(clock),
+	)
+	token := issueSession(t, issuer, context.Background())
+
+	clock.Advance(59 * time.Minute)
+	if _, err := issuer.Verify(token, "GET /articles/1"); err != nil {
+		t.Fatalf("Verify rejected a token before expiry: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := issuer.Verify(token, "GET /articles/1"); !errors.Is(err, xtended402.ErrSessionExpired) {
+		t.Fatalf("Verify returned %v for an expired token, want ErrSessionExpired", err)
+	}
+}
+
+func TestSessionScope(t *testing.T) {
+	t.Run("paid route", func(t *testing.T) {
+		issuer := xtended402.NewSessionIssuer(sessionSecret)
+		token := issueSession(t, issuer, xtended402.WithPaidRoute(context.Background(), "GET /articles/*"))
+
+		if _, err := issuer.Verify(token, "GET /articles/2"); err != nil {
+			t.Fatalf("token rejected on its paid route: %v", err)
+		}
+		if _, err := issuer.Verify(token, "POST /api/reports"); !errors.Is(err, xtended402.ErrSessionInvalid) {
+			t.Fatalf("token accepted outside its paid route: %v", err)
+		}
+	})
+
+	t.Run("paid resource", func(t *testing.T) {
+		issuer := xtended402.NewSessionIssuer(sessionSecret)
+		token := issueSession(t, issuer, context.Background())
+
+		if _, err := issuer.Verify(token, "GET /articles/1"); err != nil {
+			t.Fatalf("token rejected on its paid resource: %v", err)
+		}
+		if _, err := issuer.Verify(token, "GET /articles/2"); !errors.Is(err, xtended402.ErrSessionInvalid) {
+			t.Fatalf("token accepted outside its paid resource: %v", err)
+		}
+	})
+
+	t.Run("configured scope", func(t *testing.T) {
+		issuer := xtended402.NewSessionIssuer(sessionSecret, xtended402.WithSessionScope("GET /articles/*"))
+		token := issueSession(t, issuer, xtended402.WithPaidRoute(context.Background(), "GET /articles/1"))
+
+		if _, err := issuer.Verify(token, "GET /articles/2"); err != nil {
+			t.Fatalf("token rejected within WithSessionScope: %v", err)
+		}
+		if _, err := issuer.Verify(token, "GET /videos/1"); !errors.Is(err, xtended402.ErrSessionInvalid) {
+			t.Fatalf("token accepted outside WithSessionScope: %v", err)
+		}
+	})
+
+	t.Run("unscoped", func(t *testing.T) {
+		unscoped := xtended402.NewSessionIssuer(sessionSecret, xtended402.WithUnscopedSessions())
+		token := issueSession(t, unscoped, context.Background())
+
+		if _, err := unscoped.Verify(token, "POST /api/reports"); err != nil {
+			t.Fatalf("unscoped token rejected by an issuer opting in: %v", err)
+		}
+		scoped := xtended402.NewSessionIssuer(sessionSecret)
+		if _, err := scoped.Verify(token, "POST /api/reports"); !errors.Is(err, xtended402.ErrSessionInvalid) {
+			t.Fatalf("unscoped token accepted without WithUnscopedSessions: %v", err)
+		}
+	})
+}
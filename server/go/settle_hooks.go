@@ -0,0 +1,63 @@
+package xtended402
+
+import (
+	"strings"
+
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// SettleHooksExtensionKey is the route extension naming the settlement hooks a route runs,
+// e.g. ["inventory"]. Hooks are registered by name on the middleware, with
+// ginmw.WithNamedBeforeSettleHook and WithNamedAfterSettleHook. Set it with
+// RouteWithSettleHooks.
+const SettleHooksExtensionKey = "settleHooks"
+
+// RouteWithSettleHooks returns a copy of route running the named settlement hooks, besides
+// any it already names, so checks such as an inventory check apply only to the routes that
+// need them:
+//
+//	"POST /api/purchase": xtended402.RouteWithSettleHooks(purchaseRoute, "inventory"),
+//	"GET /api/*":         xtended402.RouteWithSettleHooks(apiRoute, "metering"),
+func RouteWithSettleHooks(route x402http.RouteConfig, names ...string) x402http.RouteConfig {
+	return withSettleHooks(route, append(append([]string{}, RouteSettleHooks(route)...), names...))
+}
+
+func withSettleHooks(route x402http.RouteConfig, hooks []string) x402http.RouteConfig {
+	extensions := make(map[string]interface{}, len(route.Extensions)+1)
+	for key, value := range route.Extensions {
+		extensions[key] = value
+	}
+	extensions[SettleHooksExtensionKey] = hooks
+	route.Extensions = extensions
+	return route
+}
+
+// RouteSettleHooks returns the settlement hooks named by route, or nil
+func RouteSettleHooks(route x402http.RouteConfig) []string {
+	switch hooks := route.Extensions[SettleHooksExtensionKey].(type) {
+	case []string:
+		return hooks
+	case []interface{}:
+		result := make([]string, 0, len(hooks))
+		for _, hook := range hooks {
+			if s, ok := hook.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	case string:
+		return strings.Fields(hooks)
+	}
+	return nil
+}
+
+// ResourceSettleHooks returns the settlement hooks named by the route in routes matching
+// resource ("METHOD /path"), or nil
+func ResourceSettleHooks(routes x402http.RoutesConfig, resource string) []string {
+	for pattern, route := range routes {
+		if ParseRoutePattern(pattern).Matches(resource) {
+			return RouteSettleHooks(route)
+		}
+	}
+	return nil
+}
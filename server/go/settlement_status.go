@@ -0,0 +1,179 @@
+package xtended402
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	x402 "github.com/coinbase/x402/go"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// SettlementState is where a settlement transaction stands
+type SettlementState string
+
+const (
+	// SettlementPending is a settlement not yet confirmed by enough blocks
+	SettlementPending SettlementState = "pending"
+
+	// SettlementConfirmed is a settlement confirmed by enough blocks
+	SettlementConfirmed SettlementState = "confirmed"
+
+	// SettlementFailed is a settlement that failed to settle or reverted on-chain
+	SettlementFailed SettlementState = "failed"
+)
+
+// SettlementStatus is what clients see of a settlement transaction
+type SettlementStatus struct {
+	Transaction string          `json:"transaction"`
+	State       SettlementState `json:"state"`
+	Network     x402.Network    `json:"network,omitempty"`
+	Resource    string          `json:"resource,omitempty"`
+	Amount      string          `json:"amount,omitempty"`
+	Asset       string          `json:"asset,omitempty"`
+	Payer       string          `json:"payer,omitempty"`
+
+	// Confirmations counts the blocks including the transaction, when confirmations are tracked
+	Confirmations uint64 `json:"confirmations,omitempty"`
+
+	// ErrorReason explains a failed settlement
+	ErrorReason string `json:"errorReason,omitempty"`
+}
+
+// ConfirmationTracker reports how many blocks confirm a transaction: 0 while it's pending, and
+// failed once it reverted
+type ConfirmationTracker interface {
+	Confirmations(ctx context.Context, network x402.Network, transaction string) (confirmations uint64, failed bool, err error)
+}
+
+// SettlementStatuses looks up settlements in a PaymentStore, recorded with RecordPayments, and
+// optionally tracks their confirmations on-chain. Without a tracker, settled payments are
+// confirmed: the facilitator waited for their receipt.
+type SettlementStatuses struct {
+	store         PaymentStore
+	tracker       ConfirmationTracker
+	confirmations uint64
+}
+
+// SettlementStatusOption configures SettlementStatuses
+type SettlementStatusOption func(*SettlementStatuses)
+
+// WithConfirmationTracker tracks settled payments until confirmations blocks include them
+// (at least 1)
+func WithConfirmationTracker(tracker ConfirmationTracker, confirmations uint64) SettlementStatusOption {
+	return func(s *SettlementStatuses) {
+		s.tracker = tracker
+		s.confirmations = max(confirmations, 1)
+	}
+}
+
+// NewSettlementStatuses creates SettlementStatuses backed by store
+func NewSettlementStatuses(store PaymentStore, opts ...SettlementStatusOption) *SettlementStatuses {
+	s := &SettlementStatuses{store: store, confirmations: 1}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Status returns the status of the settlement with transaction hash, or ErrPaymentNotFound when
+// no payment settled in it
+func (s *SettlementStatuses) Status(ctx context.Context, transaction string) (*SettlementStatus, error) {
+	records, err := s.store.ListPayments(ctx, PaymentQuery{Transaction: transaction})
+	if err != nil {
+		return nil, err
+	}
+
+	// The settlement, not a refund paid in the same transaction
+	var record *PaymentRecord
+	for _, candidate := range records {
+		if candidate.Status != PaymentStatusRefunded {
+			record = candidate
+		}
+	}
+	if record == nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	status := &SettlementStatus{
+		Transaction: record.Transaction,
+		State:       SettlementConfirmed,
+		Network:     record.Network,
+		Resource:    record.Resource,
+		Amount:      record.Amount,
+		Asset:       record.Asset,
+		Payer:       record.Payer,
+	}
+	switch {
+	case record.Status == PaymentStatusFailed:
+		status.State = SettlementFailed
+		status.ErrorReason = record.ErrorReason
+	case s.tracker != nil && record.Network != FiatNetwork:
+		confirmations, failed, err := s.tracker.Confirmations(ctx, record.Network, record.Transaction)
+		if err != nil {
+			return nil, fmt.Errorf("failed to track confirmations: %w", err)
+		}
+		status.Confirmations = confirmations
+		switch {
+		case failed:
+			status.State = SettlementFailed
+			status.ErrorReason = "transaction reverted"
+		case confirmations < s.confirmations:
+			status.State = SettlementPending
+		}
+	}
+	return status, nil
+}
+
+// ============================================================================
+// RPC Confirmation Tracker
+// ============================================================================
+
+// RPCConfirmationTracker tracks confirmations through JSON-RPC nodes
+type RPCConfirmationTracker struct {
+	clients map[x402.Network]*ethclient.Client
+}
+
+// NewRPCConfirmationTracker connects to an RPC node per network
+func NewRPCConfirmationTracker(ctx context.Context, rpcURLs map[x402.Network]string) (*RPCConfirmationTracker, error) {
+	clients := make(map[x402.Network]*ethclient.Client, len(rpcURLs))
+	for network, rpcURL := range rpcURLs {
+		client, err := ethclient.DialContext(ctx, rpcURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s RPC: %w", network, err)
+		}
+		clients[network] = client
+	}
+	return &RPCConfirmationTracker{clients: clients}, nil
+}
+
+// Confirmations reads the transaction's receipt. Transactions without one yet are pending.
+func (t *RPCConfirmationTracker) Confirmations(ctx context.Context, network x402.Network, transaction string) (uint64, bool, error) {
+	client, ok := t.clients[network]
+	if !ok {
+		return 0, false, fmt.Errorf("no RPC configured for %s", network)
+	}
+
+	receipt, err := client.TransactionReceipt(ctx, common.HexToHash(transaction))
+	if errors.Is(err, ethereum.NotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if receipt.Status == types.ReceiptStatusFailed {
+		return 0, true, nil
+	}
+
+	latest, err := client.BlockNumber(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	if latest < receipt.BlockNumber.Uint64() {
+		return 0, false, nil
+	}
+	return latest - receipt.BlockNumber.Uint64() + 1, false, nil
+}
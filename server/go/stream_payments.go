@@ -0,0 +1,151 @@
+package xtended402
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/gin-gonic/gin"
+)
+
+// StreamingExtensionKey is the route extension declaring a route's StreamTerms. Set it with
+// RouteWithStreaming. It's sent in the 402, so clients know how many increments to sign.
+const StreamingExtensionKey = "streaming"
+
+// PaymentIncrementsHeader carries the payments for a stream's increments after the first,
+// which is paid in PAYMENT-SIGNATURE. Build it with EncodePaymentIncrements.
+const PaymentIncrementsHeader = "X-Payment-Increments"
+
+// StreamIncrementMetadataKey is the event metadata key carrying which increment of a stream a
+// payment paid for, from 1
+const StreamIncrementMetadataKey = "streamIncrement"
+
+// StreamPaymentKey holds the *StreamPayment of a paid stream in the Gin context
+const StreamPaymentKey = "xtended402StreamPayment"
+
+// ErrStreamPaymentFailed is returned by a stream's writes once an increment can't be paid:
+// its settlement failed or the pre-authorized increments ran out
+var ErrStreamPaymentFailed = errors.New("stream payment failed")
+
+// StreamTerms price a streaming response in increments. The route's price is one increment's.
+// The client signs up to MaxIncrements payments of it up front, the pre-authorized maximum;
+// the first settles before the stream starts and the others as the stream reaches them.
+// Payments for increments the stream never reaches are never settled.
+type StreamTerms struct {
+	// EverySeconds and EveryBytes are how much of the stream an increment pays for. When both
+	// are set, an increment ends at whichever comes first.
+	EverySeconds int   `json:"everySeconds,omitempty"`
+	EveryBytes   int64 `json:"everyBytes,omitempty"`
+
+	// MaxIncrements is the most increments a stream may pay for
+	MaxIncrements int `json:"maxIncrements"`
+
+	// Header is PaymentIncrementsHeader
+	Header string `json:"header"`
+}
+
+// Every is EverySeconds as a duration
+func (t *StreamTerms) Every() time.Duration {
+	return time.Duration(t.EverySeconds) * time.Second
+}
+
+// RouteWithStreaming returns a copy of route settling its streamed response in increments
+func RouteWithStreaming(route x402http.RouteConfig, terms StreamTerms) x402http.RouteConfig {
+	terms.Header = PaymentIncrementsHeader
+	extensions := make(map[string]interface{}, len(route.Extensions)+1)
+	for key, value := range route.Extensions {
+		extensions[key] = value
+	}
+	extensions[StreamingExtensionKey] = terms
+	route.Extensions = extensions
+	return route
+}
+
+// RouteStreamTerms returns the stream terms declared by route, or nil
+func RouteStreamTerms(route x402http.RouteConfig) *StreamTerms {
+	switch terms := route.Extensions[StreamingExtensionKey].(type) {
+	case StreamTerms:
+		return &terms
+	case *StreamTerms:
+		return terms
+	case map[string]interface{}:
+		// Declared in a config file
+		data, err := json.Marshal(terms)
+		if err != nil {
+			return nil
+		}
+		var parsed StreamTerms
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return nil
+		}
+		return &parsed
+	}
+	return nil
+}
+
+// ResourceStreamTerms returns the stream terms declared by the route in routes matching
+// resource ("METHOD /path"), or nil
+func ResourceStreamTerms(routes x402http.RoutesConfig, resource string) *StreamTerms {
+	for pattern, route := range routes {
+		if ParseRoutePattern(pattern).Matches(resource) {
+			return RouteStreamTerms(route)
+		}
+	}
+	return nil
+}
+
+// EncodePaymentIncrements builds a PaymentIncrementsHeader value from PAYMENT-SIGNATURE values,
+// one per increment after the first
+func EncodePaymentIncrements(payments ...string) (string, error) {
+	payloads := make([]json.RawMessage, len(payments))
+	for i, payment := range payments {
+		data, err := base64.StdEncoding.DecodeString(payment)
+		if err != nil {
+			return "", fmt.Errorf("invalid payment %d: %w", i+1, err)
+		}
+		payloads[i] = data
+	}
+	data, err := json.Marshal(payloads)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecodePaymentIncrements decodes a PaymentIncrementsHeader value
+func DecodePaymentIncrements(header string) ([]x402types.PaymentPayload, error) {
+	var payloads []x402types.PaymentPayload
+	if err := decodeHeader(header, &payloads); err != nil {
+		return nil, fmt.Errorf("invalid payment increments header: %w", err)
+	}
+	for i, payload := range payloads {
+		if payload.X402Version != 2 {
+			return nil, fmt.Errorf("invalid payment increments header: payment %d: only V2 payments supported, got V%d", i+1, payload.X402Version)
+		}
+	}
+	return payloads, nil
+}
+
+// StreamPayment is how much of a stream has been paid for
+type StreamPayment struct {
+	// Authorized is how many increments the client pre-authorized, and Settled how many have
+	// been paid
+	Authorized int
+	Settled    int
+
+	// Transactions are the settled increments' transactions, in order
+	Transactions []string
+}
+
+// GetStreamPayment returns the payment of the stream being served, or nil
+func GetStreamPayment(c *gin.Context) *StreamPayment {
+	payment, ok := c.Get(StreamPaymentKey)
+	if !ok {
+		return nil
+	}
+	return payment.(*StreamPayment)
+}
@@ -0,0 +1,103 @@
+package stripe
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSessionNotFound is returned for sessions with no completed payment
+	ErrSessionNotFound = errors.New("checkout session not paid")
+
+	// ErrSessionRedeemed is returned for sessions already used for a request
+	ErrSessionRedeemed = errors.New("checkout session already redeemed")
+)
+
+// Session is a paid Checkout Session, recorded from Stripe's webhook
+type Session struct {
+	ID string
+
+	// Resource, Network, Asset, and Amount are the x402 price the session was created for
+	Resource string
+	Network  string
+	Asset    string
+	Amount   string
+
+	// AmountTotal is what the customer paid in the currency's minor units (e.g. cents)
+	AmountTotal   int64
+	Currency      string
+	PaymentIntent string
+	Email         string
+	PaidAt        time.Time
+
+	Redeemed bool
+}
+
+// SessionStore records paid sessions and hands each out for exactly one request
+type SessionStore interface {
+	// SaveSession records a paid session; saving a known session again keeps its redeemed state
+	SaveSession(ctx context.Context, session *Session) error
+
+	// ClaimSession atomically marks a paid session redeemed and returns it, or returns
+	// ErrSessionNotFound or ErrSessionRedeemed
+	ClaimSession(ctx context.Context, id string) (*Session, error)
+
+	// ReleaseSession undoes a claim
+	ReleaseSession(ctx context.Context, id string) error
+}
+
+// MemorySessionStore is an in-process SessionStore. Use a shared store when running several
+// replicas, since the webhook and the redeeming request may reach different ones.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewMemorySessionStore creates an empty MemorySessionStore
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*Session)}
+}
+
+// SaveSession records a paid session
+func (s *MemorySessionStore) SaveSession(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *session
+	if existing, ok := s.sessions[session.ID]; ok {
+		stored.Redeemed = existing.Redeemed
+	}
+	s.sessions[session.ID] = &stored
+	return nil
+}
+
+// ClaimSession marks a paid session redeemed
+func (s *MemorySessionStore) ClaimSession(ctx context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if session.Redeemed {
+		return nil, ErrSessionRedeemed
+	}
+	session.Redeemed = true
+
+	claimed := *session
+	return &claimed, nil
+}
+
+// ReleaseSession makes a redeemed session usable again
+func (s *MemorySessionStore) ReleaseSession(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[id]; ok {
+		session.Redeemed = false
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+// Package stripe lets card payers buy x402-protected resources through Stripe Checkout. Passed
+// to ginmw.WithFiatFallback, a Provider adds a Checkout link to every 402 response; Stripe's
+// checkout.session.completed webhook marks the session paid, and the client retries the
+// request with the session ID in the X-Fiat-Payment header instead of an x402 payment:
+//
+//	cards := stripe.New(os.Getenv("STRIPE_SECRET_KEY"), os.Getenv("STRIPE_WEBHOOK_SECRET"),
+//		stripe.WithSuccessURL("https://shop.example/paid?session={CHECKOUT_SESSION_ID}"),
+//	)
+//	r.POST("/stripe/webhook", gin.WrapH(cards.WebhookHandler()))
+//	r.POST("/api/purchase", ginmw.PaymentMiddleware(routes, server, ginmw.WithFiatFallback(cards)), handler)
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402types "github.com/coinbase/x402/go/types"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// APIEndpoint is the Stripe API base URL
+const APIEndpoint = "https://api.stripe.com"
+
+// ProviderName identifies Stripe on FiatCheckout, FiatPayment, and payment events
+const ProviderName = "stripe"
+
+// Provider creates Stripe Checkout Sessions for x402 prices and redeems paid sessions. It
+// implements xtended402.FiatProvider.
+type Provider struct {
+	secretKey     string
+	webhookSecret []byte
+
+	endpoint   string
+	client     *http.Client
+	successURL string
+	cancelURL  string
+	currency   string
+	oracle     xtended402.PriceOracle
+	store      SessionStore
+	clock      xtended402.Clock
+	tolerance  time.Duration
+	product    func(resource string, requirements x402types.PaymentRequirements) string
+}
+
+// Option configures a Provider
+type Option func(*Provider)
+
+// WithSuccessURL sets where Stripe sends the customer after paying. Include
+// {CHECKOUT_SESSION_ID} to receive the session ID the client must present.
+func WithSuccessURL(successURL string) Option {
+	return func(p *Provider) {
+		p.successURL = successURL
+	}
+}
+
+// WithCancelURL sets where Stripe sends the customer if they abandon checkout
+func WithCancelURL(cancelURL string) Option {
+	return func(p *Provider) {
+		p.cancelURL = cancelURL
+	}
+}
+
+// WithCurrency sets the card currency (default "usd"); it must have two decimal places
+func WithCurrency(currency string) Option {
+	return func(p *Provider) {
+		p.currency = strings.ToLower(currency)
+	}
+}
+
+// WithPriceOracle sets how x402 prices convert to the card currency (default stablecoins at par)
+func WithPriceOracle(oracle xtended402.PriceOracle) Option {
+	return func(p *Provider) {
+		p.oracle = oracle
+	}
+}
+
+// WithSessionStore sets where paid sessions are recorded (default in memory)
+func WithSessionStore(store SessionStore) Option {
+	return func(p *Provider) {
+		p.store = store
+	}
+}
+
+// WithProductName sets the line item name shown on the Checkout page (default the route's
+// resource, e.g. "POST /api/purchase")
+func WithProductName(name func(resource string, requirements x402types.PaymentRequirements) string) Option {
+	return func(p *Provider) {
+		p.product = name
+	}
+}
+
+// WithAPIEndpoint overrides the Stripe API base URL (e.g. for stripe-mock)
+func WithAPIEndpoint(endpoint string) Option {
+	return func(p *Provider) {
+		p.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client for Stripe API calls (default 10s timeout)
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *Provider) {
+		p.client = client
+	}
+}
+
+// WithClock sets the clock for webhook signature age checks (default SystemClock)
+func WithClock(clock xtended402.Clock) Option {
+	return func(p *Provider) {
+		p.clock = xtended402.ClockOrSystem(clock)
+	}
+}
+
+// WithSignatureTolerance sets how old a webhook signature may be (default 5m)
+func WithSignatureTolerance(tolerance time.Duration) Option {
+	return func(p *Provider) {
+		p.tolerance = tolerance
+	}
+}
+
+// New creates a Provider using a Stripe secret key and the signing secret of the webhook
+// endpoint served by WebhookHandler
+func New(secretKey, webhookSecret string, opts ...Option) *Provider {
+	p := &Provider{
+		secretKey:     secretKey,
+		webhookSecret: []byte(webhookSecret),
+		endpoint:      APIEndpoint,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		currency:      "usd",
+		oracle:        xtended402.NewStablecoinPriceOracle(),
+		store:         NewMemorySessionStore(),
+		clock:         xtended402.SystemClock,
+		tolerance:     5 * time.Minute,
+		product: func(resource string, requirements x402types.PaymentRequirements) string {
+			return resource
+		},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// checkoutSession is the part of a Stripe Checkout Session this package reads
+type checkoutSession struct {
+	ID              string            `json:"id"`
+	URL             string            `json:"url"`
+	ExpiresAt       int64             `json:"expires_at"`
+	AmountTotal     int64             `json:"amount_total"`
+	Currency        string            `json:"currency"`
+	PaymentStatus   string            `json:"payment_status"`
+	PaymentIntent   string            `json:"payment_intent"`
+	Metadata        map[string]string `json:"metadata"`
+	CustomerDetails *struct {
+		Email string `json:"email"`
+	} `json:"customer_details"`
+}
+
+// Session metadata keys recording the x402 price a session was created for
+const (
+	metadataResource = "x402_resource"
+	metadataNetwork  = "x402_network"
+	metadataAsset    = "x402_asset"
+	metadataAmount   = "x402_amount"
+)
+
+// Checkout creates a Checkout Session charging the card currency equivalent of requirements,
+// rounded up to the next cent. Stripe rejects charges below its minimum (0.50 USD).
+func (p *Provider) Checkout(ctx context.Context, resource string, requirements x402types.PaymentRequirements) (*xtended402.FiatCheckout, error) {
+	value, err := xtended402.FiatValue(ctx, p.oracle, x402.Network(requirements.Network), requirements.Asset, requirements.Amount, p.currency, p.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("stripe: failed to price %s: %w", resource, err)
+	}
+	cents := minorUnits(value)
+
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", p.successURL)
+	if p.cancelURL != "" {
+		form.Set("cancel_url", p.cancelURL)
+	}
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", p.currency)
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(cents, 10))
+	form.Set("line_items[0][price_data][product_data][name]", p.product(resource, requirements))
+	form.Set("metadata["+metadataResource+"]", resource)
+	form.Set("metadata["+metadataNetwork+"]", requirements.Network)
+	form.Set("metadata["+metadataAsset+"]", requirements.Asset)
+	form.Set("metadata["+metadataAmount+"]", requirements.Amount)
+
+	var session checkoutSession
+	if err := p.post(ctx, "/v1/checkout/sessions", form, &session); err != nil {
+		return nil, err
+	}
+
+	checkout := &xtended402.FiatCheckout{
+		Provider:  ProviderName,
+		URL:       session.URL,
+		Reference: session.ID,
+		Amount:    formatMinorUnits(cents),
+		Currency:  strings.ToUpper(p.currency),
+	}
+	if session.ExpiresAt > 0 {
+		checkout.ExpiresAt = time.Unix(session.ExpiresAt, 0).UTC()
+	}
+	return checkout, nil
+}
+
+// Redeem claims a paid session as payment for one request to resource. The session must have
+// been created for resource at a price covering requirements.
+func (p *Provider) Redeem(ctx context.Context, resource, reference string, requirements x402types.PaymentRequirements) (*xtended402.FiatPayment, error) {
+	session, err := p.store.ClaimSession(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", xtended402.ErrFiatPaymentInvalid, err)
+	}
+
+	if err := covers(session, resource, requirements); err != nil {
+		_ = p.store.ReleaseSession(ctx, reference)
+		return nil, fmt.Errorf("%w: %v", xtended402.ErrFiatPaymentInvalid, err)
+	}
+
+	return &xtended402.FiatPayment{
+		Provider:    ProviderName,
+		Reference:   session.ID,
+		Transaction: session.PaymentIntent,
+		Amount:      formatMinorUnits(session.AmountTotal),
+		Currency:    strings.ToUpper(session.Currency),
+		Customer:    session.Email,
+	}, nil
+}
+
+// Release makes a redeemed session usable again
+func (p *Provider) Release(ctx context.Context, reference string) error {
+	return p.store.ReleaseSession(ctx, reference)
+}
+
+// covers checks that session paid for resource at least the price in requirements
+func covers(session *Session, resource string, requirements x402types.PaymentRequirements) error {
+	if session.Resource != resource {
+		return fmt.Errorf("session was paid for %s", session.Resource)
+	}
+	if session.Network != requirements.Network || !strings.EqualFold(session.Asset, requirements.Asset) {
+		return fmt.Errorf("session was priced in %s on %s", session.Asset, session.Network)
+	}
+
+	paid, ok := new(big.Int).SetString(session.Amount, 10)
+	required, ok2 := new(big.Int).SetString(requirements.Amount, 10)
+	if !ok || !ok2 {
+		return fmt.Errorf("invalid amount")
+	}
+	if paid.Cmp(required) < 0 {
+		return fmt.Errorf("session covers %s, price is now %s", session.Amount, requirements.Amount)
+	}
+	return nil
+}
+
+func (p *Provider) post(ctx context.Context, path string, form url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("stripe: %w", err)
+	}
+	req.SetBasicAuth(p.secretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("stripe: failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiError struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &apiError) == nil && apiError.Error.Message != "" {
+			return fmt.Errorf("stripe: %s (%d)", apiError.Error.Message, resp.StatusCode)
+		}
+		return fmt.Errorf("stripe: request failed (%d)", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("stripe: invalid response: %w", err)
+	}
+	return nil
+}
+
+// minorUnits converts a currency value to cents, rounding up
+func minorUnits(value *big.Rat) int64 {
+	cents := new(big.Rat).Mul(value, big.NewRat(100, 1))
+	q, r := new(big.Int).QuoRem(cents.Num(), cents.Denom(), new(big.Int))
+	if r.Sign() > 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return q.Int64()
+}
+
+func formatMinorUnits(cents int64) string {
+	return big.NewRat(cents, 100).FloatString(2)
+}
@@ -0,0 +1,134 @@
+package stripe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the header Stripe signs webhook deliveries with
+const SignatureHeader = "Stripe-Signature"
+
+// WebhookHandler receives Stripe webhook deliveries. It verifies each delivery's signature and
+// records sessions from checkout.session.completed and
+// checkout.session.async_payment_succeeded events once they're paid; other events are
+// acknowledged and ignored.
+func (p *Provider) WebhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		if err := p.VerifySignature(r.Header.Get(SignatureHeader), payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var event struct {
+			Type string `json:"type"`
+			Data struct {
+				Object checkoutSession `json:"object"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			http.Error(w, "invalid event", http.StatusBadRequest)
+			return
+		}
+
+		switch event.Type {
+		case "checkout.session.completed", "checkout.session.async_payment_succeeded":
+			if err := p.recordSession(r, event.Data.Object); err != nil {
+				// Stripe retries non-2xx deliveries
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (p *Provider) recordSession(r *http.Request, object checkoutSession) error {
+	if object.PaymentStatus != "paid" {
+		return nil
+	}
+
+	// Sessions not created by Checkout belong to something else on the account
+	resource := object.Metadata[metadataResource]
+	if resource == "" {
+		return nil
+	}
+
+	session := &Session{
+		ID:            object.ID,
+		Resource:      resource,
+		Network:       object.Metadata[metadataNetwork],
+		Asset:         object.Metadata[metadataAsset],
+		Amount:        object.Metadata[metadataAmount],
+		AmountTotal:   object.AmountTotal,
+		Currency:      object.Currency,
+		PaymentIntent: object.PaymentIntent,
+		PaidAt:        p.clock.Now().UTC(),
+	}
+	if object.CustomerDetails != nil {
+		session.Email = object.CustomerDetails.Email
+	}
+
+	if err := p.store.SaveSession(r.Context(), session); err != nil {
+		return fmt.Errorf("failed to record session: %w", err)
+	}
+	return nil
+}
+
+// VerifySignature checks a Stripe-Signature header ("t=<unix>,v1=<hex>[,v1=...]") against the
+// raw payload
+func (p *Provider) VerifySignature(header string, payload []byte) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return errors.New("malformed stripe signature header")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid stripe signature timestamp: %w", err)
+	}
+	if p.tolerance > 0 {
+		age := p.clock.Now().Sub(time.Unix(unix, 0))
+		if age > p.tolerance || age < -p.tolerance {
+			return errors.New("stripe signature timestamp outside tolerance")
+		}
+	}
+
+	mac := hmac.New(sha256.New, p.webhookSecret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			return nil
+		}
+	}
+	return errors.New("stripe signature mismatch")
+}
@@ -0,0 +1,288 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+// QuoteHeader carries the ID of the accepted quote a follow-up call pays for
+const QuoteHeader = "X-Quote-ID"
+
+// QuoteIDMetadataKey is the event metadata key carrying the quote a payment paid for
+const QuoteIDMetadataKey = "quoteId"
+
+// Quote statuses
+const (
+	QuoteOffered  = "offered"
+	QuoteAccepted = "accepted"
+	QuoteDeclined = "declined"
+	QuotePaid     = "paid"
+	QuoteExpired  = "expired"
+)
+
+// ErrQuoteInvalid is returned when a quote is unknown, expired, or can't be accepted or paid
+// in its status
+var ErrQuoteInvalid = errors.New("quote invalid")
+
+// TaskRequest is what an agent asks a quote for: a task and its input
+type TaskRequest struct {
+	// Task is the route the task is performed at, e.g. "POST /agents/translate"
+	Task string `json:"task"`
+
+	// Input describes the work, for the quoter to price and the handler to perform
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// TaskQuote is a price offered for a task, which the agent accepts, possibly much later, and
+// then pays for with a follow-up call to the task's route carrying QuoteHeader
+type TaskQuote struct {
+	ID    string          `json:"id"`
+	Task  string          `json:"task"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// Price is what the follow-up call costs: a money string like "$0.25" or an
+	// x402.AssetAmount
+	Price x402.Price `json:"price"`
+
+	// Status is QuoteOffered, QuoteAccepted, QuoteDeclined, QuotePaid, or QuoteExpired
+	Status string `json:"status"`
+
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+
+	// Transaction is the settlement transaction once paid
+	Transaction string `json:"transaction,omitempty"`
+}
+
+// TaskQuoter prices a task. Errors are returned to the agent, e.g. for a task the server
+// doesn't perform.
+type TaskQuoter func(ctx context.Context, request TaskRequest) (x402.Price, error)
+
+// TaskQuotes implements the agent-to-agent quote flow: an agent asks for a quote for a task,
+// accepts it before it expires, then calls the task's route with QuoteHeader and pays the
+// quoted price. Price the route with QuotePrice. Each quote pays for one call. It's safe for
+// concurrent use.
+//
+// Quotes are held in memory, so run one replica or route an agent's requests to the same one.
+type TaskQuotes struct {
+	quoter TaskQuoter
+	clock  Clock
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	quotes map[string]*TaskQuote
+}
+
+// TaskQuoteOption configures TaskQuotes
+type TaskQuoteOption func(*TaskQuotes)
+
+// WithTaskQuoteTTL sets how long a quote can be accepted and paid (default 1 hour)
+func WithTaskQuoteTTL(ttl time.Duration) TaskQuoteOption {
+	return func(q *TaskQuotes) {
+		q.ttl = ttl
+	}
+}
+
+// WithTaskQuoteClock sets the clock used for expiry (defaults to the system clock)
+func WithTaskQuoteClock(clock Clock) TaskQuoteOption {
+	return func(q *TaskQuotes) {
+		q.clock = clock
+	}
+}
+
+// NewTaskQuotes creates TaskQuotes pricing tasks with quoter
+func NewTaskQuotes(quoter TaskQuoter, opts ...TaskQuoteOption) *TaskQuotes {
+	q := &TaskQuotes{
+		quoter: quoter,
+		ttl:    time.Hour,
+		quotes: make(map[string]*TaskQuote),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.clock = ClockOrSystem(q.clock)
+	return q
+}
+
+// Quote prices request and offers the quote
+func (q *TaskQuotes) Quote(ctx context.Context, request TaskRequest) (*TaskQuote, error) {
+	pattern := ParseRoutePattern(request.Task)
+	if pattern.Method() == "*" || !strings.HasPrefix(pattern.Path(), "/") {
+		return nil, fmt.Errorf("%w: task must be a route like \"POST /path\", got %q", ErrQuoteInvalid, request.Task)
+	}
+	price, err := q.quoter(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newQuoteID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quote ID: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.clock.Now()
+	q.sweepLocked(now)
+	quote := &TaskQuote{
+		ID:        id,
+		Task:      request.Task,
+		Input:     request.Input,
+		Price:     price,
+		Status:    QuoteOffered,
+		ExpiresAt: now.Add(q.ttl),
+	}
+	q.quotes[id] = quote
+	return copyQuote(quote), nil
+}
+
+// Get returns the quote with id
+func (q *TaskQuotes) Get(id string) (*TaskQuote, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	quote, err := q.findLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	return copyQuote(quote), nil
+}
+
+// Accept accepts an offered quote, so a follow-up call can pay for it
+func (q *TaskQuotes) Accept(id string) (*TaskQuote, error) {
+	return q.decide(id, QuoteAccepted)
+}
+
+// Decline declines an offered quote
+func (q *TaskQuotes) Decline(id string) (*TaskQuote, error) {
+	return q.decide(id, QuoteDeclined)
+}
+
+func (q *TaskQuotes) decide(id, status string) (*TaskQuote, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	quote, err := q.findLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	if quote.Status == status {
+		return copyQuote(quote), nil
+	}
+	if quote.Status != QuoteOffered {
+		return nil, fmt.Errorf("%w: quote is %s", ErrQuoteInvalid, quote.Status)
+	}
+	quote.Status = status
+	if status == QuoteAccepted {
+		now := q.clock.Now()
+		quote.AcceptedAt = &now
+	}
+	return copyQuote(quote), nil
+}
+
+// Payable returns the quote with id if a call to resource ("METHOD /path") can pay for it:
+// it's accepted, unpaid, unexpired, and for that route
+func (q *TaskQuotes) Payable(id, resource string) (*TaskQuote, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	quote, err := q.findLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	if quote.Status != QuoteAccepted {
+		return nil, fmt.Errorf("%w: quote is %s", ErrQuoteInvalid, quote.Status)
+	}
+	if !ParseRoutePattern(quote.Task).Matches(resource) {
+		return nil, fmt.Errorf("%w: quote is for %s", ErrQuoteInvalid, quote.Task)
+	}
+	return copyQuote(quote), nil
+}
+
+// MarkPaid records the settlement paying for the quote with id
+func (q *TaskQuotes) MarkPaid(id, transaction string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	quote, ok := q.quotes[id]
+	if !ok {
+		return fmt.Errorf("%w: unknown quote", ErrQuoteInvalid)
+	}
+	quote.Status = QuotePaid
+	quote.Transaction = transaction
+	return nil
+}
+
+// findLocked returns the quote with id, marking it expired if it wasn't accepted and paid in
+// time
+func (q *TaskQuotes) findLocked(id string) (*TaskQuote, error) {
+	quote, ok := q.quotes[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown quote", ErrQuoteInvalid)
+	}
+	if (quote.Status == QuoteOffered || quote.Status == QuoteAccepted) && q.clock.Now().After(quote.ExpiresAt) {
+		quote.Status = QuoteExpired
+	}
+	return quote, nil
+}
+
+// sweepLocked drops quotes a TTL past their expiry
+func (q *TaskQuotes) sweepLocked(now time.Time) {
+	for id, quote := range q.quotes {
+		if now.After(quote.ExpiresAt.Add(q.ttl)) {
+			delete(q.quotes, id)
+		}
+	}
+}
+
+func copyQuote(quote *TaskQuote) *TaskQuote {
+	copied := *quote
+	return &copied
+}
+
+func newQuoteID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return "q_" + hex.EncodeToString(id), nil
+}
+
+type quoteContextKey struct{}
+
+// ContextWithQuote returns a context carrying the quote a request pays for. The Gin middleware
+// adds it for requests with QuoteHeader.
+func ContextWithQuote(ctx context.Context, quote *TaskQuote) context.Context {
+	return context.WithValue(ctx, quoteContextKey{}, quote)
+}
+
+// QuoteFromContext returns the quote in ctx, or nil. Task handlers read the quoted input
+// from it.
+func QuoteFromContext(ctx context.Context) *TaskQuote {
+	quote, _ := ctx.Value(quoteContextKey{}).(*TaskQuote)
+	return quote
+}
+
+// QuotePrice creates a DynamicPriceFunc charging the price of the quote a request pays for.
+// Requests without a quote are charged fallback, or rejected when fallback is nil.
+func QuotePrice(fallback x402.Price) x402http.DynamicPriceFunc {
+	return func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (x402.Price, error) {
+		if quote := QuoteFromContext(ctx); quote != nil {
+			return quote.Price, nil
+		}
+		if fallback == nil {
+			return nil, fmt.Errorf("an accepted quote is required: send its ID in %s", QuoteHeader)
+		}
+		return fallback, nil
+	}
+}
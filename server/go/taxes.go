@@ -0,0 +1,302 @@
+package xtended402
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+)
+
+const (
+	// TaxMetadataKey is the event metadata key carrying the tax included in a payment, in the
+	// price's units (e.g. "1.90" for a money price)
+	TaxMetadataKey = "tax"
+
+	// TaxNetMetadataKey is the event metadata key carrying the price before tax
+	TaxNetMetadataKey = "taxNet"
+
+	// TaxRateMetadataKey is the event metadata key carrying the tax rate, e.g. "0.19"
+	TaxRateMetadataKey = "taxRate"
+
+	// TaxNameMetadataKey is the event metadata key carrying the tax's name, e.g. "VAT"
+	TaxNameMetadataKey = "taxName"
+
+	// TaxJurisdictionMetadataKey is the event metadata key carrying the buyer's tax
+	// jurisdiction, e.g. "DE" or "US-CA"
+	TaxJurisdictionMetadataKey = "taxJurisdiction"
+)
+
+// JurisdictionKey is where the application puts the buyer's tax jurisdiction, e.g. from their
+// billing address, for JurisdictionFromContext
+var JurisdictionKey = NewContextKey[string]("taxJurisdiction")
+
+// TaxBreakdown is the tax on one price. Amounts are in the price's units: money for money
+// prices, the asset's smallest unit for asset amounts.
+type TaxBreakdown struct {
+	// Jurisdiction is the buyer's tax jurisdiction, e.g. "DE" or "US-CA"
+	Jurisdiction string `json:"jurisdiction"`
+
+	// Name is the tax's name, e.g. "VAT"
+	Name string `json:"name,omitempty"`
+
+	// Rate is the tax rate, e.g. "0.19"
+	Rate string `json:"rate"`
+
+	Net   string `json:"net"`
+	Tax   string `json:"tax"`
+	Gross string `json:"gross"`
+}
+
+// TaxCalculator works out the tax on a price for the buyer of a request. price is the route's
+// price before the calculator applies: net, or gross for tax-inclusive pricing. decimals is
+// the precision amounts round to. It returns nil when the buyer owes no tax.
+type TaxCalculator interface {
+	CalculateTax(ctx context.Context, reqCtx x402http.HTTPRequestContext, price *big.Rat, decimals int) (*TaxBreakdown, error)
+}
+
+// TaxCalculatorFunc adapts a function to TaxCalculator
+type TaxCalculatorFunc func(ctx context.Context, reqCtx x402http.HTTPRequestContext, price *big.Rat, decimals int) (*TaxBreakdown, error)
+
+// CalculateTax calls f
+func (f TaxCalculatorFunc) CalculateTax(ctx context.Context, reqCtx x402http.HTTPRequestContext, price *big.Rat, decimals int) (*TaxBreakdown, error) {
+	return f(ctx, reqCtx, price, decimals)
+}
+
+// TaxedPrice creates a DynamicPriceFunc charging price, an x402.Price or DynamicPriceFunc,
+// adjusted by calculator for the buyer's jurisdiction. Money prices (e.g. "$10.00") are taxed
+// to the cent, or to their own precision when finer; asset amounts to the smallest unit.
+//
+// The Gin middleware records the breakdown with the payment: handlers read it from
+// PaymentData.Tax, and payment events carry it in their metadata (see TaxMetadataKey).
+func TaxedPrice(price interface{}, calculator TaxCalculator) x402http.DynamicPriceFunc {
+	return func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (x402.Price, error) {
+		resolved := x402.Price(price)
+		if dynamic, ok := price.(x402http.DynamicPriceFunc); ok {
+			var err error
+			if resolved, err = dynamic(ctx, reqCtx); err != nil {
+				return nil, err
+			}
+		}
+
+		if amount, ok := resolved.(x402.AssetAmount); ok {
+			units, ok := new(big.Rat).SetString(amount.Amount)
+			if !ok {
+				return nil, fmt.Errorf("tax: invalid asset amount %q", amount.Amount)
+			}
+			breakdown, err := calculator.CalculateTax(ctx, reqCtx, units, 0)
+			if err != nil || breakdown == nil {
+				return resolved, err
+			}
+			recordTax(ctx, breakdown)
+			taxed := copyAssetAmount(amount)
+			taxed.Amount = breakdown.Gross
+			return taxed, nil
+		}
+
+		money, decimals, err := parseMoney(resolved)
+		if err != nil {
+			return nil, err
+		}
+		breakdown, err := calculator.CalculateTax(ctx, reqCtx, money, decimals)
+		if err != nil || breakdown == nil {
+			return resolved, err
+		}
+		recordTax(ctx, breakdown)
+		return "$" + breakdown.Gross, nil
+	}
+}
+
+// parseMoney parses a money price, e.g. "$1.50", "1.50", or 1.5, returning its precision: at
+// least two decimal places
+func parseMoney(price x402.Price) (*big.Rat, int, error) {
+	var text string
+	switch value := price.(type) {
+	case string:
+		text = strings.TrimPrefix(strings.TrimSpace(value), "$")
+	case float64:
+		text = strconv.FormatFloat(value, 'f', -1, 64)
+	case int:
+		text = strconv.Itoa(value)
+	case int64:
+		text = strconv.FormatInt(value, 10)
+	default:
+		return nil, 0, fmt.Errorf("tax: unsupported price type %T", price)
+	}
+
+	amount, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil, 0, fmt.Errorf("tax: invalid price %q", text)
+	}
+	decimals := 2
+	if dot := strings.IndexByte(text, '.'); dot >= 0 && len(text)-dot-1 > decimals {
+		decimals = len(text) - dot - 1
+	}
+	return amount, decimals, nil
+}
+
+// ============================================================================
+// Tax Tables
+// ============================================================================
+
+// TaxRate is a jurisdiction's tax on a sale
+type TaxRate struct {
+	// Name is the tax's name, e.g. "VAT" or "Sales tax"
+	Name string
+
+	// Rate is the tax rate as a decimal, e.g. "0.19" for 19%
+	Rate string
+}
+
+// JurisdictionFunc returns the buyer's tax jurisdiction for a request, e.g. "DE" or "US-CA",
+// or "" when it's unknown
+type JurisdictionFunc func(ctx context.Context, reqCtx x402http.HTTPRequestContext) string
+
+// JurisdictionFromContext reads the jurisdiction the application set under JurisdictionKey
+func JurisdictionFromContext(ctx context.Context, reqCtx x402http.HTTPRequestContext) string {
+	jurisdiction, _ := JurisdictionKey.Value(ctx)
+	return jurisdiction
+}
+
+// JurisdictionFromHeader reads the jurisdiction from a request header set by a proxy, e.g.
+// Cloudflare's "CF-IPCountry"
+func JurisdictionFromHeader(name string) JurisdictionFunc {
+	return func(ctx context.Context, reqCtx x402http.HTTPRequestContext) string {
+		return reqCtx.Adapter.GetHeader(name)
+	}
+}
+
+// JurisdictionFromLocale reads the region of the buyer's preferred language, e.g. "DE" for
+// "Accept-Language: de-DE"
+func JurisdictionFromLocale(ctx context.Context, reqCtx x402http.HTTPRequestContext) string {
+	tag, _, _ := strings.Cut(reqCtx.Adapter.GetHeader("Accept-Language"), ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	_, region, ok := strings.Cut(normalizeLocale(tag), "-")
+	if !ok || len(region) != 2 {
+		return ""
+	}
+	return region
+}
+
+// TaxTable is a TaxCalculator with a fixed rate per jurisdiction:
+//
+//	taxes := &xtended402.TaxTable{
+//		Rates: map[string]xtended402.TaxRate{
+//			"DE": {Name: "VAT", Rate: "0.19"},
+//			"FR": {Name: "VAT", Rate: "0.20"},
+//		},
+//		Jurisdiction: xtended402.JurisdictionFromHeader("CF-IPCountry"),
+//	}
+//	Price: xtended402.TaxedPrice("$10.00", taxes),
+type TaxTable struct {
+	// Rates are keyed by jurisdiction, e.g. "DE" or "US-CA". A subdivision without a rate of
+	// its own is taxed at its country's ("US" for "US-CA"). Buyers in jurisdictions without a
+	// rate owe no tax.
+	Rates map[string]TaxRate
+
+	// Jurisdiction resolves the buyer's jurisdiction (default JurisdictionFromContext, then
+	// JurisdictionFromLocale)
+	Jurisdiction JurisdictionFunc
+
+	// Inclusive treats prices as already including tax, which is split out of them instead of
+	// added, as EU consumer prices are shown
+	Inclusive bool
+}
+
+// CalculateTax looks up the buyer's rate and applies it to price
+func (t *TaxTable) CalculateTax(ctx context.Context, reqCtx x402http.HTTPRequestContext, price *big.Rat, decimals int) (*TaxBreakdown, error) {
+	jurisdiction := t.jurisdiction(ctx, reqCtx)
+	rate, ok := t.Rates[jurisdiction]
+	if !ok {
+		country, _, _ := strings.Cut(jurisdiction, "-")
+		if rate, ok = t.Rates[country]; !ok {
+			return nil, nil
+		}
+	}
+	multiplier, ok := new(big.Rat).SetString(rate.Rate)
+	if !ok {
+		return nil, fmt.Errorf("tax: invalid rate %q for %s", rate.Rate, jurisdiction)
+	}
+
+	var net, tax *big.Rat
+	if t.Inclusive {
+		// gross / (1 + rate), with the tax as the rounded remainder so the parts sum to the price
+		net = new(big.Rat).Quo(price, new(big.Rat).Add(big.NewRat(1, 1), multiplier))
+		net = roundRat(net, decimals)
+		tax = new(big.Rat).Sub(price, net)
+	} else {
+		net = price
+		tax = roundRat(new(big.Rat).Mul(price, multiplier), decimals)
+	}
+	gross := new(big.Rat).Add(net, tax)
+
+	return &TaxBreakdown{
+		Jurisdiction: jurisdiction,
+		Name:         rate.Name,
+		Rate:         rate.Rate,
+		Net:          net.FloatString(decimals),
+		Tax:          tax.FloatString(decimals),
+		Gross:        gross.FloatString(decimals),
+	}, nil
+}
+
+func (t *TaxTable) jurisdiction(ctx context.Context, reqCtx x402http.HTTPRequestContext) string {
+	var jurisdiction string
+	if t.Jurisdiction != nil {
+		jurisdiction = t.Jurisdiction(ctx, reqCtx)
+	} else if jurisdiction = JurisdictionFromContext(ctx, reqCtx); jurisdiction == "" {
+		jurisdiction = JurisdictionFromLocale(ctx, reqCtx)
+	}
+	return strings.ToUpper(strings.TrimSpace(jurisdiction))
+}
+
+// roundRat rounds a non-negative value half up to decimals places
+func roundRat(value *big.Rat, decimals int) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	scaled := new(big.Rat).Mul(value, new(big.Rat).SetInt(scale))
+	num := new(big.Int).Mul(scaled.Num(), big.NewInt(2))
+	num.Add(num, scaled.Denom())
+	rounded := num.Quo(num, new(big.Int).Mul(scaled.Denom(), big.NewInt(2)))
+	return new(big.Rat).SetFrac(rounded, scale)
+}
+
+// ============================================================================
+// Tax Records
+// ============================================================================
+
+type taxContextKey struct{}
+
+// taxRecord holds the tax worked out while pricing a request
+type taxRecord struct {
+	mu        sync.Mutex
+	breakdown *TaxBreakdown
+}
+
+// ContextWithTaxes returns a context recording the tax TaxedPrice prices work out, for
+// TaxFromContext. The Gin middleware adds it to paid requests.
+func ContextWithTaxes(ctx context.Context) context.Context {
+	return context.WithValue(ctx, taxContextKey{}, &taxRecord{})
+}
+
+// TaxFromContext returns the tax worked out while pricing the request, or nil
+func TaxFromContext(ctx context.Context) *TaxBreakdown {
+	record, _ := ctx.Value(taxContextKey{}).(*taxRecord)
+	if record == nil {
+		return nil
+	}
+	record.mu.Lock()
+	defer record.mu.Unlock()
+	return record.breakdown
+}
+
+func recordTax(ctx context.Context, breakdown *TaxBreakdown) {
+	if record, _ := ctx.Value(taxContextKey{}).(*taxRecord); record != nil {
+		record.mu.Lock()
+		record.breakdown = breakdown
+		record.mu.Unlock()
+	}
+}
@@ -0,0 +1,204 @@
+package xtended402
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	texttemplate "text/template"
+
+	x402 "github.com/coinbase/x402/go"
+)
+
+// TemplateData is what customer-facing message templates render, shared by receipts, Slack and
+// Discord notifications, and templated webhooks
+type TemplateData struct {
+	Event PaymentEvent
+
+	// Amount is the formatted amount, e.g. "4.50 USDC"
+	Amount string
+
+	// Payer is the payer's address (or the card customer's email)
+	Payer string
+
+	// PayerName is the payer's resolved name, e.g. "alice.base.eth", or ""
+	PayerName string
+
+	// TxURL links to the transaction on a block explorer, or is "" when unknown
+	TxURL string
+
+	// Order is the application's order for the event, from WithTemplateOrder
+	Order interface{}
+
+	// Vars holds the variables set with WithTemplateVars
+	Vars map[string]interface{}
+}
+
+// TemplateFuncs are available in every message template:
+//
+//	short   abbreviates an address: {{short .Payer}} → "0x1234…abcd"
+//	upper   and lower change case
+//	json    encodes a value as JSON, for webhook bodies: {"text": {{json .Amount}}}
+//	default returns its first argument when the second is empty: {{default "guest" .Payer}}
+var TemplateFuncs = texttemplate.FuncMap{
+	"short": ShortAddress,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// EventTemplates renders one text/template per event type, with variables injected from the
+// event, the application's order, and fixed values:
+//
+//	templates, err := xtended402.ParseEventTemplates(map[xtended402.PaymentEventType]string{
+//		xtended402.EventPaymentSettled:  "Order {{.Order.ID}} paid: {{.Amount}} from {{short .Payer}} {{.TxURL}}",
+//		xtended402.EventPaymentRefunded: "Order {{.Order.ID}} refunded: {{.Amount}}",
+//	}, xtended402.WithTemplateOrder(lookupOrder))
+//
+// Pass the same EventTemplates to notify.WithTemplates, receipts.WithEventTemplates, and
+// WithWebhookTemplates so every channel shares its variables.
+type EventTemplates struct {
+	templates map[PaymentEventType]*texttemplate.Template
+	order     func(ctx context.Context, event PaymentEvent) interface{}
+	vars      map[string]interface{}
+	explorer  func(network x402.Network, transaction string) string
+}
+
+// TemplateOption configures EventTemplates
+type TemplateOption func(*EventTemplates)
+
+// WithTemplateOrder sets how .Order is found for an event. ctx is the paid request's context
+// when the event is first handled, but not when a dead letter is replayed, so prefer looking
+// the order up by event metadata.
+func WithTemplateOrder(order func(ctx context.Context, event PaymentEvent) interface{}) TemplateOption {
+	return func(t *EventTemplates) {
+		t.order = order
+	}
+}
+
+// WithTemplateVars sets fixed variables available as .Vars, such as a store name or support
+// email
+func WithTemplateVars(vars map[string]interface{}) TemplateOption {
+	return func(t *EventTemplates) {
+		t.vars = vars
+	}
+}
+
+// WithTemplateExplorer sets how .TxURL is built (default TransactionURL)
+func WithTemplateExplorer(explorer func(network x402.Network, transaction string) string) TemplateOption {
+	return func(t *EventTemplates) {
+		t.explorer = explorer
+	}
+}
+
+// ParseEventTemplates parses a text/template for each event type. Event types without a
+// template are left to each integration's default message.
+func ParseEventTemplates(templates map[PaymentEventType]string, opts ...TemplateOption) (*EventTemplates, error) {
+	t := &EventTemplates{
+		templates: make(map[PaymentEventType]*texttemplate.Template, len(templates)),
+		explorer:  TransactionURL,
+	}
+
+	for eventType, text := range templates {
+		parsed, err := texttemplate.New(string(eventType)).Funcs(TemplateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %w", eventType, err)
+		}
+		t.templates[eventType] = parsed
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t, nil
+}
+
+// MustParseEventTemplates is like ParseEventTemplates but panics on an invalid template
+func MustParseEventTemplates(templates map[PaymentEventType]string, opts ...TemplateOption) *EventTemplates {
+	t, err := ParseEventTemplates(templates, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Data builds the template data for event
+func (t *EventTemplates) Data(ctx context.Context, event PaymentEvent) TemplateData {
+	data := TemplateData{
+		Event:     event,
+		Amount:    event.DisplayAmount(),
+		Payer:     event.Payer,
+		PayerName: event.Metadata[PayerNameMetadataKey],
+		Vars:      t.vars,
+	}
+	if event.Transaction != "" && t.explorer != nil {
+		data.TxURL = t.explorer(event.Network, event.Transaction)
+	}
+	if t.order != nil {
+		data.Order = t.order(ctx, event)
+	}
+	return data
+}
+
+// Has reports whether there is a template for eventType
+func (t *EventTemplates) Has(eventType PaymentEventType) bool {
+	return t.templates[eventType] != nil
+}
+
+// Render renders the template for event's type. ok is false when there is none.
+func (t *EventTemplates) Render(ctx context.Context, event PaymentEvent) (text string, ok bool, err error) {
+	tmpl := t.templates[event.Type]
+	if tmpl == nil {
+		return "", false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, t.Data(ctx, event)); err != nil {
+		return "", true, fmt.Errorf("failed to render %s template: %w", event.Type, err)
+	}
+	return buf.String(), true, nil
+}
+
+// explorers are the block explorers of networks with a known transaction page
+var explorers = map[x402.Network]string{
+	"eip155:1":        "https://etherscan.io/tx/",
+	"eip155:11155111": "https://sepolia.etherscan.io/tx/",
+	"eip155:8453":     "https://basescan.org/tx/",
+	"eip155:84532":    "https://sepolia.basescan.org/tx/",
+	"eip155:10":       "https://optimistic.etherscan.io/tx/",
+	"eip155:42161":    "https://arbiscan.io/tx/",
+	"eip155:137":      "https://polygonscan.com/tx/",
+	"eip155:80002":    "https://amoy.polygonscan.com/tx/",
+	"eip155:43114":    "https://snowtrace.io/tx/",
+	"eip155:43113":    "https://testnet.snowtrace.io/tx/",
+}
+
+// TransactionURL links to transaction on network's block explorer, or returns "" for networks
+// without a known explorer (including card payments)
+func TransactionURL(network x402.Network, transaction string) string {
+	base, ok := explorers[network]
+	if !ok || transaction == "" {
+		return ""
+	}
+	return base + transaction
+}
+
+// ShortAddress abbreviates a hex address to its first six and last four characters, e.g.
+// "0x1234…abcd"
+func ShortAddress(address string) string {
+	if len(address) <= 12 {
+		return address
+	}
+	return address[:6] + "…" + address[len(address)-4:]
+}
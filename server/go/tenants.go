@@ -0,0 +1,257 @@
+package xtended402
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+)
+
+// TenantMetadataKey is the PaymentEvent.Metadata key naming the tenant a payment was made to
+const TenantMetadataKey = "tenant"
+
+// ErrTenantNotFound is returned when a request names no tenant or an unknown one
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// Tenant is a merchant collecting payments through a shared deployment
+type Tenant struct {
+	ID string `json:"id"`
+
+	// PayTo receives the tenant's payments
+	PayTo string `json:"payTo"`
+
+	// Price is the tenant's price for every route (nil uses the route's default)
+	Price x402.Price `json:"price,omitempty"`
+
+	// Prices overrides Price per route pattern, e.g. "GET /reports/*"
+	Prices map[string]x402.Price `json:"prices,omitempty"`
+
+	// Facilitator verifies and settles the tenant's payments (nil uses the default)
+	Facilitator x402.FacilitatorClient `json:"-"`
+}
+
+// PriceFor returns the tenant's price for resource ("METHOD /path"), or nil if it has none
+func (t *Tenant) PriceFor(resource string) x402.Price {
+	var matched string
+	for pattern := range t.Prices {
+		// The longest matching pattern is the most specific
+		if ParseRoutePattern(pattern).Matches(resource) && len(pattern) > len(matched) {
+			matched = pattern
+		}
+	}
+	if matched != "" {
+		return t.Prices[matched]
+	}
+	return t.Price
+}
+
+// TenantStore looks up tenants by ID
+type TenantStore interface {
+	GetTenant(ctx context.Context, id string) (*Tenant, error)
+}
+
+// TenantResolver extracts the tenant ID from a request, or "" if it names none
+type TenantResolver func(r *http.Request) string
+
+// TenantFromHost resolves the tenant from the request's subdomain of domain, so
+// "acme.pay.example.com" is tenant "acme" under "pay.example.com". With an empty domain, the
+// whole host is the tenant ID, for tenants on their own domains.
+func TenantFromHost(domain string) TenantResolver {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	return func(r *http.Request) string {
+		host := strings.ToLower(r.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if domain == "" {
+			return host
+		}
+		subdomain, ok := strings.CutSuffix(host, "."+domain)
+		if !ok || strings.Contains(subdomain, ".") {
+			return ""
+		}
+		return subdomain
+	}
+}
+
+// TenantFromHeader resolves the tenant from a request header, e.g. one set by a gateway
+func TenantFromHeader(name string) TenantResolver {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// TenantFromPathPrefix resolves the tenant from the first path segment, so "/acme/api/report"
+// is tenant "acme". Routes must include the segment, e.g. "GET /[tenant]/api/report".
+func TenantFromPathPrefix() TenantResolver {
+	return func(r *http.Request) string {
+		segment, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		return segment
+	}
+}
+
+// Tenants resolves the tenant behind each request
+type Tenants struct {
+	resolver TenantResolver
+	store    TenantStore
+}
+
+// NewTenants creates Tenants resolving IDs with resolver and looking them up in store
+func NewTenants(resolver TenantResolver, store TenantStore) *Tenants {
+	return &Tenants{resolver: resolver, store: store}
+}
+
+// Resolve returns the request's tenant, or ErrTenantNotFound
+func (t *Tenants) Resolve(r *http.Request) (*Tenant, error) {
+	id := t.resolver(r)
+	if id == "" {
+		return nil, ErrTenantNotFound
+	}
+	return t.store.GetTenant(r.Context(), id)
+}
+
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a context carrying tenant. The Gin middleware adds it with
+// WithTenants.
+func ContextWithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant in ctx, or nil
+func TenantFromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantContextKey{}).(*Tenant)
+	return tenant
+}
+
+// GetTenant returns the tenant the request pays, or nil without WithTenants
+func GetTenant(c *gin.Context) *Tenant {
+	return TenantFromContext(c.Request.Context())
+}
+
+// TenantPayTo creates a DynamicPayToFunc paying the request's tenant
+func TenantPayTo() x402http.DynamicPayToFunc {
+	return func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (string, error) {
+		tenant := TenantFromContext(ctx)
+		if tenant == nil {
+			return "", ErrTenantNotFound
+		}
+		if tenant.PayTo == "" {
+			return "", fmt.Errorf("tenant %s has no payTo address", tenant.ID)
+		}
+		return tenant.PayTo, nil
+	}
+}
+
+// TenantPrice creates a DynamicPriceFunc charging the tenant's price for the route, or
+// fallback if the tenant doesn't set one (nil fallback makes tenant prices required)
+func TenantPrice(fallback x402.Price) x402http.DynamicPriceFunc {
+	return func(ctx context.Context, reqCtx x402http.HTTPRequestContext) (x402.Price, error) {
+		tenant := TenantFromContext(ctx)
+		if tenant == nil {
+			return nil, ErrTenantNotFound
+		}
+		if price := tenant.PriceFor(reqCtx.Method + " " + reqCtx.Path); price != nil {
+			return price, nil
+		}
+		if fallback == nil {
+			return nil, fmt.Errorf("tenant %s has no price for %s %s", tenant.ID, reqCtx.Method, reqCtx.Path)
+		}
+		return fallback, nil
+	}
+}
+
+// ============================================================================
+// Tenant Facilitator
+// ============================================================================
+
+// TenantFacilitator is a FacilitatorClient that verifies and settles each payment with its
+// tenant's facilitator, falling back to a default. Capabilities come from the default, so
+// tenant facilitators must support the same schemes and networks.
+type TenantFacilitator struct {
+	fallback x402.FacilitatorClient
+}
+
+// NewTenantFacilitator creates a TenantFacilitator with a default facilitator
+func NewTenantFacilitator(fallback x402.FacilitatorClient) *TenantFacilitator {
+	return &TenantFacilitator{fallback: fallback}
+}
+
+// clientFor returns the facilitator for the tenant in ctx
+func (f *TenantFacilitator) clientFor(ctx context.Context) x402.FacilitatorClient {
+	if tenant := TenantFromContext(ctx); tenant != nil && tenant.Facilitator != nil {
+		return tenant.Facilitator
+	}
+	return f.fallback
+}
+
+// Verify verifies with the tenant's facilitator
+func (f *TenantFacilitator) Verify(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.VerifyResponse, error) {
+	return f.clientFor(ctx).Verify(ctx, payloadBytes, requirementsBytes)
+}
+
+// Settle settles with the tenant's facilitator
+func (f *TenantFacilitator) Settle(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.SettleResponse, error) {
+	return f.clientFor(ctx).Settle(ctx, payloadBytes, requirementsBytes)
+}
+
+// GetSupported returns the default facilitator's capabilities
+func (f *TenantFacilitator) GetSupported(ctx context.Context) (x402.SupportedResponse, error) {
+	return f.fallback.GetSupported(ctx)
+}
+
+// ============================================================================
+// Memory Tenant Store
+// ============================================================================
+
+// MemoryTenantStore is an in-process TenantStore
+type MemoryTenantStore struct {
+	mu      sync.RWMutex
+	tenants map[string]*Tenant
+}
+
+// NewMemoryTenantStore creates a MemoryTenantStore holding tenants
+func NewMemoryTenantStore(tenants ...*Tenant) *MemoryTenantStore {
+	s := &MemoryTenantStore{tenants: make(map[string]*Tenant, len(tenants))}
+	for _, tenant := range tenants {
+		s.tenants[tenant.ID] = tenant
+	}
+	return s
+}
+
+// SaveTenant adds or replaces a tenant
+func (s *MemoryTenantStore) SaveTenant(ctx context.Context, tenant *Tenant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tenants[tenant.ID] = tenant
+	return nil
+}
+
+// DeleteTenant removes a tenant
+func (s *MemoryTenantStore) DeleteTenant(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tenants, id)
+	return nil
+}
+
+// GetTenant returns the tenant with id, or ErrTenantNotFound
+func (s *MemoryTenantStore) GetTenant(ctx context.Context, id string) (*Tenant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tenant, ok := s.tenants[id]
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+	return tenant, nil
+}
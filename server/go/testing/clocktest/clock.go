@@ -0,0 +1,120 @@
+// Package clocktest provides a fake xtended402.Clock that only moves when told to, so expiry,
+// cooldown, and timeout logic can be tested without sleeping.
+//
+//	clock := clocktest.New(time.Time{})
+//	failover := xtended402.NewFailoverFacilitator(clients, xtended402.WithFailoverClock(clock))
+//	// ... trip the failure threshold ...
+//	clock.Advance(31 * time.Second) // cooldown elapsed
+//
+// Code waiting on the clock in another goroutine must register its timer before Advance is
+// called; use BlockUntil to wait for it.
+package clocktest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+var _ xtended402.Clock = (*Clock)(nil)
+
+// Epoch is the default start time of a fake clock
+var Epoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Clock is a fake clock. Safe for concurrent use.
+type Clock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+type waiter struct {
+	until time.Time
+	ch    chan time.Time
+}
+
+// New creates a fake clock starting at start (Epoch if zero)
+func New(start time.Time) *Clock {
+	if start.IsZero() {
+		start = Epoch
+	}
+	c := &Clock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the fake current time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that fires once the clock has been advanced by d
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.waiters = append(c.waiters, &waiter{until: c.now.Add(d), ch: ch})
+	c.cond.Broadcast()
+	return ch
+}
+
+// Advance moves the clock forward by d, firing every timer that comes due in deadline order
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// Set moves the clock to t, firing every timer that comes due. Moving backwards fires nothing.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(t)
+}
+
+func (c *Clock) set(t time.Time) {
+	c.now = t
+
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].until.Before(c.waiters[j].until)
+	})
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.until.After(t) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- t
+	}
+	c.waiters = pending
+	c.cond.Broadcast()
+}
+
+// Waiters returns the number of timers that haven't fired yet
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil waits until at least n timers are pending, e.g. until a background goroutine
+// has started its backoff sleep
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
@@ -0,0 +1,95 @@
+package contracttest_test
+
+import (
+	"net/http"
+	"testing"
+
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/gin-gonic/gin"
+
+	ginmw "github.com/mvpoyatt/xtended402/server/go/http/gin"
+	"github.com/mvpoyatt/xtended402/server/go/testing/contracttest"
+	"github.com/mvpoyatt/xtended402/server/go/testing/e2e"
+	"github.com/mvpoyatt/xtended402/server/go/testing/facilitatortest"
+	"github.com/mvpoyatt/xtended402/server/go/testing/fixtures"
+)
+
+func TestRecordings(t *testing.T) {
+	for _, timing := range []string{"before", "after"} {
+		t.Run(timing, func(t *testing.T) {
+			results := contracttest.Run(t, contracttest.WithMiddlewareOptions(ginmw.WithSettlementTiming(timing)))
+			if len(results) != len(contracttest.Recordings()) {
+				t.Fatalf("checked %d recordings, want all %d", len(results), len(contracttest.Recordings()))
+			}
+		})
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	recordings, err := contracttest.LoadDir("recordings")
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	if len(recordings) != len(contracttest.Recordings()) {
+		t.Fatalf("loaded %d recordings, want %d", len(recordings), len(contracttest.Recordings()))
+	}
+	for _, result := range contracttest.Run(t, contracttest.WithRecordings(recordings...), contracttest.WithSpecs("v2")) {
+		if result.Recording.Spec != "v2" {
+			t.Errorf("%s: spec %s ran under WithSpecs(\"v2\")", result.Recording.Name, result.Recording.Spec)
+		}
+	}
+}
+
+// TestMockFacilitator checks that the mock facilitator, which e2e tests pay against, leads the
+// middleware to answer as the recorded v2 facilitators do
+func TestMockFacilitator(t *testing.T) {
+	mocks := map[string]func(*facilitatortest.Facilitator){
+		"v2/settle-success": func(f *facilitatortest.Facilitator) {},
+		"v2/unknown-fields": func(f *facilitatortest.Facilitator) {},
+		"v2/verify-invalid": func(f *facilitatortest.Facilitator) {
+			f.OnVerify(facilitatortest.VerifyRejects("invalid_exact_evm_payload_signature"))
+		},
+		"v2/settle-failed": func(f *facilitatortest.Facilitator) {
+			f.OnSettle(facilitatortest.SettleFails("insufficient_funds"))
+		},
+	}
+
+	routes := x402http.RoutesConfig{
+		"GET /contract": {
+			Accepts: x402http.PaymentOptions{{
+				Scheme:  "exact",
+				Price:   "$0.01",
+				Network: contracttest.Network,
+				PayTo:   fixtures.DefaultPayTo,
+			}},
+		},
+	}
+
+	for _, recording := range contracttest.Recordings() {
+		if recording.Spec != "v2" {
+			continue
+		}
+		mock, ok := mocks[recording.Name]
+		if !ok {
+			t.Errorf("%s: no mock facilitator behavior for the recording", recording.Name)
+			continue
+		}
+
+		t.Run(recording.Name, func(t *testing.T) {
+			h := e2e.New(t, routes, func(r *gin.Engine, paid gin.HandlerFunc) {
+				r.GET("/contract", paid, func(c *gin.Context) {
+					c.JSON(http.StatusOK, gin.H{"ok": true})
+				})
+			}, e2e.WithNetwork(contracttest.Network))
+			mock(h.Facilitator)
+
+			resp := h.Pay(t, "GET", "/contract", nil)
+			if resp.Code != recording.Expect.Status {
+				t.Errorf("got %d, recorded facilitator gives %d: %s", resp.Code, recording.Expect.Status, resp.Body)
+			}
+			if settled := resp.Settlement != nil && resp.Settlement.Success; settled != recording.Expect.Settled {
+				t.Errorf("settled %v, recorded facilitator settles %v", settled, recording.Expect.Settled)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package contracttest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	evmserver "github.com/coinbase/x402/go/mechanisms/evm/exact/server"
+	"github.com/coinbase/x402/go/types"
+	"github.com/gin-gonic/gin"
+
+	ginmw "github.com/mvpoyatt/xtended402/server/go/http/gin"
+	"github.com/mvpoyatt/xtended402/server/go/testing/fixtures"
+)
+
+// route is the paid route every recording is replayed against
+const route = "/contract"
+
+// replayServer serves a recording over HTTP and checks the requests the client sends
+type replayServer struct {
+	recording Recording
+	payer     string
+
+	mu       sync.Mutex
+	problems []string
+}
+
+func (s *replayServer) problemf(format string, args ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.problems = append(s.problems, fmt.Sprintf(format, args...))
+}
+
+func (s *replayServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var exchange *Exchange
+	switch op := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]; op {
+	case "supported":
+		exchange = s.recording.Supported
+	case "verify", "settle":
+		s.checkPaymentRequest(op, r)
+		if op == "verify" {
+			exchange = s.recording.Verify
+		} else {
+			exchange = s.recording.Settle
+		}
+	default:
+		s.problemf("client called unknown facilitator endpoint %s %s", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+
+	if exchange == nil {
+		s.problemf("client called %s %s, which the recording expects not to be called", r.Method, r.URL.Path)
+		http.Error(w, "not recorded", http.StatusNotImplemented)
+		return
+	}
+
+	body := strings.ReplaceAll(string(exchange.Body), PayerPlaceholder, s.payer)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(exchange.Status)
+	_, _ = w.Write([]byte(body))
+}
+
+// checkPaymentRequest validates a /verify or /settle request body against the v2 wire format
+func (s *replayServer) checkPaymentRequest(op string, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.problemf("%s sent with %s, expected POST", op, r.Method)
+	}
+
+	var request struct {
+		X402Version         int                    `json:"x402Version"`
+		PaymentPayload      map[string]interface{} `json:"paymentPayload"`
+		PaymentRequirements map[string]interface{} `json:"paymentRequirements"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.problemf("%s request body is not valid JSON: %v", op, err)
+		return
+	}
+
+	if request.X402Version != 2 {
+		s.problemf("%s request has x402Version %d, expected 2", op, request.X402Version)
+	}
+	if request.PaymentPayload == nil {
+		s.problemf("%s request is missing paymentPayload", op)
+	} else if version, _ := request.PaymentPayload["x402Version"].(float64); version != 2 {
+		s.problemf("%s paymentPayload has x402Version %v, expected 2", op, request.PaymentPayload["x402Version"])
+	}
+	if request.PaymentRequirements == nil {
+		s.problemf("%s request is missing paymentRequirements", op)
+		return
+	}
+	for _, field := range []string{"scheme", "network", "amount", "asset", "payTo"} {
+		if value, _ := request.PaymentRequirements[field].(string); value == "" {
+			s.problemf("%s paymentRequirements is missing %q", op, field)
+		}
+	}
+}
+
+// check replays recording against a fresh middleware and collects incompatibilities
+func check(recording Recording, cfg *config) Result {
+	replay := &replayServer{recording: recording, payer: cfg.wallet.Address()}
+	facilitatorServer := httptest.NewServer(replay)
+	defer facilitatorServer.Close()
+
+	problems := play(recording, cfg, facilitatorServer.URL)
+
+	replay.mu.Lock()
+	defer replay.mu.Unlock()
+	return Result{Recording: recording, Problems: append(replay.problems, problems...)}
+}
+
+// play drives the middleware through a challenge and a paid request against facilitatorURL
+func play(recording Recording, cfg *config, facilitatorURL string) (problems []string) {
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	routes := x402http.RoutesConfig{
+		"GET " + route: {
+			Accepts: x402http.PaymentOptions{{
+				Scheme:  "exact",
+				Price:   "$0.01",
+				Network: Network,
+				PayTo:   fixtures.DefaultPayTo,
+			}},
+		},
+	}
+
+	middlewareOpts := append([]ginmw.MiddlewareOption{
+		ginmw.WithFacilitatorClient(x402http.NewHTTPFacilitatorClient(&x402http.FacilitatorConfig{URL: facilitatorURL})),
+		ginmw.WithScheme(Network, evmserver.NewExactEvmScheme()),
+		ginmw.WithInitFailurePolicy(ginmw.InitFailFast),
+	}, cfg.middlewareOps...)
+	middleware, err := ginmw.NewMiddlewareFromConfig(routes, middlewareOpts...)
+	if recording.Expect.InitError {
+		if err == nil {
+			problemf("middleware started, but the facilitator can't serve %s and should have been refused", Network)
+		}
+		return problems
+	}
+	if err != nil {
+		problemf("middleware failed to start: %v", err)
+		return problems
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(route, middleware.Handler(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	// Unpaid request: the challenge is built locally, but must still be payable
+	challenge := serve(router, nil)
+	if challenge.Code != http.StatusPaymentRequired {
+		problemf("unpaid request got %d, expected 402: %s", challenge.Code, challenge.Body.String())
+		return problems
+	}
+	var required types.PaymentRequired
+	if err := decodeHeader(challenge.Header().Get("PAYMENT-REQUIRED"), &required); err != nil {
+		problemf("invalid PAYMENT-REQUIRED header: %v", err)
+		return problems
+	}
+
+	paymentHeaders, err := cfg.wallet.Pay(context.Background(), &required)
+	if err != nil {
+		problemf("wallet can't pay the challenge: %v", err)
+		return problems
+	}
+
+	// Paid request: verify and settle go through the recorded facilitator
+	paid := serve(router, paymentHeaders)
+	if want := recording.Expect.Status; want != 0 && paid.Code != want {
+		problemf("paid request got %d, expected %d: %s", paid.Code, want, paid.Body.String())
+	}
+
+	var settlement *x402.SettleResponse
+	if header := paid.Header().Get("PAYMENT-RESPONSE"); header != "" {
+		settlement = &x402.SettleResponse{}
+		if err := decodeHeader(header, settlement); err != nil {
+			problemf("invalid PAYMENT-RESPONSE header: %v", err)
+			return problems
+		}
+	}
+
+	settled := settlement != nil && settlement.Success
+	switch {
+	case recording.Expect.Settled && !settled:
+		problemf("paid request has no successful PAYMENT-RESPONSE")
+	case !recording.Expect.Settled && settled:
+		problemf("paid request settled (transaction %s), expected no settlement", settlement.Transaction)
+	case settled && recording.Expect.Transaction != "" && settlement.Transaction != recording.Expect.Transaction:
+		problemf("PAYMENT-RESPONSE has transaction %s, expected %s", settlement.Transaction, recording.Expect.Transaction)
+	}
+
+	return problems
+}
+
+func serve(router *gin.Engine, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, route, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func decodeHeader(header string, v interface{}) error {
+	if header == "" {
+		return fmt.Errorf("header is missing")
+	}
+	data, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return fmt.Errorf("not base64: %w", err)
+	}
+	return json.Unmarshal(data, v)
+}
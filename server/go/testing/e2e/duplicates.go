@@ -0,0 +1,133 @@
+package e2e
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Duplicates is the outcome of submitting one signed payment several times at once
+type Duplicates struct {
+	// Responses holds one response per submission, in submission order
+	Responses []*Response
+
+	harness       *Harness
+	settledBefore int
+}
+
+// PayDuplicates answers the route's 402 challenge once, then sends n requests carrying that
+// same payment concurrently, released together to maximize overlap. Run with -race so the
+// race detector checks the middleware, facilitator client, and handlers along the way.
+//
+//	dups := h.PayDuplicates(t, 20, "POST", "/api/purchase", order)
+//	dups.AssertSettledOnce(t)
+func (h *Harness) PayDuplicates(t testing.TB, n int, method, path string, body interface{}, headers ...map[string]string) *Duplicates {
+	t.Helper()
+
+	if n < 1 {
+		t.Fatalf("e2e: PayDuplicates needs at least one request, got %d", n)
+	}
+
+	// Encode the body once; every submission replays the same bytes
+	if body != nil {
+		data, err := io.ReadAll(newRequest(t, method, path, body).Body)
+		if err != nil {
+			t.Fatalf("e2e: failed to read request body: %v", err)
+		}
+		body = data
+	}
+
+	challenge := h.Do(t, method, path, body, headers...)
+	if challenge.Code != http.StatusPaymentRequired || challenge.PaymentRequired == nil {
+		t.Fatalf("e2e: expected 402 challenge from %s %s, got %d: %s", method, path, challenge.Code, challenge.Body.String())
+	}
+
+	paymentHeaders := h.Sign(t, challenge.PaymentRequired)
+	for _, hdrs := range headers {
+		for key, value := range hdrs {
+			if _, ok := paymentHeaders[key]; !ok {
+				paymentHeaders[key] = value
+			}
+		}
+	}
+
+	// Build requests up front: t.Fatalf must not be called from the worker goroutines
+	requests := make([]*http.Request, n)
+	for i := range requests {
+		requests[i] = newRequest(t, method, path, body)
+		for key, value := range paymentHeaders {
+			requests[i].Header.Set(key, value)
+		}
+	}
+
+	dups := &Duplicates{harness: h, settledBefore: len(h.Facilitator.Settlements())}
+
+	recorders := make([]*httptest.ResponseRecorder, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range requests {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			h.Router.ServeHTTP(recorders[i], requests[i])
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for _, recorder := range recorders {
+		dups.Responses = append(dups.Responses, decodeResponse(t, recorder))
+	}
+	return dups
+}
+
+// Settled returns the responses that carry a successful settlement
+func (d *Duplicates) Settled() []*Response {
+	var settled []*Response
+	for _, resp := range d.Responses {
+		if resp.Settlement != nil && resp.Settlement.Success {
+			settled = append(settled, resp)
+		}
+	}
+	return settled
+}
+
+// Rejected returns the responses that carry no settlement
+func (d *Duplicates) Rejected() []*Response {
+	var rejected []*Response
+	for _, resp := range d.Responses {
+		if resp.Settlement == nil || !resp.Settlement.Success {
+			rejected = append(rejected, resp)
+		}
+	}
+	return rejected
+}
+
+// AssertSettledOnce fails the test unless exactly one submission settled: one response with a
+// successful settlement, one successful settle call at the facilitator, and every other
+// submission answered with an error status
+func (d *Duplicates) AssertSettledOnce(t testing.TB) {
+	t.Helper()
+
+	settled := d.Settled()
+	if len(settled) != 1 {
+		t.Errorf("e2e: expected exactly 1 settled response out of %d duplicates, got %d", len(d.Responses), len(settled))
+	}
+	if len(settled) > 0 && settled[0].Code >= 400 {
+		t.Errorf("e2e: settled response has status %d: %s", settled[0].Code, settled[0].Body.String())
+	}
+
+	for _, resp := range d.Rejected() {
+		if resp.Code < 400 {
+			t.Errorf("e2e: duplicate without settlement succeeded with %d: %s", resp.Code, resp.Body.String())
+		}
+	}
+
+	if settlements := len(d.harness.Facilitator.Settlements()) - d.settledBefore; settlements != 1 {
+		t.Errorf("e2e: expected exactly 1 facilitator settlement, got %d", settlements)
+	}
+}
@@ -0,0 +1,290 @@
+// Package e2e runs paid Gin routes end-to-end in tests: the real payment middleware, an
+// in-memory mock facilitator, and a deterministic test wallet that answers 402 challenges.
+//
+//	h := e2e.New(t, routes, func(r *gin.Engine, paid gin.HandlerFunc) {
+//		r.POST("/api/purchase", calculateOrderTotal, paid, processOrder)
+//	}, e2e.WithMiddlewareOptions(ginmw.WithSettlementTiming("before")))
+//
+//	resp := h.Pay(t, "POST", "/api/purchase", `{"items":[{"productId":"duck","quantity":2}]}`)
+//	if resp.Code != 200 || resp.Settlement == nil {
+//		t.Fatalf("purchase failed: %d %s", resp.Code, resp.Body)
+//	}
+package e2e
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	evmserver "github.com/coinbase/x402/go/mechanisms/evm/exact/server"
+	"github.com/coinbase/x402/go/types"
+	"github.com/gin-gonic/gin"
+
+	ginmw "github.com/mvpoyatt/xtended402/server/go/http/gin"
+	"github.com/mvpoyatt/xtended402/server/go/testing/facilitatortest"
+	"github.com/mvpoyatt/xtended402/server/go/testing/testwallet"
+)
+
+// DefaultNetwork is the network paid routes are tested on (Base Sepolia)
+const DefaultNetwork = x402.Network("eip155:84532")
+
+// RegisterFunc mounts routes on the harness router using paid as the payment middleware
+type RegisterFunc func(r *gin.Engine, paid gin.HandlerFunc)
+
+// Harness drives a Gin router with the payment middleware in-process
+type Harness struct {
+	Router      *gin.Engine
+	Server      *x402.X402ResourceServer
+	Middleware  *ginmw.Middleware
+	Facilitator *facilitatortest.Facilitator
+
+	// Wallet is the test wallet that signs payments
+	Wallet *testwallet.Wallet
+
+	// Payer is the test wallet address
+	Payer string
+}
+
+type harnessConfig struct {
+	network       x402.Network
+	wallet        *testwallet.Wallet
+	facilitator   *facilitatortest.Facilitator
+	middlewareOps []ginmw.MiddlewareOption
+	serverSetup   func(*x402.X402ResourceServer)
+}
+
+// Option configures a Harness
+type Option func(*harnessConfig)
+
+// WithNetwork sets the network the server accepts and the wallet pays on
+func WithNetwork(network x402.Network) Option {
+	return func(c *harnessConfig) {
+		c.network = network
+	}
+}
+
+// WithWallet sets the paying wallet (defaults to testwallet.ForNetwork for the harness network)
+func WithWallet(wallet *testwallet.Wallet) Option {
+	return func(c *harnessConfig) {
+		c.wallet = wallet
+	}
+}
+
+// WithPrivateKey pays from a wallet with the given key
+func WithPrivateKey(privateKeyHex string) Option {
+	return func(c *harnessConfig) {
+		c.wallet = testwallet.MustNew(privateKeyHex)
+	}
+}
+
+// WithFacilitator uses a preconfigured mock facilitator (the default has replay protection on)
+func WithFacilitator(facilitator *facilitatortest.Facilitator) Option {
+	return func(c *harnessConfig) {
+		c.facilitator = facilitator
+	}
+}
+
+// WithMiddlewareOptions passes options to the payment middleware
+func WithMiddlewareOptions(opts ...ginmw.MiddlewareOption) Option {
+	return func(c *harnessConfig) {
+		c.middlewareOps = append(c.middlewareOps, opts...)
+	}
+}
+
+// WithServerSetup customizes the resource server (money parsers, hooks) before the middleware is built
+func WithServerSetup(setup func(*x402.X402ResourceServer)) Option {
+	return func(c *harnessConfig) {
+		c.serverSetup = setup
+	}
+}
+
+// New creates a Harness for routes, mounting handlers with register
+func New(t testing.TB, routes x402http.RoutesConfig, register RegisterFunc, opts ...Option) *Harness {
+	t.Helper()
+
+	cfg := &harnessConfig{
+		network: DefaultNetwork,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.wallet == nil {
+		cfg.wallet = testwallet.ForNetwork(string(cfg.network))
+	}
+	if cfg.facilitator == nil {
+		cfg.facilitator = facilitatortest.New(
+			facilitatortest.WithNetworks(string(cfg.network)),
+			facilitatortest.WithReplayProtection(),
+		)
+	}
+
+	server := x402.Newx402ResourceServer(x402.WithFacilitatorClient(cfg.facilitator))
+	server.Register(cfg.network, evmserver.NewExactEvmScheme())
+	if cfg.serverSetup != nil {
+		cfg.serverSetup(server)
+	}
+
+	middlewareOpts := append([]ginmw.MiddlewareOption{ginmw.WithInitFailurePolicy(ginmw.InitFailFast)}, cfg.middlewareOps...)
+	middleware, err := ginmw.NewMiddleware(routes, server, middlewareOpts...)
+	if err != nil {
+		t.Fatalf("e2e: failed to create payment middleware: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	register(router, middleware.Handler())
+
+	return &Harness{
+		Router:      router,
+		Server:      server,
+		Middleware:  middleware,
+		Facilitator: cfg.facilitator,
+		Wallet:      cfg.wallet,
+		Payer:       cfg.wallet.Address(),
+	}
+}
+
+// Response is a recorded response with decoded x402 headers
+type Response struct {
+	*httptest.ResponseRecorder
+
+	// PaymentRequired is the decoded PAYMENT-REQUIRED header of a 402 response
+	PaymentRequired *types.PaymentRequired
+
+	// Settlement is the decoded PAYMENT-RESPONSE header of a settled response
+	Settlement *x402.SettleResponse
+}
+
+// JSON decodes the response body into v, failing the test on error
+func (r *Response) JSON(t testing.TB, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		t.Fatalf("e2e: response body is not valid JSON: %v\n%s", err, r.Body.String())
+	}
+}
+
+// Do sends a request without paying. body may be a string, []byte, io.Reader, or any value
+// marshaled as JSON; headers are optional.
+func (h *Harness) Do(t testing.TB, method, path string, body interface{}, headers ...map[string]string) *Response {
+	t.Helper()
+
+	req := newRequest(t, method, path, body)
+	for _, hdrs := range headers {
+		for key, value := range hdrs {
+			req.Header.Set(key, value)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	h.Router.ServeHTTP(recorder, req)
+	return decodeResponse(t, recorder)
+}
+
+// Pay sends a request, answers the 402 challenge with a signed payment from the test wallet,
+// and returns the paid response. Fails the test if the first response isn't a 402.
+func (h *Harness) Pay(t testing.TB, method, path string, body interface{}, headers ...map[string]string) *Response {
+	t.Helper()
+
+	// The body is sent twice, so buffer readers up front
+	if reader, ok := body.(io.Reader); ok {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("e2e: failed to read request body: %v", err)
+		}
+		body = data
+	}
+
+	challenge := h.Do(t, method, path, body, headers...)
+	if challenge.Code != http.StatusPaymentRequired || challenge.PaymentRequired == nil {
+		t.Fatalf("e2e: expected 402 challenge from %s %s, got %d: %s", method, path, challenge.Code, challenge.Body.String())
+	}
+
+	paymentHeaders := h.Sign(t, challenge.PaymentRequired)
+	for _, hdrs := range headers {
+		for key, value := range hdrs {
+			if _, ok := paymentHeaders[key]; !ok {
+				paymentHeaders[key] = value
+			}
+		}
+	}
+
+	return h.Do(t, method, path, body, paymentHeaders)
+}
+
+// Sign creates payment headers answering required with the test wallet
+func (h *Harness) Sign(t testing.TB, required *types.PaymentRequired) map[string]string {
+	t.Helper()
+
+	headers, err := h.Wallet.Pay(context.Background(), required)
+	if err != nil {
+		t.Fatalf("e2e: %v", err)
+	}
+	return headers
+}
+
+func newRequest(t testing.TB, method, path string, body interface{}) *http.Request {
+	t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	case []byte:
+		reader = strings.NewReader(string(b))
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("e2e: failed to marshal request body: %v", err)
+		}
+		reader = strings.NewReader(string(data))
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+func decodeResponse(t testing.TB, recorder *httptest.ResponseRecorder) *Response {
+	t.Helper()
+
+	resp := &Response{ResponseRecorder: recorder}
+
+	if header := recorder.Header().Get("PAYMENT-REQUIRED"); header != "" {
+		var required types.PaymentRequired
+		if err := decodeHeader(header, &required); err != nil {
+			t.Fatalf("e2e: invalid PAYMENT-REQUIRED header: %v", err)
+		}
+		resp.PaymentRequired = &required
+	}
+
+	if header := recorder.Header().Get("PAYMENT-RESPONSE"); header != "" {
+		var settlement x402.SettleResponse
+		if err := decodeHeader(header, &settlement); err != nil {
+			t.Fatalf("e2e: invalid PAYMENT-RESPONSE header: %v", err)
+		}
+		resp.Settlement = &settlement
+	}
+
+	return resp
+}
+
+func decodeHeader(header string, v interface{}) error {
+	data, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return fmt.Errorf("not base64: %w", err)
+	}
+	return json.Unmarshal(data, v)
+}
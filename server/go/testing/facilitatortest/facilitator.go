@@ -0,0 +1,435 @@
+// Package facilitatortest provides an in-memory x402.FacilitatorClient for testing paid routes
+// without network access. Verify and settle outcomes are programmable, latency can be injected,
+// and every call is recorded for assertions.
+//
+//	mock := facilitatortest.New(facilitatortest.WithNetworks("eip155:84532"))
+//	mock.OnSettle(facilitatortest.SettleFails("insufficient_funds"))
+//	server := x402.Newx402ResourceServer(x402.WithFacilitatorClient(mock))
+package facilitatortest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	"github.com/coinbase/x402/go/types"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// Operation identifies a facilitator call
+type Operation string
+
+const (
+	OpVerify    Operation = "verify"
+	OpSettle    Operation = "settle"
+	OpSupported Operation = "supported"
+)
+
+// VerifyFunc decides the outcome of a verify call
+type VerifyFunc func(ctx context.Context, payload types.PaymentPayload, requirements types.PaymentRequirements) (*x402.VerifyResponse, error)
+
+// SettleFunc decides the outcome of a settle call
+type SettleFunc func(ctx context.Context, payload types.PaymentPayload, requirements types.PaymentRequirements) (*x402.SettleResponse, error)
+
+// Call is a recorded facilitator call
+type Call struct {
+	Op           Operation
+	Payload      types.PaymentPayload
+	Requirements types.PaymentRequirements
+	Err          error
+	At           time.Time
+}
+
+// Facilitator is a configurable in-memory FacilitatorClient. Safe for concurrent use.
+type Facilitator struct {
+	mu            sync.Mutex
+	supported     x402.SupportedResponse
+	supportedErr  error
+	verify        VerifyFunc
+	settle        SettleFunc
+	verifyLatency time.Duration
+	settleLatency time.Duration
+	clock         xtended402.Clock
+	calls         []Call
+
+	replayProtection bool
+	usedNonces       map[string]bool
+}
+
+// Option configures a Facilitator
+type Option func(*Facilitator)
+
+// WithNetworks sets the networks advertised for the exact scheme (default eip155:84532)
+func WithNetworks(networks ...string) Option {
+	return func(f *Facilitator) {
+		f.supported.Kinds = nil
+		for _, network := range networks {
+			f.supported.Kinds = append(f.supported.Kinds, x402.SupportedKind{
+				X402Version: 2,
+				Scheme:      "exact",
+				Network:     network,
+			})
+		}
+	}
+}
+
+// WithSupported replaces the GetSupported response entirely
+func WithSupported(supported x402.SupportedResponse) Option {
+	return func(f *Facilitator) {
+		f.supported = supported
+	}
+}
+
+// WithVerify sets the verify outcome
+func WithVerify(fn VerifyFunc) Option {
+	return func(f *Facilitator) {
+		f.verify = fn
+	}
+}
+
+// WithSettle sets the settle outcome
+func WithSettle(fn SettleFunc) Option {
+	return func(f *Facilitator) {
+		f.settle = fn
+	}
+}
+
+// WithLatency delays verify and settle calls, honoring context cancellation
+func WithLatency(verify, settle time.Duration) Option {
+	return func(f *Facilitator) {
+		f.verifyLatency = verify
+		f.settleLatency = settle
+	}
+}
+
+// WithClock sets the clock for injected latency and call timestamps, so latency can be
+// driven by a fake clock instead of real sleeps
+func WithClock(clock xtended402.Clock) Option {
+	return func(f *Facilitator) {
+		f.clock = xtended402.ClockOrSystem(clock)
+	}
+}
+
+// WithReplayProtection settles each ERC-3009 authorization at most once, like the token
+// contract does: once a payer's nonce has settled, verify rejects it and settle fails with
+// "nonce_already_used". Settlements claim the nonce atomically, so of several concurrent
+// duplicates exactly one can succeed.
+func WithReplayProtection() Option {
+	return func(f *Facilitator) {
+		f.replayProtection = true
+	}
+}
+
+// New creates a Facilitator that accepts and settles every payment by default
+func New(opts ...Option) *Facilitator {
+	f := &Facilitator{
+		verify:     VerifySucceeds(),
+		settle:     SettleSucceeds(),
+		clock:      xtended402.SystemClock,
+		usedNonces: make(map[string]bool),
+	}
+	WithNetworks("eip155:84532")(f)
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// OnVerify replaces the verify outcome
+func (f *Facilitator) OnVerify(fn VerifyFunc) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.verify = fn
+}
+
+// OnSettle replaces the settle outcome
+func (f *Facilitator) OnSettle(fn SettleFunc) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.settle = fn
+}
+
+// SetLatency changes injected latency for subsequent calls
+func (f *Facilitator) SetLatency(verify, settle time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.verifyLatency = verify
+	f.settleLatency = settle
+}
+
+// FailSupported makes GetSupported return err (nil restores the normal response)
+func (f *Facilitator) FailSupported(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.supportedErr = err
+}
+
+// Calls returns all recorded calls in order
+func (f *Facilitator) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallsFor returns recorded calls for one operation
+func (f *Facilitator) CallsFor(op Operation) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []Call
+	for _, call := range f.calls {
+		if call.Op == op {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset clears recorded calls and used nonces
+func (f *Facilitator) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+	f.usedNonces = make(map[string]bool)
+}
+
+// Settlements returns the settle calls that succeeded
+func (f *Facilitator) Settlements() []Call {
+	var settled []Call
+	for _, call := range f.CallsFor(OpSettle) {
+		if call.Err == nil {
+			settled = append(settled, call)
+		}
+	}
+	return settled
+}
+
+// ============================================================================
+// FacilitatorClient
+// ============================================================================
+
+// Verify records the call and returns the programmed outcome
+func (f *Facilitator) Verify(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.VerifyResponse, error) {
+	f.mu.Lock()
+	fn, latency := f.verify, f.verifyLatency
+	f.mu.Unlock()
+
+	payload, requirements, err := decode(payloadBytes, requirementsBytes)
+	if err == nil {
+		err = sleep(ctx, f.clock, latency)
+	}
+
+	if err == nil && f.nonceUsed(payload) {
+		err = x402.NewVerifyError("nonce_already_used", Payer(payload), x402.Network(requirements.Network), nil)
+	}
+
+	var resp *x402.VerifyResponse
+	if err == nil {
+		resp, err = fn(ctx, payload, requirements)
+	}
+
+	f.record(OpVerify, payload, requirements, err)
+	return resp, err
+}
+
+// Settle records the call and returns the programmed outcome
+func (f *Facilitator) Settle(ctx context.Context, payloadBytes []byte, requirementsBytes []byte) (*x402.SettleResponse, error) {
+	f.mu.Lock()
+	fn, latency := f.settle, f.settleLatency
+	f.mu.Unlock()
+
+	payload, requirements, err := decode(payloadBytes, requirementsBytes)
+	if err == nil {
+		err = sleep(ctx, f.clock, latency)
+	}
+
+	if err == nil && !f.claimNonce(payload) {
+		err = x402.NewSettleError("nonce_already_used", Payer(payload), x402.Network(requirements.Network), "", nil)
+	}
+
+	var resp *x402.SettleResponse
+	if err == nil {
+		resp, err = fn(ctx, payload, requirements)
+		if err != nil {
+			f.releaseNonce(payload)
+		}
+	}
+
+	f.record(OpSettle, payload, requirements, err)
+	return resp, err
+}
+
+// GetSupported records the call and returns the configured capabilities
+func (f *Facilitator) GetSupported(ctx context.Context) (x402.SupportedResponse, error) {
+	f.mu.Lock()
+	supported, err := f.supported, f.supportedErr
+	f.mu.Unlock()
+
+	f.record(OpSupported, types.PaymentPayload{}, types.PaymentRequirements{}, err)
+	if err != nil {
+		return x402.SupportedResponse{}, err
+	}
+	return supported, nil
+}
+
+// nonceUsed reports whether payload's authorization already settled under replay protection
+func (f *Facilitator) nonceUsed(payload types.PaymentPayload) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.replayProtection && f.usedNonces[nonceKey(payload)]
+}
+
+// claimNonce marks payload's authorization as settled, returning false if it already was
+func (f *Facilitator) claimNonce(payload types.PaymentPayload) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.replayProtection {
+		return true
+	}
+	key := nonceKey(payload)
+	if f.usedNonces[key] {
+		return false
+	}
+	f.usedNonces[key] = true
+	return true
+}
+
+// releaseNonce frees a claimed nonce after a failed settlement, as a reverted transfer would
+func (f *Facilitator) releaseNonce(payload types.PaymentPayload) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.replayProtection {
+		delete(f.usedNonces, nonceKey(payload))
+	}
+}
+
+func (f *Facilitator) record(op Operation, payload types.PaymentPayload, requirements types.PaymentRequirements, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, Call{
+		Op:           op,
+		Payload:      payload,
+		Requirements: requirements,
+		Err:          err,
+		At:           f.clock.Now(),
+	})
+}
+
+// ============================================================================
+// Outcomes
+// ============================================================================
+
+// VerifySucceeds accepts every payment
+func VerifySucceeds() VerifyFunc {
+	return func(ctx context.Context, payload types.PaymentPayload, requirements types.PaymentRequirements) (*x402.VerifyResponse, error) {
+		return &x402.VerifyResponse{IsValid: true, Payer: Payer(payload)}, nil
+	}
+}
+
+// VerifyRejects rejects every payment with reason (e.g. "invalid_signature")
+func VerifyRejects(reason string) VerifyFunc {
+	return func(ctx context.Context, payload types.PaymentPayload, requirements types.PaymentRequirements) (*x402.VerifyResponse, error) {
+		return nil, x402.NewVerifyError(reason, Payer(payload), x402.Network(requirements.Network), nil)
+	}
+}
+
+// VerifyErrors simulates a facilitator outage during verify
+func VerifyErrors(err error) VerifyFunc {
+	return func(ctx context.Context, payload types.PaymentPayload, requirements types.PaymentRequirements) (*x402.VerifyResponse, error) {
+		return nil, err
+	}
+}
+
+// SettleSucceeds settles every payment with a transaction hash derived from the payload
+func SettleSucceeds() SettleFunc {
+	return func(ctx context.Context, payload types.PaymentPayload, requirements types.PaymentRequirements) (*x402.SettleResponse, error) {
+		return &x402.SettleResponse{
+			Success:     true,
+			Payer:       Payer(payload),
+			Transaction: TransactionHash(payload),
+			Network:     x402.Network(requirements.Network),
+		}, nil
+	}
+}
+
+// SettleFails fails every settlement with reason (e.g. "insufficient_funds")
+func SettleFails(reason string) SettleFunc {
+	return func(ctx context.Context, payload types.PaymentPayload, requirements types.PaymentRequirements) (*x402.SettleResponse, error) {
+		return nil, x402.NewSettleError(reason, Payer(payload), x402.Network(requirements.Network), "", nil)
+	}
+}
+
+// SettleErrors simulates a facilitator outage during settle
+func SettleErrors(err error) SettleFunc {
+	return func(ctx context.Context, payload types.PaymentPayload, requirements types.PaymentRequirements) (*x402.SettleResponse, error) {
+		return nil, err
+	}
+}
+
+// Payer returns the authorization "from" address in an exact EVM payload, if present
+func Payer(payload types.PaymentPayload) string {
+	if authorization, ok := payload.Payload["authorization"].(map[string]interface{}); ok {
+		if from, ok := authorization["from"].(string); ok {
+			return from
+		}
+	}
+	return ""
+}
+
+// Nonce returns the authorization nonce in an exact EVM payload, if present
+func Nonce(payload types.PaymentPayload) string {
+	if authorization, ok := payload.Payload["authorization"].(map[string]interface{}); ok {
+		if nonce, ok := authorization["nonce"].(string); ok {
+			return nonce
+		}
+	}
+	return ""
+}
+
+// nonceKey identifies an authorization the way the token contract does: by payer and nonce
+func nonceKey(payload types.PaymentPayload) string {
+	return strings.ToLower(Payer(payload)) + "/" + strings.ToLower(Nonce(payload))
+}
+
+// TransactionHash returns a deterministic fake transaction hash for payload
+func TransactionHash(payload types.PaymentPayload) string {
+	data, _ := json.Marshal(payload.Payload)
+	sum := sha256.Sum256(data)
+	return "0x" + hex.EncodeToString(sum[:])
+}
+
+func decode(payloadBytes, requirementsBytes []byte) (types.PaymentPayload, types.PaymentRequirements, error) {
+	var payload types.PaymentPayload
+	var requirements types.PaymentRequirements
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return payload, requirements, fmt.Errorf("facilitatortest: invalid payload: %w", err)
+	}
+	if err := json.Unmarshal(requirementsBytes, &requirements); err != nil {
+		return payload, requirements, fmt.Errorf("facilitatortest: invalid requirements: %w", err)
+	}
+	return payload, requirements, nil
+}
+
+func sleep(ctx context.Context, clock xtended402.Clock, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clock.After(d):
+		return nil
+	}
+}
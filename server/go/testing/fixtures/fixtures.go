@@ -0,0 +1,247 @@
+// Package fixtures builds valid, signed x402 payment payloads and matching requirements for unit
+// tests of hooks, stores, and custom pricing logic — no server or facilitator required.
+//
+//	payload, requirements := fixtures.Payment(t, fixtures.WithAmount("2500000"))
+//	data := fixtures.PaymentData(t, fixtures.WithNetwork("eip155:8453"))
+package fixtures
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	x402 "github.com/coinbase/x402/go"
+	"github.com/coinbase/x402/go/mechanisms/evm"
+	"github.com/coinbase/x402/go/types"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	"github.com/mvpoyatt/xtended402/server/go/testing/testwallet"
+)
+
+const (
+	// DefaultNetwork is Base Sepolia
+	DefaultNetwork = "eip155:84532"
+
+	// DefaultPayTo is the second Anvil development account
+	DefaultPayTo = "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+
+	// DefaultAmount is 1 USDC in smallest units
+	DefaultAmount = "1000000"
+)
+
+type config struct {
+	network    string
+	scheme     string
+	asset      string
+	amount     string
+	payTo      string
+	maxTimeout int
+	extra      map[string]interface{}
+	wallet     *testwallet.Wallet
+	resource   *types.ResourceInfo
+}
+
+// Option customizes generated fixtures
+type Option func(*config)
+
+// WithNetwork sets the CAIP-2 network (must be a network known to the x402 EVM mechanism)
+func WithNetwork(network string) Option {
+	return func(c *config) {
+		c.network = network
+	}
+}
+
+// WithAsset sets the token address (defaults to the network's USDC)
+func WithAsset(asset string) Option {
+	return func(c *config) {
+		c.asset = asset
+	}
+}
+
+// WithAmount sets the amount in the token's smallest units
+func WithAmount(amount string) Option {
+	return func(c *config) {
+		c.amount = amount
+	}
+}
+
+// WithPayTo sets the payment recipient
+func WithPayTo(payTo string) Option {
+	return func(c *config) {
+		c.payTo = payTo
+	}
+}
+
+// WithMaxTimeout sets MaxTimeoutSeconds on the requirements
+func WithMaxTimeout(seconds int) Option {
+	return func(c *config) {
+		c.maxTimeout = seconds
+	}
+}
+
+// WithExtra merges values into the requirements' Extra map
+func WithExtra(extra map[string]interface{}) Option {
+	return func(c *config) {
+		for key, value := range extra {
+			c.extra[key] = value
+		}
+	}
+}
+
+// WithWallet sets the payer (defaults to testwallet.ForNetwork for the network)
+func WithWallet(wallet *testwallet.Wallet) Option {
+	return func(c *config) {
+		c.wallet = wallet
+	}
+}
+
+// WithPrivateKey pays from a wallet with the given key
+func WithPrivateKey(privateKeyHex string) Option {
+	return func(c *config) {
+		c.wallet = testwallet.MustNew(privateKeyHex)
+	}
+}
+
+// WithResource sets the resource the payment is for
+func WithResource(url, description string) Option {
+	return func(c *config) {
+		c.resource = &types.ResourceInfo{URL: url, Description: description}
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		network:    DefaultNetwork,
+		scheme:     "exact",
+		amount:     DefaultAmount,
+		payTo:      DefaultPayTo,
+		maxTimeout: 300,
+		extra:      map[string]interface{}{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.wallet == nil {
+		c.wallet = testwallet.ForNetwork(c.network)
+	}
+	return c
+}
+
+// Requirements builds payment requirements. The asset defaults to the network's USDC, with the
+// EIP-712 domain name and version in Extra so signatures verify.
+func Requirements(t testing.TB, opts ...Option) types.PaymentRequirements {
+	t.Helper()
+	return requirements(t, newConfig(opts))
+}
+
+func requirements(t testing.TB, c *config) types.PaymentRequirements {
+	t.Helper()
+
+	networkConfig, err := evm.GetNetworkConfig(c.network)
+	if err != nil {
+		t.Fatalf("fixtures: %v", err)
+	}
+
+	asset := c.asset
+	if asset == "" {
+		asset = networkConfig.DefaultAsset.Address
+	}
+
+	extra := map[string]interface{}{
+		"name":    networkConfig.DefaultAsset.Name,
+		"version": networkConfig.DefaultAsset.Version,
+	}
+	for key, value := range c.extra {
+		extra[key] = value
+	}
+
+	return types.PaymentRequirements{
+		Scheme:            c.scheme,
+		Network:           c.network,
+		Asset:             asset,
+		Amount:            c.amount,
+		PayTo:             c.payTo,
+		MaxTimeoutSeconds: c.maxTimeout,
+		Extra:             extra,
+	}
+}
+
+// Payment builds requirements and a payload signed against them with the test wallet
+func Payment(t testing.TB, opts ...Option) (types.PaymentPayload, types.PaymentRequirements) {
+	t.Helper()
+
+	c := newConfig(opts)
+	reqs := requirements(t, c)
+	return Sign(t, reqs, opts...), reqs
+}
+
+// Sign signs an ERC-3009 authorization for existing requirements with the test wallet.
+// The wallet defaults to the one for requirements.Network unless WithNetwork says otherwise.
+func Sign(t testing.TB, requirements types.PaymentRequirements, opts ...Option) types.PaymentPayload {
+	t.Helper()
+
+	c := newConfig(append([]Option{WithNetwork(requirements.Network)}, opts...))
+	payload, err := c.wallet.Sign(context.Background(), requirements)
+	if err != nil {
+		t.Fatalf("fixtures: %v", err)
+	}
+	payload.Resource = c.resource
+
+	return payload
+}
+
+// Header encodes a payload as a PAYMENT-SIGNATURE header value
+func Header(t testing.TB, payload types.PaymentPayload) string {
+	t.Helper()
+	return base64.StdEncoding.EncodeToString(Bytes(t, payload))
+}
+
+// Bytes marshals v (a payload or requirements) as facilitator clients receive it
+func Bytes(t testing.TB, v interface{}) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("fixtures: failed to marshal: %v", err)
+	}
+	return data
+}
+
+// PaymentData builds a settled PaymentData as the middleware stores it for handlers
+func PaymentData(t testing.TB, opts ...Option) *xtended402.PaymentData {
+	t.Helper()
+
+	payload, reqs := Payment(t, opts...)
+	payer := Payer(payload)
+
+	return &xtended402.PaymentData{
+		PaymentPayload:      &payload,
+		PaymentRequirements: &reqs,
+		VerifyResponse:      &x402.VerifyResponse{IsValid: true, Payer: payer},
+		SettleResponse: &x402.SettleResponse{
+			Success:     true,
+			Payer:       payer,
+			Transaction: TransactionHash(payload),
+			Network:     x402.Network(reqs.Network),
+		},
+	}
+}
+
+// Payer returns the signer address of an exact EVM payload
+func Payer(payload types.PaymentPayload) string {
+	evmPayload, err := evm.PayloadFromMap(payload.Payload)
+	if err != nil {
+		return ""
+	}
+	return evmPayload.Authorization.From
+}
+
+// TransactionHash returns a deterministic fake transaction hash for payload
+func TransactionHash(payload types.PaymentPayload) string {
+	data, _ := json.Marshal(payload.Payload)
+	sum := sha256.Sum256(data)
+	return "0x" + hex.EncodeToString(sum[:])
+}
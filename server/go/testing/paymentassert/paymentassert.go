@@ -0,0 +1,255 @@
+// Package paymentassert provides test assertions for x402 responses: 402 challenges decoded
+// from the PAYMENT-REQUIRED header and settlements decoded from PAYMENT-RESPONSE.
+//
+//	paymentassert.Challenge(t, resp).
+//		Count(1).
+//		Accepts(paymentassert.Requirement{Network: "eip155:84532", Amount: "10000"})
+//
+//	paymentassert.Settled(t, resp).Payer(wallet.Address())
+//
+// Every function takes an *http.Response; use ResponseRecorder.Result() for httptest.
+package paymentassert
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	x402 "github.com/coinbase/x402/go"
+	"github.com/coinbase/x402/go/types"
+)
+
+const (
+	paymentRequiredHeader = "PAYMENT-REQUIRED"
+	paymentResponseHeader = "PAYMENT-RESPONSE"
+)
+
+// ============================================================================
+// 402 Challenges
+// ============================================================================
+
+// Requirement matches payment requirements. Empty fields match anything; addresses compare
+// case-insensitively.
+type Requirement struct {
+	Scheme  string
+	Network string
+	Asset   string
+	Amount  string
+	PayTo   string
+}
+
+func (r Requirement) matches(req types.PaymentRequirements) bool {
+	return matchExact(r.Scheme, req.Scheme) &&
+		matchExact(r.Network, req.Network) &&
+		matchAddress(r.Asset, req.Asset) &&
+		matchExact(r.Amount, req.Amount) &&
+		matchAddress(r.PayTo, req.PayTo)
+}
+
+func (r Requirement) String() string {
+	var parts []string
+	for _, field := range []struct{ name, value string }{
+		{"scheme", r.Scheme}, {"network", r.Network}, {"asset", r.Asset}, {"amount", r.Amount}, {"payTo", r.PayTo},
+	} {
+		if field.value != "" {
+			parts = append(parts, field.name+"="+field.value)
+		}
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// PaymentRequired is a decoded 402 challenge with chainable assertions
+type PaymentRequired struct {
+	*types.PaymentRequired
+	t testing.TB
+}
+
+// Challenge asserts resp is a 402 with a valid PAYMENT-REQUIRED header and decodes it
+func Challenge(t testing.TB, resp *http.Response) *PaymentRequired {
+	t.Helper()
+
+	if resp.StatusCode != http.StatusPaymentRequired {
+		t.Fatalf("paymentassert: expected status 402, got %d", resp.StatusCode)
+	}
+
+	header := resp.Header.Get(paymentRequiredHeader)
+	if header == "" {
+		t.Fatalf("paymentassert: 402 response has no %s header", paymentRequiredHeader)
+	}
+
+	var required types.PaymentRequired
+	if err := decodeHeader(header, &required); err != nil {
+		t.Fatalf("paymentassert: invalid %s header: %v", paymentRequiredHeader, err)
+	}
+
+	return &PaymentRequired{PaymentRequired: &required, t: t}
+}
+
+// Count asserts the challenge offers exactly n payment options
+func (p *PaymentRequired) Count(n int) *PaymentRequired {
+	p.t.Helper()
+	if len(p.PaymentRequired.Accepts) != n {
+		p.t.Errorf("paymentassert: expected %d payment options, got %d: %s", n, len(p.PaymentRequired.Accepts), describe(p.PaymentRequired.Accepts))
+	}
+	return p
+}
+
+// Accepts asserts at least one payment option matches want
+func (p *PaymentRequired) Accepts(want Requirement) *PaymentRequired {
+	p.t.Helper()
+	if p.Find(want) == nil {
+		p.t.Errorf("paymentassert: no payment option matches %s; offered %s", want, describe(p.PaymentRequired.Accepts))
+	}
+	return p
+}
+
+// AcceptsOnly asserts every payment option matches want
+func (p *PaymentRequired) AcceptsOnly(want Requirement) *PaymentRequired {
+	p.t.Helper()
+	for i, req := range p.PaymentRequired.Accepts {
+		if !want.matches(req) {
+			p.t.Errorf("paymentassert: payment option %d doesn't match %s: %s", i, want, describe(p.PaymentRequired.Accepts[i:i+1]))
+		}
+	}
+	return p
+}
+
+// Price asserts some payment option on network charges amount (smallest units)
+func (p *PaymentRequired) Price(network, amount string) *PaymentRequired {
+	p.t.Helper()
+	return p.Accepts(Requirement{Network: network, Amount: amount})
+}
+
+// Resource asserts the challenge describes the resource at url
+func (p *PaymentRequired) Resource(url string) *PaymentRequired {
+	p.t.Helper()
+	if p.PaymentRequired.Resource == nil || p.PaymentRequired.Resource.URL != url {
+		got := "<none>"
+		if p.PaymentRequired.Resource != nil {
+			got = p.PaymentRequired.Resource.URL
+		}
+		p.t.Errorf("paymentassert: expected resource %s, got %s", url, got)
+	}
+	return p
+}
+
+// Find returns the first payment option matching want, or nil
+func (p *PaymentRequired) Find(want Requirement) *types.PaymentRequirements {
+	for i := range p.PaymentRequired.Accepts {
+		if want.matches(p.PaymentRequired.Accepts[i]) {
+			return &p.PaymentRequired.Accepts[i]
+		}
+	}
+	return nil
+}
+
+// ============================================================================
+// Settlements
+// ============================================================================
+
+// Settlement is a decoded PAYMENT-RESPONSE header with chainable assertions
+type Settlement struct {
+	*x402.SettleResponse
+	t testing.TB
+}
+
+// Settled asserts resp carries a successful PAYMENT-RESPONSE header and decodes it
+func Settled(t testing.TB, resp *http.Response) *Settlement {
+	t.Helper()
+
+	header := resp.Header.Get(paymentResponseHeader)
+	if header == "" {
+		t.Fatalf("paymentassert: expected %s header on %d response", paymentResponseHeader, resp.StatusCode)
+	}
+
+	var settlement x402.SettleResponse
+	if err := decodeHeader(header, &settlement); err != nil {
+		t.Fatalf("paymentassert: invalid %s header: %v", paymentResponseHeader, err)
+	}
+	if !settlement.Success {
+		t.Fatalf("paymentassert: settlement failed: %s", settlement.ErrorReason)
+	}
+
+	return &Settlement{SettleResponse: &settlement, t: t}
+}
+
+// NotSettled asserts resp has no PAYMENT-RESPONSE header, i.e. no payment was taken
+func NotSettled(t testing.TB, resp *http.Response) {
+	t.Helper()
+	if header := resp.Header.Get(paymentResponseHeader); header != "" {
+		t.Errorf("paymentassert: expected no settlement, got %s header on %d response", paymentResponseHeader, resp.StatusCode)
+	}
+}
+
+// Payer asserts the settlement was paid by address
+func (s *Settlement) Payer(address string) *Settlement {
+	s.t.Helper()
+	if !strings.EqualFold(s.SettleResponse.Payer, address) {
+		s.t.Errorf("paymentassert: expected payer %s, got %s", address, s.SettleResponse.Payer)
+	}
+	return s
+}
+
+// Network asserts the settlement happened on network
+func (s *Settlement) Network(network string) *Settlement {
+	s.t.Helper()
+	if string(s.SettleResponse.Network) != network {
+		s.t.Errorf("paymentassert: expected settlement on %s, got %s", network, s.SettleResponse.Network)
+	}
+	return s
+}
+
+// Transaction asserts the settlement transaction hash is tx
+func (s *Settlement) Transaction(tx string) *Settlement {
+	s.t.Helper()
+	if !strings.EqualFold(s.SettleResponse.Transaction, tx) {
+		s.t.Errorf("paymentassert: expected transaction %s, got %s", tx, s.SettleResponse.Transaction)
+	}
+	return s
+}
+
+// HasTransaction asserts the settlement reports a transaction hash
+func (s *Settlement) HasTransaction() *Settlement {
+	s.t.Helper()
+	if s.SettleResponse.Transaction == "" {
+		s.t.Errorf("paymentassert: settlement has no transaction hash")
+	}
+	return s
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+func decodeHeader(header string, v interface{}) error {
+	data, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return fmt.Errorf("not base64: %w", err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func matchExact(want, got string) bool {
+	return want == "" || want == got
+}
+
+func matchAddress(want, got string) bool {
+	return want == "" || strings.EqualFold(want, got)
+}
+
+func describe(accepts []types.PaymentRequirements) string {
+	offered := make([]string, len(accepts))
+	for i, req := range accepts {
+		offered[i] = Requirement{
+			Scheme:  req.Scheme,
+			Network: req.Network,
+			Asset:   req.Asset,
+			Amount:  req.Amount,
+			PayTo:   req.PayTo,
+		}.String()
+	}
+	return "[" + strings.Join(offered, ", ") + "]"
+}
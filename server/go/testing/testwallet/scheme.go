@@ -0,0 +1,119 @@
+package testwallet
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/coinbase/x402/go/mechanisms/evm"
+	"github.com/coinbase/x402/go/types"
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// exactScheme signs exact-scheme ERC-3009 payments like the x402 EVM client, but for any
+// network in evm.NetworkConfigs rather than a fixed list, so registered local chains such as
+// devnet.Network can be paid on too
+type exactScheme struct {
+	signer evm.ClientEvmSigner
+}
+
+func (s *exactScheme) Scheme() string {
+	return evm.SchemeExact
+}
+
+func (s *exactScheme) CreatePaymentPayload(ctx context.Context, requirements types.PaymentRequirements) (types.PaymentPayload, error) {
+	config, err := evm.GetNetworkConfig(requirements.Network)
+	if err != nil {
+		return types.PaymentPayload{}, err
+	}
+
+	assetInfo, err := evm.GetAssetInfo(requirements.Network, requirements.Asset)
+	if err != nil {
+		return types.PaymentPayload{}, err
+	}
+
+	value, ok := new(big.Int).SetString(requirements.Amount, 10)
+	if !ok {
+		return types.PaymentPayload{}, fmt.Errorf("invalid amount: %s", requirements.Amount)
+	}
+
+	// A server-issued challenge nonce is signed in place of a random one
+	nonce, ok := requirements.Extra[xtended402.ChallengeNonceExtraKey].(string)
+	if !ok {
+		if nonce, err = evm.CreateNonce(); err != nil {
+			return types.PaymentPayload{}, err
+		}
+	}
+	// Sign for as long as the requirements ask, or an hour like the x402 EVM client
+	validity := time.Hour
+	if requirements.MaxTimeoutSeconds > 0 {
+		validity = time.Duration(requirements.MaxTimeoutSeconds) * time.Second
+	}
+	validAfter, validBefore := evm.CreateValidityWindow(validity)
+
+	// The token's EIP-712 domain comes from the requirements when the server provides it
+	tokenName, tokenVersion := assetInfo.Name, assetInfo.Version
+	if name, ok := requirements.Extra["name"].(string); ok {
+		tokenName = name
+	}
+	if version, ok := requirements.Extra["version"].(string); ok {
+		tokenVersion = version
+	}
+
+	authorization := evm.ExactEIP3009Authorization{
+		From:        s.signer.Address(),
+		To:          requirements.PayTo,
+		Value:       value.String(),
+		ValidAfter:  validAfter.String(),
+		ValidBefore: validBefore.String(),
+		Nonce:       nonce,
+	}
+	nonceBytes, _ := evm.HexToBytes(nonce)
+
+	signature, err := s.signer.SignTypedData(ctx,
+		evm.TypedDataDomain{
+			Name:              tokenName,
+			Version:           tokenVersion,
+			ChainID:           config.ChainID,
+			VerifyingContract: assetInfo.Address,
+		},
+		map[string][]evm.TypedDataField{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"TransferWithAuthorization": {
+				{Name: "from", Type: "address"},
+				{Name: "to", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "validAfter", Type: "uint256"},
+				{Name: "validBefore", Type: "uint256"},
+				{Name: "nonce", Type: "bytes32"},
+			},
+		},
+		"TransferWithAuthorization",
+		map[string]interface{}{
+			"from":        authorization.From,
+			"to":          authorization.To,
+			"value":       value,
+			"validAfter":  validAfter,
+			"validBefore": validBefore,
+			"nonce":       nonceBytes,
+		},
+	)
+	if err != nil {
+		return types.PaymentPayload{}, fmt.Errorf("failed to sign authorization: %w", err)
+	}
+
+	evmPayload := &evm.ExactEIP3009Payload{
+		Signature:     evm.BytesToHex(signature),
+		Authorization: authorization,
+	}
+	return types.PaymentPayload{
+		X402Version: 2,
+		Payload:     evmPayload.ToMap(),
+	}, nil
+}
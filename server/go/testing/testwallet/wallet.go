@@ -0,0 +1,153 @@
+// Package testwallet provides deterministic EVM wallets that sign valid ERC-3009 payments for
+// the exact scheme, so example apps and tests can pay themselves locally.
+//
+//	wallet := testwallet.ForNetwork("eip155:84532")
+//	headers, err := wallet.Pay(ctx, paymentRequired) // answers a 402 challenge
+//
+// The keys are public. Never fund them on a real network.
+package testwallet
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	x402 "github.com/coinbase/x402/go"
+	x402http "github.com/coinbase/x402/go/http"
+	"github.com/coinbase/x402/go/mechanisms/evm"
+	evmsigners "github.com/coinbase/x402/go/signers/evm"
+	"github.com/coinbase/x402/go/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Mnemonic is the default Anvil/Hardhat development mnemonic that Account keys derive from
+const Mnemonic = "test test test test test test test test test test test junk"
+
+// DefaultPrivateKey is Account(0), pre-funded on local Anvil and Hardhat chains
+const DefaultPrivateKey = "0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+
+// accountKeys are the first ten keys derived from Mnemonic (m/44'/60'/0'/0/i)
+var accountKeys = []string{
+	DefaultPrivateKey,
+	"0x59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d",
+	"0x5de4111afa1a4b94908f83103eb1f1706367c2e68ca870fc3fb9a804cdab365a",
+	"0x7c852118294e51e653712a81e05800f419141751be58f605c371e15141b007a6",
+	"0x47e179ec197488593b187f80a00eb0da91f1b9d0b13f8733639f19c30a34926a",
+	"0x8b3a350cf5c34c9194ca85829a2df0ec3153be0318b5e2d3348e872092edffba",
+	"0x92db14e403b83dfe3df233f83dfa3a0d7096f21ca9b0d6d6b8d88b2b4ec1564e",
+	"0x4bbbf85ce3377467afe5d46f804f221813b2bb87f24d81f60f1fcdbf7cbf4356",
+	"0xdbda1821b80551c9d65939329250298aa3472ba22feea921c0cf5d620ea67b97",
+	"0x2a871d0798f97d79848a013d4936a73bf4cc922c825d33c1cf7073dff6d409c6",
+}
+
+// localNetworks are development chains where Anvil/Hardhat pre-fund the Mnemonic accounts
+var localNetworks = map[string]bool{
+	"eip155:31337": true, // Anvil, Hardhat
+	"eip155:1337":  true, // Ganache, Geth --dev
+}
+
+// Wallet is a test payer with a fixed private key
+type Wallet struct {
+	privateKey string
+	signer     evm.ClientEvmSigner
+}
+
+// New creates a wallet from a hex private key
+func New(privateKeyHex string) (*Wallet, error) {
+	signer, err := evmsigners.NewClientSignerFromPrivateKey(privateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("testwallet: invalid private key: %w", err)
+	}
+	return &Wallet{privateKey: privateKeyHex, signer: signer}, nil
+}
+
+// MustNew is like New but panics on an invalid key
+func MustNew(privateKeyHex string) *Wallet {
+	w, err := New(privateKeyHex)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// Account returns development account index (0-9) of Mnemonic
+func Account(index int) *Wallet {
+	if index < 0 || index >= len(accountKeys) {
+		panic(fmt.Sprintf("testwallet: account index %d out of range [0, %d)", index, len(accountKeys)))
+	}
+	return MustNew(accountKeys[index])
+}
+
+// ForNetwork returns the fixed wallet for a CAIP-2 network. Local development chains use
+// Account(0) since it's pre-funded there; every other network gets its own key derived from
+// Mnemonic and the network, so multi-network tests can tell payers apart.
+func ForNetwork(network string) *Wallet {
+	if localNetworks[network] {
+		return Account(0)
+	}
+	key := crypto.Keccak256([]byte(Mnemonic + "/" + network))
+	return MustNew("0x" + hex.EncodeToString(key))
+}
+
+// Address returns the wallet's checksummed address
+func (w *Wallet) Address() string {
+	return w.signer.Address()
+}
+
+// PrivateKey returns the wallet's hex private key
+func (w *Wallet) PrivateKey() string {
+	return w.privateKey
+}
+
+// Signer returns the wallet as an x402 EVM client signer
+func (w *Wallet) Signer() evm.ClientEvmSigner {
+	return w.signer
+}
+
+// Client returns an x402 client paying with the exact scheme on networks
+// (every EVM network if none are given)
+func (w *Wallet) Client(networks ...x402.Network) *x402.X402Client {
+	if len(networks) == 0 {
+		networks = []x402.Network{"eip155:*"}
+	}
+
+	client := x402.Newx402Client()
+	for _, network := range networks {
+		client.Register(network, &exactScheme{signer: w.signer})
+	}
+	return client
+}
+
+// Sign signs an ERC-3009 authorization satisfying requirements
+func (w *Wallet) Sign(ctx context.Context, requirements types.PaymentRequirements) (types.PaymentPayload, error) {
+	payload, err := (&exactScheme{signer: w.signer}).CreatePaymentPayload(ctx, requirements)
+	if err != nil {
+		return types.PaymentPayload{}, fmt.Errorf("testwallet: failed to sign payment: %w", err)
+	}
+	payload.Accepted = requirements
+	return payload, nil
+}
+
+// Pay answers a 402 challenge: it selects requirements the wallet can satisfy, signs them,
+// and returns the payment headers to retry the request with
+func (w *Wallet) Pay(ctx context.Context, required *types.PaymentRequired) (map[string]string, error) {
+	client := w.Client()
+
+	selected, err := client.SelectPaymentRequirements(required.Accepts)
+	if err != nil {
+		return nil, fmt.Errorf("testwallet: cannot satisfy payment requirements: %w", err)
+	}
+
+	payload, err := client.CreatePaymentPayload(ctx, selected, required.Resource, required.Extensions)
+	if err != nil {
+		return nil, fmt.Errorf("testwallet: failed to sign payment: %w", err)
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("testwallet: failed to encode payment: %w", err)
+	}
+
+	return x402http.Newx402HTTPClient(client).EncodePaymentSignatureHeader(payloadBytes), nil
+}
@@ -0,0 +1,75 @@
+package xtended402
+
+import (
+	"math/big"
+
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// TipMetadataKey is the event metadata key carrying how much a payment paid above the price,
+// in the asset's smallest unit
+const TipMetadataKey = "tip"
+
+// AuthorizedAmount returns the amount payload pays in the asset's smallest unit: the value of
+// an EVM exact payload's authorization, otherwise the amount of the requirements it accepted
+func AuthorizedAmount(payload *x402types.PaymentPayload) string {
+	if payload == nil {
+		return ""
+	}
+	authorization, _ := payload.Payload["authorization"].(map[string]interface{})
+	if value, ok := authorization["value"].(string); ok {
+		return value
+	}
+	return payload.Accepted.Amount
+}
+
+// PaymentTip returns how much payload pays above the amount of requirements, in the asset's
+// smallest unit, or "" if it pays no more than the amount
+func PaymentTip(payload *x402types.PaymentPayload, requirements *x402types.PaymentRequirements) string {
+	if requirements == nil {
+		return ""
+	}
+	paid, ok := new(big.Int).SetString(AuthorizedAmount(payload), 10)
+	if !ok {
+		return ""
+	}
+	price, ok := new(big.Int).SetString(requirements.Amount, 10)
+	if !ok || paid.Cmp(price) <= 0 {
+		return ""
+	}
+	return paid.Sub(paid, price).String()
+}
+
+// TippedRequirements returns the requirements among available that payload tips on: it
+// accepted them with a larger amount, and pays at least their amount. Returns nil if there are
+// none.
+func TippedRequirements(payload *x402types.PaymentPayload, available []x402types.PaymentRequirements) *x402types.PaymentRequirements {
+	accepted, ok := new(big.Int).SetString(payload.Accepted.Amount, 10)
+	if !ok {
+		return nil
+	}
+	paid, ok := new(big.Int).SetString(AuthorizedAmount(payload), 10)
+	if !ok {
+		return nil
+	}
+	for i := range available {
+		requirements := &available[i]
+		if payload.Accepted.Scheme != requirements.Scheme ||
+			payload.Accepted.Network != requirements.Network ||
+			payload.Accepted.Asset != requirements.Asset ||
+			payload.Accepted.PayTo != requirements.PayTo {
+			continue
+		}
+		price, ok := new(big.Int).SetString(requirements.Amount, 10)
+		if ok && accepted.Cmp(price) > 0 && paid.Cmp(price) >= 0 {
+			return requirements
+		}
+	}
+	return nil
+}
+
+// Tip returns how much the payment paid above the price, in the asset's smallest unit, or ""
+// if it paid the price
+func (p *PaymentData) Tip() string {
+	return PaymentTip(p.PaymentPayload, p.PaymentRequirements)
+}
@@ -0,0 +1,54 @@
+package xtended402
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// TopUpHeader carries a base64-encoded JSON TopUp on 402 responses caused by an exhausted
+// balance or API key quota
+const TopUpHeader = "X-Top-Up"
+
+// Top-up models
+const (
+	TopUpBalance = "balance"
+	TopUpAPIKey  = "apiKey"
+)
+
+// TopUp tells a client how to replenish an exhausted balance or quota, so it can top up and
+// retry without a human. Balance amounts are in the asset's smallest unit; API key amounts are
+// requests.
+type TopUp struct {
+	// Model is TopUpBalance or TopUpAPIKey
+	Model string `json:"model"`
+
+	// Remaining is what's left and Required what the request needs
+	Remaining string `json:"remaining"`
+	Required  string `json:"required"`
+
+	// Suggested is the amount the server suggests adding
+	Suggested string `json:"suggested"`
+
+	// Network and Asset identify the balance's currency
+	Network string `json:"network,omitempty"`
+	Asset   string `json:"asset,omitempty"`
+
+	// Routes are the route patterns that top up: deposit routes or API key mint routes
+	Routes []string `json:"routes"`
+}
+
+// Header encodes the top-up for TopUpHeader
+func (t *TopUp) Header() string {
+	data, _ := json.Marshal(t)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// DecodeTopUp decodes a TopUpHeader value
+func DecodeTopUp(header string) (*TopUp, error) {
+	var topUp TopUp
+	if err := decodeHeader(header, &topUp); err != nil {
+		return nil, fmt.Errorf("invalid top-up header: %w", err)
+	}
+	return &topUp, nil
+}
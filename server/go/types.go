@@ -28,6 +28,38 @@ type PaymentData struct {
 
 	// RequestBody contains the raw request body JSON for access in handlers
 	RequestBody json.RawMessage
+
+	// FiatPayment is set instead of PaymentPayload when the request was paid by card through
+	// the middleware's fiat fallback
+	FiatPayment *FiatPayment
+
+	// WalletPayment is set instead of PaymentPayload when the request was paid by a direct
+	// wallet transfer through the middleware's wallet payments
+	WalletPayment *WalletPayment
+
+	// Invoice is set instead of PaymentPayload when the request redeemed a paid invoice
+	Invoice *Invoice
+
+	// OrderID is the order created by the middleware's OrderConnector, if one is configured
+	OrderID string
+
+	// OrderReference is the application's reference set by the pricing step with
+	// SetOrderReferenceGin and echoed in the payment's requirements, e.g. a cart ID
+	OrderReference string
+
+	// Tax is the tax worked out by a TaxedPrice price for the buyer, or nil
+	Tax *TaxBreakdown
+
+	// APIKey is the key minted for this payment when the route is an API key mint route
+	APIKey string
+
+	// PayerName is the payer's resolved name, e.g. "alice.base.eth", when the middleware has a
+	// NameResolver and the payer has one
+	PayerName string
+
+	// RouteParams are the parameters of the route pattern the request matched, e.g.
+	// {"id": "42"} for "GET /api/items/:id"
+	RouteParams map[string]string
 }
 
 // UnmarshalOrderData unmarshals the request body into the provided struct.
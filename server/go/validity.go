@@ -0,0 +1,79 @@
+package xtended402
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	x402http "github.com/coinbase/x402/go/http"
+	x402types "github.com/coinbase/x402/go/types"
+)
+
+// ErrValidityWindow is returned for a payment authorization that has expired, isn't valid yet,
+// or stays valid for longer than its requirements allow
+var ErrValidityWindow = errors.New("payment validity window rejected")
+
+// ValidityGrace is how far an authorization may outlast its requirements' maxTimeoutSeconds,
+// for clock skew between client and server
+const ValidityGrace = 30 * time.Second
+
+// RouteWithValidity returns a copy of route whose payment options ask for authorizations valid
+// for validity, in whole seconds: short for instant purchases, long for slow checkout flows.
+// x402 defaults to 60 seconds.
+func RouteWithValidity(route x402http.RouteConfig, validity time.Duration) x402http.RouteConfig {
+	seconds := int(math.Ceil(validity.Seconds()))
+	accepts := make(x402http.PaymentOptions, len(route.Accepts))
+	for i, option := range route.Accepts {
+		option.MaxTimeoutSeconds = seconds
+		accepts[i] = option
+	}
+	route.Accepts = accepts
+	return route
+}
+
+// AuthorizationWindow returns when an EVM payment's ERC-3009 authorization becomes valid and
+// when it expires. ok is false for payloads without one.
+func AuthorizationWindow(payload *x402types.PaymentPayload) (validAfter, validBefore time.Time, ok bool) {
+	if payload == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	authorization, _ := payload.Payload["authorization"].(map[string]interface{})
+	after, _ := authorization["validAfter"].(string)
+	before, _ := authorization["validBefore"].(string)
+	afterUnix, err := strconv.ParseInt(after, 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	beforeUnix, err := strconv.ParseInt(before, 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return time.Unix(afterUnix, 0), time.Unix(beforeUnix, 0), true
+}
+
+// CheckValidityWindow checks that an EVM payment's authorization is valid at now and expires
+// within its requirements' maxTimeoutSeconds (plus ValidityGrace). Payloads without an
+// authorization window pass.
+func CheckValidityWindow(payload *x402types.PaymentPayload, requirements *x402types.PaymentRequirements, now time.Time) error {
+	validAfter, validBefore, ok := AuthorizationWindow(payload)
+	if !ok {
+		return nil
+	}
+	switch {
+	case !now.Before(validBefore):
+		return fmt.Errorf("%w: the authorization expired at %s", ErrValidityWindow, validBefore.UTC().Format(time.RFC3339))
+	case now.Before(validAfter):
+		return fmt.Errorf("%w: the authorization isn't valid until %s", ErrValidityWindow, validAfter.UTC().Format(time.RFC3339))
+	}
+	if requirements == nil || requirements.MaxTimeoutSeconds <= 0 {
+		return nil
+	}
+	limit := now.Add(time.Duration(requirements.MaxTimeoutSeconds)*time.Second + ValidityGrace)
+	if validBefore.After(limit) {
+		return fmt.Errorf("%w: the authorization is valid until %s, longer than the route's %d seconds",
+			ErrValidityWindow, validBefore.UTC().Format(time.RFC3339), requirements.MaxTimeoutSeconds)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package xtended402
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	x402 "github.com/coinbase/x402/go"
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// WalletBalanceChecker reads how much of an asset a wallet holds, in the asset's smallest unit
+type WalletBalanceChecker interface {
+	WalletBalance(ctx context.Context, network x402.Network, asset, address string) (*big.Int, error)
+}
+
+// WalletBalanceCheckerFunc adapts a function to WalletBalanceChecker
+type WalletBalanceCheckerFunc func(ctx context.Context, network x402.Network, asset, address string) (*big.Int, error)
+
+// WalletBalance calls f
+func (f WalletBalanceCheckerFunc) WalletBalance(ctx context.Context, network x402.Network, asset, address string) (*big.Int, error) {
+	return f(ctx, network, asset, address)
+}
+
+// WalletBalanceReport says whether a wallet can pay a route, so checkout UIs can warn before
+// the user signs
+type WalletBalanceReport struct {
+	Payer string `json:"payer"`
+
+	// Sufficient is true when the wallet holds enough for at least one payment option
+	Sufficient bool `json:"sufficient"`
+
+	Accepts []WalletBalanceOption `json:"accepts"`
+}
+
+// WalletBalanceOption is the wallet's balance of one payment option's asset
+type WalletBalanceOption struct {
+	Scheme        string `json:"scheme"`
+	Network       string `json:"network"`
+	Asset         string `json:"asset"`
+	Amount        string `json:"amount"`
+	DisplayAmount string `json:"displayAmount"`
+
+	// Balance is the wallet's balance in the asset's smallest unit, "" when it couldn't be read
+	Balance    string `json:"balance,omitempty"`
+	Sufficient bool   `json:"sufficient"`
+
+	// Error says why the balance couldn't be read, e.g. no RPC node for the network
+	Error string `json:"error,omitempty"`
+}
+
+// CheckWalletBalance reads payer's balance of each payment option's asset. Options whose
+// balance can't be read aren't sufficient and carry the error.
+func CheckWalletBalance(ctx context.Context, checker WalletBalanceChecker, payer string, requirements []x402types.PaymentRequirements) WalletBalanceReport {
+	report := WalletBalanceReport{Payer: payer, Accepts: make([]WalletBalanceOption, 0, len(requirements))}
+	for _, req := range requirements {
+		option := WalletBalanceOption{
+			Scheme:        req.Scheme,
+			Network:       req.Network,
+			Asset:         req.Asset,
+			Amount:        req.Amount,
+			DisplayAmount: FormatTokenAmount(x402.Network(req.Network), req.Asset, req.Amount),
+		}
+		amount, ok := new(big.Int).SetString(req.Amount, 10)
+		if !ok {
+			option.Error = fmt.Sprintf("invalid amount %q", req.Amount)
+			report.Accepts = append(report.Accepts, option)
+			continue
+		}
+
+		balance, err := checker.WalletBalance(ctx, x402.Network(req.Network), req.Asset, payer)
+		if err != nil {
+			option.Error = err.Error()
+		} else {
+			option.Balance = balance.String()
+			option.Sufficient = balance.Cmp(amount) >= 0
+		}
+		report.Sufficient = report.Sufficient || option.Sufficient
+		report.Accepts = append(report.Accepts, option)
+	}
+	return report
+}
+
+// ============================================================================
+// RPC Wallet Balance Checker
+// ============================================================================
+
+// balanceOfABI holds the ERC-20 balanceOf method
+var balanceOfABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(`[
+		{"name": "balanceOf", "type": "function", "stateMutability": "view", "inputs": [{"name": "account", "type": "address"}], "outputs": [{"name": "", "type": "uint256"}]}
+	]`))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// RPCWalletBalanceChecker reads ERC-20 balances through JSON-RPC nodes
+type RPCWalletBalanceChecker struct {
+	clients map[x402.Network]*ethclient.Client
+}
+
+// NewRPCWalletBalanceChecker connects to an RPC node per network
+func NewRPCWalletBalanceChecker(ctx context.Context, rpcURLs map[x402.Network]string) (*RPCWalletBalanceChecker, error) {
+	clients := make(map[x402.Network]*ethclient.Client, len(rpcURLs))
+	for network, rpcURL := range rpcURLs {
+		client, err := ethclient.DialContext(ctx, rpcURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s RPC: %w", network, err)
+		}
+		clients[network] = client
+	}
+	return &RPCWalletBalanceChecker{clients: clients}, nil
+}
+
+// WalletBalance calls the token's balanceOf for address
+func (c *RPCWalletBalanceChecker) WalletBalance(ctx context.Context, network x402.Network, asset, address string) (*big.Int, error) {
+	client, ok := c.clients[network]
+	if !ok {
+		return nil, fmt.Errorf("no RPC configured for %s", network)
+	}
+	if !common.IsHexAddress(asset) || !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("not an EVM token and address: %s, %s", asset, address)
+	}
+
+	data, err := balanceOfABI.Pack("balanceOf", common.HexToAddress(address))
+	if err != nil {
+		return nil, err
+	}
+	token := common.HexToAddress(asset)
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read balance on %s: %w", network, err)
+	}
+	values, err := balanceOfABI.Unpack("balanceOf", out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read balance on %s: %w", network, err)
+	}
+	balance, _ := values[0].(*big.Int)
+	if balance == nil {
+		return nil, fmt.Errorf("unexpected balanceOf result on %s", network)
+	}
+	return balance, nil
+}
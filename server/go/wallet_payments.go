@@ -0,0 +1,520 @@
+package xtended402
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	x402 "github.com/coinbase/x402/go"
+	x402types "github.com/coinbase/x402/go/types"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"rsc.io/qr"
+)
+
+// Wallet payment references
+const (
+	// WalletPaymentHeader carries a paid wallet checkout's reference when a client retries the
+	// request after paying from a phone
+	WalletPaymentHeader = "X-Wallet-Payment"
+
+	// WalletPaymentCookie carries the reference instead of WalletPaymentHeader when the paywall
+	// page reloads itself after detecting the payment
+	WalletPaymentCookie = "xtended402_wallet_payment"
+)
+
+// WalletTransferScheme is the scheme recorded on events for direct wallet transfers
+const WalletTransferScheme = "transfer"
+
+// ErrWalletPaymentInvalid is returned when a wallet payment reference is unknown, unpaid,
+// already used, or doesn't cover the requested resource
+var ErrWalletPaymentInvalid = errors.New("wallet payment invalid")
+
+// WalletCheckout is a direct token transfer offered on the paywall for wallets without x402
+// support, typically a phone wallet scanning a QR code
+type WalletCheckout struct {
+	Reference string `json:"reference"`
+
+	// URI is the EIP-681 payment request, e.g.
+	// "ethereum:0x036C…@84532/transfer?address=0x…&uint256=10000217"
+	URI string `json:"uri"`
+
+	// Links open the payment in wallet apps
+	Links []WalletLink `json:"links"`
+
+	// QRCode is an SVG image of URI
+	QRCode string `json:"qrCode"`
+
+	Network x402.Network `json:"network"`
+	Asset   string       `json:"asset"`
+	PayTo   string       `json:"payTo"`
+
+	// Amount is the exact amount to transfer in smallest units: the price plus a few units
+	// that tell this checkout's transfer apart from others
+	Amount string `json:"amount"`
+
+	// DisplayAmount is Amount formatted, e.g. "0.010217 USDC"
+	DisplayAmount string `json:"displayAmount"`
+
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// WalletLink opens a wallet checkout in a wallet app
+type WalletLink struct {
+	Wallet string `json:"wallet"`
+	URL    string `json:"url"`
+}
+
+// WalletPaymentStatus is what the paywall polls while waiting for a transfer
+type WalletPaymentStatus struct {
+	Reference   string    `json:"reference"`
+	Paid        bool      `json:"paid"`
+	Expired     bool      `json:"expired"`
+	Transaction string    `json:"transaction,omitempty"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// WalletPayment is a detected transfer redeemed for a request
+type WalletPayment struct {
+	Reference   string       `json:"reference"`
+	Transaction string       `json:"transaction"`
+	Payer       string       `json:"payer"`
+	Network     x402.Network `json:"network"`
+	Asset       string       `json:"asset"`
+	Amount      string       `json:"amount"`
+}
+
+// Transfer is a token transfer found on chain
+type Transfer struct {
+	Transaction string
+	From        string
+	Block       uint64
+}
+
+// TransferFinder looks up token transfers on chain
+type TransferFinder interface {
+	// LatestBlock returns the network's current block number
+	LatestBlock(ctx context.Context, network x402.Network) (uint64, error)
+
+	// FindTransfer returns a transfer of exactly amount of asset to payTo at or after
+	// fromBlock, or nil if there is none yet
+	FindTransfer(ctx context.Context, network x402.Network, asset, payTo, amount string, fromBlock uint64) (*Transfer, error)
+}
+
+// walletCheckout tracks one offered checkout
+type walletCheckout struct {
+	checkout  WalletCheckout
+	resource  string
+	price     *big.Int
+	fromBlock uint64
+	checkedAt time.Time
+	transfer  *Transfer
+	redeemed  bool
+}
+
+// WalletPayments lets buyers pay from a phone wallet by scanning a QR code. Each checkout asks
+// for a direct transfer of the price plus a few smallest units, so its transfer can be told
+// apart from other buyers'; the paywall polls Status until the transfer is found, then reloads
+// with the reference, which Redeem accepts as payment for one request. It's safe for
+// concurrent use.
+//
+// Checkouts are held in memory, so run one replica or route a buyer's requests to the same
+// one. Transfers count once mined; they're never reversed by the facilitator because none is
+// involved.
+type WalletPayments struct {
+	finder        TransferFinder
+	clock         Clock
+	ttl           time.Duration
+	maxDust       int64
+	checkInterval time.Duration
+
+	mu        sync.Mutex
+	checkouts map[string]*walletCheckout
+}
+
+// WalletPaymentOption configures WalletPayments
+type WalletPaymentOption func(*WalletPayments)
+
+// WithWalletPaymentTTL sets how long a checkout's QR code can be paid (default 15 minutes).
+// Transfers arriving up to one more TTL late are still accepted.
+func WithWalletPaymentTTL(ttl time.Duration) WalletPaymentOption {
+	return func(w *WalletPayments) {
+		w.ttl = ttl
+	}
+}
+
+// WithWalletPaymentDust sets the most smallest units added to a price to tell checkouts apart
+// (default 999, a tenth of a cent of USDC). It limits how many checkouts for the same price
+// and pay-to address can be held at once.
+func WithWalletPaymentDust(maxDust int64) WalletPaymentOption {
+	return func(w *WalletPayments) {
+		w.maxDust = maxDust
+	}
+}
+
+// WithWalletPaymentCheckInterval sets how often Status may query the chain for a checkout
+// (default 2 seconds), bounding RPC load from polling pages
+func WithWalletPaymentCheckInterval(interval time.Duration) WalletPaymentOption {
+	return func(w *WalletPayments) {
+		w.checkInterval = interval
+	}
+}
+
+// WithWalletPaymentClock sets the clock used for expiry (defaults to the system clock)
+func WithWalletPaymentClock(clock Clock) WalletPaymentOption {
+	return func(w *WalletPayments) {
+		w.clock = clock
+	}
+}
+
+// NewWalletPayments creates WalletPayments finding transfers with finder
+func NewWalletPayments(finder TransferFinder, opts ...WalletPaymentOption) *WalletPayments {
+	w := &WalletPayments{
+		finder:        finder,
+		ttl:           15 * time.Minute,
+		maxDust:       999,
+		checkInterval: 2 * time.Second,
+		checkouts:     make(map[string]*walletCheckout),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.clock = ClockOrSystem(w.clock)
+	return w
+}
+
+// Checkout offers a transfer paying requirements for resource ("METHOD /path")
+func (w *WalletPayments) Checkout(ctx context.Context, resource string, requirements x402types.PaymentRequirements) (*WalletCheckout, error) {
+	network := x402.Network(requirements.Network)
+	chainID, ok := strings.CutPrefix(requirements.Network, "eip155:")
+	if !ok {
+		return nil, fmt.Errorf("wallet payments need an EVM network, got %s", network)
+	}
+	price, ok := new(big.Int).SetString(requirements.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", requirements.Amount)
+	}
+
+	fromBlock, err := w.finder.LatestBlock(ctx, network)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest block: %w", err)
+	}
+	reference, err := newWalletReference()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.clock.Now()
+	w.sweepLocked(now)
+
+	amount, err := w.uniqueAmountLocked(price, requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	uri := EIP681URI(chainID, requirements.Asset, requirements.PayTo, amount.String())
+	qrCode, err := QRCodeSVG(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &walletCheckout{
+		checkout: WalletCheckout{
+			Reference:     reference,
+			URI:           uri,
+			Links:         WalletLinks(uri),
+			QRCode:        qrCode,
+			Network:       network,
+			Asset:         requirements.Asset,
+			PayTo:         requirements.PayTo,
+			Amount:        amount.String(),
+			DisplayAmount: FormatTokenAmount(network, requirements.Asset, amount.String()),
+			ExpiresAt:     now.Add(w.ttl),
+		},
+		resource:  resource,
+		price:     price,
+		fromBlock: fromBlock,
+	}
+	w.checkouts[reference] = entry
+
+	checkout := entry.checkout
+	return &checkout, nil
+}
+
+// uniqueAmountLocked adds dust to price so no other checkout to the same address asks for the
+// same amount, keeping each transfer matched to one checkout
+func (w *WalletPayments) uniqueAmountLocked(price *big.Int, requirements x402types.PaymentRequirements) (*big.Int, error) {
+	taken := make(map[string]bool)
+	for _, entry := range w.checkouts {
+		c := entry.checkout
+		if string(c.Network) == requirements.Network &&
+			strings.EqualFold(c.Asset, requirements.Asset) && strings.EqualFold(c.PayTo, requirements.PayTo) {
+			taken[c.Amount] = true
+		}
+	}
+
+	for attempt := 0; attempt < 32; attempt++ {
+		dust, err := rand.Int(rand.Reader, big.NewInt(w.maxDust))
+		if err != nil {
+			return nil, err
+		}
+		amount := new(big.Int).Add(price, dust.Add(dust, big.NewInt(1)))
+		if !taken[amount.String()] {
+			return amount, nil
+		}
+	}
+	return nil, errors.New("too many pending wallet checkouts for this price")
+}
+
+// Status reports whether the checkout's transfer has been found, querying the chain at most
+// once per check interval
+func (w *WalletPayments) Status(ctx context.Context, reference string) (*WalletPaymentStatus, error) {
+	entry, err := w.find(ctx, reference, false)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := &WalletPaymentStatus{
+		Reference: reference,
+		Paid:      entry.transfer != nil,
+		Expired:   !w.clock.Now().Before(entry.checkout.ExpiresAt),
+		ExpiresAt: entry.checkout.ExpiresAt,
+	}
+	if entry.transfer != nil {
+		status.Transaction = entry.transfer.Transaction
+	}
+	return status, nil
+}
+
+// Redeem consumes reference as payment for resource, returning ErrWalletPaymentInvalid
+// (possibly wrapped) if it can't be used
+func (w *WalletPayments) Redeem(ctx context.Context, resource, reference string, requirements x402types.PaymentRequirements) (*WalletPayment, error) {
+	entry, err := w.find(ctx, reference, true)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	c := entry.checkout
+	required, _ := new(big.Int).SetString(requirements.Amount, 10)
+	switch {
+	case entry.transfer == nil:
+		return nil, fmt.Errorf("%w: no transfer found yet", ErrWalletPaymentInvalid)
+	case entry.redeemed:
+		return nil, fmt.Errorf("%w: already used", ErrWalletPaymentInvalid)
+	case entry.resource != resource:
+		return nil, fmt.Errorf("%w: paid for %s", ErrWalletPaymentInvalid, entry.resource)
+	case string(c.Network) != requirements.Network || !strings.EqualFold(c.Asset, requirements.Asset) || !strings.EqualFold(c.PayTo, requirements.PayTo):
+		return nil, fmt.Errorf("%w: paid with a different asset or recipient", ErrWalletPaymentInvalid)
+	case required == nil || entry.price.Cmp(required) < 0:
+		return nil, fmt.Errorf("%w: paid less than the current price", ErrWalletPaymentInvalid)
+	}
+
+	entry.redeemed = true
+	return &WalletPayment{
+		Reference:   reference,
+		Transaction: entry.transfer.Transaction,
+		Payer:       entry.transfer.From,
+		Network:     c.Network,
+		Asset:       c.Asset,
+		Amount:      c.Amount,
+	}, nil
+}
+
+// Release returns a redeemed reference so it can be used again, e.g. when the handler it paid
+// for failed
+func (w *WalletPayments) Release(ctx context.Context, reference string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if entry, ok := w.checkouts[reference]; ok {
+		entry.redeemed = false
+	}
+	return nil
+}
+
+// find returns the checkout for reference, looking for its transfer if it hasn't been found.
+// Unless force, the chain is queried at most once per check interval.
+func (w *WalletPayments) find(ctx context.Context, reference string, force bool) (*walletCheckout, error) {
+	w.mu.Lock()
+	entry, ok := w.checkouts[reference]
+	if !ok {
+		w.mu.Unlock()
+		return nil, fmt.Errorf("%w: unknown reference", ErrWalletPaymentInvalid)
+	}
+	now := w.clock.Now()
+	if entry.transfer != nil || (!force && now.Sub(entry.checkedAt) < w.checkInterval) {
+		w.mu.Unlock()
+		return entry, nil
+	}
+	entry.checkedAt = now
+	c := entry.checkout
+	w.mu.Unlock()
+
+	transfer, err := w.finder.FindTransfer(ctx, c.Network, c.Asset, c.PayTo, c.Amount, entry.fromBlock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up transfer: %w", err)
+	}
+	if transfer == nil {
+		return entry, nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if entry.transfer == nil {
+		entry.transfer = transfer
+	}
+	return entry, nil
+}
+
+// sweepLocked drops checkouts a TTL past their expiry
+func (w *WalletPayments) sweepLocked(now time.Time) {
+	for reference, entry := range w.checkouts {
+		if now.After(entry.checkout.ExpiresAt.Add(w.ttl)) {
+			delete(w.checkouts, reference)
+		}
+	}
+}
+
+func newWalletReference() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return "wp_" + hex.EncodeToString(id), nil
+}
+
+// EIP681URI builds an EIP-681 request to transfer amount (smallest units) of the ERC-20 token
+// asset to payTo on chainID
+func EIP681URI(chainID, asset, payTo, amount string) string {
+	return fmt.Sprintf("ethereum:%s@%s/transfer?address=%s&uint256=%s", asset, chainID, payTo, amount)
+}
+
+// WalletLinks returns links opening an EIP-681 request in wallet apps: the ethereum: URI for
+// whichever wallet the phone has, and MetaMask's universal link
+func WalletLinks(uri string) []WalletLink {
+	return []WalletLink{
+		{Wallet: "Wallet app", URL: uri},
+		{Wallet: "MetaMask", URL: "https://metamask.app.link/send/" + strings.TrimPrefix(uri, "ethereum:")},
+	}
+}
+
+// QRCodeSVG encodes text as a QR code drawn in SVG
+func QRCodeSVG(text string) (string, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return "", err
+	}
+
+	// A four-module quiet zone surrounds the code
+	size := code.Size + 8
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, size, size)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&svg, "M%d %dh1v1h-1z", x+4, y+4)
+			}
+		}
+	}
+	svg.WriteString(`"/></svg>`)
+	return svg.String(), nil
+}
+
+// ============================================================================
+// RPC Transfer Finder
+// ============================================================================
+
+// transferTopic is the ERC-20 Transfer(address,address,uint256) event signature
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// RPCTransferFinder finds ERC-20 transfers through JSON-RPC nodes
+type RPCTransferFinder struct {
+	clients map[x402.Network]*ethclient.Client
+}
+
+// NewRPCTransferFinder connects to an RPC node per network
+func NewRPCTransferFinder(ctx context.Context, rpcURLs map[x402.Network]string) (*RPCTransferFinder, error) {
+	clients := make(map[x402.Network]*ethclient.Client, len(rpcURLs))
+	for network, rpcURL := range rpcURLs {
+		client, err := ethclient.DialContext(ctx, rpcURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s RPC: %w", network, err)
+		}
+		clients[network] = client
+	}
+	return &RPCTransferFinder{clients: clients}, nil
+}
+
+func (f *RPCTransferFinder) client(network x402.Network) (*ethclient.Client, error) {
+	client, ok := f.clients[network]
+	if !ok {
+		return nil, fmt.Errorf("no RPC configured for %s", network)
+	}
+	return client, nil
+}
+
+// LatestBlock returns the network's current block number
+func (f *RPCTransferFinder) LatestBlock(ctx context.Context, network x402.Network) (uint64, error) {
+	client, err := f.client(network)
+	if err != nil {
+		return 0, err
+	}
+	return client.BlockNumber(ctx)
+}
+
+// FindTransfer searches Transfer logs of asset to payTo for one of exactly amount
+func (f *RPCTransferFinder) FindTransfer(ctx context.Context, network x402.Network, asset, payTo, amount string, fromBlock uint64) (*Transfer, error) {
+	client, err := f.client(network)
+	if err != nil {
+		return nil, err
+	}
+	want, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		Addresses: []common.Address{common.HexToAddress(asset)},
+		Topics: [][]common.Hash{
+			{transferTopic},
+			nil,
+			{common.BytesToHash(common.HexToAddress(payTo).Bytes())},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		if log.Removed || len(log.Topics) < 3 {
+			continue
+		}
+		if new(big.Int).SetBytes(log.Data).Cmp(want) == 0 {
+			return &Transfer{
+				Transaction: log.TxHash.Hex(),
+				From:        common.BytesToAddress(log.Topics[1].Bytes()).Hex(),
+				Block:       log.BlockNumber,
+			}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,252 @@
+// Package bigquery writes payment records to BigQuery through its REST API, for
+// warehouse.Sink. Pass an HTTP client that authorizes requests, e.g. from
+// golang.org/x/oauth2/google:
+//
+//	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/bigquery")
+//	sink := warehouse.NewSink(bigquery.New("my-project", "analytics", "payments", client))
+//
+// Rows are streamed with the record ID as insertId, so BigQuery drops best-effort duplicates
+// from retried batches. The table is partitioned by day on created_at.
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mvpoyatt/xtended402/server/go/warehouse"
+)
+
+// DefaultEndpoint is the BigQuery REST API
+const DefaultEndpoint = "https://bigquery.googleapis.com/bigquery/v2"
+
+// Backend is a warehouse.Backend for one BigQuery table
+type Backend struct {
+	project  string
+	dataset  string
+	table    string
+	client   *http.Client
+	endpoint string
+}
+
+// Option configures a Backend
+type Option func(*Backend)
+
+// WithEndpoint overrides the API endpoint, e.g. for an emulator
+func WithEndpoint(endpoint string) Option {
+	return func(b *Backend) {
+		b.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// New creates a Backend for project.dataset.table. The dataset must exist; the table is
+// created by EnsureSchema.
+func New(project, dataset, table string, client *http.Client, opts ...Option) *Backend {
+	b := &Backend{
+		project:  project,
+		dataset:  dataset,
+		table:    table,
+		client:   client,
+		endpoint: DefaultEndpoint,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+type field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+type tableResource struct {
+	TableReference map[string]string `json:"tableReference,omitempty"`
+	Schema         struct {
+		// Fields are kept raw so patching preserves what this package doesn't model
+		Fields []json.RawMessage `json:"fields"`
+	} `json:"schema"`
+	TimePartitioning map[string]string `json:"timePartitioning,omitempty"`
+}
+
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("bigquery: status %d: %s", e.status, e.message)
+}
+
+// EnsureSchema creates the table if needed, or adds the columns it's missing. Added columns
+// are NULLABLE, since BigQuery can't add required columns to an existing table.
+func (b *Backend) EnsureSchema(ctx context.Context) error {
+	var existing tableResource
+	err := b.call(ctx, http.MethodGet, b.tablePath(), nil, &existing)
+
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.status == http.StatusNotFound {
+		created := tableResource{
+			TableReference: map[string]string{
+				"projectId": b.project,
+				"datasetId": b.dataset,
+				"tableId":   b.table,
+			},
+			TimePartitioning: map[string]string{"type": "DAY", "field": "created_at"},
+		}
+		for _, column := range warehouse.Schema {
+			created.Schema.Fields = append(created.Schema.Fields, schemaField(column, false))
+		}
+		return b.call(ctx, http.MethodPost, b.datasetPath()+"/tables", created, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	have := make(map[string]bool, len(existing.Schema.Fields))
+	for _, raw := range existing.Schema.Fields {
+		var f field
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return fmt.Errorf("bigquery: invalid table schema: %w", err)
+		}
+		have[f.Name] = true
+	}
+
+	patched := existing.Schema.Fields
+	for _, column := range warehouse.Schema {
+		if !have[column.Name] {
+			patched = append(patched, schemaField(column, true))
+		}
+	}
+	if len(patched) == len(existing.Schema.Fields) {
+		return nil
+	}
+
+	var patch tableResource
+	patch.Schema.Fields = patched
+	return b.call(ctx, http.MethodPatch, b.tablePath(), patch, nil)
+}
+
+// Insert streams rows with tabledata.insertAll
+func (b *Backend) Insert(ctx context.Context, rows []warehouse.Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	type insertRow struct {
+		InsertID string        `json:"insertId"`
+		JSON     warehouse.Row `json:"json"`
+	}
+	request := struct {
+		Rows []insertRow `json:"rows"`
+	}{Rows: make([]insertRow, 0, len(rows))}
+	for _, row := range rows {
+		request.Rows = append(request.Rows, insertRow{InsertID: row.ID, JSON: row})
+	}
+
+	var response struct {
+		InsertErrors []struct {
+			Index  int `json:"index"`
+			Errors []struct {
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+	if err := b.call(ctx, http.MethodPost, b.tablePath()+"/insertAll", request, &response); err != nil {
+		return err
+	}
+
+	if len(response.InsertErrors) > 0 {
+		first := response.InsertErrors[0]
+		reason := "unknown"
+		if len(first.Errors) > 0 {
+			reason = first.Errors[0].Reason + ": " + first.Errors[0].Message
+		}
+		return fmt.Errorf("bigquery: %d of %d rows rejected (row %s: %s)",
+			len(response.InsertErrors), len(rows), rows[first.Index].ID, reason)
+	}
+	return nil
+}
+
+func (b *Backend) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("bigquery: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.endpoint+path, reader)
+	if err != nil {
+		return fmt.Errorf("bigquery: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("bigquery: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &failure) == nil && failure.Error.Message != "" {
+			message = failure.Error.Message
+		}
+		return &apiError{status: resp.StatusCode, message: message}
+	}
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("bigquery: invalid response: %w", err)
+	}
+	return nil
+}
+
+func (b *Backend) datasetPath() string {
+	return "/projects/" + url.PathEscape(b.project) + "/datasets/" + url.PathEscape(b.dataset)
+}
+
+func (b *Backend) tablePath() string {
+	return b.datasetPath() + "/tables/" + url.PathEscape(b.table)
+}
+
+func schemaField(column warehouse.Column, added bool) json.RawMessage {
+	f := field{Name: column.Name, Mode: "REQUIRED"}
+	switch column.Type {
+	case warehouse.TypeTimestamp:
+		f.Type = "TIMESTAMP"
+	case warehouse.TypeDecimal:
+		f.Type = "BIGNUMERIC"
+	default:
+		f.Type = "STRING"
+	}
+	if column.Nullable || added {
+		f.Mode = "NULLABLE"
+	}
+	data, _ := json.Marshal(f)
+	return data
+}
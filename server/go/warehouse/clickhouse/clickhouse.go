@@ -0,0 +1,163 @@
+// Package clickhouse writes payment records to ClickHouse through its HTTP interface, for
+// warehouse.Sink:
+//
+//	sink := warehouse.NewSink(clickhouse.New("http://clickhouse:8123", "analytics.payments",
+//		clickhouse.WithCredentials("writer", os.Getenv("CLICKHOUSE_PASSWORD")),
+//	))
+//
+// The table is a ReplacingMergeTree ordered by record ID, so rows inserted twice (a retried
+// batch or an overlapping backfill) collapse into one on merge; query with FINAL for exact
+// counts.
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mvpoyatt/xtended402/server/go/warehouse"
+)
+
+// Backend is a warehouse.Backend for one ClickHouse table
+type Backend struct {
+	endpoint string
+	table    string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// Option configures a Backend
+type Option func(*Backend)
+
+// WithCredentials sets the user and password (default the server's default user)
+func WithCredentials(user, password string) Option {
+	return func(b *Backend) {
+		b.user = user
+		b.password = password
+	}
+}
+
+// WithHTTPClient sets the HTTP client (default 30s timeout)
+func WithHTTPClient(client *http.Client) Option {
+	return func(b *Backend) {
+		b.client = client
+	}
+}
+
+// New creates a Backend for table ("name" or "database.name") on the server at endpoint
+// (e.g. "http://localhost:8123")
+func New(endpoint, table string, opts ...Option) *Backend {
+	b := &Backend{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		table:    table,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// EnsureSchema creates the table if needed and adds any columns it's missing
+func (b *Backend) EnsureSchema(ctx context.Context) error {
+	columns := make([]string, 0, len(warehouse.Schema))
+	additions := make([]string, 0, len(warehouse.Schema))
+	for _, column := range warehouse.Schema {
+		definition := quote(column.Name) + " " + columnType(column)
+		columns = append(columns, definition)
+		additions = append(additions, "ADD COLUMN IF NOT EXISTS "+definition)
+	}
+
+	create := fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = ReplacingMergeTree PARTITION BY toYYYYMM(created_at) ORDER BY id",
+		b.quotedTable(), strings.Join(columns, ", "),
+	)
+	if err := b.exec(ctx, create, nil); err != nil {
+		return err
+	}
+
+	alter := fmt.Sprintf("ALTER TABLE %s %s", b.quotedTable(), strings.Join(additions, ", "))
+	return b.exec(ctx, alter, nil)
+}
+
+// Insert writes rows as JSONEachRow
+func (b *Backend) Insert(ctx context.Context, rows []warehouse.Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("clickhouse: failed to encode row %s: %w", row.ID, err)
+		}
+	}
+
+	return b.exec(ctx, fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", b.quotedTable()), &body)
+}
+
+func (b *Backend) exec(ctx context.Context, query string, body io.Reader) error {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("date_time_input_format", "best_effort")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.endpoint+"/?"+params.Encode(), body)
+	if err != nil {
+		return fmt.Errorf("clickhouse: %w", err)
+	}
+	if b.user != "" {
+		req.Header.Set("X-ClickHouse-User", b.user)
+		req.Header.Set("X-ClickHouse-Key", b.password)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("clickhouse: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("clickhouse: status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (b *Backend) quotedTable() string {
+	parts := strings.Split(b.table, ".")
+	for i, part := range parts {
+		parts[i] = quote(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+func columnType(column warehouse.Column) string {
+	var base string
+	switch column.Type {
+	case warehouse.TypeTimestamp:
+		base = "DateTime64(3, 'UTC')"
+	case warehouse.TypeDecimal:
+		base = "Decimal(76, 18)"
+	default:
+		base = "String"
+	}
+	if column.Nullable {
+		return "Nullable(" + base + ")"
+	}
+	return base
+}
+
+func quote(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "\\`") + "`"
+}
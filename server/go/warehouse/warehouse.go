@@ -0,0 +1,335 @@
+// Package warehouse streams payment records into an analytics warehouse (BigQuery or
+// ClickHouse). A Sink buffers rows from payment events and writes them in batches; Backfill
+// loads history from a PaymentStore:
+//
+//	sink := warehouse.NewSink(clickhouse.New("http://clickhouse:8123", "payments"))
+//	defer sink.Close(context.Background())
+//	if err := sink.EnsureSchema(ctx); err != nil { ... }
+//	ginmw.PaymentMiddleware(routes, server, ginmw.WithEventHandler(sink.Handle))
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+)
+
+// Row is one payment record as stored in the warehouse
+type Row struct {
+	ID          string    `json:"id"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	Resource    string    `json:"resource"`
+	Scheme      string    `json:"scheme"`
+	Network     string    `json:"network"`
+	Asset       string    `json:"asset"`
+	Symbol      string    `json:"symbol"`
+	Amount      string    `json:"amount"`
+	Value       *string   `json:"value"`
+	PayTo       string    `json:"pay_to"`
+	Payer       string    `json:"payer"`
+	Transaction string    `json:"transaction"`
+	ErrorReason string    `json:"error_reason"`
+	Metadata    string    `json:"metadata"`
+	PayerName   string    `json:"payer_name"`
+	Fee         string    `json:"fee"`
+	NetAmount   string    `json:"net_amount"`
+}
+
+// ColumnType is a warehouse-neutral column type
+type ColumnType string
+
+const (
+	TypeString    ColumnType = "string"
+	TypeTimestamp ColumnType = "timestamp"
+
+	// TypeDecimal holds token amounts in whole units with up to 18 decimal places
+	TypeDecimal ColumnType = "decimal"
+)
+
+// Column describes a Row field
+type Column struct {
+	Name     string
+	Type     ColumnType
+	Nullable bool
+}
+
+// Schema lists the columns of Row. New columns are only ever appended, so EnsureSchema can
+// migrate existing tables by adding the missing ones.
+var Schema = []Column{
+	{Name: "id", Type: TypeString},
+	{Name: "status", Type: TypeString},
+	{Name: "created_at", Type: TypeTimestamp},
+	{Name: "resource", Type: TypeString},
+	{Name: "scheme", Type: TypeString},
+	{Name: "network", Type: TypeString},
+	{Name: "asset", Type: TypeString},
+	{Name: "symbol", Type: TypeString},
+	{Name: "amount", Type: TypeString},
+	{Name: "value", Type: TypeDecimal, Nullable: true},
+	{Name: "pay_to", Type: TypeString},
+	{Name: "payer", Type: TypeString},
+	{Name: "transaction", Type: TypeString},
+	{Name: "error_reason", Type: TypeString},
+	{Name: "metadata", Type: TypeString},
+	{Name: "payer_name", Type: TypeString},
+	{Name: "fee", Type: TypeString},
+	{Name: "net_amount", Type: TypeString},
+}
+
+// NewRow converts a payment record. Value is the amount in whole tokens (or currency units for
+// card payments), left null for tokens the x402 EVM mechanism doesn't know.
+func NewRow(record *xtended402.PaymentRecord) Row {
+	row := Row{
+		ID:          record.ID,
+		Status:      string(record.Status),
+		CreatedAt:   record.CreatedAt.UTC(),
+		Resource:    record.Resource,
+		Scheme:      record.Scheme,
+		Network:     string(record.Network),
+		Asset:       record.Asset,
+		Amount:      record.Amount,
+		PayTo:       record.PayTo,
+		Payer:       record.Payer,
+		Transaction: record.Transaction,
+		ErrorReason: record.ErrorReason,
+		PayerName:   record.Metadata[xtended402.PayerNameMetadataKey],
+		Fee:         record.Fee,
+		NetAmount:   record.NetAmount,
+	}
+
+	if record.Network == xtended402.FiatNetwork {
+		row.Symbol = strings.ToUpper(record.Asset)
+		if _, ok := new(big.Rat).SetString(record.Amount); ok {
+			value := record.Amount
+			row.Value = &value
+		}
+	} else if symbol, decimals, ok := xtended402.LookupToken(record.Network, record.Asset); ok {
+		row.Symbol = symbol
+		if units, ok := new(big.Int).SetString(record.Amount, 10); ok {
+			unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+			value := new(big.Rat).SetFrac(units, unit).FloatString(decimals)
+			row.Value = &value
+		}
+	}
+
+	if len(record.Metadata) > 0 {
+		metadata, _ := json.Marshal(record.Metadata)
+		row.Metadata = string(metadata)
+	}
+
+	return row
+}
+
+// Backend writes rows to a warehouse table
+type Backend interface {
+	// EnsureSchema creates the table, or adds the Schema columns it's missing
+	EnsureSchema(ctx context.Context) error
+
+	// Insert writes rows. Row IDs are stable, so backends deduplicate retried inserts where
+	// the warehouse supports it.
+	Insert(ctx context.Context, rows []Row) error
+}
+
+// Sink batches payment records into a Backend. Rows are written when a batch fills up, every
+// flush interval, and on Close. Failed batches are kept and retried on the next flush, up to
+// the buffer limit.
+type Sink struct {
+	backend   Backend
+	batchSize int
+	maxBuffer int
+	interval  time.Duration
+	clock     xtended402.Clock
+	onError   func(err error)
+
+	mu      sync.Mutex
+	buffer  []Row
+	dropped int
+	flushMu sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// SinkOption configures a Sink
+type SinkOption func(*Sink)
+
+// WithBatchSize sets how many rows trigger a write (default 500)
+func WithBatchSize(size int) SinkOption {
+	return func(s *Sink) {
+		s.batchSize = size
+	}
+}
+
+// WithFlushInterval sets how often buffered rows are written (default 10s)
+func WithFlushInterval(interval time.Duration) SinkOption {
+	return func(s *Sink) {
+		s.interval = interval
+	}
+}
+
+// WithMaxBuffer caps rows held while the warehouse is unavailable; the oldest are dropped
+// beyond it (default 100000)
+func WithMaxBuffer(rows int) SinkOption {
+	return func(s *Sink) {
+		s.maxBuffer = rows
+	}
+}
+
+// WithClock sets the clock driving the flush interval (default SystemClock)
+func WithClock(clock xtended402.Clock) SinkOption {
+	return func(s *Sink) {
+		s.clock = xtended402.ClockOrSystem(clock)
+	}
+}
+
+// WithErrorHandler is called when a write fails or rows are dropped
+func WithErrorHandler(handler func(err error)) SinkOption {
+	return func(s *Sink) {
+		s.onError = handler
+	}
+}
+
+// NewSink creates a Sink and starts its flush loop. Call Close to stop it.
+func NewSink(backend Backend, opts ...SinkOption) *Sink {
+	s := &Sink{
+		backend:   backend,
+		batchSize: 500,
+		maxBuffer: 100000,
+		interval:  10 * time.Second,
+		clock:     xtended402.SystemClock,
+		onError: func(err error) {
+			fmt.Printf("Warning: warehouse sink: %v\n", err)
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	go s.loop()
+	return s
+}
+
+// EnsureSchema creates or migrates the backend's table
+func (s *Sink) EnsureSchema(ctx context.Context) error {
+	return s.backend.EnsureSchema(ctx)
+}
+
+// Handle buffers the record for a payment event. Use it as a xtended402.PaymentEventHandler.
+func (s *Sink) Handle(ctx context.Context, event xtended402.PaymentEvent) {
+	record, err := xtended402.NewPaymentRecord(event)
+	if err != nil {
+		s.onError(err)
+		return
+	}
+	s.Add(NewRow(record))
+}
+
+// Add buffers rows, writing a batch in the background once enough have accumulated
+func (s *Sink) Add(rows ...Row) {
+	s.mu.Lock()
+	s.buffer = append(s.buffer, rows...)
+	dropped := 0
+	if len(s.buffer) > s.maxBuffer {
+		dropped = len(s.buffer) - s.maxBuffer
+		s.buffer = s.buffer[dropped:]
+		s.dropped += dropped
+	}
+	full := len(s.buffer) >= s.batchSize
+	s.mu.Unlock()
+
+	if dropped > 0 {
+		s.onError(fmt.Errorf("buffer full, dropped %d rows", dropped))
+	}
+	if full {
+		go func() {
+			_ = s.Flush(context.Background())
+		}()
+	}
+}
+
+// Flush writes all buffered rows in batches. Rows from a failed batch stay buffered.
+func (s *Sink) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	for {
+		s.mu.Lock()
+		n := min(len(s.buffer), s.batchSize)
+		batch := append([]Row(nil), s.buffer[:n]...)
+		dropped := s.dropped
+		s.mu.Unlock()
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := s.backend.Insert(ctx, batch); err != nil {
+			err = fmt.Errorf("failed to write %d rows: %w", len(batch), err)
+			s.onError(err)
+			return err
+		}
+
+		s.mu.Lock()
+		// Rows may have been dropped from the front of the buffer while writing
+		remaining := max(n-(s.dropped-dropped), 0)
+		s.buffer = s.buffer[min(remaining, len(s.buffer)):]
+		s.mu.Unlock()
+	}
+}
+
+// Close stops the flush loop and writes the remaining rows
+func (s *Sink) Close(ctx context.Context) error {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	return s.Flush(ctx)
+}
+
+// Backfill writes every record in store matching query, in batches, directly to the backend.
+// Rows are deduplicated by ID where the warehouse supports it, so backfilling a range that
+// was already streamed is safe.
+func (s *Sink) Backfill(ctx context.Context, store xtended402.PaymentStore, query xtended402.PaymentQuery) (int, error) {
+	records, err := store.ListPayments(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list payments: %w", err)
+	}
+
+	written := 0
+	for start := 0; start < len(records); start += s.batchSize {
+		end := min(start+s.batchSize, len(records))
+		rows := make([]Row, 0, end-start)
+		for _, record := range records[start:end] {
+			rows = append(rows, NewRow(record))
+		}
+		if err := s.backend.Insert(ctx, rows); err != nil {
+			return written, fmt.Errorf("backfill failed after %d rows: %w", written, err)
+		}
+		written += len(rows)
+	}
+	return written, nil
+}
+
+func (s *Sink) loop() {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.clock.After(s.interval):
+			_ = s.Flush(context.Background())
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package xtended402
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook request headers
+const (
+	WebhookEventHeader     = "X-Xtended402-Event"
+	WebhookDeliveryHeader  = "X-Xtended402-Delivery"
+	WebhookSignatureHeader = "X-Xtended402-Signature"
+)
+
+// WebhookDispatcher POSTs payment events as signed JSON to the URLs configured for each event
+// type, retrying failed deliveries with exponential backoff. Use Handle as a
+// PaymentEventHandler:
+//
+//	webhooks := xtended402.NewWebhookDispatcher(secret,
+//		xtended402.WithWebhookEndpoint(xtended402.EventPaymentSettled, "https://fulfillment.internal/hooks/x402"),
+//	)
+//	middleware := ginmw.PaymentMiddleware(routes, server, ginmw.WithEventHandler(webhooks.Handle))
+//
+// Each request carries an X-Xtended402-Signature header of the form "t=<unix>,v1=<hex>", where
+// v1 is the HMAC-SHA256 of "<t>.<body>" keyed with the secret. Receivers check it with
+// VerifyWebhookSignature.
+type WebhookDispatcher struct {
+	secret    []byte
+	endpoints map[PaymentEventType][]string
+	client    *http.Client
+	retry     RetryPolicy
+	clock     Clock
+	onFailure func(event PaymentEvent, url string, err error)
+	dead      DeadLetterQueue
+	templates *EventTemplates
+
+	wg sync.WaitGroup
+}
+
+// WebhookOption configures a WebhookDispatcher
+type WebhookOption func(*WebhookDispatcher)
+
+// WithWebhookEndpoint delivers events of eventType to urls
+func WithWebhookEndpoint(eventType PaymentEventType, urls ...string) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.endpoints[eventType] = append(d.endpoints[eventType], urls...)
+	}
+}
+
+// WithWebhookRetry sets the delivery retry policy (default 5 attempts, 10s timeout, 1s backoff
+// up to 1m)
+func WithWebhookRetry(policy RetryPolicy) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.retry = policy
+	}
+}
+
+// WithWebhookHTTPClient sets the HTTP client used for deliveries
+func WithWebhookHTTPClient(client *http.Client) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.client = client
+	}
+}
+
+// WithWebhookClock sets the clock for backoff delays, timeouts, and signature timestamps
+// (default SystemClock)
+func WithWebhookClock(clock Clock) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.clock = ClockOrSystem(clock)
+	}
+}
+
+// WithWebhookFailureHandler is called when a delivery still fails after all retries
+func WithWebhookFailureHandler(handler func(event PaymentEvent, url string, err error)) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.onFailure = handler
+	}
+}
+
+// WithWebhookDeadLetters records deliveries that fail after all retries in queue, to be
+// replayed with a DeadLetterReplayer once the endpoint recovers
+func WithWebhookDeadLetters(queue DeadLetterQueue) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.dead = queue
+	}
+}
+
+// WithWebhookTemplates sends the rendered template as the body of events that have one,
+// instead of the event's JSON, e.g. to post straight into a chat or CRM endpoint. Templates
+// must produce JSON; use the json function to escape values.
+func WithWebhookTemplates(templates *EventTemplates) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.templates = templates
+	}
+}
+
+// NewWebhookDispatcher creates a WebhookDispatcher that signs payloads with secret
+func NewWebhookDispatcher(secret string, opts ...WebhookOption) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		secret:    []byte(secret),
+		endpoints: make(map[PaymentEventType][]string),
+		client:    &http.Client{},
+		retry:     RetryPolicy{Attempts: 5, Timeout: 10 * time.Second, Backoff: time.Second, MaxBackoff: time.Minute},
+		clock:     SystemClock,
+		onFailure: func(event PaymentEvent, url string, err error) {
+			fmt.Printf("Warning: webhook %s for %s to %s failed: %v\n", event.ID, event.Type, url, err)
+		},
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Handle delivers event to every URL configured for its type in the background. It returns
+// immediately; deliveries outlive ctx's cancellation so a finished request doesn't abort them.
+func (d *WebhookDispatcher) Handle(ctx context.Context, event PaymentEvent) {
+	ctx = context.WithoutCancel(ctx)
+	for _, url := range d.endpoints[event.Type] {
+		d.wg.Add(1)
+		go func(url string) {
+			defer d.wg.Done()
+			if err := d.Deliver(ctx, url, event); err != nil {
+				d.deadLetter(ctx, url, event, err)
+				d.onFailure(event, url, err)
+			}
+		}(url)
+	}
+}
+
+// Deliver POSTs event to url, retrying according to the retry policy. Responses with status
+// 2xx succeed; 408, 429, and 5xx are retried; any other status fails immediately.
+func (d *WebhookDispatcher) Deliver(ctx context.Context, url string, event PaymentEvent) error {
+	body, err := d.payload(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	return retry(ctx, d.clock, d.retry, func(ctx context.Context) error {
+		return d.post(ctx, url, event, body)
+	})
+}
+
+// Wait blocks until in-flight deliveries finish, e.g. during graceful shutdown
+func (d *WebhookDispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// DeadLetterSource returns DeadLetterWebhook
+func (d *WebhookDispatcher) DeadLetterSource() string {
+	return DeadLetterWebhook
+}
+
+// Redeliver delivers a dead letter to its URL again
+func (d *WebhookDispatcher) Redeliver(ctx context.Context, letter *DeadLetter) error {
+	return d.Deliver(ctx, letter.Destination, letter.Event)
+}
+
+func (d *WebhookDispatcher) deadLetter(ctx context.Context, url string, event PaymentEvent, err error) {
+	if d.dead == nil {
+		return
+	}
+	if addErr := d.dead.AddDeadLetter(ctx, newDeadLetter(d.clock, DeadLetterWebhook, url, event, err)); addErr != nil {
+		fmt.Printf("Warning: failed to dead-letter webhook %s to %s: %v\n", event.ID, url, addErr)
+	}
+}
+
+func (d *WebhookDispatcher) payload(ctx context.Context, event PaymentEvent) ([]byte, error) {
+	if d.templates == nil || !d.templates.Has(event.Type) {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode webhook payload: %w", err)
+		}
+		return body, nil
+	}
+
+	text, _, err := d.templates.Render(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid([]byte(text)) {
+		return nil, fmt.Errorf("%s webhook template did not produce valid JSON", event.Type)
+	}
+	return []byte(text), nil
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, url string, event PaymentEvent, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(fmt.Errorf("invalid webhook request: %w", err))
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, string(event.Type))
+	req.Header.Set(WebhookDeliveryHeader, event.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(d.secret, d.clock.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return fmt.Errorf("webhook endpoint returned %d", resp.StatusCode)
+	default:
+		return permanent(fmt.Errorf("webhook endpoint returned %d", resp.StatusCode))
+	}
+}
+
+// SignWebhook returns the signature header value for body sent at timestamp
+func SignWebhook(secret []byte, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + webhookMAC(secret, t, body)
+}
+
+// VerifyWebhookSignature checks a webhook's signature header against its raw body, rejecting
+// signatures more than tolerance from clock's time (0 disables the age check; a nil clock
+// uses SystemClock)
+func VerifyWebhookSignature(secret string, signatureHeader string, body []byte, tolerance time.Duration, clock Clock) error {
+	var t, v1 string
+	for _, part := range strings.Split(signatureHeader, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+	if t == "" || v1 == "" {
+		return errors.New("malformed webhook signature header")
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook signature timestamp: %w", err)
+	}
+	if tolerance > 0 {
+		if age := ClockOrSystem(clock).Now().Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+			return fmt.Errorf("webhook signature timestamp outside tolerance (%s)", age.Round(time.Second))
+		}
+	}
+
+	expected := webhookMAC([]byte(secret), t, body)
+	if !hmac.Equal([]byte(expected), []byte(v1)) {
+		return errors.New("webhook signature mismatch")
+	}
+	return nil
+}
+
+func webhookMAC(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// permanentError marks a failure that retrying can't fix
+type permanentError struct {
+	err error
+}
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
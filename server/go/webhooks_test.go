@@ -0,0 +1,60 @@
+package xtended402_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	xtended402 "github.com/mvpoyatt/xtended402/server/go"
+	"github.com/mvpoyatt/xtended402/server/go/testing/clocktest"
+)
+
+const webhookSecret = "whsec_test"
+
+func TestWebhookSignature(t *testing.T) {
+	clock := clocktest.New(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	var signature string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(xtended402.WebhookSignatureHeader)
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	webhooks := xtended402.NewWebhookDispatcher(webhookSecret, xtended402.WithWebhookClock(clock))
+	if err := webhooks.Deliver(context.Background(), server.URL, sessionEvent); err != nil {
+		t.Fatalf("delivery failed: %v", err)
+	}
+
+	if err := xtended402.VerifyWebhookSignature(webhookSecret, signature, body, 5*time.Minute, clock); err != nil {
+		t.Fatalf("delivered webhook failed verification: %v", err)
+	}
+	if err := xtended402.VerifyWebhookSignature("whsec_other", signature, body, 5*time.Minute, clock); err == nil {
+		t.Fatal("verified with the wrong secret")
+	}
+	if err := xtended402.VerifyWebhookSignature(webhookSecret, signature, append(body, ' '), 5*time.Minute, clock); err == nil {
+		t.Fatal("verified a tampered body")
+	}
+
+	// Replays are rejected once the timestamp falls outside the tolerance
+	clock.Advance(4 * time.Minute)
+	if err := xtended402.VerifyWebhookSignature(webhookSecret, signature, body, 5*time.Minute, clock); err != nil {
+		t.Fatalf("webhook within tolerance failed verification: %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+	if err := xtended402.VerifyWebhookSignature(webhookSecret, signature, body, 5*time.Minute, clock); err == nil {
+		t.Fatal("verified a stale signature")
+	}
+	if err := xtended402.VerifyWebhookSignature(webhookSecret, signature, body, 0, clock); err != nil {
+		t.Fatalf("stale signature failed verification with the age check disabled: %v", err)
+	}
+
+	// Signatures from the future are rejected too
+	future := xtended402.SignWebhook([]byte(webhookSecret), clock.Now().Add(10*time.Minute), body)
+	if err := xtended402.VerifyWebhookSignature(webhookSecret, future, body, 5*time.Minute, clock); err == nil {
+		t.Fatal("verified a signature from the future")
+	}
+}